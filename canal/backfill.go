@@ -0,0 +1,336 @@
+package canal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/replication"
+	"github.com/gongzhxu/go-mysql/schema"
+)
+
+// backfillChunk is a chunk of rows of a table read by Backfill, waiting for the
+// binlog stream to reach the high watermark.
+type backfillChunk struct {
+	table *schema.Table
+	// low and high are the GTID sets executed before and after reading the
+	// rows, high is nil while reading.
+	low  mysql.GTIDSet
+	high mysql.GTIDSet
+
+	keys []string
+	rows map[string][]interface{}
+	// last is the primary key of the last row read
+	last []interface{}
+	// conflicts are the keys of the rows changed after low
+	conflicts map[string]struct{}
+
+	done chan error
+}
+
+type backfillState struct {
+	sync.Mutex
+
+	chunks []*backfillChunk
+	// GTID of the transaction being synced
+	gtid mysql.GTIDSet
+}
+
+// Backfill reads the rows of an existing table in chunks of chunkSize rows ordered
+// by the primary key while the binlog stream continues, e.g. to add a table to a
+// running pipeline. The rows are delivered to EventHandler.OnRow as InsertAction
+// RowsEvents from the sync goroutine, merged with the stream like DBLog does:
+//
+//   - the GTID set executed before (low watermark) and after (high watermark)
+//     reading a chunk are recorded,
+//   - the rows of the chunk changed by transactions of the stream not in the low
+//     watermark are dropped from the chunk, the stream has their newer versions,
+//   - the rest of the chunk is delivered once the stream reached the high watermark.
+//
+// Canal must be running and syncing by GTID, MySQL only. Backfill returns when all
+// the chunks are delivered. As the stream must move to deliver a chunk, set
+// HeartbeatPeriod if the server may be idle. The table must have a primary key.
+// The stream must have full row images (binlog_row_image=FULL).
+func (c *Canal) Backfill(ctx context.Context, schemaName, tableName string, chunkSize int) error {
+	if chunkSize <= 0 {
+		return errors.Errorf("invalid chunk size %d", chunkSize)
+	}
+	if c.cfg.Flavor == mysql.MariaDBFlavor {
+		return errors.New("backfill is only supported for MySQL")
+	}
+	if c.master.GTIDSet() == nil {
+		return errors.New("backfill requires canal to sync by GTID")
+	}
+
+	t, err := c.GetTable(schemaName, tableName)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if len(t.PKColumns) == 0 {
+		return errors.Errorf("table %s.%s has no primary key", schemaName, tableName)
+	}
+
+	var last []interface{}
+	for {
+		chunk, n, err := c.readBackfillChunk(t, last, chunkSize)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if chunk == nil {
+			return nil
+		}
+
+		select {
+		case err = <-chunk.done:
+			if err != nil {
+				return errors.Trace(err)
+			}
+		case <-ctx.Done():
+			c.removeBackfillChunk(chunk)
+			return ctx.Err()
+		case <-c.ctx.Done():
+			c.removeBackfillChunk(chunk)
+			return c.ctx.Err()
+		}
+
+		if n < chunkSize {
+			return nil
+		}
+		last = chunk.last
+	}
+}
+
+// readBackfillChunk reads the chunk of rows after the primary key last, nil if
+// there are no more rows. It returns the number of rows read, which includes
+// the rows dropped for conflicts.
+func (c *Canal) readBackfillChunk(t *schema.Table, last []interface{}, chunkSize int) (*backfillChunk, int, error) {
+	low, err := c.gtidExecuted()
+	if err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+
+	// the chunk is registered before reading the rows, so the changes after
+	// low are not missed
+	chunk := &backfillChunk{
+		table:     t,
+		low:       low,
+		rows:      make(map[string][]interface{}),
+		conflicts: make(map[string]struct{}),
+		done:      make(chan error, 1),
+	}
+	c.backfill.Lock()
+	c.backfill.chunks = append(c.backfill.chunks, chunk)
+	c.backfill.Unlock()
+
+	r, err := c.Execute(backfillQuery(t, last != nil, chunkSize), last...)
+	if err != nil {
+		c.removeBackfillChunk(chunk)
+		return nil, 0, errors.Trace(err)
+	}
+	if r.RowNumber() == 0 {
+		c.removeBackfillChunk(chunk)
+		return nil, 0, nil
+	}
+
+	high, err := c.gtidExecuted()
+	if err != nil {
+		c.removeBackfillChunk(chunk)
+		return nil, 0, errors.Trace(err)
+	}
+
+	rows := make([][]interface{}, 0, r.RowNumber())
+	for _, values := range r.Values {
+		row := make([]interface{}, len(values))
+		for i := range values {
+			row[i] = values[i].Value()
+			if b, ok := row[i].([]byte); ok && i < len(t.Columns) && t.Columns[i].Type == schema.TYPE_STRING {
+				// the binlog has strings for the string columns
+				row[i] = string(b)
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	lastRow := rows[len(rows)-1]
+	chunk.last = make([]interface{}, 0, len(t.PKColumns))
+	for _, i := range t.PKColumns {
+		chunk.last = append(chunk.last, lastRow[i])
+	}
+
+	c.backfill.Lock()
+	chunk.add(rows)
+	chunk.high = high
+	c.backfill.Unlock()
+
+	return chunk, len(rows), nil
+}
+
+// add adds the rows read, except the ones already changed by the stream.
+func (chunk *backfillChunk) add(rows [][]interface{}) {
+	for _, row := range rows {
+		key := backfillKey(chunk.table, row)
+		if _, ok := chunk.conflicts[key]; ok {
+			continue
+		}
+		chunk.keys = append(chunk.keys, key)
+		chunk.rows[key] = row
+	}
+}
+
+// conflict drops the rows changed by the stream.
+func (chunk *backfillChunk) conflict(rows [][]interface{}) {
+	for _, row := range rows {
+		key := backfillKey(chunk.table, row)
+		chunk.conflicts[key] = struct{}{}
+		delete(chunk.rows, key)
+	}
+}
+
+// pendingRows returns the rows to deliver in primary key order.
+func (chunk *backfillChunk) pendingRows() [][]interface{} {
+	rows := make([][]interface{}, 0, len(chunk.rows))
+	for _, key := range chunk.keys {
+		if row, ok := chunk.rows[key]; ok {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func (c *Canal) removeBackfillChunk(chunk *backfillChunk) {
+	c.backfill.Lock()
+	defer c.backfill.Unlock()
+	for i, ch := range c.backfill.chunks {
+		if ch == chunk {
+			c.backfill.chunks = append(c.backfill.chunks[:i], c.backfill.chunks[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *Canal) gtidExecuted() (mysql.GTIDSet, error) {
+	r, err := c.Execute("SELECT @@GLOBAL.gtid_executed")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	s, err := r.GetString(0, 0)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return mysql.ParseMysqlGTIDSet(strings.ReplaceAll(s, "\n", ""))
+}
+
+func backfillQuery(t *schema.Table, after bool, chunkSize int) string {
+	pks := make([]string, 0, len(t.PKColumns))
+	for _, i := range t.PKColumns {
+		pks = append(pks, mysql.QuoteIdentifier(t.Columns[i].Name))
+	}
+	columns := make([]string, 0, len(t.Columns))
+	for _, col := range t.Columns {
+		columns = append(columns, mysql.QuoteIdentifier(col.Name))
+	}
+
+	var where string
+	if after {
+		where = fmt.Sprintf(" WHERE (%s) > (%s)", strings.Join(pks, ","), strings.TrimSuffix(strings.Repeat("?,", len(pks)), ","))
+	}
+	return fmt.Sprintf("SELECT %s FROM %s.%s%s ORDER BY %s LIMIT %d",
+		strings.Join(columns, ","), mysql.QuoteIdentifier(t.Schema), mysql.QuoteIdentifier(t.Name), where, strings.Join(pks, ","), chunkSize)
+}
+
+// backfillKey returns the primary key of row as a string, so the rows read by
+// Backfill and the rows of the binlog, which may have other integer types,
+// can be compared.
+func backfillKey(t *schema.Table, row []interface{}) string {
+	var b strings.Builder
+	for _, i := range t.PKColumns {
+		var v interface{}
+		if i < len(row) {
+			v = row[i]
+		}
+		if bs, ok := v.([]byte); ok {
+			v = string(bs)
+		}
+		fmt.Fprintf(&b, "%v\x00", v)
+	}
+	return b.String()
+}
+
+// backfillGTID records the GTID of the transaction being synced.
+func (c *Canal) backfillGTID(e *replication.GTIDEvent) {
+	c.backfill.Lock()
+	defer c.backfill.Unlock()
+	if len(c.backfill.chunks) == 0 {
+		c.backfill.gtid = nil
+		return
+	}
+	gtid, err := e.GTIDNext()
+	if err != nil {
+		gtid = nil
+	}
+	c.backfill.gtid = gtid
+}
+
+// backfillConflicts drops the rows changed by e from the chunks which were
+// read after the change may have happened.
+func (c *Canal) backfillConflicts(e *RowsEvent) {
+	c.backfill.Lock()
+	defer c.backfill.Unlock()
+
+	for _, chunk := range c.backfill.chunks {
+		if chunk.table.Schema != e.Table.Schema || chunk.table.Name != e.Table.Name {
+			continue
+		}
+		if c.backfill.gtid != nil && chunk.low.Contain(c.backfill.gtid) {
+			continue
+		}
+		chunk.conflict(e.Rows)
+	}
+}
+
+// flushBackfills delivers the chunks whose high watermark the stream reached.
+func (c *Canal) flushBackfills() error {
+	c.backfill.Lock()
+	if len(c.backfill.chunks) == 0 {
+		c.backfill.Unlock()
+		return nil
+	}
+
+	gset := c.master.GTIDSet()
+	var ready []*backfillChunk
+	chunks := c.backfill.chunks[:0]
+	for _, chunk := range c.backfill.chunks {
+		if chunk.high != nil && gset != nil && gset.Contain(chunk.high) {
+			ready = append(ready, chunk)
+		} else {
+			chunks = append(chunks, chunk)
+		}
+	}
+	c.backfill.chunks = chunks
+	c.backfill.Unlock()
+
+	for _, chunk := range ready {
+		rows := chunk.pendingRows()
+		var err error
+		if len(rows) > 0 {
+			c.cfg.Logger.Debug("deliver backfill chunk", slog.String("schema", chunk.table.Schema),
+				slog.String("table", chunk.table.Name), slog.Int("rows", len(rows)))
+			header := &replication.EventHeader{
+				Timestamp: uint32(time.Now().Unix()),
+				EventType: replication.WRITE_ROWS_EVENTv2,
+			}
+			err = c.eventHandler.OnRow(newRowsEvent(chunk.table, InsertAction, rows, header))
+		}
+		chunk.done <- err
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
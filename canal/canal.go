@@ -6,6 +6,7 @@ import (
 	"io"
 	"log/slog"
 	"net"
+	"net/http"
 	"os"
 	"regexp"
 	"strconv"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/gongzhxu/go-mysql/client"
 	"github.com/gongzhxu/go-mysql/dump"
+	"github.com/gongzhxu/go-mysql/internal/parserdriver"
 	"github.com/gongzhxu/go-mysql/mysql"
 	"github.com/gongzhxu/go-mysql/replication"
 	"github.com/gongzhxu/go-mysql/schema"
@@ -40,6 +42,11 @@ type Canal struct {
 
 	eventHandler EventHandler
 
+	trxHandler TransactionHandler
+	trx        *Transaction
+	// gtid is the GTID of the current transaction, for the dead letters
+	gtid string
+
 	connLock sync.Mutex
 	conn     *client.Conn
 
@@ -53,6 +60,20 @@ type Canal struct {
 
 	delay *uint32
 
+	backfill backfillState
+
+	// targetLock guards the databases and tables created on Config.TargetConn
+	targetLock      sync.Mutex
+	targetDatabases map[string]bool
+	targetTables    map[string]bool
+
+	// dumpThrottle and binlogThrottle are nil without limits
+	dumpThrottle   *throttle
+	binlogThrottle *throttle
+
+	// metricsServer serves the metrics, see ServeMetrics
+	metricsServer *http.Server
+
 	ctx    context.Context
 	cancel context.CancelFunc
 }
@@ -63,6 +84,11 @@ var (
 	ErrExcludedTable        = errors.New("excluded table meta")
 )
 
+func init() {
+	// the driver of the values of the DDL parser
+	parserdriver.Install()
+}
+
 func NewCanal(cfg *Config) (*Canal, error) {
 	c := new(Canal)
 	if cfg.Logger == nil {
@@ -87,6 +113,9 @@ func NewCanal(cfg *Config) (*Canal, error) {
 
 	c.delay = new(uint32)
 
+	c.dumpThrottle = newThrottle(c.cfg.Dump.Throttle)
+	c.binlogThrottle = newThrottle(c.cfg.BinlogThrottle)
+
 	var err error
 
 	if err = c.prepareDumper(); err != nil {
@@ -145,9 +174,26 @@ func (c *Canal) prepareDumper() error {
 		return nil
 	}
 
-	if c.dumper, err = dump.NewDumper(dumpPath,
-		c.cfg.Addr, c.cfg.User, c.cfg.Password); err != nil {
-		return errors.Trace(err)
+	switch c.cfg.Dump.Tool {
+	case "", "mysqldump":
+		if c.dumper, err = dump.NewDumper(dumpPath,
+			c.cfg.Addr, c.cfg.User, c.cfg.Password); err != nil {
+			return errors.Trace(err)
+		}
+	case "mysqlpump":
+		b, err := dump.NewMysqlpumpBackend(dumpPath)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		c.dumper = dump.NewDumperWithBackend(b, c.cfg.Addr, c.cfg.User, c.cfg.Password)
+	case "mydumper":
+		b, err := dump.NewMydumperBackend(dumpPath)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		c.dumper = dump.NewDumperWithBackend(b, c.cfg.Addr, c.cfg.User, c.cfg.Password)
+	default:
+		return errors.Errorf("unsupported dump tool %q", c.cfg.Dump.Tool)
 	}
 
 	if c.dumper == nil {
@@ -176,6 +222,7 @@ func (c *Canal) prepareDumper() error {
 	c.dumper.SetMaxAllowedPacket(c.cfg.Dump.MaxAllowedPacketMB)
 	c.dumper.SetProtocol(c.cfg.Dump.Protocol)
 	c.dumper.SetExtraOptions(c.cfg.Dump.ExtraOptions)
+	c.dumper.SetProgressHandler(c.cfg.Dump.Progress)
 	// Use hex blob for mysqldump
 	c.dumper.SetHexBlob(true)
 
@@ -270,6 +317,9 @@ func (c *Canal) Close() {
 		c.conn = nil
 	}
 	c.connLock.Unlock()
+	if c.metricsServer != nil {
+		_ = c.metricsServer.Close()
+	}
 
 	_ = c.eventHandler.OnPosSynced(nil, c.master.Position(), c.master.GTIDSet(), true)
 }
@@ -383,6 +433,12 @@ func (c *Canal) GetTable(db string, table string) (*schema.Table, error) {
 		}
 		return nil, err
 	}
+	if c.cfg.SortTransactionRows {
+		if err := t.FetchForeignKeys(c); err != nil {
+			// the rows of the table are sorted as without foreign keys
+			c.cfg.Logger.Warn("canal get table foreign keys err", slog.String("table", key), slog.Any("error", errors.Trace(err)))
+		}
+	}
 
 	c.tableLock.Lock()
 	c.tables[key] = t
@@ -449,6 +505,17 @@ func (c *Canal) checkBinlogRowFormat() error {
 }
 
 func (c *Canal) prepareSyncer() error {
+	cfg, err := c.syncerConfig()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	c.syncer = replication.NewBinlogSyncer(cfg)
+
+	return nil
+}
+
+// syncerConfig returns the config of the binlog syncer of the canal.
+func (c *Canal) syncerConfig() (replication.BinlogSyncerConfig, error) {
 	cfg := replication.BinlogSyncerConfig{
 		ServerID:                c.cfg.ServerID,
 		Flavor:                  c.cfg.Flavor,
@@ -488,20 +555,18 @@ func (c *Canal) prepareSyncer() error {
 	} else {
 		host, port, err := net.SplitHostPort(c.cfg.Addr)
 		if err != nil {
-			return errors.Errorf("invalid MySQL address format %s, must host:port", c.cfg.Addr)
+			return cfg, errors.Errorf("invalid MySQL address format %s, must host:port", c.cfg.Addr)
 		}
 		portNumber, err := strconv.ParseUint(port, 10, 16)
 		if err != nil {
-			return errors.Trace(err)
+			return cfg, errors.Trace(err)
 		}
 
 		cfg.Host = host
 		cfg.Port = uint16(portNumber)
 	}
 
-	c.syncer = replication.NewBinlogSyncer(cfg)
-
-	return nil
+	return cfg, nil
 }
 
 func (c *Canal) connect(options ...client.Option) (*client.Conn, error) {
@@ -187,6 +187,23 @@ func (s *canalTestSuite) TestAnalyzeAdvancesSyncedPos() {
 	require.Greater(s.T(), endingPos.Pos, startingPos.Pos)
 }
 
+func (s *canalTestSuite) TestPositionAtTimestamp() {
+	<-s.c.WaitDumpDone()
+
+	logs, err := s.c.binaryLogs()
+	require.NoError(s.T(), err)
+	pos, err := s.c.PositionAtTimestamp(time.Unix(0, 0))
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), mysql.Position{Name: logs[0].name, Pos: 4}, pos)
+
+	s.execute("INSERT INTO test.canal_test (name) VALUES (?)", "ts")
+	end, err := s.c.GetMasterPos()
+	require.NoError(s.T(), err)
+	pos, err = s.c.PositionAtTimestamp(time.Now().Add(time.Hour))
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), end, pos)
+}
+
 func (s *canalTestSuite) TestCanalFilter() {
 	// included
 	sch, err := s.c.GetTable("test", "canal_test")
@@ -12,6 +12,7 @@ import (
 	"github.com/pingcap/errors"
 
 	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/dump"
 	"github.com/gongzhxu/go-mysql/mysql"
 	"github.com/gongzhxu/go-mysql/utils"
 )
@@ -21,6 +22,10 @@ type DumpConfig struct {
 	// If not set, ignore using mysqldump.
 	ExecutionPath string `toml:"mysqldump"`
 
+	// Tool is the dump tool at ExecutionPath: mysqldump, the default, mysqlpump
+	// or mydumper, see dump.Backend.
+	Tool string `toml:"tool"`
+
 	// Will override Databases, tables is in database table_db
 	Tables  []string `toml:"tables"`
 	TableDB string   `toml:"table_db"`
@@ -48,6 +53,12 @@ type DumpConfig struct {
 
 	// Set extra options
 	ExtraOptions []string `toml:"extra_options"`
+
+	// Progress, if set, is called with the bytes dumped per table
+	Progress dump.ProgressHandler `toml:"-"`
+
+	// Throttle limits the rate of the rows of the dump
+	Throttle ThrottleConfig `toml:"throttle"`
 }
 
 type Config struct {
@@ -74,8 +85,18 @@ type Config struct {
 	// discard row event without table meta
 	DiscardNoMetaRowEvent bool `toml:"discard_no_meta_row_event"`
 
+	// SortTransactionRows sorts the rows of the transactions passed to the
+	// TransactionHandler by the foreign keys of their tables, see
+	// SortRowsByForeignKeys. The foreign keys are only fetched with the tables
+	// if it is set.
+	SortTransactionRows bool `toml:"sort_transaction_rows"`
+
 	Dump DumpConfig `toml:"dump"`
 
+	// BinlogThrottle limits the rate of the binlog events, see DumpConfig.Throttle
+	// for the dump
+	BinlogThrottle ThrottleConfig `toml:"binlog_throttle"`
+
 	UseDecimal bool `toml:"use_decimal"`
 	ParseTime  bool `toml:"parse_time"`
 
@@ -96,6 +117,22 @@ type Config struct {
 	// and requires additional privileges.
 	DisableFlushBinlogWhileWaiting bool `toml:"disable_flush_binlog_while_waiting"`
 
+	// DeadLetterStore, if set, keeps the rows events of the binlog whose handler
+	// returns an error, see DeadLetter, and the sync goes on instead of
+	// stopping. Canal.ReplayDeadLetters passes them to the handler again.
+	DeadLetterStore DeadLetterStore `toml:"-"`
+
+	// TargetConn, if set, is the connection of the target the rows are replayed
+	// into, e.g. by a TransactionReceiver: the databases and tables of the rows
+	// are created on it if they don't exist, with Table.ToCreateTableSQL, before
+	// the rows of the dump and of the binlog are passed to the handler.
+	TargetConn *client.Conn `toml:"-"`
+
+	// Collector collects the metrics of the dump and the sync, see
+	// Canal.ServeMetrics to serve the ones of an ExpvarCollector or a
+	// PrometheusCollector
+	Collector Collector `toml:"-"`
+
 	// Set TLS config
 	TLSConfig *tls.Config
 
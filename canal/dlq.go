@@ -0,0 +1,374 @@
+package canal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/replication"
+)
+
+// DeadLetter is a rows event of the binlog whose handler returned an error, put
+// in Config.DeadLetterStore instead of stopping the canal.
+type DeadLetter struct {
+	// ID is set by the store.
+	ID uint64 `json:"id"`
+	// Pos is the position of the transaction of the event, and GTID its GTID,
+	// empty if GTID mode is off.
+	Pos    mysql.Position           `json:"pos"`
+	GTID   string                   `json:"gtid,omitempty"`
+	Header *replication.EventHeader `json:"header"`
+	Schema string                   `json:"schema"`
+	Table  string                   `json:"table"`
+	Action string                   `json:"action"`
+	Rows   [][]interface{}          `json:"-"`
+	// Error is the error returned by the handler, and Time when it was.
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// DeadLetterStore keeps the dead letters to replay them later, see
+// Canal.ReplayDeadLetters. NewFileDeadLetterStore returns a store writing them
+// to a file.
+type DeadLetterStore interface {
+	// Put adds dl, setting its ID.
+	Put(dl *DeadLetter) error
+	// List returns the dead letters in the order they were put.
+	List() ([]*DeadLetter, error)
+	// Remove removes the dead letters of the IDs.
+	Remove(ids []uint64) error
+}
+
+// deadLetterValue is a value of a row of a dead letter in JSON, with its Go type
+// to replay it as it was, like ["int32",1] or ["bytes","AQI="].
+type deadLetterValue struct {
+	v interface{}
+}
+
+func (v deadLetterValue) MarshalJSON() ([]byte, error) {
+	var typ string
+	value := v.v
+	switch x := v.v.(type) {
+	case nil:
+		return []byte("null"), nil
+	case int8, int16, int32, int64, int, uint8, uint16, uint32, uint64, uint, float32, float64, bool, string:
+		typ = fmt.Sprintf("%T", x)
+	case []byte:
+		typ = "bytes"
+	case time.Time:
+		typ, value = "time", x.Format(time.RFC3339Nano)
+	case decimal.Decimal:
+		typ, value = "decimal", x.String()
+	default:
+		// kept as text, not to lose the dead letter
+		typ, value = "string", fmt.Sprint(x)
+	}
+	return json.Marshal([]interface{}{typ, value})
+}
+
+func (v *deadLetterValue) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		v.v = nil
+		return nil
+	}
+	var pair []json.RawMessage
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return errors.Trace(err)
+	}
+	if len(pair) != 2 {
+		return errors.Errorf("invalid dead letter value %s", data)
+	}
+	var typ string
+	if err := json.Unmarshal(pair[0], &typ); err != nil {
+		return errors.Trace(err)
+	}
+
+	raw := string(pair[1])
+	var err error
+	switch typ {
+	case "int8", "int16", "int32", "int64", "int":
+		var i int64
+		i, err = strconv.ParseInt(raw, 10, 64)
+		switch typ {
+		case "int8":
+			v.v = int8(i)
+		case "int16":
+			v.v = int16(i)
+		case "int32":
+			v.v = int32(i)
+		case "int64":
+			v.v = i
+		default:
+			v.v = int(i)
+		}
+	case "uint8", "uint16", "uint32", "uint64", "uint":
+		var u uint64
+		u, err = strconv.ParseUint(raw, 10, 64)
+		switch typ {
+		case "uint8":
+			v.v = uint8(u)
+		case "uint16":
+			v.v = uint16(u)
+		case "uint32":
+			v.v = uint32(u)
+		case "uint64":
+			v.v = u
+		default:
+			v.v = uint(u)
+		}
+	case "float32":
+		var f float64
+		f, err = strconv.ParseFloat(raw, 32)
+		v.v = float32(f)
+	case "float64":
+		v.v, err = strconv.ParseFloat(raw, 64)
+	case "bool":
+		v.v, err = strconv.ParseBool(raw)
+	case "string":
+		var s string
+		err = json.Unmarshal(pair[1], &s)
+		v.v = s
+	case "bytes":
+		var b []byte
+		err = json.Unmarshal(pair[1], &b)
+		v.v = b
+	case "time":
+		var s string
+		if err = json.Unmarshal(pair[1], &s); err == nil {
+			v.v, err = time.Parse(time.RFC3339Nano, s)
+		}
+	case "decimal":
+		var s string
+		if err = json.Unmarshal(pair[1], &s); err == nil {
+			v.v, err = decimal.NewFromString(s)
+		}
+	default:
+		return errors.Errorf("invalid dead letter value type %q", typ)
+	}
+	return errors.Annotatef(err, "dead letter value %s", data)
+}
+
+type deadLetterJSON struct {
+	*deadLetterAlias
+	Rows [][]deadLetterValue `json:"rows"`
+}
+
+type deadLetterAlias DeadLetter
+
+// MarshalJSON marshals dl with the Go types of the values of the rows.
+func (dl *DeadLetter) MarshalJSON() ([]byte, error) {
+	rows := make([][]deadLetterValue, len(dl.Rows))
+	for i, row := range dl.Rows {
+		rows[i] = make([]deadLetterValue, len(row))
+		for j, v := range row {
+			rows[i][j] = deadLetterValue{v}
+		}
+	}
+	return json.Marshal(deadLetterJSON{(*deadLetterAlias)(dl), rows})
+}
+
+func (dl *DeadLetter) UnmarshalJSON(data []byte) error {
+	j := deadLetterJSON{deadLetterAlias: (*deadLetterAlias)(dl)}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	dl.Rows = make([][]interface{}, len(j.Rows))
+	for i, row := range j.Rows {
+		dl.Rows[i] = make([]interface{}, len(row))
+		for k, v := range row {
+			dl.Rows[i][k] = v.v
+		}
+	}
+	return nil
+}
+
+// FileDeadLetterStore is a DeadLetterStore writing the dead letters to a file,
+// one JSON object per line.
+type FileDeadLetterStore struct {
+	path string
+
+	mu     sync.Mutex
+	nextID uint64
+}
+
+// NewFileDeadLetterStore returns the store of the file at path, created by the
+// first Put.
+func NewFileDeadLetterStore(path string) (*FileDeadLetterStore, error) {
+	s := &FileDeadLetterStore{path: path, nextID: 1}
+	dls, err := s.List()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(dls) > 0 {
+		s.nextID = dls[len(dls)-1].ID + 1
+	}
+	return s, nil
+}
+
+func (s *FileDeadLetterStore) Put(dl *DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dl.ID = s.nextID
+	data, err := json.Marshal(dl)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if _, err = f.Write(append(data, '\n')); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+	s.nextID++
+	return nil
+}
+
+func (s *FileDeadLetterStore) List() ([]*DeadLetter, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	var dls []*DeadLetter
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, len(data)+1)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		dl := new(DeadLetter)
+		if err = json.Unmarshal(line, dl); err != nil {
+			return nil, errors.Annotatef(err, "dead letter %d of %s", len(dls)+1, s.path)
+		}
+		dls = append(dls, dl)
+	}
+	return dls, errors.Trace(sc.Err())
+}
+
+// Remove rewrites the file without the dead letters of ids.
+func (s *FileDeadLetterStore) Remove(ids []uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dls, err := s.List()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	removed := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		removed[id] = true
+	}
+
+	var buf bytes.Buffer
+	for _, dl := range dls {
+		if removed[dl.ID] {
+			continue
+		}
+		data, err := json.Marshal(dl)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(os.Rename(tmp, s.path))
+}
+
+// deadLetter puts the rows of e in the dead letter store, for the error err of
+// the handler. The error is returned if the store fails.
+func (c *Canal) deadLetter(e *RowsEvent, err error) error {
+	dl := &DeadLetter{
+		Pos:    c.master.Position(),
+		GTID:   c.gtid,
+		Header: e.Header,
+		Schema: e.Table.Schema,
+		Table:  e.Table.Name,
+		Action: e.Action,
+		Rows:   e.Rows,
+		Error:  err.Error(),
+		Time:   time.Now(),
+	}
+	if perr := c.cfg.DeadLetterStore.Put(dl); perr != nil {
+		c.cfg.Logger.Error("put dead letter", slog.Any("error", perr))
+		return errors.Annotatef(err, "put dead letter: %v", perr)
+	}
+	c.cfg.Logger.Warn("rows event put in the dead letter store",
+		slog.String("schema", dl.Schema), slog.String("table", dl.Table),
+		slog.Uint64("id", dl.ID), slog.Any("error", err))
+	return nil
+}
+
+// ReplayDeadLetters passes the rows of the dead letters of Config.DeadLetterStore
+// to the event handler again, in order, with the current structure of their
+// tables, and removes them. It stops at the first dead letter whose handler
+// fails again, returning the error. It can be called while the canal runs, the
+// handler is then called concurrently with the sync. It returns the number of
+// dead letters replayed.
+func (c *Canal) ReplayDeadLetters() (int, error) {
+	if c.cfg.DeadLetterStore == nil {
+		return 0, errors.New("no dead letter store")
+	}
+	dls, err := c.cfg.DeadLetterStore.List()
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+
+	var replayed []uint64
+	for _, dl := range dls {
+		if err = c.replayDeadLetter(dl); err != nil {
+			err = errors.Annotatef(err, "replay dead letter %d", dl.ID)
+			break
+		}
+		replayed = append(replayed, dl.ID)
+	}
+	if len(replayed) > 0 {
+		if rerr := c.cfg.DeadLetterStore.Remove(replayed); rerr != nil && err == nil {
+			err = errors.Trace(rerr)
+		}
+	}
+	return len(replayed), err
+}
+
+func (c *Canal) replayDeadLetter(dl *DeadLetter) error {
+	t, err := c.GetTable(dl.Schema, dl.Table)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e := newRowsEvent(t, dl.Action, dl.Rows, dl.Header)
+	if h, ok := c.eventHandler.(RowsWithHeaderHandler); ok && dl.Header != nil {
+		return h.OnRowsWithHeader(dl.Header, nil, e)
+	}
+	return c.eventHandler.OnRow(e)
+}
+
+func (c *Canal) setGTID(e mysql.BinlogGTIDEvent) {
+	c.gtid = ""
+	if gtid, err := e.GTIDNext(); err == nil && gtid != nil {
+		c.gtid = gtid.String()
+	}
+}
@@ -0,0 +1,98 @@
+package canal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/replication"
+	"github.com/gongzhxu/go-mysql/schema"
+)
+
+type failingRowsHandler struct {
+	DummyEventHandler
+	fail bool
+	rows []*RowsEvent
+}
+
+func (h *failingRowsHandler) OnRow(e *RowsEvent) error {
+	if h.fail {
+		return errors.New("sink unavailable")
+	}
+	h.rows = append(h.rows, e)
+	return nil
+}
+
+func TestDeadLetters(t *testing.T) {
+	store, err := NewFileDeadLetterStore(filepath.Join(t.TempDir(), "dlq.jsonl"))
+	require.NoError(t, err)
+
+	h := &failingRowsHandler{fail: true}
+	c := new(Canal)
+	c.cfg = NewDefaultConfig()
+	c.cfg.DeadLetterStore = store
+	c.master = &masterInfo{logger: c.cfg.Logger, pos: mysql.Position{Name: "mysql-bin.000003", Pos: 120}}
+	c.eventHandler = h
+	c.tables = map[string]*schema.Table{
+		"test.t": {Schema: "test", Name: "t", Columns: []schema.TableColumn{{Name: "id"}, {Name: "b"}, {Name: "d"}, {Name: "ts"}, {Name: "f"}}},
+	}
+
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+	rows := [][]interface{}{{int32(1), []byte{0x0, 0xff}, decimal.RequireFromString("1.50"), ts, float32(0.5)}, {int32(2), nil, nil, nil, nil}}
+	tableMap := &replication.TableMapEvent{Schema: []byte("test"), Table: []byte("t"), ColumnCount: 5}
+	header := &replication.EventHeader{EventType: replication.WRITE_ROWS_EVENTv2, ServerID: 11, LogPos: 300, Timestamp: 1700000000}
+	for i := 0; i < 2; i++ {
+		require.NoError(t, c.handleEvent(&replication.BinlogEvent{
+			Header: header,
+			Event:  &replication.RowsEvent{Table: tableMap, Rows: rows},
+		}))
+	}
+
+	// reopened, the next ID follows the ones in the file
+	store, err = NewFileDeadLetterStore(store.path)
+	require.NoError(t, err)
+	c.cfg.DeadLetterStore = store
+	dls, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, dls, 2)
+	dl := dls[0]
+	require.Equal(t, uint64(1), dl.ID)
+	require.Equal(t, uint64(2), dls[1].ID)
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000003", Pos: 120}, dl.Pos)
+	require.Equal(t, header, dl.Header)
+	require.Equal(t, "test", dl.Schema)
+	require.Equal(t, "t", dl.Table)
+	require.Equal(t, InsertAction, dl.Action)
+	require.Equal(t, "sink unavailable", dl.Error)
+	require.Equal(t, rows[1], dl.Rows[1])
+	require.Equal(t, int32(1), dl.Rows[0][0])
+	require.Equal(t, []byte{0x0, 0xff}, dl.Rows[0][1])
+	require.True(t, decimal.RequireFromString("1.50").Equal(dl.Rows[0][2].(decimal.Decimal)))
+	require.True(t, ts.Equal(dl.Rows[0][3].(time.Time)))
+	require.Equal(t, float32(0.5), dl.Rows[0][4])
+
+	// still failing, nothing is replayed
+	n, err := c.ReplayDeadLetters()
+	require.Error(t, err)
+	require.Equal(t, 0, n)
+
+	h.fail = false
+	n, err = c.ReplayDeadLetters()
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Len(t, h.rows, 2)
+	require.Equal(t, "t", h.rows[0].Table.Name)
+	require.Equal(t, int32(2), h.rows[1].Rows[1][0])
+	dls, err = store.List()
+	require.NoError(t, err)
+	require.Empty(t, dls)
+
+	dl = &DeadLetter{Schema: "test", Table: "t"}
+	require.NoError(t, store.Put(dl))
+	require.Equal(t, uint64(3), dl.ID)
+}
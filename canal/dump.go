@@ -53,6 +53,9 @@ func (h *dumpParseHandler) Data(db string, table string, values []string) error
 		h.c.cfg.Logger.Error("error getting table information", slog.String("database", db), slog.String("table", table), slog.Any("error", err))
 		return errors.Trace(err)
 	}
+	if err = h.c.createTargetTable(tableInfo); err != nil {
+		return errors.Trace(err)
+	}
 
 	vs := make([]interface{}, len(values))
 
@@ -111,8 +114,20 @@ func (h *dumpParseHandler) Data(db string, table string, values []string) error
 		}
 	}
 
+	var size int64
+	for _, v := range values {
+		size += int64(len(v))
+	}
+	if err = h.c.dumpThrottle.wait(h.c.ctx, 1, size); err != nil {
+		return err
+	}
+
 	events := newRowsEvent(tableInfo, InsertAction, [][]interface{}{vs}, nil)
-	return h.c.eventHandler.OnRow(events)
+	if err = h.c.eventHandler.OnRow(events); err != nil {
+		return err
+	}
+	h.c.rowDumped(db, table)
+	return nil
 }
 
 func (c *Canal) AddDumpDatabases(dbs ...string) {
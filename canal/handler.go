@@ -24,6 +24,16 @@ type EventHandler interface {
 	String() string
 }
 
+// RowsWithHeaderHandler is for event handlers that want the provenance of the rows
+// of the binlog: the header of the rows event (timestamp, server ID, log position)
+// and the TABLE_MAP_EVENT with its optional metadata. If the event handler
+// implements it, OnRowsWithHeader is called instead of OnRow for the rows events
+// of the binlog, and for the dead letters replayed, with a nil tableMap. The
+// rows of the dump and Backfill are still passed to OnRow.
+type RowsWithHeaderHandler interface {
+	OnRowsWithHeader(header *replication.EventHeader, tableMap *replication.TableMapEvent, e *RowsEvent) error
+}
+
 type DummyEventHandler struct{}
 
 func (h *DummyEventHandler) OnRotate(*replication.EventHeader, *replication.RotateEvent) error {
@@ -0,0 +1,234 @@
+package canal
+
+import (
+	"expvar"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/replication"
+)
+
+// Collector collects the metrics of a Canal, e.g. to export them to expvar
+// (see ExpvarCollector) or Prometheus (see PrometheusCollector), with
+// counters, a histogram and gauges updated by the methods. The methods are called by the goroutines of the dump
+// and the sync, and must not block.
+type Collector interface {
+	// RowDumped is called for each row of the dump once handled.
+	RowDumped(db string, table string)
+	// EventHandled is called for each binlog event, with the time taken to
+	// handle it, the handlers included.
+	EventHandled(eventType replication.EventType, d time.Duration)
+	// PositionSynced is called when the synced position is saved, with the
+	// GTID set and the replication delay, see Canal.GetDelay.
+	PositionSynced(pos mysql.Position, gset mysql.GTIDSet, delay time.Duration)
+}
+
+// ExpvarCollector is a Collector publishing the metrics as an expvar.Map with
+// the keys rows_dumped and events, maps by table and by event type, handle_ns,
+// the total time taken to handle the events, position, gtid_set and
+// delay_seconds.
+type ExpvarCollector struct {
+	m *expvar.Map
+
+	rowsDumped   expvar.Map
+	events       expvar.Map
+	handleTime   expvar.Int
+	position     expvar.String
+	gtidSet      expvar.String
+	delaySeconds expvar.Int
+}
+
+// NewExpvarCollector publishes the metrics under name. Like expvar.Publish, it
+// panics if name is already used.
+func NewExpvarCollector(name string) *ExpvarCollector {
+	c := &ExpvarCollector{m: expvar.NewMap(name)}
+	c.m.Set("rows_dumped", c.rowsDumped.Init())
+	c.m.Set("events", c.events.Init())
+	c.m.Set("handle_ns", &c.handleTime)
+	c.m.Set("position", &c.position)
+	c.m.Set("gtid_set", &c.gtidSet)
+	c.m.Set("delay_seconds", &c.delaySeconds)
+	return c
+}
+
+func (c *ExpvarCollector) RowDumped(db string, table string) {
+	c.rowsDumped.Add(db+"."+table, 1)
+}
+
+func (c *ExpvarCollector) EventHandled(eventType replication.EventType, d time.Duration) {
+	c.events.Add(eventType.String(), 1)
+	c.handleTime.Add(int64(d))
+}
+
+func (c *ExpvarCollector) PositionSynced(pos mysql.Position, gset mysql.GTIDSet, delay time.Duration) {
+	c.position.Set(pos.String())
+	if gset != nil {
+		c.gtidSet.Set(gset.String())
+	}
+	c.delaySeconds.Set(int64(delay / time.Second))
+}
+
+// PrometheusCollector is a Collector which is also a prometheus.Collector, to
+// register with a prometheus.Registerer, with the metrics:
+//
+//	<namespace>_rows_dumped_total{db, table}
+//	<namespace>_events_total{type}
+//	<namespace>_event_handle_seconds{type}, a histogram
+//	<namespace>_binlog_position{file}, the position synced in the file
+//	<namespace>_gtid_set_info{gtid_set}, 1 for the GTID set synced
+//	<namespace>_delay_seconds
+type PrometheusCollector struct {
+	rowsDumped   *prometheus.CounterVec
+	events       *prometheus.CounterVec
+	handleTime   *prometheus.HistogramVec
+	delaySeconds prometheus.Gauge
+	positionDesc *prometheus.Desc
+	gtidSetDesc  *prometheus.Desc
+
+	// mu guards the position and the GTID set synced
+	mu       sync.Mutex
+	position mysql.Position
+	gtidSet  string
+}
+
+// NewPrometheusCollector returns a collector of the metrics named
+// <namespace>_<name>, like canal_events_total for the namespace canal.
+func NewPrometheusCollector(namespace string) *PrometheusCollector {
+	return &PrometheusCollector{
+		rowsDumped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rows_dumped_total",
+			Help:      "Number of rows of the dump handled.",
+		}, []string{"db", "table"}),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_total",
+			Help:      "Number of binlog events handled.",
+		}, []string{"type"}),
+		handleTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "event_handle_seconds",
+			Help:      "Time taken to handle the binlog events, the handlers included.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"type"}),
+		delaySeconds: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "delay_seconds",
+			Help:      "Replication delay of the binlog events synced.",
+		}),
+		positionDesc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "binlog_position"),
+			"Position synced in the binlog file.", []string{"file"}, nil),
+		gtidSetDesc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "gtid_set_info"),
+			"GTID set synced, 1 for the set.", []string{"gtid_set"}, nil),
+	}
+}
+
+func (c *PrometheusCollector) RowDumped(db string, table string) {
+	c.rowsDumped.WithLabelValues(db, table).Inc()
+}
+
+func (c *PrometheusCollector) EventHandled(eventType replication.EventType, d time.Duration) {
+	c.events.WithLabelValues(eventType.String()).Inc()
+	c.handleTime.WithLabelValues(eventType.String()).Observe(d.Seconds())
+}
+
+func (c *PrometheusCollector) PositionSynced(pos mysql.Position, gset mysql.GTIDSet, delay time.Duration) {
+	c.mu.Lock()
+	c.position = pos
+	if gset != nil {
+		c.gtidSet = gset.String()
+	}
+	c.mu.Unlock()
+	c.delaySeconds.Set(delay.Seconds())
+}
+
+// Describe implements prometheus.Collector.
+func (c *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.rowsDumped.Describe(ch)
+	c.events.Describe(ch)
+	c.handleTime.Describe(ch)
+	c.delaySeconds.Describe(ch)
+	ch <- c.positionDesc
+	ch <- c.gtidSetDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *PrometheusCollector) Collect(ch chan<- prometheus.Metric) {
+	c.rowsDumped.Collect(ch)
+	c.events.Collect(ch)
+	c.handleTime.Collect(ch)
+	c.delaySeconds.Collect(ch)
+
+	c.mu.Lock()
+	pos, gset := c.position, c.gtidSet
+	c.mu.Unlock()
+	if pos.Name != "" {
+		ch <- prometheus.MustNewConstMetric(c.positionDesc, prometheus.GaugeValue, float64(pos.Pos), pos.Name)
+	}
+	if gset != "" {
+		ch <- prometheus.MustNewConstMetric(c.gtidSetDesc, prometheus.GaugeValue, 1, gset)
+	}
+}
+
+// ServeMetrics serves the expvar variables of the process at /debug/vars on
+// addr, the ones of an ExpvarCollector included, until the canal is closed.
+// If Config.Collector is a prometheus.Collector, like PrometheusCollector, its
+// metrics are served at /metrics too.
+func (c *Canal) ServeMetrics(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	if collector, ok := c.cfg.Collector.(prometheus.Collector); ok {
+		registry := prometheus.NewRegistry()
+		if err := registry.Register(collector); err != nil {
+			return errors.Trace(err)
+		}
+		mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	srv := &http.Server{Handler: mux}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.ctx.Err() != nil || c.metricsServer != nil {
+		_ = l.Close()
+		return errors.New("canal closed or metrics already served")
+	}
+	c.metricsServer = srv
+
+	go func() {
+		if err := srv.Serve(l); err != nil && errors.Cause(err) != http.ErrServerClosed {
+			c.cfg.Logger.Error("serve metrics", slog.Any("error", err))
+		}
+	}()
+	return nil
+}
+
+func (c *Canal) rowDumped(db string, table string) {
+	if c.cfg.Collector != nil {
+		c.cfg.Collector.RowDumped(db, table)
+	}
+}
+
+func (c *Canal) eventHandled(ev *replication.BinlogEvent, start time.Time) {
+	if c.cfg.Collector != nil {
+		c.cfg.Collector.EventHandled(ev.Header.EventType, time.Since(start))
+	}
+}
+
+func (c *Canal) positionSynced(pos mysql.Position, gset mysql.GTIDSet) {
+	if c.cfg.Collector != nil {
+		c.cfg.Collector.PositionSynced(pos, gset, time.Duration(atomic.LoadUint32(c.delay))*time.Second)
+	}
+}
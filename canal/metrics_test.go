@@ -0,0 +1,118 @@
+package canal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/replication"
+)
+
+func TestExpvarCollector(t *testing.T) {
+	collector := NewExpvarCollector("canal_test")
+
+	c := new(Canal)
+	c.cfg = NewDefaultConfig()
+	c.cfg.Collector = collector
+	c.master = &masterInfo{logger: c.cfg.Logger}
+	c.eventHandler = &DummyEventHandler{}
+	c.delay = new(uint32)
+	*c.delay = 3
+
+	c.rowDumped("test", "t")
+	c.rowDumped("test", "t")
+	ev := &replication.BinlogEvent{
+		Header: &replication.EventHeader{EventType: replication.XID_EVENT, LogPos: 400},
+		Event:  &replication.XIDEvent{},
+	}
+	c.master.Update(mysql.Position{Name: "mysql-bin.000001", Pos: 4})
+	require.NoError(t, c.handleEvent(ev))
+	c.eventHandled(ev, time.Now().Add(-time.Millisecond))
+
+	// the HTTP endpoint
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	require.NoError(t, c.ServeMetrics(addr))
+	defer c.metricsServer.Close()
+	require.Error(t, c.ServeMetrics("127.0.0.1:0"))
+
+	resp, err := http.Get("http://" + addr + "/debug/vars")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var vars struct {
+		Metrics struct {
+			RowsDumped   map[string]int64 `json:"rows_dumped"`
+			Events       map[string]int64 `json:"events"`
+			HandleNs     int64            `json:"handle_ns"`
+			Position     string           `json:"position"`
+			DelaySeconds int64            `json:"delay_seconds"`
+		} `json:"canal_test"`
+	}
+	require.NoError(t, json.Unmarshal(data, &vars))
+	require.Equal(t, map[string]int64{"test.t": 2}, vars.Metrics.RowsDumped)
+	require.Equal(t, map[string]int64{"XIDEvent": 1}, vars.Metrics.Events)
+	require.GreaterOrEqual(t, vars.Metrics.HandleNs, int64(time.Millisecond))
+	require.Equal(t, "(mysql-bin.000001, 400)", vars.Metrics.Position)
+	require.EqualValues(t, 3, vars.Metrics.DelaySeconds)
+}
+
+func TestPrometheusCollector(t *testing.T) {
+	collector := NewPrometheusCollector("canal")
+
+	c := new(Canal)
+	c.cfg = NewDefaultConfig()
+	c.cfg.Collector = collector
+	c.master = &masterInfo{logger: c.cfg.Logger}
+	c.eventHandler = &DummyEventHandler{}
+	c.delay = new(uint32)
+	*c.delay = 3
+
+	c.rowDumped("test", "t")
+	c.rowDumped("test", "t")
+	gset, err := mysql.ParseMysqlGTIDSet("de278ad0-2106-11e4-9f8e-6edd0ca20947:1-2")
+	require.NoError(t, err)
+	ev := &replication.BinlogEvent{
+		Header: &replication.EventHeader{EventType: replication.XID_EVENT, LogPos: 400},
+		Event:  &replication.XIDEvent{GSet: gset},
+	}
+	c.master.Update(mysql.Position{Name: "mysql-bin.000001", Pos: 4})
+	require.NoError(t, c.handleEvent(ev))
+	c.eventHandled(ev, time.Now().Add(-time.Millisecond))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	require.NoError(t, c.ServeMetrics(addr))
+	defer c.metricsServer.Close()
+
+	resp, err := http.Get("http://" + addr + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	for _, line := range []string{
+		`canal_rows_dumped_total{db="test",table="t"} 2`,
+		`canal_events_total{type="XIDEvent"} 1`,
+		`canal_event_handle_seconds_count{type="XIDEvent"} 1`,
+		`canal_binlog_position{file="mysql-bin.000001"} 400`,
+		`canal_gtid_set_info{gtid_set="de278ad0-2106-11e4-9f8e-6edd0ca20947:1-2"} 1`,
+		`canal_delay_seconds 3`,
+	} {
+		require.Contains(t, string(data), line+"\n")
+	}
+}
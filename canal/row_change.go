@@ -0,0 +1,144 @@
+package canal
+
+import (
+	"reflect"
+
+	"github.com/pingcap/errors"
+)
+
+// RowChange is the change of one row of a RowsEvent.
+type RowChange struct {
+	Action string
+	// Before is the row before the change, keyed by column name, nil for inserts.
+	// After is the row after the change, nil for deletes. The columns missing
+	// from the row image, see RowsEvent.ColumnBitmap1, are left out.
+	Before map[string]interface{}
+	After  map[string]interface{}
+	// ChangedColumns are the indexes of the columns whose values differ between
+	// Before and After, only set for updates. The columns missing from one of
+	// the images are not compared.
+	ChangedColumns []int
+	// PKBefore and PKAfter are the primary key values of Before and After, nil
+	// if the table has no primary key. A primary key column missing from the
+	// after image, not changed, has the value of the before image in PKAfter.
+	PKBefore []interface{}
+	PKAfter  []interface{}
+}
+
+// PKChanged reports whether an update changed the primary key, so sinks
+// keyed by the primary key must delete the row of PKBefore and insert PKAfter.
+func (c *RowChange) PKChanged() bool {
+	if c.Action != UpdateAction || c.PKBefore == nil {
+		return false
+	}
+	return !reflect.DeepEqual(c.PKBefore, c.PKAfter)
+}
+
+// RowChanges returns the rows of the event as RowChange, pairing the before
+// and after images of updates.
+func (r *RowsEvent) RowChanges() ([]*RowChange, error) {
+	if r.Action == UpdateAction && len(r.Rows)%2 != 0 {
+		return nil, errors.Errorf("invalid update rows event, must have 2x rows, but %d", len(r.Rows))
+	}
+
+	var changes []*RowChange
+	switch r.Action {
+	case InsertAction:
+		changes = make([]*RowChange, 0, len(r.Rows))
+		for _, row := range r.Rows {
+			changes = append(changes, &RowChange{
+				Action:  r.Action,
+				After:   r.rowMap(row, r.ColumnBitmap1),
+				PKAfter: r.pkValues(row, nil, r.ColumnBitmap1),
+			})
+		}
+	case DeleteAction:
+		changes = make([]*RowChange, 0, len(r.Rows))
+		for _, row := range r.Rows {
+			changes = append(changes, &RowChange{
+				Action:   r.Action,
+				Before:   r.rowMap(row, r.ColumnBitmap1),
+				PKBefore: r.pkValues(row, nil, r.ColumnBitmap1),
+			})
+		}
+	case UpdateAction:
+		changes = make([]*RowChange, 0, len(r.Rows)/2)
+		for i := 0; i < len(r.Rows); i += 2 {
+			before, after := r.Rows[i], r.Rows[i+1]
+			pkBefore := r.pkValues(before, nil, r.ColumnBitmap1)
+			changes = append(changes, &RowChange{
+				Action:         r.Action,
+				Before:         r.rowMap(before, r.ColumnBitmap1),
+				After:          r.rowMap(after, r.ColumnBitmap2),
+				ChangedColumns: r.changedColumns(before, after),
+				PKBefore:       pkBefore,
+				PKAfter:        r.pkValues(after, pkBefore, r.ColumnBitmap2),
+			})
+		}
+	default:
+		return nil, errors.Errorf("unsupported action %s", r.Action)
+	}
+
+	return changes, nil
+}
+
+// rowMap maps the columns of the table to the values of row. Columns missing
+// in row, because the table schema is newer than the binlog, or missing from
+// bitmap, are left out.
+func (r *RowsEvent) rowMap(row []interface{}, bitmap []byte) map[string]interface{} {
+	m := make(map[string]interface{}, len(r.Table.Columns))
+	for i, c := range r.Table.Columns {
+		if i >= len(row) {
+			break
+		}
+		if columnPresent(bitmap, i) {
+			m[c.Name] = row[i]
+		}
+	}
+	return m
+}
+
+// pkValues returns the primary key values of row, the ones missing from bitmap
+// taken from the values of defaults if set.
+func (r *RowsEvent) pkValues(row []interface{}, defaults []interface{}, bitmap []byte) []interface{} {
+	if len(r.Table.PKColumns) == 0 {
+		return nil
+	}
+
+	values := make([]interface{}, 0, len(r.Table.PKColumns))
+	for j, i := range r.Table.PKColumns {
+		switch {
+		case !columnPresent(bitmap, i) && defaults != nil:
+			values = append(values, defaults[j])
+		case i >= len(row):
+			values = append(values, nil)
+		default:
+			values = append(values, row[i])
+		}
+	}
+	return values
+}
+
+// changedColumns returns the indexes of the columns present in both images of
+// an update whose values differ.
+func (r *RowsEvent) changedColumns(before, after []interface{}) []int {
+	var changed []int
+	for i := 0; i < len(before) && i < len(after); i++ {
+		if !columnPresent(r.ColumnBitmap1, i) || !columnPresent(r.ColumnBitmap2, i) {
+			continue
+		}
+		if !reflect.DeepEqual(before[i], after[i]) {
+			changed = append(changed, i)
+		}
+	}
+	return changed
+}
+
+// columnPresent returns whether the column i is set in bitmap, a nil bitmap
+// having all the columns.
+func columnPresent(bitmap []byte, i int) bool {
+	if bitmap == nil {
+		return true
+	}
+	return i/8 < len(bitmap) && bitmap[i/8]&(1<<(uint(i)%8)) != 0
+}
@@ -26,6 +26,17 @@ type RowsEvent struct {
 	Rows [][]interface{}
 	// Header can be used to inspect the event
 	Header *replication.EventHeader
+	// TableMap is the TABLE_MAP_EVENT of the rows, with the optional metadata
+	// of binlog_row_metadata (signedness, charsets, enum and set values...),
+	// nil for the rows of the dump and Backfill.
+	TableMap *replication.TableMapEvent
+	// ColumnBitmap1 and ColumnBitmap2 are the bitmaps of the columns present in
+	// the rows, ColumnBitmap2 in the after images of the updates, with
+	// binlog_row_image MINIMAL or NOBLOB the values of the other columns are
+	// unknown, nil. Both are nil for the rows of the dump and Backfill, whose
+	// columns are all present.
+	ColumnBitmap1 []byte
+	ColumnBitmap2 []byte
 }
 
 func newRowsEvent(table *schema.Table, action string, rows [][]interface{}, header *replication.EventHeader) *RowsEvent {
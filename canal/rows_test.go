@@ -62,3 +62,145 @@ func TestRowsEvent_handleUnsigned(t *testing.T) {
 		})
 	}
 }
+
+func TestRowsEventRowChanges(t *testing.T) {
+	table := &schema.Table{
+		Schema: "test",
+		Name:   "t",
+		Columns: []schema.TableColumn{
+			{Name: "id"}, {Name: "name"}, {Name: "data"},
+		},
+		PKColumns: []int{0},
+	}
+
+	e := &RowsEvent{
+		Table:  table,
+		Action: UpdateAction,
+		Rows: [][]interface{}{
+			{int32(1), "a", []byte("x")}, {int32(1), "b", []byte("x")},
+			{int32(2), "c", []byte("y")}, {int32(3), "c", []byte("z")},
+		},
+	}
+	changes, err := e.RowChanges()
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+
+	require.Equal(t, map[string]interface{}{"id": int32(1), "name": "a", "data": []byte("x")}, changes[0].Before)
+	require.Equal(t, map[string]interface{}{"id": int32(1), "name": "b", "data": []byte("x")}, changes[0].After)
+	require.Equal(t, []int{1}, changes[0].ChangedColumns)
+	require.False(t, changes[0].PKChanged())
+
+	require.Equal(t, []int{0, 2}, changes[1].ChangedColumns)
+	require.Equal(t, []interface{}{int32(2)}, changes[1].PKBefore)
+	require.Equal(t, []interface{}{int32(3)}, changes[1].PKAfter)
+	require.True(t, changes[1].PKChanged())
+
+	e.Action = InsertAction
+	changes, err = e.RowChanges()
+	require.NoError(t, err)
+	require.Len(t, changes, 4)
+	require.Nil(t, changes[0].Before)
+	require.Equal(t, []interface{}{int32(1)}, changes[0].PKAfter)
+
+	e.Action = UpdateAction
+	e.Rows = e.Rows[:3]
+	_, err = e.RowChanges()
+	require.Error(t, err)
+}
+
+func TestRowsEventRowChangesPartialImages(t *testing.T) {
+	table := &schema.Table{
+		Schema: "test",
+		Name:   "t",
+		Columns: []schema.TableColumn{
+			{Name: "id"}, {Name: "name"}, {Name: "data"},
+		},
+		PKColumns: []int{0},
+	}
+
+	// binlog_row_image=MINIMAL: the primary key before, the changed columns after
+	e := &RowsEvent{
+		Table:         table,
+		Action:        UpdateAction,
+		ColumnBitmap1: []byte{0b001},
+		ColumnBitmap2: []byte{0b010},
+		Rows: [][]interface{}{
+			{int32(1), nil, nil}, {nil, "b", nil},
+			{int32(2), nil, nil}, {int32(3), nil, nil},
+		},
+	}
+	changes, err := e.RowChanges()
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	require.Equal(t, map[string]interface{}{"id": int32(1)}, changes[0].Before)
+	require.Equal(t, map[string]interface{}{"name": "b"}, changes[0].After)
+	require.Empty(t, changes[0].ChangedColumns)
+	require.Equal(t, []interface{}{int32(1)}, changes[0].PKAfter)
+	require.False(t, changes[0].PKChanged())
+
+	e.ColumnBitmap2 = []byte{0b001}
+	changes, err = e.RowChanges()
+	require.NoError(t, err)
+	require.Equal(t, []int{0}, changes[1].ChangedColumns)
+	require.True(t, changes[1].PKChanged())
+
+	// binlog_row_image=NOBLOB: the unchanged blob is missing from both images
+	e.ColumnBitmap1, e.ColumnBitmap2 = []byte{0b011}, []byte{0b011}
+	e.Rows = [][]interface{}{{int32(1), "a", nil}, {int32(1), "b", nil}}
+	changes, err = e.RowChanges()
+	require.NoError(t, err)
+	require.Equal(t, []int{1}, changes[0].ChangedColumns)
+	require.Equal(t, map[string]interface{}{"id": int32(1), "name": "b"}, changes[0].After)
+	require.False(t, changes[0].PKChanged())
+
+	e.Action = DeleteAction
+	e.Rows = e.Rows[:1]
+	changes, err = e.RowChanges()
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"id": int32(1), "name": "a"}, changes[0].Before)
+}
+
+func TestSortRowsByForeignKeys(t *testing.T) {
+	parent := &schema.Table{Schema: "db", Name: "parent"}
+	child := &schema.Table{Schema: "db", Name: "child", ForeignKeys: []*schema.ForeignKey{{RefSchema: "db", RefTable: "parent"}}}
+	other := &schema.Table{Schema: "db", Name: "other"}
+
+	rows := []*RowsEvent{
+		{Table: child, Action: InsertAction},
+		{Table: parent, Action: DeleteAction},
+		{Table: other, Action: UpdateAction},
+		{Table: parent, Action: InsertAction},
+		{Table: child, Action: DeleteAction},
+		{Table: child, Action: UpdateAction},
+	}
+	sorted, err := SortRowsByForeignKeys(rows)
+	require.NoError(t, err)
+	// the events of child keep their order, its delete can't pass its insert
+	require.Equal(t, []*RowsEvent{rows[1], rows[3], rows[0], rows[4], rows[5], rows[2]}, sorted)
+	// the slice passed is not sorted in place
+	require.Equal(t, InsertAction, rows[0].Action)
+
+	rows = []*RowsEvent{
+		{Table: child, Action: DeleteAction},
+		{Table: parent, Action: DeleteAction},
+		{Table: parent, Action: InsertAction},
+		{Table: child, Action: InsertAction},
+	}
+	sorted, err = SortRowsByForeignKeys(rows)
+	require.NoError(t, err)
+	require.Equal(t, rows, sorted)
+
+	// insert then delete of the same row, in a transaction of two tables
+	rows = []*RowsEvent{
+		{Table: other, Action: InsertAction},
+		{Table: other, Action: DeleteAction},
+		{Table: child, Action: DeleteAction},
+	}
+	sorted, err = SortRowsByForeignKeys(rows)
+	require.NoError(t, err)
+	require.Equal(t, []*RowsEvent{rows[2], rows[0], rows[1]}, sorted)
+
+	parent.ForeignKeys = []*schema.ForeignKey{{RefSchema: "db", RefTable: "child"}}
+	_, err = SortRowsByForeignKeys([]*RowsEvent{{Table: parent}, {Table: child}})
+	require.Error(t, err)
+}
@@ -0,0 +1,96 @@
+package canal
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// SchemaRegistry registers the schemas of the payloads of a
+// RowChangeSerializer, see SchemaRegistryClient.
+type SchemaRegistry interface {
+	// Register registers schema, of type "AVRO" or "PROTOBUF", under subject
+	// and returns its ID. Registering a schema already registered returns the
+	// same ID.
+	Register(subject, schemaType, schema string) (int, error)
+}
+
+// SchemaRegistryClient is a client of the REST API of Confluent Schema
+// Registry, and the compatible registries.
+type SchemaRegistryClient struct {
+	// URL is the URL of the registry, like http://localhost:8081.
+	URL string
+	// User and Password are sent with basic authentication if User is set.
+	User     string
+	Password string
+	// Client sends the requests, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// NewSchemaRegistryClient returns a client of the registry at url.
+func NewSchemaRegistryClient(url string) *SchemaRegistryClient {
+	return &SchemaRegistryClient{URL: strings.TrimRight(url, "/")}
+}
+
+// Register registers a new version of subject, see SchemaRegistry.
+func (c *SchemaRegistryClient) Register(subject, schemaType, schema string) (int, error) {
+	req := struct {
+		Schema     string `json:"schema"`
+		SchemaType string `json:"schemaType,omitempty"`
+	}{Schema: schema}
+	// AVRO is the default, omitted for the registries without the other types
+	if schemaType != "AVRO" {
+		req.SchemaType = schemaType
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, c.URL+"/subjects/"+url.PathEscape(subject)+"/versions", bytes.NewReader(body))
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	r.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	if c.User != "" {
+		r.SetBasicAuth(c.User, c.Password)
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(r)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			ErrorCode int    `json:"error_code"`
+			Message   string `json:"message"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Message != "" {
+			return 0, errors.Errorf("register schema of subject %s: %d %s", subject, e.ErrorCode, e.Message)
+		}
+		return 0, errors.Errorf("register schema of subject %s: %s", subject, resp.Status)
+	}
+
+	var res struct {
+		ID int `json:"id"`
+	}
+	if err = json.Unmarshal(data, &res); err != nil {
+		return 0, errors.Annotatef(err, "register schema of subject %s", subject)
+	}
+	return res.ID, nil
+}
@@ -0,0 +1,390 @@
+package canal
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/gongzhxu/go-mysql/schema"
+)
+
+// SerializationFormat is the format of the payloads of a RowChangeSerializer.
+type SerializationFormat int
+
+const (
+	SerializationAvro SerializationFormat = iota
+	SerializationProtobuf
+)
+
+func (f SerializationFormat) schemaType() string {
+	if f == SerializationProtobuf {
+		return "PROTOBUF"
+	}
+	return "AVRO"
+}
+
+// RowChangeSerializer encodes the RowChanges in Avro or Protobuf, in the wire
+// format of Confluent Schema Registry: a zero byte, the ID of the schema in 4
+// bytes big endian, the message index 0 for Protobuf, and the encoded change.
+//
+// The schema of a table is generated from its columns and registered when the
+// first change of the table is serialized, and again when the table changes,
+// after a DDL. It is a record, or a message, of the name of the table in the
+// namespace, or package, of the database, with the fields action, before and
+// after, the images of the row or null. The columns of the images are
+// nullable, their types are:
+//
+//   - long, int64 in Protobuf, for the integers and BIT
+//   - double for FLOAT and DOUBLE
+//   - bytes for BINARY, VARBINARY, BLOB and the spatial types
+//   - string for the other types: unsigned BIGINT and DECIMAL in decimal, the
+//     names of the ENUM and SET values, and the temporal types like their
+//     MySQL literals.
+//
+// The names of the columns are changed to valid Avro and Protobuf names, the
+// invalid characters are replaced by '_'.
+type RowChangeSerializer struct {
+	// Subject returns the subject of the schema of a table, <db>.<table>-value
+	// by default, like the RecordNameStrategy of Confluent.
+	Subject func(db, table string) string
+
+	registry SchemaRegistry
+	format   SerializationFormat
+
+	mu      sync.Mutex
+	schemas map[string]*payloadSchema
+}
+
+// payloadSchema is the schema registered for a table.
+type payloadSchema struct {
+	table *schema.Table
+	text  string
+	id    int
+	kinds []valueKind
+}
+
+// NewRowChangeSerializer returns a serializer registering the schemas to
+// registry, see NewSchemaRegistryClient.
+func NewRowChangeSerializer(registry SchemaRegistry, format SerializationFormat) *RowChangeSerializer {
+	return &RowChangeSerializer{
+		Subject: func(db, table string) string {
+			return db + "." + table + "-value"
+		},
+		registry: registry,
+		format:   format,
+		schemas:  make(map[string]*payloadSchema),
+	}
+}
+
+// Serialize encodes c, a change of e, registering the schema of the table of e
+// if it is new or changed.
+func (s *RowChangeSerializer) Serialize(e *RowsEvent, c *RowChange) ([]byte, error) {
+	ps, err := s.tableSchema(e.Table)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	data := make([]byte, 5, 64)
+	binary.BigEndian.PutUint32(data[1:], uint32(ps.id))
+
+	if s.format == SerializationProtobuf {
+		// the index of the message in the schema
+		data = append(data, 0)
+		return appendProtobufChange(data, e.Table, ps.kinds, c)
+	}
+	return appendAvroChange(data, e.Table, ps.kinds, c)
+}
+
+// tableSchema returns the schema of table, registered if the table is new or
+// its columns changed.
+func (s *RowChangeSerializer) tableSchema(table *schema.Table) (*payloadSchema, error) {
+	key := table.Schema + "." + table.Name
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ps := s.schemas[key]
+	if ps != nil && ps.table == table {
+		return ps, nil
+	}
+
+	// the table was reloaded, maybe by a DDL not changing the columns
+	kinds := make([]valueKind, len(table.Columns))
+	for i := range table.Columns {
+		kinds[i] = columnKind(&table.Columns[i])
+	}
+	var text string
+	if s.format == SerializationProtobuf {
+		text = protobufSchema(table, kinds)
+	} else {
+		text = avroSchema(table, kinds)
+	}
+	if ps != nil && ps.text == text {
+		ps.table = table
+		return ps, nil
+	}
+
+	id, err := s.registry.Register(s.Subject(table.Schema, table.Name), s.format.schemaType(), text)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	ps = &payloadSchema{table: table, text: text, id: id, kinds: kinds}
+	s.schemas[key] = ps
+	return ps, nil
+}
+
+// valueKind is the type of a column in the schemas.
+type valueKind uint8
+
+const (
+	kindLong valueKind = iota
+	kindDouble
+	kindString
+	kindBytes
+)
+
+func columnKind(c *schema.TableColumn) valueKind {
+	switch c.Type {
+	case schema.TYPE_NUMBER, schema.TYPE_MEDIUM_INT, schema.TYPE_BIT:
+		if c.IsUnsigned && strings.HasPrefix(c.RawType, "bigint") {
+			// may overflow a long
+			return kindString
+		}
+		return kindLong
+	case schema.TYPE_FLOAT:
+		return kindDouble
+	case schema.TYPE_BINARY, schema.TYPE_POINT:
+		return kindBytes
+	default:
+		return kindString
+	}
+}
+
+// columnValue converts v, a value of c, to the Go type of kind: int64, float64,
+// string or []byte.
+func columnValue(c *schema.TableColumn, kind valueKind, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	switch kind {
+	case kindLong:
+		switch x := v.(type) {
+		case int8:
+			return int64(x), nil
+		case int16:
+			return int64(x), nil
+		case int32:
+			return int64(x), nil
+		case int64:
+			return x, nil
+		case int:
+			return int64(x), nil
+		case uint8:
+			return int64(x), nil
+		case uint16:
+			return int64(x), nil
+		case uint32:
+			return int64(x), nil
+		case uint64:
+			return int64(x), nil
+		case uint:
+			return int64(x), nil
+		}
+	case kindDouble:
+		switch x := v.(type) {
+		case float32:
+			return float64(x), nil
+		case float64:
+			return x, nil
+		}
+	case kindBytes:
+		switch x := v.(type) {
+		case []byte:
+			return x, nil
+		case string:
+			return []byte(x), nil
+		}
+	case kindString:
+		switch x := v.(type) {
+		case string:
+			return x, nil
+		case []byte:
+			return string(x), nil
+		case decimal.Decimal:
+			return x.String(), nil
+		case time.Time:
+			return x.Format("2006-01-02 15:04:05.999999"), nil
+		case int64:
+			switch c.Type {
+			case schema.TYPE_ENUM:
+				if x <= 0 || int(x) > len(c.EnumValues) {
+					return "", nil
+				}
+				return c.EnumValues[x-1], nil
+			case schema.TYPE_SET:
+				var values []string
+				for i, name := range c.SetValues {
+					if x&(1<<uint(i)) > 0 {
+						values = append(values, name)
+					}
+				}
+				return strings.Join(values, ","), nil
+			}
+			return strconv.FormatInt(x, 10), nil
+		case uint64:
+			return strconv.FormatUint(x, 10), nil
+		default:
+			return fmt.Sprint(x), nil
+		}
+	}
+	return nil, errors.Errorf("invalid value %v of type %T of column %s", v, v, c.Name)
+}
+
+// schemaName returns name with the characters invalid in the names of Avro and
+// Protobuf replaced by '_'.
+func schemaName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || i > 0 && c >= '0' && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+func avroSchema(table *schema.Table, kinds []valueKind) string {
+	rowName := schemaName(table.Name) + "_row"
+	fields := make([]interface{}, len(table.Columns))
+	for i, c := range table.Columns {
+		typ := [...]string{kindLong: "long", kindDouble: "double", kindString: "string", kindBytes: "bytes"}[kinds[i]]
+		fields[i] = map[string]interface{}{"name": schemaName(c.Name), "type": []string{"null", typ}, "default": nil}
+	}
+	row := map[string]interface{}{"type": "record", "name": rowName, "fields": fields}
+
+	s := map[string]interface{}{
+		"type":      "record",
+		"name":      schemaName(table.Name),
+		"namespace": schemaName(table.Schema),
+		"fields": []interface{}{
+			map[string]interface{}{"name": "action", "type": "string"},
+			map[string]interface{}{"name": "before", "type": []interface{}{"null", row}, "default": nil},
+			map[string]interface{}{"name": "after", "type": []interface{}{"null", rowName}, "default": nil},
+		},
+	}
+	text, _ := json.Marshal(s)
+	return string(text)
+}
+
+func appendAvroChange(data []byte, table *schema.Table, kinds []valueKind, c *RowChange) ([]byte, error) {
+	data = appendAvroBytes(data, []byte(c.Action))
+	for _, row := range []map[string]interface{}{c.Before, c.After} {
+		if row == nil {
+			data = appendAvroLong(data, 0)
+			continue
+		}
+		data = appendAvroLong(data, 1)
+		for i := range table.Columns {
+			col := &table.Columns[i]
+			v, err := columnValue(col, kinds[i], row[col.Name])
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if v == nil {
+				data = appendAvroLong(data, 0)
+				continue
+			}
+			data = appendAvroLong(data, 1)
+			switch x := v.(type) {
+			case int64:
+				data = appendAvroLong(data, x)
+			case float64:
+				data = binary.LittleEndian.AppendUint64(data, math.Float64bits(x))
+			case string:
+				data = appendAvroBytes(data, []byte(x))
+			case []byte:
+				data = appendAvroBytes(data, x)
+			}
+		}
+	}
+	return data, nil
+}
+
+// appendAvroLong appends v zigzag encoded.
+func appendAvroLong(data []byte, v int64) []byte {
+	return binary.AppendUvarint(data, uint64(v<<1)^uint64(v>>63))
+}
+
+func appendAvroBytes(data []byte, b []byte) []byte {
+	data = appendAvroLong(data, int64(len(b)))
+	return append(data, b...)
+}
+
+func protobufSchema(table *schema.Table, kinds []valueKind) string {
+	var b strings.Builder
+	b.WriteString("syntax = \"proto3\";\n\n")
+	fmt.Fprintf(&b, "package %s;\n\n", schemaName(table.Schema))
+	fmt.Fprintf(&b, "message %s {\n", schemaName(table.Name))
+	b.WriteString("  string action = 1;\n  Row before = 2;\n  Row after = 3;\n\n  message Row {\n")
+	for i, c := range table.Columns {
+		typ := [...]string{kindLong: "int64", kindDouble: "double", kindString: "string", kindBytes: "bytes"}[kinds[i]]
+		fmt.Fprintf(&b, "    optional %s %s = %d;\n", typ, schemaName(c.Name), i+1)
+	}
+	b.WriteString("  }\n}\n")
+	return b.String()
+}
+
+// Protobuf wire types
+const (
+	protobufVarint = 0
+	protobufI64    = 1
+	protobufLen    = 2
+)
+
+func appendProtobufChange(data []byte, table *schema.Table, kinds []valueKind, c *RowChange) ([]byte, error) {
+	data = appendProtobufBytes(data, 1, []byte(c.Action))
+	for n, row := range []map[string]interface{}{c.Before, c.After} {
+		if row == nil {
+			continue
+		}
+		var msg []byte
+		for i := range table.Columns {
+			col := &table.Columns[i]
+			v, err := columnValue(col, kinds[i], row[col.Name])
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			field := uint64(i + 1)
+			switch x := v.(type) {
+			case int64:
+				msg = binary.AppendUvarint(msg, field<<3|protobufVarint)
+				msg = binary.AppendUvarint(msg, uint64(x))
+			case float64:
+				msg = binary.AppendUvarint(msg, field<<3|protobufI64)
+				msg = binary.LittleEndian.AppendUint64(msg, math.Float64bits(x))
+			case string:
+				msg = appendProtobufBytes(msg, field, []byte(x))
+			case []byte:
+				msg = appendProtobufBytes(msg, field, x)
+			}
+		}
+		data = appendProtobufBytes(data, uint64(n+2), msg)
+	}
+	return data, nil
+}
+
+func appendProtobufBytes(data []byte, field uint64, b []byte) []byte {
+	data = binary.AppendUvarint(data, field<<3|protobufLen)
+	data = binary.AppendUvarint(data, uint64(len(b)))
+	return append(data, b...)
+}
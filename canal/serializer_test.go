@@ -0,0 +1,117 @@
+package canal
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/schema"
+)
+
+type recordRegistry struct {
+	subjects []string
+	schemas  []string
+}
+
+func (r *recordRegistry) Register(subject, schemaType, schema string) (int, error) {
+	r.subjects = append(r.subjects, subject)
+	r.schemas = append(r.schemas, schema)
+	return len(r.schemas), nil
+}
+
+func serializerTable() *schema.Table {
+	return &schema.Table{
+		Schema: "test",
+		Name:   "t",
+		Columns: []schema.TableColumn{
+			{Name: "id", Type: schema.TYPE_NUMBER, RawType: "int"},
+			{Name: "name", Type: schema.TYPE_STRING, RawType: "varchar(10)"},
+			{Name: "state", Type: schema.TYPE_ENUM, RawType: "enum('on','off')", EnumValues: []string{"on", "off"}},
+		},
+	}
+}
+
+func TestRowChangeSerializer(t *testing.T) {
+	registry := &recordRegistry{}
+	s := NewRowChangeSerializer(registry, SerializationAvro)
+	e := &RowsEvent{Table: serializerTable(), Action: InsertAction}
+	c := &RowChange{Action: InsertAction, After: map[string]interface{}{"id": int32(1), "name": "a", "state": nil}}
+
+	data, err := s.Serialize(e, c)
+	require.NoError(t, err)
+	require.Equal(t, []byte{
+		0, 0, 0, 0, 1,
+		12, 'i', 'n', 's', 'e', 'r', 't',
+		// no before, after
+		0, 2,
+		2, 2, 2, 2, 'a', 0,
+	}, data)
+	require.Equal(t, []string{"test.t-value"}, registry.subjects)
+	var avro map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(registry.schemas[0]), &avro))
+	require.Equal(t, "t", avro["name"])
+	require.Equal(t, "test", avro["namespace"])
+
+	// registered once, until the columns change
+	_, err = s.Serialize(e, c)
+	require.NoError(t, err)
+	e.Table = serializerTable()
+	_, err = s.Serialize(e, c)
+	require.NoError(t, err)
+	require.Len(t, registry.schemas, 1)
+
+	e.Table.Columns = append(e.Table.Columns, schema.TableColumn{Name: "data", Type: schema.TYPE_BINARY})
+	e.Table = &schema.Table{Schema: e.Table.Schema, Name: e.Table.Name, Columns: e.Table.Columns}
+	data, err = s.Serialize(e, c)
+	require.NoError(t, err)
+	require.Len(t, registry.schemas, 2)
+	require.Equal(t, byte(2), data[4])
+
+	registry = &recordRegistry{}
+	s = NewRowChangeSerializer(registry, SerializationProtobuf)
+	e = &RowsEvent{Table: serializerTable(), Action: UpdateAction}
+	c = &RowChange{
+		Action: UpdateAction,
+		Before: map[string]interface{}{"id": int32(1), "name": nil, "state": int64(1)},
+		After:  map[string]interface{}{"id": int32(1), "name": []byte("b"), "state": int64(2)},
+	}
+	data, err = s.Serialize(e, c)
+	require.NoError(t, err)
+	require.Equal(t, []byte{
+		0, 0, 0, 0, 1, 0,
+		0x0a, 6, 'u', 'p', 'd', 'a', 't', 'e',
+		0x12, 6, 0x08, 1, 0x1a, 2, 'o', 'n',
+		0x1a, 10, 0x08, 1, 0x12, 1, 'b', 0x1a, 3, 'o', 'f', 'f',
+	}, data)
+	require.Contains(t, registry.schemas[0], "message t {")
+	require.Contains(t, registry.schemas[0], "optional int64 id = 1;")
+	require.Contains(t, registry.schemas[0], "optional string state = 3;")
+}
+
+func TestSchemaRegistryClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/subjects/test.t-value/versions", r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		var req map[string]string
+		require.NoError(t, json.Unmarshal(body, &req))
+		if req["schemaType"] == "PROTOBUF" {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error_code":409,"message":"incompatible schema"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":7}`))
+	}))
+	defer srv.Close()
+
+	c := NewSchemaRegistryClient(srv.URL + "/")
+	id, err := c.Register("test.t-value", "AVRO", `"string"`)
+	require.NoError(t, err)
+	require.Equal(t, 7, id)
+
+	_, err = c.Register("test.t-value", "PROTOBUF", `syntax = "proto3";`)
+	require.ErrorContains(t, err, "409 incompatible schema")
+}
@@ -46,9 +46,18 @@ func (c *Canal) runSyncBinlog() error {
 			return errors.Trace(err)
 		}
 
+		if err = c.binlogThrottle.wait(c.ctx, eventRows(ev), int64(ev.Header.EventSize)); err != nil {
+			return errors.Trace(err)
+		}
+
 		// Update the delay between the Canal and the Master before the handler hooks are called
 		c.updateReplicationDelay(ev)
 
+		// deliver the backfilled chunks the stream caught up with before the next event
+		if err := c.flushBackfills(); err != nil {
+			return errors.Trace(err)
+		}
+
 		switch e := ev.Event.(type) {
 		case *replication.RotateEvent:
 			// If the timestamp equals zero, the received rotate event is a fake rotate event
@@ -68,10 +77,12 @@ func (c *Canal) runSyncBinlog() error {
 			}
 		}
 
+		start := time.Now()
 		err = c.handleEvent(ev)
 		if err != nil {
 			return err
 		}
+		c.eventHandled(ev, start)
 	}
 }
 
@@ -86,6 +97,12 @@ func (c *Canal) handleEvent(ev *replication.BinlogEvent) error {
 	// next binlog pos
 	pos.Pos = ev.Header.LogPos
 
+	if c.trx != nil {
+		if _, ok := ev.Event.(*replication.TransactionPayloadEvent); !ok {
+			c.trx.Size += uint64(ev.Header.EventSize)
+		}
+	}
+
 	// We only save position with RotateEvent and XIDEvent.
 	// For RowsEvent, we can't save the position until meeting XIDEvent
 	// which tells the whole transaction is over.
@@ -124,6 +141,9 @@ func (c *Canal) handleEvent(ev *replication.BinlogEvent) error {
 		if err := c.eventHandler.OnXID(ev.Header, pos); err != nil {
 			return errors.Trace(err)
 		}
+		if err := c.commitTransaction(ev.Header, pos, e.GSet); err != nil {
+			return errors.Trace(err)
+		}
 		if e.GSet != nil {
 			c.master.UpdateGTIDSet(e.GSet)
 		}
@@ -131,15 +151,33 @@ func (c *Canal) handleEvent(ev *replication.BinlogEvent) error {
 		if err := c.eventHandler.OnGTID(ev.Header, e); err != nil {
 			return errors.Trace(err)
 		}
+		c.beginTransaction(ev.Header, e)
+		c.setGTID(e)
 	case *replication.GTIDEvent:
 		if err := c.eventHandler.OnGTID(ev.Header, e); err != nil {
 			return errors.Trace(err)
 		}
+		c.beginTransaction(ev.Header, e)
+		c.setGTID(e)
+		c.backfillGTID(e)
 	case *replication.RowsQueryEvent:
 		if err := c.eventHandler.OnRowsQueryEvent(e); err != nil {
 			return errors.Trace(err)
 		}
 	case *replication.QueryEvent:
+		switch string(e.Query) {
+		case "BEGIN":
+			// without GTIDs the transaction starts here
+			if c.trx == nil {
+				c.beginTransaction(ev.Header, nil)
+			}
+		case "COMMIT":
+			// transactions on non-transactional tables end with COMMIT instead of XID
+			if err := c.commitTransaction(ev.Header, pos, e.GSet); err != nil {
+				return errors.Trace(err)
+			}
+		}
+
 		stmts, _, err := c.parser.Parse(string(e.Query), "", "")
 		if err != nil {
 			// The parser does not understand all syntax.
@@ -151,6 +189,11 @@ func (c *Canal) handleEvent(ev *replication.BinlogEvent) error {
 			savePos = true
 		}
 		for _, stmt := range stmts {
+			if _, ok := stmt.(ast.DDLNode); ok {
+				// DDLs are not delivered as transactions, unlike SAVEPOINT,
+				// XA or DML in statement format
+				c.trx = nil
+			}
 			nodes := parseStmt(stmt)
 			for _, node := range nodes {
 				if node.db == "" {
@@ -182,6 +225,7 @@ func (c *Canal) handleEvent(ev *replication.BinlogEvent) error {
 		if err := c.eventHandler.OnPosSynced(ev.Header, pos, c.master.GTIDSet(), force); err != nil {
 			return errors.Trace(err)
 		}
+		c.positionSynced(pos, c.master.GTIDSet())
 	}
 
 	return nil
@@ -279,6 +323,9 @@ func (c *Canal) handleRowsEvent(e *replication.BinlogEvent) error {
 
 		return err
 	}
+	if err = c.createTargetTable(t); err != nil {
+		return errors.Trace(err)
+	}
 	var action string
 	switch e.Header.EventType {
 	case replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2, replication.MARIADB_WRITE_ROWS_COMPRESSED_EVENT_V1:
@@ -291,7 +338,21 @@ func (c *Canal) handleRowsEvent(e *replication.BinlogEvent) error {
 		return errors.Errorf("%s not supported now", e.Header.EventType)
 	}
 	events := newRowsEvent(t, action, ev.Rows, e.Header)
-	return c.eventHandler.OnRow(events)
+	events.TableMap = ev.Table
+	events.ColumnBitmap1, events.ColumnBitmap2 = ev.ColumnBitmap1, ev.ColumnBitmap2
+	c.backfillConflicts(events)
+	if c.trx != nil {
+		c.trx.Rows = append(c.trx.Rows, events)
+	}
+	if h, ok := c.eventHandler.(RowsWithHeaderHandler); ok {
+		err = h.OnRowsWithHeader(e.Header, ev.Table, events)
+	} else {
+		err = c.eventHandler.OnRow(events)
+	}
+	if err != nil && c.cfg.DeadLetterStore != nil {
+		return c.deadLetter(events, err)
+	}
+	return err
 }
 
 func (c *Canal) FlushBinlog() error {
@@ -1,9 +1,16 @@
 package canal
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/pingcap/tidb/pkg/parser"
 	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/replication"
+	"github.com/gongzhxu/go-mysql/schema"
 )
 
 func TestGetShowBinaryLogQuery(t *testing.T) {
@@ -29,3 +36,226 @@ func TestGetShowBinaryLogQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleEventTransaction(t *testing.T) {
+	c := new(Canal)
+	c.cfg = NewDefaultConfig()
+	c.parser = parser.New()
+	c.master = &masterInfo{logger: c.cfg.Logger}
+	c.eventHandler = &DummyEventHandler{}
+	c.tables = map[string]*schema.Table{
+		"test.t": {Schema: "test", Name: "t", Columns: []schema.TableColumn{{Name: "id"}}},
+	}
+
+	var trxs []*Transaction
+	c.OnTransaction(func(trx *Transaction) error {
+		trxs = append(trxs, trx)
+		return nil
+	})
+
+	gset, err := mysql.ParseGTIDSet(mysql.MySQLFlavor, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1")
+	require.NoError(t, err)
+
+	rows := func(pos uint32) *replication.BinlogEvent {
+		return &replication.BinlogEvent{
+			Header: &replication.EventHeader{EventType: replication.WRITE_ROWS_EVENTv2, LogPos: pos, EventSize: 10},
+			Event: &replication.RowsEvent{
+				Table: &replication.TableMapEvent{Schema: []byte("test"), Table: []byte("t")},
+				Rows:  [][]interface{}{{int32(pos)}},
+			},
+		}
+	}
+	events := []*replication.BinlogEvent{
+		{
+			Header: &replication.EventHeader{EventType: replication.GTID_EVENT, LogPos: 100, EventSize: 20},
+			Event:  &replication.GTIDEvent{GNO: 1, ImmediateCommitTimestamp: 1700000000000000},
+		},
+		{
+			Header: &replication.EventHeader{EventType: replication.QUERY_EVENT, LogPos: 150, EventSize: 30},
+			Event:  &replication.QueryEvent{Query: []byte("BEGIN")},
+		},
+		rows(200),
+		rows(300),
+		{
+			Header: &replication.EventHeader{EventType: replication.XID_EVENT, LogPos: 400, EventSize: 40},
+			Event:  &replication.XIDEvent{GSet: gset},
+		},
+		// a DDL is not a transaction
+		{
+			Header: &replication.EventHeader{EventType: replication.GTID_EVENT, LogPos: 500, EventSize: 20},
+			Event:  &replication.GTIDEvent{GNO: 2},
+		},
+		{
+			Header: &replication.EventHeader{EventType: replication.QUERY_EVENT, LogPos: 600, EventSize: 30},
+			Event:  &replication.QueryEvent{Query: []byte("CREATE DATABASE db1")},
+		},
+		// without GTID, ended by COMMIT
+		{
+			Header: &replication.EventHeader{EventType: replication.QUERY_EVENT, LogPos: 700, EventSize: 30, Timestamp: 1700000001},
+			Event:  &replication.QueryEvent{Query: []byte("BEGIN")},
+		},
+		{
+			Header: &replication.EventHeader{EventType: replication.QUERY_EVENT, LogPos: 750, EventSize: 30},
+			Event:  &replication.QueryEvent{Query: []byte("SAVEPOINT s1")},
+		},
+		rows(800),
+		{
+			Header: &replication.EventHeader{EventType: replication.QUERY_EVENT, LogPos: 900, EventSize: 30, Timestamp: 1700000002},
+			Event:  &replication.QueryEvent{Query: []byte("COMMIT")},
+		},
+	}
+	for _, ev := range events {
+		require.NoError(t, c.handleEvent(ev))
+	}
+
+	require.Len(t, trxs, 2)
+
+	require.NotNil(t, trxs[0].GTID)
+	require.Equal(t, time.UnixMicro(1700000000000000), trxs[0].CommitTime)
+	require.Equal(t, uint64(20+30+10+10+40), trxs[0].Size)
+	require.Len(t, trxs[0].Rows, 2)
+	require.Equal(t, InsertAction, trxs[0].Rows[1].Action)
+	require.Equal(t, uint32(400), trxs[0].Pos.Pos)
+	require.Equal(t, gset.String(), trxs[0].GSet.String())
+
+	require.Nil(t, trxs[1].GTID)
+	require.Equal(t, time.Unix(1700000002, 0), trxs[1].CommitTime)
+	require.Equal(t, uint64(30+30+10+30), trxs[1].Size)
+	require.Len(t, trxs[1].Rows, 1)
+	require.Equal(t, uint32(900), trxs[1].Pos.Pos)
+	require.Nil(t, trxs[1].GSet)
+}
+
+type backfillTestHandler struct {
+	DummyEventHandler
+	rows []*RowsEvent
+}
+
+func (h *backfillTestHandler) OnRow(e *RowsEvent) error {
+	h.rows = append(h.rows, e)
+	return nil
+}
+
+func TestBackfillMerge(t *testing.T) {
+	const sid = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	parseGset := func(s string) mysql.GTIDSet {
+		gset, err := mysql.ParseMysqlGTIDSet(s)
+		require.NoError(t, err)
+		return gset
+	}
+
+	table := &schema.Table{Schema: "test", Name: "t", Columns: []schema.TableColumn{{Name: "id"}, {Name: "name"}}, PKColumns: []int{0}}
+	h := &backfillTestHandler{}
+	c := new(Canal)
+	c.cfg = NewDefaultConfig()
+	c.master = &masterInfo{logger: c.cfg.Logger, gset: parseGset(sid + ":1")}
+	c.eventHandler = h
+	c.tables = map[string]*schema.Table{"test.t": table}
+
+	chunk := &backfillChunk{
+		table:     table,
+		low:       parseGset(sid + ":1"),
+		rows:      make(map[string][]interface{}),
+		conflicts: make(map[string]struct{}),
+		done:      make(chan error, 1),
+	}
+	c.backfill.chunks = append(c.backfill.chunks, chunk)
+
+	u := []byte{0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62}
+	trx := func(gno int64, id int32) []*replication.BinlogEvent {
+		return []*replication.BinlogEvent{
+			{
+				Header: &replication.EventHeader{EventType: replication.GTID_EVENT},
+				Event:  &replication.GTIDEvent{SID: u, GNO: gno},
+			},
+			{
+				Header: &replication.EventHeader{EventType: replication.UPDATE_ROWS_EVENTv2},
+				Event: &replication.RowsEvent{
+					Table: &replication.TableMapEvent{Schema: []byte("test"), Table: []byte("t")},
+					Rows:  [][]interface{}{{id, "a"}, {id, "b"}},
+				},
+			},
+			{
+				Header: &replication.EventHeader{EventType: replication.XID_EVENT},
+				Event:  &replication.XIDEvent{GSet: parseGset(fmt.Sprintf("%s:1-%d", sid, gno))},
+			},
+		}
+	}
+
+	// the stream changes row 2 while the chunk is read
+	for _, ev := range trx(2, 2) {
+		require.NoError(t, c.handleEvent(ev))
+	}
+	chunk.add([][]interface{}{{int64(1), "a"}, {int64(2), "a"}, {int64(3), "a"}})
+	chunk.high = parseGset(sid + ":1-3")
+
+	// and row 3 before the high watermark, after the chunk is read
+	require.NoError(t, c.flushBackfills())
+	require.Len(t, h.rows, 1)
+	for _, ev := range trx(3, 3) {
+		require.NoError(t, c.handleEvent(ev))
+	}
+	require.Len(t, h.rows, 2)
+
+	// the high watermark is reached
+	require.NoError(t, c.flushBackfills())
+	require.Len(t, h.rows, 3)
+	require.Equal(t, InsertAction, h.rows[2].Action)
+	require.Equal(t, [][]interface{}{{int64(1), "a"}}, h.rows[2].Rows)
+	require.NoError(t, <-chunk.done)
+	require.Empty(t, c.backfill.chunks)
+}
+
+func TestBackfillQuery(t *testing.T) {
+	table := &schema.Table{Schema: "test", Name: "t", Columns: []schema.TableColumn{{Name: "a"}, {Name: "b"}, {Name: "c"}}, PKColumns: []int{0, 1}}
+	require.Equal(t, "SELECT `a`,`b`,`c` FROM `test`.`t` ORDER BY `a`,`b` LIMIT 10", backfillQuery(table, false, 10))
+	require.Equal(t, "SELECT `a`,`b`,`c` FROM `test`.`t` WHERE (`a`,`b`) > (?,?) ORDER BY `a`,`b` LIMIT 10", backfillQuery(table, true, 10))
+}
+
+type rowsWithHeaderHandler struct {
+	DummyEventHandler
+	rows       int
+	withHeader int
+	header     *replication.EventHeader
+	tableMap   *replication.TableMapEvent
+}
+
+func (h *rowsWithHeaderHandler) OnRow(e *RowsEvent) error {
+	h.rows++
+	return nil
+}
+
+func (h *rowsWithHeaderHandler) OnRowsWithHeader(header *replication.EventHeader, tableMap *replication.TableMapEvent, e *RowsEvent) error {
+	h.header = header
+	h.tableMap = tableMap
+	h.withHeader++
+	return nil
+}
+
+func TestOnRowsWithHeader(t *testing.T) {
+	h := &rowsWithHeaderHandler{}
+	c := new(Canal)
+	c.cfg = NewDefaultConfig()
+	c.master = &masterInfo{logger: c.cfg.Logger}
+	c.eventHandler = h
+	c.tables = map[string]*schema.Table{
+		"test.t": {Schema: "test", Name: "t", Columns: []schema.TableColumn{{Name: "id"}}},
+	}
+
+	tableMap := &replication.TableMapEvent{
+		Schema: []byte("test"), Table: []byte("t"),
+		ColumnCount: 1, ColumnType: []byte{mysql.MYSQL_TYPE_LONG}, SignednessBitmap: []byte{0x80},
+	}
+	header := &replication.EventHeader{EventType: replication.WRITE_ROWS_EVENTv2, ServerID: 11, LogPos: 200, Timestamp: 1700000000}
+	require.NoError(t, c.handleEvent(&replication.BinlogEvent{
+		Header: header,
+		Event:  &replication.RowsEvent{Table: tableMap, Rows: [][]interface{}{{int32(1)}}},
+	}))
+
+	require.Equal(t, 0, h.rows)
+	require.Equal(t, 1, h.withHeader)
+	require.Same(t, header, h.header)
+	require.Equal(t, uint32(11), h.header.ServerID)
+	require.Same(t, tableMap, h.tableMap)
+	require.Equal(t, map[int]bool{0: true}, h.tableMap.UnsignedMap())
+}
@@ -0,0 +1,41 @@
+package canal
+
+import (
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/schema"
+)
+
+// createTargetTable creates the database and the table t on Config.TargetConn
+// if they don't exist, once per table.
+func (c *Canal) createTargetTable(t *schema.Table) error {
+	conn := c.cfg.TargetConn
+	if conn == nil {
+		return nil
+	}
+
+	c.targetLock.Lock()
+	defer c.targetLock.Unlock()
+	key := t.String()
+	if c.targetTables[key] {
+		return nil
+	}
+	if !c.targetDatabases[t.Schema] {
+		if _, err := conn.Execute("CREATE DATABASE IF NOT EXISTS " + mysql.QuoteIdentifier(t.Schema)); err != nil {
+			return errors.Annotatef(err, "create database %s on the target", t.Schema)
+		}
+		if c.targetDatabases == nil {
+			c.targetDatabases = make(map[string]bool)
+		}
+		c.targetDatabases[t.Schema] = true
+	}
+	if _, err := conn.Execute(t.ToCreateTableSQL()); err != nil {
+		return errors.Annotatef(err, "create table %s on the target", key)
+	}
+	if c.targetTables == nil {
+		c.targetTables = make(map[string]bool)
+	}
+	c.targetTables[key] = true
+	return nil
+}
@@ -0,0 +1,64 @@
+package canal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/replication"
+	"github.com/gongzhxu/go-mysql/schema"
+)
+
+func TestCreateTargetTable(t *testing.T) {
+	h := &replayHandler{}
+	addr := serve(t, h)
+	conn, err := client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	c := new(Canal)
+	c.cfg = NewDefaultConfig()
+	c.cfg.TargetConn = conn
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	defer c.cancel()
+	c.master = &masterInfo{logger: c.cfg.Logger}
+	c.eventHandler = &DummyEventHandler{}
+	table := func(db, name string) *schema.Table {
+		ta := &schema.Table{Schema: db, Name: name}
+		ta.AddColumn("id", "int(11)", "", "")
+		ta.AddIndex("PRIMARY").AddColumn("id", 1)
+		ta.PKColumns = []int{0}
+		return ta
+	}
+	c.tables = map[string]*schema.Table{"test.t": table("test", "t"), "test.u": table("test", "u"), "other.t": table("other", "t")}
+
+	d := &dumpParseHandler{c: c}
+	require.NoError(t, d.Data("test", "t", []string{"1"}))
+	require.NoError(t, d.Data("test", "t", []string{"2"}))
+	require.NoError(t, d.Data("other", "t", []string{"1"}))
+	rows := &replication.BinlogEvent{
+		Header: &replication.EventHeader{EventType: replication.WRITE_ROWS_EVENTv2, LogPos: 100},
+		Event: &replication.RowsEvent{
+			Table: &replication.TableMapEvent{Schema: []byte("test"), Table: []byte("u")},
+			Rows:  [][]interface{}{{int32(1)}},
+		},
+	}
+	require.NoError(t, c.handleEvent(rows))
+	require.NoError(t, c.handleEvent(rows))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var queries []string
+	for _, s := range h.statements {
+		queries = append(queries, s.Query)
+	}
+	require.Equal(t, []string{
+		"CREATE DATABASE IF NOT EXISTS `test`",
+		c.tables["test.t"].ToCreateTableSQL(),
+		"CREATE DATABASE IF NOT EXISTS `other`",
+		c.tables["other.t"].ToCreateTableSQL(),
+		c.tables["test.u"].ToCreateTableSQL(),
+	}, queries)
+}
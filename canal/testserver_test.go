@@ -0,0 +1,35 @@
+package canal
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+// serve serves the commands of the user root, without password, with h until
+// the end of the test, and returns the address of the server.
+func serve(tb testing.TB, h server.Handler) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(tb, err)
+	tb.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn, err := server.NewConn(c, "root", "", h)
+				if err != nil {
+					return
+				}
+				for conn.HandleCommand() == nil {
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
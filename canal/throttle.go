@@ -0,0 +1,104 @@
+package canal
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gongzhxu/go-mysql/replication"
+	"github.com/gongzhxu/go-mysql/utils"
+)
+
+// ThrottleConfig limits the rate the rows are handled at, 0 means no limit. As
+// the next rows are only read once the handler returns, it also limits the load
+// on the source.
+type ThrottleConfig struct {
+	// RowsPerSecond is the number of rows, an updated row counts once.
+	RowsPerSecond int `toml:"rows_per_second"`
+	// BytesPerSecond is the size of the rows in the dump, or of the events in
+	// the binlog.
+	BytesPerSecond int64 `toml:"bytes_per_second"`
+}
+
+// throttle is a token bucket for the rows and one for the bytes, both holding
+// one second of the rate.
+type throttle struct {
+	mu    sync.Mutex
+	rows  rateBucket
+	bytes rateBucket
+}
+
+type rateBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// newThrottle returns nil if cfg has no limits.
+func newThrottle(cfg ThrottleConfig) *throttle {
+	if cfg.RowsPerSecond <= 0 && cfg.BytesPerSecond <= 0 {
+		return nil
+	}
+	now := utils.Now()
+	t := &throttle{}
+	if cfg.RowsPerSecond > 0 {
+		t.rows = rateBucket{rate: float64(cfg.RowsPerSecond), tokens: float64(cfg.RowsPerSecond), last: now}
+	}
+	if cfg.BytesPerSecond > 0 {
+		t.bytes = rateBucket{rate: float64(cfg.BytesPerSecond), tokens: float64(cfg.BytesPerSecond), last: now}
+	}
+	return t
+}
+
+// take returns how long to wait before using n tokens, they are taken at once.
+func (b *rateBucket) take(now time.Time, n int64) time.Duration {
+	if b.rate == 0 || n <= 0 {
+		return 0
+	}
+	b.tokens = min(b.rate, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// wait blocks until rows and bytes are allowed by the limits, or ctx is done.
+func (t *throttle) wait(ctx context.Context, rows int, bytes int64) error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	now := utils.Now()
+	d := max(t.rows.take(now, int64(rows)), t.bytes.take(now, bytes))
+	t.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// eventRows returns the number of rows of a rows event.
+func eventRows(e *replication.BinlogEvent) int {
+	ev, ok := e.Event.(*replication.RowsEvent)
+	if !ok {
+		return 0
+	}
+	switch e.Header.EventType {
+	case replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2, replication.MARIADB_UPDATE_ROWS_COMPRESSED_EVENT_V1:
+		// the rows before and after the update
+		return len(ev.Rows) / 2
+	default:
+		return len(ev.Rows)
+	}
+}
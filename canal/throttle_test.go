@@ -0,0 +1,40 @@
+package canal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/replication"
+)
+
+func TestThrottle(t *testing.T) {
+	require.Nil(t, newThrottle(ThrottleConfig{}))
+	require.NoError(t, (*throttle)(nil).wait(context.Background(), 100, 100))
+
+	th := newThrottle(ThrottleConfig{RowsPerSecond: 10, BytesPerSecond: 1000})
+	now := th.rows.last
+
+	// one second of rows is allowed at once
+	require.Zero(t, th.rows.take(now, 10))
+	require.Equal(t, 500*time.Millisecond, th.rows.take(now, 5))
+	require.Equal(t, 500*time.Millisecond, th.rows.take(now.Add(500*time.Millisecond), 5))
+	// the bucket doesn't hold more than one second
+	require.Zero(t, th.bytes.take(now.Add(time.Hour), 1000))
+	require.Equal(t, 100*time.Millisecond, th.bytes.take(now.Add(time.Hour), 100))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, th.wait(ctx, 0, 1000), context.Canceled)
+
+	update := &replication.BinlogEvent{
+		Header: &replication.EventHeader{EventType: replication.UPDATE_ROWS_EVENTv2},
+		Event:  &replication.RowsEvent{Rows: make([][]interface{}, 4)},
+	}
+	require.Equal(t, 2, eventRows(update))
+	update.Header.EventType = replication.WRITE_ROWS_EVENTv2
+	require.Equal(t, 4, eventRows(update))
+	require.Equal(t, 0, eventRows(&replication.BinlogEvent{Header: update.Header, Event: &replication.XIDEvent{}}))
+}
@@ -0,0 +1,181 @@
+package canal
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/replication"
+)
+
+type binaryLog struct {
+	name string
+	size uint64
+}
+
+// RunFromTimestamp syncs from the first transaction with an event at or after
+// t, see PositionAtTimestamp, ignoring mysqldump. It is to reprocess the
+// changes since t, e.g. after the downstream data is corrupted.
+func (c *Canal) RunFromTimestamp(t time.Time) error {
+	pos, err := c.PositionAtTimestamp(t)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	c.cfg.Logger.Info("run from timestamp", slog.Time("timestamp", t), slog.Any("pos", pos))
+
+	return c.RunFrom(pos)
+}
+
+// PositionAtTimestamp returns the position of the binlog files of the master
+// after the last transaction completed before t, so syncing from it begins
+// with the first transaction having an event at or after t. It is the start of
+// the oldest file if t is before it, and the end of the binlog files if t is
+// after their last event.
+//
+// The binlog files are searched by the time they were created, then the file
+// is read up to t, with a dump connection of a random server ID so the canal
+// can run meanwhile. The timestamps of the events have a precision of one second.
+func (c *Canal) PositionAtTimestamp(t time.Time) (mysql.Position, error) {
+	logs, err := c.binaryLogs()
+	if err != nil {
+		return mysql.Position{}, errors.Trace(err)
+	}
+	if len(logs) == 0 {
+		return mysql.Position{}, errors.New("no binlog file")
+	}
+	return positionAtTimestamp(logs, t.Unix(), c.scanBinlog)
+}
+
+// binlogScanner calls fn for the events of the binlog file name until it
+// returns false or the end of the file, see Canal.scanBinlog.
+type binlogScanner func(name string, fn func(ev *replication.BinlogEvent) bool) error
+
+// positionAtTimestamp returns the position of PositionAtTimestamp for ts in
+// the binlog files logs, read by scan.
+func positionAtTimestamp(logs []binaryLog, ts int64, scan binlogScanner) (mysql.Position, error) {
+	// the last file created at or before ts
+	i, j := 0, len(logs)
+	for i < j {
+		h := int(uint(i+j) >> 1)
+		created, err := binlogCreated(scan, logs[h].name)
+		if err != nil {
+			return mysql.Position{}, errors.Trace(err)
+		}
+		if created <= ts {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+	if i == 0 {
+		return mysql.Position{Name: logs[0].name, Pos: 4}, nil
+	}
+	file := logs[i-1]
+
+	pos := mysql.Position{Name: file.name, Pos: 4}
+	after := false
+	err := scan(file.name, func(ev *replication.BinlogEvent) bool {
+		if int64(ev.Header.Timestamp) >= ts {
+			after = true
+			return false
+		}
+		switch e := ev.Event.(type) {
+		case *replication.FormatDescriptionEvent, *replication.PreviousGTIDsEvent, *replication.XIDEvent:
+			pos.Pos = ev.Header.LogPos
+		case *replication.QueryEvent:
+			// BEGIN starts a transaction, any other statement ends it (COMMIT, DDL)
+			if !strings.EqualFold(string(e.Query), "BEGIN") {
+				pos.Pos = ev.Header.LogPos
+			}
+		}
+		return uint64(ev.Header.LogPos) < file.size
+	})
+	if err != nil {
+		return mysql.Position{}, errors.Trace(err)
+	}
+	if !after && i < len(logs) {
+		// all the events of the file are before ts
+		pos = mysql.Position{Name: logs[i].name, Pos: 4}
+	}
+	return pos, nil
+}
+
+// binaryLogs returns the binlog files of the master, oldest first.
+func (c *Canal) binaryLogs() ([]binaryLog, error) {
+	r, err := c.Execute("SHOW BINARY LOGS")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	logs := make([]binaryLog, r.RowNumber())
+	for i := range logs {
+		logs[i].name, _ = r.GetString(i, 0)
+		logs[i].size, _ = r.GetUint(i, 1)
+	}
+	return logs, nil
+}
+
+// binlogCreated returns the time the binlog file name was created, the
+// timestamp of its FormatDescriptionEvent.
+func binlogCreated(scan binlogScanner, name string) (int64, error) {
+	var created int64
+	err := scan(name, func(ev *replication.BinlogEvent) bool {
+		if _, ok := ev.Event.(*replication.FormatDescriptionEvent); ok {
+			created = int64(ev.Header.Timestamp)
+			return false
+		}
+		return true
+	})
+	return created, errors.Trace(err)
+}
+
+// scanBinlogReadTimeout is how long scanBinlog waits for an event, the dump
+// of a file already written doesn't wait for the server.
+const scanBinlogReadTimeout = 10 * time.Second
+
+// scanBinlog calls fn for the events of the binlog file name until it returns
+// false or the rotate event ending the file, the fake rotate events are skipped
+// and the rows events aren't decoded. It fails if no event is read for
+// scanBinlogReadTimeout.
+func (c *Canal) scanBinlog(name string, fn func(ev *replication.BinlogEvent) bool) error {
+	cfg, err := c.syncerConfig()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for cfg.ServerID == c.cfg.ServerID {
+		cfg.ServerID = uint32(rand.Intn(1000)) + 1001
+	}
+	cfg.RowsEventDecodeFunc = func(*replication.RowsEvent, []byte) error {
+		return nil
+	}
+
+	syncer := replication.NewBinlogSyncer(cfg)
+	defer syncer.Close()
+	s, err := syncer.StartSync(mysql.Position{Name: name, Pos: 4})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for {
+		ctx, cancel := context.WithTimeout(c.ctx, scanBinlogReadTimeout)
+		ev, err := s.GetEvent(ctx)
+		cancel()
+		if err != nil {
+			return errors.Annotatef(err, "read binlog %s", name)
+		}
+		rotate, ok := ev.Event.(*replication.RotateEvent)
+		if ok && ev.Header.Timestamp == 0 {
+			continue
+		}
+		if !fn(ev) {
+			return nil
+		}
+		if ok && string(rotate.NextLogName) != name {
+			// the end of the file, the dump goes on with the next one
+			return nil
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package canal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/replication"
+)
+
+// testBinlog is a binlog file of transactions of one second each, from
+// created: BEGIN, a rows event and XID, then a DDL.
+func testBinlog(name string, created uint32, next string) []*replication.BinlogEvent {
+	var events []*replication.BinlogEvent
+	pos := uint32(4)
+	add := func(ts uint32, e replication.Event) {
+		pos += 100
+		events = append(events, &replication.BinlogEvent{
+			Header: &replication.EventHeader{Timestamp: ts, LogPos: pos},
+			Event:  e,
+		})
+	}
+	// the fake rotate event of the dump
+	events = append(events, &replication.BinlogEvent{
+		Header: &replication.EventHeader{},
+		Event:  &replication.RotateEvent{Position: 4, NextLogName: []byte(name)},
+	})
+	add(created, &replication.FormatDescriptionEvent{})
+	add(created, &replication.PreviousGTIDsEvent{})
+	for ts := created + 1; ts <= created+2; ts++ {
+		add(ts, &replication.QueryEvent{Query: []byte("BEGIN")})
+		add(ts, &replication.RowsEvent{})
+		add(ts, &replication.XIDEvent{})
+	}
+	add(created+3, &replication.QueryEvent{Query: []byte("CREATE TABLE t (id INT)")})
+	if next != "" {
+		add(created+3, &replication.RotateEvent{Position: 4, NextLogName: []byte(next)})
+	}
+	return events
+}
+
+func TestPositionAtTimestamp(t *testing.T) {
+	files := map[string][]*replication.BinlogEvent{}
+	var logs []binaryLog
+	names := []string{"mysql-bin.000001", "mysql-bin.000002", "mysql-bin.000003", "mysql-bin.000004", "mysql-bin.000005"}
+	for i, name := range names {
+		next := ""
+		if i+1 < len(names) {
+			next = names[i+1]
+		}
+		// the files are created every 10 seconds, from 100
+		events := testBinlog(name, uint32(100+10*i), next)
+		files[name] = events
+		logs = append(logs, binaryLog{name: name, size: uint64(events[len(events)-1].Header.LogPos)})
+	}
+
+	var scanned []string
+	scan := func(name string, fn func(ev *replication.BinlogEvent) bool) error {
+		scanned = append(scanned, name)
+		for _, ev := range files[name] {
+			// the fake rotate events are skipped
+			if ev.Header.Timestamp == 0 {
+				continue
+			}
+			if !fn(ev) {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	tests := []struct {
+		ts  int64
+		pos mysql.Position
+	}{
+		// before the oldest file
+		{50, mysql.Position{Name: "mysql-bin.000001", Pos: 4}},
+		// the file is created at ts
+		{100, mysql.Position{Name: "mysql-bin.000001", Pos: 4}},
+		// the end of the previous GTIDs, before the first transaction
+		{101, mysql.Position{Name: "mysql-bin.000001", Pos: 204}},
+		// after the XID of the first transaction, not its BEGIN
+		{102, mysql.Position{Name: "mysql-bin.000001", Pos: 504}},
+		// after the second transaction, before the DDL
+		{103, mysql.Position{Name: "mysql-bin.000001", Pos: 804}},
+		// all the events of the file are before ts
+		{104, mysql.Position{Name: "mysql-bin.000002", Pos: 4}},
+		{109, mysql.Position{Name: "mysql-bin.000002", Pos: 4}},
+		{122, mysql.Position{Name: "mysql-bin.000003", Pos: 504}},
+		{133, mysql.Position{Name: "mysql-bin.000004", Pos: 804}},
+		{141, mysql.Position{Name: "mysql-bin.000005", Pos: 204}},
+		// after the last event, the end of the last file
+		{200, mysql.Position{Name: "mysql-bin.000005", Pos: 904}},
+	}
+	for _, tt := range tests {
+		scanned = nil
+		pos, err := positionAtTimestamp(logs, tt.ts, scan)
+		require.NoError(t, err)
+		require.Equal(t, tt.pos, pos, tt.ts)
+		// the creation times of at most 3 of the 5 files are read, then the file
+		// of ts
+		require.LessOrEqual(t, len(scanned), 4, tt.ts)
+	}
+
+	scanned = nil
+	_, err := positionAtTimestamp(logs, 122, scan)
+	require.NoError(t, err)
+	require.Equal(t, []string{"mysql-bin.000003", "mysql-bin.000005", "mysql-bin.000004", "mysql-bin.000003"}, scanned)
+}
@@ -0,0 +1,139 @@
+package canal
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/replication"
+	"github.com/gongzhxu/go-mysql/schema"
+)
+
+// Transaction is a group of row events committed together.
+type Transaction struct {
+	// GTID is the event which started the transaction, nil if GTID mode is off.
+	GTID mysql.BinlogGTIDEvent
+	// CommitTime is the commit time on the immediate source. The timestamp
+	// of the commit event is used if the server doesn't send it.
+	CommitTime time.Time
+	// Size is the total size in bytes of the (uncompressed) binlog events
+	// of the transaction.
+	Size uint64
+	// Rows holds the row events of the transaction, in binlog order, or sorted
+	// by the foreign keys if Config.SortTransactionRows is set.
+	// Events of excluded tables are not included.
+	Rows []*RowsEvent
+	// Pos is the binlog position after the commit event.
+	Pos mysql.Position
+	// GSet is the executed GTID set after the commit, nil if GTID mode is off.
+	GSet mysql.GTIDSet
+}
+
+// TransactionHandler is called once for every committed transaction.
+type TransactionHandler func(trx *Transaction) error
+
+// OnTransaction registers h to receive row events grouped per transaction.
+// The handler is called after EventHandler.OnXID and before the position is
+// synced. OnRow is still called for each rows event. DDL statements are not
+// delivered as transactions, use EventHandler.OnDDL for them.
+// You must register the handler before starting Canal.
+func (c *Canal) OnTransaction(h TransactionHandler) {
+	c.trxHandler = h
+}
+
+func (c *Canal) beginTransaction(header *replication.EventHeader, gtid mysql.BinlogGTIDEvent) {
+	if c.trxHandler == nil {
+		return
+	}
+
+	c.trx = &Transaction{
+		GTID: gtid,
+		Size: uint64(header.EventSize),
+	}
+	if e, ok := gtid.(*replication.GTIDEvent); ok && e.ImmediateCommitTimestamp > 0 {
+		c.trx.CommitTime = e.ImmediateCommitTime()
+	}
+}
+
+func (c *Canal) commitTransaction(header *replication.EventHeader, pos mysql.Position, gset mysql.GTIDSet) error {
+	trx := c.trx
+	if trx == nil {
+		return nil
+	}
+	c.trx = nil
+
+	if trx.CommitTime.IsZero() {
+		trx.CommitTime = time.Unix(int64(header.Timestamp), 0)
+	}
+	trx.Pos = pos
+	if gset != nil {
+		trx.GSet = gset.Clone()
+	}
+	if c.cfg.SortTransactionRows {
+		rows, err := SortRowsByForeignKeys(trx.Rows)
+		if err != nil {
+			c.cfg.Logger.Warn("keep the binlog order of the rows", slog.Any("pos", pos), slog.Any("error", err))
+		} else {
+			trx.Rows = rows
+		}
+	}
+
+	return c.trxHandler(trx)
+}
+
+// SortRowsByForeignKeys sorts the row events of a transaction so they can be
+// applied to a target checking the foreign keys: the deletes first, the tables
+// referencing others before them, then the inserts and updates, the referenced
+// tables first. The events of a table keep their binlog order, as its rows may
+// be written several times, e.g. inserted then deleted: an event only moves
+// ahead of the events of other tables. It fails if the foreign keys of the
+// tables have a cycle, see schema.SortByForeignKeys.
+func SortRowsByForeignKeys(rows []*RowsEvent) ([]*RowsEvent, error) {
+	var tables []*schema.Table
+	queues := make(map[string][]*RowsEvent)
+	for _, e := range rows {
+		name := e.Table.String()
+		if _, ok := queues[name]; !ok {
+			tables = append(tables, e.Table)
+		}
+		queues[name] = append(queues[name], e)
+	}
+	if len(tables) < 2 {
+		return rows, nil
+	}
+
+	sorted, err := schema.SortByForeignKeys(tables)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	rank := make(map[string]int, len(sorted))
+	for i, ta := range sorted {
+		rank[ta.String()] = i
+	}
+
+	key := func(e *RowsEvent) int {
+		r := rank[e.Table.String()]
+		if e.Action == DeleteAction {
+			// before all inserts and updates, in reverse order
+			return -1 - r
+		}
+		return r
+	}
+	// merge the events of the tables, taking the first event of a table with
+	// the lowest key, of the table seen first for the same key
+	res := make([]*RowsEvent, 0, len(rows))
+	for len(res) < len(rows) {
+		var next string
+		for _, ta := range tables {
+			name := ta.String()
+			if q := queues[name]; len(q) > 0 && (next == "" || key(q[0]) < key(queues[next][0])) {
+				next = name
+			}
+		}
+		res = append(res, queues[next][0])
+		queues[next] = queues[next][1:]
+	}
+	return res, nil
+}
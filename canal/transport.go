@@ -0,0 +1,602 @@
+package canal
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pingcap/errors"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/schema"
+)
+
+// TransportOffset is the position of the source after a transaction, from where
+// the replication is resumed.
+type TransportOffset struct {
+	Pos mysql.Position
+	// Flavor and GTIDSet are the executed GTID set, empty if GTID mode is off.
+	Flavor  string
+	GTIDSet string
+}
+
+// ReplayStatement is a statement replaying the rows of a transaction.
+type ReplayStatement struct {
+	Query string
+	Args  []interface{}
+}
+
+// ReplayTransaction is a transaction sent by a TransactionSender.
+type ReplayTransaction struct {
+	Offset     TransportOffset
+	Statements []ReplayStatement
+}
+
+// TransactionSender sends the transactions of a Canal to a TransactionReceiver
+// replaying them into another MySQL, forming a logical replication pipe, e.g.
+// between regions. It is registered with Canal.OnTransaction(s.Send) and sends
+// them over a gRPC stream of the TransactionTransport service of the
+// receiver, see TransactionReceiver.Register.
+//
+// The transactions are sent in batches of BatchSize transactions, or after
+// MaxDelay for the last ones. A batch is a message of the stream, encoded with
+// gob and compressed with zstd. The receiver acknowledges each batch with the
+// offset of its last transaction once it is replayed and committed, see
+// Offset. The rows are sent as the statements replaying them: REPLACE for the
+// inserts, UPDATE and DELETE by the primary key, or by all the columns for the
+// tables without one. The row images must be full, binlog_row_image=FULL, and
+// the generated columns are skipped.
+//
+// The transactions of a batch not acknowledged yet are lost if the source
+// stops, so the positions passed to EventHandler.OnPosSynced must not be used
+// to resume the source: it is resumed from Offset, the checkpoint of the
+// receiver sent when the stream is opened, with Canal.RunFrom or
+// Canal.StartFromGTID.
+type TransactionSender struct {
+	// BatchSize is the number of transactions of a batch, 100 by default.
+	BatchSize int
+	// MaxDelay is the maximum time a transaction waits for its batch to be
+	// full, 100ms by default.
+	MaxDelay time.Duration
+
+	stream grpc.ClientStream
+	enc    *zstd.Encoder
+	// done is closed once the acknowledgements are read
+	done chan struct{}
+
+	// mu guards the batch and the sending of the stream
+	mu    sync.Mutex
+	batch []*ReplayTransaction
+	timer *time.Timer
+
+	// ackMu guards the acknowledgements, signaled by acked
+	ackMu  sync.Mutex
+	acked  *sync.Cond
+	sent   int
+	acks   int
+	offset TransportOffset
+	err    error
+}
+
+// NewTransactionSender opens a stream of the TransactionTransport service of
+// conn, ended by ctx, and returns the sender of the transactions once the
+// receiver sent its offset, see Offset.
+func NewTransactionSender(ctx context.Context, conn grpc.ClientConnInterface) (*TransactionSender, error) {
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	stream, err := conn.NewStream(ctx, &transportServiceDesc.Streams[0], transportReplicateMethod)
+	if err != nil {
+		return nil, errors.Annotate(err, "open transaction stream")
+	}
+	s := &TransactionSender{
+		BatchSize: 100,
+		MaxDelay:  100 * time.Millisecond,
+		stream:    stream,
+		enc:       enc,
+		done:      make(chan struct{}),
+	}
+	s.acked = sync.NewCond(&s.ackMu)
+	if s.offset, err = recvOffset(stream); err != nil {
+		return nil, errors.Annotate(err, "receive transaction offset")
+	}
+	go s.recvAcks()
+	return s, nil
+}
+
+// Offset returns the offset the source is resumed from: the one of the last
+// transaction replayed by the receiver, acknowledged on the stream, empty if
+// none was.
+func (s *TransactionSender) Offset() TransportOffset {
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+	return s.offset
+}
+
+// Send adds trx to the batch, and sends the batch if it is full. It returns the
+// error of sending or replaying a previous batch, after which the stream can't
+// be used.
+func (s *TransactionSender) Send(trx *Transaction) error {
+	t, err := replayTransaction(trx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err = s.failed(); err != nil {
+		return err
+	}
+	s.batch = append(s.batch, t)
+	if len(s.batch) >= s.BatchSize {
+		return s.flush()
+	}
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.MaxDelay, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.timer = nil
+			_ = s.flush()
+		})
+	}
+	return nil
+}
+
+// Flush sends the transactions of the batch, and waits for the receiver to
+// acknowledge all the batches sent.
+func (s *TransactionSender) Flush() error {
+	s.mu.Lock()
+	err := s.flush()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+	for s.acks < s.sent && s.err == nil {
+		s.acked.Wait()
+	}
+	return s.err
+}
+
+// Close flushes the batch and closes the stream.
+func (s *TransactionSender) Close() error {
+	err := s.Flush()
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if closeErr := s.stream.CloseSend(); err == nil && closeErr != nil {
+		err = errors.Trace(closeErr)
+	}
+	s.mu.Unlock()
+	if err == nil {
+		// the receiver ends the stream
+		<-s.done
+		if err = s.failed(); err == errTransportClosed {
+			err = nil
+		}
+	}
+	s.enc.Close()
+	return err
+}
+
+// errTransportClosed is the error of the sender once its stream was ended by
+// the receiver.
+var errTransportClosed = errors.New("transaction stream closed")
+
+func (s *TransactionSender) failed() error {
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+	return s.err
+}
+
+func (s *TransactionSender) fail(err error) {
+	s.ackMu.Lock()
+	defer s.ackMu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.acked.Broadcast()
+}
+
+func (s *TransactionSender) flush() error {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if err := s.failed(); err != nil || len(s.batch) == 0 {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s.batch); err != nil {
+		s.fail(errors.Annotate(err, "encode transactions"))
+		return s.failed()
+	}
+	s.batch = s.batch[:0]
+
+	msg := &wrapperspb.BytesValue{Value: s.enc.EncodeAll(buf.Bytes(), make([]byte, 0, buf.Len()/2))}
+	if err := s.stream.SendMsg(msg); err != nil {
+		if err == io.EOF {
+			// the stream ended, with the error received by recvAcks
+			<-s.done
+		}
+		s.fail(errors.Annotate(err, "send transactions"))
+		return s.failed()
+	}
+	s.ackMu.Lock()
+	s.sent++
+	s.ackMu.Unlock()
+	return nil
+}
+
+// recvAcks reads the acknowledgements of the batches until the stream ends.
+func (s *TransactionSender) recvAcks() {
+	defer close(s.done)
+	for {
+		offset, err := recvOffset(s.stream)
+		if err == io.EOF {
+			s.fail(errTransportClosed)
+			return
+		} else if err != nil {
+			s.fail(errors.Annotate(err, "receive transaction acknowledgement"))
+			return
+		}
+		s.ackMu.Lock()
+		s.acks++
+		s.offset = offset
+		s.acked.Broadcast()
+		s.ackMu.Unlock()
+	}
+}
+
+// recvOffset receives an offset of the receiver.
+func recvOffset(stream grpc.ClientStream) (TransportOffset, error) {
+	var offset TransportOffset
+	msg := new(wrapperspb.BytesValue)
+	if err := stream.RecvMsg(msg); err != nil {
+		return offset, err
+	}
+	err := gob.NewDecoder(bytes.NewReader(msg.Value)).Decode(&offset)
+	return offset, errors.Trace(err)
+}
+
+// replayTransaction returns the statements replaying trx.
+func replayTransaction(trx *Transaction) (*ReplayTransaction, error) {
+	t := &ReplayTransaction{Offset: TransportOffset{Pos: trx.Pos}}
+	if trx.GSet != nil {
+		t.Offset.GTIDSet = trx.GSet.String()
+		t.Offset.Flavor = mysql.MySQLFlavor
+		if _, ok := trx.GSet.(*mysql.MariadbGTIDSet); ok {
+			t.Offset.Flavor = mysql.MariaDBFlavor
+		}
+	}
+	for _, e := range trx.Rows {
+		changes, err := e.RowChanges()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		for _, c := range changes {
+			stmt, err := replayStatement(e.Table, c)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			t.Statements = append(t.Statements, stmt)
+		}
+	}
+	return t, nil
+}
+
+// replayStatement returns the statement replaying c. It fails if the row of an
+// update or a delete can't be found, without its primary key or all its
+// columns in the before image.
+func replayStatement(table *schema.Table, c *RowChange) (ReplayStatement, error) {
+	name := mysql.QuoteIdentifier(table.Schema) + "." + mysql.QuoteIdentifier(table.Name)
+	var columns []string
+	var args []interface{}
+	if c.After != nil {
+		for i := range table.Columns {
+			col := &table.Columns[i]
+			if v, ok := c.After[col.Name]; ok && !col.IsVirtual && !col.IsStored {
+				columns = append(columns, mysql.QuoteIdentifier(col.Name))
+				args = append(args, replayValue(v))
+			}
+		}
+	}
+
+	if c.Action == InsertAction {
+		return ReplayStatement{
+			Query: fmt.Sprintf("REPLACE INTO %s (%s) VALUES (%s)", name, strings.Join(columns, ","),
+				strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",")),
+			Args: args,
+		}, nil
+	}
+
+	// the row is found by the primary key, or by all its columns
+	var where []string
+	for i := range table.Columns {
+		col := &table.Columns[i]
+		if col.IsVirtual || col.IsStored || len(table.PKColumns) > 0 && !table.IsPrimaryKey(i) {
+			continue
+		}
+		v, ok := c.Before[col.Name]
+		if !ok {
+			// e.g. binlog_row_image=MINIMAL for a table without primary key
+			return ReplayStatement{}, errors.Errorf("no column %s in the before image of %s, the row image must be full", col.Name, table)
+		}
+		where = append(where, mysql.QuoteIdentifier(col.Name)+" <=> ?")
+		args = append(args, replayValue(v))
+	}
+	if len(where) == 0 {
+		return ReplayStatement{}, errors.Errorf("no column to find the row of %s", table)
+	}
+	limit := ""
+	if len(table.PKColumns) == 0 {
+		limit = " LIMIT 1"
+	}
+
+	if c.Action == DeleteAction {
+		return ReplayStatement{
+			Query: fmt.Sprintf("DELETE FROM %s WHERE %s%s", name, strings.Join(where, " AND "), limit),
+			Args:  args,
+		}, nil
+	}
+	for i := range columns {
+		columns[i] += " = ?"
+	}
+	return ReplayStatement{
+		Query: fmt.Sprintf("UPDATE %s SET %s WHERE %s%s", name, strings.Join(columns, ", "), strings.Join(where, " AND "), limit),
+		Args:  args,
+	}, nil
+}
+
+// replayValue returns v as a type encoded by gob without registering it, and
+// executed by client.Conn.Execute.
+func replayValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case int8:
+		return int64(x)
+	case int16:
+		return int64(x)
+	case int32:
+		return int64(x)
+	case int:
+		return int64(x)
+	case uint8:
+		return uint64(x)
+	case uint16:
+		return uint64(x)
+	case uint32:
+		return uint64(x)
+	case uint:
+		return uint64(x)
+	case float32:
+		return float64(x)
+	case decimal.Decimal:
+		return x.String()
+	case time.Time:
+		return x.Format("2006-01-02 15:04:05.999999")
+	}
+	return v
+}
+
+// DefaultMaxBatchSize is the default TransactionReceiver.MaxBatchSize.
+const DefaultMaxBatchSize = 64 << 20
+
+// TransactionReceiver replays the transactions sent by a TransactionSender into
+// a MySQL. Each transaction is applied in a transaction along with its offset,
+// saved in a checkpoint table: the transactions replayed already are skipped,
+// so the source can be resumed from Offset, or before, after a failure. It
+// serves one stream at a time.
+type TransactionReceiver struct {
+	// MaxBatchSize is the maximum size of a batch once decompressed, a larger
+	// one fails the stream instead of being allocated. It is
+	// DefaultMaxBatchSize by default. The compressed batches are limited by
+	// the grpc.MaxRecvMsgSize of the server, 4MB by default.
+	MaxBatchSize uint64
+
+	conn       *client.Conn
+	checkpoint string
+	streaming  atomic.Bool
+
+	mu     sync.Mutex
+	offset TransportOffset
+	gset   mysql.GTIDSet
+}
+
+// NewTransactionReceiver returns a receiver replaying into conn, saving the
+// offsets in the table checkpoint, like "db.canal_checkpoint", created if it
+// doesn't exist.
+func NewTransactionReceiver(conn *client.Conn, checkpoint string) (*TransactionReceiver, error) {
+	var name string
+	for i, part := range strings.SplitN(checkpoint, ".", 2) {
+		if i > 0 {
+			name += "."
+		}
+		name += mysql.QuoteIdentifier(part)
+	}
+	r := &TransactionReceiver{MaxBatchSize: DefaultMaxBatchSize, conn: conn, checkpoint: name}
+
+	if _, err := conn.Execute(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s ("+
+		"id TINYINT UNSIGNED PRIMARY KEY, pos_name VARCHAR(255) NOT NULL, pos INT UNSIGNED NOT NULL, "+
+		"flavor VARCHAR(16) NOT NULL, gtid_set TEXT NOT NULL)", name)); err != nil {
+		return nil, errors.Trace(err)
+	}
+	res, err := conn.Execute("SELECT pos_name, pos, flavor, gtid_set FROM " + name + " WHERE id = 1")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if res.RowNumber() > 0 {
+		r.offset.Pos.Name, _ = res.GetString(0, 0)
+		pos, _ := res.GetUint(0, 1)
+		r.offset.Pos.Pos = uint32(pos)
+		r.offset.Flavor, _ = res.GetString(0, 2)
+		r.offset.GTIDSet, _ = res.GetString(0, 3)
+		if r.offset.GTIDSet != "" {
+			if r.gset, err = mysql.ParseGTIDSet(r.offset.Flavor, r.offset.GTIDSet); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+	}
+	return r, nil
+}
+
+// Register registers the receiver as the TransactionTransport service of s,
+// like a grpc.Server.
+func (r *TransactionReceiver) Register(s grpc.ServiceRegistrar) {
+	s.RegisterService(&transportServiceDesc, r)
+}
+
+// Offset returns the offset of the last transaction replayed, from where the
+// source is resumed, empty if none was.
+func (r *TransactionReceiver) Offset() TransportOffset {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.offset
+}
+
+// replicate replays the batches of stream until the sender closes it. The
+// offset is sent when the stream is opened, then after each batch.
+func (r *TransactionReceiver) replicate(stream grpc.ServerStream) error {
+	if !r.streaming.CompareAndSwap(false, true) {
+		return status.Error(codes.Aborted, "a transaction stream is replayed already")
+	}
+	defer r.streaming.Store(false)
+
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(r.MaxBatchSize))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer dec.Close()
+
+	if err = r.sendOffset(stream); err != nil {
+		return err
+	}
+	for {
+		msg := new(wrapperspb.BytesValue)
+		if err = stream.RecvMsg(msg); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		data, err := dec.DecodeAll(msg.Value, nil)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "decompress transactions: %v", err)
+		}
+		var batch []*ReplayTransaction
+		if err = gob.NewDecoder(bytes.NewReader(data)).Decode(&batch); err != nil {
+			return status.Errorf(codes.InvalidArgument, "decode transactions: %v", err)
+		}
+
+		for _, t := range batch {
+			if err = r.replay(t); err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+		}
+		if err = r.sendOffset(stream); err != nil {
+			return err
+		}
+	}
+}
+
+// sendOffset sends the offset of the last transaction replayed.
+func (r *TransactionReceiver) sendOffset(stream grpc.ServerStream) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(r.Offset()); err != nil {
+		return errors.Trace(err)
+	}
+	return stream.SendMsg(&wrapperspb.BytesValue{Value: buf.Bytes()})
+}
+
+// replayed returns whether t was replayed already.
+func (r *TransactionReceiver) replayed(t *ReplayTransaction) (bool, error) {
+	if t.Offset.GTIDSet != "" && r.gset != nil {
+		gset, err := mysql.ParseGTIDSet(t.Offset.Flavor, t.Offset.GTIDSet)
+		if err != nil {
+			return false, errors.Trace(err)
+		}
+		return r.gset.Contain(gset), nil
+	}
+	return r.offset.Pos.Name != "" && t.Offset.Pos.Compare(r.offset.Pos) <= 0, nil
+}
+
+func (r *TransactionReceiver) replay(t *ReplayTransaction) (err error) {
+	if done, err := r.replayed(t); err != nil || done {
+		return err
+	}
+
+	if err = r.conn.Begin(); err != nil {
+		return errors.Trace(err)
+	}
+	defer func() {
+		if err != nil {
+			_ = r.conn.Rollback()
+		}
+	}()
+	for _, s := range t.Statements {
+		if _, err = r.conn.Execute(s.Query, s.Args...); err != nil {
+			return errors.Annotatef(err, "replay %s", s.Query)
+		}
+	}
+	if _, err = r.conn.Execute("REPLACE INTO "+r.checkpoint+" (id, pos_name, pos, flavor, gtid_set) VALUES (1, ?, ?, ?, ?)",
+		t.Offset.Pos.Name, t.Offset.Pos.Pos, t.Offset.Flavor, t.Offset.GTIDSet); err != nil {
+		return errors.Trace(err)
+	}
+	if err = r.conn.Commit(); err != nil {
+		return errors.Trace(err)
+	}
+
+	var gset mysql.GTIDSet
+	if t.Offset.GTIDSet != "" {
+		if gset, err = mysql.ParseGTIDSet(t.Offset.Flavor, t.Offset.GTIDSet); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	r.mu.Lock()
+	r.offset, r.gset = t.Offset, gset
+	r.mu.Unlock()
+	return nil
+}
+
+// transportReplicateMethod is the method of the stream of the
+// TransactionTransport service.
+const transportReplicateMethod = "/canal.TransactionTransport/Replicate"
+
+// transportServer is the server of the TransactionTransport service.
+type transportServer interface {
+	replicate(stream grpc.ServerStream) error
+}
+
+// transportServiceDesc is the TransactionTransport service: the messages of
+// its bidirectional stream Replicate are google.protobuf.BytesValue, the
+// batches from the sender and the offsets from the receiver, encoded with gob.
+var transportServiceDesc = grpc.ServiceDesc{
+	ServiceName: "canal.TransactionTransport",
+	HandlerType: (*transportServer)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName: "Replicate",
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			return srv.(transportServer).replicate(stream)
+		},
+		ServerStreams: true,
+		ClientStreams: true,
+	}},
+}
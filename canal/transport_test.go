@@ -0,0 +1,188 @@
+package canal
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/schema"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+// replayHandler records the statements replayed.
+type replayHandler struct {
+	server.EmptyHandler
+	mu         sync.Mutex
+	statements []ReplayStatement
+}
+
+func (h *replayHandler) HandleQuery(query string) (*mysql.Result, error) {
+	if strings.HasPrefix(query, "SELECT") {
+		rs, err := mysql.BuildSimpleTextResultset([]string{"pos_name", "pos", "flavor", "gtid_set"}, nil)
+		if err != nil {
+			return nil, err
+		}
+		return mysql.NewResult(rs), nil
+	}
+	h.record(query, nil)
+	return nil, nil
+}
+
+func (h *replayHandler) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	return strings.Count(query, "?"), 0, nil, nil
+}
+
+func (h *replayHandler) HandleStmtExecute(context interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	h.record(query, args)
+	return &mysql.Result{AffectedRows: 1}, nil
+}
+
+func (h *replayHandler) HandleStmtClose(context interface{}) error {
+	return nil
+}
+
+func (h *replayHandler) record(query string, args []interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.statements = append(h.statements, ReplayStatement{Query: query, Args: args})
+}
+
+// serveTransport serves the TransactionTransport service of r in memory, and
+// returns a connection to it.
+func serveTransport(t *testing.T, r *TransactionReceiver) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	r.Register(srv)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///transport",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestTransactionTransport(t *testing.T) {
+	table := &schema.Table{
+		Schema: "test",
+		Name:   "t",
+		Columns: []schema.TableColumn{
+			{Name: "id", Type: schema.TYPE_NUMBER},
+			{Name: "name", Type: schema.TYPE_STRING},
+			{Name: "len", Type: schema.TYPE_NUMBER, IsVirtual: true},
+		},
+		PKColumns: []int{0},
+	}
+	trx := &Transaction{
+		Pos: mysql.Position{Name: "mysql-bin.000001", Pos: 100},
+		Rows: []*RowsEvent{
+			{Table: table, Action: InsertAction, Rows: [][]interface{}{{int32(1), "a", int64(1)}}},
+			{Table: table, Action: UpdateAction, Rows: [][]interface{}{{int32(1), "a", int64(1)}, {int32(1), "bb", int64(2)}}},
+			{Table: table, Action: DeleteAction, Rows: [][]interface{}{{int32(1), "bb", int64(2)}}},
+		},
+	}
+
+	h := &replayHandler{}
+	addr := serve(t, h)
+	conn, err := client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	r, err := NewTransactionReceiver(conn, "test.checkpoint")
+	require.NoError(t, err)
+	grpcConn := serveTransport(t, r)
+
+	s, err := NewTransactionSender(context.Background(), grpcConn)
+	require.NoError(t, err)
+	require.Equal(t, TransportOffset{}, s.Offset())
+	// one stream at a time
+	_, err = NewTransactionSender(context.Background(), grpcConn)
+	require.Equal(t, codes.Aborted, status.Code(errors.Cause(err)))
+
+	s.BatchSize = 2
+	s.MaxDelay = time.Hour
+	require.NoError(t, s.Send(trx))
+	trx2 := *trx
+	trx2.Pos.Pos = 200
+	trx2.Rows = trx.Rows[:1]
+	// a batch of 2
+	require.NoError(t, s.Send(&trx2))
+	require.NoError(t, s.Flush())
+	require.Equal(t, TransportOffset{Pos: mysql.Position{Name: "mysql-bin.000001", Pos: 200}}, s.Offset())
+	require.Equal(t, s.Offset(), r.Offset())
+	require.NoError(t, s.Send(trx))
+	require.NoError(t, s.Close())
+	require.ErrorIs(t, s.Send(trx), errTransportClosed)
+
+	h.mu.Lock()
+	require.Contains(t, h.statements[0].Query, "CREATE TABLE IF NOT EXISTS `test`.`checkpoint`")
+	require.Equal(t, []ReplayStatement{
+		{Query: "BEGIN"},
+		{Query: "REPLACE INTO `test`.`t` (`id`,`name`) VALUES (?,?)", Args: []interface{}{int64(1), []byte("a")}},
+		{Query: "UPDATE `test`.`t` SET `id` = ?, `name` = ? WHERE `id` <=> ?", Args: []interface{}{int64(1), []byte("bb"), int64(1)}},
+		{Query: "DELETE FROM `test`.`t` WHERE `id` <=> ?", Args: []interface{}{int64(1)}},
+		{
+			Query: "REPLACE INTO `test`.`checkpoint` (id, pos_name, pos, flavor, gtid_set) VALUES (1, ?, ?, ?, ?)",
+			Args:  []interface{}{[]byte("mysql-bin.000001"), uint32(100), []byte{}, []byte{}},
+		},
+		{Query: "COMMIT"},
+	}, h.statements[1:7])
+	// the third transaction was replayed already, at 100
+	require.Len(t, h.statements, 1+6+4)
+	h.mu.Unlock()
+
+	// the source resumes from the offset of the receiver
+	s, err = NewTransactionSender(context.Background(), grpcConn)
+	require.NoError(t, err)
+	require.Equal(t, TransportOffset{Pos: mysql.Position{Name: "mysql-bin.000001", Pos: 200}}, s.Offset())
+
+	// a batch larger than MaxBatchSize is not decompressed
+	r.MaxBatchSize = 1 << 10
+	require.NoError(t, s.Close())
+	s, err = NewTransactionSender(context.Background(), grpcConn)
+	require.NoError(t, err)
+	big := *trx
+	big.Pos.Pos = 300
+	big.Rows = []*RowsEvent{{Table: table, Action: InsertAction, Rows: [][]interface{}{{int32(2), strings.Repeat("a", 4<<10), int64(1)}}}}
+	require.NoError(t, s.Send(&big))
+	err = s.Flush()
+	require.Equal(t, codes.InvalidArgument, status.Code(errors.Cause(err)))
+	require.ErrorIs(t, s.Close(), err)
+	require.Equal(t, TransportOffset{Pos: mysql.Position{Name: "mysql-bin.000001", Pos: 200}}, r.Offset())
+}
+
+func TestReplayStatementImage(t *testing.T) {
+	table := &schema.Table{
+		Schema:  "test",
+		Name:    "t",
+		Columns: []schema.TableColumn{{Name: "id", Type: schema.TYPE_NUMBER}, {Name: "name", Type: schema.TYPE_STRING}},
+	}
+	// binlog_row_image=MINIMAL logs no column of the before image of a table
+	// without primary key
+	_, err := replayStatement(table, &RowChange{Action: DeleteAction, Before: map[string]interface{}{}})
+	require.ErrorContains(t, err, "no column id in the before image of test.t")
+	_, err = replayStatement(table, &RowChange{Action: UpdateAction, Before: map[string]interface{}{"id": 1}, After: map[string]interface{}{"name": "a"}})
+	require.ErrorContains(t, err, "no column name")
+
+	table.PKColumns = []int{0}
+	stmt, err := replayStatement(table, &RowChange{Action: UpdateAction, Before: map[string]interface{}{"id": 1}, After: map[string]interface{}{"name": "a"}})
+	require.NoError(t, err)
+	require.Equal(t, ReplayStatement{Query: "UPDATE `test`.`t` SET `name` = ? WHERE `id` <=> ?", Args: []interface{}{"a", int64(1)}}, stmt)
+	_, err = replayStatement(table, &RowChange{Action: DeleteAction, Before: map[string]interface{}{"name": "a"}})
+	require.ErrorContains(t, err, "no column id")
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/gongzhxu/go-mysql/mysql"
 	"github.com/gongzhxu/go-mysql/packet"
 	"github.com/pingcap/errors"
-	"github.com/pingcap/tidb/pkg/parser/charset"
 )
 
 const defaultAuthPluginName = mysql.AUTH_NATIVE_PASSWORD
@@ -158,7 +157,7 @@ func (c *Conn) genAuthResponse(authData []byte) ([]byte, bool, error) {
 	case mysql.AUTH_CACHING_SHA2_PASSWORD:
 		return mysql.CalcCachingSha2Password(authData, c.password), false, nil
 	case mysql.AUTH_CLEAR_PASSWORD:
-		return []byte(c.password), true, nil
+		return c.clearPasswordResponse()
 	case mysql.AUTH_SHA256_PASSWORD:
 		if len(c.password) == 0 {
 			return nil, true, nil
@@ -167,6 +166,10 @@ func (c *Conn) genAuthResponse(authData []byte) ([]byte, bool, error) {
 			// write cleartext auth packet
 			// see: https://dev.mysql.com/doc/refman/8.0/en/sha256-pluggable-authentication.html
 			return []byte(c.password), true, nil
+		} else if pub := c.serverPublicKey(); pub != nil {
+			// encrypt with the known public key of the server
+			enc, err := mysql.EncryptPassword(c.password, authData, pub)
+			return enc, false, errors.Trace(err)
 		} else {
 			// request public key from server
 			// see: https://dev.mysql.com/doc/internals/en/public-key-retrieval.html
@@ -182,7 +185,10 @@ func (c *Conn) genAuthResponse(authData []byte) ([]byte, bool, error) {
 		}
 		return res, false, nil
 	default:
-		// not reachable
+		if p, ok := c.authPlugins[c.authPluginName]; ok {
+			auth, err := p.Start(c, authData)
+			return auth, false, errors.Trace(err)
+		}
 		return nil, false, fmt.Errorf("auth plugin '%s' is not supported", c.authPluginName)
 	}
 }
@@ -203,7 +209,7 @@ func (c *Conn) genAttributes() []byte {
 
 // See: http://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::HandshakeResponse
 func (c *Conn) writeAuthHandshake() error {
-	if !authPluginAllowed(c.authPluginName) {
+	if !c.authPluginAllowed(c.authPluginName) {
 		return fmt.Errorf("unknown auth plugin name '%s'", c.authPluginName)
 	}
 
@@ -287,19 +293,19 @@ func (c *Conn) writeAuthHandshake() error {
 	data[11] = 0x00
 
 	// Charset [1 byte]
-	// use default collation id 255 here, is `utf8mb4_0900_ai_ci`
-	collationName := c.collation
-	if len(collationName) == 0 {
-		collationName = mysql.DEFAULT_COLLATION_NAME
-	}
-	collation, err := charset.GetCollationByName(collationName)
+	// the collation set by SetCollation, or the default collation of the charset
+	collation, handshakeCollation, err := c.handshakeCollation()
 	if err != nil {
-		return fmt.Errorf("invalid collation name %s", collationName)
+		return errors.Trace(err)
+	}
+	c.charset = collation.CharsetName
+	if len(c.collation) != 0 {
+		c.collation = collation.Name
 	}
 
-	// the MySQL protocol calls for the collation id to be sent as 1 byte, where only the
-	// lower 8 bits are used in this field.
-	data[12] = byte(collation.ID & 0xff)
+	// the MySQL protocol calls for the collation id to be sent as 1 byte, the collations
+	// with a larger ID are set by SET NAMES after the handshake.
+	data[12] = byte(handshakeCollation.ID)
 
 	// SSL Connection Request Packet
 	// http://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::SSLRequest
@@ -310,7 +316,7 @@ func (c *Conn) writeAuthHandshake() error {
 		}
 
 		// Switch to TLS
-		tlsConn := tls.Client(c.Conn.Conn, c.tlsConfig)
+		tlsConn := tls.Client(c.Conn.Conn, c.handshakeTLSConfig())
 		if err := tlsConn.Handshake(); err != nil {
 			return err
 		}
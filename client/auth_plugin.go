@@ -0,0 +1,123 @@
+package client
+
+import (
+	"encoding/binary"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// AuthPlugin is a client side authentication method, in addition to the
+// built-in ones, see WithAuthPlugin.
+type AuthPlugin interface {
+	// Start returns the first response to authData, the data sent by the server
+	// with the initial handshake or the auth switch request.
+	Start(c *Conn, authData []byte) ([]byte, error)
+	// Next returns the response to data, sent by the server in an AuthMoreData
+	// packet, until the server sends OK.
+	Next(c *Conn, data []byte) ([]byte, error)
+}
+
+// WithAuthPlugin makes the client support the authentication method name with
+// p, when the server asks for it.
+func WithAuthPlugin(name string, p AuthPlugin) Option {
+	return func(c *Conn) error {
+		if c.authPlugins == nil {
+			c.authPlugins = make(map[string]AuthPlugin)
+		}
+		c.authPlugins[name] = p
+		return nil
+	}
+}
+
+// WithCleartextPassword enables the 'mysql_clear_password' authentication
+// method, sending the password in clear, used by the servers authenticating
+// with LDAP or PAM. Like the enable-cleartext-plugin option of the MySQL client
+// it must be enabled explicitly, and only works over TLS or a unix socket.
+func WithCleartextPassword() Option {
+	return func(c *Conn) error {
+		c.cleartextPassword = true
+		return nil
+	}
+}
+
+// authPluginAllowed returns whether the client supports the auth plugin.
+func (c *Conn) authPluginAllowed(pluginName string) bool {
+	if _, ok := c.authPlugins[pluginName]; ok {
+		return true
+	}
+	return authPluginAllowed(pluginName) || (pluginName == mysql.AUTH_CLEAR_PASSWORD && c.cleartextPassword)
+}
+
+// clearPasswordResponse returns the password for 'mysql_clear_password'.
+func (c *Conn) clearPasswordResponse() ([]byte, bool, error) {
+	if !c.cleartextPassword {
+		return nil, false, errors.Errorf("auth plugin '%s' is not enabled, see WithCleartextPassword", mysql.AUTH_CLEAR_PASSWORD)
+	}
+	if c.tlsConfig == nil && c.proto != "unix" {
+		return nil, false, errors.Errorf("auth plugin '%s' requires TLS or a unix socket", mysql.AUTH_CLEAR_PASSWORD)
+	}
+	return []byte(c.password), true, nil
+}
+
+// handlePluginAuthMoreData answers the AuthMoreData packets of the server with
+// the registered auth plugin, data is the first one.
+func (c *Conn) handlePluginAuthMoreData(p AuthPlugin, data []byte) error {
+	for data != nil {
+		resp, err := p.Next(c, data)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err = c.WriteAuthSwitchPacket(resp, false); err != nil {
+			return errors.Trace(err)
+		}
+		var switchToPlugin string
+		if data, switchToPlugin, err = c.readAuthResult(); err != nil {
+			return err
+		} else if switchToPlugin != "" {
+			return errors.Errorf("can not switch auth plugin more than once")
+		}
+	}
+	return nil
+}
+
+// KerberosTokenFunc returns the GSSAPI token for the service principal name spn
+// of the realm, to authenticate the user, and the next tokens for the tokens of
+// the server, serverToken is nil for the first one. It is usually implemented
+// with a Kerberos library, from a ticket cache or a keytab.
+type KerberosTokenFunc func(spn string, realm string, serverToken []byte) ([]byte, error)
+
+// WithKerberos enables the 'authentication_kerberos_client' authentication
+// method of MySQL Enterprise, getting the GSSAPI tokens from f.
+func WithKerberos(f KerberosTokenFunc) Option {
+	return WithAuthPlugin(mysql.AUTH_KERBEROS_CLIENT, &kerberosAuthPlugin{tokens: f})
+}
+
+type kerberosAuthPlugin struct {
+	tokens     KerberosTokenFunc
+	spn, realm string
+}
+
+// Start reads the service principal name and the realm sent by the server,
+// each one prefixed by its 2 bytes length.
+func (p *kerberosAuthPlugin) Start(_ *Conn, authData []byte) ([]byte, error) {
+	var fields [2]string
+	for i := range fields {
+		if len(authData) < 2 {
+			return nil, mysql.ErrMalformPacket
+		}
+		n := int(binary.LittleEndian.Uint16(authData))
+		if len(authData) < 2+n {
+			return nil, mysql.ErrMalformPacket
+		}
+		fields[i] = string(authData[2 : 2+n])
+		authData = authData[2+n:]
+	}
+	p.spn, p.realm = fields[0], fields[1]
+	return p.tokens(p.spn, p.realm, nil)
+}
+
+func (p *kerberosAuthPlugin) Next(_ *Conn, data []byte) ([]byte, error) {
+	return p.tokens(p.spn, p.realm, data)
+}
@@ -0,0 +1,59 @@
+package client_test
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+type tokenAuthPlugin struct {
+	challenges []string
+}
+
+func (p *tokenAuthPlugin) Start(_ *client.Conn, _ []byte) ([]byte, error) {
+	return []byte("token"), nil
+}
+
+func (p *tokenAuthPlugin) Next(_ *client.Conn, data []byte) ([]byte, error) {
+	p.challenges = append(p.challenges, string(data))
+	return append(data, "-response"...), nil
+}
+
+func TestAuthPlugin(t *testing.T) {
+	s := server.NewDefaultServer()
+	s.RegisterAuthPlugin("test_token", server.AuthPluginFunc(func(c *server.Conn, authData []byte) error {
+		if !bytes.Equal(authData, []byte("token")) {
+			return server.ErrAccessDenied
+		}
+		for _, challenge := range []string{"first", "second"} {
+			resp, err := c.AuthMoreData([]byte(challenge))
+			if err != nil {
+				return err
+			}
+			if string(resp) != challenge+"-response" {
+				return server.ErrAccessDenied
+			}
+		}
+		return nil
+	}))
+	require.NoError(t, s.SetDefaultAuthMethod("test_token"))
+
+	addr := serveConns(t, func(c net.Conn) (*server.Conn, error) {
+		return s.NewConn(c, "root", "", &server.EmptyHandler{})
+	})
+
+	_, err := client.Connect(addr, "root", "", "", "")
+	require.ErrorContains(t, err, "auth plugin 'test_token' is not supported")
+
+	p := &tokenAuthPlugin{}
+	conn, err := client.Connect(addr, "root", "", "", "", client.WithAuthPlugin("test_token", p))
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.Ping())
+	require.Equal(t, []string{"first", "second"}, p.challenges)
+}
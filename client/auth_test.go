@@ -67,8 +67,9 @@ func TestConnCollation(t *testing.T) {
 		if collation.ID <= 255 {
 			require.Equal(t, byte(collation.ID), handShakeResponse[12])
 		} else {
-			// if the collation ID is > 255 the collation ID should just be the lower-8 bits
-			require.Equal(t, byte(collation.ID&0xff), handShakeResponse[12])
+			// if the collation ID is > 255 the default collation of its charset is sent,
+			// the collation is set by SET NAMES after the handshake
+			require.Equal(t, mysql.DEFAULT_COLLATION_ID, handShakeResponse[12])
 		}
 
 		// the 13th byte should always be 0x00
@@ -110,3 +111,35 @@ func sendAuthResponse(t *testing.T, collation string) net.Conn {
 	}()
 	return server
 }
+
+func TestConnDefaultCollation(t *testing.T) {
+	testcases := []struct {
+		serverVersion string
+		charset       string
+		collation     string
+	}{
+		{"8.0.36", "utf8mb4", "utf8mb4_0900_ai_ci"},
+		{"5.7.44-log", "utf8mb4", "utf8mb4_general_ci"},
+		{"5.5.5-10.11.6-MariaDB", "utf8mb4", "utf8mb4_general_ci"},
+		{"8.0.36", "latin1", "latin1_swedish_ci"},
+		{"8.0.36", "UTF8", "utf8_general_ci"},
+		{"8.0.36", "binary", "binary"},
+	}
+	for _, tc := range testcases {
+		c := &Conn{serverVersion: tc.serverVersion, charset: tc.charset}
+		collation, handshake, err := c.handshakeCollation()
+		require.NoError(t, err)
+		require.Equal(t, tc.collation, collation.Name)
+		require.Equal(t, collation, handshake)
+	}
+
+	c := &Conn{serverVersion: "5.7.44", charset: "utf8mb4", collation: "utf8mb4_ja_0900_as_cs"}
+	collation, handshake, err := c.handshakeCollation()
+	require.NoError(t, err)
+	require.Equal(t, "utf8mb4_ja_0900_as_cs", collation.Name)
+	require.Equal(t, "utf8mb4_general_ci", handshake.Name)
+
+	c = &Conn{charset: "nope"}
+	_, _, err = c.handshakeCollation()
+	require.ErrorContains(t, err, "invalid charset nope")
+}
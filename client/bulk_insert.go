@@ -0,0 +1,301 @@
+package client
+
+import (
+	"encoding/hex"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// DuplicateKeyAction is what BulkInsert does with rows conflicting with
+// existing rows on a primary or unique key.
+type DuplicateKeyAction int
+
+const (
+	// DuplicateKeyError fails the statement (plain INSERT).
+	DuplicateKeyError DuplicateKeyAction = iota
+	// DuplicateKeyIgnore keeps the existing rows (INSERT IGNORE).
+	DuplicateKeyIgnore
+	// DuplicateKeyReplace deletes the existing rows first (REPLACE).
+	DuplicateKeyReplace
+	// DuplicateKeyUpdate updates the existing rows (INSERT ... ON DUPLICATE KEY UPDATE).
+	DuplicateKeyUpdate
+)
+
+type (
+	bulkInsertOptions struct {
+		duplicateKey  DuplicateKeyAction
+		updateColumns []string
+
+		maxRows int
+		maxSize int
+	}
+
+	BulkInsertOption func(o *bulkInsertOptions)
+)
+
+// WithDuplicateKeyAction sets what to do with rows conflicting with existing rows.
+// For DuplicateKeyUpdate, updateColumns are the columns to update, all the
+// inserted columns if empty.
+func WithDuplicateKeyAction(action DuplicateKeyAction, updateColumns ...string) BulkInsertOption {
+	return func(o *bulkInsertOptions) {
+		o.duplicateKey = action
+		o.updateColumns = updateColumns
+	}
+}
+
+// WithBatchRows sets the maximum number of rows of an INSERT statement, 0 means no limit.
+func WithBatchRows(rows int) BulkInsertOption {
+	return func(o *bulkInsertOptions) {
+		o.maxRows = rows
+	}
+}
+
+// WithMaxBatchSize sets the maximum size in bytes of an INSERT statement,
+// by default the max_allowed_packet of the server.
+func WithMaxBatchSize(size int) BulkInsertOption {
+	return func(o *bulkInsertOptions) {
+		o.maxSize = size
+	}
+}
+
+// BulkInsert inserts the rows received from rows until it is closed, batching
+// them into multi-row INSERT statements not larger than max_allowed_packet.
+// A row has a value for each of columns, see appendSQLValue for the supported types.
+// It returns the total number of affected rows.
+//
+// On error BulkInsert returns without reading rows anymore, unless the rows are
+// sent in a transaction the rows of the statements already executed are kept.
+func (c *Conn) BulkInsert(table string, columns []string, rows <-chan []interface{}, options ...BulkInsertOption) (uint64, error) {
+	var o bulkInsertOptions
+	for _, option := range options {
+		option(&o)
+	}
+
+	if o.maxSize <= 0 {
+		r, err := c.exec("SELECT @@max_allowed_packet")
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		maxPacket, err := r.GetInt(0, 0)
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		// the COM_QUERY command byte is part of the packet
+		o.maxSize = int(maxPacket) - 1
+	}
+
+	b := newBulkInserter(table, columns, &o, c.status&mysql.SERVER_STATUS_NO_BACKSLASH_ESCAPED > 0)
+	b.exec = c.exec
+
+	for row := range rows {
+		if err := b.add(row); err != nil {
+			return b.affectedRows, err
+		}
+	}
+	err := b.flush()
+	return b.affectedRows, err
+}
+
+type bulkInserter struct {
+	opts *bulkInsertOptions
+
+	columns            int
+	prefix             string
+	suffix             string
+	noBackslashEscapes bool
+
+	buf  []byte
+	row  []byte
+	rows int
+
+	affectedRows uint64
+
+	exec func(query string) (*mysql.Result, error)
+}
+
+func newBulkInserter(table string, columns []string, opts *bulkInsertOptions, noBackslashEscapes bool) *bulkInserter {
+	var prefix strings.Builder
+	switch opts.duplicateKey {
+	case DuplicateKeyIgnore:
+		prefix.WriteString("INSERT IGNORE INTO ")
+	case DuplicateKeyReplace:
+		prefix.WriteString("REPLACE INTO ")
+	default:
+		prefix.WriteString("INSERT INTO ")
+	}
+	prefix.WriteString(quoteTableName(table))
+	prefix.WriteString(" (")
+	for i, column := range columns {
+		if i > 0 {
+			prefix.WriteByte(',')
+		}
+		prefix.WriteString(mysql.QuoteIdentifier(column))
+	}
+	prefix.WriteString(") VALUES ")
+
+	var suffix strings.Builder
+	if opts.duplicateKey == DuplicateKeyUpdate {
+		updateColumns := opts.updateColumns
+		if len(updateColumns) == 0 {
+			updateColumns = columns
+		}
+		suffix.WriteString(" ON DUPLICATE KEY UPDATE ")
+		for i, column := range updateColumns {
+			if i > 0 {
+				suffix.WriteByte(',')
+			}
+			column = mysql.QuoteIdentifier(column)
+			suffix.WriteString(column)
+			suffix.WriteString("=VALUES(")
+			suffix.WriteString(column)
+			suffix.WriteByte(')')
+		}
+	}
+
+	return &bulkInserter{
+		opts:               opts,
+		columns:            len(columns),
+		prefix:             prefix.String(),
+		suffix:             suffix.String(),
+		noBackslashEscapes: noBackslashEscapes,
+	}
+}
+
+func (b *bulkInserter) add(row []interface{}) error {
+	if len(row) != b.columns {
+		return errors.Errorf("row has %d values, but %d columns", len(row), b.columns)
+	}
+
+	var err error
+	b.row = append(b.row[:0], '(')
+	for i, v := range row {
+		if i > 0 {
+			b.row = append(b.row, ',')
+		}
+		if b.row, err = appendSQLValue(b.row, v, b.noBackslashEscapes); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	b.row = append(b.row, ')')
+
+	if len(b.prefix)+len(b.row)+len(b.suffix) > b.opts.maxSize {
+		return errors.Errorf("row of %d bytes exceeds the max statement size %d", len(b.row), b.opts.maxSize)
+	}
+
+	if b.rows > 0 && (len(b.buf)+1+len(b.row)+len(b.suffix) > b.opts.maxSize ||
+		(b.opts.maxRows > 0 && b.rows >= b.opts.maxRows)) {
+		if err := b.flush(); err != nil {
+			return err
+		}
+	}
+
+	if b.rows == 0 {
+		b.buf = append(b.buf[:0], b.prefix...)
+	} else {
+		b.buf = append(b.buf, ',')
+	}
+	b.buf = append(b.buf, b.row...)
+	b.rows++
+	return nil
+}
+
+func (b *bulkInserter) flush() error {
+	if b.rows == 0 {
+		return nil
+	}
+
+	b.buf = append(b.buf, b.suffix...)
+	r, err := b.exec(string(b.buf))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	b.affectedRows += r.AffectedRows
+	b.rows = 0
+	return nil
+}
+
+// appendSQLValue appends v as a SQL literal. Supported types are nil, integers,
+// floats, bool, string, []byte and time.Time.
+func appendSQLValue(buf []byte, v interface{}, noBackslashEscapes bool) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, "NULL"...), nil
+	case int:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int8:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int16:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int32:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(buf, v, 10), nil
+	case uint:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint8:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint16:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint32:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint64:
+		return strconv.AppendUint(buf, v, 10), nil
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return nil, errors.Errorf("invalid float %v", v)
+		}
+		return strconv.AppendFloat(buf, float64(v), 'g', -1, 32), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, errors.Errorf("invalid float %v", v)
+		}
+		return strconv.AppendFloat(buf, v, 'g', -1, 64), nil
+	case bool:
+		if v {
+			return append(buf, '1'), nil
+		}
+		return append(buf, '0'), nil
+	case string:
+		return appendSQLString(buf, v, noBackslashEscapes), nil
+	case []byte:
+		if v == nil {
+			return append(buf, "NULL"...), nil
+		}
+		buf = append(buf, "X'"...)
+		buf = hex.AppendEncode(buf, v)
+		return append(buf, '\''), nil
+	case time.Time:
+		if v.IsZero() {
+			return append(buf, "'0000-00-00 00:00:00'"...), nil
+		}
+		buf = append(buf, '\'')
+		buf = v.AppendFormat(buf, "2006-01-02 15:04:05.999999")
+		return append(buf, '\''), nil
+	default:
+		return nil, errors.Errorf("unsupported value type %T", v)
+	}
+}
+
+func appendSQLString(buf []byte, s string, noBackslashEscapes bool) []byte {
+	buf = append(buf, '\'')
+	if noBackslashEscapes {
+		buf = append(buf, strings.ReplaceAll(s, "'", "''")...)
+	} else {
+		buf = append(buf, mysql.Escape(s)...)
+	}
+	return append(buf, '\'')
+}
+
+// quoteTableName quotes a table name, which may be qualified by the schema.
+func quoteTableName(table string) string {
+	if schema, name, ok := strings.Cut(table, "."); ok {
+		return mysql.QuoteIdentifier(schema) + "." + mysql.QuoteIdentifier(name)
+	}
+	return mysql.QuoteIdentifier(table)
+}
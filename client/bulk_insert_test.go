@@ -0,0 +1,85 @@
+package client
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+func TestAppendSQLValue(t *testing.T) {
+	testcases := []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, "NULL"},
+		{-12, "-12"},
+		{uint64(18446744073709551615), "18446744073709551615"},
+		{1.5, "1.5"},
+		{true, "1"},
+		{"it's\n", `'it\'s\n'`},
+		{[]byte{0x00, 0xff}, "X'00ff'"},
+		{time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.UTC), "'2024-01-02 03:04:05.6'"},
+	}
+	for _, tc := range testcases {
+		b, err := appendSQLValue(nil, tc.value, false)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, string(b))
+	}
+
+	b, err := appendSQLValue(nil, "it's", true)
+	require.NoError(t, err)
+	require.Equal(t, "'it''s'", string(b))
+
+	_, err = appendSQLValue(nil, struct{}{}, false)
+	require.Error(t, err)
+	for _, v := range []interface{}{math.NaN(), math.Inf(-1), float32(math.Inf(1))} {
+		_, err = appendSQLValue(nil, v, false)
+		require.Error(t, err)
+	}
+}
+
+func TestBulkInserter(t *testing.T) {
+	var queries []string
+	exec := func(query string) (*mysql.Result, error) {
+		queries = append(queries, query)
+		return &mysql.Result{AffectedRows: 2}, nil
+	}
+
+	opts := &bulkInsertOptions{maxSize: 100}
+	WithDuplicateKeyAction(DuplicateKeyUpdate, "name")(opts)
+	b := newBulkInserter("db.t", []string{"id", "name"}, opts, false)
+	b.exec = exec
+
+	for _, row := range [][]interface{}{{1, "a"}, {2, "b"}, {3, "c"}} {
+		require.NoError(t, b.add(row))
+	}
+	require.NoError(t, b.flush())
+	require.Equal(t, []string{
+		"INSERT INTO `db`.`t` (`id`,`name`) VALUES (1,'a') ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)",
+		"INSERT INTO `db`.`t` (`id`,`name`) VALUES (2,'b') ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)",
+		"INSERT INTO `db`.`t` (`id`,`name`) VALUES (3,'c') ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)",
+	}, queries)
+	require.EqualValues(t, 6, b.affectedRows)
+
+	queries = nil
+	opts = &bulkInsertOptions{maxSize: 1024, maxRows: 2}
+	WithDuplicateKeyAction(DuplicateKeyIgnore)(opts)
+	b = newBulkInserter("t", []string{"id"}, opts, false)
+	b.exec = exec
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, b.add([]interface{}{i}))
+	}
+	require.NoError(t, b.flush())
+	require.Equal(t, []string{
+		"INSERT IGNORE INTO `t` (`id`) VALUES (1),(2)",
+		"INSERT IGNORE INTO `t` (`id`) VALUES (3)",
+	}, queries)
+
+	b = newBulkInserter("t", []string{"id"}, &bulkInsertOptions{maxSize: 30}, false)
+	require.ErrorContains(t, b.add([]interface{}{"a very long value exceeding the limit"}), "exceeds the max statement size")
+	require.ErrorContains(t, b.add([]interface{}{1, 2}), "row has 2 values")
+}
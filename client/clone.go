@@ -0,0 +1,317 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+/*
+CloneReplica provisions a replica with the clone plugin of MySQL 8.0.17+: the
+recipient copies the data of the donor with CLONE INSTANCE, restarts, and then
+replicates from the source with GTID auto positioning.
+
+Usage:
+	err := client.CloneReplica(ctx, client.CloneConfig{
+		Connect: func(ctx context.Context) (*client.Conn, error) {
+			return client.ConnectWithContext(ctx, `10.0.0.3:3306`, `root`, `rootpwd`, ``, ``, 10*time.Second)
+		},
+		Donor:               `10.0.0.1:3306`,
+		User:                `clone`,
+		Password:            `clonepwd`,
+		ReplicationUser:     `repl`,
+		ReplicationPassword: `replpwd`,
+	})
+*/
+
+// errRestartServerFailed is ER_RESTART_SERVER_FAILED, returned by CLONE INSTANCE
+// when the data is cloned but the server can't restart itself, it isn't
+// managed by a supervisor like systemd.
+const errRestartServerFailed = 3707
+
+type (
+	CloneConfig struct {
+		// Connect connects to the recipient, the server to provision, as a user
+		// with CLONE_ADMIN. It is called again after the restart of the recipient,
+		// and for OnProgress.
+		Connect func(ctx context.Context) (*Conn, error)
+
+		// Donor is the address host:port of the server to clone, User and
+		// Password of its user with BACKUP_ADMIN. RequireSSL clones over an
+		// encrypted connection.
+		Donor      string
+		User       string
+		Password   string
+		RequireSSL bool
+
+		// Source is the address of the server to replicate from, the donor if
+		// empty. The replication isn't configured if ReplicationUser is empty.
+		Source              string
+		ReplicationUser     string
+		ReplicationPassword string
+
+		// OnProgress is called every ProgressInterval, 1s by default, with the
+		// stages of the clone, see CloneProgress.
+		OnProgress       func(stages []CloneStage)
+		ProgressInterval time.Duration
+
+		// RestartTimeout is how long to wait for the recipient to restart after
+		// the clone, 5 minutes by default.
+		RestartTimeout time.Duration
+	}
+
+	// CloneStage is a row of performance_schema.clone_progress.
+	CloneStage struct {
+		// Stage is like DROP DATA, FILE COPY or RESTART, and State Not Started,
+		// In Progress or Completed.
+		Stage string
+		State string
+		// BeginTime and EndTime are the timestamps of the server, empty if not
+		// set.
+		BeginTime string
+		EndTime   string
+		Threads   uint64
+		// Estimate and Data are the bytes estimated and cloned, Network the bytes
+		// received.
+		Estimate uint64
+		Data     uint64
+		Network  uint64
+	}
+)
+
+// CloneReplica clones the donor of cfg into the recipient and configures the
+// replication. It installs the clone plugin on the recipient if needed, the
+// donor must have it. The data of the recipient is replaced.
+func CloneReplica(ctx context.Context, cfg CloneConfig) error {
+	if cfg.ProgressInterval <= 0 {
+		cfg.ProgressInterval = time.Second
+	}
+	if cfg.RestartTimeout <= 0 {
+		cfg.RestartTimeout = 5 * time.Minute
+	}
+	if cfg.Source == "" {
+		cfg.Source = cfg.Donor
+	}
+	host, port, err := splitHostPort(cfg.Donor)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	conn, err := cfg.Connect(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer conn.Close()
+
+	if err = installClonePlugin(conn); err != nil {
+		return errors.Trace(err)
+	}
+	if _, err = conn.Execute(fmt.Sprintf("SET GLOBAL clone_valid_donor_list = '%s'", mysql.Escape(cfg.Donor))); err != nil {
+		return errors.Trace(err)
+	}
+
+	var monitor *Conn
+	if cfg.OnProgress != nil {
+		if monitor, err = cfg.Connect(ctx); err != nil {
+			return errors.Trace(err)
+		}
+		defer monitor.Close()
+	}
+
+	query := fmt.Sprintf("CLONE INSTANCE FROM '%s'@'%s':%d IDENTIFIED BY '%s'",
+		mysql.Escape(cfg.User), mysql.Escape(host), port, mysql.Escape(cfg.Password))
+	if cfg.RequireSSL {
+		query += " REQUIRE SSL"
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.Execute(query)
+		done <- err
+	}()
+
+	ticker := time.NewTicker(cfg.ProgressInterval)
+	defer ticker.Stop()
+wait:
+	for {
+		select {
+		case err = <-done:
+			break wait
+		case <-ticker.C:
+			if monitor != nil {
+				if stages, err := CloneProgress(monitor); err == nil {
+					cfg.OnProgress(stages)
+				}
+			}
+		case <-ctx.Done():
+			// the clone goes on in the server
+			conn.Close()
+			return errors.Trace(ctx.Err())
+		}
+	}
+	// the recipient restarts after the data is cloned, which closes the
+	// connection, the status tells whether the clone succeeded
+	if code, ok := mysql.MyErrorCode(err); ok && code != errRestartServerFailed {
+		return errors.Annotate(err, "clone instance")
+	}
+
+	replica, err := waitCloneCompleted(ctx, cfg)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer replica.Close()
+
+	if cfg.ReplicationUser == "" {
+		return nil
+	}
+	return errors.Trace(startCloneReplication(replica, cfg))
+}
+
+// CloneProgress returns the stages of the last clone of the server of conn,
+// from performance_schema.clone_progress.
+func CloneProgress(conn *Conn) ([]CloneStage, error) {
+	r, err := conn.Execute(`SELECT STAGE, STATE, BEGIN_TIME, END_TIME, THREADS, ESTIMATE, DATA, NETWORK FROM performance_schema.clone_progress ORDER BY ID`)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer r.Close()
+
+	stages := make([]CloneStage, r.RowNumber())
+	for i := range stages {
+		s := &stages[i]
+		s.Stage, _ = r.GetString(i, 0)
+		s.State, _ = r.GetString(i, 1)
+		s.BeginTime, _ = r.GetString(i, 2)
+		s.EndTime, _ = r.GetString(i, 3)
+		s.Threads, _ = r.GetUint(i, 4)
+		s.Estimate, _ = r.GetUint(i, 5)
+		s.Data, _ = r.GetUint(i, 6)
+		s.Network, _ = r.GetUint(i, 7)
+	}
+	return stages, nil
+}
+
+// installClonePlugin installs the clone plugin if it isn't active.
+func installClonePlugin(conn *Conn) error {
+	r, err := conn.Execute(`SELECT PLUGIN_STATUS FROM information_schema.PLUGINS WHERE PLUGIN_NAME = 'clone'`)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer r.Close()
+	if r.RowNumber() > 0 {
+		if status, _ := r.GetString(0, 0); status == "ACTIVE" {
+			return nil
+		}
+	}
+	_, err = conn.Execute(`INSTALL PLUGIN clone SONAME 'mysql_clone.so'`)
+	return errors.Annotate(err, "install clone plugin")
+}
+
+// waitCloneCompleted reconnects to the recipient until it has restarted and
+// performance_schema.clone_status has the clone completed, and returns the
+// connection.
+func waitCloneCompleted(ctx context.Context, cfg CloneConfig) (*Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.RestartTimeout)
+	defer cancel()
+
+	var lastErr error
+	for {
+		conn, err := cfg.Connect(ctx)
+		if err == nil {
+			var completed bool
+			if completed, err = cloneCompleted(conn); completed {
+				return conn, nil
+			}
+			conn.Close()
+			if _, ok := err.(*cloneFailedError); ok {
+				return nil, err
+			}
+		}
+		lastErr = err
+
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			if lastErr == nil {
+				lastErr = ctx.Err()
+			}
+			return nil, errors.Annotate(lastErr, "wait for the recipient to restart after the clone")
+		}
+	}
+}
+
+type cloneFailedError struct {
+	code    int64
+	message string
+}
+
+func (e *cloneFailedError) Error() string {
+	return fmt.Sprintf("clone failed: %d %s", e.code, e.message)
+}
+
+// cloneCompleted returns whether the last clone of the server of conn is
+// completed, and a *cloneFailedError if it failed.
+func cloneCompleted(conn *Conn) (bool, error) {
+	r, err := conn.Execute(`SELECT STATE, ERROR_NO, ERROR_MESSAGE FROM performance_schema.clone_status`)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	defer r.Close()
+	if r.RowNumber() == 0 {
+		return false, errors.New("no clone status")
+	}
+
+	state, _ := r.GetString(0, 0)
+	switch state {
+	case "Completed":
+		return true, nil
+	case "Failed":
+		code, _ := r.GetInt(0, 1)
+		message, _ := r.GetString(0, 2)
+		return false, &cloneFailedError{code: code, message: message}
+	}
+	return false, errors.Errorf("clone is %s", strings.ToLower(state))
+}
+
+// startCloneReplication configures the replication from the source of cfg,
+// with GTID auto positioning as the clone has the GTIDs of the donor, and
+// starts it.
+func startCloneReplication(conn *Conn, cfg CloneConfig) error {
+	host, port, err := splitHostPort(cfg.Source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	change, start, prefix := "CHANGE REPLICATION SOURCE TO", "START REPLICA", "SOURCE"
+	if c, err := conn.CompareServerVersion("8.0.23"); err == nil && c < 0 {
+		change, start, prefix = "CHANGE MASTER TO", "START SLAVE", "MASTER"
+	}
+	query := fmt.Sprintf("%[1]s %[2]s_HOST = '%[3]s', %[2]s_PORT = %[4]d, %[2]s_USER = '%[5]s', %[2]s_PASSWORD = '%[6]s', %[2]s_AUTO_POSITION = 1",
+		change, prefix, mysql.Escape(host), port, mysql.Escape(cfg.ReplicationUser), mysql.Escape(cfg.ReplicationPassword))
+	if cfg.RequireSSL {
+		query += fmt.Sprintf(", %s_SSL = 1", prefix)
+	}
+	if _, err = conn.Execute(query); err != nil {
+		return errors.Annotate(err, "configure replication")
+	}
+	_, err = conn.Execute(start)
+	return errors.Annotate(err, "start replication")
+}
+
+func splitHostPort(addr string) (string, int, error) {
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, errors.Trace(err)
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return "", 0, errors.Errorf("invalid port of %s", addr)
+	}
+	return host, port, nil
+}
@@ -0,0 +1,93 @@
+package client_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+type cloneHandler struct {
+	server.EmptyHandler
+	mu      sync.Mutex
+	queries []string
+}
+
+func (h *cloneHandler) HandleQuery(query string) (*mysql.Result, error) {
+	h.mu.Lock()
+	h.queries = append(h.queries, query)
+	h.mu.Unlock()
+
+	var names []string
+	var rows [][]interface{}
+	switch {
+	case strings.Contains(query, "information_schema.PLUGINS"):
+		names = []string{"PLUGIN_STATUS"}
+	case strings.HasPrefix(query, "CLONE INSTANCE"):
+		time.Sleep(50 * time.Millisecond)
+		return nil, mysql.NewError(3707, "Restart server failed (mysqld is not managed by supervisor process).")
+	case strings.Contains(query, "clone_progress"):
+		names = []string{"STAGE", "STATE", "BEGIN_TIME", "END_TIME", "THREADS", "ESTIMATE", "DATA", "NETWORK"}
+		rows = [][]interface{}{{"FILE COPY", "In Progress", "2026-01-01 00:00:00.000", nil, 4, 1000, 500, 510}}
+	case strings.Contains(query, "clone_status"):
+		names = []string{"STATE", "ERROR_NO", "ERROR_MESSAGE"}
+		rows = [][]interface{}{{"Completed", 0, ""}}
+	default:
+		return nil, nil
+	}
+	r, err := mysql.BuildSimpleTextResultset(names, rows)
+	if err != nil {
+		return nil, err
+	}
+	return mysql.NewResult(r), nil
+}
+
+func (h *cloneHandler) statements() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var statements []string
+	for _, query := range h.queries {
+		if !strings.HasPrefix(query, "SELECT") {
+			statements = append(statements, query)
+		}
+	}
+	return statements
+}
+
+func TestCloneReplica(t *testing.T) {
+	h := &cloneHandler{}
+	addr := serve(t, h)
+
+	var progress []client.CloneStage
+	err := client.CloneReplica(context.Background(), client.CloneConfig{
+		Connect: func(ctx context.Context) (*client.Conn, error) {
+			return client.ConnectWithContext(ctx, addr, "root", "", "", "", time.Second)
+		},
+		Donor:               "10.0.0.1:3306",
+		User:                "clone",
+		Password:            "it's",
+		ReplicationUser:     "repl",
+		ReplicationPassword: "replpwd",
+		OnProgress:          func(stages []client.CloneStage) { progress = stages },
+		ProgressInterval:    10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []client.CloneStage{{
+		Stage: "FILE COPY", State: "In Progress", BeginTime: "2026-01-01 00:00:00.000",
+		Threads: 4, Estimate: 1000, Data: 500, Network: 510,
+	}}, progress)
+	require.Equal(t, []string{
+		"INSTALL PLUGIN clone SONAME 'mysql_clone.so'",
+		"SET GLOBAL clone_valid_donor_list = '10.0.0.1:3306'",
+		`CLONE INSTANCE FROM 'clone'@'10.0.0.1':3306 IDENTIFIED BY 'it\'s'`,
+		"CHANGE MASTER TO MASTER_HOST = '10.0.0.1', MASTER_PORT = 3306, MASTER_USER = 'repl', MASTER_PASSWORD = 'replpwd', MASTER_AUTO_POSITION = 1",
+		"START SLAVE",
+	}, h.statements())
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/pkg/parser/charset"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// defaultCollation returns the default collation of cs on the server,
+// utf8mb4_0900_ai_ci for utf8mb4 only exists since MySQL 8.0.
+func (c *Conn) defaultCollation(cs string) (*charset.Collation, error) {
+	if len(cs) == 0 {
+		cs = mysql.DEFAULT_CHARSET
+	}
+	name, ok := mysql.CharsetDefaultCollation(cs)
+	if !ok {
+		var err error
+		if name, err = charset.GetDefaultCollation(strings.ToLower(cs)); err != nil {
+			return nil, errors.Errorf("invalid charset %s", cs)
+		}
+	}
+
+	if name == mysql.DEFAULT_COLLATION_NAME && !c.supportsUCA0900() {
+		name = "utf8mb4_general_ci"
+	}
+	return charset.GetCollationByName(name)
+}
+
+func (c *Conn) supportsUCA0900() bool {
+	if strings.Contains(strings.ToLower(c.serverVersion), "mariadb") {
+		return false
+	}
+	cmp, err := c.CompareServerVersion("8.0.0")
+	return err != nil || cmp >= 0
+}
+
+// handshakeCollation returns the collation of the connection, the collation set
+// by SetCollation or the default collation of its charset, and the collation to
+// send in the handshake response. The handshake response only has 1 byte for the
+// collation ID, so for a collation with a larger ID it is the default collation of
+// the charset, the collation is set by SET NAMES after the handshake.
+func (c *Conn) handshakeCollation() (collation *charset.Collation, handshake *charset.Collation, err error) {
+	if len(c.collation) != 0 {
+		if collation, err = charset.GetCollationByName(c.collation); err != nil {
+			return nil, nil, errors.Errorf("invalid collation name %s", c.collation)
+		}
+	} else if collation, err = c.defaultCollation(c.charset); err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	if collation.ID <= 255 {
+		return collation, collation, nil
+	}
+	if handshake, err = c.defaultCollation(collation.CharsetName); err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	if handshake.ID > 255 {
+		// MariaDB 11 has defaults > 255
+		handshake, err = charset.GetCollationByName(mysql.DEFAULT_COLLATION_NAME)
+	}
+	return collation, handshake, errors.Trace(err)
+}
+
+// WithCollation sets the collation of the connection, see SetCollation.
+// pass to options when connect
+func WithCollation(collation string) Option {
+	return func(c *Conn) error {
+		return c.SetCollation(collation)
+	}
+}
+
+// WithCharsetConversion converts the string values of the resultsets of the
+// character columns in other character sets than UTF-8, like latin1 or gbk, to
+// UTF-8, by the collation of their fields, see mysql.ToUTF8. The values are in
+// the character set of character_set_results, so it is for a connection with a
+// non-UTF-8 charset, or character_set_results set to NULL to get the values in
+// the character set of the columns. Result.RowDatas are not converted.
+func WithCharsetConversion() Option {
+	return func(c *Conn) error {
+		c.convertCharset = true
+		return nil
+	}
+}
@@ -2,7 +2,9 @@ package client
 
 import (
 	"bytes"
+	"container/list"
 	"context"
+	"crypto/rsa"
 	"crypto/tls"
 	"fmt"
 	"math/bits"
@@ -32,10 +34,30 @@ type Conn struct {
 	db        string
 	tlsConfig *tls.Config
 	proto     string
+	addr      string
+
+	// pinned RSA public key of the server, see WithServerPublicKey, and whether
+	// the authentication used the cached key
+	serverPubKey     *rsa.PublicKey
+	usedCachedPubKey bool
+
+	// shared TLS session cache, see WithTLSSessionCache
+	tlsSessionCache tls.ClientSessionCache
+
+	// PROXY protocol addresses, see WithProxyProtocolHeader
+	proxySrc *net.TCPAddr
+	proxyDst *net.TCPAddr
 
 	// Connection read and write timeouts to set on the connection
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
+	// timeouts of the handshake and of ExecuteContext, see WithTimeouts
+	connectTimeout time.Duration
+	execTimeout    time.Duration
+	// see WithMaxExecutionTimeHint
+	maxExecutionTimeHint bool
+	// see WithResultLimits
+	resultLimits ResultLimits
 
 	// The buffer size to use in the packet connection
 	BufferSize int
@@ -56,9 +78,15 @@ type Conn struct {
 	charset string
 	// sets the collation to be set on the auth handshake, this does not issue a 'set names' command
 	collation string
+	// convert the values of the columns in other charsets to UTF-8, see WithCharsetConversion
+	convertCharset bool
 
 	salt           []byte
 	authPluginName string
+	// auth plugins added by WithAuthPlugin, and whether mysql_clear_password is
+	// enabled, see WithCleartextPassword
+	authPlugins       map[string]AuthPlugin
+	cleartextPassword bool
 
 	connectionID uint32
 
@@ -66,6 +94,28 @@ type Conn struct {
 
 	// Include the file + line as query attribute. The number set which frame in the stack should be used.
 	includeLine int
+
+	// warnings of the last statement, see WithWarnings and WithStrictWarnings
+	fetchWarnings  bool
+	strictWarnings bool
+	warnings       []Warning
+
+	// variables set by the SET statements, see WithSessionTracking
+	trackSession bool
+	sessionVars  []string
+
+	// hooks of the commands, see WithQueryHook
+	queryHooks []QueryHook
+
+	// set by WithReconnect
+	reconnect *reconnectState
+
+	// prepared statements open, by last use, see WithMaxOpenStmts
+	stmts    *list.List
+	maxStmts int
+
+	// see WithResultPool
+	pooledResults bool
 }
 
 // This function will be called for every row in resultset from ExecuteSelectStreaming.
@@ -105,8 +155,18 @@ func ConnectWithContext(ctx context.Context, addr, user, password, dbName, chars
 // Dialer connects to the address on the named network using the provided context.
 type Dialer func(ctx context.Context, network, address string) (net.Conn, error)
 
-// ConnectWithDialer to a MySQL server using the given Dialer.
+// ConnectWithDialer to a MySQL server using the given Dialer. addr can be a
+// comma separated list of addresses, like 10.0.0.1:3306,10.0.0.2:3306, to fail
+// over to the next one if the connection fails, see Failover.
 func ConnectWithDialer(ctx context.Context, network, addr, user, password, dbName, charset string, dialer Dialer, options ...Option) (*Conn, error) {
+	if addrs := splitAddrs(addr); len(addrs) > 1 {
+		conn, _, err := connectAny(ctx, network, addrs, user, password, dbName, charset, dialer, options...)
+		return conn, err
+	}
+	return connectAddr(ctx, network, addr, user, password, dbName, charset, dialer, options...)
+}
+
+func connectAddr(ctx context.Context, network, addr, user, password, dbName, charset string, dialer Dialer, options ...Option) (*Conn, error) {
 	c := new(Conn)
 
 	c.includeLine = -1
@@ -140,6 +200,7 @@ func ConnectWithDialer(ctx context.Context, network, addr, user, password, dbNam
 	c.password = password
 	c.db = dbName
 	c.proto = network
+	c.addr = addr
 	if len(charset) != 0 {
 		c.charset = charset
 	} else {
@@ -156,6 +217,17 @@ func ConnectWithDialer(ctx context.Context, network, addr, user, password, dbNam
 		}
 	}
 
+	if c.reconnect != nil {
+		c.reconnect.network, c.reconnect.dialer, c.reconnect.options = network, dialer, options
+	}
+
+	if c.proxySrc != nil {
+		if err := c.writeProxyHeader(conn); err != nil {
+			_ = conn.Close()
+			return nil, errors.Trace(err)
+		}
+	}
+
 	c.Conn = packet.NewConnWithTimeout(conn, c.ReadTimeout, c.WriteTimeout, c.BufferSize)
 	if c.tlsConfig != nil {
 		seq := c.Sequence
@@ -163,7 +235,18 @@ func ConnectWithDialer(ctx context.Context, network, addr, user, password, dbNam
 		c.Sequence = seq
 	}
 
-	if err = c.handshake(); err != nil {
+	var connectTimer *time.Timer
+	if c.connectTimeout > 0 {
+		connectTimer = time.AfterFunc(c.connectTimeout, func() { _ = conn.Close() })
+	}
+	err = c.handshake()
+	if connectTimer != nil && !connectTimer.Stop() {
+		if err == nil {
+			c.Close()
+		}
+		return nil, errors.Annotatef(context.DeadlineExceeded, "connect timeout %s", c.connectTimeout)
+	}
+	if err != nil {
 		// in the event of an error c.handshake() will close the connection
 		return nil, errors.Trace(err)
 	}
@@ -184,7 +267,7 @@ func ConnectWithDialer(ctx context.Context, network, addr, user, password, dbNam
 		}
 
 		if collation.ID > 255 {
-			if _, err := c.exec(fmt.Sprintf("SET NAMES %s COLLATE %s", c.charset, c.collation)); err != nil {
+			if _, err := c.exec(fmt.Sprintf("SET NAMES %s COLLATE %s", collation.CharsetName, collation.Name)); err != nil {
 				c.Close()
 				return nil, errors.Trace(err)
 			}
@@ -217,6 +300,8 @@ func (c *Conn) handshake() error {
 
 // Close directly closes the connection. Use Quit() to first send COM_QUIT to the server and then close the connection.
 func (c *Conn) Close() error {
+	// best effort, the connection may be broken
+	_ = c.CloseStmts()
 	return c.Conn.Close()
 }
 
@@ -305,8 +390,22 @@ func (c *Conn) CompareServerVersion(v string) (int, error) {
 }
 
 func (c *Conn) Execute(command string, args ...interface{}) (*mysql.Result, error) {
+	if c.reconnect != nil {
+		return c.executeReconnecting(false, command, args...)
+	}
+	return c.execute(command, args...)
+}
+
+func (c *Conn) execute(command string, args ...interface{}) (*mysql.Result, error) {
 	if len(args) == 0 {
-		return c.exec(command)
+		r, err := c.exec(command)
+		if err != nil {
+			return nil, err
+		}
+		if c.trackSession {
+			c.recordSessionVars(command)
+		}
+		return c.checkWarnings(r)
 	} else {
 		if s, err := c.Prepare(command); err != nil {
 			return nil, errors.Trace(err)
@@ -326,12 +425,17 @@ func (c *Conn) Execute(command string, args ...interface{}) (*mysql.Result, erro
 // flag set to signal the server multiple queries are executed. Handling the responses
 // is up to the implementation of perResultCallback.
 func (c *Conn) ExecuteMultiple(query string, perResultCallback ExecPerResultCallback) (*mysql.Result, error) {
-	if err := c.execSend(query); err != nil {
-		return nil, errors.Trace(err)
-	}
-
 	var err error
 	var result *mysql.Result
+	if len(c.queryHooks) > 0 {
+		e := c.beforeQuery(mysql.COM_QUERY, query, nil)
+		query = e.Query
+		defer func() { c.afterQuery(e, result, err) }()
+	}
+
+	if err = c.execSend(query); err != nil {
+		return nil, errors.Trace(err)
+	}
 
 	bs := utils.ByteSliceGet(16)
 	defer utils.ByteSlicePut(bs)
@@ -378,7 +482,14 @@ func (c *Conn) ExecuteMultiple(query string, perResultCallback ExecPerResultCall
 // When given, perResultCallback will be called once per result
 //
 // ExecuteSelectStreaming should be used only for SELECT queries with a large response resultset for memory preserving.
-func (c *Conn) ExecuteSelectStreaming(command string, result *mysql.Result, perRowCallback SelectPerRowCallback, perResultCallback SelectPerResultCallback) error {
+func (c *Conn) ExecuteSelectStreaming(command string, result *mysql.Result, perRowCallback SelectPerRowCallback, perResultCallback SelectPerResultCallback) (err error) {
+	if len(c.queryHooks) > 0 {
+		e := c.beforeQuery(mysql.COM_QUERY, command, nil)
+		command = e.Query
+		perRowCallback = countRows(e, perRowCallback)
+		defer func() { c.afterQuery(e, result, err) }()
+	}
+
 	if err := c.execSend(command); err != nil {
 		return errors.Trace(err)
 	}
@@ -521,8 +632,14 @@ func (c *Conn) ReadOKPacket() (*mysql.Result, error) {
 }
 
 // Send COM_QUERY and read the result
-func (c *Conn) exec(query string) (*mysql.Result, error) {
-	err := c.execSend(query)
+func (c *Conn) exec(query string) (r *mysql.Result, err error) {
+	if len(c.queryHooks) > 0 {
+		e := c.beforeQuery(mysql.COM_QUERY, query, nil)
+		query = e.Query
+		defer func() { c.afterQuery(e, r, err) }()
+	}
+
+	err = c.execSend(query)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -532,37 +649,42 @@ func (c *Conn) exec(query string) (*mysql.Result, error) {
 // Sends COM_QUERY
 // https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_query.html
 func (c *Conn) execSend(query string) error {
-	var buf bytes.Buffer
-	defer clear(c.queryAttributes)
-
-	if c.capability&mysql.CLIENT_QUERY_ATTRIBUTES > 0 {
-		if c.includeLine >= 0 {
-			_, file, line, ok := runtime.Caller(c.includeLine)
-			if ok {
-				lineAttr := mysql.QueryAttribute{
-					Name:  "_line",
-					Value: fmt.Sprintf("%s:%d", file, line),
-				}
-				c.queryAttributes = append(c.queryAttributes, lineAttr)
+	// the attributes are sent along with the next query only
+	defer func() { c.queryAttributes = nil }()
+
+	if c.capability&mysql.CLIENT_QUERY_ATTRIBUTES == 0 {
+		return errors.Trace(c.writeCommandStr(mysql.COM_QUERY, query))
+	}
+
+	buf := utils.BytesBufferGet()
+	defer utils.BytesBufferPut(buf)
+
+	if c.includeLine >= 0 {
+		_, file, line, ok := runtime.Caller(c.includeLine)
+		if ok {
+			lineAttr := mysql.QueryAttribute{
+				Name:  "_line",
+				Value: fmt.Sprintf("%s:%d", file, line),
 			}
+			c.queryAttributes = append(c.queryAttributes, lineAttr)
 		}
+	}
 
-		numParams := len(c.queryAttributes)
-		buf.Write(mysql.PutLengthEncodedInt(uint64(numParams)))
-		buf.WriteByte(0x1) // parameter_set_count, unused
-		if numParams > 0 {
-			// null_bitmap, length: (num_params+7)/8
-			for i := 0; i < (numParams+7)/8; i++ {
-				buf.WriteByte(0x0)
-			}
-			buf.WriteByte(0x1) // new_params_bind_flag, unused
-			for _, qa := range c.queryAttributes {
-				buf.Write(qa.TypeAndFlag())
-				buf.Write(mysql.PutLengthEncodedString([]byte(qa.Name)))
-			}
-			for _, qa := range c.queryAttributes {
-				buf.Write(qa.ValueBytes())
-			}
+	numParams := len(c.queryAttributes)
+	buf.Write(mysql.PutLengthEncodedInt(uint64(numParams)))
+	buf.WriteByte(0x1) // parameter_set_count, unused
+	if numParams > 0 {
+		// null_bitmap, length: (num_params+7)/8
+		for i := 0; i < (numParams+7)/8; i++ {
+			buf.WriteByte(0x0)
+		}
+		buf.WriteByte(0x1) // new_params_bind_flag, unused
+		for _, qa := range c.queryAttributes {
+			buf.Write(qa.TypeAndFlag())
+			buf.Write(mysql.PutLengthEncodedString([]byte(qa.Name)))
+		}
+		for _, qa := range c.queryAttributes {
+			buf.Write(qa.ValueBytes())
 		}
 	}
 
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/packet"
+)
+
+func TestWatchContext(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	c := &Conn{Conn: packet.NewConn(clientConn)}
+
+	// the connection is kept once the watch ended, whatever ctx
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := c.watchContext(ctx)
+	require.False(t, stop())
+	cancel()
+	go func() { _, _ = serverConn.Write([]byte{1}) }()
+	_, err := clientConn.Read(make([]byte, 1))
+	require.NoError(t, err)
+
+	// the read waiting for the server is interrupted
+	ctx, cancel = context.WithCancel(context.Background())
+	stop = c.watchContext(ctx)
+	cancel()
+	_, err = clientConn.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.ErrClosedPipe)
+	require.True(t, stop())
+
+	require.False(t, c.watchContext(context.Background())())
+}
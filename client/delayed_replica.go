@@ -0,0 +1,272 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+/*
+A delayed replica applies the events of its source SOURCE_DELAY after they were
+committed, to recover from a bad transaction, like a DROP TABLE, within the
+delay: the SQL thread is stopped and then rolled forward to just before it.
+
+Usage:
+	conn.SetReplicaDelay(time.Hour)
+	...
+	conn.StopReplicaSQLThread()
+	// apply the relay log until before the bad transaction
+	conn.RollForwardToGTIDSet(ctx, gset, client.GTIDWaitOptions{})
+	// or until the time of the last good one
+	conn.RollForwardToTime(ctx, badTime.Add(-time.Second), 0)
+*/
+
+// ReplicaSQLStatus is the status of the SQL thread of a replica, from SHOW
+// REPLICA STATUS.
+type ReplicaSQLStatus struct {
+	Running bool
+	// Delay is SQL_Delay, the SOURCE_DELAY of the replica.
+	Delay time.Duration
+	// Waiting is set if the SQL thread waits for the delay of the next event
+	// to elapse, it is applied in RemainingDelay.
+	Waiting        bool
+	RemainingDelay time.Duration
+}
+
+// replicaSyntax returns the keywords of the replication statements, REPLICA
+// and SOURCE, or SLAVE and MASTER for MySQL < 8.0.23 and MariaDB.
+func (c *Conn) replicaSyntax() (replica string, source string) {
+	if strings.Contains(strings.ToLower(c.serverVersion), "mariadb") {
+		return "SLAVE", "MASTER"
+	}
+	if cmp, err := c.CompareServerVersion("8.0.23"); err == nil && cmp < 0 {
+		return "SLAVE", "MASTER"
+	}
+	return "REPLICA", "SOURCE"
+}
+
+// StopReplicaSQLThread stops the SQL thread of the replica, which applies the
+// relay log, the I/O thread keeps fetching the events of the source.
+func (c *Conn) StopReplicaSQLThread() error {
+	replica, _ := c.replicaSyntax()
+	_, err := c.Execute("STOP " + replica + " SQL_THREAD")
+	return errors.Trace(err)
+}
+
+// StartReplicaSQLThread starts the SQL thread of the replica.
+func (c *Conn) StartReplicaSQLThread() error {
+	replica, _ := c.replicaSyntax()
+	_, err := c.Execute("START " + replica + " SQL_THREAD")
+	return errors.Trace(err)
+}
+
+// SetReplicaDelay sets the SOURCE_DELAY of the replica, to the second, and
+// starts the SQL thread, stopped first as CHANGE REPLICATION SOURCE requires.
+func (c *Conn) SetReplicaDelay(delay time.Duration) error {
+	if delay < 0 {
+		return errors.Errorf("invalid replica delay %s", delay)
+	}
+	if err := c.changeReplicaDelay(delay); err != nil {
+		return errors.Trace(err)
+	}
+	return c.StartReplicaSQLThread()
+}
+
+// changeReplicaDelay sets the SOURCE_DELAY of the replica, with its SQL
+// thread stopped.
+func (c *Conn) changeReplicaDelay(delay time.Duration) error {
+	if err := c.StopReplicaSQLThread(); err != nil {
+		return errors.Trace(err)
+	}
+	replica, source := c.replicaSyntax()
+	change := "CHANGE REPLICATION SOURCE TO"
+	if replica == "SLAVE" {
+		change = "CHANGE MASTER TO"
+	}
+	_, err := c.Execute(fmt.Sprintf("%s %s_DELAY = %d", change, source, int64(delay/time.Second)))
+	return errors.Trace(err)
+}
+
+// GetReplicaSQLStatus returns the status of the SQL thread of the replica.
+func (c *Conn) GetReplicaSQLStatus() (ReplicaSQLStatus, error) {
+	var st ReplicaSQLStatus
+
+	replica, _ := c.replicaSyntax()
+	r, err := c.Execute("SHOW " + replica + " STATUS")
+	if err != nil {
+		return st, errors.Trace(err)
+	}
+	defer r.Close()
+	if r.RowNumber() == 0 {
+		return st, errors.New("server is not a replica")
+	}
+
+	running := "Replica_SQL_Running"
+	if _, ok := r.FieldNames[running]; !ok {
+		running = "Slave_SQL_Running"
+	}
+	s, err := r.GetStringByName(0, running)
+	if err != nil {
+		return st, errors.Trace(err)
+	}
+	st.Running = s == "Yes"
+
+	delay, err := r.GetIntByName(0, "SQL_Delay")
+	if err != nil {
+		return st, errors.Trace(err)
+	}
+	st.Delay = time.Duration(delay) * time.Second
+
+	isNull, err := r.IsNullByName(0, "SQL_Remaining_Delay")
+	if err != nil {
+		return st, errors.Trace(err)
+	}
+	if !isNull {
+		remaining, err := r.GetIntByName(0, "SQL_Remaining_Delay")
+		if err != nil {
+			return st, errors.Trace(err)
+		}
+		st.Waiting = true
+		st.RemainingDelay = time.Duration(remaining) * time.Second
+	}
+	return st, nil
+}
+
+// RollForwardToGTIDSet applies the relay log of the stopped replica until the
+// transactions of gset are executed, with START REPLICA SQL_THREAD UNTIL
+// SQL_AFTER_GTIDS, and waits for them, see WaitForGTIDSet. The SOURCE_DELAY is
+// set to 0 first: call SetReplicaDelay to resume the delayed replication. With
+// MariaDB, both threads are started until master_gtid_pos.
+func (c *Conn) RollForwardToGTIDSet(ctx context.Context, gset mysql.GTIDSet, opts GTIDWaitOptions) error {
+	if err := c.changeReplicaDelay(0); err != nil {
+		return errors.Trace(err)
+	}
+
+	replica, _ := c.replicaSyntax()
+	query := fmt.Sprintf("START %s SQL_THREAD UNTIL SQL_AFTER_GTIDS = '%s'", replica, mysql.Escape(gset.String()))
+	if _, ok := gset.(*mysql.MariadbGTIDSet); ok {
+		query = fmt.Sprintf("START %s UNTIL master_gtid_pos = '%s'", replica, mysql.Escape(gset.String()))
+	}
+	if _, err := c.Execute(query); err != nil {
+		return errors.Trace(err)
+	}
+
+	if err := c.WaitForGTIDSet(ctx, gset, opts); err != nil {
+		// don't apply the events after gset, once the wait is over
+		_ = c.StopReplicaSQLThread()
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// RollForwardToTime applies the relay log of the replica until the events
+// committed on the source at t, to the second, and stops the SQL thread before
+// the first event committed after. As START REPLICA UNTIL has no time, the
+// SOURCE_DELAY is set for the events after t to be applied later than in a
+// round of 10 poll intervals, 1s by default, until the SQL thread waits for
+// such an event. It lasts until ctx is done if no event is committed after t.
+// The SQL thread is stopped and SOURCE_DELAY left to its value at the end:
+// call SetReplicaDelay to resume the delayed replication.
+func (c *Conn) RollForwardToTime(ctx context.Context, t time.Time, pollInterval time.Duration) error {
+	if pollInterval <= 0 {
+		pollInterval = DefaultGTIDWaitPollInterval
+	}
+	round := max(int64((10*pollInterval+time.Second-1)/time.Second), 1)
+	target := t.Unix()
+
+	err := c.rollForwardToTime(ctx, target, round, pollInterval)
+	if err != nil {
+		// don't apply the events after t, once the delay elapses
+		_ = c.StopReplicaSQLThread()
+	}
+	return errors.Trace(err)
+}
+
+func (c *Conn) rollForwardToTime(ctx context.Context, target int64, round int64, pollInterval time.Duration) error {
+	for {
+		now, err := c.serverUnixTime()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if target > now {
+			return errors.Errorf("time %s is in the future of the server", time.Unix(target, 0))
+		}
+
+		// the events committed up to target are applied by end, the ones after
+		// from end+1
+		delay := now - target + round
+		end := now + round
+		if err = c.SetReplicaDelay(time.Duration(delay) * time.Second); err != nil {
+			return errors.Trace(err)
+		}
+
+		for now < end {
+			if err = sleepContext(ctx, pollInterval); err != nil {
+				return err
+			}
+			st, at, err := c.replicaSQLStatusAt()
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if !st.Running {
+				return errors.New("replica SQL thread stopped")
+			}
+			if st.Waiting && at+int64(st.RemainingDelay/time.Second) > end {
+				// the next event is after target
+				return c.StopReplicaSQLThread()
+			}
+			now = at
+		}
+		// the SQL thread is late, or no event is committed after target yet:
+		// another round, its delay set before the events after target apply
+	}
+}
+
+// replicaSQLStatusAt returns the status of the SQL thread and the time of the
+// server it was read at, in seconds.
+func (c *Conn) replicaSQLStatusAt() (ReplicaSQLStatus, int64, error) {
+	for {
+		before, err := c.serverUnixTime()
+		if err != nil {
+			return ReplicaSQLStatus{}, 0, errors.Trace(err)
+		}
+		st, err := c.GetReplicaSQLStatus()
+		if err != nil {
+			return st, 0, errors.Trace(err)
+		}
+		after, err := c.serverUnixTime()
+		if err != nil {
+			return st, 0, errors.Trace(err)
+		}
+		// SQL_Remaining_Delay is relative to the second of the status
+		if before == after {
+			return st, after, nil
+		}
+	}
+}
+
+func (c *Conn) serverUnixTime() (int64, error) {
+	r, err := c.Execute("SELECT UNIX_TIMESTAMP()")
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer r.Close()
+	n, err := r.GetInt(0, 0)
+	return n, errors.Trace(err)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,117 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+// delayedReplicaHandler is a delayed replica whose relay log has two events
+// committed at target and one after
+type delayedReplicaHandler struct {
+	server.EmptyHandler
+	mu      sync.Mutex
+	target  int64
+	delay   int64
+	running bool
+	polls   int
+	queries []string
+}
+
+func (h *delayedReplicaHandler) HandleQuery(query string) (*mysql.Result, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var names []string
+	var values []interface{}
+	switch {
+	case query == "SELECT UNIX_TIMESTAMP()":
+		names, values = []string{"v"}, []interface{}{time.Now().Unix()}
+	case query == "SHOW SLAVE STATUS":
+		h.polls++
+		// applying, then waiting for the event at target, then the one after
+		var remaining interface{}
+		if h.polls > 1 {
+			ts := h.target
+			if h.polls > 2 {
+				ts++
+			}
+			remaining = ts + h.delay - time.Now().Unix()
+		}
+		running := "No"
+		if h.running {
+			running = "Yes"
+		}
+		names = []string{"Slave_SQL_Running", "SQL_Delay", "SQL_Remaining_Delay"}
+		values = []interface{}{running, h.delay, remaining}
+	case strings.HasPrefix(query, "SELECT WAIT_FOR_EXECUTED_GTID_SET("):
+		h.queries = append(h.queries, query)
+		names, values = []string{"v"}, []interface{}{int64(0)}
+	default:
+		h.queries = append(h.queries, query)
+		switch {
+		case strings.HasPrefix(query, "CHANGE MASTER TO MASTER_DELAY = "):
+			h.delay, _ = strconv.ParseInt(strings.TrimPrefix(query, "CHANGE MASTER TO MASTER_DELAY = "), 10, 64)
+		case strings.HasPrefix(query, "START SLAVE SQL_THREAD"):
+			h.running = true
+		case query == "STOP SLAVE SQL_THREAD":
+			h.running = false
+		default:
+			return nil, errors.New("unexpected query " + query)
+		}
+		return nil, nil
+	}
+	rs, err := mysql.BuildSimpleTextResultset(names, [][]interface{}{values})
+	if err != nil {
+		return nil, err
+	}
+	return mysql.NewResult(rs), nil
+}
+
+func TestDelayedReplica(t *testing.T) {
+	h := &delayedReplicaHandler{}
+	addr := serve(t, h)
+
+	conn, err := client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.SetReplicaDelay(time.Hour))
+	require.Equal(t, []string{"STOP SLAVE SQL_THREAD", "CHANGE MASTER TO MASTER_DELAY = 3600", "START SLAVE SQL_THREAD"}, h.queries)
+	st, err := conn.GetReplicaSQLStatus()
+	require.NoError(t, err)
+	require.Equal(t, client.ReplicaSQLStatus{Running: true, Delay: time.Hour}, st)
+
+	h.queries = nil
+	gset, err := mysql.ParseGTIDSet(mysql.MySQLFlavor, testServerUUID+":1-4")
+	require.NoError(t, err)
+	require.NoError(t, conn.RollForwardToGTIDSet(context.Background(), gset, client.GTIDWaitOptions{}))
+	require.Equal(t, []string{
+		"STOP SLAVE SQL_THREAD",
+		"CHANGE MASTER TO MASTER_DELAY = 0",
+		"START SLAVE SQL_THREAD UNTIL SQL_AFTER_GTIDS = '" + testServerUUID + ":1-4'",
+		"SELECT WAIT_FOR_EXECUTED_GTID_SET('" + testServerUUID + ":1-4', 1.000)",
+	}, h.queries)
+
+	// stopped once waiting for the event after target
+	h.queries, h.polls = nil, 0
+	h.target = time.Now().Unix() - 60
+	require.NoError(t, conn.RollForwardToTime(context.Background(), time.Unix(h.target, 0), 10*time.Millisecond))
+	require.Equal(t, 3, h.polls)
+	require.False(t, h.running)
+	require.Equal(t, "STOP SLAVE SQL_THREAD", h.queries[len(h.queries)-1])
+	require.GreaterOrEqual(t, h.delay, int64(61))
+
+	err = conn.RollForwardToTime(context.Background(), time.Now().Add(time.Hour), 0)
+	require.ErrorContains(t, err, "in the future")
+}
@@ -0,0 +1,195 @@
+package client
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// ExplainPlan is the structured form of EXPLAIN FORMAT=JSON output.
+type ExplainPlan struct {
+	// Tables lists the accessed tables in the order they appear in the plan.
+	Tables []ExplainTable
+	// Raw is the JSON document returned by the server.
+	Raw string
+}
+
+// ExplainTable describes how a single table is accessed. Its fields mirror the
+// columns of the traditional tabular EXPLAIN output.
+type ExplainTable struct {
+	SelectID     uint64
+	Table        string
+	AccessType   string
+	PossibleKeys []string
+	Key          string
+	KeyLength    string
+	Ref          []string
+	Rows         uint64
+	Filtered     float64
+	Extra        []string
+}
+
+// Explain runs EXPLAIN FORMAT=JSON for the query and returns the parsed plan.
+func (c *Conn) Explain(query string) (*ExplainPlan, error) {
+	r, err := c.exec("EXPLAIN FORMAT=JSON " + query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer r.Close()
+
+	if r.Resultset == nil || r.RowNumber() == 0 {
+		return nil, errors.Errorf("empty EXPLAIN result for %q", query)
+	}
+
+	s, err := r.GetString(0, 0)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	// the string may point into the pooled resultset buffer
+	return ParseExplainJSON(strings.Clone(s))
+}
+
+// ParseExplainJSON parses the JSON document produced by EXPLAIN FORMAT=JSON.
+func ParseExplainJSON(s string) (*ExplainPlan, error) {
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+
+	var doc map[string]interface{}
+	if err := d.Decode(&doc); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	qb, ok := doc["query_block"]
+	if !ok {
+		return nil, errors.Errorf("missing query_block in EXPLAIN output")
+	}
+
+	p := &ExplainPlan{Raw: s}
+	p.walk(qb, 0, nil)
+	return p, nil
+}
+
+// explainOperationFlags maps the flags of grouping / ordering operations to
+// the text the tabular EXPLAIN shows in the Extra column.
+var explainOperationFlags = []struct {
+	key   string
+	extra string
+}{
+	{"using_temporary_table", "Using temporary"},
+	{"using_filesort", "Using filesort"},
+}
+
+// walk visits v depth-first, collecting every table node. Operation flags found
+// on the way down are reported on the first table below the operation.
+func (p *ExplainPlan) walk(v interface{}, selectID uint64, pending []string) []string {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, e := range v {
+			pending = p.walk(e, selectID, pending)
+		}
+		return pending
+	case map[string]interface{}:
+		if id, ok := v["select_id"]; ok {
+			selectID = explainUint(id)
+		}
+		for _, f := range explainOperationFlags {
+			if b, _ := v[f.key].(bool); b {
+				pending = append(pending, f.extra)
+			}
+		}
+		if _, ok := v["table_name"]; ok {
+			p.Tables = append(p.Tables, newExplainTable(v, selectID, pending))
+			pending = nil
+		}
+
+		// map iteration order is random, sort keys to keep the result stable
+		keys := make([]string, 0, len(v))
+		for k, e := range v {
+			switch e.(type) {
+			case map[string]interface{}, []interface{}:
+				if k != "cost_info" {
+					keys = append(keys, k)
+				}
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			pending = p.walk(v[k], selectID, pending)
+		}
+	}
+	return pending
+}
+
+func newExplainTable(v map[string]interface{}, selectID uint64, extra []string) ExplainTable {
+	t := ExplainTable{
+		SelectID:     selectID,
+		Table:        explainString(v["table_name"]),
+		AccessType:   explainString(v["access_type"]),
+		PossibleKeys: explainStrings(v["possible_keys"]),
+		Key:          explainString(v["key"]),
+		KeyLength:    explainString(v["key_length"]),
+		Ref:          explainStrings(v["ref"]),
+		Filtered:     explainFloat(v["filtered"]),
+		Extra:        extra,
+	}
+
+	// MySQL 5.6 reports "rows", newer versions "rows_examined_per_scan"
+	if rows, ok := v["rows_examined_per_scan"]; ok {
+		t.Rows = explainUint(rows)
+	} else {
+		t.Rows = explainUint(v["rows"])
+	}
+
+	if b, _ := v["using_index"].(bool); b {
+		t.Extra = append(t.Extra, "Using index")
+	}
+	if _, ok := v["attached_condition"]; ok {
+		t.Extra = append(t.Extra, "Using where")
+	}
+	if s := explainString(v["using_join_buffer"]); s != "" {
+		t.Extra = append(t.Extra, "Using join buffer ("+s+")")
+	}
+	if s := explainString(v["message"]); s != "" {
+		t.Extra = append(t.Extra, s)
+	}
+
+	return t
+}
+
+func explainString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+func explainStrings(v interface{}) []string {
+	a, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	s := make([]string, 0, len(a))
+	for _, e := range a {
+		s = append(s, explainString(e))
+	}
+	return s
+}
+
+// explainUint and explainFloat accept both numbers and strings, the type of
+// some fields like "filtered" differs between server versions.
+func explainUint(v interface{}) uint64 {
+	n, _ := strconv.ParseUint(explainString(v), 10, 64)
+	return n
+}
+
+func explainFloat(v interface{}) float64 {
+	f, _ := strconv.ParseFloat(explainString(v), 64)
+	return f
+}
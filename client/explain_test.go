@@ -0,0 +1,81 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExplainJSON(t *testing.T) {
+	// trimmed output of MySQL 8.0 for
+	// SELECT * FROM t1 JOIN t2 ON t1.id = t2.id WHERE t1.c > 1 ORDER BY t1.c
+	s := `{
+  "query_block": {
+    "select_id": 1,
+    "cost_info": {"query_cost": "3.40"},
+    "ordering_operation": {
+      "using_temporary_table": true,
+      "using_filesort": true,
+      "nested_loop": [
+        {
+          "table": {
+            "table_name": "t1",
+            "access_type": "range",
+            "possible_keys": ["PRIMARY", "idx_c"],
+            "key": "idx_c",
+            "key_length": "5",
+            "rows_examined_per_scan": 3,
+            "filtered": "100.00",
+            "using_index": true,
+            "attached_condition": "(t1.c > 1)"
+          }
+        },
+        {
+          "table": {
+            "table_name": "t2",
+            "access_type": "eq_ref",
+            "possible_keys": ["PRIMARY"],
+            "key": "PRIMARY",
+            "key_length": "4",
+            "ref": ["test.t1.id"],
+            "rows_examined_per_scan": 1,
+            "filtered": 50
+          }
+        }
+      ]
+    }
+  }
+}`
+
+	p, err := ParseExplainJSON(s)
+	require.NoError(t, err)
+	require.Equal(t, s, p.Raw)
+	require.Len(t, p.Tables, 2)
+
+	require.Equal(t, ExplainTable{
+		SelectID:     1,
+		Table:        "t1",
+		AccessType:   "range",
+		PossibleKeys: []string{"PRIMARY", "idx_c"},
+		Key:          "idx_c",
+		KeyLength:    "5",
+		Rows:         3,
+		Filtered:     100,
+		Extra:        []string{"Using temporary", "Using filesort", "Using index", "Using where"},
+	}, p.Tables[0])
+
+	require.Equal(t, ExplainTable{
+		SelectID:     1,
+		Table:        "t2",
+		AccessType:   "eq_ref",
+		PossibleKeys: []string{"PRIMARY"},
+		Key:          "PRIMARY",
+		KeyLength:    "4",
+		Ref:          []string{"test.t1.id"},
+		Rows:         1,
+		Filtered:     50,
+	}, p.Tables[1])
+
+	_, err = ParseExplainJSON(`{"foo": 1}`)
+	require.Error(t, err)
+}
@@ -0,0 +1,195 @@
+package client
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+/*
+Failover connects to the first of several servers which accepts the connection,
+e.g. the members of a Group Replication cluster.
+
+Usage:
+	f := client.NewFailover([]string{`10.0.0.1:3306`, `10.0.0.2:3306`}, client.WithGroupReplicationDiscovery())
+	conn, err := f.Connect(ctx, `username`, `userpwd`, `dbname`, ``)
+
+Connect, the Pool and the driver accept the addresses separated by commas:
+	conn, err := client.Connect(`10.0.0.1:3306,10.0.0.2:3306`, `username`, `userpwd`, `dbname`, ``)
+*/
+
+type (
+	Failover struct {
+		dialer Dialer
+		// discover returns the addresses to try, refresh the addresses after a
+		// connection, both optional
+		discover func(ctx context.Context) ([]string, error)
+		refresh  func(conn *Conn) ([]string, error)
+
+		mu    sync.Mutex
+		addrs []string
+	}
+
+	FailoverOption func(f *Failover)
+)
+
+// WithFailoverDialer sets the Dialer, a net.Dialer with a 10s timeout by
+// default like Connect.
+func WithFailoverDialer(dialer Dialer) FailoverOption {
+	return func(f *Failover) {
+		f.dialer = dialer
+	}
+}
+
+// WithAddrDiscovery sets a callback returning the addresses to try, in order,
+// before every Connect, e.g. from a service registry. The addresses passed to
+// NewFailover are used if it fails.
+func WithAddrDiscovery(discover func(ctx context.Context) ([]string, error)) FailoverOption {
+	return func(f *Failover) {
+		f.discover = discover
+	}
+}
+
+// WithGroupReplicationDiscovery refreshes the addresses after every Connect
+// from the ONLINE members of the Group Replication cluster of the server, see
+// GroupReplicationMembers, so the next Connect finds the members which joined
+// after NewFailover. The addresses are kept if the server is not in a group.
+func WithGroupReplicationDiscovery() FailoverOption {
+	return func(f *Failover) {
+		f.refresh = GroupReplicationMembers
+	}
+}
+
+// NewFailover returns a Failover trying addrs in order.
+func NewFailover(addrs []string, options ...FailoverOption) *Failover {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	f := &Failover{
+		dialer: dialer.DialContext,
+		addrs:  append([]string(nil), addrs...),
+	}
+	for _, o := range options {
+		o(f)
+	}
+	return f
+}
+
+// Addrs returns the addresses tried by the next Connect, without the ones of
+// WithAddrDiscovery.
+func (f *Failover) Addrs() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.addrs...)
+}
+
+// Connect connects to the first address which accepts the connection, see
+// ConnectWithDialer.
+func (f *Failover) Connect(ctx context.Context, user, password, dbName, charset string, options ...Option) (*Conn, error) {
+	addrs := f.Addrs()
+	if f.discover != nil {
+		if discovered, err := f.discover(ctx); err == nil && len(discovered) > 0 {
+			addrs = discovered
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("no address to connect to")
+	}
+
+	conn, i, err := connectAny(ctx, "", addrs, user, password, dbName, charset, f.dialer, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	var refreshed []string
+	if f.refresh != nil {
+		if members, err := f.refresh(conn); err == nil && len(members) > 0 {
+			refreshed = members
+		}
+	}
+	f.mu.Lock()
+	if refreshed != nil {
+		f.addrs = refreshed
+	} else if f.discover == nil && i > 0 {
+		// try the address which works first the next time
+		f.addrs = append(append([]string{addrs[i]}, addrs[:i]...), addrs[i+1:]...)
+	}
+	f.mu.Unlock()
+	return conn, nil
+}
+
+// splitAddrs splits a comma separated list of addresses.
+func splitAddrs(addr string) []string {
+	if !strings.Contains(addr, ",") {
+		return []string{addr}
+	}
+	var addrs []string
+	for _, a := range strings.Split(addr, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
+
+// connectAny connects to the first of addrs which accepts the connection, and
+// returns its index. It doesn't try the next addresses after an error which
+// would be the same on all the servers, like a wrong password, or if ctx is done.
+func connectAny(ctx context.Context, network string, addrs []string, user, password, dbName, charset string, dialer Dialer, options ...Option) (*Conn, int, error) {
+	var errs []string
+	for i, addr := range addrs {
+		conn, err := connectAddr(ctx, network, addr, user, password, dbName, charset, dialer, options...)
+		if err == nil {
+			return conn, i, nil
+		}
+		if ctx.Err() != nil || !isFailoverError(err) {
+			return nil, i, err
+		}
+		errs = append(errs, addr+": "+err.Error())
+	}
+	return nil, -1, errors.Errorf("connect to %s failed: %s", strings.Join(addrs, ","), strings.Join(errs, "; "))
+}
+
+// isFailoverError returns whether the connection may succeed on another server
+// after err.
+func isFailoverError(err error) bool {
+	if mysql.IsAccessDenied(err) {
+		return false
+	}
+	code, ok := mysql.MyErrorCode(err)
+	return !ok || code != mysql.ER_BAD_DB_ERROR
+}
+
+// GroupReplicationMembers returns the addresses of the ONLINE members of the
+// Group Replication cluster of the server of conn, from
+// performance_schema.replication_group_members, the primary first. It returns
+// no address if the server is not in a group.
+func GroupReplicationMembers(conn *Conn) ([]string, error) {
+	r, err := conn.Execute(`SELECT MEMBER_HOST, MEMBER_PORT, MEMBER_ROLE FROM performance_schema.replication_group_members WHERE MEMBER_STATE = 'ONLINE'`)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer r.Close()
+
+	var primaries, secondaries []string
+	for i := 0; i < r.RowNumber(); i++ {
+		host, _ := r.GetString(i, 0)
+		port, _ := r.GetUint(i, 1)
+		role, _ := r.GetString(i, 2)
+		if host == "" || port == 0 {
+			continue
+		}
+		addr := net.JoinHostPort(host, strconv.FormatUint(port, 10))
+		if role == "PRIMARY" {
+			primaries = append(primaries, addr)
+		} else {
+			secondaries = append(secondaries, addr)
+		}
+	}
+	return append(primaries, secondaries...), nil
+}
@@ -0,0 +1,90 @@
+package client_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+// groupHandler answers the query of the members of a Group Replication cluster
+type groupHandler struct {
+	server.EmptyHandler
+	members [][]interface{}
+}
+
+func (h *groupHandler) HandleQuery(query string) (*mysql.Result, error) {
+	if !strings.Contains(query, "replication_group_members") {
+		return nil, nil
+	}
+	r, err := mysql.BuildSimpleResultset([]string{"MEMBER_HOST", "MEMBER_PORT", "MEMBER_ROLE"}, h.members, false)
+	if err != nil {
+		return nil, err
+	}
+	return mysql.NewResult(r), nil
+}
+
+// closedAddr returns an address nothing listens on.
+func closedAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	return addr
+}
+
+func TestConnectFailover(t *testing.T) {
+	live := serve(t, &sessionHandler{})
+	dead := closedAddr(t)
+
+	conn, err := client.Connect(dead+", "+live, "root", "", "", "")
+	require.NoError(t, err)
+	require.NoError(t, conn.Ping())
+	conn.Close()
+
+	_, err = client.Connect(dead+","+closedAddr(t), "root", "", "", "")
+	require.ErrorContains(t, err, dead)
+
+	// the same error on all the servers
+	_, err = client.Connect(live+","+live, "root", "wrong", "", "")
+	require.True(t, mysql.IsAccessDenied(err))
+}
+
+func TestFailoverGroupReplication(t *testing.T) {
+	h := &groupHandler{}
+	live := serve(t, h)
+	host, port, err := net.SplitHostPort(live)
+	require.NoError(t, err)
+	h.members = [][]interface{}{
+		{host, port, "SECONDARY"},
+		{"10.0.0.3", "3306", "PRIMARY"},
+	}
+
+	dead := closedAddr(t)
+	f := client.NewFailover([]string{dead, live}, client.WithGroupReplicationDiscovery())
+	conn, err := f.Connect(context.Background(), "root", "", "", "")
+	require.NoError(t, err)
+	conn.Close()
+	require.Equal(t, []string{"10.0.0.3:3306", live}, f.Addrs())
+
+	// not in a group, the working address is tried first
+	h.members = nil
+	f = client.NewFailover([]string{dead, live}, client.WithGroupReplicationDiscovery())
+	conn, err = f.Connect(context.Background(), "root", "", "", "")
+	require.NoError(t, err)
+	conn.Close()
+	require.Equal(t, []string{live, dead}, f.Addrs())
+
+	f = client.NewFailover(nil, client.WithAddrDiscovery(func(context.Context) ([]string, error) {
+		return []string{dead, live}, nil
+	}))
+	conn, err = f.Connect(context.Background(), "root", "", "", "")
+	require.NoError(t, err)
+	conn.Close()
+}
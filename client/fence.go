@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// ErrFenceFailed is returned by Fence if the server still executes writes
+// once fenced.
+var ErrFenceFailed = errors.New("fenced server still writes")
+
+// FenceOptions are the options of Fence.
+type FenceOptions struct {
+	// KeepUsers are the users whose sessions are not killed, like the ones of
+	// the monitoring. The replication threads, the system threads and the
+	// session of the connection are never killed.
+	KeepUsers []string
+	// VerifyInterval is how long the executed GTID set has to stay the same
+	// for the server to be fenced, 1s by default.
+	VerifyInterval time.Duration
+}
+
+// Fence stops the writes of a demoted primary so that it can't diverge from
+// the new one: it sets super_read_only, which also blocks the users with
+// SUPER or CONNECTION_ADMIN, kills the client sessions of the processlist,
+// rolling back their transactions, and verifies that the GTID set executed by
+// the server doesn't change during opts.VerifyInterval. It returns the ids of
+// the sessions killed, and ErrFenceFailed if the server still writes.
+func (c *Conn) Fence(ctx context.Context, opts FenceOptions) ([]uint64, error) {
+	if opts.VerifyInterval <= 0 {
+		opts.VerifyInterval = time.Second
+	}
+
+	if _, err := c.Execute("SET GLOBAL super_read_only = ON"); err != nil {
+		return nil, errors.Trace(err)
+	}
+	killed, err := c.killSessions(opts.KeepUsers)
+	if err != nil {
+		return killed, errors.Trace(err)
+	}
+
+	before, err := c.executedGTIDSet()
+	if err != nil {
+		return killed, errors.Trace(err)
+	}
+	if err = sleepContext(ctx, opts.VerifyInterval); err != nil {
+		return killed, err
+	}
+	r, err := c.Execute("SELECT @@GLOBAL.super_read_only")
+	if err != nil {
+		return killed, errors.Trace(err)
+	}
+	readOnly, err := r.GetInt(0, 0)
+	r.Close()
+	if err != nil {
+		return killed, errors.Trace(err)
+	}
+	if readOnly != 1 {
+		return killed, errors.Annotate(ErrFenceFailed, "super_read_only was reset")
+	}
+	after, err := c.executedGTIDSet()
+	if err != nil {
+		return killed, errors.Trace(err)
+	}
+	if !after.Equal(before) {
+		return killed, errors.Annotatef(ErrFenceFailed, "executed %s after %s", after, before)
+	}
+	return killed, nil
+}
+
+// killSessions kills the client sessions of the processlist, except the ones
+// of keepUsers.
+func (c *Conn) killSessions(keepUsers []string) ([]uint64, error) {
+	// the threads of the replicas and the ones of the server itself
+	users := []string{"'system user'", "'event_scheduler'"}
+	for _, user := range keepUsers {
+		users = append(users, "'"+mysql.Escape(user)+"'")
+	}
+	r, err := c.Execute(fmt.Sprintf("SELECT ID FROM information_schema.processlist WHERE ID <> CONNECTION_ID() AND COMMAND NOT IN ('Binlog Dump', 'Binlog Dump GTID', 'Daemon') AND USER NOT IN (%s)",
+		strings.Join(users, ", ")))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	ids := make([]uint64, r.RowNumber())
+	for i := range ids {
+		if ids[i], err = r.GetUint(i, 0); err != nil {
+			r.Close()
+			return nil, errors.Trace(err)
+		}
+	}
+	r.Close()
+
+	var killed []uint64
+	for _, id := range ids {
+		if _, err = c.Execute(fmt.Sprintf("KILL %d", id)); err != nil {
+			// the session already ended
+			if code, ok := mysql.MyErrorCode(err); ok && code == mysql.ER_NO_SUCH_THREAD {
+				continue
+			}
+			return killed, errors.Trace(err)
+		}
+		killed = append(killed, id)
+	}
+	return killed, nil
+}
@@ -0,0 +1,91 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+// fenceHandler is a demoted primary with the client sessions 5, 6 and 7, the
+// session 6 ending before it's killed
+type fenceHandler struct {
+	server.EmptyHandler
+	mu       sync.Mutex
+	executed int
+	// writes is the number of transactions executed after each read of the
+	// executed GTID set
+	writes   int
+	readOnly int64
+	queries  []string
+}
+
+func (h *fenceHandler) HandleQuery(query string) (*mysql.Result, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var value interface{}
+	switch {
+	case strings.HasPrefix(query, "SELECT ID FROM information_schema.processlist"):
+		h.queries = append(h.queries, query)
+		rs, err := mysql.BuildSimpleTextResultset([]string{"ID"}, [][]interface{}{{uint64(5)}, {uint64(6)}, {uint64(7)}})
+		if err != nil {
+			return nil, err
+		}
+		return mysql.NewResult(rs), nil
+	case query == "SELECT @@GLOBAL.gtid_executed":
+		value = fmt.Sprintf("%s:1-%d", testServerUUID, h.executed)
+		h.executed += h.writes
+	case query == "SELECT @@GLOBAL.super_read_only":
+		value = h.readOnly
+	default:
+		h.queries = append(h.queries, query)
+		switch query {
+		case "SET GLOBAL super_read_only = ON":
+			h.readOnly = 1
+		case "KILL 6":
+			return nil, mysql.NewError(mysql.ER_NO_SUCH_THREAD, "Unknown thread id: 6")
+		}
+		return nil, nil
+	}
+	rs, err := mysql.BuildSimpleTextResultset([]string{"v"}, [][]interface{}{{value}})
+	if err != nil {
+		return nil, err
+	}
+	return mysql.NewResult(rs), nil
+}
+
+func TestFence(t *testing.T) {
+	h := &fenceHandler{executed: 10}
+	conn, err := client.Connect(serve(t, h), "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	opts := client.FenceOptions{KeepUsers: []string{"monitor"}, VerifyInterval: time.Millisecond}
+	killed, err := conn.Fence(context.Background(), opts)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{5, 7}, killed)
+	require.Equal(t, []string{
+		"SET GLOBAL super_read_only = ON",
+		"SELECT ID FROM information_schema.processlist WHERE ID <> CONNECTION_ID() AND COMMAND NOT IN ('Binlog Dump', 'Binlog Dump GTID', 'Daemon') AND USER NOT IN ('system user', 'event_scheduler', 'monitor')",
+		"KILL 5",
+		"KILL 6",
+		"KILL 7",
+	}, h.queries)
+
+	// a transaction committed while fenced
+	h.mu.Lock()
+	h.writes = 1
+	h.mu.Unlock()
+	_, err = conn.Fence(context.Background(), opts)
+	require.ErrorIs(t, err, client.ErrFenceFailed)
+	require.ErrorContains(t, err, ":1-11 after ")
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// ErrGTIDSetNotExecuted is returned by PositionOfGTIDSet if the GTID set is not
+// in the binlog files of the server.
+var ErrGTIDSetNotExecuted = errors.New("GTID set not executed in the binlog files")
+
+// binlogEventsPageSize is the number of events read at once by SHOW BINLOG EVENTS.
+const binlogEventsPageSize = 10000
+
+type binlogEventRow struct {
+	eventType string
+	endLogPos uint32
+	info      string
+}
+
+// scanBinlogEvents calls fn for the events of the binlog file name, in pages
+// of SHOW BINLOG EVENTS, until it returns false.
+func (c *Conn) scanBinlogEvents(name string, fn func(e binlogEventRow) (bool, error)) error {
+	from := uint64(4)
+	for {
+		r, err := c.Execute(fmt.Sprintf("SHOW BINLOG EVENTS IN '%s' FROM %d LIMIT %d", mysql.Escape(name), from, binlogEventsPageSize))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		for i := 0; i < r.RowNumber(); i++ {
+			var e binlogEventRow
+			if e.eventType, err = r.GetStringByName(i, "Event_type"); err != nil {
+				return errors.Trace(err)
+			}
+			if from, err = r.GetUintByName(i, "End_log_pos"); err != nil {
+				return errors.Trace(err)
+			}
+			if e.info, err = r.GetStringByName(i, "Info"); err != nil {
+				return errors.Trace(err)
+			}
+			e.endLogPos = uint32(from)
+			if ok, err := fn(e); err != nil || !ok {
+				return err
+			}
+		}
+		if r.RowNumber() < binlogEventsPageSize {
+			return nil
+		}
+	}
+}
+
+// binlogGTIDs tracks the GTID set executed along the events of a binlog file,
+// a transaction is executed once its commit is.
+type binlogGTIDs struct {
+	executed *mysql.MysqlGTIDSet
+	// pending is the GTID of the transaction in progress
+	pending string
+}
+
+// add returns whether e completes a transaction.
+func (g *binlogGTIDs) add(e binlogEventRow) (bool, error) {
+	switch e.eventType {
+	case "Previous_gtids":
+		// the UUID sets are split in lines
+		set, err := mysql.ParseMysqlGTIDSet(strings.Join(strings.Fields(e.info), ""))
+		if err != nil {
+			return false, errors.Trace(err)
+		}
+		g.executed = set.(*mysql.MysqlGTIDSet)
+	case "Gtid":
+		// SET @@SESSION.GTID_NEXT= 'uuid:gno'
+		start, end := strings.IndexByte(e.info, '\''), strings.LastIndexByte(e.info, '\'')
+		if start < 0 || end <= start {
+			return false, errors.Errorf("invalid Gtid event info %q", e.info)
+		}
+		g.pending = e.info[start+1 : end]
+	case "Xid", "Query":
+		if (e.eventType == "Query" && strings.EqualFold(e.info, "BEGIN")) || g.pending == "" {
+			return false, nil
+		}
+		if g.executed == nil {
+			return false, errors.New("no Previous_gtids event before the transactions")
+		}
+		if err := g.executed.Update(g.pending); err != nil {
+			return false, errors.Trace(err)
+		}
+		g.pending = ""
+		return true, nil
+	}
+	return false, nil
+}
+
+// GTIDSetAtPosition returns the GTID set executed by the server at pos of its
+// binlog files, from the Previous_gtids event of the file and the transactions
+// committed before pos, read with SHOW BINLOG EVENTS. A transaction in progress
+// at pos is not included. It is to switch from syncing by position to syncing
+// by GTID, only for MySQL.
+func (c *Conn) GTIDSetAtPosition(pos mysql.Position) (mysql.GTIDSet, error) {
+	var g binlogGTIDs
+	err := c.scanBinlogEvents(pos.Name, func(e binlogEventRow) (bool, error) {
+		if e.endLogPos > pos.Pos {
+			return false, nil
+		}
+		_, err := g.add(e)
+		return true, err
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if g.executed == nil {
+		return nil, errors.Errorf("no Previous_gtids event in %s before %d", pos.Name, pos.Pos)
+	}
+	return g.executed, nil
+}
+
+// PositionOfGTIDSet returns the first position of the binlog files of the
+// server at which gset is executed, the reverse of GTIDSetAtPosition, to switch
+// from syncing by GTID to syncing by position. It is the start of the oldest
+// file if gset is executed before it, and ErrGTIDSetNotExecuted if gset is not
+// executed yet. Only for MySQL.
+func (c *Conn) PositionOfGTIDSet(gset mysql.GTIDSet) (mysql.Position, error) {
+	if _, ok := gset.(*mysql.MysqlGTIDSet); !ok {
+		return mysql.Position{}, errors.Errorf("%T is not a MySQL GTID set", gset)
+	}
+
+	r, err := c.Execute("SHOW BINARY LOGS")
+	if err != nil {
+		return mysql.Position{}, errors.Trace(err)
+	}
+	files := make([]string, r.RowNumber())
+	for i := range files {
+		if files[i], err = r.GetString(i, 0); err != nil {
+			return mysql.Position{}, errors.Trace(err)
+		}
+	}
+
+	// the last file whose Previous_gtids event does not contain gset has it
+	for i := len(files) - 1; i >= 0; i-- {
+		var g binlogGTIDs
+		var pos mysql.Position
+		err = c.scanBinlogEvents(files[i], func(e binlogEventRow) (bool, error) {
+			committed, err := g.add(e)
+			if err != nil {
+				return false, err
+			}
+			if e.eventType == "Previous_gtids" && g.executed.Contain(gset) {
+				return false, nil
+			}
+			if committed && g.executed.Contain(gset) {
+				pos = mysql.Position{Name: files[i], Pos: e.endLogPos}
+				return false, nil
+			}
+			return true, nil
+		})
+		if err != nil {
+			return mysql.Position{}, errors.Trace(err)
+		}
+		if pos.Name != "" {
+			return pos, nil
+		}
+		if g.executed == nil || !g.executed.Contain(gset) {
+			if i == len(files)-1 {
+				return mysql.Position{}, ErrGTIDSetNotExecuted
+			}
+			// executed by the end of the file
+			return mysql.Position{Name: files[i+1], Pos: 4}, nil
+		}
+	}
+	if len(files) == 0 {
+		return mysql.Position{}, errors.New("no binlog files")
+	}
+	return mysql.Position{Name: files[0], Pos: 4}, nil
+}
@@ -0,0 +1,124 @@
+package client_test
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+type testBinlogEvent struct {
+	pos, end  int64
+	eventType string
+	info      string
+}
+
+// binlogEventsHandler answers SHOW BINARY LOGS and SHOW BINLOG EVENTS
+type binlogEventsHandler struct {
+	server.EmptyHandler
+	files map[string][]testBinlogEvent
+}
+
+var showBinlogEventsExp = regexp.MustCompile(`^SHOW BINLOG EVENTS IN '(.+)' FROM (\d+) LIMIT (\d+)$`)
+
+func (h *binlogEventsHandler) HandleQuery(query string) (*mysql.Result, error) {
+	var rs *mysql.Resultset
+	var err error
+	if query == "SHOW BINARY LOGS" {
+		rs, err = mysql.BuildSimpleTextResultset([]string{"Log_name", "File_size"}, [][]interface{}{
+			{"mysql-bin.000001", int64(650)},
+			{"mysql-bin.000002", int64(400)},
+		})
+	} else if m := showBinlogEventsExp.FindStringSubmatch(query); m != nil {
+		from, _ := strconv.ParseInt(m[2], 10, 64)
+		limit, _ := strconv.Atoi(m[3])
+		var rows [][]interface{}
+		for _, e := range h.files[m[1]] {
+			if e.pos >= from && len(rows) < limit {
+				rows = append(rows, []interface{}{m[1], e.pos, e.eventType, int64(1), e.end, e.info})
+			}
+		}
+		rs, err = mysql.BuildSimpleTextResultset([]string{"Log_name", "Pos", "Event_type", "Server_id", "End_log_pos", "Info"}, rows)
+	} else {
+		return nil, errors.New("unexpected query " + query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mysql.NewResult(rs), nil
+}
+
+func TestGTIDSetPosition(t *testing.T) {
+	gtidNext := func(gno int) string {
+		return "SET @@SESSION.GTID_NEXT= '" + testServerUUID + ":" + strconv.Itoa(gno) + "'"
+	}
+	h := &binlogEventsHandler{files: map[string][]testBinlogEvent{
+		"mysql-bin.000001": {
+			{4, 126, "Format_desc", "Server ver: 8.0.36, Binlog ver: 4"},
+			{126, 157, "Previous_gtids", ""},
+			{157, 236, "Gtid", gtidNext(1)},
+			{236, 311, "Query", "BEGIN"},
+			{311, 366, "Table_map", "table_id: 90 (test.t)"},
+			{366, 419, "Write_rows", "table_id: 90 flags: STMT_END_F"},
+			{419, 450, "Xid", "COMMIT /* xid=10 */"},
+			{450, 529, "Gtid", gtidNext(2)},
+			{529, 600, "Query", "CREATE TABLE t2 (id int)"},
+			{600, 650, "Rotate", "mysql-bin.000002;pos=4"},
+		},
+		"mysql-bin.000002": {
+			{4, 126, "Format_desc", "Server ver: 8.0.36, Binlog ver: 4"},
+			{126, 197, "Previous_gtids", testServerUUID + ":1-2"},
+			{197, 276, "Gtid", gtidNext(3)},
+			{276, 351, "Query", "BEGIN"},
+			{351, 400, "Xid", "COMMIT /* xid=20 */"},
+		},
+	}}
+	addr := serve(t, h)
+
+	conn, err := client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	for _, tc := range []struct {
+		pos  mysql.Position
+		gset string
+	}{
+		{mysql.Position{Name: "mysql-bin.000001", Pos: 157}, ""},
+		// in a transaction
+		{mysql.Position{Name: "mysql-bin.000001", Pos: 366}, ""},
+		{mysql.Position{Name: "mysql-bin.000001", Pos: 450}, testServerUUID + ":1"},
+		{mysql.Position{Name: "mysql-bin.000001", Pos: 650}, testServerUUID + ":1-2"},
+		{mysql.Position{Name: "mysql-bin.000002", Pos: 400}, testServerUUID + ":1-3"},
+	} {
+		gset, err := conn.GTIDSetAtPosition(tc.pos)
+		require.NoError(t, err, tc.pos)
+		require.Equal(t, tc.gset, gset.String(), tc.pos)
+	}
+
+	for _, tc := range []struct {
+		gset string
+		pos  mysql.Position
+	}{
+		{"", mysql.Position{Name: "mysql-bin.000001", Pos: 4}},
+		{testServerUUID + ":1", mysql.Position{Name: "mysql-bin.000001", Pos: 450}},
+		{testServerUUID + ":1-2", mysql.Position{Name: "mysql-bin.000001", Pos: 600}},
+		{testServerUUID + ":2-3", mysql.Position{Name: "mysql-bin.000002", Pos: 400}},
+	} {
+		gset, err := mysql.ParseMysqlGTIDSet(tc.gset)
+		require.NoError(t, err)
+		pos, err := conn.PositionOfGTIDSet(gset)
+		require.NoError(t, err, tc.gset)
+		require.Equal(t, tc.pos, pos, tc.gset)
+	}
+
+	gset, err := mysql.ParseMysqlGTIDSet(testServerUUID + ":1-4")
+	require.NoError(t, err)
+	_, err = conn.PositionOfGTIDSet(gset)
+	require.ErrorIs(t, err, client.ErrGTIDSetNotExecuted)
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// ErrGTIDWaitTimeout is returned by WaitForGTIDSet if the GTID set is not
+// executed within GTIDWaitOptions.Timeout.
+var ErrGTIDWaitTimeout = errors.New("timeout waiting for the GTID set")
+
+// DefaultGTIDWaitPollInterval is the default GTIDWaitOptions.PollInterval.
+var DefaultGTIDWaitPollInterval = time.Second
+
+// GTIDWaitOptions are the options of WaitForGTIDSet.
+type GTIDWaitOptions struct {
+	// Timeout bounds the wait, 0 waits until the context is done.
+	Timeout time.Duration
+	// PollInterval is how long each wait on the server lasts, the progress is
+	// reported and the context checked between them.
+	PollInterval time.Duration
+	// Progress is called after each wait that did not reach the GTID set.
+	Progress func(p GTIDWaitProgress)
+}
+
+// GTIDWaitProgress is the progress of WaitForGTIDSet.
+type GTIDWaitProgress struct {
+	// Executed is the GTID set executed by the server, gtid_executed for MySQL
+	// and gtid_slave_pos for MariaDB.
+	Executed mysql.GTIDSet
+	// Missing is the part of the GTID set not executed yet, only for MySQL.
+	Missing mysql.GTIDSet
+	Elapsed time.Duration
+}
+
+// WaitForGTIDSet waits until the server executed gset, with
+// WAIT_FOR_EXECUTED_GTID_SET for a MySQL GTID set or MASTER_GTID_WAIT for a
+// MariaDB one, usually to let a replica catch up before a failover or a
+// switchover. ctx is checked between the waits on the server, see
+// GTIDWaitOptions.PollInterval.
+func (c *Conn) WaitForGTIDSet(ctx context.Context, gset mysql.GTIDSet, opts GTIDWaitOptions) error {
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = DefaultGTIDWaitPollInterval
+	}
+
+	flavor, wait, executed := mysql.MySQLFlavor, "WAIT_FOR_EXECUTED_GTID_SET", "@@GLOBAL.gtid_executed"
+	if _, ok := gset.(*mysql.MariadbGTIDSet); ok {
+		flavor, wait, executed = mysql.MariaDBFlavor, "MASTER_GTID_WAIT", "@@GLOBAL.gtid_slave_pos"
+	}
+
+	start := time.Now()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		timeout := interval
+		if opts.Timeout > 0 {
+			left := opts.Timeout - time.Since(start)
+			if left <= 0 {
+				return errors.Annotatef(ErrGTIDWaitTimeout, "%s after %s", gset, opts.Timeout)
+			}
+			timeout = min(timeout, left)
+		}
+
+		// both return 0 once the set is executed
+		r, err := c.Execute(fmt.Sprintf("SELECT %s('%s', %.3f)", wait, mysql.Escape(gset.String()), timeout.Seconds()))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		n, err := r.GetInt(0, 0)
+		r.Close()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if n == 0 {
+			return nil
+		}
+
+		if opts.Progress != nil {
+			p, err := c.gtidWaitProgress(flavor, executed, gset)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			p.Elapsed = time.Since(start)
+			opts.Progress(p)
+		}
+	}
+}
+
+func (c *Conn) gtidWaitProgress(flavor string, executed string, gset mysql.GTIDSet) (GTIDWaitProgress, error) {
+	var p GTIDWaitProgress
+
+	r, err := c.Execute("SELECT " + executed)
+	if err != nil {
+		return p, errors.Trace(err)
+	}
+	defer r.Close()
+	s, err := r.GetString(0, 0)
+	if err != nil {
+		return p, errors.Trace(err)
+	}
+	if p.Executed, err = mysql.ParseGTIDSet(flavor, s); err != nil {
+		return p, errors.Trace(err)
+	}
+
+	if set, ok := gset.(*mysql.MysqlGTIDSet); ok {
+		missing := set.Clone().(*mysql.MysqlGTIDSet)
+		if err = missing.Minus(*p.Executed.(*mysql.MysqlGTIDSet)); err != nil {
+			return p, errors.Trace(err)
+		}
+		p.Missing = missing
+	}
+	return p, nil
+}
@@ -0,0 +1,92 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+const testServerUUID = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+
+// gtidWaitHandler executes the GTIDs one by one at each wait
+type gtidWaitHandler struct {
+	server.EmptyHandler
+	mu       sync.Mutex
+	executed int
+	target   int
+	waits    []string
+}
+
+func (h *gtidWaitHandler) HandleQuery(query string) (*mysql.Result, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var value interface{}
+	switch {
+	case strings.HasPrefix(query, "SELECT WAIT_FOR_EXECUTED_GTID_SET("):
+		h.waits = append(h.waits, query)
+		if h.executed < h.target {
+			h.executed++
+		}
+		value = int64(1)
+		if h.executed == h.target {
+			value = int64(0)
+		}
+	case query == "SELECT @@GLOBAL.gtid_executed":
+		value = testServerUUID + ":1-" + strconv.Itoa(h.executed)
+	default:
+		return nil, errors.New("unexpected query " + query)
+	}
+	rs, err := mysql.BuildSimpleTextResultset([]string{"v"}, [][]interface{}{{value}})
+	if err != nil {
+		return nil, err
+	}
+	return mysql.NewResult(rs), nil
+}
+
+func TestWaitForGTIDSet(t *testing.T) {
+	h := &gtidWaitHandler{executed: 1, target: 4}
+	addr := serve(t, h)
+
+	conn, err := client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	gset, err := mysql.ParseGTIDSet(mysql.MySQLFlavor, testServerUUID+":1-4")
+	require.NoError(t, err)
+
+	var missing []string
+	err = conn.WaitForGTIDSet(context.Background(), gset, client.GTIDWaitOptions{
+		PollInterval: 250 * time.Millisecond,
+		Progress: func(p client.GTIDWaitProgress) {
+			missing = append(missing, p.Missing.String())
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{testServerUUID + ":3-4", testServerUUID + ":4"}, missing)
+	require.Equal(t, "SELECT WAIT_FOR_EXECUTED_GTID_SET('"+testServerUUID+":1-4', 0.250)", h.waits[0])
+
+	// far ahead of the executed set
+	h.mu.Lock()
+	h.target = 1 << 20
+	h.mu.Unlock()
+	err = conn.WaitForGTIDSet(context.Background(), gset, client.GTIDWaitOptions{
+		Timeout:      10 * time.Millisecond,
+		PollInterval: time.Millisecond,
+	})
+	require.ErrorIs(t, err, client.ErrGTIDWaitTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, conn.WaitForGTIDSet(ctx, gset, client.GTIDWaitOptions{}), context.Canceled)
+}
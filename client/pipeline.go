@@ -0,0 +1,210 @@
+package client
+
+import (
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// Pipeline queues statements to send them together, without waiting for the
+// response of one before sending the next, and reads their responses in order
+// while they are sent, saving the round trips of the batches of independent
+// statements like the lookups of many keys, see Conn.Pipeline.
+//
+// The statements are executed in the session like one by one, a transaction
+// is not implied: a failing statement doesn't stop or roll back the others.
+// The warnings are not fetched, see WithWarnings, and pipelining isn't
+// supported with compression.
+type Pipeline struct {
+	c   *Conn
+	ops []pipelineOp
+
+	// called with each result of the queries, see ExecutePipelined
+	perResult ExecPerResultCallback
+}
+
+// PipelineResult is the response of a statement of a Pipeline.
+type PipelineResult struct {
+	// Result is the result of Execute and ExecuteStmt, the last one for the
+	// queries with several statements.
+	Result *mysql.Result
+	// Stmt is the statement prepared by Prepare.
+	Stmt *Stmt
+	// Err is the error of the statement.
+	Err error
+}
+
+type pipelineOp struct {
+	command byte
+	query   string
+	stmt    *Stmt
+	args    []interface{}
+}
+
+// Pipeline returns an empty pipeline of the connection, the connection is not
+// used until Run.
+func (c *Conn) Pipeline() *Pipeline {
+	return &Pipeline{c: c}
+}
+
+// Execute queues a query, without arguments: a query with arguments is executed
+// by Execute as a prepared statement, whose ID is needed to execute it, see
+// Prepare and ExecuteStmt.
+func (p *Pipeline) Execute(query string) {
+	p.ops = append(p.ops, pipelineOp{command: mysql.COM_QUERY, query: query})
+}
+
+// Prepare queues the preparation of a statement, which can be executed by the
+// next pipelines.
+func (p *Pipeline) Prepare(query string) {
+	p.ops = append(p.ops, pipelineOp{command: mysql.COM_STMT_PREPARE, query: query})
+}
+
+// ExecuteStmt queues the execution of a prepared statement.
+func (p *Pipeline) ExecuteStmt(s *Stmt, args ...interface{}) {
+	p.ops = append(p.ops, pipelineOp{command: mysql.COM_STMT_EXECUTE, query: s.query, stmt: s, args: args})
+}
+
+// Len returns the number of statements queued.
+func (p *Pipeline) Len() int {
+	return len(p.ops)
+}
+
+// Run sends the statements queued and returns their responses, in order. The
+// pipeline is emptied to be reused. The error returned is the error of the
+// connection, which can't be used anymore then, the responses not read have
+// it as Err.
+func (p *Pipeline) Run() ([]PipelineResult, error) {
+	c := p.c
+	ops := p.ops
+	p.ops = nil
+	if c.Compression != mysql.MYSQL_COMPRESS_NONE {
+		return nil, errors.New("pipelining is not supported with compression")
+	}
+	if len(ops) == 0 {
+		return nil, nil
+	}
+
+	results := make([]PipelineResult, len(ops))
+	sent := make([]bool, len(ops))
+	var events []*QueryEvent
+	if len(c.queryHooks) > 0 {
+		events = make([]*QueryEvent, len(ops))
+	}
+
+	if err := c.SetWriteBuffering(true); err != nil {
+		return nil, errors.Trace(err)
+	}
+	for i := range ops {
+		op := &ops[i]
+		if events != nil {
+			events[i] = c.beforeQuery(op.command, op.query, op.args)
+			op.query = events[i].Query
+		}
+		// the packets are buffered, the errors are those of the arguments
+		var err error
+		switch op.command {
+		case mysql.COM_QUERY:
+			err = c.execSend(op.query)
+		case mysql.COM_STMT_PREPARE:
+			err = c.writeCommandStr(mysql.COM_STMT_PREPARE, op.query)
+		case mysql.COM_STMT_EXECUTE:
+			err = op.stmt.write(op.args...)
+		}
+		if err != nil {
+			results[i].Err = errors.Trace(err)
+			if events != nil {
+				c.afterQuery(events[i], nil, results[i].Err)
+			}
+			continue
+		}
+		sent[i] = true
+	}
+
+	// written while the responses are read, the server would block writing
+	// them otherwise, with the rest of the statements not read yet
+	flushed := make(chan error, 1)
+	go func() {
+		flushed <- c.Flush()
+	}()
+
+	var err error
+	for i := range ops {
+		if !sent[i] {
+			continue
+		}
+		res := &results[i]
+		if err != nil {
+			res.Err = err
+			continue
+		}
+
+		// the response starts at 1, after the command
+		c.Sequence = 1
+		switch ops[i].command {
+		case mysql.COM_QUERY:
+			res.Result, res.Err = p.readQueryResults()
+		case mysql.COM_STMT_PREPARE:
+			res.Stmt, res.Err = c.readPrepareResponse(ops[i].query)
+		case mysql.COM_STMT_EXECUTE:
+			res.Result, res.Err = c.readResult(true)
+		}
+		if events != nil {
+			c.afterQuery(events[i], res.Result, res.Err)
+		}
+		if res.Err != nil && !isStatementError(res.Err) {
+			err = res.Err
+			// stops the flush too
+			c.Conn.Conn.Close()
+		}
+	}
+	if flushErr := <-flushed; err == nil {
+		err = flushErr
+	}
+	_ = c.SetWriteBuffering(false)
+	if err == nil {
+		err = c.evictStmts()
+	}
+	return results, errors.Trace(err)
+}
+
+// readQueryResults reads the results of a query and returns the last one.
+func (p *Pipeline) readQueryResults() (*mysql.Result, error) {
+	for {
+		r, err := p.c.readResult(false)
+		if p.perResult != nil {
+			p.perResult(r, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if r.Status&mysql.SERVER_MORE_RESULTS_EXISTS == 0 {
+			return r, nil
+		}
+	}
+}
+
+// isStatementError returns whether err is the error of a statement, after
+// which the connection can still be used.
+func isStatementError(err error) bool {
+	switch errors.Cause(err).(type) {
+	case *mysql.MyError, *ResultLimitError:
+		return true
+	}
+	return false
+}
+
+// ExecutePipelined executes the queries with a Pipeline, and calls
+// perResultCallback with each of their results, or errors, in order, the
+// queries with several statements having several results like with
+// ExecuteMultiple. A query failing doesn't stop the next ones, the error
+// returned is the error of the connection, which can't be used anymore then.
+func (c *Conn) ExecutePipelined(queries []string, perResultCallback ExecPerResultCallback) error {
+	p := c.Pipeline()
+	p.perResult = perResultCallback
+	for _, query := range queries {
+		p.Execute(query)
+	}
+	_, err := p.Run()
+	return err
+}
@@ -0,0 +1,118 @@
+package client_test
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+type pipelineHandler struct {
+	countRowsHandler
+}
+
+func (h pipelineHandler) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	if query != "ROWS ?" {
+		return 0, 0, nil, mysql.NewDefaultError(mysql.ER_PARSE_ERROR, "unknown", query, 1)
+	}
+	return 1, 1, nil, nil
+}
+
+func (h pipelineHandler) HandleStmtExecute(context interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	n, err := strconv.Atoi(fmt.Sprint(args[0]))
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]interface{}, n)
+	for i := range rows {
+		rows[i] = []interface{}{"0123456789"}
+	}
+	rs, err := mysql.BuildSimpleBinaryResultset([]string{"a"}, rows)
+	if err != nil {
+		return nil, err
+	}
+	return mysql.NewResult(rs), nil
+}
+
+func TestPipeline(t *testing.T) {
+	conn, err := client.Connect(serve(t, pipelineHandler{}), "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	p := conn.Pipeline()
+	p.Prepare("ROWS ?")
+	p.Prepare("bad")
+	p.Execute("3")
+	require.Equal(t, 3, p.Len())
+	results, err := p.Run()
+	require.NoError(t, err)
+	require.Equal(t, 0, p.Len())
+	require.Len(t, results, 3)
+	require.NoError(t, results[0].Err)
+	require.Equal(t, 1, results[0].Stmt.ParamNum())
+	code, _ := mysql.MyErrorCode(results[1].Err)
+	require.EqualValues(t, mysql.ER_PARSE_ERROR, code)
+	require.Nil(t, results[1].Stmt)
+	require.Equal(t, 3, results[2].Result.RowNumber())
+
+	s := results[0].Stmt
+	p.ExecuteStmt(s, 2)
+	p.ExecuteStmt(s)
+	p.ExecuteStmt(s, 4)
+	results, err = p.Run()
+	require.NoError(t, err)
+	require.Equal(t, 2, results[0].Result.RowNumber())
+	require.ErrorContains(t, results[1].Err, "argument mismatch")
+	require.Equal(t, 4, results[2].Result.RowNumber())
+
+	r, err := s.Execute(1)
+	require.NoError(t, err)
+	require.Equal(t, 1, r.RowNumber())
+}
+
+func TestExecutePipelined(t *testing.T) {
+	conn, err := client.Connect(serve(t, countRowsHandler{}), "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// more than the buffers of the sockets
+	queries := make([]string, 2000)
+	for i := range queries {
+		queries[i] = strconv.Itoa(i % 50)
+	}
+	queries[10] = "not a number"
+
+	var rows []int
+	var errs int
+	err = conn.ExecutePipelined(queries, func(r *mysql.Result, err error) {
+		if err != nil {
+			errs++
+			return
+		}
+		rows = append(rows, r.RowNumber())
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, errs)
+	require.Len(t, rows, len(queries)-1)
+	require.Equal(t, 9, rows[9])
+	require.Equal(t, 11, rows[10])
+	require.Equal(t, 49, rows[len(rows)-1])
+
+	r, err := conn.Execute("2")
+	require.NoError(t, err)
+	require.Equal(t, 2, r.RowNumber())
+
+	var results []*mysql.Result
+	conn.SetResultLimits(client.ResultLimits{MaxRows: 1})
+	err = conn.ExecutePipelined([]string{"1", "2", "1"}, func(r *mysql.Result, err error) {
+		results = append(results, r)
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	require.Nil(t, results[1])
+	require.NotNil(t, results[2])
+}
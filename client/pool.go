@@ -37,6 +37,17 @@ type (
 		idlePingTimeout  Timestamp
 		connect          func() (*Conn, error)
 
+		collector         PoolCollector
+		onConnStateChange func(conn *Conn, state ConnState)
+
+		// reset the session of the connections put back, see WithSessionReset
+		resetSession     bool
+		sessionResetMode SessionResetMode
+
+		// liveness check of the connections, COM_PING if nil, see WithHealthCheck
+		healthCheck        HealthCheck
+		healthCheckTimeout time.Duration
+
 		synchro struct {
 			sync.Mutex
 			idleConnections []Connection
@@ -122,8 +133,23 @@ func NewPoolWithOptions(
 			return Connect(addr, user, password, dbName, charset, po.connOptions...)
 		},
 
+		collector:         po.collector,
+		onConnStateChange: po.onConnStateChange,
+
+		resetSession:     po.resetSession,
+		sessionResetMode: po.sessionResetMode,
+
+		healthCheck:        po.healthCheck,
+		healthCheckTimeout: po.healthCheckTimeout,
+
 		readyConnection: make(chan Connection),
 	}
+	if pool.healthCheckTimeout <= 0 {
+		pool.healthCheckTimeout = defaultHealthCheckTimeout
+	}
+	if po.healthCheckInterval != nil {
+		pool.idlePingTimeout = healthCheckPeriod(*po.healthCheckInterval)
+	}
 
 	pool.ctx, pool.cancel = context.WithCancel(context.Background())
 
@@ -217,12 +243,26 @@ func (pool *Pool) GetConn(ctx context.Context) (*Conn, error) {
 			}
 		}
 
+		pool.connStateChanged(connection.conn, ConnStateActive)
 		return connection.conn, nil
 	}
 }
 
-// PutConn returns working connection back to pool
+// PutConn returns working connection back to pool, its prepared statements
+// are closed
 func (pool *Pool) PutConn(conn *Conn) {
+	if err := conn.CloseStmts(); err != nil {
+		pool.logger.Error("Pool: PutConn: close statements", slog.Any("error", err))
+		pool.closeConn(conn)
+		return
+	}
+	if pool.resetSession {
+		if err := conn.ResetSession(pool.sessionResetMode); err != nil {
+			pool.logger.Error("Pool: PutConn: reset session", slog.Any("error", err))
+			pool.closeConn(conn)
+			return
+		}
+	}
 	pool.putConnection(Connection{
 		conn:      conn,
 		lastUseAt: pool.nowTs(),
@@ -266,6 +306,12 @@ func (pool *Pool) getConnection(ctx context.Context) (Connection, error) {
 
 	// No idle connections are available
 
+	if pool.collector != nil {
+		defer func(start time.Time) {
+			pool.collector.ConnWaited(time.Since(start))
+		}(time.Now())
+	}
+
 	select {
 	case connection := <-pool.readyConnection:
 		return connection, nil
@@ -279,8 +325,10 @@ func (pool *Pool) putConnectionUnsafe(connection Connection) {
 	if len(pool.synchro.idleConnections) == cap(pool.synchro.idleConnections) {
 		pool.synchro.stats.TotalCount--
 		_ = connection.conn.Close() // Could it be more effective to close older connections?
+		pool.connClosed(connection.conn)
 	} else {
 		pool.synchro.idleConnections = append(pool.synchro.idleConnections, connection)
+		pool.connStateChanged(connection.conn, ConnStateIdle)
 	}
 }
 
@@ -358,6 +406,7 @@ func (pool *Pool) createNewConnection() (Connection, error) {
 	pool.synchro.stats.CreatedCount++
 	pool.synchro.Unlock()
 
+	pool.connCreated(connection.conn)
 	return connection, nil
 }
 
@@ -534,6 +583,7 @@ func (pool *Pool) closeConn(conn *Conn) {
 	pool.synchro.Unlock()
 
 	_ = conn.Close() // Closing is not an instant action, so do it outside the lock
+	pool.connClosed(conn)
 }
 
 func (pool *Pool) startNewConnections(count int) {
@@ -559,11 +609,19 @@ func (pool *Pool) startNewConnections(count int) {
 }
 
 func (pool *Pool) ping(conn *Conn) error {
-	deadline := utils.Now().Add(100 * time.Millisecond)
+	deadline := utils.Now().Add(pool.healthCheckTimeout)
 	_ = conn.SetDeadline(deadline)
-	err := conn.Ping()
+	var err error
+	if pool.healthCheck != nil {
+		err = pool.healthCheck(conn)
+	} else {
+		err = conn.Ping()
+	}
 	if err != nil {
 		pool.logger.Error("Pool: ping query fail", slog.Any("error", err))
+		if pool.collector != nil {
+			pool.collector.PingFailed(err)
+		}
 	} else {
 		_ = conn.SetDeadline(time.Time{})
 	}
@@ -581,6 +639,7 @@ func (pool *Pool) Close() {
 	for _, connection := range pool.synchro.idleConnections {
 		pool.synchro.stats.TotalCount--
 		_ = connection.conn.Close()
+		pool.connClosed(connection.conn)
 	}
 	pool.synchro.idleConnections = nil
 	pool.synchro.Unlock()
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"math"
+	"time"
+
+	"github.com/pingcap/errors"
+)
+
+// ErrReplicaLagging is returned by the ReplicaLagCheck of a replica lagging
+// behind its source more than the maximum lag.
+var ErrReplicaLagging = errors.New("replica lag exceeds the maximum")
+
+// HealthCheck checks that a connection of the pool can be used, the connection
+// is closed if it returns an error. The deadline of the connection is set to
+// the timeout of the check.
+type HealthCheck func(conn *Conn) error
+
+// defaultHealthCheckTimeout is the timeout of the health checks, see
+// WithHealthCheck.
+const defaultHealthCheckTimeout = 100 * time.Millisecond
+
+// WithHealthCheck replaces the liveness check of the pool, COM_PING by default,
+// by check, with the timeout, 100ms if <= 0. It checks the connections idle for
+// MaxIdleTimeoutWithoutPing, or the interval of WithHealthCheckInterval, before
+// they are returned by GetConn and periodically while they are idle. See
+// QueryCheck and ReplicaLagCheck.
+func WithHealthCheck(check HealthCheck, timeout time.Duration) PoolOption {
+	return func(o *poolOptions) {
+		o.healthCheck = check
+		o.healthCheckTimeout = timeout
+	}
+}
+
+// WithHealthCheckInterval checks the connections idle for interval instead of
+// MaxIdleTimeoutWithoutPing, with a precision of a second, or all the
+// connections GetConn returns if interval is 0.
+func WithHealthCheckInterval(interval time.Duration) PoolOption {
+	return func(o *poolOptions) {
+		o.healthCheckInterval = &interval
+	}
+}
+
+// QueryCheck returns a HealthCheck executing query, like SELECT 1, the check
+// fails if the query does.
+func QueryCheck(query string) HealthCheck {
+	return func(conn *Conn) error {
+		r, err := conn.Execute(query)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		r.Close()
+		return nil
+	}
+}
+
+// ReplicaLagCheck returns a HealthCheck evicting the connections to replicas
+// lagging more than maxLag, or whose lag can't be measured, like when the
+// replication is stopped. lag measures it, ReplicaLag if nil, see QueryLag.
+func ReplicaLagCheck(lag func(conn *Conn) (time.Duration, error), maxLag time.Duration) HealthCheck {
+	if lag == nil {
+		lag = ReplicaLag
+	}
+	return func(conn *Conn) error {
+		l, err := lag(conn)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if l > maxLag {
+			return errors.Annotatef(ErrReplicaLagging, "lag %s", l)
+		}
+		return nil
+	}
+}
+
+// QueryLag returns a lag function for ReplicaLagCheck and WithLagFunc
+// executing query, which returns the lag in seconds in the first column of its
+// first row, like
+//
+//	SELECT TIMESTAMPDIFF(MICROSECOND, MAX(ts), UTC_TIMESTAMP(6)) / 1e6 FROM heartbeat.heartbeat
+//
+// for a heartbeat table updated on the source like the one of pt-heartbeat.
+func QueryLag(query string) func(conn *Conn) (time.Duration, error) {
+	return func(conn *Conn) (time.Duration, error) {
+		r, err := conn.Execute(query)
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		defer r.Close()
+
+		if r.Resultset == nil || r.RowNumber() == 0 {
+			return 0, errors.New("no lag returned")
+		}
+		if isNull, _ := r.IsNull(0, 0); isNull {
+			return 0, errors.New("no lag returned")
+		}
+		seconds, err := r.GetFloat(0, 0)
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+}
+
+// healthCheckPeriod returns the idle time after which the connections are
+// checked, see WithHealthCheckInterval.
+func healthCheckPeriod(interval time.Duration) Timestamp {
+	if interval <= 0 {
+		// all the connections are older
+		return -1
+	}
+	return Timestamp(math.Ceil(interval.Seconds()))
+}
@@ -0,0 +1,76 @@
+package client_test
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+type lagHandler struct {
+	server.EmptyHandler
+	lag *atomic.Value
+}
+
+func (h *lagHandler) HandleQuery(query string) (*mysql.Result, error) {
+	lag, _ := h.lag.Load().(string)
+	var row []interface{}
+	if lag == "" {
+		row = []interface{}{nil}
+	} else {
+		row = []interface{}{lag}
+	}
+	r, err := mysql.BuildSimpleTextResultset([]string{"lag"}, [][]interface{}{row})
+	if err != nil {
+		return nil, err
+	}
+	return mysql.NewResult(r), nil
+}
+
+func TestPoolReplicaLagCheck(t *testing.T) {
+	lag := &atomic.Value{}
+	lag.Store("0.5")
+	addr := serve(t, &lagHandler{lag: lag})
+
+	var closed atomic.Int32
+	pool, err := client.NewPoolWithOptions(addr, "root", "", "", "",
+		client.WithPoolLimits(0, 2, 2),
+		client.WithHealthCheck(client.ReplicaLagCheck(client.QueryLag("SELECT lag"), time.Second), time.Second),
+		client.WithHealthCheckInterval(0),
+		client.WithOnConnStateChange(func(conn *client.Conn, state client.ConnState) {
+			if state == client.ConnStateClosed {
+				closed.Add(1)
+			}
+		}))
+	require.NoError(t, err)
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := pool.GetConn(ctx)
+	require.NoError(t, err)
+	pool.PutConn(conn)
+
+	// the replica is lagging, its connections are evicted
+	for _, v := range []string{strconv.Itoa(5), ""} {
+		lag.Store(v)
+		n := closed.Load()
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		_, err = pool.GetConn(ctx)
+		cancel()
+		require.Error(t, err)
+		require.Greater(t, closed.Load(), n)
+	}
+
+	lag.Store("0")
+	conn, err = pool.GetConn(ctx)
+	require.NoError(t, err)
+	pool.PutConn(conn)
+}
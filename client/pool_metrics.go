@@ -0,0 +1,111 @@
+package client
+
+import (
+	"expvar"
+	"time"
+)
+
+// ConnState is the state of a connection of a Pool, see WithOnConnStateChange.
+type ConnState int
+
+const (
+	// ConnStateNew is a connection just established.
+	ConnStateNew ConnState = iota
+	// ConnStateIdle is a connection waiting in the pool.
+	ConnStateIdle
+	// ConnStateActive is a connection returned by GetConn.
+	ConnStateActive
+	// ConnStateClosed is a connection closed by the pool.
+	ConnStateClosed
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case ConnStateNew:
+		return "new"
+	case ConnStateIdle:
+		return "idle"
+	case ConnStateActive:
+		return "active"
+	case ConnStateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// PoolCollector collects the metrics of a Pool, e.g. to export them to expvar
+// (see ExpvarCollector) or Prometheus. The methods are called from several
+// goroutines, and must not block.
+type PoolCollector interface {
+	// ConnCreated is called when a new connection is established.
+	ConnCreated()
+	// ConnClosed is called when the pool closes a connection.
+	ConnClosed()
+	// PingFailed is called when the check of an idle connection fails.
+	PingFailed(err error)
+	// ConnWaited is called when GetConn had to wait for a connection, with the
+	// time waited.
+	ConnWaited(d time.Duration)
+}
+
+// ExpvarCollector is a PoolCollector publishing the metrics as an expvar.Map
+// with the keys created, closed, ping_failures, waits and wait_ns.
+type ExpvarCollector struct {
+	m *expvar.Map
+
+	created      expvar.Int
+	closed       expvar.Int
+	pingFailures expvar.Int
+	waits        expvar.Int
+	waitTime     expvar.Int
+}
+
+// NewExpvarCollector publishes the metrics under name. Like expvar.Publish, it
+// panics if name is already used.
+func NewExpvarCollector(name string) *ExpvarCollector {
+	c := &ExpvarCollector{m: expvar.NewMap(name)}
+	c.m.Set("created", &c.created)
+	c.m.Set("closed", &c.closed)
+	c.m.Set("ping_failures", &c.pingFailures)
+	c.m.Set("waits", &c.waits)
+	c.m.Set("wait_ns", &c.waitTime)
+	return c
+}
+
+func (c *ExpvarCollector) ConnCreated() {
+	c.created.Add(1)
+}
+
+func (c *ExpvarCollector) ConnClosed() {
+	c.closed.Add(1)
+}
+
+func (c *ExpvarCollector) PingFailed(error) {
+	c.pingFailures.Add(1)
+}
+
+func (c *ExpvarCollector) ConnWaited(d time.Duration) {
+	c.waits.Add(1)
+	c.waitTime.Add(int64(d))
+}
+
+func (pool *Pool) connCreated(conn *Conn) {
+	if pool.collector != nil {
+		pool.collector.ConnCreated()
+	}
+	pool.connStateChanged(conn, ConnStateNew)
+}
+
+func (pool *Pool) connClosed(conn *Conn) {
+	if pool.collector != nil {
+		pool.collector.ConnClosed()
+	}
+	pool.connStateChanged(conn, ConnStateClosed)
+}
+
+func (pool *Pool) connStateChanged(conn *Conn, state ConnState) {
+	if pool.onConnStateChange != nil {
+		pool.onConnStateChange(conn, state)
+	}
+}
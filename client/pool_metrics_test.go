@@ -0,0 +1,55 @@
+package client_test
+
+import (
+	"context"
+	"expvar"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+func TestPoolCollector(t *testing.T) {
+	s := server.NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+
+	addr := serveConns(t, func(c net.Conn) (*server.Conn, error) {
+		return s.NewConn(c, "root", "", server.EmptyHandler{})
+	})
+
+	var m sync.Mutex
+	states := make(map[*client.Conn][]client.ConnState)
+	collector := client.NewExpvarCollector("test_pool_collector")
+
+	pool, err := client.NewPoolWithOptions(addr, "root", "", "", "",
+		client.WithPoolLimits(0, 1, 1),
+		client.WithCollector(collector),
+		client.WithOnConnStateChange(func(conn *client.Conn, state client.ConnState) {
+			m.Lock()
+			states[conn] = append(states[conn], state)
+			m.Unlock()
+		}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := pool.GetConn(ctx)
+	require.NoError(t, err)
+	pool.PutConn(conn)
+	pool.Close()
+
+	m.Lock()
+	require.Equal(t, []client.ConnState{client.ConnStateNew, client.ConnStateActive, client.ConnStateIdle, client.ConnStateClosed}, states[conn])
+	m.Unlock()
+
+	vars := expvar.Get("test_pool_collector").(*expvar.Map)
+	require.GreaterOrEqual(t, vars.Get("created").(*expvar.Int).Value(), int64(1))
+	require.Equal(t, vars.Get("created").String(), vars.Get("closed").String())
+	require.Equal(t, "1", vars.Get("waits").String())
+	require.Equal(t, "0", vars.Get("ping_failures").String())
+}
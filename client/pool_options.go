@@ -1,6 +1,7 @@
 package client
 
 import (
+	"crypto/tls"
 	"log/slog"
 	"time"
 )
@@ -21,6 +22,16 @@ type (
 		connOptions []Option
 
 		newPoolPingTimeout time.Duration
+
+		collector         PoolCollector
+		onConnStateChange func(conn *Conn, state ConnState)
+
+		resetSession     bool
+		sessionResetMode SessionResetMode
+
+		healthCheck         HealthCheck
+		healthCheckTimeout  time.Duration
+		healthCheckInterval *time.Duration
 	}
 )
 
@@ -59,3 +70,39 @@ func WithNewPoolPingTimeout(timeout time.Duration) PoolOption {
 		o.newPoolPingTimeout = timeout
 	}
 }
+
+// WithTLSSessionResumption makes the connections of the pool share a TLS session cache
+// of the given capacity (a default capacity if capacity < 1), so reconnects resume
+// the TLS sessions instead of doing full handshakes. See WithTLSSessionCache.
+func WithTLSSessionResumption(capacity int) PoolOption {
+	return func(o *poolOptions) {
+		o.connOptions = append(o.connOptions, WithTLSSessionCache(tls.NewLRUClientSessionCache(capacity)))
+	}
+}
+
+// WithCollector sets the collector of the pool metrics, see ExpvarCollector.
+func WithCollector(collector PoolCollector) PoolOption {
+	return func(o *poolOptions) {
+		o.collector = collector
+	}
+}
+
+// WithOnConnStateChange sets a callback called when a connection of the pool
+// changes state. It is called from several goroutines, and for some changes
+// while the pool is locked, so it must not call the methods of the pool.
+func WithOnConnStateChange(f func(conn *Conn, state ConnState)) PoolOption {
+	return func(o *poolOptions) {
+		o.onConnStateChange = f
+	}
+}
+
+// WithSessionReset makes PutConn reset the session of the connections with
+// mode, see WithSessionTracking and Conn.ResetSession, so the variables set do
+// not leak to the next user. A connection failing the reset is closed.
+func WithSessionReset(mode SessionResetMode) PoolOption {
+	return func(o *poolOptions) {
+		o.resetSession = true
+		o.sessionResetMode = mode
+		o.connOptions = append(o.connOptions, WithSessionTracking())
+	}
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"encoding/binary"
+	"net"
+
+	"github.com/pingcap/errors"
+)
+
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// WithProxyProtocolHeader sends a PROXY protocol v2 header before the handshake,
+// announcing src as the address of the client. dst is the announced server
+// address, the remote address of the connection is used if it's nil.
+func WithProxyProtocolHeader(src, dst *net.TCPAddr) Option {
+	return func(c *Conn) error {
+		if src == nil {
+			return errors.New("PROXY protocol source address is required")
+		}
+		c.proxySrc = src
+		c.proxyDst = dst
+		return nil
+	}
+}
+
+func (c *Conn) writeProxyHeader(conn net.Conn) error {
+	dst := c.proxyDst
+	if dst == nil {
+		addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+		if !ok {
+			return errors.Errorf("PROXY protocol needs a TCP connection, got %s", conn.RemoteAddr().Network())
+		}
+		dst = addr
+	}
+
+	header, err := encodeProxyHeaderV2(c.proxySrc, dst)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	_, err = conn.Write(header)
+	return errors.Trace(err)
+}
+
+func encodeProxyHeaderV2(src, dst *net.TCPAddr) ([]byte, error) {
+	srcIP, dstIP := src.IP.To4(), dst.IP.To4()
+	family := byte(0x11) // TCP over IPv4
+	if srcIP == nil || dstIP == nil {
+		// mixed families are sent as IPv6
+		srcIP, dstIP = src.IP.To16(), dst.IP.To16()
+		family = 0x21 // TCP over IPv6
+		if srcIP == nil || dstIP == nil {
+			return nil, errors.Errorf("invalid PROXY protocol addresses %s, %s", src, dst)
+		}
+	}
+
+	header := make([]byte, 0, len(proxyProtocolV2Signature)+4+2*len(srcIP)+4)
+	header = append(header, proxyProtocolV2Signature...)
+	header = append(header, 0x21, family) // version 2, PROXY command
+	header = binary.BigEndian.AppendUint16(header, uint16(2*len(srcIP)+4))
+	header = append(header, srcIP...)
+	header = append(header, dstIP...)
+	header = binary.BigEndian.AppendUint16(header, uint16(src.Port))
+	header = binary.BigEndian.AppendUint16(header, uint16(dst.Port))
+
+	return header, nil
+}
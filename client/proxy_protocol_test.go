@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeProxyHeaderV2(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 56324}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 3306}
+
+	header, err := encodeProxyHeaderV2(src, dst)
+	require.NoError(t, err)
+	expected := append([]byte(nil), proxyProtocolV2Signature...)
+	expected = append(expected, 0x21, 0x11, 0x00, 12, 10, 0, 0, 1, 10, 0, 0, 2, 0xdc, 0x04, 0x0c, 0xea)
+	require.Equal(t, expected, header)
+
+	// mixed families are sent as IPv6
+	dst = &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 3306}
+	header, err = encodeProxyHeaderV2(src, dst)
+	require.NoError(t, err)
+	require.Equal(t, byte(0x21), header[13])
+	require.Len(t, header, 16+36)
+}
+
+func TestConnWithProxyProtocolHeader(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	src := &net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 40000}
+	dst := &net.TCPAddr{IP: net.ParseIP("192.168.1.1"), Port: 3306}
+
+	received := make(chan []byte, 1)
+	go func() {
+		// the header must come before anything else, the server then hangs up
+		buf := make([]byte, 28)
+		_, err := io.ReadFull(serverConn, buf)
+		require.NoError(t, err)
+		received <- buf
+		serverConn.Close()
+	}()
+
+	dialer := func(context.Context, string, string) (net.Conn, error) { return clientConn, nil }
+	_, err := ConnectWithDialer(context.Background(), "tcp", "", "root", "", "", "", dialer, WithProxyProtocolHeader(src, dst))
+	require.Error(t, err)
+
+	expected, err := encodeProxyHeaderV2(src, dst)
+	require.NoError(t, err)
+	require.Equal(t, expected, <-received)
+}
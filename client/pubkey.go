@@ -0,0 +1,124 @@
+package client
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"sync"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// serverPublicKeys caches the RSA public keys of the servers by address, so the
+// full authentication of caching_sha2_password and sha256_password over plain TCP
+// does not need to fetch the key again.
+var serverPublicKeys sync.Map
+
+// WithServerPublicKey pins the RSA public key of the server (the file of its
+// caching_sha2_password_public_key_path or sha256_password_public_key_path), so
+// the password is encrypted with it over plain TCP instead of the key sent by
+// the server, like the server-public-key-path option of the mysql client.
+// pass to options when connect
+func WithServerPublicKey(pub *rsa.PublicKey) Option {
+	return func(c *Conn) error {
+		c.serverPubKey = pub
+		return nil
+	}
+}
+
+// WithServerPublicKeyFile is like WithServerPublicKey with a PEM file.
+// pass to options when connect
+func WithServerPublicKeyFile(path string) Option {
+	return func(c *Conn) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		pub, err := parsePublicKey(data)
+		if err != nil {
+			return errors.Annotatef(err, "server public key %s", path)
+		}
+		c.serverPubKey = pub
+		return nil
+	}
+}
+
+// ClearServerPublicKeyCache removes the cached public keys of all servers.
+func ClearServerPublicKeyCache() {
+	serverPublicKeys.Clear()
+}
+
+func parsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.Errorf("not a RSA public key: %T", pub)
+	}
+	return rsaPub, nil
+}
+
+// serverPublicKey returns the pinned or cached public key of the server, nil if
+// it has to be requested.
+func (c *Conn) serverPublicKey() *rsa.PublicKey {
+	if c.serverPubKey != nil {
+		return c.serverPubKey
+	}
+	if pub, ok := serverPublicKeys.Load(c.addr); ok {
+		c.usedCachedPubKey = true
+		return pub.(*rsa.PublicKey)
+	}
+	return nil
+}
+
+// cacheServerPublicKey parses and caches the public key sent by the server.
+func (c *Conn) cacheServerPublicKey(data []byte) (*rsa.PublicKey, error) {
+	pub, err := parsePublicKey(data)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	serverPublicKeys.Store(c.addr, pub)
+	return pub, nil
+}
+
+// writeCachingSha2FullAuth sends the password encrypted with the public key of
+// the server, requesting the key if it is not known.
+func (c *Conn) writeCachingSha2FullAuth() error {
+	pub := c.serverPublicKey()
+	if pub == nil {
+		// request public key
+		data := make([]byte, 4+1)
+		data[4] = 2 // cachingSha2PasswordRequestPublicKey
+		if err := c.WritePacket(data); err != nil {
+			return errors.Wrap(err, "WritePacket(single byte) failed")
+		}
+		data, err := c.ReadPacket()
+		if err != nil {
+			return errors.Wrap(err, "ReadPacket failed")
+		}
+		if len(data) == 0 {
+			return mysql.ErrMalformPacket
+		}
+		if pub, err = c.cacheServerPublicKey(data[1:]); err != nil {
+			return err
+		}
+	}
+	return c.WriteEncryptedPassword(c.password, c.salt, pub)
+}
+
+// forgetCachedPublicKey drops the cached key of the server if the authentication
+// with it failed, the key may have been rotated.
+func (c *Conn) forgetCachedPublicKey(err error) {
+	if c.usedCachedPubKey && mysql.IsAccessDenied(err) {
+		serverPublicKeys.Delete(c.addr)
+	}
+}
@@ -0,0 +1,70 @@
+package client_test
+
+import (
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+	"github.com/gongzhxu/go-mysql/test_util/test_keys"
+)
+
+// passwordProvider is not a server.InMemoryProvider, so the server does the full
+// caching_sha2_password authentication
+type passwordProvider struct{}
+
+func (passwordProvider) CheckUsername(username string) (bool, error) {
+	return username == "root", nil
+}
+
+func (passwordProvider) GetCredential(username string) (string, bool, error) {
+	return "secret", username == "root", nil
+}
+
+func TestServerPublicKeyCache(t *testing.T) {
+	tlsConf := server.NewServerTLSConfig(test_keys.CaPem, test_keys.CertPem, test_keys.KeyPem, tls.VerifyClientCertIfGiven)
+	newServer := func(pubKey []byte) *server.Server {
+		return server.NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_CACHING_SHA2_PASSWORD, pubKey, tlsConf)
+	}
+
+	var current atomic.Pointer[server.Server]
+	current.Store(newServer(test_keys.PubPem))
+
+	addr := serveConns(t, func(c net.Conn) (*server.Conn, error) {
+		return current.Load().NewCustomizedConn(c, passwordProvider{}, server.EmptyHandler{})
+	})
+
+	connect := func(options ...client.Option) error {
+		conn, err := client.Connect(addr, "root", "secret", "", "", options...)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return conn.Ping()
+	}
+
+	client.ClearServerPublicKeyCache()
+	require.NoError(t, connect())
+
+	// a server not sending its key, the cached key is used, and the full
+	// authentication happens again as the server has no password cache
+	current.Store(newServer(nil))
+	require.NoError(t, connect())
+
+	current.Store(newServer(nil))
+	client.ClearServerPublicKeyCache()
+	require.Error(t, connect())
+
+	// a pinned key
+	path := filepath.Join(t.TempDir(), "public_key.pem")
+	require.NoError(t, os.WriteFile(path, test_keys.PubPem, 0o600))
+	current.Store(newServer(nil))
+	require.NoError(t, connect(client.WithServerPublicKeyFile(path)))
+}
@@ -0,0 +1,207 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// Reconnect configures the reconnection of a connection, see WithReconnect.
+type Reconnect struct {
+	// MaxAttempts is the number of connection attempts after the connection is
+	// dropped, 3 by default.
+	MaxAttempts int
+	// Backoff is the wait before the second attempt, doubled after each
+	// attempt, 100ms by default. The first attempt is immediate.
+	Backoff time.Duration
+}
+
+// reconnectState is what is needed to connect again, and the statements
+// establishing the session.
+type reconnectState struct {
+	config  Reconnect
+	network string
+	dialer  Dialer
+	options []Option
+
+	statements []sessionStatement
+	reconnects int
+}
+
+// sessionStatement is a statement replayed after a reconnection, key is what
+// it sets: a later statement with the same key replaces it.
+type sessionStatement struct {
+	key   string
+	query string
+}
+
+// WithReconnect makes Execute reconnect after the connection is dropped, to the
+// server it was connected to, with its options. The statements establishing
+// the session executed by Execute, USE, SET NAMES and the SET of session and
+// user variables, like sql_mode, are recorded and replayed after reconnecting,
+// see SessionStatements, the database and charset set by UseDB and
+// SetCharset are kept too.
+//
+// The statement executed when the connection is dropped returns its error, it
+// may have been executed or not, unless it is executed by ExecuteIdempotent,
+// which executes it again. The transaction, temporary tables, locks and prepared
+// statements of the session are lost, the Stmt are prepared again when used.
+func WithReconnect(r Reconnect) Option {
+	return func(c *Conn) error {
+		if r.MaxAttempts <= 0 {
+			r.MaxAttempts = 3
+		}
+		if r.Backoff <= 0 {
+			r.Backoff = 100 * time.Millisecond
+		}
+		c.reconnect = &reconnectState{config: r}
+		return nil
+	}
+}
+
+// SessionStatements returns the statements replayed after a reconnection, in
+// order, see WithReconnect.
+func (c *Conn) SessionStatements() []string {
+	if c.reconnect == nil {
+		return nil
+	}
+	queries := make([]string, len(c.reconnect.statements))
+	for i, s := range c.reconnect.statements {
+		queries[i] = s.query
+	}
+	return queries
+}
+
+// Reconnects returns the number of reconnections of the connection, see
+// WithReconnect.
+func (c *Conn) Reconnects() int {
+	if c.reconnect == nil {
+		return 0
+	}
+	return c.reconnect.reconnects
+}
+
+// ExecuteIdempotent executes a statement like Execute, which can be executed
+// twice without harm, like a SELECT or an UPDATE setting a value: if the
+// connection is dropped, it is executed again after reconnecting, except in a
+// transaction, see WithReconnect.
+func (c *Conn) ExecuteIdempotent(command string, args ...interface{}) (*mysql.Result, error) {
+	if c.reconnect == nil {
+		return c.execute(command, args...)
+	}
+	return c.executeReconnecting(true, command, args...)
+}
+
+// executeReconnecting executes a statement, reconnecting if the connection is
+// dropped, and executing it again then if it is idempotent.
+func (c *Conn) executeReconnecting(idempotent bool, command string, args ...interface{}) (*mysql.Result, error) {
+	inTransaction := c.IsInTransaction()
+	r, err := c.execute(command, args...)
+	if err != nil && mysql.ErrorEqual(err, mysql.ErrBadConn) {
+		if rerr := c.reconnectSession(); rerr != nil {
+			return nil, errors.Annotatef(rerr, "reconnect after %v", err)
+		}
+		if !idempotent || inTransaction {
+			return nil, err
+		}
+		r, err = c.execute(command, args...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		c.recordSessionStatement(command)
+	}
+	return r, nil
+}
+
+// reconnectSession connects again and replays the session statements.
+func (c *Conn) reconnectSession() error {
+	state := c.reconnect
+	backoff := state.config.Backoff
+	var err error
+	for attempt := 0; attempt < state.config.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		var nc *Conn
+		nc, err = connectAddr(context.Background(), state.network, c.addr, c.user, c.password, c.db, c.charset, state.dialer, state.options...)
+		if err != nil {
+			if !isFailoverError(err) {
+				break
+			}
+			continue
+		}
+
+		_ = c.Conn.Close()
+		c.forgetStmts()
+		sessionVars := c.sessionVars
+		*c = *nc
+		c.reconnect = state
+		c.sessionVars = sessionVars
+		state.reconnects++
+
+		for _, s := range state.statements {
+			if _, err := c.exec(s.query); err != nil {
+				return errors.Annotatef(err, "replay %s", s.query)
+			}
+		}
+		return nil
+	}
+	return errors.Trace(err)
+}
+
+// recordSessionStatement records query if it establishes the session.
+func (c *Conn) recordSessionStatement(query string) {
+	key, ok := sessionStatementKey(query)
+	if !ok {
+		return
+	}
+	state := c.reconnect
+	statements := state.statements[:0]
+	for _, s := range state.statements {
+		if s.key != key {
+			statements = append(statements, s)
+		}
+	}
+	state.statements = append(statements, sessionStatement{key: key, query: query})
+}
+
+// forgetSessionVariables drops the SET of variables of the session statements,
+// after ResetSession.
+func (c *Conn) forgetSessionVariables() {
+	if c.reconnect == nil {
+		return
+	}
+	statements := c.reconnect.statements[:0]
+	for _, s := range c.reconnect.statements {
+		if !strings.HasPrefix(s.key, "SET ") {
+			statements = append(statements, s)
+		}
+	}
+	c.reconnect.statements = statements
+}
+
+// sessionStatementKey returns what query sets if it is a USE, a SET NAMES or
+// CHARACTER SET, or the SET of session or user variables.
+func sessionStatementKey(query string) (string, bool) {
+	q := strings.ToUpper(skipQueryComments(query))
+	switch {
+	case hasKeywordPrefix(q, "USE"):
+		return "USE", true
+	case hasKeywordPrefix(q, "SET"):
+		rest := strings.TrimLeft(q[3:], " \t\r\n")
+		if hasKeywordPrefix(rest, "NAMES") || hasKeywordPrefix(rest, "CHARACTER") || hasKeywordPrefix(rest, "CHARSET") {
+			return "NAMES", true
+		}
+		if names := parseSetVariables(query); len(names) > 0 {
+			return "SET " + strings.Join(names, ","), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,61 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+func TestReconnect(t *testing.T) {
+	h := &sessionHandler{}
+	conn, err := client.Connect(serve(t, h), "root", "", "", "", client.WithReconnect(client.Reconnect{}))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	for _, query := range []string{
+		"USE shop",
+		"SET NAMES utf8mb4",
+		"SET sql_mode = 'ANSI'",
+		"SET @x = 1",
+		"SELECT 1",
+		"SET SESSION sql_mode = ''",
+		"SET GLOBAL max_connections = 10",
+	} {
+		_, err = conn.Execute(query)
+		require.NoError(t, err, query)
+	}
+	replayed := []string{"USE shop", "SET NAMES utf8mb4", "SET @x = 1", "SET SESSION sql_mode = ''"}
+	require.Equal(t, replayed, conn.SessionStatements())
+
+	// the statement failing isn't executed again
+	id := conn.GetConnectionID()
+	conn.Conn.Conn.Close()
+	h.mu.Lock()
+	h.queries = nil
+	h.mu.Unlock()
+	_, err = conn.Execute("UPDATE t SET n = n + 1")
+	require.True(t, mysql.ErrorEqual(err, mysql.ErrBadConn))
+	require.Equal(t, 1, conn.Reconnects())
+	require.NotEqual(t, id, conn.GetConnectionID())
+	h.mu.Lock()
+	require.Equal(t, replayed, h.queries)
+	h.queries = nil
+	h.mu.Unlock()
+
+	// unless it is idempotent
+	conn.Conn.Conn.Close()
+	_, err = conn.ExecuteIdempotent("SELECT 2")
+	require.NoError(t, err)
+	require.Equal(t, 2, conn.Reconnects())
+	h.mu.Lock()
+	require.Equal(t, append(replayed, "SELECT 2"), h.queries)
+	h.mu.Unlock()
+
+	// the connection works again
+	_, err = conn.Execute("SELECT 3")
+	require.NoError(t, err)
+	require.Equal(t, "SELECT 3", h.lastQuery())
+}
@@ -2,10 +2,7 @@ package client
 
 import (
 	"bytes"
-	"crypto/rsa"
-	"crypto/x509"
 	"encoding/binary"
-	"encoding/pem"
 	"fmt"
 
 	"github.com/pingcap/errors"
@@ -38,7 +35,7 @@ func (c *Conn) handleOKPacket(data []byte) (*mysql.Result, error) {
 	var n int
 	pos := 1
 
-	r := mysql.NewResultReserveResultset(0)
+	r := c.newResult(0)
 
 	r.AffectedRows, _, n = mysql.LengthEncodedInt(data[pos:])
 	pos += n
@@ -85,7 +82,11 @@ func (c *Conn) handleErrorPacket(data []byte) error {
 	return e
 }
 
-func (c *Conn) handleAuthResult() error {
+func (c *Conn) handleAuthResult() (err error) {
+	defer func() {
+		c.forgetCachedPublicKey(err)
+	}()
+
 	data, switchToPlugin, err := c.readAuthResult()
 	if err != nil {
 		return fmt.Errorf("readAuthResult: %w", err)
@@ -120,6 +121,10 @@ func (c *Conn) handleAuthResult() error {
 		}
 	}
 
+	if p, ok := c.authPlugins[c.authPluginName]; ok {
+		return c.handlePluginAuthMoreData(p, data)
+	}
+
 	// handle caching_sha2_password
 	switch c.authPluginName {
 	case mysql.AUTH_CACHING_SHA2_PASSWORD:
@@ -137,7 +142,7 @@ func (c *Conn) handleAuthResult() error {
 					return err
 				}
 			} else {
-				if err = c.WritePublicKeyAuthPacket(c.password, c.salt); err != nil {
+				if err = c.writeCachingSha2FullAuth(); err != nil {
 					return err
 				}
 			}
@@ -150,13 +155,12 @@ func (c *Conn) handleAuthResult() error {
 		if len(data) == 0 {
 			return nil // auth already succeeded
 		}
-		block, _ := pem.Decode(data)
-		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		pub, err := c.cacheServerPublicKey(data)
 		if err != nil {
 			return err
 		}
 		// send encrypted password
-		err = c.WriteEncryptedPassword(c.password, c.salt, pub.(*rsa.PublicKey))
+		err = c.WriteEncryptedPassword(c.password, c.salt, pub)
 		if err != nil {
 			return err
 		}
@@ -285,7 +289,7 @@ func (c *Conn) readResultset(data []byte, binary bool) (*mysql.Result, error) {
 		return nil, mysql.ErrMalformPacket
 	}
 
-	result := mysql.NewResultReserveResultset(int(count))
+	result := c.newResult(int(count))
 
 	if err := c.readResultColumns(result); err != nil {
 		return nil, errors.Trace(err)
@@ -379,6 +383,7 @@ func (c *Conn) readResultColumns(result *mysql.Result) (err error) {
 
 func (c *Conn) readResultRows(result *mysql.Result, isBinary bool) (err error) {
 	var data []byte
+	var size int
 
 	for {
 		rawPkgLen := len(result.RawPkg)
@@ -405,6 +410,13 @@ func (c *Conn) readResultRows(result *mysql.Result, isBinary bool) (err error) {
 		}
 
 		result.RowDatas = append(result.RowDatas, data)
+		size += len(data)
+		if err = c.checkResultLimits(len(result.RowDatas), size); err != nil {
+			if skipErr := c.skipResultRows(); skipErr != nil {
+				return skipErr
+			}
+			return err
+		}
 	}
 
 	if cap(result.Values) < len(result.RowDatas) {
@@ -418,6 +430,11 @@ func (c *Conn) readResultRows(result *mysql.Result, isBinary bool) (err error) {
 		if err != nil {
 			return errors.Trace(err)
 		}
+		if c.convertCharset {
+			if err = mysql.ConvertRowToUTF8(result.Fields, result.Values[i]); err != nil {
+				return errors.Trace(err)
+			}
+		}
 	}
 
 	return nil
@@ -456,6 +473,11 @@ func (c *Conn) readResultRowsStreaming(result *mysql.Result, isBinary bool, perR
 		if err != nil {
 			return errors.Trace(err)
 		}
+		if c.convertCharset {
+			if err = mysql.ConvertRowToUTF8(result.Fields, row); err != nil {
+				return errors.Trace(err)
+			}
+		}
 
 		// Send the row to "userland" code
 		err = perRowCb(row)
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// ResultLimits limit the resultsets read by Execute and Stmt.Execute, 0 for no
+// limit, see WithResultLimits.
+type ResultLimits struct {
+	// MaxRows is the maximum number of rows of a resultset.
+	MaxRows int
+	// MaxBytes is the maximum size of the rows of a resultset, as sent by the
+	// server.
+	MaxBytes int
+}
+
+// ResultLimitError is the error of a resultset exceeding the ResultLimits.
+type ResultLimitError struct {
+	Limits ResultLimits
+	// Rows and Bytes are the rows read and their size when the limit was
+	// exceeded.
+	Rows  int
+	Bytes int
+}
+
+func (e *ResultLimitError) Error() string {
+	if e.Limits.MaxRows > 0 && e.Rows > e.Limits.MaxRows {
+		return fmt.Sprintf("resultset exceeds the limit of %d rows", e.Limits.MaxRows)
+	}
+	return fmt.Sprintf("resultset exceeds the limit of %d bytes", e.Limits.MaxBytes)
+}
+
+// WithResultLimits limits the size of the resultsets of every statement, to
+// protect from the SELECTs returning more rows than expected. The reading of a
+// resultset exceeding them stops with a *ResultLimitError, its rows are skipped
+// without being kept, so the connection can still be used. The streaming
+// SELECTs, which don't keep the rows, are not limited.
+func WithResultLimits(l ResultLimits) Option {
+	return func(c *Conn) error {
+		c.resultLimits = l
+		return nil
+	}
+}
+
+// SetResultLimits changes the limits of the resultsets of the next statements,
+// see WithResultLimits.
+func (c *Conn) SetResultLimits(l ResultLimits) {
+	c.resultLimits = l
+}
+
+// checkResultLimits returns a *ResultLimitError if a resultset of rows of bytes
+// exceeds the limits.
+func (c *Conn) checkResultLimits(rows, bytes int) error {
+	l := c.resultLimits
+	if l.MaxRows > 0 && rows > l.MaxRows || l.MaxBytes > 0 && bytes > l.MaxBytes {
+		return &ResultLimitError{Limits: l, Rows: rows, Bytes: bytes}
+	}
+	return nil
+}
+
+// skipResultRows reads the rest of the rows of a resultset without keeping
+// them, until the EOF packet, or an error packet.
+func (c *Conn) skipResultRows() error {
+	var data []byte
+	var err error
+	for {
+		data, err = c.ReadPacketReuseMem(data[:0])
+		if err != nil {
+			return err
+		}
+		if data[0] == mysql.ERR_HEADER {
+			return nil
+		}
+		if c.isEOFPacket(data) {
+			if c.capability&mysql.CLIENT_PROTOCOL_41 > 0 {
+				c.status = binary.LittleEndian.Uint16(data[3:])
+			}
+			return nil
+		}
+	}
+}
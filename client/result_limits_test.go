@@ -0,0 +1,67 @@
+package client_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/pingcap/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+type countRowsHandler struct {
+	server.EmptyHandler
+}
+
+// HandleQuery returns as many rows as the query, a number.
+func (h countRowsHandler) HandleQuery(query string) (*mysql.Result, error) {
+	n, err := strconv.Atoi(query)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]interface{}, n)
+	for i := range rows {
+		rows[i] = []interface{}{"0123456789"}
+	}
+	rs, err := mysql.BuildSimpleTextResultset([]string{"a"}, rows)
+	if err != nil {
+		return nil, err
+	}
+	return mysql.NewResult(rs), nil
+}
+
+func TestResultLimits(t *testing.T) {
+	conn, err := client.Connect(serve(t, countRowsHandler{}), "root", "", "", "",
+		client.WithResultLimits(client.ResultLimits{MaxRows: 3}))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	r, err := conn.Execute("3")
+	require.NoError(t, err)
+	require.Equal(t, 3, r.RowNumber())
+
+	_, err = conn.Execute("100")
+	var limitErr *client.ResultLimitError
+	require.ErrorAs(t, err, &limitErr)
+	require.Equal(t, 4, limitErr.Rows)
+	require.EqualError(t, errors.Cause(err), "resultset exceeds the limit of 3 rows")
+
+	// the rest of the rows were skipped
+	r, err = conn.Execute("2")
+	require.NoError(t, err)
+	require.Equal(t, 2, r.RowNumber())
+
+	// each row is 11 bytes
+	conn.SetResultLimits(client.ResultLimits{MaxBytes: 30})
+	_, err = conn.Execute("3")
+	require.ErrorAs(t, err, &limitErr)
+	require.Equal(t, 33, limitErr.Bytes)
+
+	conn.SetResultLimits(client.ResultLimits{})
+	r, err = conn.Execute("100")
+	require.NoError(t, err)
+	require.Equal(t, 100, r.RowNumber())
+}
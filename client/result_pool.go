@@ -0,0 +1,23 @@
+package client
+
+import "github.com/gongzhxu/go-mysql/mysql"
+
+// WithResultPool makes Execute return results from a pool, see
+// mysql.NewPooledResult, to not allocate them, their Resultset and their Field
+// for every statement, e.g. for point selects: a result returns to the pool
+// when it is closed, it must be closed once and not used after.
+func WithResultPool() Option {
+	return func(c *Conn) error {
+		c.pooledResults = true
+		return nil
+	}
+}
+
+// newResult returns a result of fieldCount fields, from the pool with
+// WithResultPool.
+func (c *Conn) newResult(fieldCount int) *mysql.Result {
+	if c.pooledResults {
+		return mysql.NewPooledResult(fieldCount)
+	}
+	return mysql.NewResultReserveResultset(fieldCount)
+}
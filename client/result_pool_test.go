@@ -0,0 +1,88 @@
+package client_test
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+// servePointSelect serves the resultset of a point select, one BIGINT column
+// and one row, to every command, without allocating on the server side so the
+// allocations of the benchmarks are the ones of the client.
+func servePointSelect(tb testing.TB) string {
+	var resp []byte
+	appendPacket := func(seq byte, data []byte) {
+		resp = binary.LittleEndian.AppendUint32(resp, uint32(len(data))|uint32(seq)<<24)
+		resp = append(resp, data...)
+	}
+	eof := []byte{mysql.EOF_HEADER, 0, 0, byte(mysql.SERVER_STATUS_AUTOCOMMIT), 0}
+	appendPacket(1, []byte{1})
+	appendPacket(2, (&mysql.Field{Name: []byte("id"), Type: mysql.MYSQL_TYPE_LONGLONG, Charset: 63}).Dump())
+	appendPacket(3, eof)
+	appendPacket(4, []byte{1, '1'})
+	appendPacket(5, eof)
+
+	return listen(tb, func(c net.Conn) {
+		conn, err := server.NewConn(c, "root", "", &server.EmptyHandler{})
+		if err != nil {
+			return
+		}
+		var buf []byte
+		for {
+			conn.ResetSequence()
+			if buf, err = conn.ReadPacketReuseMem(buf[:0]); err != nil {
+				return
+			}
+			if _, err = c.Write(resp); err != nil {
+				return
+			}
+		}
+	})
+}
+
+func TestResultPool(t *testing.T) {
+	conn, err := client.Connect(servePointSelect(t), "root", "", "", "", client.WithResultPool())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	for i := 0; i < 3; i++ {
+		r, err := conn.Execute("SELECT id FROM t WHERE id = 1")
+		require.NoError(t, err)
+		require.Equal(t, 1, r.RowNumber())
+		id, err := r.GetIntByName(0, "id")
+		require.NoError(t, err)
+		require.Equal(t, int64(1), id)
+		r.Close()
+	}
+}
+
+func BenchmarkExecutePointSelect(b *testing.B) {
+	b.Run("default", func(b *testing.B) {
+		benchmarkExecute(b)
+	})
+	b.Run("result pool", func(b *testing.B) {
+		benchmarkExecute(b, client.WithResultPool())
+	})
+}
+
+func benchmarkExecute(b *testing.B, options ...client.Option) {
+	conn, err := client.Connect(servePointSelect(b), "root", "", "", "", options...)
+	require.NoError(b, err)
+	defer conn.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r, err := conn.Execute("SELECT id FROM t WHERE id = 1")
+		if err != nil {
+			b.Fatal(err)
+		}
+		r.Close()
+	}
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// SessionResetMode is how ResetSession resets the session state.
+type SessionResetMode int
+
+const (
+	// SessionResetConnection sends COM_RESET_CONNECTION, which also drops the
+	// temporary tables, the prepared statements and the locks of the session.
+	SessionResetConnection SessionResetMode = iota
+	// SessionResetDefaults sets the session variables set back to DEFAULT and
+	// the user variables to NULL, keeping the rest of the session.
+	SessionResetDefaults
+)
+
+// WithSessionTracking records the session and user variables set by the SET
+// statements run by Execute, to reset them with ResetSession. The variables set
+// otherwise, like by SELECT @v := 1 or in a stored procedure, are not recorded.
+func WithSessionTracking() Option {
+	return func(c *Conn) error {
+		c.trackSession = true
+		return nil
+	}
+}
+
+// SessionVariables returns the session variables set since the connection or
+// the last ResetSession, the user variables with their @, see WithSessionTracking.
+func (c *Conn) SessionVariables() []string {
+	return append([]string(nil), c.sessionVars...)
+}
+
+// ResetSession resets the session with mode. SessionResetConnection always
+// resets it, as the temporary tables, prepared statements or locks are not
+// tracked, and sets the charset again. SessionResetDefaults resets the
+// variables recorded by the session tracking, nothing is done if there are
+// none.
+func (c *Conn) ResetSession(mode SessionResetMode) error {
+	switch mode {
+	case SessionResetConnection:
+		if err := c.writeCommand(mysql.COM_RESET_CONNECTION); err != nil {
+			return errors.Trace(err)
+		}
+		if _, err := c.readOK(); err != nil {
+			return errors.Trace(err)
+		}
+		c.forgetStmts()
+		if c.charset != "" {
+			if _, err := c.exec(fmt.Sprintf("SET NAMES %s", c.charset)); err != nil {
+				return errors.Trace(err)
+			}
+		}
+	case SessionResetDefaults:
+		if len(c.sessionVars) == 0 {
+			return nil
+		}
+		assignments := make([]string, len(c.sessionVars))
+		for i, name := range c.sessionVars {
+			if strings.HasPrefix(name, "@") {
+				assignments[i] = name + " = NULL"
+			} else {
+				assignments[i] = "SESSION " + name + " = DEFAULT"
+			}
+		}
+		if _, err := c.exec("SET " + strings.Join(assignments, ", ")); err != nil {
+			return errors.Trace(err)
+		}
+	default:
+		return errors.Errorf("invalid session reset mode %d", mode)
+	}
+	c.sessionVars = c.sessionVars[:0]
+	c.forgetSessionVariables()
+	return nil
+}
+
+// recordSessionVars records the variables set by query if it is a SET statement.
+func (c *Conn) recordSessionVars(query string) {
+	for _, name := range parseSetVariables(query) {
+		found := false
+		for _, v := range c.sessionVars {
+			if v == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.sessionVars = append(c.sessionVars, name)
+		}
+	}
+}
+
+// parseSetVariables returns the session and user variables assigned by a SET
+// statement, in lower case for the session variables. The global and persisted
+// variables, and SET NAMES, CHARACTER SET, TRANSACTION and PASSWORD are skipped.
+func parseSetVariables(query string) []string {
+	query = strings.TrimSpace(query)
+	if len(query) < 4 || !strings.EqualFold(query[:4], "SET ") {
+		return nil
+	}
+
+	var names []string
+	for _, assignment := range splitAssignments(query[4:]) {
+		lhs, _, ok := strings.Cut(assignment, "=")
+		if !ok {
+			// SET NAMES, TRANSACTION, etc.
+			continue
+		}
+		lhs = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(lhs), ":"))
+		fields := strings.Fields(lhs)
+		if len(fields) == 2 {
+			switch strings.ToUpper(fields[0]) {
+			case "SESSION", "LOCAL":
+				lhs = fields[1]
+			default:
+				// GLOBAL, PERSIST or PERSIST_ONLY
+				continue
+			}
+		} else if len(fields) != 1 {
+			continue
+		}
+
+		lower := strings.ToLower(lhs)
+		switch {
+		case strings.HasPrefix(lower, "@@session."):
+			lhs = lhs[len("@@session."):]
+		case strings.HasPrefix(lower, "@@local."):
+			lhs = lhs[len("@@local."):]
+		case strings.HasPrefix(lower, "@@global."), strings.HasPrefix(lower, "@@persist"):
+			continue
+		case strings.HasPrefix(lower, "@@"):
+			lhs = lhs[2:]
+		case strings.HasPrefix(lower, "@"):
+			names = append(names, lhs)
+			continue
+		}
+		switch name := strings.ToLower(strings.Trim(lhs, "`")); name {
+		case "names", "character", "password", "transaction":
+		default:
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// splitAssignments splits the assignments of a SET statement on the commas
+// outside of quotes and parentheses.
+func splitAssignments(s string) []string {
+	var parts []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case quote != 0:
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		case ch == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
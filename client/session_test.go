@@ -0,0 +1,103 @@
+package client_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+// sessionHandler records the queries and the COM_RESET_CONNECTION commands
+type sessionHandler struct {
+	server.EmptyHandler
+	mu      sync.Mutex
+	queries []string
+	resets  int
+}
+
+func (h *sessionHandler) HandleQuery(query string) (*mysql.Result, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.queries = append(h.queries, query)
+	return nil, nil
+}
+
+func (h *sessionHandler) HandleOtherCommand(cmd byte, data []byte) error {
+	if cmd != mysql.COM_RESET_CONNECTION {
+		return h.EmptyHandler.HandleOtherCommand(cmd, data)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.resets++
+	return nil
+}
+
+func (h *sessionHandler) lastQuery() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.queries[len(h.queries)-1]
+}
+
+func TestResetSession(t *testing.T) {
+	h := &sessionHandler{}
+	conn, err := client.Connect(serve(t, h), "root", "", "", "", client.WithSessionTracking())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	for _, query := range []string{
+		"SET SESSION sql_mode = 'ANSI_QUOTES,NO_ZERO_DATE', @x := (1, 2), GLOBAL max_connections = 10",
+		"set @@time_zone = '+00:00', @@session.sql_mode = DEFAULT, @@global.read_only = 1",
+		"SET NAMES utf8mb4",
+		"SET TRANSACTION ISOLATION LEVEL READ COMMITTED",
+		"SELECT 1",
+	} {
+		_, err = conn.Execute(query)
+		require.NoError(t, err)
+	}
+	require.Equal(t, []string{"sql_mode", "@x", "time_zone"}, conn.SessionVariables())
+
+	require.NoError(t, conn.ResetSession(client.SessionResetDefaults))
+	require.Equal(t, "SET SESSION sql_mode = DEFAULT, @x = NULL, SESSION time_zone = DEFAULT", h.lastQuery())
+	require.Empty(t, conn.SessionVariables())
+
+	// no variable to reset
+	last := h.lastQuery()
+	require.NoError(t, conn.ResetSession(client.SessionResetDefaults))
+	require.Equal(t, last, h.lastQuery())
+	// but maybe temporary tables or locks
+	require.NoError(t, conn.ResetSession(client.SessionResetConnection))
+	require.Equal(t, 1, h.resets)
+
+	_, err = conn.Execute("SET autocommit = 0")
+	require.NoError(t, err)
+	require.NoError(t, conn.ResetSession(client.SessionResetConnection))
+	require.Equal(t, 2, h.resets)
+	require.Empty(t, conn.SessionVariables())
+}
+
+func TestPoolSessionReset(t *testing.T) {
+	h := &sessionHandler{}
+	pool, err := client.NewPoolWithOptions(serve(t, h), "root", "", "", "",
+		client.WithPoolLimits(0, 1, 1),
+		client.WithSessionReset(client.SessionResetDefaults),
+	)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	conn, err := pool.GetConn(context.Background())
+	require.NoError(t, err)
+	_, err = conn.Execute("SET @user_id = 42")
+	require.NoError(t, err)
+	pool.PutConn(conn)
+	require.Equal(t, "SET @user_id = NULL", h.lastQuery())
+
+	conn, err = pool.GetConn(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, conn.SessionVariables())
+	pool.PutConn(conn)
+}
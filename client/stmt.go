@@ -1,9 +1,11 @@
 package client
 
 import (
+	"container/list"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"runtime"
 
@@ -13,12 +15,21 @@ import (
 )
 
 type Stmt struct {
-	conn *Conn
-	id   uint32
+	conn  *Conn
+	id    uint32
+	query string
 
 	params   int
 	columns  int
 	warnings int
+
+	// longData marks the params sent by SendLongData for the next execute
+	longData []bool
+
+	// elem is the element of the open statements of the connection, nil if the
+	// statement is deallocated, see WithMaxOpenStmts
+	elem   *list.Element
+	closed bool
 }
 
 func (s *Stmt) ParamNum() int {
@@ -33,15 +44,36 @@ func (s *Stmt) WarningsNum() int {
 	return s.warnings
 }
 
-func (s *Stmt) Execute(args ...interface{}) (*mysql.Result, error) {
+func (s *Stmt) Execute(args ...interface{}) (r *mysql.Result, err error) {
+	if len(s.conn.queryHooks) > 0 {
+		e := s.conn.beforeQuery(mysql.COM_STMT_EXECUTE, s.query, args)
+		defer func() { s.conn.afterQuery(e, r, err) }()
+	}
+
+	if err := s.prepare(); err != nil {
+		return nil, errors.Trace(err)
+	}
 	if err := s.write(args...); err != nil {
 		return nil, errors.Trace(err)
 	}
 
-	return s.conn.readResult(true)
+	r, err = s.conn.readResult(true)
+	if err != nil {
+		return nil, err
+	}
+	return s.conn.checkWarnings(r)
 }
 
-func (s *Stmt) ExecuteSelectStreaming(result *mysql.Result, perRowCb SelectPerRowCallback, perResCb SelectPerResultCallback, args ...interface{}) error {
+func (s *Stmt) ExecuteSelectStreaming(result *mysql.Result, perRowCb SelectPerRowCallback, perResCb SelectPerResultCallback, args ...interface{}) (err error) {
+	if len(s.conn.queryHooks) > 0 {
+		e := s.conn.beforeQuery(mysql.COM_STMT_EXECUTE, s.query, args)
+		perRowCb = countRows(e, perRowCb)
+		defer func() { s.conn.afterQuery(e, result, err) }()
+	}
+
+	if err := s.prepare(); err != nil {
+		return errors.Trace(err)
+	}
 	if err := s.write(args...); err != nil {
 		return errors.Trace(err)
 	}
@@ -49,7 +81,60 @@ func (s *Stmt) ExecuteSelectStreaming(result *mysql.Result, perRowCb SelectPerRo
 	return s.conn.readResultStreaming(true, result, perRowCb, perResCb)
 }
 
+// longDataChunkSize is the size of the value sent in each COM_STMT_SEND_LONG_DATA
+const longDataChunkSize = 1 << 20
+
+// SendLongData sends the value of the param paramIndex, read from r in chunks of
+// COM_STMT_SEND_LONG_DATA, so a large value is never held in memory at once. The
+// next Execute sends the param as a blob and ignores its arg. The server does
+// not reply to COM_STMT_SEND_LONG_DATA, its errors are returned by Execute.
+func (s *Stmt) SendLongData(paramIndex int, r io.Reader) error {
+	if paramIndex < 0 || paramIndex >= s.params {
+		return errors.Errorf("invalid param index %d, need less than %d", paramIndex, s.params)
+	}
+	if err := s.prepare(); err != nil {
+		return errors.Trace(err)
+	}
+
+	data := make([]byte, 4+7+longDataChunkSize)
+	data[4] = mysql.COM_STMT_SEND_LONG_DATA
+	binary.LittleEndian.PutUint32(data[5:], s.id)
+	binary.LittleEndian.PutUint16(data[9:], uint16(paramIndex))
+
+	for sent := false; ; sent = true {
+		n, err := io.ReadFull(r, data[11:])
+		// an empty value is sent too, so the param is not NULL
+		if n > 0 || !sent {
+			s.conn.ResetSequence()
+			if werr := s.conn.WritePacket(data[:11+n]); werr != nil {
+				return errors.Trace(werr)
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	if s.longData == nil {
+		s.longData = make([]bool, s.params)
+	}
+	s.longData[paramIndex] = true
+	return nil
+}
+
+// Close deallocates the statement, closing it again does nothing.
 func (s *Stmt) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.elem == nil {
+		return nil
+	}
+	s.conn.stmts.Remove(s.elem)
+	s.elem = nil
 	if err := s.conn.writeCommandUint32(mysql.COM_STMT_CLOSE, s.id); err != nil {
 		return errors.Trace(err)
 	}
@@ -59,7 +144,9 @@ func (s *Stmt) Close() error {
 
 // https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_stmt_execute.html
 func (s *Stmt) write(args ...interface{}) error {
-	defer clear(s.conn.queryAttributes)
+	defer func() { s.conn.queryAttributes = nil }()
+	// the server discards the long data once the statement is executed
+	defer func() { s.longData = nil }()
 	paramsNum := s.params
 
 	if len(args) != paramsNum {
@@ -91,6 +178,15 @@ func (s *Stmt) write(args ...interface{}) error {
 	var newParamBoundFlag byte = 0
 
 	for i := range args {
+		if s.longData != nil && s.longData[i] {
+			// the value was sent by SendLongData
+			newParamBoundFlag = 1
+			paramTypes[i] = []byte{mysql.MYSQL_TYPE_LONG_BLOB}
+			paramNames[i] = []byte{0}
+			paramFlags[i] = []byte{0}
+			continue
+		}
+
 		if args[i] == nil {
 			nullBitmap[i/8] |= 1 << (uint(i) % 8)
 			paramTypes[i] = []byte{mysql.MYSQL_TYPE_NULL}
@@ -175,6 +271,7 @@ func (s *Stmt) write(args ...interface{}) error {
 		paramFlags[i+paramsNum] = []byte{tf[1]}
 		paramValues[i+paramsNum] = qa.ValueBytes()
 		paramNames[i+paramsNum] = mysql.PutLengthEncodedString([]byte(qa.Name))
+		newParamBoundFlag = 1
 	}
 
 	data := utils.BytesBufferGet()
@@ -190,7 +287,7 @@ func (s *Stmt) write(args ...interface{}) error {
 	data.Write([]byte{byte(s.id), byte(s.id >> 8), byte(s.id >> 16), byte(s.id >> 24)})
 
 	flags := mysql.CURSOR_TYPE_NO_CURSOR
-	if paramsNum > 0 {
+	if paramsNum > 0 || (s.conn.capability&mysql.CLIENT_QUERY_ATTRIBUTES > 0 && qaLen > 0) {
 		flags |= mysql.PARAMETER_COUNT_AVAILABLE
 	}
 	data.WriteByte(flags)
@@ -232,11 +329,28 @@ func (s *Stmt) write(args ...interface{}) error {
 	return s.conn.WritePacket(data.Bytes())
 }
 
-func (c *Conn) Prepare(query string) (*Stmt, error) {
+func (c *Conn) Prepare(query string) (s *Stmt, err error) {
+	if len(c.queryHooks) > 0 {
+		e := c.beforeQuery(mysql.COM_STMT_PREPARE, query, nil)
+		query = e.Query
+		defer func() { c.afterQuery(e, nil, err) }()
+	}
+
 	if err := c.writeCommandStr(mysql.COM_STMT_PREPARE, query); err != nil {
 		return nil, errors.Trace(err)
 	}
+	s, err = c.readPrepareResponse(query)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.evictStmts(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return s, nil
+}
 
+// readPrepareResponse reads the response of the COM_STMT_PREPARE of query.
+func (c *Conn) readPrepareResponse(query string) (*Stmt, error) {
 	data, err := c.ReadPacket()
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -250,6 +364,7 @@ func (c *Conn) Prepare(query string) (*Stmt, error) {
 
 	s := new(Stmt)
 	s.conn = c
+	s.query = query
 
 	pos := 1
 
@@ -281,5 +396,6 @@ func (c *Conn) Prepare(query string) (*Stmt, error) {
 		}
 	}
 
+	c.trackStmt(s)
 	return s, nil
 }
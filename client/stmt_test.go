@@ -0,0 +1,143 @@
+package client_test
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+type stmtArgsRecorder struct {
+	server.EmptyHandler
+	args chan []interface{}
+}
+
+func (h *stmtArgsRecorder) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	return strings.Count(query, "?"), 0, nil, nil
+}
+
+func (h *stmtArgsRecorder) HandleStmtExecute(context interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	h.args <- append([]interface{}(nil), args...)
+	return nil, nil
+}
+
+func TestStmtSendLongData(t *testing.T) {
+	h := &stmtArgsRecorder{args: make(chan []interface{}, 1)}
+	addr := serve(t, h)
+
+	conn, err := client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	stmt, err := conn.Prepare("INSERT INTO t VALUES (?, ?, ?)")
+	require.NoError(t, err)
+	defer stmt.Close()
+
+	// more than one chunk
+	blob := bytes.Repeat([]byte("0123456789"), 300000)
+	require.NoError(t, stmt.SendLongData(1, bytes.NewReader(blob)))
+	require.NoError(t, stmt.SendLongData(2, bytes.NewReader(nil)))
+	_, err = stmt.Execute(1, nil, "ignored")
+	require.NoError(t, err)
+	args := <-h.args
+	require.EqualValues(t, 1, args[0])
+	require.Equal(t, blob, args[1])
+	require.Equal(t, []byte{}, args[2])
+
+	// the long data is only used once
+	_, err = stmt.Execute(2, "a", nil)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{int64(2), []byte("a"), nil}, <-h.args)
+
+	require.Error(t, stmt.SendLongData(3, bytes.NewReader(nil)))
+}
+
+// stmtTracker counts the statements open on the server by query.
+type stmtTracker struct {
+	server.EmptyHandler
+	mu       sync.Mutex
+	open     map[string]int
+	prepares int
+}
+
+func (h *stmtTracker) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.open[query]++
+	h.prepares++
+	return 0, 0, query, nil
+}
+
+func (h *stmtTracker) HandleStmtExecute(context interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	return nil, nil
+}
+
+func (h *stmtTracker) HandleStmtClose(context interface{}) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.open[context.(string)]--; h.open[context.(string)] == 0 {
+		delete(h.open, context.(string))
+	}
+	return nil
+}
+
+func (h *stmtTracker) state() (map[string]int, int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	open := make(map[string]int, len(h.open))
+	for q, n := range h.open {
+		open[q] = n
+	}
+	return open, h.prepares
+}
+
+func TestStmtTracking(t *testing.T) {
+	h := &stmtTracker{open: make(map[string]int)}
+	conn, err := client.Connect(serve(t, h), "root", "", "", "", client.WithMaxOpenStmts(2))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	a, err := conn.Prepare("SELECT 1")
+	require.NoError(t, err)
+	b, err := conn.Prepare("SELECT 2")
+	require.NoError(t, err)
+	// a is used last, b is evicted
+	_, err = a.Execute()
+	require.NoError(t, err)
+	c, err := conn.Prepare("SELECT 3")
+	require.NoError(t, err)
+	require.Equal(t, 2, conn.OpenStmts())
+	require.NoError(t, conn.Ping())
+	open, prepares := h.state()
+	require.Equal(t, map[string]int{"SELECT 1": 1, "SELECT 3": 1}, open)
+	require.Equal(t, 3, prepares)
+
+	// b is prepared again, evicting a
+	_, err = b.Execute()
+	require.NoError(t, err)
+	require.NoError(t, c.Reset())
+	open, prepares = h.state()
+	require.Equal(t, map[string]int{"SELECT 2": 1, "SELECT 3": 1}, open)
+	require.Equal(t, 4, prepares)
+
+	require.NoError(t, c.Close())
+	require.NoError(t, c.Close())
+	_, err = c.Execute()
+	require.ErrorIs(t, err, client.ErrStmtClosed)
+	require.Error(t, c.Reset())
+	require.Equal(t, 1, conn.OpenStmts())
+
+	// b is deallocated, a was already
+	require.NoError(t, conn.CloseStmts())
+	require.Equal(t, 0, conn.OpenStmts())
+	require.NoError(t, conn.Ping())
+	open, _ = h.state()
+	require.Empty(t, open)
+	require.NoError(t, a.Close())
+}
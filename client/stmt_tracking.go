@@ -0,0 +1,125 @@
+package client
+
+import (
+	"container/list"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// ErrStmtClosed is returned by the Stmt used after it is closed.
+var ErrStmtClosed = errors.New("statement closed")
+
+// WithMaxOpenStmts caps the prepared statements open on the connection to max,
+// to not exhaust max_prepared_stmt_count with long-lived connections, like the
+// ones of a Pool: preparing another statement deallocates the least recently
+// used one, which is prepared again the next time it is used. Zero, the
+// default, is no cap.
+func WithMaxOpenStmts(max int) Option {
+	return func(c *Conn) error {
+		if max < 0 {
+			return errors.Errorf("invalid max open statements %d", max)
+		}
+		c.maxStmts = max
+		return nil
+	}
+}
+
+// OpenStmts returns the number of prepared statements open on the server, the
+// ones neither closed nor deallocated, see WithMaxOpenStmts.
+func (c *Conn) OpenStmts() int {
+	if c.stmts == nil {
+		return 0
+	}
+	return c.stmts.Len()
+}
+
+// CloseStmts closes the open prepared statements, like Conn.Close and
+// Pool.PutConn do.
+func (c *Conn) CloseStmts() error {
+	for c.stmts != nil && c.stmts.Len() > 0 {
+		if err := c.stmts.Back().Value.(*Stmt).Close(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// Reset resets the statement with COM_STMT_RESET: the long data sent by
+// SendLongData is discarded and the cursor is closed.
+func (s *Stmt) Reset() error {
+	if err := s.prepare(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := s.conn.writeCommandUint32(mysql.COM_STMT_RESET, s.id); err != nil {
+		return errors.Trace(err)
+	}
+	if _, err := s.conn.readOK(); err != nil {
+		return errors.Trace(err)
+	}
+	s.longData = nil
+	return nil
+}
+
+// prepare makes s the most recently used statement, preparing it again if it
+// was deallocated.
+func (s *Stmt) prepare() error {
+	if s.closed {
+		return ErrStmtClosed
+	}
+	if s.elem != nil {
+		s.conn.stmts.MoveToFront(s.elem)
+		return nil
+	}
+	if s.longData != nil {
+		return errors.New("statement deallocated after SendLongData")
+	}
+
+	c := s.conn
+	if err := c.writeCommandStr(mysql.COM_STMT_PREPARE, s.query); err != nil {
+		return errors.Trace(err)
+	}
+	ns, err := c.readPrepareResponse(s.query)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	// s replaces ns in the open statements
+	s.id, s.params, s.columns, s.warnings = ns.id, ns.params, ns.columns, ns.warnings
+	s.elem = ns.elem
+	s.elem.Value = s
+	return c.evictStmts()
+}
+
+// trackStmt adds s to the open statements, as the most recently used one.
+func (c *Conn) trackStmt(s *Stmt) {
+	if c.stmts == nil {
+		c.stmts = list.New()
+	}
+	s.elem = c.stmts.PushFront(s)
+}
+
+// evictStmts deallocates the least recently used statements above the cap of
+// WithMaxOpenStmts.
+func (c *Conn) evictStmts() error {
+	for c.maxStmts > 0 && c.stmts != nil && c.stmts.Len() > c.maxStmts {
+		s := c.stmts.Remove(c.stmts.Back()).(*Stmt)
+		s.elem = nil
+		if err := c.writeCommandUint32(mysql.COM_STMT_CLOSE, s.id); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// forgetStmts marks the open statements deallocated by the server, after a
+// COM_RESET_CONNECTION or a reconnection, they are prepared again when used.
+func (c *Conn) forgetStmts() {
+	if c.stmts == nil {
+		return
+	}
+	for e := c.stmts.Front(); e != nil; e = e.Next() {
+		e.Value.(*Stmt).elem = nil
+	}
+	c.stmts.Init()
+}
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// ErrStreamStopped is returned by a streaming SELECT stopped by StreamControl.Stop.
+var ErrStreamStopped = errors.New("streaming select stopped")
+
+// StreamControl is the flow control of a streaming SELECT, see
+// ExecuteSelectStreamingControl. The rows are read one by one, so while the
+// stream is paused the connection is not read, and the server stops sending
+// rows when the buffers of the socket are full: a consumer feeding a slow
+// downstream pauses the stream instead of buffering the rows.
+//
+// The server aborts the query if it can't send for net_write_timeout seconds,
+// 60 by default, so the stream should not be paused longer.
+type StreamControl struct {
+	mu      sync.Mutex
+	paused  bool
+	stopped bool
+	// resume is closed by Resume and Stop to wake up the stream
+	resume chan struct{}
+}
+
+// NewStreamControl returns the control of a stream, not paused.
+func NewStreamControl() *StreamControl {
+	return &StreamControl{}
+}
+
+// Pause stops reading the rows after the callback being called returns, until
+// Resume. It can be called by the callbacks or from another goroutine.
+func (s *StreamControl) Pause() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.paused && !s.stopped {
+		s.paused = true
+		s.resume = make(chan struct{})
+	}
+}
+
+// Resume reads the next rows of a paused stream.
+func (s *StreamControl) Resume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paused {
+		s.paused = false
+		close(s.resume)
+	}
+}
+
+// Paused returns whether the stream is paused.
+func (s *StreamControl) Paused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+// Stop ends the stream with ErrStreamStopped after the callback being called
+// returns, or at once if it is paused. Like after an error of a callback, the
+// rest of the resultset is not read and the connection can't be used anymore.
+func (s *StreamControl) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	if s.paused {
+		s.paused = false
+		close(s.resume)
+	}
+}
+
+// wait blocks while the stream is paused.
+func (s *StreamControl) wait() error {
+	s.mu.Lock()
+	resume, paused := s.resume, s.paused
+	s.mu.Unlock()
+	if paused {
+		<-resume
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return ErrStreamStopped
+	}
+	return nil
+}
+
+// rowCallback returns perRowCb, waiting after it while the stream is paused.
+func (s *StreamControl) rowCallback(perRowCb SelectPerRowCallback) SelectPerRowCallback {
+	return func(row []mysql.FieldValue) error {
+		if err := perRowCb(row); err != nil {
+			return err
+		}
+		return s.wait()
+	}
+}
+
+// resultCallback returns perResCb, waiting after it while the stream is paused,
+// so the stream can be paused before its first row.
+func (s *StreamControl) resultCallback(perResCb SelectPerResultCallback) SelectPerResultCallback {
+	return func(result *mysql.Result) error {
+		if perResCb != nil {
+			if err := perResCb(result); err != nil {
+				return err
+			}
+		}
+		return s.wait()
+	}
+}
+
+// ExecuteSelectStreamingControl is ExecuteSelectStreaming with the flow control
+// ctrl, which the callbacks or another goroutine use to pause, resume or stop
+// reading the rows.
+func (c *Conn) ExecuteSelectStreamingControl(command string, result *mysql.Result, ctrl *StreamControl, perRowCallback SelectPerRowCallback, perResultCallback SelectPerResultCallback) error {
+	return c.ExecuteSelectStreaming(command, result, ctrl.rowCallback(perRowCallback), ctrl.resultCallback(perResultCallback))
+}
+
+// ExecuteSelectStreamingControl is ExecuteSelectStreaming with the flow control
+// ctrl, see Conn.ExecuteSelectStreamingControl.
+func (s *Stmt) ExecuteSelectStreamingControl(result *mysql.Result, ctrl *StreamControl, perRowCb SelectPerRowCallback, perResCb SelectPerResultCallback, args ...interface{}) error {
+	return s.ExecuteSelectStreaming(result, ctrl.rowCallback(perRowCb), ctrl.resultCallback(perResCb), args...)
+}
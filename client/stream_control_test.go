@@ -0,0 +1,80 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+type rowsHandler struct {
+	server.EmptyHandler
+}
+
+func (h *rowsHandler) HandleQuery(query string) (*mysql.Result, error) {
+	var rows [][]interface{}
+	for i := 1; i <= 5; i++ {
+		rows = append(rows, []interface{}{int64(i)})
+	}
+	r, err := mysql.BuildSimpleTextResultset([]string{"id"}, rows)
+	if err != nil {
+		return nil, err
+	}
+	return mysql.NewResult(r), nil
+}
+
+func TestStreamControl(t *testing.T) {
+	addr := serve(t, &rowsHandler{})
+	conn, err := client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctrl := client.NewStreamControl()
+	rows := make(chan int64, 10)
+	done := make(chan error, 1)
+	go func() {
+		var result mysql.Result
+		done <- conn.ExecuteSelectStreamingControl("SELECT id", &result, ctrl, func(row []mysql.FieldValue) error {
+			rows <- row[0].AsInt64()
+			if row[0].AsInt64() == 2 {
+				ctrl.Pause()
+			}
+			return nil
+		}, nil)
+	}()
+
+	require.Equal(t, int64(1), <-rows)
+	require.Equal(t, int64(2), <-rows)
+	require.True(t, ctrl.Paused())
+	select {
+	case <-rows:
+		require.Fail(t, "row read while paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	ctrl.Resume()
+	require.NoError(t, <-done)
+	require.Len(t, rows, 3)
+
+	// stopped while paused before the first row
+	conn, err = client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+	ctrl = client.NewStreamControl()
+	go func() {
+		var result mysql.Result
+		done <- conn.ExecuteSelectStreamingControl("SELECT id", &result, ctrl, func([]mysql.FieldValue) error {
+			return nil
+		}, func(*mysql.Result) error {
+			ctrl.Pause()
+			return nil
+		})
+	}()
+	require.Eventually(t, ctrl.Paused, time.Second, time.Millisecond)
+	ctrl.Stop()
+	require.ErrorIs(t, <-done, client.ErrStreamStopped)
+}
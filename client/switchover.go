@@ -0,0 +1,244 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+/*
+Switchover moves the primary role to one of its replicas for a planned
+maintenance of the primary, without losing transactions: the writes of the old
+primary are stopped before the target catches up and is promoted.
+
+Usage:
+	err := client.Switchover(ctx, client.SwitchoverConfig{
+		Primary:             primary,
+		Target:              target,
+		TargetAddr:          `10.0.0.2:3306`,
+		Replicas:            []*client.Conn{replica},
+		ReplicationUser:     `repl`,
+		ReplicationPassword: `replpwd`,
+		OnPromoted: func(ctx context.Context) error {
+			// move the VIP or the DNS record to the target
+			return nil
+		},
+	})
+*/
+
+// SwitchoverConfig is the configuration of Switchover.
+type SwitchoverConfig struct {
+	// Primary is the connection to the current primary, Target to the replica
+	// to promote and Replicas to the other replicas of Primary, repointed to
+	// Target. All use GTIDs.
+	Primary  *Conn
+	Target   *Conn
+	Replicas []*Conn
+
+	// TargetAddr is the address host:port the replicas connect to Target with,
+	// as ReplicationUser with ReplicationPassword.
+	TargetAddr          string
+	ReplicationUser     string
+	ReplicationPassword string
+
+	// DrainTimeout is how long the transactions running on Primary are waited
+	// for before it is set read only, they are rolled back by the server after
+	// it. They aren't waited for if 0.
+	DrainTimeout time.Duration
+	// Fence fences Primary instead of only setting it read only, see
+	// Conn.Fence, once its transactions are drained.
+	Fence *FenceOptions
+	// Wait are the options of the waits of Target and Replicas for the
+	// transactions of Primary, see WaitForGTIDSet.
+	Wait GTIDWaitOptions
+
+	// OnDemoted is called once Primary is read only, e.g. to remove its VIP,
+	// and OnPromoted once Target accepts the writes, e.g. to move the VIP or
+	// the DNS record of the primary to it. Switchover fails with their errors,
+	// OnPromoted is called before the replicas are repointed.
+	OnDemoted  func(ctx context.Context) error
+	OnPromoted func(ctx context.Context) error
+
+	// ReplicateFromTarget repoints Primary to Target too, as a replica.
+	ReplicateFromTarget bool
+}
+
+// Switchover makes cfg.Target the primary: it drains the transactions of
+// cfg.Primary and sets it read only, or fences it, waits for Target to execute its GTID set,
+// stops the replication of Target and lets it accept writes. The replicas of
+// cfg.Replicas are then repointed to Target once they executed the GTID set.
+// Primary is set writable again if the switchover fails before Target is
+// promoted.
+func Switchover(ctx context.Context, cfg SwitchoverConfig) error {
+	if cfg.Primary == nil || cfg.Target == nil {
+		return errors.New("switchover requires the primary and the target")
+	}
+	if (len(cfg.Replicas) > 0 || cfg.ReplicateFromTarget) && cfg.TargetAddr == "" {
+		return errors.New("switchover requires the address of the target to repoint the replicas")
+	}
+
+	if err := drainTransactions(ctx, cfg.Primary, cfg.DrainTimeout); err != nil {
+		return errors.Trace(err)
+	}
+	if err := demotePrimary(ctx, cfg); err != nil {
+		// it stays the primary, the target isn't promoted
+		_ = setReadOnly(cfg.Primary, false)
+		return errors.Trace(err)
+	}
+	gset, err := promoteTarget(ctx, cfg)
+	if err != nil {
+		// the target isn't promoted, the writes go on on the primary
+		_ = setReadOnly(cfg.Primary, false)
+		return errors.Trace(err)
+	}
+	if cfg.OnPromoted != nil {
+		if err = cfg.OnPromoted(ctx); err != nil {
+			return errors.Annotate(err, "switchover promoted hook")
+		}
+	}
+
+	replicas := cfg.Replicas
+	if cfg.ReplicateFromTarget {
+		replicas = append(replicas[:len(replicas):len(replicas)], cfg.Primary)
+	}
+	for _, replica := range replicas {
+		if err = replica.WaitForGTIDSet(ctx, gset, cfg.Wait); err != nil {
+			return errors.Annotatef(err, "wait for replica %s", replica.addr)
+		}
+		if err = replica.ChangeReplicationSource(cfg.TargetAddr, cfg.ReplicationUser, cfg.ReplicationPassword); err != nil {
+			return errors.Annotatef(err, "repoint replica %s", replica.addr)
+		}
+	}
+	return nil
+}
+
+// demotePrimary sets the primary of cfg read only, or fences it.
+func demotePrimary(ctx context.Context, cfg SwitchoverConfig) error {
+	if cfg.Fence == nil {
+		return setReadOnly(cfg.Primary, true)
+	}
+	_, err := cfg.Primary.Fence(ctx, *cfg.Fence)
+	return errors.Trace(err)
+}
+
+// promoteTarget waits for the target of cfg to execute the GTID set of the
+// read only primary, and promotes it. It returns the GTID set.
+func promoteTarget(ctx context.Context, cfg SwitchoverConfig) (mysql.GTIDSet, error) {
+	gset, err := cfg.Primary.executedGTIDSet()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if cfg.OnDemoted != nil {
+		if err = cfg.OnDemoted(ctx); err != nil {
+			return nil, errors.Annotate(err, "switchover demoted hook")
+		}
+	}
+	if err = cfg.Target.WaitForGTIDSet(ctx, gset, cfg.Wait); err != nil {
+		return nil, errors.Annotate(err, "wait for the target")
+	}
+
+	replica, _ := cfg.Target.replicaSyntax()
+	if _, err = cfg.Target.Execute("STOP " + replica); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if _, err = cfg.Target.Execute("RESET " + replica + " ALL"); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return gset, errors.Trace(setReadOnly(cfg.Target, false))
+}
+
+// drainTransactions waits up to timeout for the transactions running on the
+// server to end.
+func drainTransactions(ctx context.Context, conn *Conn, timeout time.Duration) error {
+	if timeout <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		// the transaction of conn itself isn't counted
+		r, err := conn.Execute("SELECT COUNT(*) FROM information_schema.innodb_trx WHERE trx_mysql_thread_id <> CONNECTION_ID()")
+		if err != nil {
+			return errors.Trace(err)
+		}
+		n, err := r.GetInt(0, 0)
+		r.Close()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if n == 0 {
+			return nil
+		}
+		if err = sleepContext(ctx, 100*time.Millisecond); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				// what's left is rolled back once read only
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// setReadOnly sets read_only, super_read_only is set off with it.
+func setReadOnly(conn *Conn, readOnly bool) error {
+	value := "OFF"
+	if readOnly {
+		value = "ON"
+	}
+	_, err := conn.Execute("SET GLOBAL read_only = " + value)
+	return errors.Trace(err)
+}
+
+// executedGTIDSet returns the GTID set executed by the server, gtid_executed
+// for MySQL and gtid_binlog_pos for MariaDB.
+func (c *Conn) executedGTIDSet() (mysql.GTIDSet, error) {
+	flavor, executed := mysql.MySQLFlavor, "@@GLOBAL.gtid_executed"
+	if strings.Contains(strings.ToLower(c.serverVersion), "mariadb") {
+		flavor, executed = mysql.MariaDBFlavor, "@@GLOBAL.gtid_binlog_pos"
+	}
+	r, err := c.Execute("SELECT " + executed)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer r.Close()
+	s, err := r.GetString(0, 0)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	gset, err := mysql.ParseGTIDSet(flavor, s)
+	return gset, errors.Trace(err)
+}
+
+// ChangeReplicationSource makes the server a replica of the source at addr
+// host:port, with GTID auto positioning, or the slave_pos GTID of MariaDB, and
+// restarts its replication.
+func (c *Conn) ChangeReplicationSource(addr string, user string, password string) error {
+	host, port, err := splitHostPort(addr)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	replica, source := c.replicaSyntax()
+	if _, err = c.Execute("STOP " + replica); err != nil {
+		return errors.Trace(err)
+	}
+	change, position := "CHANGE REPLICATION SOURCE TO", "SOURCE_AUTO_POSITION = 1"
+	if replica == "SLAVE" {
+		change, position = "CHANGE MASTER TO", "MASTER_AUTO_POSITION = 1"
+	}
+	if strings.Contains(strings.ToLower(c.serverVersion), "mariadb") {
+		position = "MASTER_USE_GTID = slave_pos"
+	}
+	query := fmt.Sprintf("%[1]s %[2]s_HOST = '%[3]s', %[2]s_PORT = %[4]d, %[2]s_USER = '%[5]s', %[2]s_PASSWORD = '%[6]s', %[7]s",
+		change, source, mysql.Escape(host), port, mysql.Escape(user), mysql.Escape(password), position)
+	if _, err = c.Execute(query); err != nil {
+		return errors.Trace(err)
+	}
+	_, err = c.Execute("START " + replica)
+	return errors.Trace(err)
+}
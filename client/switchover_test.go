@@ -0,0 +1,145 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+// switchoverHandler is a server of a switchover, with running transactions
+// ending one by one and the GTID set executed at the first wait unless lagging
+type switchoverHandler struct {
+	server.EmptyHandler
+	mu           sync.Mutex
+	transactions int64
+	lagging      bool
+	queries      []string
+}
+
+func (h *switchoverHandler) HandleQuery(query string) (*mysql.Result, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var value interface{}
+	switch {
+	case strings.HasPrefix(query, "SELECT COUNT(*) FROM information_schema.innodb_trx"):
+		value = h.transactions
+		if h.transactions > 0 {
+			h.transactions--
+		}
+	case query == "SELECT @@GLOBAL.gtid_executed":
+		value = testServerUUID + ":1-10"
+	case query == "SELECT @@GLOBAL.super_read_only":
+		value = int64(1)
+	case strings.HasPrefix(query, "SELECT ID FROM information_schema.processlist"):
+		h.queries = append(h.queries, "SELECT ID FROM information_schema.processlist")
+		rs, err := mysql.BuildSimpleTextResultset([]string{"ID"}, nil)
+		if err != nil {
+			return nil, err
+		}
+		return mysql.NewResult(rs), nil
+	case strings.HasPrefix(query, "SELECT WAIT_FOR_EXECUTED_GTID_SET("):
+		h.queries = append(h.queries, query)
+		value = int64(0)
+		if h.lagging {
+			value = int64(1)
+		}
+	default:
+		h.queries = append(h.queries, query)
+		return nil, nil
+	}
+	rs, err := mysql.BuildSimpleTextResultset([]string{"v"}, [][]interface{}{{value}})
+	if err != nil {
+		return nil, err
+	}
+	return mysql.NewResult(rs), nil
+}
+
+func (h *switchoverHandler) take() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	q := h.queries
+	h.queries = nil
+	return q
+}
+
+func TestSwitchover(t *testing.T) {
+	connect := func(h *switchoverHandler) *client.Conn {
+		conn, err := client.Connect(serve(t, h), "root", "", "", "")
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	primary := &switchoverHandler{transactions: 2}
+	target := &switchoverHandler{}
+	replica := &switchoverHandler{}
+
+	var hooks []string
+	cfg := client.SwitchoverConfig{
+		Primary:             connect(primary),
+		Target:              connect(target),
+		Replicas:            []*client.Conn{connect(replica)},
+		TargetAddr:          "10.0.0.2:3306",
+		ReplicationUser:     "repl",
+		ReplicationPassword: "it's",
+		DrainTimeout:        time.Second,
+		ReplicateFromTarget: true,
+		OnDemoted: func(ctx context.Context) error {
+			hooks = append(hooks, "demoted")
+			return nil
+		},
+		OnPromoted: func(ctx context.Context) error {
+			hooks = append(hooks, "promoted")
+			return nil
+		},
+	}
+	require.NoError(t, client.Switchover(context.Background(), cfg))
+	require.Equal(t, []string{"demoted", "promoted"}, hooks)
+	require.Zero(t, primary.transactions)
+
+	wait := "SELECT WAIT_FOR_EXECUTED_GTID_SET('" + testServerUUID + ":1-10', 1.000)"
+	repoint := []string{
+		wait,
+		"STOP SLAVE",
+		"CHANGE MASTER TO MASTER_HOST = '10.0.0.2', MASTER_PORT = 3306, MASTER_USER = 'repl', MASTER_PASSWORD = 'it\\'s', MASTER_AUTO_POSITION = 1",
+		"START SLAVE",
+	}
+	require.Equal(t, append([]string{"SET GLOBAL read_only = ON"}, repoint...), primary.take())
+	require.Equal(t, []string{wait, "STOP SLAVE", "RESET SLAVE ALL", "SET GLOBAL read_only = OFF"}, target.take())
+	require.Equal(t, repoint, replica.take())
+
+	// fenced
+	cfg.Fence = &client.FenceOptions{VerifyInterval: time.Millisecond}
+	require.NoError(t, client.Switchover(context.Background(), cfg))
+	require.Equal(t, []string{"SET GLOBAL super_read_only = ON", "SELECT ID FROM information_schema.processlist", repoint[0]}, primary.take()[:3])
+	target.take()
+	replica.take()
+	cfg.Fence = nil
+
+	// the primary is writable again if the target doesn't catch up
+	target.lagging = true
+	cfg.Wait = client.GTIDWaitOptions{Timeout: 10 * time.Millisecond, PollInterval: time.Millisecond}
+	err := client.Switchover(context.Background(), cfg)
+	require.ErrorIs(t, err, client.ErrGTIDWaitTimeout)
+	require.Equal(t, []string{"SET GLOBAL read_only = ON", "SET GLOBAL read_only = OFF"}, primary.take())
+	require.Empty(t, replica.take())
+
+	target.lagging = false
+	cfg.OnPromoted = func(ctx context.Context) error {
+		return errors.New("dns")
+	}
+	require.ErrorContains(t, client.Switchover(context.Background(), cfg), "dns")
+	require.Empty(t, replica.take())
+
+	cfg.TargetAddr = ""
+	require.Error(t, client.Switchover(context.Background(), cfg))
+}
@@ -0,0 +1,48 @@
+package client_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+// listen accepts the connections of a test server until the end of the test,
+// handling each with handle in a goroutine, and returns its address.
+func listen(tb testing.TB, handle func(c net.Conn)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(tb, err)
+	tb.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go handle(c)
+		}
+	}()
+	return l.Addr().String()
+}
+
+// serveConns serves the commands of the connections returned by newConn for
+// the connections accepted, see listen.
+func serveConns(tb testing.TB, newConn func(c net.Conn) (*server.Conn, error)) string {
+	return listen(tb, func(c net.Conn) {
+		conn, err := newConn(c)
+		if err != nil {
+			return
+		}
+		for conn.HandleCommand() == nil {
+		}
+	})
+}
+
+// serve serves the commands of the user root, without password, with h.
+func serve(tb testing.TB, h server.Handler) string {
+	return serveConns(tb, func(c net.Conn) (*server.Conn, error) {
+		return server.NewConn(c, "root", "", h)
+	})
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// Timeouts are the timeouts of a connection by category, 0 for none, see
+// WithTimeouts.
+type Timeouts struct {
+	// Connect limits the handshake and the authentication, after the dial whose
+	// timeout is the one of ConnectWithTimeout or of the Dialer.
+	Connect time.Duration
+	// Read and Write limit every read and write of a packet, see
+	// Conn.ReadTimeout and Conn.WriteTimeout.
+	Read  time.Duration
+	Write time.Duration
+	// Exec limits the statements of ExecuteContext, in addition to the deadline
+	// of the context.
+	Exec time.Duration
+}
+
+// WithTimeouts sets the timeouts of the connection.
+func WithTimeouts(t Timeouts) Option {
+	return func(c *Conn) error {
+		c.connectTimeout = t.Connect
+		c.ReadTimeout = t.Read
+		c.WriteTimeout = t.Write
+		c.execTimeout = t.Exec
+		return nil
+	}
+}
+
+// WithMaxExecutionTimeHint makes ExecuteContext add the optimizer hint
+// /*+ MAX_EXECUTION_TIME(ms) */ to the SELECT statements executed with a
+// deadline, so the server stops them when the client gives up on them instead
+// of running them to the end. The server returns ER_QUERY_TIMEOUT then. The hint
+// is only added for MySQL 5.7.8 and later, to the statements starting with
+// SELECT without one.
+func WithMaxExecutionTimeHint() Option {
+	return func(c *Conn) error {
+		c.maxExecutionTimeHint = true
+		return nil
+	}
+}
+
+// ExecuteContext executes a statement like Execute, limited by the deadline of
+// ctx and the exec timeout of WithTimeouts. If ctx is done before the statement
+// completes, the connection is closed and ctx.Err() is returned.
+func (c *Conn) ExecuteContext(ctx context.Context, command string, args ...interface{}) (*mysql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.execTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.execTimeout)
+		defer cancel()
+	}
+	if deadline, ok := ctx.Deadline(); ok && c.maxExecutionTimeHint && c.supportsMaxExecutionTime() {
+		command = addMaxExecutionTimeHint(command, time.Until(deadline))
+	}
+
+	stop := c.watchContext(ctx)
+	r, err := c.Execute(command, args...)
+	if stop() {
+		// the connection was closed, even if the statement completed
+		if r != nil {
+			r.Close()
+		}
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	return r, nil
+}
+
+// supportsMaxExecutionTime returns whether the server has the
+// MAX_EXECUTION_TIME hint, MariaDB has max_statement_time instead.
+func (c *Conn) supportsMaxExecutionTime() bool {
+	if strings.Contains(strings.ToLower(c.serverVersion), "mariadb") {
+		return false
+	}
+	cmp, err := c.CompareServerVersion("5.7.8")
+	return err == nil && cmp >= 0
+}
+
+// addMaxExecutionTimeHint adds the MAX_EXECUTION_TIME hint of d, rounded up to
+// the millisecond, to query if it is a SELECT statement without one.
+func addMaxExecutionTimeHint(query string, d time.Duration) string {
+	trimmed := strings.TrimLeft(query, " \t\r\n")
+	if len(trimmed) <= len("SELECT") || !strings.EqualFold(trimmed[:len("SELECT")], "SELECT") {
+		return query
+	}
+	switch trimmed[len("SELECT")] {
+	case ' ', '\t', '\r', '\n':
+	default:
+		return query
+	}
+	if strings.Contains(strings.ToUpper(query), "MAX_EXECUTION_TIME") {
+		return query
+	}
+
+	ms := (d + time.Millisecond - 1) / time.Millisecond
+	if ms < 1 {
+		ms = 1
+	}
+	return trimmed[:len("SELECT")] + " /*+ MAX_EXECUTION_TIME(" + strconv.FormatInt(int64(ms), 10) + ") */" + trimmed[len("SELECT"):]
+}
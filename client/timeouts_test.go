@@ -0,0 +1,86 @@
+package client_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+type timeoutHandler struct {
+	server.EmptyHandler
+	mu      sync.Mutex
+	queries []string
+}
+
+func (h *timeoutHandler) HandleQuery(query string) (*mysql.Result, error) {
+	h.mu.Lock()
+	h.queries = append(h.queries, query)
+	h.mu.Unlock()
+	if strings.Contains(query, "SLEEP") {
+		time.Sleep(time.Second)
+	}
+	return nil, nil
+}
+
+func (h *timeoutHandler) lastQuery() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.queries[len(h.queries)-1]
+}
+
+func TestExecuteContextMaxExecutionTimeHint(t *testing.T) {
+	h := &timeoutHandler{}
+	conn, err := client.Connect(serve(t, h), "root", "", "", "", client.WithMaxExecutionTimeHint())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = conn.ExecuteContext(ctx, " select 1")
+	require.NoError(t, err)
+	query := h.lastQuery()
+	require.True(t, strings.HasPrefix(query, "select /*+ MAX_EXECUTION_TIME("), query)
+	require.True(t, strings.HasSuffix(query, ") */ 1"), query)
+
+	for _, q := range []string{"UPDATE t SET a = 1", "SELECT /*+ MAX_EXECUTION_TIME(10) */ 1", "SELECT(1)"} {
+		_, err = conn.ExecuteContext(ctx, q)
+		require.NoError(t, err)
+		require.Equal(t, q, h.lastQuery())
+	}
+
+	// no deadline
+	_, err = conn.ExecuteContext(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+	require.Equal(t, "SELECT 1", h.lastQuery())
+}
+
+func TestTimeouts(t *testing.T) {
+	h := &timeoutHandler{}
+	addr := serve(t, h)
+	conn, err := client.Connect(addr, "root", "", "", "", client.WithTimeouts(client.Timeouts{Exec: 50 * time.Millisecond}))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.ExecuteContext(context.Background(), "SELECT SLEEP(1)")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the handshake of a server not answering
+	addr = listen(t, func(c net.Conn) {
+		time.Sleep(time.Second)
+		c.Close()
+	})
+	start := time.Now()
+	_, err = client.Connect(addr, "root", "", "", "", client.WithTimeouts(client.Timeouts{Connect: 50 * time.Millisecond}))
+	require.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+}
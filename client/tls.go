@@ -38,3 +38,39 @@ func NewClientTLSConfig(caPem, certPem, keyPem []byte, insecureSkipVerify bool,
 
 	return config
 }
+
+// WithTLSSessionCache sets the TLS session cache used by the connection, so a
+// connection to a server already connected to with the same cache can resume the
+// TLS session (or use a TLS 1.3 session ticket) instead of doing a full handshake.
+// The cache is only used if a TLS config is set and the config has no cache itself,
+// the config is not modified.
+// pass to options when connect
+func WithTLSSessionCache(cache tls.ClientSessionCache) Option {
+	return func(c *Conn) error {
+		c.tlsSessionCache = cache
+		return nil
+	}
+}
+
+// TLSConnectionState returns the state of the TLS connection, false if the
+// connection does not use TLS.
+func (c *Conn) TLSConnectionState() (tls.ConnectionState, bool) {
+	if c.Conn == nil {
+		return tls.ConnectionState{}, false
+	}
+	tlsConn, ok := c.Conn.Conn.(*tls.Conn)
+	if !ok {
+		return tls.ConnectionState{}, false
+	}
+	return tlsConn.ConnectionState(), true
+}
+
+// handshakeTLSConfig returns the TLS config to switch the connection to TLS with.
+func (c *Conn) handshakeTLSConfig() *tls.Config {
+	if c.tlsSessionCache == nil || c.tlsConfig.ClientSessionCache != nil {
+		return c.tlsConfig
+	}
+	config := c.tlsConfig.Clone()
+	config.ClientSessionCache = c.tlsSessionCache
+	return config
+}
@@ -0,0 +1,45 @@
+package client_test
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+	"github.com/gongzhxu/go-mysql/test_util/test_keys"
+)
+
+func TestTLSSessionCache(t *testing.T) {
+	tlsConf := server.NewServerTLSConfig(test_keys.CaPem, test_keys.CertPem, test_keys.KeyPem, tls.VerifyClientCertIfGiven)
+	s := server.NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, tlsConf)
+
+	addr := serveConns(t, func(c net.Conn) (*server.Conn, error) {
+		return s.NewConn(c, "root", "", server.EmptyHandler{})
+	})
+
+	config := &tls.Config{InsecureSkipVerify: true}
+	cache := tls.NewLRUClientSessionCache(0)
+	connect := func() tls.ConnectionState {
+		conn, err := client.Connect(addr, "root", "", "", "",
+			func(c *client.Conn) error {
+				c.SetTLSConfig(config)
+				return nil
+			},
+			client.WithTLSSessionCache(cache))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		require.NoError(t, conn.Ping())
+		state, ok := conn.TLSConnectionState()
+		require.True(t, ok)
+		return state
+	}
+
+	require.False(t, connect().DidResume)
+	require.True(t, connect().DidResume)
+	require.Nil(t, config.ClientSessionCache)
+}
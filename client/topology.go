@@ -0,0 +1,466 @@
+package client
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+/*
+Topology splits reads and writes between a primary and replica pools.
+
+Usage:
+	primary, _ := client.NewPoolWithOptions(`10.0.0.1:3306`, `username`, `userpwd`, `dbname`, ``)
+	replica, _ := client.NewPoolWithOptions(`10.0.0.2:3306`, `username`, `userpwd`, `dbname`, ``)
+	topo := client.NewTopology(primary, []*client.Pool{replica}, client.WithMaxReplicaLag(5*time.Second))
+	defer topo.Close()
+	topo.Execute(ctx, `SELECT * FROM t`)             // a replica
+	topo.Execute(ctx, `UPDATE t SET a = 1`)          // the primary
+	topo.Transaction(ctx, func(conn *client.Conn) error { ... }) // the primary
+*/
+
+// unknownLag is the lag of replicas that weren't checked yet or aren't replicating.
+const unknownLag = time.Duration(math.MaxInt64)
+
+// DefaultLagCheckInterval is how often Topology checks the lag of the replicas.
+var DefaultLagCheckInterval = 5 * time.Second
+
+type (
+	Topology struct {
+		logger *slog.Logger
+
+		primary  *Pool
+		replicas []*topologyReplica
+
+		maxLag           time.Duration
+		lagCheckInterval time.Duration
+		lagFunc          func(conn *Conn) (time.Duration, error)
+
+		mu   sync.Mutex
+		next int
+
+		ctx    context.Context
+		cancel context.CancelFunc
+		wg     sync.WaitGroup
+	}
+
+	topologyReplica struct {
+		pool *Pool
+		lag  time.Duration
+	}
+
+	TopologyOption func(t *Topology)
+)
+
+// WithMaxReplicaLag sets the maximal lag of a replica to be used for reads,
+// if all replicas lag more the reads go to the primary. 0 (the default)
+// disables the check and uses all replicas.
+func WithMaxReplicaLag(lag time.Duration) TopologyOption {
+	return func(t *Topology) {
+		t.maxLag = lag
+	}
+}
+
+// WithLagCheckInterval sets how often the lag of the replicas is checked.
+func WithLagCheckInterval(interval time.Duration) TopologyOption {
+	return func(t *Topology) {
+		t.lagCheckInterval = interval
+	}
+}
+
+// WithLagFunc replaces the lag check, by default Seconds_Behind_Source of
+// SHOW REPLICA STATUS, for example to read the timestamp of a heartbeat table.
+func WithLagFunc(f func(conn *Conn) (time.Duration, error)) TopologyOption {
+	return func(t *Topology) {
+		t.lagFunc = f
+	}
+}
+
+func WithTopologyLogger(logger *slog.Logger) TopologyOption {
+	return func(t *Topology) {
+		t.logger = logger
+	}
+}
+
+// NewTopology creates a Topology sending reads to the replicas and everything
+// else to the primary. The pools are owned by the Topology and closed by Close.
+func NewTopology(primary *Pool, replicas []*Pool, options ...TopologyOption) *Topology {
+	t := &Topology{
+		logger:           slog.Default(),
+		primary:          primary,
+		lagCheckInterval: DefaultLagCheckInterval,
+		lagFunc:          ReplicaLag,
+	}
+	for _, o := range options {
+		o(t)
+	}
+
+	for _, p := range replicas {
+		lag := time.Duration(0)
+		if t.maxLag > 0 {
+			// until the first check
+			lag = unknownLag
+		}
+		t.replicas = append(t.replicas, &topologyReplica{pool: p, lag: lag})
+	}
+
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+
+	if t.maxLag > 0 && len(t.replicas) > 0 {
+		t.checkLag()
+
+		t.wg.Add(1)
+		go t.lagChecker()
+	}
+
+	return t
+}
+
+// Primary returns the pool of the primary.
+func (t *Topology) Primary() *Pool {
+	return t.primary
+}
+
+// ReplicaLags returns the last measured lag of every replica, in the order
+// passed to NewTopology. The lag is math.MaxInt64 if unknown.
+func (t *Topology) ReplicaLags() []time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lags := make([]time.Duration, len(t.replicas))
+	for i, r := range t.replicas {
+		lags[i] = r.lag
+	}
+	return lags
+}
+
+// Execute runs the command on a replica if it is a read, see IsReadQuery, or on
+// the primary otherwise. Transactions must use Transaction, as consecutive calls
+// may use different connections.
+func (t *Topology) Execute(ctx context.Context, command string, args ...interface{}) (*mysql.Result, error) {
+	if isTransactionStatement(command) {
+		return nil, errors.Errorf("transaction statement %q must be run with Transaction", command)
+	}
+
+	if IsReadQuery(command) {
+		if pool := t.pickReplica(); pool != nil {
+			r, err := execOnPool(ctx, pool, command, args...)
+			if err == nil || !mysql.ErrorEqual(errors.Cause(err), mysql.ErrBadConn) {
+				return r, err
+			}
+			t.logger.Warn("Topology: replica connection failed, retrying on primary", slog.Any("error", err))
+		}
+	}
+
+	return execOnPool(ctx, t.primary, command, args...)
+}
+
+// Transaction runs f in a transaction on the primary. The transaction is
+// committed if f returns nil and rolled back otherwise.
+func (t *Topology) Transaction(ctx context.Context, f func(conn *Conn) error) error {
+	conn, err := t.primary.GetConn(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	if err = conn.Begin(); err != nil {
+		putConn(t.primary, conn, err)
+		return errors.Trace(err)
+	}
+
+	if err = f(conn); err != nil {
+		if rerr := conn.Rollback(); rerr != nil {
+			putConn(t.primary, conn, rerr)
+			return errors.Trace(err)
+		}
+		t.primary.PutConn(conn)
+		return err
+	}
+
+	err = conn.Commit()
+	putConn(t.primary, conn, err)
+	return errors.Trace(err)
+}
+
+// Close stops the lag checks and closes all pools.
+func (t *Topology) Close() {
+	t.cancel()
+	t.wg.Wait()
+
+	t.primary.Close()
+	for _, r := range t.replicas {
+		r.pool.Close()
+	}
+}
+
+func execOnPool(ctx context.Context, pool *Pool, command string, args ...interface{}) (*mysql.Result, error) {
+	conn, err := pool.GetConn(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	r, err := conn.Execute(command, args...)
+	if conn.IsInTransaction() {
+		// don't leak an open transaction to the next user of the connection
+		pool.DropConn(conn)
+	} else {
+		putConn(pool, conn, err)
+	}
+	return r, err
+}
+
+func putConn(pool *Pool, conn *Conn, err error) {
+	if err != nil && mysql.ErrorEqual(errors.Cause(err), mysql.ErrBadConn) {
+		pool.DropConn(conn)
+	} else {
+		pool.PutConn(conn)
+	}
+}
+
+// pickReplica returns the replica with the lowest lag within the limit, round
+// robin between replicas with the same lag, or nil if no replica can be used.
+func (t *Topology) pickReplica() *Pool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var best []*Pool
+	lowest := unknownLag
+	for _, r := range t.replicas {
+		if t.maxLag > 0 && r.lag > t.maxLag {
+			continue
+		}
+		if r.lag < lowest || best == nil {
+			best, lowest = best[:0], r.lag
+		}
+		if r.lag == lowest {
+			best = append(best, r.pool)
+		}
+	}
+
+	if len(best) == 0 {
+		return nil
+	}
+	t.next++
+	return best[t.next%len(best)]
+}
+
+func (t *Topology) lagChecker() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(t.lagCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-ticker.C:
+			t.checkLag()
+		}
+	}
+}
+
+func (t *Topology) checkLag() {
+	for _, r := range t.replicas {
+		lag, err := t.replicaLag(r.pool)
+		if err != nil {
+			t.logger.Warn("Topology: cannot get replica lag", slog.Any("error", err))
+			lag = unknownLag
+		}
+
+		t.mu.Lock()
+		r.lag = lag
+		t.mu.Unlock()
+	}
+}
+
+func (t *Topology) replicaLag(pool *Pool) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(t.ctx, t.lagCheckInterval)
+	defer cancel()
+
+	conn, err := pool.GetConn(ctx)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+
+	lag, err := t.lagFunc(conn)
+	putConn(pool, conn, err)
+	return lag, errors.Trace(err)
+}
+
+// ReplicaLag returns Seconds_Behind_Source of SHOW REPLICA STATUS, or
+// Seconds_Behind_Master of SHOW SLAVE STATUS on older servers. It returns an
+// error if the server isn't replicating.
+func ReplicaLag(conn *Conn) (time.Duration, error) {
+	column := "Seconds_Behind_Source"
+	r, err := conn.Execute("SHOW REPLICA STATUS")
+	if err != nil {
+		// MySQL < 8.0.22 and MariaDB < 10.5.1
+		column = "Seconds_Behind_Master"
+		if r, err = conn.Execute("SHOW SLAVE STATUS"); err != nil {
+			return 0, errors.Trace(err)
+		}
+	}
+	defer r.Close()
+
+	if r.RowNumber() == 0 {
+		return 0, errors.New("server is not a replica")
+	}
+	if _, ok := r.FieldNames[column]; !ok {
+		column = "Seconds_Behind_Master"
+	}
+
+	isNull, err := r.IsNullByName(0, column)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	if isNull {
+		return 0, errors.New("replication is not running")
+	}
+
+	secs, err := r.GetIntByName(0, column)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	return time.Duration(secs) * time.Second, nil
+}
+
+// IsReadQuery reports whether query is a SELECT which can run on a replica.
+// Locking reads and SELECT ... INTO are not.
+func IsReadQuery(query string) bool {
+	q := strings.ToUpper(skipQueryComments(query))
+	q = strings.TrimLeft(q, "(")
+
+	if !hasKeywordPrefix(q, "SELECT") {
+		return false
+	}
+
+	// the keywords are matched on the words of the query, whatever the
+	// whitespace and the comments between them
+	words := queryWords(q)
+	for i := range words {
+		switch {
+		case words[i] == "INTO",
+			hasWords(words[i:], "FOR", "UPDATE"),
+			hasWords(words[i:], "FOR", "SHARE"),
+			hasWords(words[i:], "LOCK", "IN", "SHARE", "MODE"):
+			return false
+		}
+	}
+	return true
+}
+
+func hasWords(words []string, prefix ...string) bool {
+	if len(words) < len(prefix) {
+		return false
+	}
+	for i, w := range prefix {
+		if words[i] != w {
+			return false
+		}
+	}
+	return true
+}
+
+// queryWords returns the unquoted words of query, skipping its comments and
+// its string literals and quoted identifiers. The content of the executable
+// comments is part of the query.
+func queryWords(query string) []string {
+	var words []string
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case isWordByte(c):
+			j := i
+			for j < len(query) && isWordByte(query[j]) {
+				j++
+			}
+			words = append(words, query[i:j])
+			i = j
+		case c == '\'' || c == '"' || c == '`':
+			i++
+			for i < len(query) && query[i] != c {
+				if query[i] == '\\' && c != '`' {
+					i++
+				}
+				i++
+			}
+			i++
+		case strings.HasPrefix(query[i:], "/*!"):
+			// the version of the executable comment, its end is skipped below
+			i += 3
+			for i < len(query) && query[i] >= '0' && query[i] <= '9' {
+				i++
+			}
+		case strings.HasPrefix(query[i:], "/*"):
+			j := strings.Index(query[i+2:], "*/")
+			if j < 0 {
+				return words
+			}
+			i += j + 4
+		case c == '#', strings.HasPrefix(query[i:], "-- "), strings.HasPrefix(query[i:], "--\t"):
+			j := strings.IndexByte(query[i:], '\n')
+			if j < 0 {
+				return words
+			}
+			i += j + 1
+		default:
+			i++
+		}
+	}
+	return words
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '$' || c >= 0x80
+}
+
+func isTransactionStatement(query string) bool {
+	q := strings.ToUpper(skipQueryComments(query))
+	return hasKeywordPrefix(q, "BEGIN") || hasKeywordPrefix(q, "START") || hasKeywordPrefix(q, "COMMIT") ||
+		hasKeywordPrefix(q, "ROLLBACK") || hasKeywordPrefix(q, "SAVEPOINT")
+}
+
+func hasKeywordPrefix(q string, keyword string) bool {
+	if !strings.HasPrefix(q, keyword) {
+		return false
+	}
+	if len(q) == len(keyword) {
+		return true
+	}
+	c := q[len(keyword)]
+	return !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_')
+}
+
+// skipQueryComments removes the leading whitespace and comments of query.
+func skipQueryComments(query string) string {
+	for {
+		query = strings.TrimLeft(query, " \t\r\n;")
+		switch {
+		case strings.HasPrefix(query, "/*!"), strings.HasPrefix(query, "/*+"):
+			// executable comments and optimizer hints are part of the statement
+			return query
+		case strings.HasPrefix(query, "/*"):
+			i := strings.Index(query, "*/")
+			if i < 0 {
+				return ""
+			}
+			query = query[i+2:]
+		case strings.HasPrefix(query, "-- "), strings.HasPrefix(query, "#"):
+			i := strings.IndexByte(query, '\n')
+			if i < 0 {
+				return ""
+			}
+			query = query[i+1:]
+		default:
+			return query
+		}
+	}
+}
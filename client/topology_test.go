@@ -0,0 +1,79 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsReadQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		read  bool
+	}{
+		{"SELECT 1", true},
+		{"  select * from t where id = ?", true},
+		{"/* comment */ SELECT a FROM t", true},
+		{"-- comment\nSELECT a FROM t", true},
+		{"(SELECT a FROM t) UNION (SELECT b FROM u)", true},
+		{"SELECT a FROM t FOR UPDATE", false},
+		{"SELECT a FROM t LOCK IN SHARE MODE", false},
+		{"SELECT a INTO @a FROM t", false},
+		{"SELECT a FROM t WHERE id = 1\nFOR UPDATE", false},
+		{"SELECT a FROM t\tFOR SHARE", false},
+		{"SELECT a FROM t LOCK  IN SHARE MODE", false},
+		{"SELECT a FROM t FOR\r\n  UPDATE NOWAIT", false},
+		{"SELECT a FROM t FOR /* x */ UPDATE", false},
+		{"SELECT a FROM t /*!80000 FOR SHARE */", false},
+		{"SELECT a\nINTO @a FROM t", false},
+		{"SELECT a FROM t WHERE b = ' FOR UPDATE'", true},
+		{"SELECT `into` FROM t -- FOR UPDATE", true},
+		{"SELECT a FROM t WHERE b = 'it\\'s FOR UPDATE'", true},
+		{"SELECT a FROM t AS format", true},
+		{"SELECTED", false},
+		{"INSERT INTO t SELECT * FROM u", false},
+		{"UPDATE t SET a = 1", false},
+		{"SHOW TABLES", false},
+		{"/* SELECT */ DELETE FROM t", false},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.read, IsReadQuery(tt.query), tt.query)
+	}
+
+	require.True(t, isTransactionStatement("begin"))
+	require.True(t, isTransactionStatement("START TRANSACTION READ ONLY"))
+	require.True(t, isTransactionStatement("/* x */ COMMIT"))
+	require.False(t, isTransactionStatement("STARTER"))
+	require.False(t, isTransactionStatement("SELECT 1"))
+}
+
+func TestTopologyPickReplica(t *testing.T) {
+	p1, p2, p3 := new(Pool), new(Pool), new(Pool)
+	topo := &Topology{
+		maxLag: 5 * time.Second,
+		replicas: []*topologyReplica{
+			{pool: p1, lag: time.Second},
+			{pool: p2, lag: 10 * time.Second},
+			{pool: p3, lag: time.Second},
+		},
+	}
+
+	// round robin between the replicas with the lowest lag
+	picked := map[*Pool]int{}
+	for i := 0; i < 6; i++ {
+		picked[topo.pickReplica()]++
+	}
+	require.Equal(t, map[*Pool]int{p1: 3, p3: 3}, picked)
+
+	topo.replicas[0].lag = unknownLag
+	require.Equal(t, p3, topo.pickReplica())
+
+	topo.replicas[2].lag = 6 * time.Second
+	require.Nil(t, topo.pickReplica())
+
+	// without a limit every replica is used
+	topo.maxLag = 0
+	require.Equal(t, p3, topo.pickReplica())
+}
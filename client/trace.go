@@ -0,0 +1,148 @@
+package client
+
+import (
+	"encoding/hex"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// QueryEvent describes a command sent by a Conn to a QueryHook.
+type QueryEvent struct {
+	// Command is mysql.COM_QUERY, COM_STMT_PREPARE or COM_STMT_EXECUTE
+	Command byte
+	// Query is the SQL text, the one of the statement for COM_STMT_EXECUTE
+	Query string
+	// Args are the args of COM_STMT_EXECUTE
+	Args  []interface{}
+	Start time.Time
+
+	// Duration, Rows, the number of rows of the result set, AffectedRows and Err
+	// are set for AfterQuery.
+	Duration     time.Duration
+	Rows         int
+	AffectedRows uint64
+	Err          error
+
+	// Data is kept from BeforeQuery to AfterQuery, e.g. for the span of the
+	// command.
+	Data interface{}
+}
+
+// QueryHook is called before and after each command of a Conn, see
+// WithQueryHook. It is to trace or log the queries, e.g. with OpenTelemetry.
+type QueryHook interface {
+	// BeforeQuery is called before the command is sent. It can change e.Query,
+	// e.g. to add a comment with AppendQueryComment.
+	BeforeQuery(e *QueryEvent)
+	// AfterQuery is called once the result is read, or the command failed.
+	AfterQuery(e *QueryEvent)
+}
+
+// WithQueryHook adds h to the hooks of the commands. The BeforeQuery methods are
+// called in the order the hooks are added, and the AfterQuery ones in reverse.
+func WithQueryHook(h QueryHook) Option {
+	return func(c *Conn) error {
+		c.queryHooks = append(c.queryHooks, h)
+		return nil
+	}
+}
+
+func (c *Conn) beforeQuery(command byte, query string, args []interface{}) *QueryEvent {
+	e := &QueryEvent{Command: command, Query: query, Args: args, Start: time.Now()}
+	for _, h := range c.queryHooks {
+		h.BeforeQuery(e)
+	}
+	return e
+}
+
+func (c *Conn) afterQuery(e *QueryEvent, r *mysql.Result, err error) {
+	e.Duration = time.Since(e.Start)
+	e.Err = err
+	if r != nil {
+		e.AffectedRows = r.AffectedRows
+		if r.Resultset != nil && e.Rows == 0 {
+			e.Rows = len(r.Values)
+		}
+	}
+	for i := len(c.queryHooks) - 1; i >= 0; i-- {
+		c.queryHooks[i].AfterQuery(e)
+	}
+}
+
+// countRows counts the rows streamed to perRowCb in e.Rows.
+func countRows(e *QueryEvent, perRowCb SelectPerRowCallback) SelectPerRowCallback {
+	return func(row []mysql.FieldValue) error {
+		e.Rows++
+		return perRowCb(row)
+	}
+}
+
+// QueryCommentHook is a QueryHook adding the tags returned by Tags to the
+// queries of COM_QUERY and COM_STMT_PREPARE, with AppendQueryComment. With the
+// traceparent of the span of the query, see Traceparent, it propagates the trace
+// context to the server, e.g. to its slow query log. The hooks added before can
+// pass the span in e.Data.
+type QueryCommentHook struct {
+	Tags func(e *QueryEvent) map[string]string
+}
+
+func (h *QueryCommentHook) BeforeQuery(e *QueryEvent) {
+	if e.Command == mysql.COM_QUERY || e.Command == mysql.COM_STMT_PREPARE {
+		e.Query = AppendQueryComment(e.Query, h.Tags(e))
+	}
+}
+
+func (h *QueryCommentHook) AfterQuery(*QueryEvent) {}
+
+// AppendQueryComment appends tags to query as a comment in the sqlcommenter
+// format, /*key='value',...*/ with the keys sorted and the values URL encoded,
+// before the ; ending the query. query is returned as is if there are no tags.
+func AppendQueryComment(query string, tags map[string]string) string {
+	if len(tags) == 0 {
+		return query
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	trimmed := strings.TrimRight(query, " \t\r\n;")
+	var b strings.Builder
+	b.Grow(len(query) + 32*len(tags))
+	b.WriteString(trimmed)
+	b.WriteString(" /*")
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(commentEscape(k))
+		b.WriteString("='")
+		b.WriteString(commentEscape(tags[k]))
+		b.WriteByte('\'')
+	}
+	b.WriteString("*/")
+	b.WriteString(query[len(trimmed):])
+	return b.String()
+}
+
+// commentEscape URL encodes s, with %20 for the spaces.
+func commentEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// Traceparent returns the W3C traceparent of a span, like
+// 00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01, for the traceparent
+// tag of AppendQueryComment.
+func Traceparent(traceID [16]byte, spanID [8]byte, sampled bool) string {
+	flags := "00"
+	if sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(traceID[:]) + "-" + hex.EncodeToString(spanID[:]) + "-" + flags
+}
@@ -0,0 +1,126 @@
+package client_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pingcap/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+// traceHandler returns 2 rows for SELECT, and fails FAIL
+type traceHandler struct {
+	server.EmptyHandler
+	mu      sync.Mutex
+	queries []string
+}
+
+func (h *traceHandler) result(query string) (*mysql.Result, error) {
+	h.mu.Lock()
+	h.queries = append(h.queries, query)
+	h.mu.Unlock()
+	if strings.HasPrefix(query, "FAIL") {
+		return nil, errors.New("failed")
+	}
+	if !strings.HasPrefix(query, "SELECT") {
+		return nil, nil
+	}
+	rs, err := mysql.BuildSimpleTextResultset([]string{"a"}, [][]interface{}{{1}, {2}})
+	return mysql.NewResult(rs), err
+}
+
+func (h *traceHandler) HandleQuery(query string) (*mysql.Result, error) {
+	return h.result(query)
+}
+
+func (h *traceHandler) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	h.mu.Lock()
+	h.queries = append(h.queries, query)
+	h.mu.Unlock()
+	return 1, 1, nil, nil
+}
+
+func (h *traceHandler) HandleStmtExecute(_ interface{}, query string, _ []interface{}) (*mysql.Result, error) {
+	rs, err := mysql.BuildSimpleBinaryResultset([]string{"a"}, [][]interface{}{{1}})
+	return mysql.NewResult(rs), err
+}
+
+func (h *traceHandler) HandleStmtClose(interface{}) error {
+	return nil
+}
+
+func (h *traceHandler) lastQuery() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.queries[len(h.queries)-1]
+}
+
+type recordHook struct {
+	before int
+	events []client.QueryEvent
+}
+
+func (h *recordHook) BeforeQuery(e *client.QueryEvent) {
+	h.before++
+	e.Data = h.before
+}
+
+func (h *recordHook) AfterQuery(e *client.QueryEvent) {
+	h.events = append(h.events, *e)
+}
+
+func TestQueryHooks(t *testing.T) {
+	h := &traceHandler{}
+	rec := &recordHook{}
+	traceparent := client.Traceparent([16]byte{0x0a, 0xf7}, [8]byte{0xb7}, true)
+	comment := &client.QueryCommentHook{Tags: func(e *client.QueryEvent) map[string]string {
+		return map[string]string{"traceparent": traceparent}
+	}}
+	conn, err := client.Connect(serve(t, h), "root", "", "", "",
+		client.WithQueryHook(rec), client.WithQueryHook(comment))
+	require.NoError(t, err)
+	defer conn.Close()
+	rec.events = nil
+
+	_, err = conn.Execute("SELECT a FROM t;")
+	require.NoError(t, err)
+	require.Equal(t, "SELECT a FROM t /*traceparent='"+traceparent+"'*/;", h.lastQuery())
+	e := rec.events[len(rec.events)-1]
+	require.Equal(t, mysql.COM_QUERY, e.Command)
+	require.Equal(t, 2, e.Rows)
+	require.NoError(t, e.Err)
+	require.Positive(t, e.Duration)
+	require.Equal(t, rec.before, e.Data)
+
+	_, err = conn.Execute("FAIL")
+	require.Error(t, err)
+	require.Error(t, rec.events[len(rec.events)-1].Err)
+
+	rec.events = nil
+	_, err = conn.Execute("SELECT ?", 7)
+	require.NoError(t, err)
+	require.Len(t, rec.events, 2)
+	require.Equal(t, mysql.COM_STMT_PREPARE, rec.events[0].Command)
+	require.Contains(t, rec.events[0].Query, "traceparent")
+	require.Equal(t, mysql.COM_STMT_EXECUTE, rec.events[1].Command)
+	require.Equal(t, []interface{}{7}, rec.events[1].Args)
+	require.Equal(t, 1, rec.events[1].Rows)
+
+	var result mysql.Result
+	err = conn.ExecuteSelectStreaming("SELECT a FROM t", &result, func([]mysql.FieldValue) error { return nil }, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, rec.events[len(rec.events)-1].Rows)
+}
+
+func TestAppendQueryComment(t *testing.T) {
+	require.Equal(t, "SELECT 1", client.AppendQueryComment("SELECT 1", nil))
+	require.Equal(t, "SELECT 1 /*action='a%20b%27c',route='%2Fusers'*/;\n",
+		client.AppendQueryComment("SELECT 1;\n", map[string]string{"route": "/users", "action": "a b'c"}))
+	require.Equal(t, "00-0af70000000000000000000000000000-b700000000000000-00",
+		client.Traceparent([16]byte{0x0a, 0xf7}, [8]byte{0xb7}, false))
+}
@@ -0,0 +1,214 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// TxOptions are the options of a transaction started by BeginTxContext.
+type TxOptions struct {
+	// Isolation is the isolation level of the transaction: "READ UNCOMMITTED",
+	// "READ COMMITTED", "REPEATABLE READ" or "SERIALIZABLE", in any case, empty
+	// for the level of the session.
+	Isolation string
+	ReadOnly  bool
+	// ConsistentSnapshot starts a consistent read at once, see START TRANSACTION
+	// WITH CONSISTENT SNAPSHOT.
+	ConsistentSnapshot bool
+}
+
+// Tx is a transaction started by BeginTxContext. The statements of the
+// transaction are executed on the Conn, Tx only manages the transaction and its
+// savepoints. It is not safe for concurrent use, like Conn.
+type Tx struct {
+	c *Conn
+
+	// savepoints are the names of the savepoints set, in order
+	savepoints []string
+	seq        int
+}
+
+// isolationLevels are the isolation levels of TxOptions in upper case, as
+// they can't be quoted in SET TRANSACTION.
+var isolationLevels = map[string]bool{
+	"READ UNCOMMITTED": true,
+	"READ COMMITTED":   true,
+	"REPEATABLE READ":  true,
+	"SERIALIZABLE":     true,
+}
+
+// BeginTxContext starts a transaction with opts. If ctx is done before the
+// transaction is started, the connection is closed and ctx.Err() is returned.
+func (c *Conn) BeginTxContext(ctx context.Context, opts TxOptions) (*Tx, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	stop := c.watchContext(ctx)
+	tx, err := c.beginTx(ctx, opts)
+	if stop() {
+		// the connection was closed, even if the transaction was started
+		return nil, ctx.Err()
+	}
+	return tx, err
+}
+
+func (c *Conn) beginTx(ctx context.Context, opts TxOptions) (*Tx, error) {
+	if opts.Isolation != "" {
+		level := strings.ToUpper(opts.Isolation)
+		if !isolationLevels[level] {
+			return nil, errors.Errorf("invalid isolation level %q", opts.Isolation)
+		}
+		if _, err := c.exec("SET TRANSACTION ISOLATION LEVEL " + level); err != nil {
+			return nil, contextError(ctx, err)
+		}
+	}
+
+	query := "START TRANSACTION"
+	sep := " "
+	if opts.ConsistentSnapshot {
+		query += sep + "WITH CONSISTENT SNAPSHOT"
+		sep = ", "
+	}
+	if opts.ReadOnly {
+		query += sep + "READ ONLY"
+	}
+	if _, err := c.exec(query); err != nil {
+		return nil, contextError(ctx, err)
+	}
+	return &Tx{c: c}, nil
+}
+
+// watchContext closes the connection if ctx is done before the returned
+// function is called, so a statement waiting for the server is interrupted.
+// The function waits for the watch to end and reports whether the connection
+// was closed, it can only be used again if not.
+func (c *Conn) watchContext(ctx context.Context) func() bool {
+	if ctx.Done() == nil {
+		return func() bool { return false }
+	}
+	done := make(chan struct{})
+	closed := make(chan bool, 1)
+	// the network connection, packet.Conn.Close resets the sequence of the
+	// statement in progress
+	netConn := c.Conn.Conn
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = netConn.Close()
+			closed <- true
+		case <-done:
+			closed <- false
+		}
+	}()
+	return func() bool {
+		close(done)
+		return <-closed
+	}
+}
+
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return errors.Trace(err)
+}
+
+// Conn returns the connection of the transaction.
+func (tx *Tx) Conn() *Conn {
+	return tx.c
+}
+
+// Execute executes a statement in the transaction, see Conn.Execute.
+func (tx *Tx) Execute(command string, args ...interface{}) (*mysql.Result, error) {
+	return tx.c.Execute(command, args...)
+}
+
+// Commit commits the transaction.
+func (tx *Tx) Commit() error {
+	tx.savepoints = nil
+	return tx.c.Commit()
+}
+
+// Rollback rolls the transaction back.
+func (tx *Tx) Rollback() error {
+	tx.savepoints = nil
+	return tx.c.Rollback()
+}
+
+// Savepoint sets a savepoint and returns its name, sp_1, sp_2, etc.
+func (tx *Tx) Savepoint() (string, error) {
+	tx.seq++
+	name := "sp_" + strconv.Itoa(tx.seq)
+	if _, err := tx.c.exec("SAVEPOINT " + name); err != nil {
+		return "", errors.Trace(err)
+	}
+	tx.savepoints = append(tx.savepoints, name)
+	return name, nil
+}
+
+// RollbackTo rolls the transaction back to the savepoint name, which is kept.
+// The savepoints set after it are removed, as the server does.
+func (tx *Tx) RollbackTo(name string) error {
+	i, err := tx.savepointIndex(name)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.c.exec("ROLLBACK TO SAVEPOINT " + name); err != nil {
+		return errors.Trace(err)
+	}
+	tx.savepoints = tx.savepoints[:i+1]
+	return nil
+}
+
+// Release removes the savepoint name and the ones set after it, without
+// rolling back.
+func (tx *Tx) Release(name string) error {
+	i, err := tx.savepointIndex(name)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.c.exec("RELEASE SAVEPOINT " + name); err != nil {
+		return errors.Trace(err)
+	}
+	tx.savepoints = tx.savepoints[:i]
+	return nil
+}
+
+// Savepoints returns the names of the savepoints of the transaction, in order.
+func (tx *Tx) Savepoints() []string {
+	return append([]string(nil), tx.savepoints...)
+}
+
+// Nested runs fn as a nested transaction: fn runs after a new savepoint, which
+// is released if fn succeeds, or rolled back to and released if fn fails. The
+// error of fn is returned.
+func (tx *Tx) Nested(fn func(tx *Tx) error) error {
+	name, err := tx.Savepoint()
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		if rerr := tx.RollbackTo(name); rerr != nil {
+			return errors.Annotatef(rerr, "rollback to savepoint %s after %v", name, err)
+		}
+		if rerr := tx.Release(name); rerr != nil {
+			return errors.Annotatef(rerr, "release savepoint %s after %v", name, err)
+		}
+		return err
+	}
+	return tx.Release(name)
+}
+
+func (tx *Tx) savepointIndex(name string) (int, error) {
+	for i := len(tx.savepoints) - 1; i >= 0; i-- {
+		if tx.savepoints[i] == name {
+			return i, nil
+		}
+	}
+	return -1, errors.Errorf("savepoint %s does not exist", name)
+}
@@ -0,0 +1,99 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+type queryRecorder struct {
+	server.EmptyHandler
+	mu      sync.Mutex
+	queries []string
+}
+
+func (h *queryRecorder) HandleQuery(query string) (*mysql.Result, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.queries = append(h.queries, query)
+	return nil, nil
+}
+
+func (h *queryRecorder) take() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	q := h.queries
+	h.queries = nil
+	return q
+}
+
+func TestTx(t *testing.T) {
+	h := &queryRecorder{}
+	addr := serve(t, h)
+
+	conn, err := client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.BeginTxContext(context.Background(), client.TxOptions{Isolation: "READ COMMITTED; DROP TABLE t"})
+	require.ErrorContains(t, err, "invalid isolation level")
+	require.Empty(t, h.take())
+
+	tx, err := conn.BeginTxContext(context.Background(), client.TxOptions{
+		Isolation:          "repeatable read",
+		ReadOnly:           true,
+		ConsistentSnapshot: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"SET TRANSACTION ISOLATION LEVEL REPEATABLE READ",
+		"START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY",
+	}, h.take())
+
+	sp1, err := tx.Savepoint()
+	require.NoError(t, err)
+	sp2, err := tx.Savepoint()
+	require.NoError(t, err)
+	require.Equal(t, []string{"sp_1", "sp_2"}, tx.Savepoints())
+	require.NoError(t, tx.RollbackTo(sp1))
+	require.Equal(t, []string{"sp_1"}, tx.Savepoints())
+	require.Error(t, tx.Release(sp2))
+
+	errFailed := errors.New("failed")
+	err = tx.Nested(func(tx *client.Tx) error {
+		_, err := tx.Execute("UPDATE t SET a = 1")
+		require.NoError(t, err)
+		return errFailed
+	})
+	require.ErrorIs(t, err, errFailed)
+	require.NoError(t, tx.Nested(func(tx *client.Tx) error { return nil }))
+	require.NoError(t, tx.Release(sp1))
+	require.Empty(t, tx.Savepoints())
+	require.NoError(t, tx.Commit())
+	require.Equal(t, []string{
+		"SAVEPOINT sp_1",
+		"SAVEPOINT sp_2",
+		"ROLLBACK TO SAVEPOINT sp_1",
+		"SAVEPOINT sp_3",
+		"UPDATE t SET a = 1",
+		"ROLLBACK TO SAVEPOINT sp_3",
+		"RELEASE SAVEPOINT sp_3",
+		"SAVEPOINT sp_4",
+		"RELEASE SAVEPOINT sp_4",
+		"RELEASE SAVEPOINT sp_1",
+		"COMMIT",
+	}, h.take())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = conn.BeginTxContext(ctx, client.TxOptions{})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Empty(t, h.take())
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// Warning is a row of SHOW WARNINGS.
+type Warning struct {
+	// Level is Note, Warning or Error
+	Level   string
+	Code    uint16
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s %d: %s", w.Level, w.Code, w.Message)
+}
+
+// WarningsError is the error of a statement with warnings, see
+// WithStrictWarnings.
+type WarningsError struct {
+	Warnings []Warning
+}
+
+func (e *WarningsError) Error() string {
+	s := make([]string, len(e.Warnings))
+	for i, w := range e.Warnings {
+		s[i] = w.String()
+	}
+	return "statement has warnings: " + strings.Join(s, "; ")
+}
+
+// WithWarnings fetches the warnings with SHOW WARNINGS after each statement of
+// Execute and Stmt.Execute reporting some, see Conn.Warnings.
+func WithWarnings() Option {
+	return func(c *Conn) error {
+		c.fetchWarnings = true
+		return nil
+	}
+}
+
+// WithStrictWarnings fetches the warnings like WithWarnings, and returns them as
+// a *WarningsError. The statement is executed though, a transaction has to be
+// rolled back by the caller. Notes are not errors.
+func WithStrictWarnings() Option {
+	return func(c *Conn) error {
+		c.fetchWarnings = true
+		c.strictWarnings = true
+		return nil
+	}
+}
+
+// Warnings returns the warnings of the last statement, fetched if WithWarnings
+// or WithStrictWarnings is used.
+func (c *Conn) Warnings() []Warning {
+	return c.warnings
+}
+
+// ShowWarnings returns the warnings of the last statement, with SHOW WARNINGS.
+func (c *Conn) ShowWarnings() ([]Warning, error) {
+	r, err := c.exec("SHOW WARNINGS")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer r.Close()
+
+	warnings := make([]Warning, r.RowNumber())
+	for i := range warnings {
+		w := &warnings[i]
+		if w.Level, err = r.GetString(i, 0); err != nil {
+			return nil, errors.Trace(err)
+		}
+		code, err := r.GetUint(i, 1)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		w.Code = uint16(code)
+		if w.Message, err = r.GetString(i, 2); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return warnings, nil
+}
+
+// checkWarnings fetches the warnings of the result r of a statement if enabled,
+// and returns them as an error in strict mode.
+func (c *Conn) checkWarnings(r *mysql.Result) (*mysql.Result, error) {
+	if !c.fetchWarnings {
+		return r, nil
+	}
+	c.warnings = nil
+	if r.Warnings == 0 {
+		return r, nil
+	}
+
+	warnings, err := c.ShowWarnings()
+	if err != nil {
+		r.Close()
+		return nil, errors.Trace(err)
+	}
+	c.warnings = warnings
+
+	if c.strictWarnings {
+		for _, w := range warnings {
+			if w.Level != "Note" {
+				r.Close()
+				return nil, &WarningsError{Warnings: warnings}
+			}
+		}
+	}
+	return r, nil
+}
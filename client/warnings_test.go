@@ -0,0 +1,76 @@
+package client_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+type warningsHandler struct {
+	server.EmptyHandler
+	last string
+}
+
+func (h *warningsHandler) HandleQuery(query string) (*mysql.Result, error) {
+	if query != "SHOW WARNINGS" {
+		h.last = query
+		switch query {
+		case "INSERT 1":
+			return &mysql.Result{Warnings: 1}, nil
+		case "NOTE":
+			return &mysql.Result{Warnings: 1}, nil
+		}
+		return &mysql.Result{}, nil
+	}
+
+	var rows [][]interface{}
+	switch h.last {
+	case "INSERT 1":
+		rows = [][]interface{}{{"Warning", uint64(1265), "Data truncated for column 'a' at row 1"}}
+	case "NOTE":
+		rows = [][]interface{}{{"Note", uint64(1051), "Unknown table 'test.t'"}}
+	default:
+		return nil, errors.New("no warnings expected")
+	}
+	rs, err := mysql.BuildSimpleTextResultset([]string{"Level", "Code", "Message"}, rows)
+	if err != nil {
+		return nil, err
+	}
+	return mysql.NewResult(rs), nil
+}
+
+func TestWarnings(t *testing.T) {
+	addr := serve(t, &warningsHandler{})
+
+	conn, err := client.Connect(addr, "root", "", "", "", client.WithWarnings())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	r, err := conn.Execute("INSERT 1")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, r.Warnings)
+	require.Equal(t, []client.Warning{{Level: "Warning", Code: 1265, Message: "Data truncated for column 'a' at row 1"}}, conn.Warnings())
+
+	_, err = conn.Execute("SELECT 1")
+	require.NoError(t, err)
+	require.Empty(t, conn.Warnings())
+
+	strict, err := client.Connect(addr, "root", "", "", "", client.WithStrictWarnings())
+	require.NoError(t, err)
+	defer strict.Close()
+
+	_, err = strict.Execute("INSERT 1")
+	var warningsErr *client.WarningsError
+	require.ErrorAs(t, err, &warningsErr)
+	require.Equal(t, "statement has warnings: Warning 1265: Data truncated for column 'a' at row 1", err.Error())
+
+	// notes are not errors
+	_, err = strict.Execute("NOTE")
+	require.NoError(t, err)
+	require.Len(t, strict.Warnings(), 1)
+}
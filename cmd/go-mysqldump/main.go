@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/pingcap/errors"
 
@@ -23,6 +24,7 @@ var (
 	tableDB       = flag.String("table_db", "", "database for dump tables")
 	ignoreTables  = flag.String("ignore_tables", "", "ignore tables, must be database.table format, separated by comma")
 	skipBinlogPos = flag.Bool("skip-binlog-pos", false, "skip fetching binlog position via --master-data/--source-data")
+	progress      = flag.Bool("progress", false, "report the bytes dumped per table to stderr")
 )
 
 func main() {
@@ -36,6 +38,21 @@ func main() {
 
 	d.SkipMasterData(*skipBinlogPos)
 
+	if *progress {
+		start := time.Now()
+		var last time.Time
+		var lastTable string
+		d.SetProgressHandler(func(db, table string, bytes int64) {
+			// when a table starts, then at most once per second
+			now := time.Now()
+			if table == lastTable && now.Sub(last) < time.Second {
+				return
+			}
+			last, lastTable = now, table
+			fmt.Fprintf(os.Stderr, "%s.%s: %d bytes, %s elapsed\n", db, table, bytes, now.Sub(start).Truncate(time.Second))
+		})
+	}
+
 	if len(*ignoreTables) > 0 {
 		subs := strings.Split(*ignoreTables, ",")
 		for _, sub := range subs {
@@ -10,6 +10,7 @@ import (
 	goErrors "errors"
 	"fmt"
 	"io"
+	"net"
 	"net/url"
 	"regexp"
 	"strings"
@@ -45,7 +46,9 @@ var customTLSMutex sync.Mutex
 var (
 	dsnRegex           = regexp.MustCompile("@[^@]+/[^@/]+")
 	customTLSConfigMap = make(map[string]*tls.Config)
-	options            = map[string]DriverOption{
+	// TLS configs by name for the tls parameter, see RegisterTLSConfig
+	tlsConfigRegistry = make(map[string]*tls.Config)
+	options           = map[string]DriverOption{
 		"compress":     CompressOption,
 		"collation":    CollationOption,
 		"readTimeout":  ReadTimeoutOption,
@@ -134,6 +137,7 @@ func (ci connInfo) Connect(ctx context.Context) (sqldriver.Conn, error) {
 
 	if ci.standardDSN {
 		var timeout time.Duration
+		var charset string
 		configuredOptions := make([]client.Option, 0, len(ci.params))
 		for key, value := range ci.params {
 			if key == "ssl" && len(value) > 0 {
@@ -157,6 +161,21 @@ func (ci connInfo) Connect(ctx context.Context) (sqldriver.Conn, error) {
 				default:
 					return nil, errors.Errorf("Supported options are ssl=true or ssl=custom")
 				}
+			} else if key == "tls" && len(value) > 0 {
+				tlsConfig, err := dsnTLSConfig(value[0], ci.addr)
+				if err != nil {
+					return nil, err
+				}
+				if tlsConfig != nil {
+					configuredOptions = append(configuredOptions, func(c *client.Conn) error {
+						c.SetTLSConfig(tlsConfig)
+						return nil
+					})
+				}
+			} else if key == "charset" && len(value) > 0 {
+				// go-sql-driver/mysql accepts a list of charsets to try, only the
+				// first one is used
+				charset, _, _ = strings.Cut(value[0], ",")
 			} else if key == "timeout" && len(value) > 0 {
 				if timeout, err = time.ParseDuration(value[0]); err != nil {
 					return nil, errors.Wrap(err, "invalid duration value for timeout option")
@@ -182,7 +201,7 @@ func (ci connInfo) Connect(ctx context.Context) (sqldriver.Conn, error) {
 		if timeout <= 0 {
 			timeout = 10 * time.Second
 		}
-		c, err = client.ConnectWithContext(ctx, ci.addr, ci.user, ci.password, ci.db, "", timeout, configuredOptions...)
+		c, err = client.ConnectWithContext(ctx, ci.addr, ci.user, ci.password, ci.db, charset, timeout, configuredOptions...)
 	} else {
 		// No more processing here. Let's only support url parameters with the newer style DSN
 		c, err = client.ConnectWithContext(ctx, ci.addr, ci.user, ci.password, ci.db, "", 10*time.Second)
@@ -583,6 +602,64 @@ func SetCustomTLSConfig(dsn string, caPem []byte, certPem []byte, keyPem []byte,
 	return nil
 }
 
+// RegisterTLSConfig registers a TLS config under name, so it can be used with the
+// tls=name DSN parameter, like go-sql-driver/mysql. The names true, false and
+// skip-verify are reserved: tls=true verifies the server certificate against
+// the host of the DSN, tls=skip-verify does not verify it. If the config has no
+// ServerName and verifies the certificate, the host of the DSN is used.
+// It requires a full import of the driver (not by side-effects only).
+func RegisterTLSConfig(name string, config *tls.Config) error {
+	switch strings.ToLower(name) {
+	case "true", "false", "skip-verify", "preferred":
+		return errors.Errorf("TLS config name %q is reserved", name)
+	}
+
+	customTLSMutex.Lock()
+	tlsConfigRegistry[name] = config
+	customTLSMutex.Unlock()
+	return nil
+}
+
+// DeregisterTLSConfig removes the TLS config registered under name.
+func DeregisterTLSConfig(name string) {
+	customTLSMutex.Lock()
+	delete(tlsConfigRegistry, name)
+	customTLSMutex.Unlock()
+}
+
+// dsnTLSConfig returns the TLS config for the value of the tls parameter, nil if
+// TLS is not used.
+func dsnTLSConfig(value string, addr string) (*tls.Config, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	switch strings.ToLower(value) {
+	case "false", "0":
+		return nil, nil
+	case "true", "1":
+		return &tls.Config{ServerName: host}, nil
+	case "skip-verify":
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	case "preferred":
+		return nil, errors.Errorf("tls=preferred is not supported, use tls=skip-verify")
+	}
+
+	customTLSMutex.Lock()
+	config, ok := tlsConfigRegistry[value]
+	customTLSMutex.Unlock()
+	if !ok {
+		return nil, errors.Errorf("invalid value / unknown config name: %s", value)
+	}
+
+	if config.ServerName == "" && !config.InsecureSkipVerify {
+		config = config.Clone()
+		config.ServerName = host
+	}
+	return config, nil
+}
+
 // SetDSNOptions sets custom options to the driver that allows modifications to the connection.
 // It requires a full import of the driver (not by side-effects only).
 // Example of supplying a custom option:
@@ -37,15 +37,16 @@ func WriteTimeoutOption(c *client.Conn, value string) error {
 
 func CompressOption(c *client.Conn, value string) error {
 	switch value {
-	case "zlib":
+	case "zlib", "true", "1":
+		// true and 1 are the go-sql-driver/mysql values
 		c.SetCapability(mysql.CLIENT_COMPRESS)
 	case "zstd":
 		c.SetCapability(mysql.CLIENT_ZSTD_COMPRESSION_ALGORITHM)
-	case "uncompressed":
+	case "uncompressed", "false", "0":
 		c.UnsetCapability(mysql.CLIENT_COMPRESS)
 		c.UnsetCapability(mysql.CLIENT_ZSTD_COMPRESSION_ALGORITHM)
 	default:
-		return errors.Errorf("invalid compression algorithm '%s', valid values are 'zstd','zlib','uncompressed'", value)
+		return errors.Errorf("invalid compression algorithm '%s', valid values are 'zstd','zlib','uncompressed','true','false'", value)
 	}
 
 	return nil
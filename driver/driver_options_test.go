@@ -2,6 +2,7 @@ package driver
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
 	sqlDriver "database/sql/driver"
 	"fmt"
@@ -412,3 +413,49 @@ func (h *mockHandler) HandleStmtClose(context interface{}) error {
 func (h *mockHandler) HandleOtherCommand(cmd byte, data []byte) error {
 	return nil
 }
+
+func TestDriverOptions_TLS(t *testing.T) {
+	config, err := dsnTLSConfig("true", "db.example.com:3306")
+	require.NoError(t, err)
+	require.Equal(t, "db.example.com", config.ServerName)
+	require.False(t, config.InsecureSkipVerify)
+
+	config, err = dsnTLSConfig("skip-verify", "db.example.com:3306")
+	require.NoError(t, err)
+	require.True(t, config.InsecureSkipVerify)
+
+	config, err = dsnTLSConfig("false", "db.example.com:3306")
+	require.NoError(t, err)
+	require.Nil(t, config)
+
+	_, err = dsnTLSConfig("custom-ca", "db.example.com:3306")
+	require.Error(t, err)
+
+	require.Error(t, RegisterTLSConfig("skip-verify", &tls.Config{}))
+	registered := &tls.Config{MinVersion: tls.VersionTLS12}
+	require.NoError(t, RegisterTLSConfig("custom-ca", registered))
+	defer DeregisterTLSConfig("custom-ca")
+
+	config, err = dsnTLSConfig("custom-ca", "db.example.com:3306")
+	require.NoError(t, err)
+	require.Equal(t, "db.example.com", config.ServerName)
+	require.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	require.Empty(t, registered.ServerName)
+}
+
+func TestDriverOptions_Charset(t *testing.T) {
+	srv := CreateMockServer(t)
+	defer srv.Stop()
+
+	db, err := sql.Open("mysql", "root@127.0.0.1:3307/test?charset=latin1,utf8mb4&compress=false")
+	require.NoError(t, err)
+	defer db.Close()
+
+	c, err := db.Conn(context.TODO())
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.Raw(func(driverConn any) error {
+		require.Equal(t, "latin1", driverConn.(*conn).GetCharset())
+		return nil
+	}))
+}
@@ -0,0 +1,188 @@
+package dump
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// Backend is the tool run by Dumper to dump the server, mysqldump by default,
+// see NewDumperWithBackend.
+type Backend interface {
+	// Dump runs the tool for run with the options of d, writing to w statements
+	// in the format of mysqldump that Parse handles: USE, one INSERT per row with
+	// the table name only, and if run.MasterData is set, SET @@GLOBAL.GTID_PURGED
+	// and CHANGE MASTER TO for the position of the dump.
+	Dump(d *Dumper, w io.Writer, run DumpRun) error
+}
+
+// DumpRun is what a Backend dumps in one run. The databases with different
+// objects are dumped by different runs, see SetDatabaseObjects.
+type DumpRun struct {
+	// Databases are the databases to dump, all of them if empty, unless the
+	// tables of Dumper.TableDB are set by AddTables.
+	Databases []string
+	Objects   Objects
+	// MasterData is set for the first run, unless SkipMasterData is.
+	MasterData bool
+}
+
+// NewDumperWithBackend returns a Dumper running b instead of mysqldump, e.g.
+// a MysqlpumpBackend or a MydumperBackend.
+func NewDumperWithBackend(b Backend, addr string, user string, password string) *Dumper {
+	d := newDumper(addr, user, password)
+	d.backend = b
+	return d
+}
+
+type mysqldumpBackend struct{}
+
+func (mysqldumpBackend) Dump(d *Dumper, w io.Writer, run DumpRun) error {
+	return d.runMysqldump(w, run)
+}
+
+// connArgs returns the options to connect to d.Addr, a unix socket if it
+// contains a /, common to the tools.
+func (d *Dumper) connArgs() []string {
+	var args []string
+	if strings.Contains(d.Addr, "/") {
+		args = append(args, fmt.Sprintf("--socket=%s", d.Addr))
+	} else {
+		host, port, err := net.SplitHostPort(d.Addr)
+		if err != nil {
+			host = d.Addr
+		}
+
+		args = append(args, fmt.Sprintf("--host=%s", host))
+		if port != "" {
+			args = append(args, fmt.Sprintf("--port=%s", port))
+		}
+	}
+
+	args = append(args, fmt.Sprintf("--user=%s", d.User))
+	args = append(args, fmt.Sprintf("--password=%s", d.Password))
+	return args
+}
+
+// execTool runs the tool at path with args, writing its output to stdout. The
+// password is masked in the logs.
+func (d *Dumper) execTool(path string, args []string, stdout io.Writer) error {
+	logged := make([]string, len(args))
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "--password=") {
+			arg = "--password=******"
+		}
+		logged[i] = arg
+	}
+	d.Logger.Info("exec dump tool with", slog.String("path", path), slog.Any("args", logged))
+
+	cmd := exec.Command(path, args...)
+	cmd.Stderr = d.ErrOut
+	cmd.Stdout = stdout
+	return cmd.Run()
+}
+
+// lineRewriter calls rewrite for each line written, with its \n, to rewrite
+// the output of a tool to w. Close handles the last line if it has no \n.
+type lineRewriter struct {
+	w       io.Writer
+	rewrite func(w io.Writer, line []byte) error
+	buf     []byte
+}
+
+func (r *lineRewriter) Write(p []byte) (int, error) {
+	r.buf = append(r.buf, p...)
+	line := r.buf
+	for {
+		i := bytes.IndexByte(line, '\n')
+		if i < 0 {
+			break
+		}
+		if err := r.rewrite(r.w, line[:i+1]); err != nil {
+			return 0, err
+		}
+		line = line[i+1:]
+	}
+	r.buf = append(r.buf[:0], line...)
+	return len(p), nil
+}
+
+func (r *lineRewriter) Close() error {
+	if len(r.buf) == 0 {
+		return nil
+	}
+	err := r.rewrite(r.w, r.buf)
+	r.buf = r.buf[:0]
+	return err
+}
+
+// writeRows writes the rows of values, the VALUES list of an INSERT, as one
+// INSERT per row into table.
+func writeRows(w io.Writer, table string, values string) error {
+	rows, err := splitRows(values)
+	if err != nil {
+		return errors.Annotatef(err, "parse rows of %s", table)
+	}
+	for _, row := range rows {
+		if _, err = fmt.Fprintf(w, "INSERT INTO `%s` VALUES (%s);\n", table, row); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// splitRows splits the rows (...),(...) of a VALUES list, the strings in double
+// quotes, as written by mydumper, are converted to single quotes like mysqldump
+// writes them.
+func splitRows(s string) ([]string, error) {
+	var rows []string
+	var row strings.Builder
+	var quote byte
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case quote != 0:
+			switch {
+			case ch == '\\' && i+1 < len(s):
+				row.WriteByte(ch)
+				i++
+				ch = s[i]
+			case ch == quote:
+				quote = 0
+				ch = '\''
+			case ch == '\'':
+				// a single quote in a string in double quotes
+				row.WriteByte('\\')
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+			ch = '\''
+		case ch == '(':
+			depth++
+			if depth == 1 {
+				row.Reset()
+				continue
+			}
+		case ch == ')':
+			depth--
+			if depth == 0 {
+				rows = append(rows, row.String())
+				continue
+			}
+		}
+		if depth > 0 {
+			row.WriteByte(ch)
+		}
+	}
+	if quote != 0 || depth != 0 {
+		return nil, errors.New("unterminated row")
+	}
+	return rows, nil
+}
@@ -0,0 +1,112 @@
+package dump
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordParseHandler struct {
+	binlog []string
+	gtids  []string
+	rows   []string
+}
+
+func (h *recordParseHandler) BinLog(name string, pos uint64) error {
+	h.binlog = append(h.binlog, name)
+	return nil
+}
+
+func (h *recordParseHandler) GtidSet(gtidsets string) error {
+	h.gtids = append(h.gtids, gtidsets)
+	return nil
+}
+
+func (h *recordParseHandler) Data(schema string, table string, values []string) error {
+	h.rows = append(h.rows, schema+"."+table+":"+strings.Join(values, "|"))
+	return nil
+}
+
+func TestSplitRows(t *testing.T) {
+	rows, err := splitRows(`(1,'a,b'),(2,"c'd\"e"),(3,CONCAT('(',')'))`)
+	require.NoError(t, err)
+	require.Equal(t, []string{`1,'a,b'`, `2,'c\'d\"e'`, `3,CONCAT('(',')')`}, rows)
+
+	_, err = splitRows(`(1,'a)`)
+	require.Error(t, err)
+}
+
+func TestPumpWriter(t *testing.T) {
+	var buf bytes.Buffer
+	pw := newPumpWriter(&buf)
+	out := "SET @@GLOBAL.GTID_PURGED=/*!80000 '+'*/ '3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5';\n" +
+		"INSERT INTO `test1`.`t1` VALUES (1,'a'),(2,'b');\n" +
+		"INSERT INTO `test2`.`t2` (`id`,`name`) VALUES (3,'c');\n" +
+		"INSERT INTO `test1`.`t1` VALUES (4,'d');"
+	// written in pieces, like a pipe does
+	for _, piece := range []string{out[:30], out[30:100], out[100:]} {
+		_, err := pw.Write([]byte(piece))
+		require.NoError(t, err)
+	}
+	require.NoError(t, pw.Close())
+
+	h := new(recordParseHandler)
+	require.NoError(t, Parse(&buf, h, true))
+	require.Equal(t, []string{"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}, h.gtids)
+	require.Equal(t, []string{"test1.t1:1|'a'", "test1.t1:2|'b'", "test2.t2:3|'c'", "test1.t1:4|'d'"}, h.rows)
+}
+
+func TestParseMydumperMetadata(t *testing.T) {
+	old := "Started dump at: 2024-01-01 00:00:00\n" +
+		"SHOW SLAVE STATUS:\n\tHost: 10.0.0.1\n\tLog: mysql-bin.000099\n\tPos: 1\n\n" +
+		"SHOW MASTER STATUS:\n\tLog: mysql-bin.000002\n\tPos: 1234\n\tGTID:3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5\n\n" +
+		"Finished dump at: 2024-01-01 00:00:01\n"
+	m, err := parseMydumperMetadata(strings.NewReader(old))
+	require.NoError(t, err)
+	require.Equal(t, &mydumperMetadata{file: "mysql-bin.000002", pos: 1234, gtid: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}, m)
+
+	ini := "[config]\nquote_character = BACKTICK\n\n" +
+		"[source]\n# Channel_Name = ''\nFile = \"mysql-bin.000003\"\nPosition = 4\nExecuted_Gtid_Set = \"\"\n\n" +
+		"[replication]\nrelay_master_log_file = \"mysql-bin.000099\"\n"
+	m, err = parseMydumperMetadata(strings.NewReader(ini))
+	require.NoError(t, err)
+	require.Equal(t, &mydumperMetadata{file: "mysql-bin.000003", pos: 4}, m)
+
+	_, err = parseMydumperMetadata(strings.NewReader("Started dump at: 2024-01-01 00:00:00\n"))
+	require.Error(t, err)
+}
+
+func TestMydumperFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"metadata": "[source]\nFile = \"mysql-bin.000002\"\nPosition = 1234\n" +
+			"Executed_Gtid_Set = \"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5\"\n",
+		"test1-schema-create.sql": "CREATE DATABASE `test1`;\n",
+		"test1.t1-schema.sql":     "CREATE TABLE `t1` (id int, name varchar(10));\n",
+		"test1.t1.00000.sql":      "/*!40101 SET NAMES binary*/;\nINSERT INTO `t1` VALUES(1,\"a\"),\n(2,\"b;\");\n",
+		"test1.t1.00001.sql":      "INSERT INTO `t1` VALUES\n(3,NULL);\n",
+		"test2.t2.sql":            "INSERT INTO `t2` (`id`) VALUES(4);\n",
+	}
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeMydumperMetadata(&buf, filepath.Join(dir, "metadata")))
+	require.NoError(t, writeMydumperFiles(&buf, dir))
+	require.Contains(t, buf.String(), "USE `test1`;\nCREATE DATABASE `test1`;\nCREATE TABLE `t1`")
+
+	h := new(recordParseHandler)
+	require.NoError(t, Parse(&buf, h, true))
+	require.Equal(t, []string{"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}, h.gtids)
+	require.Equal(t, []string{"mysql-bin.000002"}, h.binlog)
+	require.Equal(t, []string{"test1.t1:1|'a'", "test1.t1:2|'b;'", "test1.t1:3|NULL", "test2.t2:4"}, h.rows)
+
+	require.Equal(t, `^(?!(test1\.t2)$)(test1|test2)\.`,
+		mydumperRegex([]string{"test1", "test2"}, map[string][]string{"test1": {"t2"}}))
+	require.Empty(t, mydumperRegex(nil, nil))
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"net"
 	"os"
 	"os/exec"
 	"regexp"
@@ -44,12 +43,24 @@ type Dumper struct {
 	maxAllowedPacket  int
 	hexBlob           bool
 
+	compression Compression
+	chunkSize   int64
+
+	mode            Mode
+	objects         Objects
+	databaseObjects map[string]Objects
+
 	// see detectColumnStatisticsParamSupported
 	isColumnStatisticsParamSupported bool
 
 	mysqldumpVersion    string
 	sourceDataSupported bool
 
+	progress ProgressHandler
+	metadata bool
+
+	backend Backend
+
 	Logger *slog.Logger
 }
 
@@ -73,17 +84,9 @@ func NewDumper(executionPath string, addr string, user string, password string)
 		}
 	}
 
-	d := new(Dumper)
+	d := newDumper(addr, user, password)
 	d.ExecutionPath = path
-	d.Addr = addr
-	d.User = user
-	d.Password = password
-	d.Tables = make([]string, 0, 16)
-	d.Databases = make([]string, 0, 16)
-	d.Charset = mysql.DEFAULT_CHARSET
-	d.IgnoreTables = make(map[string][]string)
-	d.ExtraOptions = make([]string, 0, 5)
-	d.masterDataSkipped = false
+	d.backend = mysqldumpBackend{}
 
 	out, err := exec.Command(d.ExecutionPath, `--help`).CombinedOutput()
 	if err != nil {
@@ -93,11 +96,24 @@ func NewDumper(executionPath string, addr string, user string, password string)
 	d.mysqldumpVersion = d.getMysqldumpVersion(out)
 	d.sourceDataSupported = d.detectSourceDataSupported(d.mysqldumpVersion)
 
-	d.ErrOut = os.Stderr
+	return d, nil
+}
 
+func newDumper(addr string, user string, password string) *Dumper {
+	d := new(Dumper)
+	d.Addr = addr
+	d.User = user
+	d.Password = password
+	d.Tables = make([]string, 0, 16)
+	d.Databases = make([]string, 0, 16)
+	d.Charset = mysql.DEFAULT_CHARSET
+	d.IgnoreTables = make(map[string][]string)
+	d.ExtraOptions = make([]string, 0, 5)
+	d.masterDataSkipped = false
+	d.objects = ObjectTriggers
+	d.ErrOut = os.Stderr
 	d.Logger = slog.Default()
-
-	return d, nil
+	return d
 }
 
 // New mysqldump versions try to send queries to information_schema.COLUMN_STATISTICS table which does not exist in old MySQL (<5.x).
@@ -208,32 +224,62 @@ func (d *Dumper) Reset() {
 	d.IgnoreTables = make(map[string][]string)
 	d.Databases = d.Databases[0:0]
 	d.Where = ""
+	d.databaseObjects = nil
 }
 
+// Dump runs the dump tool and writes the output to w, compressed if set by SetCompression.
 func (d *Dumper) Dump(w io.Writer) error {
-	args := make([]string, 0, 16)
+	cw, err := NewCompressWriter(w, d.compression)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = d.dump(cw); err != nil {
+		return err
+	}
+	return errors.Trace(cw.Close())
+}
 
-	// Common args
-	if strings.Contains(d.Addr, "/") {
-		args = append(args, fmt.Sprintf("--socket=%s", d.Addr))
-	} else {
-		host, port, err := net.SplitHostPort(d.Addr)
+func (d *Dumper) dump(w io.Writer) error {
+	if d.progress != nil {
+		w = newProgressWriter(w, d.progress, d.TableDB)
+	}
+
+	if d.metadata {
+		if err := d.writeMetadata(w); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	if len(d.Tables) > 0 {
+		// If we only dump some tables, the dump data will not have database name
+		// which makes us hard to parse, so here we add it manually.
+
+		_, err := fmt.Fprintf(w, "USE `%s`;\n", d.TableDB)
 		if err != nil {
-			host = d.Addr
+			return fmt.Errorf(`could not write USE command: %w`, err)
 		}
+	}
 
-		args = append(args, fmt.Sprintf("--host=%s", host))
-		if port != "" {
-			args = append(args, fmt.Sprintf("--port=%s", port))
+	backend := d.backend
+	if backend == nil {
+		backend = mysqldumpBackend{}
+	}
+	for i, g := range d.dumpGroups() {
+		run := DumpRun{Databases: g.databases, Objects: g.objects, MasterData: !d.masterDataSkipped && i == 0}
+		if err := backend.Dump(d, w, run); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	args = append(args, fmt.Sprintf("--user=%s", d.User))
-	passwordArg := fmt.Sprintf("--password=%s", d.Password)
-	args = append(args, passwordArg)
-	passwordArgIndex := len(args) - 1
+// runMysqldump runs mysqldump for run.
+func (d *Dumper) runMysqldump(w io.Writer, run DumpRun) error {
+	args := make([]string, 0, 16)
+	args = append(args, d.connArgs()...)
+	connArgs := len(args)
 
-	if !d.masterDataSkipped {
+	if run.MasterData {
 		if d.sourceDataSupported {
 			args = append(args, "--source-data")
 		} else {
@@ -258,8 +304,8 @@ func (d *Dumper) Dump(w io.Writer) error {
 	args = append(args, "--skip-opt")
 	args = append(args, "--quick")
 
-	// We only care about data
-	args = append(args, "--no-create-info")
+	args = append(args, d.mode.args()...)
+	args = append(args, run.Objects.args()...)
 
 	// Multi row is easy for us to parse the data
 	args = append(args, "--skip-extended-insert")
@@ -291,33 +337,23 @@ func (d *Dumper) Dump(w io.Writer) error {
 		args = append(args, `--column-statistics=0`)
 	}
 
-	if len(d.Tables) == 0 && len(d.Databases) == 0 {
+	if len(d.Tables) == 0 && len(run.Databases) == 0 {
 		args = append(args, "--all-databases")
 	} else if len(d.Tables) == 0 {
 		args = append(args, "--databases")
-		args = append(args, d.Databases...)
+		args = append(args, run.Databases...)
 	} else {
 		args = append(args, d.TableDB)
 		args = append(args, d.Tables...)
+	}
 
-		// If we only dump some tables, the dump data will not have database name
-		// which makes us hard to parse, so here we add it manually.
-
-		_, err := fmt.Fprintf(w, "USE `%s`;\n", d.TableDB)
-		if err != nil {
-			return fmt.Errorf(`could not write USE command: %w`, err)
+	if d.metadata {
+		if err := writeDumpFlags(w, args[connArgs:]); err != nil {
+			return err
 		}
 	}
 
-	args[passwordArgIndex] = "--password=******"
-	d.Logger.Info("exec mysqldump with", slog.Any("args", args))
-	args[passwordArgIndex] = passwordArg
-	cmd := exec.Command(d.ExecutionPath, args...)
-
-	cmd.Stderr = d.ErrOut
-	cmd.Stdout = w
-
-	return cmd.Run()
+	return d.execTool(d.ExecutionPath, args, w)
 }
 
 // DumpAndParse: Dump MySQL and parse immediately
@@ -331,7 +367,7 @@ func (d *Dumper) DumpAndParse(h ParseHandler) error {
 		done <- err
 	}()
 
-	err := d.Dump(w)
+	err := d.dump(w)
 	_ = w.CloseWithError(err)
 
 	err = <-done
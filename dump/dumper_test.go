@@ -1,6 +1,11 @@
 package dump
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -60,3 +65,137 @@ func TestDetectSourceDataSupported(t *testing.T) {
 		require.Equal(t, v.supported, d.detectSourceDataSupported(v.version), v.version)
 	}
 }
+
+func TestDumpGroups(t *testing.T) {
+	d := &Dumper{objects: ObjectTriggers}
+	require.Equal(t, []dumpGroup{{objects: ObjectTriggers}}, d.dumpGroups())
+
+	d.AddDatabases("a", "b", "c")
+	d.SetDatabaseObjects("b", ObjectsAll)
+	require.Equal(t, []dumpGroup{
+		{objects: ObjectTriggers, databases: []string{"a", "c"}},
+		{objects: ObjectsAll, databases: []string{"b"}},
+	}, d.dumpGroups())
+
+	d.Reset()
+	d.SetObjects(ObjectRoutines)
+	d.AddTables("a", "t1", "t2")
+	require.Equal(t, []dumpGroup{{objects: ObjectRoutines}}, d.dumpGroups())
+
+	require.Equal(t, []string{"--skip-triggers", "--routines"}, ObjectRoutines.args())
+	require.Equal(t, []string{"--triggers", "--routines", "--events"}, ObjectsAll.args())
+	require.Equal(t, []string{"--no-create-info"}, ModeData.args())
+	require.Equal(t, []string{"--no-data", "--create-options"}, ModeSchema.args())
+}
+
+// dumpRuns runs the dump of d with a mysqldump printing its arguments, and
+// returns the arguments of each run.
+func dumpRuns(t *testing.T, d *Dumper) [][]string {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake mysqldump is a shell script")
+	}
+	d.ExecutionPath = filepath.Join(t.TempDir(), "mysqldump")
+	require.NoError(t, os.WriteFile(d.ExecutionPath, []byte("#!/bin/sh\nprintf '%s\\n' \"$@\" END\n"), 0o755))
+
+	var buf bytes.Buffer
+	require.NoError(t, d.Dump(&buf))
+	var runs [][]string
+	var args []string
+	for _, arg := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if strings.HasPrefix(arg, "USE ") {
+			// the database of the tables
+			continue
+		}
+		if arg == "END" {
+			runs = append(runs, args)
+			args = nil
+			continue
+		}
+		args = append(args, arg)
+	}
+	return runs
+}
+
+// modeArgs are the arguments of the mode and of the objects of a dump.
+var modeArgs = map[string]bool{
+	"--no-data": true, "--no-create-info": true, "--create-options": true,
+	"--triggers": true, "--skip-triggers": true, "--routines": true, "--events": true,
+}
+
+func TestDumpModeArgs(t *testing.T) {
+	d := newDumper("127.0.0.1:3306", "root", "")
+	d.AddDatabases("db1")
+	require.Equal(t, [][]string{{
+		"--host=127.0.0.1", "--port=3306", "--user=root", "--password=",
+		"--master-data", "--single-transaction", "--skip-lock-tables", "--compact", "--skip-opt", "--quick",
+		"--no-create-info", "--triggers",
+		"--skip-extended-insert", "--skip-tz-utc", "--default-character-set=utf8mb4", "--databases", "db1",
+	}}, dumpRuns(t, d))
+
+	tests := []struct {
+		name    string
+		mode    Mode
+		objects Objects
+		args    []string
+	}{
+		{"data only", ModeData, ObjectTriggers, []string{"--no-create-info", "--triggers"}},
+		{"schema only", ModeSchema, ObjectTriggers, []string{"--no-data", "--create-options", "--triggers"}},
+		{"schema and data", ModeSchemaAndData, ObjectTriggers, []string{"--create-options", "--triggers"}},
+		{"no triggers", ModeSchema, 0, []string{"--no-data", "--create-options", "--skip-triggers"}},
+		{"routines", ModeSchema, ObjectRoutines, []string{"--no-data", "--create-options", "--skip-triggers", "--routines"}},
+		{"events", ModeData, ObjectEvents, []string{"--no-create-info", "--skip-triggers", "--events"}},
+		{"all objects", ModeSchemaAndData, ObjectsAll, []string{"--create-options", "--triggers", "--routines", "--events"}},
+	}
+	for _, tt := range tests {
+		d.SetMode(tt.mode)
+		d.SetObjects(tt.objects)
+		runs := dumpRuns(t, d)
+		require.Len(t, runs, 1, tt.name)
+		var args []string
+		for _, arg := range runs[0] {
+			if modeArgs[arg] {
+				args = append(args, arg)
+			}
+		}
+		require.Equal(t, tt.args, args, tt.name)
+	}
+}
+
+func TestDumpDatabaseObjectsArgs(t *testing.T) {
+	d := newDumper("/tmp/mysql.sock", "root", "")
+	d.SetMode(ModeSchema)
+	d.AddDatabases("a", "b", "c", "d")
+	d.SetDatabaseObjects("b", ObjectsAll)
+	d.SetDatabaseObjects("c", ObjectRoutines)
+	d.SetDatabaseObjects("d", ObjectsAll)
+
+	// a run per objects, the master data written by the first one only
+	tail := func(args []string, n int) []string { return args[len(args)-n:] }
+	runs := dumpRuns(t, d)
+	require.Len(t, runs, 3)
+	require.Equal(t, "--socket=/tmp/mysql.sock", runs[0][0])
+	require.Contains(t, runs[0], "--master-data")
+	require.Subset(t, runs[0], []string{"--no-data", "--triggers"})
+	require.NotContains(t, runs[0], "--routines")
+	require.NotContains(t, runs[0], "--events")
+	require.Equal(t, []string{"--databases", "a"}, tail(runs[0], 2))
+
+	require.NotContains(t, runs[1], "--master-data")
+	require.Subset(t, runs[1], []string{"--no-data", "--triggers", "--routines", "--events"})
+	require.Equal(t, []string{"--databases", "b", "d"}, tail(runs[1], 3))
+
+	require.NotContains(t, runs[2], "--master-data")
+	require.Subset(t, runs[2], []string{"--no-data", "--skip-triggers", "--routines"})
+	require.NotContains(t, runs[2], "--events")
+	require.Equal(t, []string{"--databases", "c"}, tail(runs[2], 2))
+
+	// the tables of a database are dumped with its objects
+	d.Reset()
+	d.SetDatabaseObjects("a", ObjectEvents)
+	d.AddTables("a", "t1", "t2")
+	runs = dumpRuns(t, d)
+	require.Len(t, runs, 1)
+	require.Subset(t, runs[0], []string{"--skip-triggers", "--events"})
+	require.NotContains(t, runs[0], "--routines")
+	require.Equal(t, []string{"a", "t1", "t2"}, tail(runs[0], 3))
+}
@@ -0,0 +1,460 @@
+package dump
+
+import (
+	"bufio"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/client"
+)
+
+// Loader applies a dump of Dumper, the output of mysqldump, to a target server,
+// like the mysql client would, with several connections: the tables are loaded
+// in parallel, one per connection at a time, the statements of a table being
+// applied in order. The session statements, like SET NAMES or USE, are applied
+// on all the connections, the other statements, like CREATE DATABASE or the
+// triggers, once the statements before them are applied. LOCK TABLES and
+// UNLOCK TABLES are skipped.
+//
+// The progress is saved to the checkpoint file, see SetCheckpointFile, to
+// resume a load which failed by loading the same dump again.
+type Loader struct {
+	connect func() (*client.Conn, error)
+
+	workers        int
+	batchSize      int
+	checkpointPath string
+}
+
+// NewLoader returns a Loader applying dumps with the connections returned by
+// connect, to the target server.
+func NewLoader(connect func() (*client.Conn, error)) *Loader {
+	return &Loader{connect: connect, workers: 4, batchSize: 1000}
+}
+
+// SetWorkers sets the number of tables loaded in parallel, 4 by default.
+func (l *Loader) SetWorkers(n int) {
+	l.workers = max(n, 1)
+}
+
+// SetBatchSize sets the maximum number of rows of an INSERT, 1000 by default:
+// the INSERT of mysqldump with more rows are split in several, executed in a
+// transaction.
+func (l *Loader) SetBatchSize(rows int) {
+	l.batchSize = max(rows, 1)
+}
+
+// SetCheckpointFile saves the progress of Load to the file path, and makes
+// Load resume from it if it exists, skipping the statements already applied.
+// The dump must be the same.
+func (l *Loader) SetCheckpointFile(path string) {
+	l.checkpointPath = path
+}
+
+// LoadCheckpoint is the progress of a Loader, the statements of the dump
+// applied, numbered from 0 without the session statements.
+type LoadCheckpoint struct {
+	// Applied is the number of the first statements, all applied.
+	Applied int64 `json:"applied"`
+	// Done are the statements applied after them.
+	Done []int64 `json:"done,omitempty"`
+}
+
+type statementKind int
+
+const (
+	statementSkip statementKind = iota
+	// applied on all the connections, every time
+	statementSession
+	// applied in order with the other statements of its table
+	statementTable
+	// applied once the statements before it are
+	statementGlobal
+)
+
+var (
+	loadTableExp  = regexp.MustCompile("(?i)^(?:(?:INSERT|REPLACE)(?: IGNORE)? INTO |DROP TABLE (?:IF EXISTS )?|CREATE TABLE (?:IF NOT EXISTS )?|ALTER TABLE )`((?:[^`]|``)+)`")
+	loadInsertExp = regexp.MustCompile("(?is)^((?:INSERT|REPLACE)(?: IGNORE)? INTO `(?:[^`]|``)+`(?: \\([^)]*\\))? VALUES ?)(.*)$")
+	loadUseExp    = regexp.MustCompile("(?i)^USE `((?:[^`]|``)+)`")
+	// the version of a conditional comment /*!40101 ... */
+	conditionalVersionExp = regexp.MustCompile(`^\d*`)
+)
+
+// classifyStatement returns the kind of a statement of a dump, and its table if
+// it is a statement of a table.
+func classifyStatement(stmt string) (statementKind, string) {
+	s := stmt
+	if strings.HasPrefix(s, "/*!") {
+		s = strings.TrimSuffix(s[3:], "*/")
+		s = strings.TrimSpace(s[len(conditionalVersionExp.FindString(s)):])
+	}
+	upper := strings.ToUpper(s[:min(len(s), 64)])
+	switch {
+	case upper == "":
+		return statementSkip, ""
+	case strings.HasPrefix(upper, "LOCK TABLES"), strings.HasPrefix(upper, "UNLOCK TABLES"):
+		return statementSkip, ""
+	case strings.HasPrefix(upper, "SET "):
+		if strings.Contains(strings.ToUpper(s), "GLOBAL") || strings.Contains(upper, "PERSIST") {
+			return statementGlobal, ""
+		}
+		return statementSession, ""
+	case strings.HasPrefix(upper, "USE "), strings.HasPrefix(upper, "SELECT "), strings.HasPrefix(upper, "PREPARE "),
+		strings.HasPrefix(upper, "EXECUTE "), strings.HasPrefix(upper, "DEALLOCATE "),
+		upper == "COMMIT", upper == "BEGIN", upper == "START TRANSACTION":
+		return statementSession, ""
+	}
+	if m := loadTableExp.FindStringSubmatch(s); m != nil {
+		return statementTable, strings.ReplaceAll(m[1], "``", "`")
+	}
+	return statementGlobal, ""
+}
+
+// statementReader reads the statements of a dump, skipping the comments, with
+// the delimiters changed by DELIMITER, like for the triggers.
+type statementReader struct {
+	r         *bufio.Reader
+	delimiter string
+}
+
+func newStatementReader(r io.Reader) *statementReader {
+	return &statementReader{r: bufio.NewReaderSize(r, 1024*64), delimiter: ";"}
+}
+
+// next returns the next statement without its delimiter, io.EOF at the end.
+func (r *statementReader) next() (string, error) {
+	var stmt strings.Builder
+	var quote byte
+	for {
+		line, err := r.r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", errors.Trace(err)
+		}
+		if line == "" && err == io.EOF {
+			if strings.TrimSpace(stmt.String()) != "" {
+				return "", errors.Errorf("unterminated statement %.64s", stmt.String())
+			}
+			return "", io.EOF
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if stmt.Len() == 0 {
+			trimmed := strings.TrimSpace(line)
+			switch {
+			case trimmed == "", trimmed == "--", strings.HasPrefix(trimmed, "-- "):
+				continue
+			case len(trimmed) > 10 && strings.EqualFold(trimmed[:10], "DELIMITER "):
+				r.delimiter = strings.TrimSpace(trimmed[10:])
+				continue
+			}
+		} else {
+			stmt.WriteByte('\n')
+		}
+		stmt.WriteString(line)
+
+		quote = scanQuotes(line, quote)
+		if quote == 0 {
+			if s := strings.TrimRight(stmt.String(), " \t"); strings.HasSuffix(s, r.delimiter) {
+				return strings.TrimSpace(strings.TrimSuffix(s, r.delimiter)), nil
+			}
+		}
+	}
+}
+
+// scanQuotes returns the quote still open at the end of line, quote is the
+// one open at its start.
+func scanQuotes(line string, quote byte) byte {
+	for i := 0; i < len(line); i++ {
+		switch ch := line[i]; {
+		case quote != 0 && ch == '\\' && quote != '`':
+			i++
+		case quote != 0 && ch == quote:
+			quote = 0
+		case quote == 0 && (ch == '\'' || ch == '"' || ch == '`'):
+			quote = ch
+		}
+	}
+	return quote
+}
+
+// loadTask is a statement for a worker, seq is -1 for the session statements,
+// barrier is set for the tasks waiting for the previous ones.
+type loadTask struct {
+	seq     int64
+	query   string
+	barrier *sync.WaitGroup
+}
+
+type loadWorker struct {
+	conn  *client.Conn
+	tasks chan loadTask
+}
+
+// loadState is the state of a Load shared by the workers.
+type loadState struct {
+	l *Loader
+
+	mu        sync.Mutex
+	applied   int64
+	done      map[int64]bool
+	err       error
+	lastSaved time.Time
+	// serializes the writes of the checkpoint file
+	saveMu sync.Mutex
+}
+
+// Load applies the dump read from r.
+func (l *Loader) Load(r io.Reader) error {
+	st := &loadState{l: l, done: make(map[int64]bool), lastSaved: time.Now()}
+	if err := st.readCheckpoint(); err != nil {
+		return errors.Trace(err)
+	}
+
+	workers := make([]*loadWorker, l.workers)
+	var wg sync.WaitGroup
+	defer func() {
+		for _, w := range workers {
+			if w != nil {
+				w.conn.Close()
+			}
+		}
+	}()
+	for i := range workers {
+		conn, err := l.connect()
+		if err != nil {
+			for _, w := range workers[:i] {
+				close(w.tasks)
+			}
+			wg.Wait()
+			return errors.Trace(err)
+		}
+		workers[i] = &loadWorker{conn: conn, tasks: make(chan loadTask, 16)}
+		wg.Add(1)
+		go func(w *loadWorker) {
+			defer wg.Done()
+			st.run(w)
+		}(workers[i])
+	}
+
+	err := st.dispatch(newStatementReader(r), workers)
+	for _, w := range workers {
+		close(w.tasks)
+	}
+	wg.Wait()
+
+	if saveErr := st.saveCheckpoint(true); err == nil {
+		err = saveErr
+	}
+	if err == nil {
+		err = st.failed()
+	}
+	return err
+}
+
+// dispatch sends the statements of rd to the workers.
+func (st *loadState) dispatch(rd *statementReader, workers []*loadWorker) error {
+	var seq int64
+	var db string
+	for st.failed() == nil {
+		stmt, err := rd.next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Trace(err)
+		}
+
+		kind, table := classifyStatement(stmt)
+		switch kind {
+		case statementSkip:
+			continue
+		case statementSession:
+			if m := loadUseExp.FindStringSubmatch(stmt); m != nil {
+				db = m[1]
+			}
+			for _, w := range workers {
+				w.tasks <- loadTask{seq: -1, query: stmt}
+			}
+			continue
+		}
+
+		n := seq
+		seq++
+		if st.isDone(n) {
+			continue
+		}
+		if kind == statementTable {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(db + "." + table))
+			workers[h.Sum32()%uint32(len(workers))].tasks <- loadTask{seq: n, query: stmt}
+			continue
+		}
+
+		// the workers are idle once the barrier is done, the first one executes
+		// the statement
+		var barrier sync.WaitGroup
+		barrier.Add(len(workers))
+		for _, w := range workers {
+			w.tasks <- loadTask{seq: -1, barrier: &barrier}
+		}
+		barrier.Wait()
+		if st.failed() == nil {
+			st.finish(n, st.execute(workers[0].conn, loadTask{seq: n, query: stmt}))
+		}
+	}
+	return nil
+}
+
+// run executes the tasks of the worker, only the barriers after an error.
+func (st *loadState) run(w *loadWorker) {
+	for task := range w.tasks {
+		switch {
+		case task.barrier != nil:
+			task.barrier.Done()
+		case st.failed() != nil:
+		case task.seq < 0:
+			if _, err := w.conn.Execute(task.query); err != nil {
+				st.finish(-1, errors.Annotatef(err, "execute %.64s", task.query))
+			}
+		default:
+			st.finish(task.seq, st.execute(w.conn, task))
+		}
+	}
+}
+
+// execute executes a statement, the INSERT with more rows than the batch size
+// being split in a transaction.
+func (st *loadState) execute(conn *client.Conn, task loadTask) error {
+	err := st.executeBatches(conn, task.query)
+	if err != nil {
+		return errors.Annotatef(err, "execute statement %d %.64s", task.seq, task.query)
+	}
+	return nil
+}
+
+func (st *loadState) executeBatches(conn *client.Conn, query string) error {
+	m := loadInsertExp.FindStringSubmatch(query)
+	if m == nil || strings.Count(m[2], "),(") < st.l.batchSize {
+		_, err := conn.Execute(query)
+		return errors.Trace(err)
+	}
+	rows, err := splitRows(m[2])
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if len(rows) <= st.l.batchSize {
+		_, err = conn.Execute(query)
+		return errors.Trace(err)
+	}
+
+	if err = conn.Begin(); err != nil {
+		return errors.Trace(err)
+	}
+	for len(rows) > 0 {
+		n := min(len(rows), st.l.batchSize)
+		if _, err = conn.Execute(m[1] + "(" + strings.Join(rows[:n], "),(") + ")"); err != nil {
+			_ = conn.Rollback()
+			return errors.Trace(err)
+		}
+		rows = rows[n:]
+	}
+	return errors.Trace(conn.Commit())
+}
+
+func (st *loadState) failed() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.err
+}
+
+func (st *loadState) isDone(seq int64) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return seq < st.applied || st.done[seq]
+}
+
+// finish records the statement seq as applied, or the first error, and saves
+// the checkpoint every second.
+func (st *loadState) finish(seq int64, err error) {
+	st.mu.Lock()
+	if err != nil {
+		if st.err == nil {
+			st.err = err
+		}
+		st.mu.Unlock()
+		return
+	}
+	if seq >= 0 {
+		st.done[seq] = true
+		for st.done[st.applied] {
+			delete(st.done, st.applied)
+			st.applied++
+		}
+	}
+	st.mu.Unlock()
+	if err = st.saveCheckpoint(false); err != nil {
+		st.finish(-1, err)
+	}
+}
+
+func (st *loadState) readCheckpoint() error {
+	if st.l.checkpointPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(st.l.checkpointPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.Trace(err)
+	}
+	var cp LoadCheckpoint
+	if err = json.Unmarshal(data, &cp); err != nil {
+		return errors.Annotatef(err, "parse checkpoint %s", st.l.checkpointPath)
+	}
+	st.applied = cp.Applied
+	for _, seq := range cp.Done {
+		st.done[seq] = true
+	}
+	return nil
+}
+
+// saveCheckpoint saves the checkpoint if a second passed since the last time,
+// or if force is set.
+func (st *loadState) saveCheckpoint(force bool) error {
+	if st.l.checkpointPath == "" {
+		return nil
+	}
+	st.saveMu.Lock()
+	defer st.saveMu.Unlock()
+	st.mu.Lock()
+	if !force && time.Since(st.lastSaved) < time.Second {
+		st.mu.Unlock()
+		return nil
+	}
+	st.lastSaved = time.Now()
+	cp := LoadCheckpoint{Applied: st.applied}
+	for seq := range st.done {
+		cp.Done = append(cp.Done, seq)
+	}
+	st.mu.Unlock()
+	slices.Sort(cp.Done)
+
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	// written to a temporary file first not to leave a partial file
+	tmp := st.l.checkpointPath + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(os.Rename(tmp, st.l.checkpointPath))
+}
@@ -0,0 +1,159 @@
+package dump
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/server"
+)
+
+const testLoadDump = `-- MySQL dump 10.13  Distrib 8.0.32, for Linux (x86_64)
+--
+-- Host: 127.0.0.1    Database: shop
+-- ------------------------------------------------------
+/*!40101 SET NAMES utf8mb4 */;
+/*!40014 SET @OLD_UNIQUE_CHECKS=@@UNIQUE_CHECKS, UNIQUE_CHECKS=0 */;
+SET @@GLOBAL.GTID_PURGED=/*!80000 '+'*/ 'de278ad0-2106-11e4-9f8e-6edd0ca20947:1-2';
+
+CREATE DATABASE /*!32312 IF NOT EXISTS*/ ` + "`shop`" + `;
+
+USE ` + "`shop`" + `;
+DROP TABLE IF EXISTS ` + "`a`" + `;
+CREATE TABLE ` + "`a`" + ` (
+  ` + "`id`" + ` int NOT NULL,
+  ` + "`s`" + ` varchar(10) DEFAULT 'x;y',
+  PRIMARY KEY (` + "`id`" + `)
+);
+LOCK TABLES ` + "`a`" + ` WRITE;
+/*!40000 ALTER TABLE ` + "`a`" + ` DISABLE KEYS */;
+INSERT INTO ` + "`a`" + ` VALUES (1,'a;\'b'),(2,'c'),(3,NULL),(4,'d'),(5,'e');
+/*!40000 ALTER TABLE ` + "`a`" + ` ENABLE KEYS */;
+UNLOCK TABLES;
+DROP TABLE IF EXISTS ` + "`b`" + `;
+CREATE TABLE ` + "`b`" + ` (` + "`id`" + ` int);
+INSERT INTO ` + "`b`" + ` VALUES (1);
+INSERT INTO ` + "`b`" + ` VALUES (2);
+DELIMITER ;;
+/*!50003 CREATE*/ /*!50003 TRIGGER ` + "`t`" + ` BEFORE INSERT ON ` + "`b`" + ` FOR EACH ROW BEGIN
+SET NEW.id = NEW.id + 1;
+END */;;
+DELIMITER ;
+/*!40101 SET SQL_MODE=@OLD_SQL_MODE */;
+-- Dump completed
+`
+
+// loadHandler records the statements executed by the connections, and fails
+// the statements containing fail.
+type loadHandler struct {
+	server.EmptyHandler
+	mu      *sync.Mutex
+	queries *[]string
+	fail    string
+}
+
+func (h loadHandler) HandleQuery(query string) (*mysql.Result, error) {
+	if h.fail != "" && strings.Contains(query, h.fail) {
+		return nil, mysql.NewError(mysql.ER_DUP_ENTRY, "duplicate entry")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*h.queries = append(*h.queries, query)
+	return nil, nil
+}
+
+func count(queries []string, query string) int {
+	n := 0
+	for _, q := range queries {
+		if q == query {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLoader(t *testing.T) {
+	var mu sync.Mutex
+	var queries []string
+	h := loadHandler{mu: &mu, queries: &queries}
+	addr := serve(t, h)
+	connect := func() (*client.Conn, error) {
+		return client.Connect(addr, "root", "", "", "")
+	}
+
+	l := NewLoader(connect)
+	l.SetWorkers(3)
+	l.SetBatchSize(2)
+	require.NoError(t, l.Load(strings.NewReader(testLoadDump)))
+
+	// the session statements on all the connections, the others once
+	require.Equal(t, 3, count(queries, "/*!40101 SET NAMES utf8mb4 */"))
+	require.Equal(t, 3, count(queries, "USE `shop`"))
+	require.Equal(t, 1, count(queries, "CREATE DATABASE /*!32312 IF NOT EXISTS*/ `shop`"))
+	require.Equal(t, 1, count(queries, "SET @@GLOBAL.GTID_PURGED=/*!80000 '+'*/ 'de278ad0-2106-11e4-9f8e-6edd0ca20947:1-2'"))
+	require.Equal(t, 0, count(queries, "LOCK TABLES `a` WRITE"))
+	require.Equal(t, 1, count(queries, "CREATE TABLE `a` (\n  `id` int NOT NULL,\n  `s` varchar(10) DEFAULT 'x;y',\n  PRIMARY KEY (`id`)\n)"))
+	require.Equal(t, 1, count(queries, "/*!50003 CREATE*/ /*!50003 TRIGGER `t` BEFORE INSERT ON `b` FOR EACH ROW BEGIN\nSET NEW.id = NEW.id + 1;\nEND */"))
+
+	// the rows in batches of 2, in a transaction, after the ALTER TABLE
+	var a []string
+	for _, q := range queries {
+		if strings.Contains(q, "`a`") || q == "BEGIN" || q == "COMMIT" {
+			a = append(a, q)
+		}
+	}
+	require.Equal(t, []string{
+		"DROP TABLE IF EXISTS `a`",
+		a[1],
+		"/*!40000 ALTER TABLE `a` DISABLE KEYS */",
+		"BEGIN",
+		"INSERT INTO `a` VALUES (1,'a;\\'b'),(2,'c')",
+		"INSERT INTO `a` VALUES (3,NULL),(4,'d')",
+		"INSERT INTO `a` VALUES (5,'e')",
+		"COMMIT",
+		"/*!40000 ALTER TABLE `a` ENABLE KEYS */",
+	}, a)
+}
+
+func TestLoaderResume(t *testing.T) {
+	var mu sync.Mutex
+	var queries []string
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	load := func(fail string) error {
+		addr := serve(t, loadHandler{mu: &mu, queries: &queries, fail: fail})
+		l := NewLoader(func() (*client.Conn, error) {
+			return client.Connect(addr, "root", "", "", "")
+		})
+		l.SetWorkers(1)
+		l.SetCheckpointFile(checkpoint)
+		return l.Load(strings.NewReader(testLoadDump))
+	}
+
+	err := load("VALUES (2)")
+	require.ErrorContains(t, err, "duplicate entry")
+	require.FileExists(t, checkpoint)
+	require.Equal(t, 1, count(queries, "INSERT INTO `b` VALUES (1)"))
+
+	queries = nil
+	require.NoError(t, load(""))
+	// resumed at the statement failing, with the session statements
+	require.Equal(t, []string{
+		"/*!40101 SET NAMES utf8mb4 */",
+		"/*!40014 SET @OLD_UNIQUE_CHECKS=@@UNIQUE_CHECKS, UNIQUE_CHECKS=0 */",
+		"USE `shop`",
+		"INSERT INTO `b` VALUES (2)",
+		"/*!50003 CREATE*/ /*!50003 TRIGGER `t` BEFORE INSERT ON `b` FOR EACH ROW BEGIN\nSET NEW.id = NEW.id + 1;\nEND */",
+		"/*!40101 SET SQL_MODE=@OLD_SQL_MODE */",
+	}, queries)
+
+	data, err := os.ReadFile(checkpoint)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"applied": 12}`, string(data))
+}
@@ -0,0 +1,134 @@
+package dump
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/client"
+)
+
+// Metadata is the metadata of the source server and the dump, read from the
+// comments at the start of the dump.
+type Metadata struct {
+	// ToolVersion is the version of mysqldump, from its header.
+	ToolVersion string
+	// ServerVersion is the version of the server, from the header of mysqldump
+	// or written by Dumper.
+	ServerVersion string
+
+	// GTIDMode, BinlogFormat and BinlogRowImage are the global variables of the
+	// server written by Dumper, empty if unknown, e.g. gtid_mode for MariaDB.
+	GTIDMode       string
+	BinlogFormat   string
+	BinlogRowImage string
+
+	// Flags are the options mysqldump was run with by Dumper, without the
+	// options to connect.
+	Flags []string
+}
+
+// MetadataHandler is a ParseHandler receiving the Metadata of the dump, before
+// the first statement of the dump is handled, so the dump can be checked before
+// it is loaded. Metadata is called once, even if the dump has no metadata.
+type MetadataHandler interface {
+	ParseHandler
+	Metadata(m *Metadata) error
+}
+
+// metadataVariables are the global variables written by Dumper, in order.
+var metadataVariables = []string{"gtid_mode", "binlog_format", "binlog_row_image"}
+
+var (
+	// -- MySQL dump 10.13  Distrib 8.0.32, for Linux (x86_64)
+	// -- MariaDB dump 10.19  Distrib 10.6.12-MariaDB, for debian-linux-gnu (x86_64)
+	toolVersionExp   = regexp.MustCompile(`^-- (?:MySQL|MariaDB) dump \S+\s+Distrib ([^,]+),`)
+	serverVersionExp = regexp.MustCompile(`^-- Server version\s+(\S+)`)
+	serverVarExp     = regexp.MustCompile(`^-- Server variable: (\w+)=(.*)$`)
+	dumpFlagsExp     = regexp.MustCompile(`^-- Dump flags: (.+)$`)
+)
+
+// parseMetadataLine sets the metadata of the comment line to m, it returns
+// false if line is not a comment.
+func parseMetadataLine(line string, m *Metadata) (bool, error) {
+	if line != "--" && !(len(line) > 3 && line[:3] == "-- ") {
+		return false, nil
+	}
+
+	if v := toolVersionExp.FindStringSubmatch(line); v != nil {
+		m.ToolVersion = v[1]
+	} else if v = serverVersionExp.FindStringSubmatch(line); v != nil {
+		m.ServerVersion = v[1]
+	} else if v = serverVarExp.FindStringSubmatch(line); v != nil {
+		switch v[1] {
+		case "gtid_mode":
+			m.GTIDMode = v[2]
+		case "binlog_format":
+			m.BinlogFormat = v[2]
+		case "binlog_row_image":
+			m.BinlogRowImage = v[2]
+		}
+	} else if v = dumpFlagsExp.FindStringSubmatch(line); v != nil && m.Flags == nil {
+		if err := json.Unmarshal([]byte(v[1]), &m.Flags); err != nil {
+			return true, errors.Annotatef(err, "parse dump flags %s", v[1])
+		}
+	}
+	return true, nil
+}
+
+// SetMetadata writes the Metadata of the server and of the dump as comments at
+// the start of the dump, for the MetadataHandler of Parse. The variables of the
+// server are read by connecting to it.
+func (d *Dumper) SetMetadata(v bool) {
+	d.metadata = v
+}
+
+// writeMetadata writes the version and the variables of the server.
+func (d *Dumper) writeMetadata(w io.Writer) error {
+	conn, err := client.Connect(d.Addr, d.User, d.Password, "", "")
+	if err != nil {
+		return errors.Annotate(err, "connect to read the metadata")
+	}
+	defer conn.Close()
+
+	r, err := conn.Execute("SHOW GLOBAL VARIABLES WHERE Variable_name IN ('version', 'gtid_mode', 'binlog_format', 'binlog_row_image')")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer r.Close()
+
+	vars := make(map[string]string, r.RowNumber())
+	for i := 0; i < r.RowNumber(); i++ {
+		name, _ := r.GetString(i, 0)
+		value, _ := r.GetString(i, 1)
+		vars[name] = value
+	}
+
+	if _, err = fmt.Fprintf(w, "-- Server version\t%s\n", vars["version"]); err != nil {
+		return errors.Trace(err)
+	}
+	for _, name := range metadataVariables {
+		value, ok := vars[name]
+		if !ok {
+			continue
+		}
+		if _, err = fmt.Fprintf(w, "-- Server variable: %s=%s\n", name, value); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// writeDumpFlags writes the options of mysqldump, args without the options to
+// connect.
+func writeDumpFlags(w io.Writer, args []string) error {
+	flags, err := json.Marshal(args)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = fmt.Fprintf(w, "-- Dump flags: %s\n", flags)
+	return errors.Trace(err)
+}
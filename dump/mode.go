@@ -0,0 +1,114 @@
+package dump
+
+import "slices"
+
+// Mode is what Dump writes for the tables.
+type Mode int
+
+const (
+	// ModeData dumps the rows only (--no-create-info), the default. DumpAndParse
+	// only handles rows.
+	ModeData Mode = iota
+	// ModeSchema dumps the CREATE statements only (--no-data), e.g. to bootstrap
+	// the schema of a new server.
+	ModeSchema
+	// ModeSchemaAndData dumps the CREATE statements and the rows.
+	ModeSchemaAndData
+)
+
+func (m Mode) args() []string {
+	switch m {
+	case ModeSchema:
+		return []string{"--no-data", "--create-options"}
+	case ModeSchemaAndData:
+		return []string{"--create-options"}
+	default:
+		return []string{"--no-create-info"}
+	}
+}
+
+// Objects are the objects dumped with the tables.
+type Objects uint8
+
+const (
+	ObjectTriggers Objects = 1 << iota
+	ObjectRoutines
+	ObjectEvents
+
+	ObjectsAll = ObjectTriggers | ObjectRoutines | ObjectEvents
+)
+
+func (o Objects) args() []string {
+	args := make([]string, 0, 3)
+	if o&ObjectTriggers > 0 {
+		args = append(args, "--triggers")
+	} else {
+		args = append(args, "--skip-triggers")
+	}
+	if o&ObjectRoutines > 0 {
+		args = append(args, "--routines")
+	}
+	if o&ObjectEvents > 0 {
+		args = append(args, "--events")
+	}
+	return args
+}
+
+// SetMode sets what to dump for the tables, ModeData by default.
+func (d *Dumper) SetMode(m Mode) {
+	d.mode = m
+}
+
+// SetObjects sets the objects dumped with the tables, ObjectTriggers by default
+// like mysqldump.
+func (d *Dumper) SetObjects(o Objects) {
+	d.objects = o
+}
+
+// SetDatabaseObjects sets the objects dumped with the tables of db, overriding
+// SetObjects. It only applies to the databases added by AddDatabases or AddTables.
+//
+// mysqldump can't dump different objects per database, so a dump of databases
+// with different objects runs mysqldump for each group of databases with the same
+// objects. The dumps of the groups are separate transactions, and only the first
+// one writes the master data.
+func (d *Dumper) SetDatabaseObjects(db string, o Objects) {
+	if d.databaseObjects == nil {
+		d.databaseObjects = make(map[string]Objects)
+	}
+	d.databaseObjects[db] = o
+}
+
+// dumpGroup is the databases dumped by one mysqldump run.
+type dumpGroup struct {
+	objects   Objects
+	databases []string
+}
+
+func (d *Dumper) objectsOf(db string) Objects {
+	if o, ok := d.databaseObjects[db]; ok {
+		return o
+	}
+	return d.objects
+}
+
+func (d *Dumper) dumpGroups() []dumpGroup {
+	if len(d.Tables) > 0 {
+		return []dumpGroup{{objects: d.objectsOf(d.TableDB)}}
+	}
+	if len(d.Databases) == 0 {
+		return []dumpGroup{{objects: d.objects}}
+	}
+
+	var groups []dumpGroup
+	for _, db := range d.Databases {
+		o := d.objectsOf(db)
+		i := slices.IndexFunc(groups, func(g dumpGroup) bool { return g.objects == o })
+		if i < 0 {
+			groups = append(groups, dumpGroup{objects: o})
+			i = len(groups) - 1
+		}
+		groups[i].databases = append(groups[i].databases, db)
+	}
+	return groups
+}
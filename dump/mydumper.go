@@ -0,0 +1,339 @@
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+var (
+	// mydumper writes the rows of db.table to db.table.sql, or to
+	// db.table.00000.sql, db.table.00001.sql, etc... when it splits the table
+	mydumperDataFileExp = regexp.MustCompile(`^([^.]+)\.([^.]+)(\.\d+)?\.sql$`)
+	// and the schemas to db-schema-create.sql and db.table-schema.sql
+	mydumperSchemaFileExp = regexp.MustCompile(`^([^.]+)(\.[^.]+)?-schema(-[a-z]+)?\.sql$`)
+
+	mydumperInsertExp = regexp.MustCompile("(?s)^INSERT INTO `(.+?)`(?: \\([^)]*\\))? ?VALUES ?(.+);\\s*$")
+)
+
+// MydumperBackend dumps with mydumper, in parallel, to a directory. Once
+// mydumper is done, the metadata and the files are written in order to the
+// output of the dump. The charset and the max allowed packet are not set.
+type MydumperBackend struct {
+	ExecutionPath string
+	// Dir is the output directory of mydumper, a temporary directory removed
+	// after the dump if empty.
+	Dir string
+	// Threads is the number of threads of mydumper, its default if 0.
+	Threads int
+}
+
+// NewMydumperBackend looks up the mydumper at executionPath, mydumper in the
+// PATH if empty.
+func NewMydumperBackend(executionPath string) (*MydumperBackend, error) {
+	if executionPath == "" {
+		executionPath = "mydumper"
+	}
+	path, err := exec.LookPath(executionPath)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &MydumperBackend{ExecutionPath: path}, nil
+}
+
+func (b *MydumperBackend) Dump(d *Dumper, w io.Writer, run DumpRun) error {
+	dir := b.Dir
+	if dir == "" {
+		var err error
+		if dir, err = os.MkdirTemp("", "mydumper"); err != nil {
+			return errors.Trace(err)
+		}
+		defer os.RemoveAll(dir)
+	}
+
+	args := d.connArgs()
+	args = append(args, fmt.Sprintf("--outputdir=%s", dir))
+	if b.Threads > 0 {
+		args = append(args, fmt.Sprintf("--threads=%d", b.Threads))
+	}
+
+	switch d.mode {
+	case ModeSchema:
+		args = append(args, "--no-data")
+	case ModeData:
+		args = append(args, "--no-schemas")
+	}
+	if run.Objects&ObjectTriggers > 0 {
+		args = append(args, "--triggers")
+	}
+	if run.Objects&ObjectRoutines > 0 {
+		args = append(args, "--routines")
+	}
+	if run.Objects&ObjectEvents > 0 {
+		args = append(args, "--events")
+	}
+
+	if d.hexBlob {
+		args = append(args, "--hex-blob")
+	}
+	if len(d.Where) != 0 {
+		args = append(args, fmt.Sprintf("--where=%s", d.Where))
+	}
+
+	if len(d.Tables) > 0 {
+		tables := make([]string, len(d.Tables))
+		for i, table := range d.Tables {
+			tables[i] = d.TableDB + "." + table
+		}
+		args = append(args, "--tables-list="+strings.Join(tables, ","))
+	} else if regex := mydumperRegex(run.Databases, d.IgnoreTables); regex != "" {
+		args = append(args, "--regex="+regex)
+	}
+	if len(d.ExtraOptions) != 0 {
+		args = append(args, d.ExtraOptions...)
+	}
+
+	if err := d.execTool(b.ExecutionPath, args, d.ErrOut); err != nil {
+		return err
+	}
+
+	if run.MasterData {
+		if err := writeMydumperMetadata(w, filepath.Join(dir, "metadata")); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return errors.Trace(writeMydumperFiles(w, dir))
+}
+
+// mydumperRegex returns the --regex of the databases, excluding the ignored
+// tables, matched against db.table.
+func mydumperRegex(databases []string, ignoreTables map[string][]string) string {
+	var ignored []string
+	for db, tables := range ignoreTables {
+		for _, table := range tables {
+			ignored = append(ignored, regexp.QuoteMeta(db+"."+table))
+		}
+	}
+	if len(databases) == 0 && len(ignored) == 0 {
+		return ""
+	}
+
+	var regex strings.Builder
+	regex.WriteString("^")
+	if len(ignored) > 0 {
+		slices.Sort(ignored)
+		fmt.Fprintf(&regex, "(?!(%s)$)", strings.Join(ignored, "|"))
+	}
+	if len(databases) > 0 {
+		quoted := make([]string, len(databases))
+		for i, db := range databases {
+			quoted[i] = regexp.QuoteMeta(db)
+		}
+		fmt.Fprintf(&regex, `(%s)\.`, strings.Join(quoted, "|"))
+	}
+	return regex.String()
+}
+
+// mydumperMetadata is the position of the dump in the metadata file.
+type mydumperMetadata struct {
+	file string
+	pos  uint64
+	gtid string
+}
+
+// parseMydumperMetadata parses the position of the source in the metadata file,
+// in the format of mydumper 0.9 and 0.10:
+//
+//	SHOW MASTER STATUS:
+//		Log: mysql-bin.000002
+//		Pos: 1234
+//		GTID:3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5
+//
+// or in the ini format of the later versions:
+//
+//	[source]
+//	File = "mysql-bin.000002"
+//	Position = 1234
+//	Executed_Gtid_Set = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"
+//
+// The position of the source of the server, if it is a replica, is ignored.
+func parseMydumperMetadata(r io.Reader) (*mydumperMetadata, error) {
+	m := new(mydumperMetadata)
+	inSource := false
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			continue
+		case strings.HasPrefix(trimmed, "["):
+			inSource = trimmed == "[source]" || trimmed == "[master]"
+			continue
+		case strings.HasSuffix(trimmed, ":") && line == trimmed:
+			inSource = trimmed == "SHOW MASTER STATUS:" || trimmed == "SHOW BINARY LOG STATUS:"
+			continue
+		case !inSource || strings.HasPrefix(trimmed, "#"):
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, "=")
+		if !ok {
+			if key, value, ok = strings.Cut(trimmed, ":"); !ok {
+				continue
+			}
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		switch strings.TrimSpace(key) {
+		case "Log", "File":
+			m.file = value
+		case "Pos", "Position":
+			pos, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, errors.Errorf("invalid position %q in mydumper metadata", value)
+			}
+			m.pos = pos
+		case "GTID", "Executed_Gtid_Set":
+			m.gtid = value
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if m.file == "" {
+		return nil, errors.New("no source position in mydumper metadata")
+	}
+	return m, nil
+}
+
+// writeMydumperMetadata writes the position of the metadata file like mysqldump
+// does, the GTID set before the binlog position.
+func writeMydumperMetadata(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer f.Close()
+
+	m, err := parseMydumperMetadata(f)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if m.gtid != "" {
+		if _, err = fmt.Fprintf(w, "SET @@GLOBAL.GTID_PURGED='%s';\n", m.gtid); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	_, err = fmt.Fprintf(w, "CHANGE MASTER TO MASTER_LOG_FILE='%s', MASTER_LOG_POS=%d;\n", m.file, m.pos)
+	return errors.Trace(err)
+}
+
+// writeMydumperFiles writes the files of the mydumper output dir in order, the
+// schemas first, with a USE before the files of each database.
+func writeMydumperFiles(w io.Writer, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	var schemas, data []string
+	for _, e := range entries {
+		switch name := e.Name(); {
+		case mydumperSchemaFileExp.MatchString(name):
+			schemas = append(schemas, name)
+		case mydumperDataFileExp.MatchString(name):
+			data = append(data, name)
+		}
+	}
+
+	var db string
+	use := func(name string, exp *regexp.Regexp) error {
+		if m := exp.FindStringSubmatch(name); m[1] != db {
+			db = m[1]
+			_, err := fmt.Fprintf(w, "USE `%s`;\n", db)
+			return errors.Trace(err)
+		}
+		return nil
+	}
+
+	// os.ReadDir sorts by name, the database schemas come before their tables
+	for _, name := range schemas {
+		if err = use(name, mydumperSchemaFileExp); err != nil {
+			return err
+		}
+		if err = copyFile(w, filepath.Join(dir, name)); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	for _, name := range data {
+		if err = use(name, mydumperDataFileExp); err != nil {
+			return err
+		}
+		if err = writeMydumperData(w, filepath.Join(dir, name)); err != nil {
+			return errors.Annotatef(err, "read %s", name)
+		}
+	}
+	return nil
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return errors.Trace(err)
+}
+
+// writeMydumperData writes the rows of a data file, whose INSERT statements
+// have several rows, on one or several lines, as an INSERT per row.
+func writeMydumperData(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 1024*16)
+	var stmt []byte
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return errors.Trace(err)
+		}
+
+		if len(stmt) > 0 || bytes.HasPrefix(line, []byte("INSERT INTO ")) {
+			stmt = append(stmt, line...)
+			// the rows end with ), and the last one with );
+			if bytes.HasSuffix(bytes.TrimRight(stmt, "\r\n"), []byte(");")) {
+				m := mydumperInsertExp.FindSubmatch(stmt)
+				if m == nil {
+					return errors.Errorf("invalid INSERT statement %.64q", stmt)
+				}
+				if err := writeRows(w, string(m[1]), string(m[2])); err != nil {
+					return err
+				}
+				stmt = stmt[:0]
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+	if len(stmt) > 0 {
+		return errors.Errorf("unterminated INSERT statement %.64q", stmt)
+	}
+	return nil
+}
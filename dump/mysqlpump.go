@@ -0,0 +1,129 @@
+package dump
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+
+	"github.com/pingcap/errors"
+)
+
+// pumpInsertExp matches the INSERT statements of mysqlpump, whose table names
+// are qualified by the database.
+var pumpInsertExp = regexp.MustCompile("^INSERT INTO `(.+?)`\\.`(.+?)`(?: \\([^)]*\\))? VALUES (.+);\\s*$")
+
+// MysqlpumpBackend dumps with mysqlpump, in parallel. mysqlpump can't write the
+// binlog position, so only the GTID set of the dump is reported to the
+// ParseHandler, the sync must resume by GTID or with SkipMasterData. Where is not
+// supported. mysqlpump was removed in MySQL 8.4.
+type MysqlpumpBackend struct {
+	ExecutionPath string
+	// Parallelism is the number of threads of mysqlpump, its default if 0.
+	Parallelism int
+}
+
+// NewMysqlpumpBackend looks up the mysqlpump at executionPath, mysqlpump in
+// the PATH if empty.
+func NewMysqlpumpBackend(executionPath string) (*MysqlpumpBackend, error) {
+	if executionPath == "" {
+		executionPath = "mysqlpump"
+	}
+	path, err := exec.LookPath(executionPath)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &MysqlpumpBackend{ExecutionPath: path}, nil
+}
+
+func (b *MysqlpumpBackend) Dump(d *Dumper, w io.Writer, run DumpRun) error {
+	if d.Where != "" {
+		return errors.New("mysqlpump does not support a where condition")
+	}
+
+	args := d.connArgs()
+	args = append(args, "--single-transaction", "--skip-definer", "--skip-watch-progress")
+	if run.MasterData {
+		args = append(args, "--set-gtid-purged=ON")
+	} else {
+		args = append(args, "--set-gtid-purged=OFF")
+	}
+	if b.Parallelism > 0 {
+		args = append(args, fmt.Sprintf("--default-parallelism=%d", b.Parallelism))
+	}
+
+	switch d.mode {
+	case ModeSchema:
+		args = append(args, "--skip-dump-rows")
+	case ModeData:
+		args = append(args, "--no-create-db", "--no-create-info")
+	}
+	if run.Objects&ObjectTriggers == 0 {
+		args = append(args, "--skip-triggers")
+	}
+	if run.Objects&ObjectRoutines == 0 {
+		args = append(args, "--skip-routines")
+	}
+	if run.Objects&ObjectEvents == 0 {
+		args = append(args, "--skip-events")
+	}
+	args = append(args, "--skip-users")
+
+	if d.hexBlob {
+		args = append(args, "--hex-blob")
+	}
+	if d.maxAllowedPacket > 0 {
+		args = append(args, fmt.Sprintf("--max-allowed-packet=%dM", d.maxAllowedPacket))
+	}
+	if d.Protocol != "" {
+		args = append(args, fmt.Sprintf("--protocol=%s", d.Protocol))
+	}
+	if len(d.Charset) != 0 {
+		args = append(args, fmt.Sprintf("--default-character-set=%s", d.Charset))
+	}
+	for db, tables := range d.IgnoreTables {
+		for _, table := range tables {
+			args = append(args, fmt.Sprintf("--exclude-tables=%s.%s", db, table))
+		}
+	}
+	if len(d.ExtraOptions) != 0 {
+		args = append(args, d.ExtraOptions...)
+	}
+
+	if len(d.Tables) > 0 {
+		args = append(args, d.TableDB)
+		args = append(args, d.Tables...)
+	} else if len(run.Databases) > 0 {
+		args = append(args, "--databases")
+		args = append(args, run.Databases...)
+	} else {
+		args = append(args, "--all-databases")
+	}
+
+	pw := newPumpWriter(w)
+	if err := d.execTool(b.ExecutionPath, args, pw); err != nil {
+		return err
+	}
+	return errors.Trace(pw.Close())
+}
+
+// newPumpWriter rewrites the INSERT statements of mysqlpump, of several rows and
+// with qualified table names in any order, to a USE of the database and an
+// INSERT per row.
+func newPumpWriter(w io.Writer) *lineRewriter {
+	var db string
+	return &lineRewriter{w: w, rewrite: func(w io.Writer, line []byte) error {
+		m := pumpInsertExp.FindSubmatch(line)
+		if m == nil {
+			_, err := w.Write(line)
+			return errors.Trace(err)
+		}
+		if string(m[1]) != db {
+			db = string(m[1])
+			if _, err := fmt.Fprintf(w, "USE `%s`;\n", db); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		return writeRows(w, string(m[2]), string(m[3]))
+	}}
+}
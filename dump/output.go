@@ -0,0 +1,301 @@
+package dump
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pingcap/errors"
+)
+
+// Compression is the compression of the dump output.
+type Compression int
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionZstd
+)
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionGzip:
+		return "gzip"
+	case CompressionZstd:
+		return "zstd"
+	default:
+		return fmt.Sprintf("Compression(%d)", int(c))
+	}
+}
+
+// Ext returns the file extension of the compression, like ".gz".
+func (c Compression) Ext() string {
+	switch c {
+	case CompressionGzip:
+		return ".gz"
+	case CompressionZstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// NewCompressWriter returns a writer compressing to w. Close flushes the
+// compressed stream but doesn't close w.
+func NewCompressWriter(w io.Writer, c Compression) (io.WriteCloser, error) {
+	switch c {
+	case CompressionNone:
+		return nopWriteCloser{w}, nil
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		zw, err := zstd.NewWriter(w)
+		return zw, errors.Trace(err)
+	default:
+		return nil, errors.Errorf("unsupported compression %s", c)
+	}
+}
+
+// ChunkFile describes a file written by ChunkWriter.
+type ChunkFile struct {
+	Name string `json:"name"`
+	// Size is the size of the file, RawSize the size before compression.
+	Size    int64  `json:"size"`
+	RawSize int64  `json:"raw_size"`
+	SHA256  string `json:"sha256"`
+}
+
+// Manifest lists the files of a chunked dump in order. Concatenating the
+// decompressed files gives the full dump.
+type Manifest struct {
+	Compression string      `json:"compression"`
+	Files       []ChunkFile `json:"files"`
+}
+
+// ChunkWriter writes the dump to numbered files in a directory, like
+// dump.000001.sql.gz, starting a new file once ChunkSize uncompressed bytes
+// are written. Files are only switched at the end of a line ending with a
+// semicolon, the end of a statement of mysqldump, so the multi-line statements
+// like CREATE TABLE are not split across files. The bodies of the stored
+// programs, between DELIMITER lines, may be. Close writes the manifest
+// <prefix>.manifest.json.
+type ChunkWriter struct {
+	Dir         string
+	Prefix      string
+	Compression Compression
+	// ChunkSize is the uncompressed size of a file, 0 means no rotation.
+	ChunkSize int64
+
+	// OnChunk is called after a file is completed, for example to upload it
+	// while the dump is still running.
+	OnChunk func(path string, f ChunkFile) error
+
+	files []ChunkFile
+
+	file *os.File
+	cw   io.WriteCloser
+	hash *countingHash
+	raw  int64
+	// last is the last byte written
+	last byte
+}
+
+// NewChunkWriter creates a ChunkWriter, dir is created if it doesn't exist.
+func NewChunkWriter(dir string, prefix string, compression Compression, chunkSize int64) (*ChunkWriter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &ChunkWriter{
+		Dir:         dir,
+		Prefix:      prefix,
+		Compression: compression,
+		ChunkSize:   chunkSize,
+	}, nil
+}
+
+func (w *ChunkWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if w.file == nil {
+			if err := w.open(); err != nil {
+				return written, errors.Trace(err)
+			}
+		}
+
+		n := len(p)
+		rotate := false
+		if w.ChunkSize > 0 && w.raw+int64(n) >= w.ChunkSize {
+			// the chunk is full, switch after the next end of statement
+			from := 0
+			if w.ChunkSize > w.raw {
+				from = int(w.ChunkSize - w.raw - 1)
+			}
+			if i := statementEnd(p, from, w.last); i >= 0 {
+				n = i + 1
+				rotate = true
+			}
+		}
+
+		m, err := w.cw.Write(p[:n])
+		w.raw += int64(m)
+		written += m
+		if m > 0 {
+			w.last = p[m-1]
+		}
+		if err != nil {
+			return written, errors.Trace(err)
+		}
+		p = p[n:]
+
+		if rotate {
+			if err = w.finish(); err != nil {
+				return written, errors.Trace(err)
+			}
+		}
+	}
+	return written, nil
+}
+
+// statementEnd returns the index of the first newline of p from from following
+// a semicolon, -1 if there is none. last is the byte before p.
+func statementEnd(p []byte, from int, last byte) int {
+	for i := from; i < len(p); i++ {
+		j := bytes.IndexByte(p[i:], '\n')
+		if j < 0 {
+			return -1
+		}
+		i += j
+		if i > 0 && p[i-1] == ';' || i == 0 && last == ';' {
+			return i
+		}
+	}
+	return -1
+}
+
+// Close completes the current file and writes the manifest.
+func (w *ChunkWriter) Close() error {
+	if w.file != nil {
+		if err := w.finish(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	data, err := json.MarshalIndent(w.Manifest(), "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(os.WriteFile(filepath.Join(w.Dir, w.Prefix+".manifest.json"), data, 0o644))
+}
+
+// Manifest returns the manifest of the completed files.
+func (w *ChunkWriter) Manifest() *Manifest {
+	return &Manifest{
+		Compression: w.Compression.String(),
+		Files:       append([]ChunkFile{}, w.files...),
+	}
+}
+
+func (w *ChunkWriter) open() error {
+	name := fmt.Sprintf("%s.%06d.sql%s", w.Prefix, len(w.files)+1, w.Compression.Ext())
+	f, err := os.Create(filepath.Join(w.Dir, name))
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	w.hash = &countingHash{h: sha256.New()}
+	cw, err := NewCompressWriter(io.MultiWriter(f, w.hash), w.Compression)
+	if err != nil {
+		f.Close()
+		return errors.Trace(err)
+	}
+
+	w.file, w.cw, w.raw = f, cw, 0
+	return nil
+}
+
+func (w *ChunkWriter) finish() error {
+	err := w.cw.Close()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	path := w.file.Name()
+	f := ChunkFile{
+		Name:    filepath.Base(path),
+		Size:    w.hash.n,
+		RawSize: w.raw,
+		SHA256:  hex.EncodeToString(w.hash.h.Sum(nil)),
+	}
+	w.files = append(w.files, f)
+	w.file, w.cw = nil, nil
+
+	if w.OnChunk != nil {
+		return errors.Trace(w.OnChunk(path, f))
+	}
+	return nil
+}
+
+type countingHash struct {
+	h interface {
+		io.Writer
+		Sum([]byte) []byte
+	}
+	n int64
+}
+
+func (c *countingHash) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return c.h.Write(p)
+}
+
+// SetCompression compresses the output of Dump and DumpToDir.
+func (d *Dumper) SetCompression(c Compression) {
+	d.compression = c
+}
+
+// SetChunkSize sets the uncompressed size of the files written by DumpToDir,
+// 0 writes a single file.
+func (d *Dumper) SetChunkSize(size int64) {
+	d.chunkSize = size
+}
+
+// DumpToDir dumps to files named <prefix>.NNNNNN.sql[.gz|.zst] in dir,
+// rotated by the chunk size, and writes the manifest <prefix>.manifest.json.
+// onChunk, if not nil, is called after every completed file.
+func (d *Dumper) DumpToDir(dir string, prefix string, onChunk func(path string, f ChunkFile) error) (*Manifest, error) {
+	w, err := NewChunkWriter(dir, prefix, d.compression, d.chunkSize)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	w.OnChunk = onChunk
+
+	if err = d.dump(w); err != nil {
+		if w.file != nil {
+			w.file.Close()
+		}
+		return nil, errors.Trace(err)
+	}
+	if err = w.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return w.Manifest(), nil
+}
@@ -0,0 +1,113 @@
+package dump
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkWriter(t *testing.T) {
+	var dump strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&dump, "INSERT INTO `t` VALUES (%d,'abcdefghij');\n", i)
+	}
+
+	for _, c := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+		dir := t.TempDir()
+		w, err := NewChunkWriter(dir, "dump", c, 1000)
+		require.NoError(t, err)
+
+		var completed []string
+		w.OnChunk = func(path string, f ChunkFile) error {
+			completed = append(completed, f.Name)
+			return nil
+		}
+
+		// write in odd sizes to cross the chunk boundaries within a write
+		data := []byte(dump.String())
+		for len(data) > 0 {
+			n := min(len(data), 333)
+			_, err = w.Write(data[:n])
+			require.NoError(t, err)
+			data = data[n:]
+		}
+		require.NoError(t, w.Close())
+
+		b, err := os.ReadFile(filepath.Join(dir, "dump.manifest.json"))
+		require.NoError(t, err)
+		var m Manifest
+		require.NoError(t, json.Unmarshal(b, &m))
+		require.Equal(t, c.String(), m.Compression)
+		require.Greater(t, len(m.Files), 1)
+		require.Equal(t, fmt.Sprintf("dump.000001.sql%s", c.Ext()), m.Files[0].Name)
+
+		var out bytes.Buffer
+		for i, f := range m.Files {
+			require.Equal(t, f.Name, completed[i])
+
+			file, err := os.Open(filepath.Join(dir, f.Name))
+			require.NoError(t, err)
+			var r io.Reader = file
+			switch c {
+			case CompressionGzip:
+				r, err = gzip.NewReader(file)
+				require.NoError(t, err)
+			case CompressionZstd:
+				zr, err := zstd.NewReader(file)
+				require.NoError(t, err)
+				defer zr.Close()
+				r = zr
+			}
+			raw, err := io.ReadAll(r)
+			require.NoError(t, err)
+			file.Close()
+
+			require.Equal(t, f.RawSize, int64(len(raw)))
+			require.True(t, bytes.HasSuffix(raw, []byte("\n")))
+			if i < len(m.Files)-1 {
+				require.GreaterOrEqual(t, f.RawSize, int64(1000))
+			}
+			out.Write(raw)
+		}
+		require.Equal(t, dump.String(), out.String())
+	}
+}
+
+func TestChunkWriterStatements(t *testing.T) {
+	var dump strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&dump, "CREATE TABLE `t%d` (\n  `id` int NOT NULL,\n  PRIMARY KEY (`id`)\n);\n", i)
+	}
+
+	dir := t.TempDir()
+	w, err := NewChunkWriter(dir, "dump", CompressionNone, 100)
+	require.NoError(t, err)
+	// a byte per write, the end of a statement crosses the writes
+	data := []byte(dump.String())
+	for i := range data {
+		_, err = w.Write(data[i : i+1])
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	m := w.Manifest()
+	require.Greater(t, len(m.Files), 1)
+	var out strings.Builder
+	for _, f := range m.Files {
+		raw, err := os.ReadFile(filepath.Join(dir, f.Name))
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(raw, []byte("CREATE TABLE")), f.Name)
+		require.True(t, bytes.HasSuffix(raw, []byte(");\n")), f.Name)
+		out.Write(raw)
+	}
+	require.Equal(t, dump.String(), out.String())
+}
@@ -34,12 +34,29 @@ var (
 
 // Parse the dump data with Dumper generate.
 // It can not parse all the data formats with mysqldump outputs
+// If h is a MetadataHandler, it receives the Metadata of the dump first.
 func Parse(r io.Reader, h ParseHandler, parseBinlogPos bool) error {
 	rb := bufio.NewReaderSize(r, 1024*16)
 
 	var db string
 	var binlogParsed bool
 
+	mh, _ := h.(MetadataHandler)
+	var meta *Metadata
+	if mh != nil {
+		meta = new(Metadata)
+	}
+	// sendMetadata calls the MetadataHandler once, before the first statement
+	// handled.
+	sendMetadata := func() error {
+		if meta == nil {
+			return nil
+		}
+		m := meta
+		meta = nil
+		return errors.Trace(mh.Metadata(m))
+	}
+
 	for {
 		line, err := rb.ReadString('\n')
 		if err != nil && err != io.EOF {
@@ -53,12 +70,23 @@ func Parse(r io.Reader, h ParseHandler, parseBinlogPos bool) error {
 			return c == '\r' || c == '\n'
 		})
 
+		if meta != nil {
+			if comment, err := parseMetadataLine(line, meta); err != nil {
+				return errors.Trace(err)
+			} else if comment {
+				continue
+			}
+		}
+
 		if parseBinlogPos && !binlogParsed {
 			// parsed gtid set from mysqldump
 			// gtid comes before binlog file-position
 			if m := gtidExp.FindAllStringSubmatch(line, -1); len(m) == 1 {
 				gtidStr := m[0][1]
 				if gtidStr != "" {
+					if err := sendMetadata(); err != nil {
+						return err
+					}
 					if err := h.GtidSet(gtidStr); err != nil {
 						return errors.Trace(err)
 					}
@@ -71,6 +99,9 @@ func Parse(r io.Reader, h ParseHandler, parseBinlogPos bool) error {
 					return errors.Errorf("parse binlog %v err, invalid number", line)
 				}
 
+				if err = sendMetadata(); err != nil {
+					return err
+				}
 				if err = h.BinLog(name, pos); err != nil && err != ErrSkip {
 					return errors.Trace(err)
 				}
@@ -91,13 +122,16 @@ func Parse(r io.Reader, h ParseHandler, parseBinlogPos bool) error {
 				return errors.Errorf("parse values %v err", line)
 			}
 
+			if err = sendMetadata(); err != nil {
+				return err
+			}
 			if err = h.Data(db, table, values); err != nil && err != ErrSkip {
 				return errors.Trace(err)
 			}
 		}
 	}
 
-	return nil
+	return sendMetadata()
 }
 
 func parseValues(str string) ([]string, error) {
@@ -181,3 +181,53 @@ func TestParseLine(t *testing.T) {
 		require.Equal(t, te.expected, m[0][2])
 	}
 }
+
+type metadataParseHandler struct {
+	testParseHandler
+	meta  []*Metadata
+	calls []string
+}
+
+func (h *metadataParseHandler) Metadata(m *Metadata) error {
+	h.meta = append(h.meta, m)
+	h.calls = append(h.calls, "metadata")
+	return nil
+}
+
+func (h *metadataParseHandler) Data(schema string, table string, values []string) error {
+	h.calls = append(h.calls, "data")
+	return nil
+}
+
+func TestParseMetadata(t *testing.T) {
+	dump := `-- MySQL dump 10.13  Distrib 8.0.32, for Linux (x86_64)
+--
+-- Host: 127.0.0.1    Database: test
+-- ------------------------------------------------------
+-- Server version	8.0.30
+-- Server variable: gtid_mode=ON
+-- Server variable: binlog_format=ROW
+-- Server variable: binlog_row_image=FULL
+USE ` + "`test`" + `;
+-- Dump flags: ["--single-transaction","--where=id > 1"]
+INSERT INTO ` + "`t`" + ` VALUES (1);
+-- Server version	5.7.1
+INSERT INTO ` + "`t`" + ` VALUES (2);
+`
+	h := new(metadataParseHandler)
+	require.NoError(t, Parse(strings.NewReader(dump), h, true))
+	require.Equal(t, []string{"metadata", "data", "data"}, h.calls)
+	require.Equal(t, &Metadata{
+		ToolVersion:    "8.0.32",
+		ServerVersion:  "8.0.30",
+		GTIDMode:       "ON",
+		BinlogFormat:   "ROW",
+		BinlogRowImage: "FULL",
+		Flags:          []string{"--single-transaction", "--where=id > 1"},
+	}, h.meta[0])
+
+	// without metadata
+	h = new(metadataParseHandler)
+	require.NoError(t, Parse(strings.NewReader(""), h, true))
+	require.Equal(t, []*Metadata{{}}, h.meta)
+}
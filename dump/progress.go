@@ -0,0 +1,104 @@
+package dump
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+)
+
+// ProgressHandler is called with the number of uncompressed bytes of the dump
+// of the table written so far. It is called after every write of mysqldump to
+// the output and a last time when the next table starts, so it must be cheap.
+// Comparing bytes to the DATA_LENGTH of the table in information_schema.TABLES
+// gives an estimate of the remaining time.
+type ProgressHandler func(db, table string, bytes int64)
+
+// progressLinePrefix is the length of the start of the lines kept to find the
+// table, enough for the statement and a quoted name of 64 characters.
+const progressLinePrefix = 512
+
+var (
+	createTableExp = regexp.MustCompile("^CREATE TABLE (?:IF NOT EXISTS )?`(.+?)`")
+	insertTableExp = regexp.MustCompile("^INSERT INTO `(.+?)` ")
+)
+
+// SetProgressHandler reports the progress of Dump, DumpToDir and DumpAndParse
+// per table to h, nil disables it.
+func (d *Dumper) SetProgressHandler(h ProgressHandler) {
+	d.progress = h
+}
+
+// progressWriter counts the bytes written per table, the table is found from
+// the statements at the start of the lines written by mysqldump.
+type progressWriter struct {
+	w io.Writer
+	h ProgressHandler
+
+	db    string
+	table string
+	n     int64
+
+	// line is the start of the current line, lineSize its size so far
+	line     []byte
+	lineSize int64
+}
+
+func newProgressWriter(w io.Writer, h ProgressHandler, db string) *progressWriter {
+	return &progressWriter{w: w, h: h, db: db, line: make([]byte, 0, progressLinePrefix)}
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+
+	for data := p[:n]; len(data) > 0; {
+		i := bytes.IndexByte(data, '\n')
+		end := len(data)
+		if i >= 0 {
+			end = i + 1
+		}
+		if room := progressLinePrefix - len(w.line); room > 0 {
+			w.line = append(w.line, data[:min(end, room)]...)
+		}
+		w.lineSize += int64(end)
+		data = data[end:]
+
+		if i >= 0 {
+			w.endLine()
+		}
+	}
+
+	if w.table != "" && n > 0 {
+		w.h(w.db, w.table, w.n)
+	}
+	return n, err
+}
+
+// endLine switches the database or the table if the line starts a new one, the
+// line is counted in the new table.
+func (w *progressWriter) endLine() {
+	var db, table string
+	if m := useExp.FindSubmatch(w.line); m != nil {
+		db = string(m[1])
+	} else if m := createTableExp.FindSubmatch(w.line); m != nil {
+		table = string(m[1])
+	} else if m := insertTableExp.FindSubmatch(w.line); m != nil {
+		table = string(m[1])
+	}
+
+	if db != "" && db != w.db {
+		w.switchTable(db, "")
+	} else if table != "" && table != w.table {
+		w.switchTable(w.db, table)
+	}
+
+	w.n += w.lineSize
+	w.line = w.line[:0]
+	w.lineSize = 0
+}
+
+func (w *progressWriter) switchTable(db, table string) {
+	if w.table != "" {
+		w.h(w.db, w.table, w.n)
+	}
+	w.db, w.table, w.n = db, table, 0
+}
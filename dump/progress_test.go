@@ -0,0 +1,40 @@
+package dump
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressWriter(t *testing.T) {
+	type progress struct {
+		db, table string
+		bytes     int64
+	}
+	var reports []progress
+	var out bytes.Buffer
+	w := newProgressWriter(&out, func(db, table string, bytes int64) {
+		reports = append(reports, progress{db, table, bytes})
+	}, "")
+
+	create := "CREATE TABLE `t1` (\n  `id` int\n);\n"
+	insert1 := "INSERT INTO `t1` VALUES (1);\n"
+	insert2 := "INSERT INTO `t2` VALUES (2);\n"
+	dump := "USE `db1`;\n" + create + insert1 + "USE `db2`;\n" + insert2
+
+	// split in the middle of the lines
+	for _, s := range []string{dump[:5], dump[5:20], dump[20:60], dump[60:]} {
+		n, err := w.Write([]byte(s))
+		require.NoError(t, err)
+		require.Equal(t, len(s), n)
+	}
+	require.Equal(t, dump, out.String())
+
+	// the insert into t1 is not complete after the third write
+	require.Equal(t, []progress{
+		{"db1", "t1", int64(len(create))},
+		{"db1", "t1", int64(len(create + insert1))},
+		{"db2", "t2", int64(len(insert2))},
+	}, reports)
+}
@@ -0,0 +1,116 @@
+// Package parserdriver is the driver of the values of the TiDB parser, for the
+// packages of this module parsing SQL.
+//
+// The parser requires a single driver per program, set by ast.NewValueExpr:
+// canal installs this one, instead of the heavier types of TiDB, and server
+// reads the values of any driver, installing this one only if the program has
+// none.
+package parserdriver
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/format"
+)
+
+// Install sets the driver of the TiDB parser to this one.
+func Install() {
+	ast.NewValueExpr = newValueExpr
+	ast.NewParamMarkerExpr = newParamExpr
+	ast.NewDecimal = func(s string) (interface{}, error) {
+		return Decimal(s), nil
+	}
+	ast.NewHexLiteral = func(s string) (interface{}, error) {
+		return Literal(s), nil
+	}
+	ast.NewBitLiteral = func(s string) (interface{}, error) {
+		return Literal(s), nil
+	}
+}
+
+var ensureOnce sync.Once
+
+// Ensure installs this driver if the program has none, like the ones of the
+// parser_driver and test_driver packages of TiDB.
+func Ensure() {
+	ensureOnce.Do(func() {
+		if ast.NewValueExpr == nil {
+			Install()
+		}
+	})
+}
+
+// Decimal is the value of a decimal literal, like 1.5, its text.
+type Decimal string
+
+func (d Decimal) String() string { return string(d) }
+
+// Literal is the value of a hexadecimal or bit literal, like 0x41 or b'1',
+// its text.
+type Literal string
+
+type paramExpr struct {
+	valueExpr
+}
+
+func newParamExpr(_ int) ast.ParamMarkerExpr {
+	return &paramExpr{}
+}
+func (pe *paramExpr) SetOrder(o int) {}
+
+// valueExpr is a literal, its value is nil for NULL, a bool, an int64, an
+// uint64, a float64, a string, a Decimal or a Literal.
+type valueExpr struct {
+	ast.TexprNode
+	value interface{}
+}
+
+func newValueExpr(value interface{}, _ string, _ string) ast.ValueExpr {
+	return &valueExpr{value: value}
+}
+func (ve *valueExpr) SetValue(val interface{})       { ve.value = val }
+func (ve *valueExpr) GetValue() interface{}          { return ve.value }
+func (ve *valueExpr) GetDatumString() string         { return ve.GetString() }
+func (ve *valueExpr) GetProjectionOffset() int       { return 0 }
+func (ve *valueExpr) SetProjectionOffset(offset int) {}
+func (ve *valueExpr) Format(w io.Writer)             { fmt.Fprint(w, ve.GetString()) }
+
+func (ve *valueExpr) GetString() string {
+	switch v := ve.value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case Decimal:
+		return string(v)
+	case Literal:
+		return string(v)
+	}
+	return fmt.Sprint(ve.value)
+}
+
+func (ve *valueExpr) Restore(ctx *format.RestoreCtx) error {
+	switch v := ve.value.(type) {
+	case nil:
+		ctx.WriteKeyWord("NULL")
+	case bool:
+		ctx.WriteKeyWord(strconv.FormatBool(v))
+	case string:
+		ctx.WriteString(v)
+	default:
+		ctx.WritePlain(ve.GetString())
+	}
+	return nil
+}
+
+func (ve *valueExpr) Accept(v ast.Visitor) (ast.Node, bool) {
+	newNode, skipChildren := v.Enter(ve)
+	if skipChildren {
+		return v.Leave(newNode)
+	}
+	return v.Leave(ve)
+}
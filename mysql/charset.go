@@ -0,0 +1,116 @@
+package mysql
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/pkg/parser/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+// charsetEncodings are the encodings of the character sets converted by ToUTF8.
+var charsetEncodings = map[string]encoding.Encoding{
+	// latin1 of MySQL is cp1252
+	"latin1":   charmap.Windows1252,
+	"latin2":   charmap.ISO8859_2,
+	"latin5":   charmap.ISO8859_9,
+	"latin7":   charmap.ISO8859_13,
+	"greek":    charmap.ISO8859_7,
+	"hebrew":   charmap.ISO8859_8,
+	"cp1250":   charmap.Windows1250,
+	"cp1251":   charmap.Windows1251,
+	"cp1256":   charmap.Windows1256,
+	"cp1257":   charmap.Windows1257,
+	"cp850":    charmap.CodePage850,
+	"cp852":    charmap.CodePage852,
+	"cp866":    charmap.CodePage866,
+	"koi8r":    charmap.KOI8R,
+	"koi8u":    charmap.KOI8U,
+	"macroman": charmap.Macintosh,
+	"tis620":   charmap.Windows874,
+	// GBK is a superset of gb2312, EUC-CN
+	"gbk":     simplifiedchinese.GBK,
+	"gb2312":  simplifiedchinese.GBK,
+	"gb18030": simplifiedchinese.GB18030,
+	"big5":    traditionalchinese.Big5,
+	"sjis":    japanese.ShiftJIS,
+	"cp932":   japanese.ShiftJIS,
+	"ujis":    japanese.EUCJP,
+	"eucjpms": japanese.EUCJP,
+	"euckr":   korean.EUCKR,
+}
+
+// CollationEncoding returns the encoding of the character set of the collation
+// id, false if the values of the collation don't need to be converted to UTF-8:
+// utf8, utf8mb4, ascii, binary, or a character set not supported.
+func CollationEncoding(id uint64) (encoding.Encoding, bool) {
+	c, err := charset.GetCollationByID(int(id))
+	if err != nil {
+		return nil, false
+	}
+	enc, ok := charsetEncodings[c.CharsetName]
+	return enc, ok
+}
+
+// ToUTF8 converts data of the character set of the collation id to UTF-8, data
+// is returned as is if it doesn't need to be converted, see CollationEncoding.
+func ToUTF8(collationID uint64, data []byte) ([]byte, error) {
+	enc, ok := CollationEncoding(collationID)
+	if !ok || isASCII(data) {
+		return data, nil
+	}
+	b, err := enc.NewDecoder().Bytes(data)
+	return b, errors.Trace(err)
+}
+
+func isASCII(data []byte) bool {
+	for _, c := range data {
+		if c >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// isCharacterType returns whether the values of the field type tp are text in
+// the character set of the field, or binary if it is the binary collation.
+func isCharacterType(tp uint8) bool {
+	switch tp {
+	case MYSQL_TYPE_VARCHAR, MYSQL_TYPE_VAR_STRING, MYSQL_TYPE_STRING,
+		MYSQL_TYPE_ENUM, MYSQL_TYPE_SET,
+		MYSQL_TYPE_TINY_BLOB, MYSQL_TYPE_MEDIUM_BLOB, MYSQL_TYPE_LONG_BLOB, MYSQL_TYPE_BLOB:
+		return true
+	}
+	return false
+}
+
+// ConvertRowToUTF8 converts the string values of row of the character columns
+// of fields in other character sets than UTF-8 to UTF-8, by the collation of
+// the fields, see ToUTF8.
+func ConvertRowToUTF8(fields []*Field, row []FieldValue) error {
+	for i := range row {
+		if i >= len(fields) || row[i].Type != FieldValueTypeString || !isCharacterType(fields[i].Type) {
+			continue
+		}
+		b, err := ToUTF8(uint64(fields[i].Charset), row[i].str)
+		if err != nil {
+			return errors.Annotatef(err, "convert column %s to UTF-8", fields[i].Name)
+		}
+		row[i].str = b
+	}
+	return nil
+}
+
+// ConvertToUTF8 converts the values of r like ConvertRowToUTF8, RowDatas are
+// not changed.
+func (r *Resultset) ConvertToUTF8() error {
+	for _, row := range r.Values {
+		if err := ConvertRowToUTF8(r.Fields, row); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToUTF8(t *testing.T) {
+	// latin1_swedish_ci
+	b, err := ToUTF8(8, []byte("caf\xe9"))
+	require.NoError(t, err)
+	require.Equal(t, "café", string(b))
+
+	// gbk_chinese_ci
+	b, err = ToUTF8(28, []byte("\xd6\xd0\xce\xc4"))
+	require.NoError(t, err)
+	require.Equal(t, "中文", string(b))
+
+	// utf8mb4, binary and unknown collations are not converted
+	for _, id := range []uint64{45, 63, 100000} {
+		b, err = ToUTF8(id, []byte("caf\xe9"))
+		require.NoError(t, err)
+		require.Equal(t, "caf\xe9", string(b))
+	}
+}
+
+func TestResultsetConvertToUTF8(t *testing.T) {
+	r := &Resultset{
+		Fields: []*Field{
+			{Name: []byte("name"), Type: MYSQL_TYPE_VAR_STRING, Charset: 8},
+			{Name: []byte("data"), Type: MYSQL_TYPE_BLOB, Charset: 63},
+			{Name: []byte("id"), Type: MYSQL_TYPE_LONGLONG, Charset: 63},
+		},
+		Values: [][]FieldValue{{
+			NewFieldValue(FieldValueTypeString, 0, []byte("caf\xe9")),
+			NewFieldValue(FieldValueTypeString, 0, []byte("\xe9")),
+			NewFieldValue(FieldValueTypeSigned, 1, nil),
+		}, {
+			NewFieldValue(FieldValueTypeNull, 0, nil),
+			NewFieldValue(FieldValueTypeNull, 0, nil),
+			NewFieldValue(FieldValueTypeSigned, 2, nil),
+		}},
+	}
+	require.NoError(t, r.ConvertToUTF8())
+	require.Equal(t, "café", string(r.Values[0][0].AsString()))
+	require.Equal(t, "\xe9", string(r.Values[0][1].AsString()))
+	require.Equal(t, int64(1), r.Values[0][2].AsInt64())
+	require.Nil(t, r.Values[1][0].Value())
+}
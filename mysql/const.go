@@ -25,6 +25,7 @@ const (
 	AUTH_CACHING_SHA2_PASSWORD = "caching_sha2_password"
 	AUTH_SHA256_PASSWORD       = "sha256_password"
 	AUTH_MARIADB_ED25519       = "client_ed25519"
+	AUTH_KERBEROS_CLIENT       = "authentication_kerberos_client"
 )
 
 // SERVER_STATUS_flags_enum
@@ -0,0 +1,471 @@
+package mysql
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pingcap/errors"
+	"github.com/shopspring/decimal"
+)
+
+// EvalDefault evaluates def, the default value of a column of type columnType
+// like "datetime(3)" or "int unsigned", for a row inserted at now, e.g. to fill
+// the columns missing from the rows of a minimal row image. def is written like
+// in SHOW CREATE TABLE: NULL, the literals, like 'abc', 1.5, b'101' or x'4142',
+// CURRENT_TIMESTAMP and the other functions of the current date and time, with
+// their precision, and the expressions of MySQL 8.0.13 made of them with +, -,
+// * and /, like (1 + 2). Unlike SHOW CREATE TABLE, the COLUMN_DEFAULT of
+// information_schema.COLUMNS of MySQL has the string literals unquoted, the
+// expressions being marked DEFAULT_GENERATED in EXTRA.
+//
+// The value is nil for NULL, an int64, or uint64 for the unsigned columns, for
+// the integers, BIT and YEAR, a float64 for FLOAT and DOUBLE, and a string for
+// the other types, DECIMAL with the scale of the column and the temporal types
+// like their MySQL literals. The other expressions, like UUID(), return an
+// error.
+func EvalDefault(def string, columnType string, now time.Time) (interface{}, error) {
+	p := &defaultParser{s: def, now: now}
+	v, err := p.parseExpr()
+	if err != nil {
+		return nil, errors.Annotatef(err, "default %s", def)
+	}
+	p.skipSpaces()
+	if p.pos < len(p.s) {
+		return nil, errors.Errorf("unsupported default %s", def)
+	}
+	if v.kind == defaultNull {
+		return nil, nil
+	}
+	r, err := v.convert(strings.ToLower(strings.TrimSpace(columnType)))
+	if err != nil {
+		return nil, errors.Annotatef(err, "default %s", def)
+	}
+	return r, nil
+}
+
+type defaultKind int
+
+const (
+	defaultNull defaultKind = iota
+	defaultNumber
+	defaultString
+	// a hex or bit literal
+	defaultBytes
+	defaultDate
+	defaultDatetime
+	defaultTime
+)
+
+type defaultValue struct {
+	kind defaultKind
+	num  decimal.Decimal
+	str  string
+	time time.Time
+	fsp  int
+}
+
+// defaultParser parses and evaluates a default expression.
+type defaultParser struct {
+	s   string
+	pos int
+	now time.Time
+}
+
+func (p *defaultParser) skipSpaces() {
+	for p.pos < len(p.s) && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+// peek returns the next character, 0 at the end.
+func (p *defaultParser) peek() byte {
+	p.skipSpaces()
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *defaultParser) parseExpr() (defaultValue, error) {
+	v, err := p.parseTerm()
+	if err != nil {
+		return v, err
+	}
+	for {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return v, nil
+		}
+		p.pos++
+		w, err := p.parseTerm()
+		if err != nil {
+			return v, err
+		}
+		if v, err = arithmetic(op, v, w); err != nil {
+			return v, err
+		}
+	}
+}
+
+func (p *defaultParser) parseTerm() (defaultValue, error) {
+	v, err := p.parseFactor()
+	if err != nil {
+		return v, err
+	}
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' {
+			return v, nil
+		}
+		p.pos++
+		w, err := p.parseFactor()
+		if err != nil {
+			return v, err
+		}
+		if v, err = arithmetic(op, v, w); err != nil {
+			return v, err
+		}
+	}
+}
+
+func (p *defaultParser) parseFactor() (defaultValue, error) {
+	switch c := p.peek(); {
+	case c == '(':
+		p.pos++
+		v, err := p.parseExpr()
+		if err != nil {
+			return v, err
+		}
+		if p.peek() != ')' {
+			return v, errors.New("missing )")
+		}
+		p.pos++
+		return v, nil
+	case c == '-' || c == '+':
+		p.pos++
+		v, err := p.parseFactor()
+		if err != nil || c == '+' {
+			return v, err
+		}
+		return arithmetic('-', defaultValue{kind: defaultNumber}, v)
+	case c == '\'' || c == '"':
+		s, err := p.parseQuoted(c)
+		return defaultValue{kind: defaultString, str: s}, err
+	case c >= '0' && c <= '9' || c == '.':
+		return p.parseNumber()
+	case c == '_' || unicode.IsLetter(rune(c)):
+		return p.parseWord()
+	case c == 0:
+		return defaultValue{}, errors.New("unexpected end")
+	default:
+		return defaultValue{}, errors.Errorf("unexpected %c", c)
+	}
+}
+
+// parseQuoted parses a string literal quoted by q, with its escapes.
+func (p *defaultParser) parseQuoted(q byte) (string, error) {
+	var b strings.Builder
+	p.pos++
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == q && p.pos < len(p.s) && p.s[p.pos] == q:
+			b.WriteByte(q)
+			p.pos++
+		case c == q:
+			return b.String(), nil
+		case c == '\\' && p.pos < len(p.s):
+			c = p.s[p.pos]
+			p.pos++
+			switch c {
+			case '0':
+				c = 0
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'Z':
+				c = 26
+			}
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", errors.New("unterminated string")
+}
+
+func (p *defaultParser) parseNumber() (defaultValue, error) {
+	if strings.HasPrefix(p.s[p.pos:], "0x") || strings.HasPrefix(p.s[p.pos:], "0b") {
+		base := p.s[p.pos+1]
+		p.pos += 2
+		start := p.pos
+		for p.pos < len(p.s) && isWordChar(p.s[p.pos]) {
+			p.pos++
+		}
+		return bytesLiteral(base, p.s[start:p.pos])
+	}
+
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c >= '0' && c <= '9' || c == '.' {
+			p.pos++
+		} else if (c == 'e' || c == 'E') && p.pos+1 < len(p.s) {
+			p.pos++
+			if p.s[p.pos] == '-' || p.s[p.pos] == '+' {
+				p.pos++
+			}
+		} else {
+			break
+		}
+	}
+	d, err := decimal.NewFromString(p.s[start:p.pos])
+	if err != nil {
+		return defaultValue{}, errors.Trace(err)
+	}
+	return defaultValue{kind: defaultNumber, num: d}, nil
+}
+
+func (p *defaultParser) parseWord() (defaultValue, error) {
+	start := p.pos
+	for p.pos < len(p.s) && isWordChar(p.s[p.pos]) {
+		p.pos++
+	}
+	word := strings.ToUpper(p.s[start:p.pos])
+
+	// x'41', b'1' and the strings with a charset introducer, like _utf8mb4'a'
+	if p.pos < len(p.s) && p.s[p.pos] == '\'' {
+		switch {
+		case word == "X" || word == "B":
+			s, err := p.parseQuoted('\'')
+			if err != nil {
+				return defaultValue{}, err
+			}
+			return bytesLiteral(word[0]|0x20, s)
+		case word[0] == '_':
+			s, err := p.parseQuoted('\'')
+			return defaultValue{kind: defaultString, str: s}, err
+		}
+	}
+
+	switch word {
+	case "NULL":
+		return defaultValue{kind: defaultNull}, nil
+	case "TRUE":
+		return defaultValue{kind: defaultNumber, num: decimal.NewFromInt(1)}, nil
+	case "FALSE":
+		return defaultValue{kind: defaultNumber, num: decimal.Zero}, nil
+	}
+
+	v := defaultValue{time: p.now}
+	switch word {
+	case "CURRENT_TIMESTAMP", "NOW", "LOCALTIME", "LOCALTIMESTAMP", "SYSDATE":
+		v.kind = defaultDatetime
+	case "UTC_TIMESTAMP":
+		v.kind, v.time = defaultDatetime, p.now.UTC()
+	case "CURRENT_DATE", "CURDATE":
+		v.kind = defaultDate
+	case "UTC_DATE":
+		v.kind, v.time = defaultDate, p.now.UTC()
+	case "CURRENT_TIME", "CURTIME":
+		v.kind = defaultTime
+	case "UTC_TIME":
+		v.kind, v.time = defaultTime, p.now.UTC()
+	default:
+		return v, errors.Errorf("unsupported function %s", word)
+	}
+
+	// the precision, CURRENT_TIMESTAMP(3)
+	if p.peek() == '(' {
+		p.pos++
+		if c := p.peek(); c >= '0' && c <= '6' {
+			v.fsp = int(c - '0')
+			p.pos++
+		}
+		if p.peek() != ')' {
+			return v, errors.Errorf("invalid arguments of %s", word)
+		}
+		p.pos++
+	}
+	return v, nil
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// bytesLiteral returns the bytes of a hex, base 'x', or bit, base 'b', literal.
+func bytesLiteral(base byte, digits string) (defaultValue, error) {
+	if base == 'x' {
+		if len(digits)%2 != 0 {
+			digits = "0" + digits
+		}
+		b, err := hex.DecodeString(digits)
+		if err != nil {
+			return defaultValue{}, errors.Trace(err)
+		}
+		return defaultValue{kind: defaultBytes, str: string(b)}, nil
+	}
+
+	b := make([]byte, (len(digits)+7)/8)
+	for i := 0; i < len(digits); i++ {
+		bit := len(digits) - 1 - i
+		switch digits[i] {
+		case '1':
+			b[len(b)-1-bit/8] |= 1 << (bit % 8)
+		case '0':
+		default:
+			return defaultValue{}, errors.Errorf("invalid bit literal %s", digits)
+		}
+	}
+	return defaultValue{kind: defaultBytes, str: string(b)}, nil
+}
+
+// number returns v as a number, the strings are converted like MySQL does in
+// arithmetic, their numeric prefix.
+func (v defaultValue) number() (decimal.Decimal, error) {
+	switch v.kind {
+	case defaultNumber:
+		return v.num, nil
+	case defaultString:
+		s := strings.TrimSpace(v.str)
+		end := 0
+		for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.' || end == 0 && (s[end] == '-' || s[end] == '+')) {
+			end++
+		}
+		d, err := decimal.NewFromString(s[:end])
+		if err != nil {
+			return decimal.Zero, nil
+		}
+		return d, nil
+	case defaultBytes:
+		var u uint64
+		for i := 0; i < len(v.str); i++ {
+			u = u<<8 | uint64(v.str[i])
+		}
+		return decimal.NewFromBigInt(new(big.Int).SetUint64(u), 0), nil
+	default:
+		return decimal.Zero, errors.New("unsupported arithmetic of a date or time")
+	}
+}
+
+func arithmetic(op byte, v, w defaultValue) (defaultValue, error) {
+	if v.kind == defaultNull || w.kind == defaultNull {
+		return defaultValue{kind: defaultNull}, nil
+	}
+	a, err := v.number()
+	if err != nil {
+		return v, err
+	}
+	b, err := w.number()
+	if err != nil {
+		return v, err
+	}
+	r := defaultValue{kind: defaultNumber}
+	switch op {
+	case '+':
+		r.num = a.Add(b)
+	case '-':
+		r.num = a.Sub(b)
+	case '*':
+		r.num = a.Mul(b)
+	case '/':
+		if b.IsZero() {
+			return defaultValue{kind: defaultNull}, nil
+		}
+		// div_precision_increment is 4 by default
+		r.num = a.DivRound(b, int32(a.Exponent()*-1)+4)
+	}
+	return r, nil
+}
+
+// format returns the MySQL literal of a date or time.
+func (v defaultValue) format() string {
+	layout := "2006-01-02 15:04:05"
+	switch v.kind {
+	case defaultDate:
+		return v.time.Format("2006-01-02")
+	case defaultTime:
+		layout = "15:04:05"
+	}
+	if v.fsp > 0 {
+		layout += "." + strings.Repeat("0", v.fsp)
+	}
+	return v.time.Format(layout)
+}
+
+func (v defaultValue) convert(columnType string) (interface{}, error) {
+	base := columnType
+	if i := strings.IndexAny(base, "( "); i >= 0 {
+		base = base[:i]
+	}
+	isTemporal := v.kind == defaultDate || v.kind == defaultDatetime || v.kind == defaultTime
+
+	switch base {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint", "year", "bit", "bool", "boolean":
+		if isTemporal {
+			return nil, errors.Errorf("invalid default of %s", columnType)
+		}
+		d, err := v.number()
+		if err != nil {
+			return nil, err
+		}
+		d = d.Round(0)
+		if strings.Contains(columnType, "unsigned") || base == "bit" {
+			u, err := strconv.ParseUint(d.String(), 10, 64)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if base == "bit" {
+				return int64(u), nil
+			}
+			return u, nil
+		}
+		return d.IntPart(), nil
+	case "float", "double", "real":
+		if isTemporal {
+			return nil, errors.Errorf("invalid default of %s", columnType)
+		}
+		d, err := v.number()
+		if err != nil {
+			return nil, err
+		}
+		f, _ := d.Float64()
+		return f, nil
+	case "decimal", "numeric", "dec", "fixed":
+		if isTemporal {
+			return nil, errors.Errorf("invalid default of %s", columnType)
+		}
+		d, err := v.number()
+		if err != nil {
+			return nil, err
+		}
+		scale := 0
+		if i := strings.IndexByte(columnType, ','); i >= 0 {
+			if j := strings.IndexByte(columnType[i:], ')'); j >= 0 {
+				scale, _ = strconv.Atoi(strings.TrimSpace(columnType[i+1 : i+j]))
+			}
+		}
+		return d.StringFixed(int32(scale)), nil
+	}
+
+	switch v.kind {
+	case defaultNumber:
+		return v.num.String(), nil
+	case defaultDate, defaultDatetime, defaultTime:
+		if base == "date" {
+			v.kind = defaultDate
+		}
+		return v.format(), nil
+	default:
+		return v.str, nil
+	}
+}
@@ -0,0 +1,62 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvalDefault(t *testing.T) {
+	now := time.Date(2024, 3, 5, 10, 20, 30, 123456789, time.FixedZone("", 3600))
+
+	cases := []struct {
+		def        string
+		columnType string
+		expected   interface{}
+	}{
+		{"NULL", "int", nil},
+		{"0", "int", int64(0)},
+		{"-5", "bigint", int64(-5)},
+		{"'7'", "int unsigned", uint64(7)},
+		{"1.5", "tinyint", int64(2)},
+		{"1.5", "double", 1.5},
+		{"'1.25'", "decimal(10,3)", "1.250"},
+		{"b'101'", "bit(3)", int64(5)},
+		{"x'0102'", "bit(16)", int64(258)},
+		{"TRUE", "tinyint(1)", int64(1)},
+		{"2024", "year", int64(2024)},
+		{"'abc'", "varchar(10)", "abc"},
+		{"'it''s \\n'", "text", "it's \n"},
+		{"_utf8mb4'abc'", "varchar(10)", "abc"},
+		{"(_utf8mb4'abc')", "varchar(10)", "abc"},
+		{"x'4142'", "varbinary(2)", "AB"},
+		{"12", "varchar(10)", "12"},
+		{"CURRENT_TIMESTAMP", "timestamp", "2024-03-05 10:20:30"},
+		{"CURRENT_TIMESTAMP(3)", "datetime(3)", "2024-03-05 10:20:30.123"},
+		{"now(6)", "datetime(6)", "2024-03-05 10:20:30.123456"},
+		{"LOCALTIMESTAMP()", "datetime", "2024-03-05 10:20:30"},
+		{"UTC_TIMESTAMP", "datetime", "2024-03-05 09:20:30"},
+		{"(curdate())", "date", "2024-03-05"},
+		{"CURRENT_TIMESTAMP", "date", "2024-03-05"},
+		{"curtime()", "time", "10:20:30"},
+		{"'2020-01-01 00:00:00'", "datetime", "2020-01-01 00:00:00"},
+		{"(1 + 2 * 3)", "int", int64(7)},
+		{"((1 + 2) * 3)", "int", int64(9)},
+		{"(10 / 4)", "decimal(5,2)", "2.50"},
+		{"(-(2 - 5))", "int", int64(3)},
+		{"(1 / 0)", "int", nil},
+	}
+	for _, c := range cases {
+		v, err := EvalDefault(c.def, c.columnType, now)
+		require.NoError(t, err, c.def)
+		require.Equal(t, c.expected, v, c.def)
+	}
+
+	for _, def := range []string{"(uuid())", "'abc", "(1 + 2", "1 2", "CURRENT_TIMESTAMP + 1"} {
+		_, err := EvalDefault(def, "varchar(36)", now)
+		require.Error(t, err, def)
+	}
+	_, err := EvalDefault("CURRENT_TIMESTAMP", "int", now)
+	require.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package mysql
+
+import (
+	"errors"
+)
+
+// Error codes of MySQL 5.7 and later used by the classification, errcode.go
+// stops at MySQL 5.6.
+const (
+	ER_CONNECTION_KILLED          = 1927
+	ER_QUERY_TIMEOUT              = 3024
+	ER_ACCOUNT_HAS_BEEN_LOCKED    = 3118
+	ER_LOCK_NOWAIT                = 3572
+	ER_CLIENT_INTERACTION_TIMEOUT = 4031
+)
+
+// MyErrorCode returns the code of the MyError in the chain of err.
+func MyErrorCode(err error) (uint16, bool) {
+	var e *MyError
+	if !errors.As(err, &e) {
+		return 0, false
+	}
+	return e.Code, true
+}
+
+func errorCodeIn(err error, codes ...uint16) bool {
+	code, ok := MyErrorCode(err)
+	if !ok {
+		return false
+	}
+	for _, c := range codes {
+		if code == c {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRetryable reports whether err is a server error caused by a transient
+// condition, so retrying the statement, or the whole transaction for a deadlock,
+// may succeed: lock conflicts, too many connections or transactions, a server
+// shutting down, or a server switched to read only by a failover.
+//
+// Network errors are not MyErrors and are not reported, as the statement may
+// have been executed.
+func IsRetryable(err error) bool {
+	return errorCodeIn(err,
+		ER_LOCK_DEADLOCK,
+		ER_LOCK_WAIT_TIMEOUT,
+		ER_LOCK_NOWAIT,
+		ER_LOCK_ABORTED,
+		ER_TOO_MANY_CONCURRENT_TRXS,
+		ER_CON_COUNT_ERROR,
+		ER_TOO_MANY_USER_CONNECTIONS,
+		ER_SERVER_SHUTDOWN,
+		ER_CONNECTION_KILLED,
+		ER_OPTION_PREVENTS_STATEMENT,
+		ER_READ_ONLY_MODE,
+	)
+}
+
+// IsDeadlock reports whether err is a deadlock, the transaction was rolled back.
+func IsDeadlock(err error) bool {
+	return errorCodeIn(err, ER_LOCK_DEADLOCK)
+}
+
+// IsAccessDenied reports whether err is caused by the account or its privileges:
+// a wrong password, missing privileges, a locked account or an expired password.
+func IsAccessDenied(err error) bool {
+	return errorCodeIn(err,
+		ER_DBACCESS_DENIED_ERROR,
+		ER_ACCESS_DENIED_ERROR,
+		ER_ACCESS_DENIED_NO_PASSWORD_ERROR,
+		ER_TABLEACCESS_DENIED_ERROR,
+		ER_COLUMNACCESS_DENIED_ERROR,
+		ER_PROCACCESS_DENIED_ERROR,
+		ER_SPECIFIC_ACCESS_DENIED_ERROR,
+		ER_MUST_CHANGE_PASSWORD,
+		ER_MUST_CHANGE_PASSWORD_LOGIN,
+		ER_ACCOUNT_HAS_BEEN_LOCKED,
+	)
+}
+
+// IsSyntaxError reports whether err is caused by an invalid or empty statement.
+func IsSyntaxError(err error) bool {
+	return errorCodeIn(err,
+		ER_PARSE_ERROR,
+		ER_SYNTAX_ERROR,
+		ER_EMPTY_QUERY,
+	)
+}
+
+// IsDuplicateKey reports whether err is a violation of a primary or unique key.
+func IsDuplicateKey(err error) bool {
+	return errorCodeIn(err,
+		ER_DUP_ENTRY,
+		ER_DUP_KEY,
+		ER_DUP_UNIQUE,
+		ER_DUP_ENTRY_WITH_KEY_NAME,
+	)
+}
+
+// IsTimeout reports whether err is a statement or session timeout of the server:
+// max_execution_time, lock_wait_timeout or the client being idle too long.
+func IsTimeout(err error) bool {
+	return errorCodeIn(err,
+		ER_QUERY_TIMEOUT,
+		ER_LOCK_WAIT_TIMEOUT,
+		ER_CLIENT_INTERACTION_TIMEOUT,
+	)
+}
@@ -0,0 +1,37 @@
+package mysql
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pingcap/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorClass(t *testing.T) {
+	deadlock := NewDefaultError(ER_LOCK_DEADLOCK)
+
+	code, ok := MyErrorCode(errors.Trace(deadlock))
+	require.True(t, ok)
+	require.Equal(t, uint16(ER_LOCK_DEADLOCK), code)
+	_, ok = MyErrorCode(ErrBadConn)
+	require.False(t, ok)
+
+	require.True(t, IsRetryable(deadlock))
+	require.True(t, IsRetryable(fmt.Errorf("exec: %w", errors.Trace(deadlock))))
+	require.True(t, IsDeadlock(deadlock))
+	require.False(t, IsRetryable(ErrBadConn))
+	require.False(t, IsRetryable(nil))
+	require.False(t, IsRetryable(NewDefaultError(ER_PARSE_ERROR, "", "", 1)))
+
+	require.True(t, IsAccessDenied(NewDefaultError(ER_ACCESS_DENIED_ERROR, "root", "localhost", "YES")))
+	require.True(t, IsAccessDenied(NewError(ER_ACCOUNT_HAS_BEEN_LOCKED, "locked")))
+	require.False(t, IsAccessDenied(deadlock))
+
+	require.True(t, IsSyntaxError(NewDefaultError(ER_PARSE_ERROR, "", "", 1)))
+	require.False(t, IsSyntaxError(deadlock))
+
+	require.True(t, IsDuplicateKey(NewDefaultError(ER_DUP_ENTRY, "1", "PRIMARY")))
+	require.True(t, IsTimeout(NewError(ER_QUERY_TIMEOUT, "timeout")))
+	require.True(t, IsTimeout(NewDefaultError(ER_LOCK_WAIT_TIMEOUT)))
+}
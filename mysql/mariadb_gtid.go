@@ -1,7 +1,8 @@
 package mysql
 
 import (
-	"bytes"
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"sort"
@@ -143,12 +144,17 @@ func (s *MariadbGTIDSet) AddSet(gtid *MariadbGTID) error {
 	return nil
 }
 
-// Update updates mariadb gtid set
+// Update updates mariadb gtid set. GTIDStr is a comma separated list of
+// gtids, like gtid_binlog_pos, the spaces and the newlines around the gtids
+// and the empty items are ignored.
 func (s *MariadbGTIDSet) Update(GTIDStr string) error {
 	sp := strings.Split(GTIDStr, ",")
-	// todo, handle redundant same uuid
 	for i := 0; i < len(sp); i++ {
-		gtid, err := ParseMariadbGTID(sp[i])
+		str := strings.TrimSpace(sp[i])
+		if str == "" {
+			continue
+		}
+		gtid, err := ParseMariadbGTID(str)
 		if err != nil {
 			return errors.Trace(err)
 		}
@@ -160,6 +166,63 @@ func (s *MariadbGTIDSet) Update(GTIDStr string) error {
 	return nil
 }
 
+// Add adds the gtids of addend, keeping the highest sequence number of each
+// domain and server.
+func (s *MariadbGTIDSet) Add(addend MariadbGTIDSet) error {
+	for domainID, set := range addend.Sets {
+		for serverID, gtid := range set {
+			serverSets, ok := s.Sets[domainID]
+			if !ok {
+				serverSets = make(map[uint32]*MariadbGTID)
+				s.Sets[domainID] = serverSets
+			}
+			if o, ok := serverSets[serverID]; !ok || o.SequenceNumber < gtid.SequenceNumber {
+				serverSets[serverID] = gtid.Clone()
+			}
+		}
+	}
+	return nil
+}
+
+// Minus removes the gtids covered by subtrahend, those of its domains whose
+// sequence number isn't higher than the last one of the domain in subtrahend,
+// so the set is left with the transactions subtrahend is missing.
+func (s *MariadbGTIDSet) Minus(subtrahend MariadbGTIDSet) error {
+	for domainID, set := range s.Sets {
+		last, ok := subtrahend.domainSequence(domainID)
+		if !ok {
+			continue
+		}
+		for serverID, gtid := range set {
+			if gtid.SequenceNumber <= last {
+				delete(set, serverID)
+			}
+		}
+		if len(set) == 0 {
+			delete(s.Sets, domainID)
+		}
+	}
+	return nil
+}
+
+// domainSequence returns the highest sequence number of the domain, false if
+// the set has no gtid of the domain. The sequence numbers are increasing within
+// a domain whatever the server, a gtid with a lower sequence number of the
+// domain is older.
+func (s *MariadbGTIDSet) domainSequence(domainID uint32) (uint64, bool) {
+	set, ok := s.Sets[domainID]
+	if !ok || len(set) == 0 {
+		return 0, false
+	}
+	var last uint64
+	for _, gtid := range set {
+		if gtid.SequenceNumber > last {
+			last = gtid.SequenceNumber
+		}
+	}
+	return last, true
+}
+
 func (s *MariadbGTIDSet) String() string {
 	sets := make([]string, 0, len(s.Sets))
 	for _, set := range s.Sets {
@@ -172,19 +235,10 @@ func (s *MariadbGTIDSet) String() string {
 	return strings.Join(sets, ",")
 }
 
-// Encode encodes mariadb gtid set
+// Encode encodes mariadb gtid set, in the text format of String as MariaDB
+// uses it to start the binlog dump, see MarshalBinary for the binary format.
 func (s *MariadbGTIDSet) Encode() []byte {
-	var buf bytes.Buffer
-	sep := ""
-	for _, set := range s.Sets {
-		for _, gtid := range set {
-			buf.WriteString(sep)
-			buf.WriteString(gtid.String())
-			sep = ","
-		}
-	}
-
-	return buf.Bytes()
+	return []byte(s.String())
 }
 
 // Clone clones a mariadb gtid set
@@ -233,24 +287,23 @@ func (s *MariadbGTIDSet) Equal(o GTIDSet) bool {
 	return true
 }
 
-// Contain return whether one mariadb gtid set covers another mariadb gtid set
+// Contain return whether one mariadb gtid set covers another mariadb gtid set,
+// per domain: the highest sequence number of each domain of o is not higher
+// than the highest one of the domain in s, whatever the servers.
 func (s *MariadbGTIDSet) Contain(o GTIDSet) bool {
 	other, ok := o.(*MariadbGTIDSet)
 	if !ok {
 		return false
 	}
 
-	for doaminID, set := range other.Sets {
-		serverSet, ok := s.Sets[doaminID]
+	for doaminID := range other.Sets {
+		otherLast, ok := other.domainSequence(doaminID)
 		if !ok {
-			return false
+			continue
 		}
-		for serverID, gtid := range set {
-			if o, ok := serverSet[serverID]; !ok {
-				return false
-			} else if !o.Contain(gtid) {
-				return false
-			}
+		last, ok := s.domainSequence(doaminID)
+		if !ok || last < otherLast {
+			return false
 		}
 	}
 
@@ -260,3 +313,96 @@ func (s *MariadbGTIDSet) Contain(o GTIDSet) bool {
 func (s *MariadbGTIDSet) IsEmpty() bool {
 	return len(s.Sets) == 0
 }
+
+// MarshalJSON encodes the set as a JSON string in the MariaDB text format.
+func (s *MariadbGTIDSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON decodes a set from a JSON string in the MariaDB text format.
+func (s *MariadbGTIDSet) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return errors.Trace(err)
+	}
+	set, err := ParseMariadbGTIDSet(str)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	s.Sets = set.(*MariadbGTIDSet).Sets
+	return nil
+}
+
+// MarshalBinary encodes the set in the binary format of the GTID_LIST events of
+// MariaDB, the gtid_binlog_state at the start of a binlog file: the number of
+// gtids as uint32 followed by domain ID (uint32), server ID (uint32) and
+// sequence number (uint64) of each gtid, all little endian and sorted by domain
+// and server ID.
+func (s *MariadbGTIDSet) MarshalBinary() ([]byte, error) {
+	gtids := make([]*MariadbGTID, 0, len(s.Sets))
+	for _, set := range s.Sets {
+		for _, gtid := range set {
+			gtids = append(gtids, gtid)
+		}
+	}
+	sort.Slice(gtids, func(i, j int) bool {
+		if gtids[i].DomainID != gtids[j].DomainID {
+			return gtids[i].DomainID < gtids[j].DomainID
+		}
+		return gtids[i].ServerID < gtids[j].ServerID
+	})
+
+	data := make([]byte, 4, 4+16*len(gtids))
+	binary.LittleEndian.PutUint32(data, uint32(len(gtids)))
+	for _, gtid := range gtids {
+		data = binary.LittleEndian.AppendUint32(data, gtid.DomainID)
+		data = binary.LittleEndian.AppendUint32(data, gtid.ServerID)
+		data = binary.LittleEndian.AppendUint64(data, gtid.SequenceNumber)
+	}
+
+	return data, nil
+}
+
+// UnmarshalBinary decodes a set produced by MarshalBinary.
+func (s *MariadbGTIDSet) UnmarshalBinary(data []byte) error {
+	set, err := DecodeMariadbGTIDSet(data)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	s.Sets = set.Sets
+	return nil
+}
+
+// DecodeMariadbGTIDSet decodes a set produced by MariadbGTIDSet.MarshalBinary,
+// or the body of a GTID_LIST event.
+func DecodeMariadbGTIDSet(data []byte) (*MariadbGTIDSet, error) {
+	if len(data) < 4 {
+		return nil, errors.Errorf("invalid mariadb gtid set buffer, less 4")
+	}
+
+	// the 4 high bits are the flags of the GTID_LIST events
+	n := int(binary.LittleEndian.Uint32(data) & (1<<28 - 1))
+	if len(data) != 4+16*n {
+		return nil, errors.Errorf("invalid mariadb gtid set buffer, must %d, but %d", 4+16*n, len(data))
+	}
+
+	s := &MariadbGTIDSet{
+		Sets: make(map[uint32]map[uint32]*MariadbGTID),
+	}
+
+	pos := 4
+	for i := 0; i < n; i++ {
+		gtid := &MariadbGTID{
+			DomainID:       binary.LittleEndian.Uint32(data[pos:]),
+			ServerID:       binary.LittleEndian.Uint32(data[pos+4:]),
+			SequenceNumber: binary.LittleEndian.Uint64(data[pos+8:]),
+		}
+		pos += 16
+
+		if err := s.AddSet(gtid); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	return s, nil
+}
@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -206,6 +207,9 @@ func TestMariaDBGTIDSetContain(t *testing.T) {
 		{"1-1-1,2-2-2", "1-1-1,2-2-2", true},
 		{"1-1-1,2-2-2", "1-1-1,2-2-1", true},
 		{"1-1-1,2-2-2", "1-1-1,2-2-3", false},
+		// per domain, whatever the server
+		{"1-1-5", "1-3-4", true},
+		{"1-1-5,1-3-2", "1-3-6", false},
 	}
 
 	for _, cs := range cases {
@@ -253,3 +257,65 @@ func TestMariadbGTIDSetIsEmpty(t *testing.T) {
 	require.NoError(t, err)
 	require.False(t, nonEmptyGTIDSet.IsEmpty())
 }
+
+func TestMariadbGTIDSetSerialization(t *testing.T) {
+	gset, err := ParseMariadbGTIDSet("0-1-1,1-2-100,1-3-5")
+	require.NoError(t, err)
+
+	data, err := json.Marshal(gset)
+	require.NoError(t, err)
+	require.Equal(t, `"0-1-1,1-2-100,1-3-5"`, string(data))
+
+	decoded := new(MariadbGTIDSet)
+	require.NoError(t, json.Unmarshal(data, decoded))
+	require.True(t, gset.Equal(decoded))
+
+	b, err := gset.(*MariadbGTIDSet).MarshalBinary()
+	require.NoError(t, err)
+	require.Len(t, b, 4+3*16)
+
+	decoded = new(MariadbGTIDSet)
+	require.NoError(t, decoded.UnmarshalBinary(b))
+	require.True(t, gset.Equal(decoded))
+
+	_, err = DecodeMariadbGTIDSet(b[:len(b)-1])
+	require.Error(t, err)
+
+	// body of a GTID_LIST event, with flags
+	b[3] |= 0x10
+	decoded, err = DecodeMariadbGTIDSet(b)
+	require.NoError(t, err)
+	require.True(t, gset.Equal(decoded))
+
+	empty, err := ParseMariadbGTIDSet("")
+	require.NoError(t, err)
+	b, err = empty.(*MariadbGTIDSet).MarshalBinary()
+	require.NoError(t, err)
+	decoded = new(MariadbGTIDSet)
+	require.NoError(t, decoded.UnmarshalBinary(b))
+	require.True(t, decoded.IsEmpty())
+}
+
+func TestMariadbGTIDSetAddMinus(t *testing.T) {
+	gset, err := ParseMariadbGTIDSet(" 0-1-10,\n1-2-100, ,2-1-3,")
+	require.NoError(t, err)
+	require.Equal(t, "0-1-10,1-2-100,2-1-3", gset.String())
+	require.Equal(t, "0-1-10,1-2-100,2-1-3", string(gset.Encode()))
+	s := gset.(*MariadbGTIDSet)
+
+	other, err := ParseMariadbGTIDSet("0-1-12,1-3-50,3-1-1")
+	require.NoError(t, err)
+	union := s.Clone().(*MariadbGTIDSet)
+	require.NoError(t, union.Add(*other.(*MariadbGTIDSet)))
+	require.Equal(t, "0-1-12,1-2-100,1-3-50,2-1-3,3-1-1", union.String())
+	require.True(t, union.Contain(gset))
+	require.True(t, union.Contain(other))
+
+	// the transactions of gset missing in other
+	diff := s.Clone().(*MariadbGTIDSet)
+	require.NoError(t, diff.Minus(*other.(*MariadbGTIDSet)))
+	require.Equal(t, "1-2-100,2-1-3", diff.String())
+
+	require.NoError(t, diff.Minus(*s))
+	require.True(t, diff.IsEmpty())
+}
@@ -3,6 +3,7 @@ package mysql
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
@@ -579,8 +580,15 @@ func (s *MysqlGTIDSet) Encode() []byte {
 
 	_ = binary.Write(&buf, binary.LittleEndian, uint64(len(s.Sets)))
 
-	for i := range s.Sets {
-		s.Sets[i].encode(&buf)
+	// sort by SID so the same set always encodes to the same bytes
+	sids := make([]string, 0, len(s.Sets))
+	for sid := range s.Sets {
+		sids = append(sids, sid)
+	}
+	sort.Strings(sids)
+
+	for _, sid := range sids {
+		s.Sets[sid].encode(&buf)
 	}
 
 	return buf.Bytes()
@@ -600,3 +608,37 @@ func (gtid *MysqlGTIDSet) Clone() GTIDSet {
 func (s *MysqlGTIDSet) IsEmpty() bool {
 	return len(s.Sets) == 0
 }
+
+// MarshalJSON encodes the set as a JSON string in the MySQL text format.
+func (s *MysqlGTIDSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON decodes a set from a JSON string in the MySQL text format.
+func (s *MysqlGTIDSet) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return errors.Trace(err)
+	}
+	set, err := ParseMysqlGTIDSet(str)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	s.Sets = set.(*MysqlGTIDSet).Sets
+	return nil
+}
+
+// MarshalBinary encodes the set like Encode, the format used by COM_BINLOG_DUMP_GTID.
+func (s *MysqlGTIDSet) MarshalBinary() ([]byte, error) {
+	return s.Encode(), nil
+}
+
+// UnmarshalBinary decodes a set produced by MarshalBinary.
+func (s *MysqlGTIDSet) UnmarshalBinary(data []byte) error {
+	set, err := DecodeMysqlGTIDSet(data)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	s.Sets = set.Sets
+	return nil
+}
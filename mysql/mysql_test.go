@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"testing"
@@ -369,3 +370,34 @@ func TestMysqlGTIDSetIsEmpty(t *testing.T) {
 	nonEmptyGTIDSet := mysqlGTIDfromString(t, "de278ad0-2106-11e4-9f8e-6edd0ca20947:1-2")
 	require.False(t, nonEmptyGTIDSet.IsEmpty())
 }
+
+func TestMysqlGTIDSetSerialization(t *testing.T) {
+	gset, err := ParseMysqlGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7,519ce70f-a893-11e9-a95a-b32dc65a7026:58")
+	require.NoError(t, err)
+
+	data, err := json.Marshal(struct {
+		GTID *MysqlGTIDSet `json:"gtid"`
+	}{gset.(*MysqlGTIDSet)})
+	require.NoError(t, err)
+	require.Equal(t, `{"gtid":"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7,519ce70f-a893-11e9-a95a-b32dc65a7026:58"}`, string(data))
+
+	var v struct {
+		GTID *MysqlGTIDSet `json:"gtid"`
+	}
+	require.NoError(t, json.Unmarshal(data, &v))
+	require.True(t, gset.Equal(v.GTID))
+
+	b1, err := gset.(*MysqlGTIDSet).MarshalBinary()
+	require.NoError(t, err)
+	// the encoding doesn't depend on the map iteration order
+	b2, err := gset.Clone().(*MysqlGTIDSet).MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, b1, b2)
+
+	decoded := new(MysqlGTIDSet)
+	require.NoError(t, decoded.UnmarshalBinary(b1))
+	require.True(t, gset.Equal(decoded))
+
+	require.Error(t, decoded.UnmarshalBinary(b1[:10]))
+	require.Error(t, decoded.UnmarshalJSON([]byte(`"invalid"`)))
+}
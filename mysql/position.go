@@ -34,9 +34,67 @@ func (p Position) String() string {
 	return fmt.Sprintf("(%s, %d)", p.Name, p.Pos)
 }
 
+// CompareStrict is like Compare, but returns an error instead of comparing the
+// positions of binlog files with different basenames, or without a numeric
+// extension. Positions without a file name are before the others.
+func (p Position) CompareStrict(o Position) (int, error) {
+	nameCmp, err := CompareBinlogFileNameStrict(p.Name, o.Name)
+	if err != nil {
+		return 0, err
+	}
+	if nameCmp != 0 {
+		return nameCmp, nil
+	}
+	return p.Compare(o), nil
+}
+
+// ParseBinlogFileName splits a binlog file name like mysql-bin.000001 into its
+// basename and the number of its extension. The number is not limited to six
+// digits, mysql-bin.1000000 follows mysql-bin.999999.
+func ParseBinlogFileName(name string) (base string, seq uint64, err error) {
+	// mysqld appends a numeric extension to the binary log base name to generate binary log file names
+	// ...
+	// If you supply an extension in the log name (for example, --log-bin=base_name.extension),
+	// the extension is silently removed and ignored.
+	// ref: https://dev.mysql.com/doc/refman/8.0/en/binary-log.html
+	i := strings.LastIndexByte(name, '.')
+	if i == -1 {
+		return "", 0, fmt.Errorf("binlog file %s doesn't contain numeric extension", name)
+	}
+	seq, err = strconv.ParseUint(name[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("binlog file %s doesn't contain numeric extension", name)
+	}
+	return name[:i], seq, nil
+}
+
+// CompareBinlogFileNameStrict compares the binlog filename of a and b like
+// CompareBinlogFileName, but returns an error if one of them has no numeric
+// extension or if their basenames are different, as their order is unknown.
+func CompareBinlogFileNameStrict(a, b string) (int, error) {
+	if a == "" || b == "" {
+		return CompareBinlogFileName(a, b), nil
+	}
+
+	aBase, aSeq, err := ParseBinlogFileName(a)
+	if err != nil {
+		return 0, err
+	}
+	bBase, bSeq, err := ParseBinlogFileName(b)
+	if err != nil {
+		return 0, err
+	}
+	if aBase != bBase {
+		return 0, fmt.Errorf("binlog files %s and %s have different basenames", a, b)
+	}
+	return compareSeq(aSeq, bSeq), nil
+}
+
 // CompareBinlogFileName compares the binlog filename of a and b.
 // if a>b will return 1.
 // if b>a will return -1.
+// Files with different basenames are ordered by basename, and it panics if a
+// file has a non numeric extension, see CompareBinlogFileNameStrict.
 func CompareBinlogFileName(a, b string) int {
 	// sometimes it's convenient to construct a `Position` literal with no `Name`
 	if a == "" && b == "" {
@@ -47,23 +105,16 @@ func CompareBinlogFileName(a, b string) int {
 		return 1
 	}
 
-	splitBinlogName := func(n string) (string, int) {
-		// mysqld appends a numeric extension to the binary log base name to generate binary log file names
-		// ...
-		// If you supply an extension in the log name (for example, --log-bin=base_name.extension),
-		// the extension is silently removed and ignored.
-		// ref: https://dev.mysql.com/doc/refman/8.0/en/binary-log.html
-		i := strings.LastIndexByte(n, '.')
-		if i == -1 {
+	splitBinlogName := func(n string) (string, uint64) {
+		if strings.IndexByte(n, '.') == -1 {
 			// try keeping backward compatibility
 			return n, 0
 		}
-
-		seq, err := strconv.Atoi(n[i+1:])
+		base, seq, err := ParseBinlogFileName(n)
 		if err != nil {
-			panic(fmt.Sprintf("binlog file %s doesn't contain numeric extension", err))
+			panic(err.Error())
 		}
-		return n[:i], seq
+		return base, seq
 	}
 
 	// get the basename(aBase) and the serial number(aSeq)
@@ -77,9 +128,13 @@ func CompareBinlogFileName(a, b string) int {
 		return -1
 	}
 
-	if aSeq > bSeq {
+	return compareSeq(aSeq, bSeq)
+}
+
+func compareSeq(a, b uint64) int {
+	if a > b {
 		return 1
-	} else if aSeq < bSeq {
+	} else if a < b {
 		return -1
 	} else {
 		return 0
@@ -46,3 +46,30 @@ func TestPosCompare(t *testing.T) {
 		require.Equal(t, 0, p.Compare(p))
 	}
 }
+
+func TestPosCompareStrict(t *testing.T) {
+	cmp, err := Position{"mysql-bin.999999", 100}.CompareStrict(Position{"mysql-bin.1000000", 4})
+	require.NoError(t, err)
+	require.Equal(t, -1, cmp)
+
+	cmp, err = Position{"mysql-bin.000002", 100}.CompareStrict(Position{"mysql-bin.000002", 4})
+	require.NoError(t, err)
+	require.Equal(t, 1, cmp)
+
+	cmp, err = Position{"", 100}.CompareStrict(Position{"mysql-bin.000001", 4})
+	require.NoError(t, err)
+	require.Equal(t, -1, cmp)
+
+	_, err = Position{"mysql-bin.000001", 4}.CompareStrict(Position{"relay-bin.000002", 4})
+	require.ErrorContains(t, err, "different basenames")
+
+	for _, name := range []string{"mysql-bin", "mysql-bin.log", "mysql-bin.-1"} {
+		_, err = Position{name, 4}.CompareStrict(Position{"mysql-bin.000001", 4})
+		require.ErrorContains(t, err, "numeric extension", name)
+	}
+
+	base, seq, err := ParseBinlogFileName("my.bin.log.12345678901")
+	require.NoError(t, err)
+	require.Equal(t, "my.bin.log", base)
+	require.EqualValues(t, 12345678901, seq)
+}
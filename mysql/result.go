@@ -1,5 +1,7 @@
 package mysql
 
+import "sync"
+
 // Result should be created by NewResultWithoutRows or NewResult. The zero value
 // of Result is invalid.
 type Result struct {
@@ -10,6 +12,10 @@ type Result struct {
 	AffectedRows uint64
 
 	*Resultset
+
+	// pooled is set for the results of NewPooledResult, returned to the pool by
+	// Close
+	pooled bool
 }
 
 func NewResult(resultset *Resultset) *Result {
@@ -28,7 +34,32 @@ type Executer interface {
 	Execute(query string, args ...interface{}) (*Result, error)
 }
 
+var resultPool = sync.Pool{
+	New: func() interface{} {
+		return &Result{pooled: true}
+	},
+}
+
+// NewPooledResult returns a Result with a Resultset of fieldCount fields, like
+// NewResultReserveResultset, from a pool: Close returns it to the pool with its
+// Resultset and its Field, reused by the next one, so the Result and its values
+// must not be used after Close, which must be called once.
+func NewPooledResult(fieldCount int) *Result {
+	r := resultPool.Get().(*Result)
+	if r.Resultset == nil {
+		r.Resultset = NewResultset(fieldCount)
+	} else {
+		r.Reset(fieldCount)
+	}
+	return r
+}
+
 func (r *Result) Close() {
+	if r.pooled {
+		*r = Result{Resultset: r.Resultset, pooled: true}
+		resultPool.Put(r)
+		return
+	}
 	if r.Resultset != nil {
 		r.returnToPool()
 		r.Resultset = nil
@@ -31,3 +31,17 @@ func TestHasResultset_nil(t *testing.T) {
 	b := r.HasResultset()
 	require.False(t, b)
 }
+
+func TestPooledResult(t *testing.T) {
+	r := NewPooledResult(2)
+	require.Len(t, r.Fields, 2)
+	r.AffectedRows = 3
+	r.Values = append(r.Values, []FieldValue{{}, {}})
+	r.Close()
+
+	r = NewPooledResult(1)
+	require.Zero(t, r.AffectedRows)
+	require.Len(t, r.Fields, 1)
+	require.Zero(t, r.RowNumber())
+	r.Close()
+}
@@ -56,6 +56,8 @@ func (r *Resultset) returnToPool() {
 func (r *Resultset) Reset(fieldsCount int) {
 	r.RawPkg = r.RawPkg[:0]
 
+	// drop the fields of the last use, their slots are reused below
+	clear(r.Fields[:cap(r.Fields)])
 	r.Fields = r.Fields[:0]
 	r.Values = r.Values[:0]
 	r.RowDatas = r.RowDatas[:0]
@@ -0,0 +1,694 @@
+package mysql
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/utils"
+)
+
+// notFixedDecimals is the decimals of a float column without a fixed number of
+// decimals, NOT_FIXED_DEC in MySQL.
+const notFixedDecimals = 31
+
+// BinaryCollationID is the id of the binary collation, of the binary strings
+// and of the non string columns.
+const BinaryCollationID = 63
+
+// ResultsetBuilder builds a Resultset of columns of the given types, unlike
+// BuildSimpleResultset which guesses the types from the Go values, for a
+// server to send the metadata a client expects, e.g. an unsigned INT or a
+// DECIMAL(10,2). The columns are added first, then the rows one by one:
+//
+//	b := NewResultsetBuilder(false).
+//		AddColumn("id", MYSQL_TYPE_LONG, ColumnUnsigned(), ColumnNotNull()).
+//		AddColumn("price", MYSQL_TYPE_NEWDECIMAL, ColumnDecimals(2)).
+//		AddColumn("data", MYSQL_TYPE_BLOB, ColumnBinary())
+//	err := b.AddRow(1, "9.90", []byte{0x1})
+//	...
+//	r, err := b.Build()
+type ResultsetBuilder struct {
+	binary bool
+	fields []*Field
+	rows   []RowData
+	// maxLength is the length of the longest value of each column
+	maxLength []int
+	err       error
+}
+
+// ColumnOption sets the metadata of a column of a ResultsetBuilder.
+type ColumnOption func(f *Field)
+
+// ColumnUnsigned sets UNSIGNED_FLAG, for an integer column.
+func ColumnUnsigned() ColumnOption {
+	return func(f *Field) { f.Flag |= UNSIGNED_FLAG }
+}
+
+// ColumnNotNull sets NOT_NULL_FLAG, the rows can't have NULL in the column.
+func ColumnNotNull() ColumnOption {
+	return func(f *Field) { f.Flag |= NOT_NULL_FLAG }
+}
+
+// ColumnFlags adds flags, like PRI_KEY_FLAG or AUTO_INCREMENT_FLAG.
+func ColumnFlags(flags uint16) ColumnOption {
+	return func(f *Field) { f.Flag |= flags }
+}
+
+// ColumnDecimals sets the decimals of a DECIMAL or FLOAT column, the digits
+// after the point, or the fractional seconds precision of a temporal column.
+func ColumnDecimals(decimals uint8) ColumnOption {
+	return func(f *Field) { f.Decimal = decimals }
+}
+
+// ColumnLength sets the display length of the column. By default it is the one
+// of MySQL for the numeric and temporal types, and the length of the longest
+// value in bytes for the others.
+func ColumnLength(length uint32) ColumnOption {
+	return func(f *Field) { f.ColumnLength = length }
+}
+
+// ColumnCollation sets the collation of a string column, DEFAULT_COLLATION_ID
+// by default.
+func ColumnCollation(id uint16) ColumnOption {
+	return func(f *Field) {
+		f.Charset = id
+		if id == BinaryCollationID {
+			f.Flag |= BINARY_FLAG
+		} else {
+			f.Flag &^= BINARY_FLAG
+		}
+	}
+}
+
+// ColumnBinary sets the binary collation, for a BINARY, VARBINARY or BLOB
+// column.
+func ColumnBinary() ColumnOption {
+	return ColumnCollation(BinaryCollationID)
+}
+
+// ColumnTable sets the schema and table of the column, the original name is
+// the name.
+func ColumnTable(schema, table string) ColumnOption {
+	return func(f *Field) {
+		f.Schema = []byte(schema)
+		f.Table = []byte(table)
+		f.OrgTable = f.Table
+		f.OrgName = f.Name
+	}
+}
+
+// NewResultsetBuilder returns a builder of a resultset in the binary protocol,
+// for COM_STMT_EXECUTE, if binary is set, else in the text protocol.
+func NewResultsetBuilder(binary bool) *ResultsetBuilder {
+	return &ResultsetBuilder{binary: binary}
+}
+
+// AddColumn adds a column of type typ, one of the MYSQL_TYPE_ constants. The
+// numeric and temporal columns have the binary collation, the string columns
+// DEFAULT_COLLATION_ID unless set by ColumnCollation or ColumnBinary.
+func (b *ResultsetBuilder) AddColumn(name string, typ uint8, opts ...ColumnOption) *ResultsetBuilder {
+	if len(b.rows) > 0 {
+		b.setErr(errors.Errorf("column %s added after the rows", name))
+		return b
+	}
+
+	f := &Field{Name: []byte(name), Type: typ}
+	switch {
+	case isStringType(typ):
+		f.Charset = uint16(DEFAULT_COLLATION_ID)
+		switch typ {
+		case MYSQL_TYPE_TINY_BLOB, MYSQL_TYPE_BLOB, MYSQL_TYPE_MEDIUM_BLOB, MYSQL_TYPE_LONG_BLOB:
+			f.Flag |= BLOB_FLAG
+		case MYSQL_TYPE_ENUM:
+			f.Flag |= ENUM_FLAG
+		case MYSQL_TYPE_SET:
+			f.Flag |= SET_FLAG
+		}
+	default:
+		f.Charset = BinaryCollationID
+		f.Flag |= BINARY_FLAG
+		switch typ {
+		case MYSQL_TYPE_FLOAT, MYSQL_TYPE_DOUBLE:
+			f.Decimal = notFixedDecimals
+		case MYSQL_TYPE_JSON:
+			f.Flag |= BLOB_FLAG
+		case MYSQL_TYPE_TIMESTAMP:
+			f.Flag |= TIMESTAMP_FLAG
+		}
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.ColumnLength == 0 {
+		f.ColumnLength = defaultColumnLength(f)
+	}
+
+	b.fields = append(b.fields, f)
+	b.maxLength = append(b.maxLength, 0)
+	return b
+}
+
+// isStringType returns whether the columns of typ have a collation.
+func isStringType(typ uint8) bool {
+	switch typ {
+	case MYSQL_TYPE_VARCHAR, MYSQL_TYPE_VAR_STRING, MYSQL_TYPE_STRING,
+		MYSQL_TYPE_TINY_BLOB, MYSQL_TYPE_BLOB, MYSQL_TYPE_MEDIUM_BLOB, MYSQL_TYPE_LONG_BLOB,
+		MYSQL_TYPE_ENUM, MYSQL_TYPE_SET:
+		return true
+	}
+	return false
+}
+
+// defaultColumnLength returns the display length of MySQL for a column of f,
+// 0 if it depends on the values.
+func defaultColumnLength(f *Field) uint32 {
+	unsigned := f.Flag&UNSIGNED_FLAG != 0
+	fsp := uint32(0)
+	if f.Decimal > 0 && f.Decimal <= 6 {
+		fsp = uint32(f.Decimal) + 1
+	}
+	switch f.Type {
+	case MYSQL_TYPE_TINY:
+		if unsigned {
+			return 3
+		}
+		return 4
+	case MYSQL_TYPE_SHORT:
+		if unsigned {
+			return 5
+		}
+		return 6
+	case MYSQL_TYPE_INT24:
+		if unsigned {
+			return 8
+		}
+		return 9
+	case MYSQL_TYPE_LONG:
+		if unsigned {
+			return 10
+		}
+		return 11
+	case MYSQL_TYPE_LONGLONG:
+		return 20
+	case MYSQL_TYPE_YEAR:
+		return 4
+	case MYSQL_TYPE_FLOAT:
+		return 12
+	case MYSQL_TYPE_DOUBLE:
+		return 22
+	case MYSQL_TYPE_DATE:
+		return 10
+	case MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIMESTAMP:
+		return 19 + fsp
+	case MYSQL_TYPE_TIME:
+		return 10 + fsp
+	}
+	return 0
+}
+
+func (b *ResultsetBuilder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// AddRow adds a row with a value for each column, nil for NULL. The values are
+// converted to the type of their column:
+//   - the integers and floats accept the Go integers and floats, and bool for
+//     the integers
+//   - DECIMAL accepts a string, []byte, or a Go integer or float formatted
+//     with the decimals of the column
+//   - DATE, DATETIME and TIMESTAMP accept a time.Time, or a string like
+//     2006-01-02 15:04:05.999999
+//   - TIME accepts a time.Duration, or a string like -838:59:59.000000
+//   - the others accept a string or []byte, or any value of
+//     BuildSimpleResultset.
+func (b *ResultsetBuilder) AddRow(values ...interface{}) error {
+	if b.err != nil {
+		return b.err
+	}
+	if len(values) != len(b.fields) {
+		return errors.Errorf("row %d has %d values, need %d", len(b.rows), len(values), len(b.fields))
+	}
+
+	var row []byte
+	var nullBitmap []byte
+	if b.binary {
+		nullBitmap = make([]byte, (len(b.fields)+7+2)>>3)
+		row = append(row, OK_HEADER)
+		row = append(row, nullBitmap...)
+	}
+
+	for i, value := range values {
+		f := b.fields[i]
+		if value == nil {
+			if f.Flag&NOT_NULL_FLAG != 0 {
+				return errors.Errorf("NULL in the NOT NULL column %s", f.Name)
+			}
+			if b.binary {
+				nullBitmap[(i+2)/8] |= 1 << (uint(i+2) % 8)
+			} else {
+				// NULL value is encoded as 0xfb
+				row = append(row, 0xfb)
+			}
+			continue
+		}
+
+		start := len(row)
+		var err error
+		if b.binary {
+			row, err = appendBinaryValue(row, f, value)
+		} else {
+			row, err = appendTextValue(row, f, value)
+		}
+		if err != nil {
+			return errors.Annotatef(err, "column %s", f.Name)
+		}
+		if n := len(row) - start; n > b.maxLength[i] {
+			b.maxLength[i] = n
+		}
+	}
+	if b.binary {
+		copy(row[1:], nullBitmap)
+	}
+
+	b.rows = append(b.rows, row)
+	return nil
+}
+
+// Build returns the resultset of the columns and rows added.
+func (b *ResultsetBuilder) Build() (*Resultset, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	r := NewResultset(len(b.fields))
+	for i, f := range b.fields {
+		if f.ColumnLength == 0 {
+			f.ColumnLength = uint32(b.maxLength[i])
+		}
+		r.Fields[i] = f
+		r.FieldNames[string(f.Name)] = i
+	}
+	r.RowDatas = append(r.RowDatas, b.rows...)
+	return r, nil
+}
+
+// intBits returns the size in bits of the integer columns of typ, 0 if typ is
+// not an integer type.
+func intBits(typ uint8) int {
+	switch typ {
+	case MYSQL_TYPE_TINY:
+		return 8
+	case MYSQL_TYPE_SHORT, MYSQL_TYPE_YEAR:
+		return 16
+	case MYSQL_TYPE_INT24:
+		return 24
+	case MYSQL_TYPE_LONG:
+		return 32
+	case MYSQL_TYPE_LONGLONG:
+		return 64
+	}
+	return 0
+}
+
+// toInt returns value as an integer of bits, unsigned or not, in an uint64.
+func toInt(value interface{}, bits int, unsigned bool) (uint64, error) {
+	var i int64
+	var u uint64
+	isUint := false
+	switch v := value.(type) {
+	case int8:
+		i = int64(v)
+	case int16:
+		i = int64(v)
+	case int32:
+		i = int64(v)
+	case int64:
+		i = v
+	case int:
+		i = int64(v)
+	case uint8:
+		u, isUint = uint64(v), true
+	case uint16:
+		u, isUint = uint64(v), true
+	case uint32:
+		u, isUint = uint64(v), true
+	case uint64:
+		u, isUint = v, true
+	case uint:
+		u, isUint = uint64(v), true
+	case bool:
+		if v {
+			i = 1
+		}
+	default:
+		return 0, errors.Errorf("invalid type %T for an integer", value)
+	}
+
+	if unsigned {
+		if !isUint {
+			if i < 0 {
+				return 0, errors.Errorf("negative value %d for an unsigned integer", i)
+			}
+			u = uint64(i)
+		}
+		if bits < 64 && u >= 1<<bits {
+			return 0, errors.Errorf("value %d out of range", u)
+		}
+		return u, nil
+	}
+	if isUint {
+		if u > math.MaxInt64 {
+			return 0, errors.Errorf("value %d out of range", u)
+		}
+		i = int64(u)
+	}
+	if bits < 64 && (i < -1<<(bits-1) || i >= 1<<(bits-1)) {
+		return 0, errors.Errorf("value %d out of range", i)
+	}
+	return utils.Int64ToUint64(i), nil
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case int8, int16, int32, int64, int, uint8, uint16, uint32, uint64, uint:
+		s, err := FormatTextValue(v)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseFloat(string(s), 64)
+	default:
+		return 0, errors.Errorf("invalid type %T for a float", value)
+	}
+}
+
+// formatFloat formats v with the decimals of f, the shortest representation if
+// they are not fixed.
+func formatFloat(dst []byte, f *Field, v float64) []byte {
+	bitSize := 64
+	if f.Type == MYSQL_TYPE_FLOAT {
+		bitSize = 32
+	}
+	if f.Decimal >= notFixedDecimals {
+		return strconv.AppendFloat(dst, v, 'g', -1, bitSize)
+	}
+	return strconv.AppendFloat(dst, v, 'f', int(f.Decimal), bitSize)
+}
+
+// toDecimal returns the text of a DECIMAL value.
+func toDecimal(f *Field, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return utils.StringToByteSlice(v), nil
+	case []byte:
+		return v, nil
+	case float32, float64:
+		fv, _ := toFloat(v)
+		return strconv.AppendFloat(nil, fv, 'f', int(f.Decimal), 64), nil
+	case int8, int16, int32, int64, int, uint8, uint16, uint32, uint64, uint:
+		b, err := FormatTextValue(v)
+		if err != nil {
+			return nil, err
+		}
+		if f.Decimal > 0 {
+			b = append(b, '.')
+			b = append(b, strings.Repeat("0", int(f.Decimal))...)
+		}
+		return b, nil
+	default:
+		return nil, errors.Errorf("invalid type %T for a decimal", value)
+	}
+}
+
+// toTime returns a time.Time of a value of a DATE, DATETIME or TIMESTAMP column.
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return parseDateTime(v)
+	case []byte:
+		return parseDateTime(string(v))
+	default:
+		return time.Time{}, errors.Errorf("invalid type %T for a date", value)
+	}
+}
+
+func parseDateTime(s string) (time.Time, error) {
+	if strings.HasPrefix(s, "0000-00-00") {
+		return time.Time{}, nil
+	}
+	layout := "2006-01-02"
+	if len(s) > len(layout) {
+		layout = "2006-01-02 15:04:05.999999"
+	}
+	t, err := time.Parse(layout, s)
+	return t, errors.Trace(err)
+}
+
+// toDuration returns a time.Duration of a value of a TIME column.
+func toDuration(value interface{}) (time.Duration, error) {
+	switch v := value.(type) {
+	case time.Duration:
+		return v, nil
+	case string:
+		return parseTime(v)
+	case []byte:
+		return parseTime(string(v))
+	default:
+		return 0, errors.Errorf("invalid type %T for a time", value)
+	}
+}
+
+// parseTime parses a TIME like -838:59:59.000000.
+func parseTime(s string) (time.Duration, error) {
+	neg := strings.HasPrefix(s, "-")
+	parts := strings.Split(strings.TrimPrefix(s, "-"), ":")
+	if len(parts) != 3 {
+		return 0, errors.Errorf("invalid time %q", s)
+	}
+	h, err1 := strconv.ParseUint(parts[0], 10, 32)
+	m, err2 := strconv.ParseUint(parts[1], 10, 8)
+	sec, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil || m > 59 || sec >= 60 {
+		return 0, errors.Errorf("invalid time %q", s)
+	}
+	d := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(math.Round(sec*1e6))*time.Microsecond
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// formatTime appends the text of a TIME, with fsp fractional digits.
+func formatTime(dst []byte, d time.Duration, fsp uint8) []byte {
+	if d < 0 {
+		dst = append(dst, '-')
+		d = -d
+	}
+	us := d.Microseconds()
+	sec := us / 1e6
+	dst = appendPadded(dst, sec/3600, 2)
+	dst = append(dst, ':')
+	dst = appendPadded(dst, sec/60%60, 2)
+	dst = append(dst, ':')
+	dst = appendPadded(dst, sec%60, 2)
+	if fsp > 0 && fsp <= 6 {
+		dst = append(dst, '.')
+		frac := us % 1e6
+		for i := fsp; i < 6; i++ {
+			frac /= 10
+		}
+		dst = appendPadded(dst, frac, int(fsp))
+	}
+	return dst
+}
+
+func appendPadded(dst []byte, v int64, width int) []byte {
+	s := strconv.FormatInt(v, 10)
+	for i := len(s); i < width; i++ {
+		dst = append(dst, '0')
+	}
+	return append(dst, s...)
+}
+
+// dateTimeLayout returns the layout of the text of a temporal column of f.
+func dateTimeLayout(f *Field) string {
+	if f.Type == MYSQL_TYPE_DATE {
+		return time.DateOnly
+	}
+	if f.Decimal > 0 && f.Decimal <= 6 {
+		return time.DateTime + "." + strings.Repeat("0", int(f.Decimal))
+	}
+	return time.DateTime
+}
+
+func isDateType(typ uint8) bool {
+	return typ == MYSQL_TYPE_DATE || typ == MYSQL_TYPE_DATETIME || typ == MYSQL_TYPE_TIMESTAMP
+}
+
+// appendTextValue appends the length encoded text of value in the column f.
+func appendTextValue(dst []byte, f *Field, value interface{}) ([]byte, error) {
+	var b []byte
+	switch {
+	case f.Type == MYSQL_TYPE_NULL:
+		return nil, errors.New("non NULL value in a NULL column")
+	case intBits(f.Type) > 0:
+		v, err := toInt(value, intBits(f.Type), f.Flag&UNSIGNED_FLAG != 0)
+		if err != nil {
+			return nil, err
+		}
+		if f.Flag&UNSIGNED_FLAG != 0 {
+			b = strconv.AppendUint(nil, v, 10)
+		} else {
+			b = strconv.AppendInt(nil, utils.Uint64ToInt64(v), 10)
+		}
+	case f.Type == MYSQL_TYPE_FLOAT || f.Type == MYSQL_TYPE_DOUBLE:
+		v, err := toFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		b = formatFloat(nil, f, v)
+	case f.Type == MYSQL_TYPE_DECIMAL || f.Type == MYSQL_TYPE_NEWDECIMAL:
+		var err error
+		if b, err = toDecimal(f, value); err != nil {
+			return nil, err
+		}
+	case isDateType(f.Type):
+		t, err := toTime(value)
+		if err != nil {
+			return nil, err
+		}
+		if t.IsZero() {
+			// the zero date 0000-00-00, time.Time has no year 0
+			layout := dateTimeLayout(f)
+			b = append([]byte("0000-00-00"), layout[len(time.DateOnly):]...)
+			for i := len(time.DateOnly); i < len(b); i++ {
+				if b[i] >= '0' && b[i] <= '9' {
+					b[i] = '0'
+				}
+			}
+		} else {
+			b = t.AppendFormat(nil, dateTimeLayout(f))
+		}
+	case f.Type == MYSQL_TYPE_TIME:
+		d, err := toDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		b = formatTime(nil, d, f.Decimal)
+	default:
+		var err error
+		if b, err = FormatTextValue(value); err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, PutLengthEncodedString(b)...), nil
+}
+
+// appendBinaryValue appends value in the binary protocol in the column f.
+func appendBinaryValue(dst []byte, f *Field, value interface{}) ([]byte, error) {
+	switch {
+	case f.Type == MYSQL_TYPE_NULL:
+		return nil, errors.New("non NULL value in a NULL column")
+	case intBits(f.Type) > 0:
+		bits := intBits(f.Type)
+		v, err := toInt(value, bits, f.Flag&UNSIGNED_FLAG != 0)
+		if err != nil {
+			return nil, err
+		}
+		switch bits {
+		case 8:
+			return append(dst, byte(v)), nil
+		case 16:
+			return binary.LittleEndian.AppendUint16(dst, uint16(v)), nil
+		case 24, 32:
+			return binary.LittleEndian.AppendUint32(dst, uint32(v)), nil
+		default:
+			return binary.LittleEndian.AppendUint64(dst, v), nil
+		}
+	case f.Type == MYSQL_TYPE_FLOAT:
+		v, err := toFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		return binary.LittleEndian.AppendUint32(dst, math.Float32bits(float32(v))), nil
+	case f.Type == MYSQL_TYPE_DOUBLE:
+		v, err := toFloat(value)
+		if err != nil {
+			return nil, err
+		}
+		return binary.LittleEndian.AppendUint64(dst, math.Float64bits(v)), nil
+	case f.Type == MYSQL_TYPE_DECIMAL || f.Type == MYSQL_TYPE_NEWDECIMAL:
+		b, err := toDecimal(f, value)
+		if err != nil {
+			return nil, err
+		}
+		return append(dst, PutLengthEncodedString(b)...), nil
+	case isDateType(f.Type):
+		t, err := toTime(value)
+		if err != nil {
+			return nil, err
+		}
+		if t.IsZero() {
+			return append(dst, 0), nil
+		}
+		if f.Type == MYSQL_TYPE_DATE {
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		b, err := toBinaryDateTime(t)
+		if err != nil {
+			return nil, err
+		}
+		return append(dst, b...), nil
+	case f.Type == MYSQL_TYPE_TIME:
+		d, err := toDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		return appendBinaryTime(dst, d), nil
+	default:
+		b, err := FormatTextValue(value)
+		if err != nil {
+			return nil, err
+		}
+		return append(dst, PutLengthEncodedString(b)...), nil
+	}
+}
+
+// appendBinaryTime appends a TIME in the binary protocol, its length, the sign,
+// the days, the hours, minutes and seconds, and the microseconds if any.
+func appendBinaryTime(dst []byte, d time.Duration) []byte {
+	if d == 0 {
+		return append(dst, 0)
+	}
+	var neg byte
+	if d < 0 {
+		neg = 1
+		d = -d
+	}
+	us := d.Microseconds()
+	sec := us / 1e6
+	frac := us % 1e6
+	if frac > 0 {
+		dst = append(dst, 12)
+	} else {
+		dst = append(dst, 8)
+	}
+	dst = append(dst, neg)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(sec/86400))
+	dst = append(dst, byte(sec/3600%24), byte(sec/60%60), byte(sec%60))
+	if frac > 0 {
+		dst = binary.LittleEndian.AppendUint32(dst, uint32(frac))
+	}
+	return dst
+}
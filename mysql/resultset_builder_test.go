@@ -0,0 +1,73 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultsetBuilder(t *testing.T) {
+	ts := time.Date(2024, 3, 4, 5, 6, 7, 890000000, time.UTC)
+	for _, binary := range []bool{false, true} {
+		b := NewResultsetBuilder(binary).
+			AddColumn("id", MYSQL_TYPE_LONG, ColumnUnsigned(), ColumnNotNull()).
+			AddColumn("n", MYSQL_TYPE_TINY).
+			AddColumn("price", MYSQL_TYPE_NEWDECIMAL, ColumnDecimals(2)).
+			AddColumn("ratio", MYSQL_TYPE_DOUBLE).
+			AddColumn("created", MYSQL_TYPE_DATETIME, ColumnDecimals(3)).
+			AddColumn("day", MYSQL_TYPE_DATE).
+			AddColumn("elapsed", MYSQL_TYPE_TIME).
+			AddColumn("name", MYSQL_TYPE_VAR_STRING).
+			AddColumn("data", MYSQL_TYPE_BLOB, ColumnBinary())
+		require.NoError(t, b.AddRow(uint32(4000000000), -5, "9.90", 0.5, ts, ts, -(26*time.Hour+3*time.Second), "abc", []byte{0x1, 0x2}))
+		require.NoError(t, b.AddRow(1, nil, 3, 2, nil, "0000-00-00", "01:02:03", nil, nil))
+
+		require.Error(t, b.AddRow(nil, nil, nil, nil, nil, nil, nil, nil, nil))
+		require.Error(t, b.AddRow(-1, nil, nil, nil, nil, nil, nil, nil, nil))
+		require.Error(t, b.AddRow(1, 128, nil, nil, nil, nil, nil, nil, nil))
+		require.Error(t, b.AddRow(1))
+
+		r, err := b.Build()
+		require.NoError(t, err)
+		require.Len(t, r.RowDatas, 2)
+
+		id := r.Fields[0]
+		require.Equal(t, uint16(BINARY_FLAG|NOT_NULL_FLAG|UNSIGNED_FLAG), id.Flag)
+		require.Equal(t, uint16(BinaryCollationID), id.Charset)
+		require.Equal(t, uint32(10), id.ColumnLength)
+		require.Equal(t, uint8(notFixedDecimals), r.Fields[3].Decimal)
+		require.Equal(t, uint32(23), r.Fields[4].ColumnLength)
+		require.Equal(t, uint16(DEFAULT_COLLATION_ID), r.Fields[7].Charset)
+		require.Equal(t, uint32(4), r.Fields[7].ColumnLength)
+		require.Equal(t, uint16(BLOB_FLAG|BINARY_FLAG), r.Fields[8].Flag)
+
+		var rows [][]interface{}
+		for _, data := range r.RowDatas {
+			values, err := data.Parse(r.Fields, binary, nil)
+			require.NoError(t, err)
+			row := make([]interface{}, len(values))
+			for i := range values {
+				row[i] = values[i].Value()
+			}
+			rows = append(rows, row)
+		}
+
+		if binary {
+			require.Equal(t, []interface{}{uint64(4000000000), int64(-5), []byte("9.90"), 0.5,
+				[]byte("2024-03-04 05:06:07.890000"), []byte("2024-03-04"), []byte("-26:00:03"), []byte("abc"), []byte{0x1, 0x2}}, rows[0])
+			require.Equal(t, []interface{}{uint64(1), nil, []byte("3.00"), float64(2),
+				nil, []byte("0000-00-00"), []byte("01:02:03"), nil, nil}, rows[1])
+		} else {
+			require.Equal(t, []interface{}{uint64(4000000000), int64(-5), []byte("9.90"), 0.5,
+				[]byte("2024-03-04 05:06:07.890"), []byte("2024-03-04"), []byte("-26:00:03"), []byte("abc"), []byte{0x1, 0x2}}, rows[0])
+			require.Equal(t, []interface{}{uint64(1), nil, []byte("3.00"), float64(2),
+				nil, []byte("0000-00-00"), []byte("01:02:03"), nil, nil}, rows[1])
+		}
+	}
+
+	b := NewResultsetBuilder(false).AddColumn("a", MYSQL_TYPE_LONG)
+	require.NoError(t, b.AddRow(1))
+	_, err := b.AddColumn("b", MYSQL_TYPE_LONG).Build()
+	require.Error(t, err)
+}
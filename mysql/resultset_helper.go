@@ -44,6 +44,8 @@ func FormatTextValue(value interface{}) ([]byte, error) {
 		return utils.StringToByteSlice(v), nil
 	case time.Time:
 		return utils.StringToByteSlice(v.Format(time.DateTime)), nil
+	case []float32:
+		return EncodeVector(v), nil
 	case nil:
 		return nil, nil
 	default:
@@ -121,6 +123,8 @@ func formatBinaryValue(value interface{}) ([]byte, error) {
 		return utils.StringToByteSlice(v), nil
 	case time.Time:
 		return toBinaryDateTime(v)
+	case []float32:
+		return EncodeVector(v), nil
 	default:
 		return nil, errors.Errorf("invalid type %T", value)
 	}
@@ -138,6 +142,8 @@ func fieldType(value interface{}) (typ uint8, err error) {
 		typ = MYSQL_TYPE_VAR_STRING
 	case time.Time:
 		typ = MYSQL_TYPE_DATETIME
+	case []float32:
+		typ = MYSQL_TYPE_VECTOR
 	case nil:
 		typ = MYSQL_TYPE_NULL
 	default:
@@ -159,6 +165,9 @@ func formatField(field *Field, value interface{}) error {
 		field.Flag = BINARY_FLAG | NOT_NULL_FLAG
 	case string, []byte, time.Time:
 		field.Charset = 33
+	case []float32:
+		field.Charset = 63
+		field.Flag = BINARY_FLAG
 	case nil:
 		field.Charset = 33
 	default:
@@ -272,7 +281,7 @@ func BuildSimpleBinaryResultset(names []string, values [][]interface{}) (*Result
 				return nil, errors.Trace(err)
 			}
 
-			if r.Fields[j].Type == MYSQL_TYPE_VAR_STRING {
+			if r.Fields[j].Type == MYSQL_TYPE_VAR_STRING || r.Fields[j].Type == MYSQL_TYPE_VECTOR {
 				row = append(row, PutLengthEncodedString(b)...)
 			} else {
 				row = append(row, b...)
@@ -22,3 +22,46 @@ func TestGetIntNeg(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, int64(-193), v)
 }
+
+func TestResetFields(t *testing.T) {
+	r := &Resultset{}
+	r.Reset(2)
+	r.Fields[0], r.Fields[1] = &Field{Name: []byte("a")}, &Field{Name: []byte("b")}
+	r.Reset(0)
+	r.Reset(1)
+	require.Equal(t, []*Field{nil}, r.Fields)
+	require.Nil(t, r.Fields[:2][1])
+}
+
+func TestGetVector(t *testing.T) {
+	vec := []float32{1, -2.5, 3.25}
+	data := EncodeVector(vec)
+	require.Len(t, data, 12)
+	require.Equal(t, "[1.00000e+00,-2.50000e+00,3.25000e+00]", FormatVector(vec))
+
+	r := NewResultset(2)
+	r.Fields[0] = &Field{Name: []byte("v")}
+	r.Fields[1] = &Field{Name: []byte("n")}
+	r.FieldNames = map[string]int{"v": 0, "n": 1}
+	r.Values = [][]FieldValue{{
+		NewFieldValue(FieldValueTypeString, 0, data),
+		NewFieldValue(FieldValueTypeNull, 0, nil),
+	}}
+
+	v, err := r.GetVectorByName(0, "v")
+	require.NoError(t, err)
+	require.Equal(t, vec, v)
+	v, err = r.GetVector(0, 1)
+	require.NoError(t, err)
+	require.Nil(t, v)
+
+	_, err = DecodeVector(data[:5])
+	require.Error(t, err)
+
+	for _, binary := range []bool{false, true} {
+		rs, err := BuildSimpleResultset([]string{"v"}, [][]interface{}{{vec}}, binary)
+		require.NoError(t, err)
+		require.Equal(t, uint8(MYSQL_TYPE_VECTOR), rs.Fields[0].Type)
+		require.Equal(t, uint16(63), rs.Fields[0].Charset)
+	}
+}
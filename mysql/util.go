@@ -429,6 +429,12 @@ func Escape(sql string) string {
 	return string(dest)
 }
 
+// QuoteIdentifier quotes an identifier, like a table or a column name, with
+// backticks, doubling the backticks in it.
+func QuoteIdentifier(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "``") + "`"
+}
+
 func GetNetProto(addr string) string {
 	if strings.Contains(addr, "/") {
 		return "unix"
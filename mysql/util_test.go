@@ -129,3 +129,8 @@ func TestToBinaryDateTime(t *testing.T) {
 		})
 	}
 }
+
+func TestQuoteIdentifier(t *testing.T) {
+	require.Equal(t, "`t`", QuoteIdentifier("t"))
+	require.Equal(t, "`a``b`", QuoteIdentifier("a`b"))
+}
@@ -0,0 +1,72 @@
+package mysql
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+
+	"github.com/pingcap/errors"
+)
+
+// DecodeVector decodes the binary value of a VECTOR column, an array of
+// little-endian IEEE 754 single precision floats.
+func DecodeVector(data []byte) ([]float32, error) {
+	if len(data)%4 != 0 {
+		return nil, errors.Errorf("invalid vector length %d, must be a multiple of 4", len(data))
+	}
+
+	v := make([]float32, len(data)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return v, nil
+}
+
+// EncodeVector encodes v to the binary value of a VECTOR column.
+func EncodeVector(v []float32) []byte {
+	data := make([]byte, 0, len(v)*4)
+	for _, f := range v {
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(f))
+	}
+	return data
+}
+
+// FormatVector formats v like VECTOR_TO_STRING(), e.g. "[1.00000e+00,2.50000e+00]".
+func FormatVector(v []float32) string {
+	b := make([]byte, 0, 2+len(v)*13)
+	b = append(b, '[')
+	for i, f := range v {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendFloat(b, float64(f), 'e', 5, 32)
+	}
+	return string(append(b, ']'))
+}
+
+// GetVector returns the value of a VECTOR column, nil if NULL.
+func (r *Resultset) GetVector(row, column int) ([]float32, error) {
+	d, err := r.GetValue(row, column)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := d.(type) {
+	case []byte:
+		return DecodeVector(v)
+	case string:
+		return DecodeVector([]byte(v))
+	case nil:
+		return nil, nil
+	default:
+		return nil, errors.Errorf("data type is %T", v)
+	}
+}
+
+func (r *Resultset) GetVectorByName(row int, name string) ([]float32, error) {
+	if column, err := r.NameIndex(name); err != nil {
+		return nil, err
+	} else {
+		return r.GetVector(row, column)
+	}
+}
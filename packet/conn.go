@@ -52,6 +52,10 @@ type Conn struct {
 	compressedReader io.Reader
 
 	compressedReaderActive bool
+
+	// the packets written while buffering, see SetWriteBuffering
+	writeBuffering bool
+	writeBuf       []byte
 }
 
 func NewConn(conn net.Conn) *Conn {
@@ -106,21 +110,8 @@ func (c *Conn) ReadPacketReuseMem(dst []byte) ([]byte, error) {
 		utils.BytesBufferPut(buf)
 	}()
 
-	if c.Compression != mysql.MYSQL_COMPRESS_NONE {
-		// it's possible that we're using compression but the server response with a compressed
-		// packet with uncompressed length of 0. In this case we leave compressedReader nil. The
-		// compressedReaderActive flag is important to track the state of the reader, allowing
-		// for the compressedReader to be reset after a packet write. Without this flag, when a
-		// compressed packet with uncompressed length of 0 is read, the compressedReader would
-		// be nil, and we'd incorrectly attempt to read the next packet as compressed.
-		if !c.compressedReaderActive {
-			var err error
-			c.compressedReader, err = c.newCompressedPacketReader()
-			if err != nil {
-				return nil, err
-			}
-			c.compressedReaderActive = true
-		}
+	if err := c.activateCompressedReader(); err != nil {
+		return nil, err
 	}
 
 	if err := c.ReadPacketTo(buf); err != nil {
@@ -149,6 +140,88 @@ func (c *Conn) ReadPacketReuseMem(dst []byte) ([]byte, error) {
 	return result, nil
 }
 
+// ReadPacketTruncated reads a packet like ReadPacket, but keeps only its first
+// limit bytes, the rest is read and discarded. size is the full size of the packet.
+func (c *Conn) ReadPacketTruncated(limit int) (data []byte, size int, err error) {
+	if err = c.activateCompressedReader(); err != nil {
+		return nil, 0, err
+	}
+
+	w := &truncatingWriter{limit: limit}
+	if err = c.ReadPacketTo(w); err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+	return w.buf, w.size, nil
+}
+
+type truncatingWriter struct {
+	buf   []byte
+	limit int
+	size  int
+}
+
+func (w *truncatingWriter) Write(p []byte) (int, error) {
+	if room := w.limit - len(w.buf); room > 0 {
+		w.buf = append(w.buf, p[:min(room, len(p))]...)
+	}
+	w.size += len(p)
+	return len(p), nil
+}
+
+// ReadPacketLimited reads a packet like ReadPacket, the packets of more than
+// 16MB reassembled, but stops reading it when it exceeds limit bytes: ok is
+// false then, and the rest of the packet is not read so the connection can't
+// be read anymore.
+func (c *Conn) ReadPacketLimited(limit int) (data []byte, ok bool, err error) {
+	if err = c.activateCompressedReader(); err != nil {
+		return nil, false, err
+	}
+
+	w := &limitedWriter{limit: limit}
+	if err = c.ReadPacketTo(w); err != nil {
+		if w.exceeded {
+			return nil, false, nil
+		}
+		return nil, false, errors.Trace(err)
+	}
+	return w.buf, true, nil
+}
+
+type limitedWriter struct {
+	buf      []byte
+	limit    int
+	exceeded bool
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if len(w.buf)+len(p) > w.limit {
+		w.exceeded = true
+		return 0, errors.Errorf("packet exceeds %d bytes", w.limit)
+	}
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+func (c *Conn) activateCompressedReader() error {
+	if c.Compression != mysql.MYSQL_COMPRESS_NONE {
+		// it's possible that we're using compression but the server response with a compressed
+		// packet with uncompressed length of 0. In this case we leave compressedReader nil. The
+		// compressedReaderActive flag is important to track the state of the reader, allowing
+		// for the compressedReader to be reset after a packet write. Without this flag, when a
+		// compressed packet with uncompressed length of 0 is read, the compressedReader would
+		// be nil, and we'd incorrectly attempt to read the next packet as compressed.
+		if !c.compressedReaderActive {
+			var err error
+			c.compressedReader, err = c.newCompressedPacketReader()
+			if err != nil {
+				return err
+			}
+			c.compressedReaderActive = true
+		}
+	}
+	return nil
+}
+
 // newCompressedPacketReader creates a new compressed packet reader.
 func (c *Conn) newCompressedPacketReader() (io.Reader, error) {
 	if c.readTimeout != 0 {
@@ -346,7 +419,46 @@ func (c *Conn) WritePacket(data []byte) error {
 	return nil
 }
 
+// SetWriteBuffering buffers the packets written until Flush instead of writing
+// them at once, to send several commands together without waiting for their
+// responses, which are read in order after. Flush can be called from another
+// goroutine than the one reading the responses. Disabling it flushes the
+// buffered packets.
+func (c *Conn) SetWriteBuffering(enabled bool) error {
+	c.writeBuffering = enabled
+	if !enabled {
+		return c.Flush()
+	}
+	return nil
+}
+
+// Flush writes the packets buffered, see SetWriteBuffering.
+func (c *Conn) Flush() error {
+	if len(c.writeBuf) == 0 {
+		return nil
+	}
+	data := c.writeBuf
+	c.writeBuf = nil
+
+	if c.writeTimeout != 0 {
+		if err := c.SetWriteDeadline(utils.Now().Add(c.writeTimeout)); err != nil {
+			return errors.Wrapf(mysql.ErrBadConn, "Flush failed. err %v", err)
+		}
+	}
+	if n, err := c.Write(data); err != nil {
+		return errors.Wrapf(mysql.ErrBadConn, "Flush failed. err %v", err)
+	} else if n != len(data) {
+		return errors.Wrapf(mysql.ErrBadConn, "Flush failed. only %v bytes written, while %v expected", n, len(data))
+	}
+	return nil
+}
+
 func (c *Conn) writeWithTimeout(b []byte) (n int, err error) {
+	if c.writeBuffering {
+		c.writeBuf = append(c.writeBuf, b...)
+		return len(b), nil
+	}
+
 	if c.writeTimeout != 0 {
 		if err := c.SetWriteDeadline(utils.Now().Add(c.writeTimeout)); err != nil {
 			return n, err
@@ -0,0 +1,191 @@
+package replication
+
+import (
+	"cmp"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// binlogReadSize is the size of the buffered reads of the binlog files, e.g.
+// of the range requests of an object storage.
+const binlogReadSize = 1 << 20
+
+// BinlogStorage is where the binlog files parsed by ParseStorage are, like a
+// directory, see DirStorage, or a bucket of an object storage like S3 or GCS
+// where they are archived, e.g. for a point-in-time recovery, to parse them
+// without downloading them first. The files are read by ranges of 1MiB.
+type BinlogStorage interface {
+	// List returns the names of the files, the ones not named like binlog
+	// files, like the index, are skipped by ParseStorage.
+	List() ([]string, error)
+	// Open returns the reader of the file name and its size. The error is, or
+	// wraps, fs.ErrNotExist if the file doesn't exist. The reader is closed
+	// once the file is parsed if it is an io.Closer.
+	Open(name string) (io.ReaderAt, int64, error)
+}
+
+// DirStorage returns the storage of the binlog files of the directory dir.
+func DirStorage(dir string) BinlogStorage {
+	return dirStorage(dir)
+}
+
+type dirStorage string
+
+func (d dirStorage) List() ([]string, error) {
+	entries, err := os.ReadDir(string(d))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func (d dirStorage) Open(name string) (io.ReaderAt, int64, error) {
+	f, err := os.Open(filepath.Join(string(d), name))
+	if err != nil {
+		return nil, 0, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, st.Size(), nil
+}
+
+// isNotExist returns whether err is, or wraps, fs.ErrNotExist.
+func isNotExist(err error) bool {
+	for err != nil {
+		if os.IsNotExist(err) {
+			return true
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return false
+		}
+		err = u.Unwrap()
+	}
+	return false
+}
+
+// ParseStorage parses the binlog files of s like ParseDir, from the file
+// fromFile at the offset fromPos, or from the first file if fromFile is empty.
+func (p *BinlogParser) ParseStorage(s BinlogStorage, fromFile string, fromPos int64, onEvent OnEventFunc) error {
+	files, err := binlogFiles(s)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	name := fromFile
+	if name == "" {
+		if len(files) == 0 {
+			return nil
+		}
+		name = files[0].name
+		for _, f := range files[1:] {
+			if f.base != files[0].base {
+				return errors.Errorf("several binlog base names, %s and %s", files[0].base, f.base)
+			}
+		}
+	}
+	base, seq, err := mysql.ParseBinlogFileName(name)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	r, size, err := s.Open(name)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for {
+		var rotate *RotateEvent
+		p.Reset()
+		err = p.parseReaderAt(name, r, size, fromPos, func(e *BinlogEvent) error {
+			if ev, ok := e.Event.(*RotateEvent); ok && e.Header.Flags&LOG_EVENT_ARTIFICIAL_F == 0 {
+				rotate = ev
+			}
+			return onEvent(e)
+		})
+		if c, ok := r.(io.Closer); ok {
+			c.Close()
+		}
+		if err != nil {
+			return errors.Annotatef(err, "parse %s", name)
+		}
+		if atomic.LoadUint32(&p.stopProcessing) == 1 {
+			return nil
+		}
+
+		next, nextPos := "", int64(0)
+		if rotate != nil {
+			next, nextPos = string(rotate.NextLogName), int64(rotate.Position)
+		} else {
+			for _, f := range files {
+				if f.base == base && f.seq > seq {
+					next = f.name
+					break
+				}
+			}
+		}
+		if next == "" {
+			return nil
+		}
+
+		nextBase, nextSeq, err := mysql.ParseBinlogFileName(next)
+		if err != nil {
+			return errors.Trace(err)
+		} else if nextBase != base || nextSeq <= seq {
+			return errors.Errorf("%s rotates to %s, not a next file", name, next)
+		}
+		if r, size, err = s.Open(next); isNotExist(err) {
+			return nil
+		} else if err != nil {
+			return errors.Trace(err)
+		}
+		name, seq, fromPos = next, nextSeq, nextPos
+	}
+}
+
+type binlogFile struct {
+	name string
+	base string
+	seq  uint64
+}
+
+// binlogFiles returns the files of s named like binlog files, by base name
+// and sequence number.
+func binlogFiles(s BinlogStorage) ([]binlogFile, error) {
+	names, err := s.List()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var files []binlogFile
+	for _, name := range names {
+		base, seq, err := mysql.ParseBinlogFileName(name)
+		if err != nil {
+			// the index and other files
+			continue
+		}
+		files = append(files, binlogFile{name: name, base: base, seq: seq})
+	}
+	slices.SortFunc(files, func(a, b binlogFile) int {
+		if c := strings.Compare(a.base, b.base); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.seq, b.seq)
+	})
+	return files, nil
+}
@@ -2,9 +2,12 @@ package replication
 
 import (
 	"context"
+	"sync/atomic"
 	"time"
 
 	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
 )
 
 var (
@@ -17,6 +20,21 @@ type BinlogStreamer struct {
 	ch  chan *BinlogEvent
 	ech chan error
 	err error
+
+	// bufferedBytes is the raw size of the events in ch, see
+	// BinlogSyncerConfig.MaxBufferedEventBytes. released is signaled when events
+	// are read.
+	bufferedBytes    atomic.Int64
+	maxBufferedBytes int64
+	released         chan struct{}
+
+	// heartbeatPeriod is the period of the synthetic heartbeats, see
+	// SetSyntheticHeartbeatPeriod. lastRead is when the last event was read,
+	// lastPos the position after it and lastEventTime its timestamp.
+	heartbeatPeriod time.Duration
+	lastRead        time.Time
+	lastPos         mysql.Position
+	lastEventTime   time.Time
 }
 
 // GetEvent gets the binlog event one by one, it will block until Syncer receives any events from MySQL
@@ -26,9 +44,16 @@ func (s *BinlogStreamer) GetEvent(ctx context.Context) (*BinlogEvent, error) {
 		return nil, ErrNeedSyncAgain
 	}
 
+	heartbeat, stop := s.heartbeatTimer()
+	defer stop()
+
 	select {
 	case c := <-s.ch:
+		s.release(c)
+		s.track(c)
 		return c, nil
+	case <-heartbeat:
+		return s.syntheticHeartbeat(), nil
 	case s.err = <-s.ech:
 		return nil, s.err
 	case <-ctx.Done():
@@ -43,12 +68,19 @@ func (s *BinlogStreamer) GetEventWithStartTime(ctx context.Context, startTime ti
 		return nil, ErrNeedSyncAgain
 	}
 	startUnix := startTime.Unix()
+	heartbeat, stop := s.heartbeatTimer()
+	defer stop()
+
 	select {
 	case c := <-s.ch:
+		s.release(c)
+		s.track(c)
 		if int64(c.Header.Timestamp) >= startUnix {
 			return c, nil
 		}
 		return nil, nil
+	case <-heartbeat:
+		return s.syntheticHeartbeat(), nil
 	case s.err = <-s.ech:
 		return nil, s.err
 	case <-ctx.Done():
@@ -62,6 +94,8 @@ func (s *BinlogStreamer) DumpEvents() []*BinlogEvent {
 	events := make([]*BinlogEvent, count)
 	for i := range events {
 		events[i] = <-s.ch
+		s.release(events[i])
+		s.track(events[i])
 	}
 	return events
 }
@@ -94,6 +128,7 @@ func NewBinlogStreamerWithChanSize(chanSize int) *BinlogStreamer {
 
 	s.ch = make(chan *BinlogEvent, chanSize)
 	s.ech = make(chan error, 4)
+	s.released = make(chan struct{}, 1)
 
 	return s
 }
@@ -101,14 +136,51 @@ func NewBinlogStreamerWithChanSize(chanSize int) *BinlogStreamer {
 // AddEventToStreamer adds a binlog event to the streamer. You can use it when you want to add an event to the streamer manually.
 // can be used in replication handlers
 func (s *BinlogStreamer) AddEventToStreamer(ev *BinlogEvent) error {
+	s.bufferedBytes.Add(int64(len(ev.RawData)))
 	select {
 	case s.ch <- ev:
 		return nil
 	case err := <-s.ech:
+		s.release(ev)
 		return err
 	}
 }
 
+// BufferedBytes returns the raw size of the events waiting to be read.
+func (s *BinlogStreamer) BufferedBytes() int64 {
+	return s.bufferedBytes.Load()
+}
+
+// reserve waits until e fits in the limit of the buffered bytes and counts it,
+// it returns false if ctx is done before.
+func (s *BinlogStreamer) reserve(ctx context.Context, e *BinlogEvent) bool {
+	n := int64(len(e.RawData))
+	for s.maxBufferedBytes > 0 {
+		buffered := s.bufferedBytes.Load()
+		if buffered > 0 && buffered+n > s.maxBufferedBytes {
+			select {
+			case <-s.released:
+				continue
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if s.bufferedBytes.CompareAndSwap(buffered, buffered+n) {
+			return true
+		}
+	}
+	s.bufferedBytes.Add(n)
+	return true
+}
+
+func (s *BinlogStreamer) release(e *BinlogEvent) {
+	s.bufferedBytes.Add(-int64(len(e.RawData)))
+	select {
+	case s.released <- struct{}{}:
+	default:
+	}
+}
+
 // AddErrorToStreamer adds an error to the streamer.
 func (s *BinlogStreamer) AddErrorToStreamer(err error) bool {
 	select {
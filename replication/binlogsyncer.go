@@ -46,9 +46,20 @@ type BinlogSyncerConfig struct {
 	// Charset is for MySQL client character set
 	Charset string
 
-	// SemiSyncEnabled enables semi-sync or not.
+	// SemiSyncEnabled enables semi-sync or not. The syncer then acknowledges the
+	// events the server waits for, once they are handled: sent to the streamer,
+	// or returned by SynchronousEventHandler.HandleEvent. Semi-sync is disabled if
+	// the rpl_semi_sync_source (rpl_semi_sync_master before MySQL 8.0.26) plugin
+	// is not enabled on the server.
 	SemiSyncEnabled bool
 
+	// SemiSyncBeforeACK, if set, is called for each event the server waits an
+	// ACK for, before the ACK is sent. It is to acknowledge the events only once
+	// written, e.g. with RelayLog.WriteEvent, as the server may commit the
+	// transaction as soon as it receives the ACK. If it returns an error, no ACK
+	// is sent and the sync fails with it.
+	SemiSyncBeforeACK func(e *BinlogEvent) error
+
 	// RawModeEnabled is for not parsing binlog event.
 	RawModeEnabled bool
 
@@ -79,6 +90,14 @@ type BinlogSyncerConfig struct {
 	// FloatWithTrailingZero structure for floats.
 	UseFloatWithTrailingZero bool
 
+	// Decode VECTOR columns to []float32 instead of []byte.
+	UseFloat32Vector bool
+
+	// Convert the values of the character columns of the rows events in other
+	// character sets than UTF-8, like latin1 or gbk, to UTF-8, see
+	// BinlogParser.SetConvertCharset.
+	ConvertCharset bool
+
 	// RecvBufferSize sets the size in bytes of the operating system's receive buffer associated with the connection.
 	RecvBufferSize int
 
@@ -95,6 +114,25 @@ type BinlogSyncerConfig struct {
 	// whether disable re-sync for broken connection
 	DisableRetrySync bool
 
+	// ResumeByGTIDOnFailover makes the syncer check, when it re-syncs by file and
+	// position, that the position exists on the server it reconnected to. If the
+	// server changed (e.g. a VIP moved to another server after a failover) or the
+	// position is beyond its binary logs, the syncer re-syncs from the GTID set of
+	// the transactions received and keeps syncing by GTID, instead of failing with
+	// error 1236. The GTID set is known after a PREVIOUS_GTIDS_EVENT is received,
+	// or if it is passed to StartSyncWithGTIDFallback. The user needs the privilege
+	// to run SHOW BINARY LOGS. MySQL only.
+	ResumeByGTIDOnFailover bool
+
+	// DeduplicateEvents makes the syncer skip the events it delivers again after
+	// reconnecting, so within a BinlogSyncer each event is delivered once. Syncing
+	// by GTID, the server resends the transaction interrupted by the reconnect,
+	// or the last one if the reconnect happened right after its commit. Syncing by
+	// position, the events up to the last delivered position are skipped. The
+	// events sent by the server on each connection, like FORMAT_DESCRIPTION_EVENT
+	// or the fake ROTATE_EVENT, are still delivered.
+	DeduplicateEvents bool
+
 	// Only works when MySQL/MariaDB variable binlog_checksum=CRC32.
 	// For MySQL, binlog_checksum was introduced since 5.6.2, but CRC32 was set as default value since 5.6.6 .
 	// https://dev.mysql.com/doc/refman/5.6/en/replication-options-binary-log.html#option_mysqld_binlog-checksum
@@ -122,12 +160,50 @@ type BinlogSyncerConfig struct {
 
 	RowsEventDecodeFunc func(*RowsEvent, []byte) error
 
+	// RowsDecodeConcurrency is the number of goroutines decoding the rows of
+	// RowsEvents while the next events are read, which helps with large rows
+	// like JSON or BLOB columns. Events are still delivered in order. 0 or 1
+	// decodes in the syncer goroutine. Ignored if RowsEventDecodeFunc is set.
+	RowsDecodeConcurrency int
+
 	TableMapOptionalMetaDecodeFunc func([]byte) error
 
+	// TableColumnNamesFunc returns the full, ordered column names of a table.
+	// It lets RowsEvent.NamedRows map minimal row images (binlog_row_image=MINIMAL)
+	// to column names when binlog_row_metadata is not FULL.
+	TableColumnNamesFunc func(schema, table string) ([]string, error)
+
+	// EventDecoders are registered to the parser with RegisterEventDecoder.
+	EventDecoders map[EventType]EventDecoder
+
+	// AttachRowsQuery sets RowsEvent.Query to the statement of the preceding
+	// ROWS_QUERY_EVENT (MySQL, binlog_rows_query_log_events=ON) or
+	// ANNOTATE_ROWS_EVENT (MariaDB, binlog_annotate_row_events=ON).
+	AttachRowsQuery bool
+
 	DiscardGTIDSet bool
 
 	EventCacheCount int
 
+	// MaxEventSize is the size in bytes of the largest event accepted, 0 for no
+	// limit. Only the start of a larger event is kept in memory, then the sync fails
+	// with an EventTooLargeError, or the event is delivered as a TruncatedEvent if
+	// TruncateOversizedEvents is set.
+	MaxEventSize            int
+	TruncateOversizedEvents bool
+
+	// MaxBufferedEventBytes limits the raw size of the events waiting in the
+	// BinlogStreamer to be read by GetEvent, 0 for no limit. The syncer stops
+	// reading from the server while the limit is reached, an event is always let
+	// through if the streamer is empty. EventCacheCount still limits the count.
+	MaxBufferedEventBytes int64
+
+	// SyntheticHeartbeatPeriod makes the BinlogStreamer return a
+	// SyntheticHeartbeatEvent when no event has been read for the period, with
+	// the position of the last event. Unlike HeartbeatPeriod, the server is not
+	// involved. 0 disables it.
+	SyntheticHeartbeatPeriod time.Duration
+
 	// SynchronousEventHandler is used for synchronous event handling.
 	// This should not be used together with StartBackupWithHandler.
 	// If this is not nil, GetEvent does not need to be called.
@@ -166,6 +242,22 @@ type BinlogSyncer struct {
 	lastConnectionID uint32
 
 	retryCount int
+
+	// set by deferRowsDecode for the RowsEvent just parsed
+	deferredRows *deferredRows
+
+	// GTID set and last GTID received when syncing by position, and the UUID
+	// of the server, see ResumeByGTIDOnFailover
+	resumeGset      mysql.GTIDSet
+	resumeGTIDEvent *GTIDEvent
+	serverUUID      string
+
+	status syncStatus
+
+	dedup *eventDeduper
+
+	// the algorithm of binlog_checksum of the server, see negotiateChecksum
+	checksumAlg byte
 }
 
 // NewBinlogSyncer creates the BinlogSyncer with the given configuration.
@@ -195,15 +287,29 @@ func NewBinlogSyncer(cfg BinlogSyncerConfig) *BinlogSyncer {
 
 	b.cfg = cfg
 	b.parser = NewBinlogParser()
+	if cfg.DeduplicateEvents {
+		b.dedup = &eventDeduper{}
+	}
+
 	b.parser.SetFlavor(cfg.Flavor)
 	b.parser.SetRawMode(b.cfg.RawModeEnabled)
 	b.parser.SetParseTime(b.cfg.ParseTime)
 	b.parser.SetTimestampStringLocation(b.cfg.TimestampStringLocation)
 	b.parser.SetUseDecimal(b.cfg.UseDecimal)
 	b.parser.SetUseFloatWithTrailingZero(b.cfg.UseFloatWithTrailingZero)
+	b.parser.SetUseFloat32Vector(b.cfg.UseFloat32Vector)
+	b.parser.SetConvertCharset(b.cfg.ConvertCharset)
 	b.parser.SetVerifyChecksum(b.cfg.VerifyChecksum)
 	b.parser.SetRowsEventDecodeFunc(b.cfg.RowsEventDecodeFunc)
+	if b.concurrentRowsDecode() {
+		b.parser.deferRowsDecodeFunc = b.deferRowsDecode
+	}
 	b.parser.SetTableMapOptionalMetaDecodeFunc(b.cfg.TableMapOptionalMetaDecodeFunc)
+	b.parser.SetTableColumnNamesFunc(b.cfg.TableColumnNamesFunc)
+	b.parser.SetAttachRowsQuery(b.cfg.AttachRowsQuery)
+	for t, decoder := range b.cfg.EventDecoders {
+		b.parser.RegisterEventDecoder(t, decoder)
+	}
 	b.running = false
 	b.ctx, b.cancel = context.WithCancel(context.Background())
 
@@ -307,25 +413,8 @@ func (b *BinlogSyncer) registerSlave() error {
 	// save last last connection id for kill
 	b.lastConnectionID = b.c.GetConnectionID()
 
-	// for mysql 5.6+, binlog has a crc32 checksum
-	// before mysql 5.6, this will not work, don't matter.:-)
-	if r, err := b.c.Execute("SHOW GLOBAL VARIABLES LIKE 'BINLOG_CHECKSUM'"); err != nil {
+	if err := b.negotiateChecksum(); err != nil {
 		return errors.Trace(err)
-	} else {
-		s, _ := r.GetString(0, 1)
-		if s != "" {
-			// maybe CRC32 or NONE
-
-			// mysqlbinlog.cc use NONE, see its below comments:
-			// Make a notice to the server that this client
-			// is checksum-aware. It does not need the first fake Rotate
-			// necessary checksummed.
-			// That preference is specified below.
-
-			if _, err = b.c.Execute(`SET @master_binlog_checksum='NONE', @source_binlog_checksum='NONE'`); err != nil {
-				return errors.Trace(err)
-			}
-		}
 	}
 
 	if b.cfg.Flavor == mysql.MariaDBFlavor {
@@ -374,19 +463,31 @@ func (b *BinlogSyncer) enableSemiSync() error {
 		return nil
 	}
 
-	if r, err := b.c.Execute("SHOW VARIABLES LIKE 'rpl_semi_sync_master_enabled';"); err != nil {
+	// MySQL 8.0.26 renamed the plugins, the source plugin checks
+	// @rpl_semi_sync_replica, the former one @rpl_semi_sync_slave
+	r, err := b.c.Execute("SHOW VARIABLES WHERE Variable_name IN ('rpl_semi_sync_master_enabled', 'rpl_semi_sync_source_enabled');")
+	if err != nil {
 		return errors.Trace(err)
-	} else {
-		s, _ := r.GetString(0, 1)
-		if s != "ON" {
-			b.cfg.Logger.Error("master does not support semi synchronous replication, use no semi-sync")
-			b.cfg.SemiSyncEnabled = false
-			return nil
+	}
+	userVar := ""
+	for i := 0; i < r.RowNumber(); i++ {
+		name, _ := r.GetString(i, 0)
+		if s, _ := r.GetString(i, 1); s != "ON" {
+			continue
+		}
+		if name == "rpl_semi_sync_source_enabled" {
+			userVar = "@rpl_semi_sync_replica"
+		} else {
+			userVar = "@rpl_semi_sync_slave"
 		}
 	}
+	if userVar == "" {
+		b.cfg.Logger.Error("master does not support semi synchronous replication, use no semi-sync")
+		b.cfg.SemiSyncEnabled = false
+		return nil
+	}
 
-	_, err := b.c.Execute(`SET @rpl_semi_sync_slave = 1;`)
-	if err != nil {
+	if _, err = b.c.Execute(fmt.Sprintf("SET %s = 1;", userVar)); err != nil {
 		return errors.Trace(err)
 	}
 
@@ -415,6 +516,8 @@ func (b *BinlogSyncer) startDumpStream() *BinlogStreamer {
 	b.running = true
 
 	s := NewBinlogStreamerWithChanSize(b.cfg.EventCacheCount)
+	s.maxBufferedBytes = b.cfg.MaxBufferedEventBytes
+	s.SetSyntheticHeartbeatPeriod(b.cfg.SyntheticHeartbeatPeriod)
 
 	b.wg.Add(1)
 	go b.onStream(s)
@@ -448,7 +551,11 @@ func (b *BinlogSyncer) StartSync(pos mysql.Position) (*BinlogStreamer, error) {
 		return nil, errors.Trace(errSyncRunning)
 	}
 
-	if err := b.prepareSyncPos(pos); err != nil {
+	if b.resumeByGTIDOnFailover() {
+		if err := b.prepareResumePos(pos); err != nil {
+			return nil, errors.Trace(err)
+		}
+	} else if err := b.prepareSyncPos(pos); err != nil {
 		return nil, errors.Trace(err)
 	}
 
@@ -635,6 +742,17 @@ func (b *BinlogSyncer) writeRegisterSlaveCommand() error {
 	return b.c.WritePacket(data)
 }
 
+// ackSemiSync acknowledges e, handled up to b.nextPos, once SemiSyncBeforeACK
+// returns.
+func (b *BinlogSyncer) ackSemiSync(e *BinlogEvent) error {
+	if b.cfg.SemiSyncBeforeACK != nil {
+		if err := b.cfg.SemiSyncBeforeACK(e); err != nil {
+			return errors.Annotate(err, "before semi-sync ACK")
+		}
+	}
+	return errors.Trace(b.replySemiSyncACK(b.nextPos))
+}
+
 func (b *BinlogSyncer) replySemiSyncACK(p mysql.Position) error {
 	b.c.ResetSequence()
 
@@ -663,6 +781,9 @@ func (b *BinlogSyncer) retrySync() error {
 
 	b.parser.Reset()
 	b.prevMySQLGTIDEvent = nil
+	if b.dedup != nil {
+		b.dedup.reconnected()
+	}
 
 	if b.prevGset != nil {
 		extra := []interface{}{slog.String("GTID Set", b.prevGset.String())}
@@ -676,7 +797,11 @@ func (b *BinlogSyncer) retrySync() error {
 		}
 	} else {
 		b.cfg.Logger.Info("begin to re-sync", slog.String("file", b.nextPos.Name), slog.Uint64("position", uint64(b.nextPos.Pos)))
-		if err := b.prepareSyncPos(b.nextPos); err != nil {
+		if b.resumeByGTIDOnFailover() {
+			if err := b.prepareResumePos(b.nextPos); err != nil {
+				return errors.Trace(err)
+			}
+		} else if err := b.prepareSyncPos(b.nextPos); err != nil {
 			return errors.Trace(err)
 		}
 	}
@@ -734,8 +859,14 @@ func (b *BinlogSyncer) onStream(s *BinlogStreamer) {
 		b.wg.Done()
 	}()
 
+	var decoder *rowsDecoder
+	if b.concurrentRowsDecode() {
+		decoder = newRowsDecoder(b, s, b.cfg.RowsDecodeConcurrency)
+		defer decoder.close()
+	}
+
 	for {
-		data, err := b.c.ReadPacket()
+		data, size, err := b.readPacket()
 		select {
 		case <-b.ctx.Done():
 			s.close()
@@ -745,6 +876,9 @@ func (b *BinlogSyncer) onStream(s *BinlogStreamer) {
 
 		if err != nil {
 			b.cfg.Logger.Error(err.Error())
+			if decoder != nil && !decoder.wait() {
+				return
+			}
 			// we meet connection error, should re-connect again with
 			// last nextPos or nextGTID we got.
 			if len(b.nextPos.Name) == 0 && b.prevGset == nil {
@@ -802,12 +936,32 @@ func (b *BinlogSyncer) onStream(s *BinlogStreamer) {
 		switch data[0] {
 		case mysql.OK_HEADER:
 			// Parse the event
-			e, needACK, err := b.parseEvent(data)
+			var e *BinlogEvent
+			var needACK bool
+			if b.oversized(data, size) {
+				e, needACK, err = b.parseOversizedEvent(data, size)
+			} else {
+				e, needACK, err = b.parseEvent(data)
+			}
 			if err != nil {
 				s.closeWithError(err)
 				return
 			}
 
+			if decoder != nil {
+				// the event is handled by the decoder, the ACK must wait for it
+				if !decoder.add(e) || needACK && !decoder.wait() {
+					return
+				}
+				if needACK {
+					if err = b.ackSemiSync(e); err != nil {
+						s.closeWithError(err)
+						return
+					}
+				}
+				continue
+			}
+
 			// Handle the event and send ACK if necessary
 			err = b.handleEventAndACK(s, e, needACK)
 			if err != nil {
@@ -831,6 +985,10 @@ func (b *BinlogSyncer) onStream(s *BinlogStreamer) {
 	}
 }
 
+func (b *BinlogSyncer) concurrentRowsDecode() bool {
+	return b.cfg.RowsDecodeConcurrency > 1 && b.cfg.RowsEventDecodeFunc == nil && !b.cfg.RawModeEnabled
+}
+
 // parseEvent parses the raw data into a BinlogEvent.
 // It only handles parsing and does not perform any side effects.
 // Returns the parsed BinlogEvent, a boolean indicating if an ACK is needed, and an error if the
@@ -908,6 +1066,9 @@ func (b *BinlogSyncer) handleEventAndACK(s *BinlogStreamer, e *BinlogEvent, need
 			b.prevGset = prev
 		}
 
+	case *FormatDescriptionEvent:
+		b.checkChecksumAlgorithm(event)
+
 	case *XIDEvent:
 		if !b.cfg.DiscardGTIDSet {
 			event.GSet = b.getCurrentGtidSet()
@@ -919,6 +1080,23 @@ func (b *BinlogSyncer) handleEventAndACK(s *BinlogStreamer, e *BinlogEvent, need
 		}
 	}
 
+	b.status.update(e, b.nextPos)
+
+	if b.dedup != nil && b.dedup.duplicate(e, b.nextPos, b.prevGset == nil) {
+		b.cfg.Logger.Debug("skip duplicate event", slog.String("type", e.Header.EventType.String()),
+			slog.Uint64("position", uint64(e.Header.LogPos)))
+		if needACK {
+			return errors.Trace(b.replySemiSyncACK(b.nextPos))
+		}
+		return nil
+	}
+
+	if b.prevGset == nil && b.resumeByGTIDOnFailover() {
+		if err := b.trackResumeGTIDs(e); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
 	// Use SynchronousEventHandler if it's set
 	if b.cfg.SynchronousEventHandler != nil {
 		err := b.cfg.SynchronousEventHandler.HandleEvent(e)
@@ -927,6 +1105,9 @@ func (b *BinlogSyncer) handleEventAndACK(s *BinlogStreamer, e *BinlogEvent, need
 		}
 	} else {
 		// Asynchronous mode: send the event to the streamer channel
+		if !s.reserve(b.ctx, e) {
+			return errors.New("sync is being closed...")
+		}
 		select {
 		case s.ch <- e:
 		case <-b.ctx.Done():
@@ -935,10 +1116,7 @@ func (b *BinlogSyncer) handleEventAndACK(s *BinlogStreamer, e *BinlogEvent, need
 	}
 
 	if needACK {
-		err := b.replySemiSyncACK(b.nextPos)
-		if err != nil {
-			return errors.Trace(err)
-		}
+		return b.ackSemiSync(e)
 	}
 
 	return nil
@@ -952,6 +1130,14 @@ func (b *BinlogSyncer) getCurrentGtidSet() mysql.GTIDSet {
 	return nil
 }
 
+// ChecksumAlgorithm returns the checksum algorithm of the events negotiated
+// with the server, BINLOG_CHECKSUM_ALG_CRC32 or BINLOG_CHECKSUM_ALG_OFF, or
+// BINLOG_CHECKSUM_ALG_UNDEF before the first connection and for the servers
+// without checksums, before MySQL 5.6.1 and MariaDB 5.3.
+func (b *BinlogSyncer) ChecksumAlgorithm() byte {
+	return b.checksumAlg
+}
+
 // LastConnectionID returns last connectionID.
 func (b *BinlogSyncer) LastConnectionID() uint32 {
 	return b.lastConnectionID
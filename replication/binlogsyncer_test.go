@@ -1,11 +1,21 @@
 package replication
 
 import (
+	"encoding/binary"
+	"net"
 	"os"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/pingcap/errors"
 	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/packet"
 )
 
 func TestLocalHostname(t *testing.T) {
@@ -48,3 +58,224 @@ func TestLocalHostname_os(t *testing.T) {
 	h, _ := os.Hostname()
 	require.Equal(t, h, b.localHostname())
 }
+
+type collectEventHandler struct {
+	sync.Mutex
+	events []*BinlogEvent
+}
+
+func (h *collectEventHandler) HandleEvent(e *BinlogEvent) error {
+	h.Lock()
+	h.events = append(h.events, e)
+	h.Unlock()
+	return nil
+}
+
+func TestRowsDecoder(t *testing.T) {
+	fde := []byte{0x64, 0x61, 0x72, 0x63, 0xf, 0xb, 0x0, 0x0, 0x0, 0x77, 0x0, 0x0, 0x0, 0x7b, 0x0, 0x0, 0x0, 0x1, 0x0, 0x4, 0x0, 0x35, 0x2e, 0x37, 0x2e, 0x32, 0x32, 0x2d, 0x6c, 0x6f, 0x67, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x64, 0x61, 0x72, 0x63, 0x13, 0x38, 0xd, 0x0, 0x8, 0x0, 0x12, 0x0, 0x4, 0x4, 0x4, 0x4, 0x12, 0x0, 0x0, 0x5f, 0x0, 0x4, 0x1a, 0x8, 0x0, 0x0, 0x0, 0x8, 0x8, 0x8, 0x2, 0x0, 0x0, 0x0, 0xa, 0xa, 0xa, 0x2a, 0x2a, 0x0, 0x12, 0x34, 0x0, 0x1, 0xb8, 0x78, 0x9d, 0xfe}
+	tableMap := []byte{0x8d, 0x61, 0x72, 0x63, 0x13, 0xb, 0x0, 0x0, 0x0, 0x2c, 0x0, 0x0, 0x0, 0xa7, 0x0, 0x0, 0x0, 0x1, 0x0, 0x6c, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x2, 0x64, 0x62, 0x0, 0x3, 0x74, 0x62, 0x6c, 0x0, 0x1, 0x3, 0x0, 0x0, 0x63, 0x17, 0xe6, 0xf0}
+	// INSERT INTO tbl VALUES (1)
+	rows := []byte{0xb6, 0x61, 0x72, 0x63, 0x1e, 0xb, 0x0, 0x0, 0x0, 0x28, 0x0, 0x0, 0x0, 0xcf, 0x0, 0x0, 0x0, 0x1, 0x0, 0x6c, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x2, 0x0, 0x1, 0xff, 0x0, 0x1, 0x0, 0x0, 0x0, 0xf9, 0xf7, 0x89, 0x2a}
+
+	h := &collectEventHandler{}
+	b := NewBinlogSyncer(BinlogSyncerConfig{
+		ServerID:                1,
+		RowsDecodeConcurrency:   4,
+		SynchronousEventHandler: h,
+	})
+	defer b.Close()
+
+	d := newRowsDecoder(b, NewBinlogStreamer(), 4)
+
+	data := [][]byte{fde}
+	for i := 0; i < 100; i++ {
+		data = append(data, tableMap, rows)
+	}
+	for _, raw := range data {
+		e, err := b.parser.Parse(raw)
+		require.NoError(t, err)
+		require.True(t, d.add(e))
+	}
+	require.True(t, d.wait())
+	d.close()
+
+	require.Len(t, h.events, len(data))
+	for i, e := range h.events {
+		require.Equal(t, data[i], e.RawData)
+		if re, ok := e.Event.(*RowsEvent); ok {
+			require.Equal(t, [][]interface{}{{int32(1)}}, re.Rows)
+		}
+	}
+	require.Equal(t, uint32(0xcf), b.nextPos.Pos)
+}
+
+func TestTrackResumeGTIDs(t *testing.T) {
+	b := BinlogSyncer{cfg: BinlogSyncerConfig{ResumeByGTIDOnFailover: true}}
+	sid := uuid.MustParse("3e11fa47-71ca-11e1-9e33-c80aa9429562")
+	gtid := func(gno int64) *BinlogEvent {
+		return &BinlogEvent{Event: &GTIDEvent{SID: sid[:], GNO: gno}}
+	}
+
+	// the GTID set is unknown until a PREVIOUS_GTIDS_EVENT
+	require.NoError(t, b.trackResumeGTIDs(gtid(5)))
+	require.NoError(t, b.trackResumeGTIDs(&BinlogEvent{Event: &XIDEvent{}}))
+	require.Nil(t, b.resumeGset)
+
+	events := []*BinlogEvent{
+		{Event: &PreviousGTIDsEvent{GTIDSets: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}},
+		gtid(6),
+		{Event: &QueryEvent{Query: []byte("BEGIN")}},
+		{Event: &XIDEvent{}},
+		gtid(7),
+		{Event: &QueryEvent{Query: []byte("CREATE TABLE t (id int)")}},
+		gtid(8),
+		{Event: &QueryEvent{Query: []byte("BEGIN")}},
+	}
+	for _, e := range events {
+		require.NoError(t, b.trackResumeGTIDs(e))
+	}
+	// the transaction in progress is not in the set
+	require.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-7", b.resumeGset.String())
+
+	// a GTID set passed to StartSyncWithGTIDFallback
+	b.resumeGTIDEvent = nil
+	fallback, err := mysql.ParseMysqlGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-2")
+	require.NoError(t, err)
+	b.resumeGset = fallback
+	for _, e := range []*BinlogEvent{gtid(3), {Event: &XIDEvent{}}} {
+		require.NoError(t, b.trackResumeGTIDs(e))
+	}
+	require.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-3", b.resumeGset.String())
+}
+
+func TestSyncStatus(t *testing.T) {
+	b := BinlogSyncer{}
+
+	status, err := b.Status()
+	require.NoError(t, err)
+	require.Nil(t, status.LastGTID)
+	require.Zero(t, status.Lag)
+
+	sid := uuid.MustParse("3e11fa47-71ca-11e1-9e33-c80aa9429562")
+	ts := uint32(time.Now().Add(-time.Minute).Unix())
+	b.status.update(&BinlogEvent{Header: &EventHeader{Timestamp: ts}, Event: &GTIDEvent{SID: sid[:], GNO: 7}},
+		mysql.Position{Name: "mysql-bin.000001", Pos: 100})
+	b.status.update(&BinlogEvent{Header: &EventHeader{Timestamp: ts}, Event: &XIDEvent{}},
+		mysql.Position{Name: "mysql-bin.000001", Pos: 200})
+
+	status, err = b.Status()
+	require.NoError(t, err)
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000001", Pos: 200}, status.Position)
+	require.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:7", status.LastGTID.String())
+	require.GreaterOrEqual(t, status.Lag, time.Minute)
+	require.GreaterOrEqual(t, b.Lag(), time.Minute)
+
+	// a heartbeat means the syncer is caught up
+	b.status.update(&BinlogEvent{Header: &EventHeader{EventType: HEARTBEAT_EVENT}, Event: &GenericEvent{}},
+		mysql.Position{Name: "mysql-bin.000001", Pos: 200})
+	require.Less(t, b.Lag(), time.Minute)
+}
+
+func TestEventDeduplication(t *testing.T) {
+	sid := uuid.MustParse("3e11fa47-71ca-11e1-9e33-c80aa9429562")
+	ev := func(typ EventType, pos uint32, e Event) *BinlogEvent {
+		return &BinlogEvent{Header: &EventHeader{EventType: typ, LogPos: pos}, Event: e}
+	}
+	gtid := func(gno int64, pos uint32) *BinlogEvent {
+		return ev(GTID_EVENT, pos, &GTIDEvent{SID: sid[:], GNO: gno})
+	}
+	rows := func(pos uint32) *BinlogEvent {
+		return ev(WRITE_ROWS_EVENTv2, pos, &RowsEvent{})
+	}
+	xid := func(pos uint32) *BinlogEvent {
+		return ev(XID_EVENT, pos, &XIDEvent{})
+	}
+	fde := ev(FORMAT_DESCRIPTION_EVENT, 0, &FormatDescriptionEvent{})
+	pos := mysql.Position{Name: "mysql-bin.000001"}
+
+	var delivered []uint32
+	d := &eventDeduper{}
+	send := func(byPos bool, events ...*BinlogEvent) {
+		for _, e := range events {
+			if !d.duplicate(e, pos, byPos) {
+				delivered = append(delivered, e.Header.LogPos)
+			}
+		}
+	}
+
+	// by GTID, reconnecting in the middle of transaction 2
+	send(false, fde, gtid(1, 100), rows(200), xid(300), gtid(2, 400), rows(500))
+	d.reconnected()
+	send(false, fde, gtid(2, 400), rows(500), rows(600), xid(700))
+	require.Equal(t, []uint32{0, 100, 200, 300, 400, 500, 0, 600, 700}, delivered)
+
+	// reconnecting right after the commit of transaction 2
+	delivered = nil
+	d.reconnected()
+	send(false, fde, gtid(2, 400), rows(500), rows(600), xid(700), gtid(3, 800), xid(900))
+	require.Equal(t, []uint32{0, 800, 900}, delivered)
+
+	// by position
+	delivered = nil
+	d.reconnected()
+	send(true, fde, rows(800), xid(900), gtid(4, 1000), xid(1100))
+	require.Equal(t, []uint32{0, 1000, 1100}, delivered)
+}
+
+type eventHandlerFunc func(e *BinlogEvent) error
+
+func (f eventHandlerFunc) HandleEvent(e *BinlogEvent) error {
+	return f(e)
+}
+
+func TestSemiSyncBeforeACK(t *testing.T) {
+	server, conn := net.Pipe()
+	defer server.Close()
+	defer conn.Close()
+
+	var steps []string
+	b := &BinlogSyncer{
+		cfg: BinlogSyncerConfig{
+			SemiSyncEnabled: true,
+			SynchronousEventHandler: eventHandlerFunc(func(e *BinlogEvent) error {
+				steps = append(steps, "handle")
+				return nil
+			}),
+			SemiSyncBeforeACK: func(e *BinlogEvent) error {
+				steps = append(steps, "write")
+				if e.Header.LogPos == 400 {
+					return errors.New("disk full")
+				}
+				return nil
+			},
+		},
+		c:       &client.Conn{Conn: packet.NewConn(conn)},
+		nextPos: mysql.Position{Name: "mysql-bin.000001", Pos: 4},
+	}
+	xid := func(pos uint32) *BinlogEvent {
+		return &BinlogEvent{Header: &EventHeader{EventType: XID_EVENT, LogPos: pos}, Event: &XIDEvent{}}
+	}
+
+	acks := make(chan []byte, 1)
+	go func() {
+		data, err := packet.NewConn(server).ReadPacket()
+		if err == nil {
+			acks <- data
+		}
+	}()
+	require.NoError(t, b.handleEventAndACK(nil, xid(300), true))
+	ack := <-acks
+	require.Equal(t, SemiSyncIndicator, ack[0])
+	require.Equal(t, uint64(300), binary.LittleEndian.Uint64(ack[1:]))
+	require.Equal(t, "mysql-bin.000001", string(ack[9:]))
+	require.Equal(t, []string{"handle", "write"}, steps)
+
+	// no ACK if the event is not written
+	err := b.handleEventAndACK(nil, xid(400), true)
+	require.ErrorContains(t, err, "disk full")
+
+	// nor for the events the server does not wait an ACK for
+	steps = nil
+	require.NoError(t, b.handleEventAndACK(nil, xid(500), false))
+	require.Equal(t, []string{"handle"}, steps)
+}
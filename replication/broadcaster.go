@@ -0,0 +1,249 @@
+package replication
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// ErrPositionPassed is returned by Broadcaster.View for a start position the
+// broadcaster has already passed.
+var ErrPositionPassed = errors.New("the broadcaster has passed the position")
+
+// EventFilter returns whether a BroadcastView receives the event.
+type EventFilter func(e *BinlogEvent) bool
+
+// Broadcaster fans out the events of one BinlogSyncer to several views, each
+// with its own filter and position, so N consumers of the same master don't
+// need N replica registrations. Each event is delivered to the views in turn:
+// a view whose buffer is full blocks the others, no event is dropped.
+type Broadcaster struct {
+	syncer   *BinlogSyncer
+	chanSize int
+
+	mu    sync.Mutex
+	views map[*BroadcastView]struct{}
+	// pos is the position after the last event delivered
+	pos     mysql.Position
+	err     error
+	started bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// BroadcastView is a view of a Broadcaster, read with the methods of its
+// BinlogStreamer. Its errors are the errors of the broadcaster, ErrSyncClosed
+// once closed.
+type BroadcastView struct {
+	*BinlogStreamer
+
+	b      *Broadcaster
+	filter EventFilter
+	start  mysql.Position
+	// started is set once the broadcaster reaches start
+	started bool
+
+	posMu sync.Mutex
+	pos   mysql.Position
+}
+
+// NewBroadcaster returns a broadcaster of the events of syncer, not started
+// yet. chanSize is the buffer of the views, 10240 events if <= 0.
+func NewBroadcaster(syncer *BinlogSyncer, chanSize int) *Broadcaster {
+	return &Broadcaster{
+		syncer:   syncer,
+		chanSize: chanSize,
+		views:    make(map[*BroadcastView]struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start starts syncing from pos, the earliest position of the views.
+func (b *Broadcaster) Start(pos mysql.Position) error {
+	b.mu.Lock()
+	b.pos = pos
+	b.mu.Unlock()
+	s, err := b.syncer.StartSync(pos)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	b.run(s)
+	return nil
+}
+
+// StartGTID starts syncing from gset, the position of the broadcaster is known
+// from the first RotateEvent.
+func (b *Broadcaster) StartGTID(gset mysql.GTIDSet) error {
+	s, err := b.syncer.StartSyncGTID(gset)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	b.run(s)
+	return nil
+}
+
+// View returns a new view receiving the events accepted by filter, all if nil,
+// from start, or from the current position of the broadcaster if start is
+// zero. The RotateEvents and FormatDescriptionEvents are always received.
+// ErrPositionPassed is returned if the broadcaster is already after start.
+func (b *Broadcaster) View(start mysql.Position, filter EventFilter) (*BroadcastView, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return nil, b.err
+	}
+	if start.Name != "" && b.started && b.pos.Name != "" && start.Compare(b.pos) < 0 {
+		return nil, errors.Annotatef(ErrPositionPassed, "view at %s, broadcaster at %s", start, b.pos)
+	}
+
+	v := &BroadcastView{
+		BinlogStreamer: NewBinlogStreamerWithChanSize(b.chanSize),
+		b:              b,
+		filter:         filter,
+		start:          start,
+		started:        start.Name == "",
+		pos:            start,
+	}
+	if v.started {
+		v.pos = b.pos
+	}
+	b.views[v] = struct{}{}
+	return v, nil
+}
+
+// Position returns the position of the broadcaster, after the last event
+// delivered to the views.
+func (b *Broadcaster) Position() mysql.Position {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.pos
+}
+
+// Close stops syncing and closes the views.
+func (b *Broadcaster) Close() {
+	// the views are closed first to unblock the delivery
+	b.stop(ErrSyncClosed)
+
+	b.mu.Lock()
+	cancel := b.cancel
+	b.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-b.done
+	}
+	b.syncer.Close()
+}
+
+func (b *Broadcaster) run(s *BinlogStreamer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b.mu.Lock()
+	b.cancel = cancel
+	b.started = true
+	b.mu.Unlock()
+
+	go func() {
+		defer close(b.done)
+		for {
+			e, err := s.GetEvent(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					b.stop(err)
+				}
+				return
+			}
+			b.deliver(e)
+		}
+	}()
+}
+
+// deliver sends e to the views.
+func (b *Broadcaster) deliver(e *BinlogEvent) {
+	b.mu.Lock()
+	trackPosition(&b.pos, e)
+	pos := b.pos
+	views := make([]*BroadcastView, 0, len(b.views))
+	for v := range b.views {
+		views = append(views, v)
+	}
+	b.mu.Unlock()
+
+	for _, v := range views {
+		if !v.reached(pos) {
+			continue
+		}
+		if v.accept(e) {
+			if err := v.AddEventToStreamer(e); err != nil {
+				// closed while blocked, the error was consumed
+				v.closeWithError(err)
+				continue
+			}
+		}
+		v.posMu.Lock()
+		v.pos = pos
+		v.posMu.Unlock()
+	}
+}
+
+// stop closes the views with err.
+func (b *Broadcaster) stop(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err == nil {
+		b.err = err
+	}
+	for v := range b.views {
+		v.closeWithError(err)
+		delete(b.views, v)
+	}
+}
+
+// reached returns whether the events ending at pos are after the start of v,
+// the events ending at or before start were read before.
+func (v *BroadcastView) reached(pos mysql.Position) bool {
+	if !v.started && pos.Name != "" && pos.Compare(v.start) > 0 {
+		v.started = true
+	}
+	return v.started
+}
+
+// accept returns whether v receives e.
+func (v *BroadcastView) accept(e *BinlogEvent) bool {
+	switch e.Event.(type) {
+	case *RotateEvent, *FormatDescriptionEvent:
+		return true
+	}
+	return v.filter == nil || v.filter(e)
+}
+
+// Position returns the position after the last event received by the view,
+// the events filtered out included.
+func (v *BroadcastView) Position() mysql.Position {
+	v.posMu.Lock()
+	defer v.posMu.Unlock()
+	return v.pos
+}
+
+// Close removes the view from the broadcaster, its GetEvent returns
+// ErrSyncClosed.
+func (v *BroadcastView) Close() {
+	v.b.mu.Lock()
+	delete(v.b.views, v)
+	v.b.mu.Unlock()
+	v.closeWithError(ErrSyncClosed)
+}
+
+// trackPosition updates pos to the position after e.
+func trackPosition(pos *mysql.Position, e *BinlogEvent) {
+	if e.Header == nil {
+		return
+	}
+	if r, ok := e.Event.(*RotateEvent); ok {
+		*pos = mysql.Position{Name: string(r.NextLogName), Pos: uint32(r.Position)}
+	} else if e.Header.LogPos > 0 {
+		pos.Pos = e.Header.LogPos
+	}
+}
@@ -0,0 +1,55 @@
+package replication
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+func TestBroadcasterViews(t *testing.T) {
+	b := NewBroadcaster(nil, 10)
+	b.pos = mysql.Position{Name: "mysql-bin.000001", Pos: 4}
+	b.started = true
+
+	all, err := b.View(mysql.Position{}, nil)
+	require.NoError(t, err)
+	queries, err := b.View(mysql.Position{Name: "mysql-bin.000001", Pos: 200}, func(e *BinlogEvent) bool {
+		_, ok := e.Event.(*QueryEvent)
+		return ok
+	})
+	require.NoError(t, err)
+
+	events := []*BinlogEvent{
+		{Header: &EventHeader{EventType: QUERY_EVENT, LogPos: 100}, Event: &QueryEvent{Query: []byte("BEGIN")}},
+		{Header: &EventHeader{EventType: XID_EVENT, LogPos: 200}, Event: &XIDEvent{XID: 1}},
+		{Header: &EventHeader{EventType: QUERY_EVENT, LogPos: 300}, Event: &QueryEvent{Query: []byte("BEGIN")}},
+		{Header: &EventHeader{EventType: XID_EVENT, LogPos: 400}, Event: &XIDEvent{XID: 2}},
+		{Header: &EventHeader{EventType: ROTATE_EVENT, LogPos: 450}, Event: &RotateEvent{Position: 4, NextLogName: []byte("mysql-bin.000002")}},
+	}
+	for _, e := range events {
+		b.deliver(e)
+	}
+
+	require.Equal(t, events, all.DumpEvents())
+	require.Equal(t, []*BinlogEvent{events[2], events[4]}, queries.DumpEvents())
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000002", Pos: 4}, queries.Position())
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000002", Pos: 4}, b.Position())
+
+	_, err = b.View(mysql.Position{Name: "mysql-bin.000001", Pos: 300}, nil)
+	require.ErrorIs(t, err, ErrPositionPassed)
+
+	all.Close()
+	_, err = all.GetEvent(context.Background())
+	require.ErrorIs(t, err, ErrSyncClosed)
+	b.deliver(events[0])
+	require.Len(t, queries.DumpEvents(), 1)
+
+	b.stop(ErrSyncClosed)
+	_, err = queries.GetEvent(context.Background())
+	require.ErrorIs(t, err, ErrSyncClosed)
+	_, err = b.View(mysql.Position{}, nil)
+	require.ErrorIs(t, err, ErrSyncClosed)
+}
@@ -0,0 +1,83 @@
+package replication
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// checksumAlgorithmByName returns the algorithm of the value of binlog_checksum,
+// BINLOG_CHECKSUM_ALG_UNDEF for "", the servers without the variable.
+func checksumAlgorithmByName(name string) (byte, error) {
+	switch strings.ToUpper(name) {
+	case "":
+		return BINLOG_CHECKSUM_ALG_UNDEF, nil
+	case "NONE":
+		return BINLOG_CHECKSUM_ALG_OFF, nil
+	case "CRC32":
+		return BINLOG_CHECKSUM_ALG_CRC32, nil
+	default:
+		return 0, errors.Errorf("unsupported binlog_checksum %s", name)
+	}
+}
+
+// checksumAlgorithmName returns the name of alg like binlog_checksum.
+func checksumAlgorithmName(alg byte) string {
+	switch alg {
+	case BINLOG_CHECKSUM_ALG_OFF:
+		return "NONE"
+	case BINLOG_CHECKSUM_ALG_CRC32:
+		return "CRC32"
+	default:
+		return "UNDEF"
+	}
+}
+
+// negotiateChecksum gets the checksum algorithm of the server, and tells it that
+// the syncer is checksum-aware, before the binlog dump.
+func (b *BinlogSyncer) negotiateChecksum() error {
+	// binlog_checksum is since MySQL 5.6.1 and MariaDB 5.3, the events of the
+	// older servers have no checksums
+	r, err := b.c.Execute("SHOW GLOBAL VARIABLES LIKE 'BINLOG_CHECKSUM'")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	s, _ := r.GetString(0, 1)
+	alg, err := checksumAlgorithmByName(s)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	b.checksumAlg = alg
+	if alg == BINLOG_CHECKSUM_ALG_UNDEF {
+		return nil
+	}
+
+	// mysqlbinlog.cc use NONE, see its below comments:
+	// Make a notice to the server that this client
+	// is checksum-aware. It does not need the first fake Rotate
+	// necessary checksummed.
+	// That preference is specified below.
+	//
+	// The events then have the checksums of the algorithm of the
+	// FormatDescriptionEvent of their binlog file.
+	if _, err = b.c.Execute(`SET @master_binlog_checksum='NONE', @source_binlog_checksum='NONE'`); err != nil {
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// checkChecksumAlgorithm compares the checksum algorithm of e, the
+// FormatDescriptionEvent of a binlog file, with the negotiated one. They differ
+// for the files written before a change of binlog_checksum: the events are
+// still read with the algorithm of their file, but without checksums to verify
+// if it is off.
+func (b *BinlogSyncer) checkChecksumAlgorithm(e *FormatDescriptionEvent) {
+	if b.checksumAlg == BINLOG_CHECKSUM_ALG_UNDEF || e.ChecksumAlgorithm == b.checksumAlg {
+		return
+	}
+	b.cfg.Logger.Warn("checksum algorithm of binlog file differs from binlog_checksum",
+		slog.String("file", b.nextPos.Name),
+		slog.String("algorithm", checksumAlgorithmName(e.ChecksumAlgorithm)),
+		slog.String("binlog_checksum", checksumAlgorithmName(b.checksumAlg)))
+}
@@ -0,0 +1,42 @@
+package replication
+
+import "time"
+
+// updatedDBNamesOverMax is the count of Q_UPDATED_DB_NAMES for the statements
+// updating too many databases, without their names.
+const updatedDBNamesOverMax = 254
+
+// Microseconds returns the microseconds of the start time of the query, whose
+// seconds are the timestamp of the event, from the status variable
+// Q_MICROSECONDS of MySQL or Q_HRNOW of MariaDB. They are only written for the
+// queries using them, like NOW(6), ok is false otherwise.
+func (e *QueryEvent) Microseconds() (usec uint32, ok bool) {
+	// the variables before an unknown one are parsed
+	v, _ := e.ParseStatusVars()
+	if v.Has(Q_MICROSECONDS) || v.Has(Q_HRNOW) {
+		return v.Microseconds, true
+	}
+	return 0, false
+}
+
+// CommitTime returns the time of the event with the best precision available:
+// the commit time of the transaction in microseconds for the GTID events of
+// MySQL 8.0, immediate_commit_timestamp, the start time of the query in
+// microseconds for the queries with them, see QueryEvent.Microseconds, and the
+// timestamp of the event in seconds otherwise. MariaDB doesn't write sub-second
+// times in its GTID events, only in the queries using them. The events of a
+// transaction have the time of their statement, the XIDEvent the time of the
+// commit.
+func (e *BinlogEvent) CommitTime() time.Time {
+	switch ev := e.Event.(type) {
+	case *GTIDEvent:
+		if ev.ImmediateCommitTimestamp > 0 {
+			return ev.ImmediateCommitTime()
+		}
+	case *QueryEvent:
+		if usec, ok := ev.Microseconds(); ok {
+			return time.Unix(int64(e.Header.Timestamp), int64(usec)*1000)
+		}
+	}
+	return time.Unix(int64(e.Header.Timestamp), 0)
+}
@@ -0,0 +1,103 @@
+package replication
+
+import (
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// eventDeduper suppresses the events delivered again after a reconnect, see
+// BinlogSyncerConfig.DeduplicateEvents.
+//
+// Syncing by GTID, the server resends the transaction which was being received,
+// or the last one received if the reconnect happened right after its commit, from
+// its GTID event. The events of this transaction are counted, so the ones
+// already delivered are skipped. Syncing by position, the events up to the last
+// position delivered are skipped.
+type eventDeduper struct {
+	// GTID of the last transaction delivered and its number of events delivered
+	gtid      string
+	delivered int
+
+	// pos is the end position of the last event delivered
+	pos mysql.Position
+
+	// replaying is set by a reconnect until an event not delivered yet is seen,
+	// replayed is the number of events of the transaction gtid seen again
+	replaying bool
+	replayed  int
+}
+
+// reconnected is called when the syncer reconnects.
+func (d *eventDeduper) reconnected() {
+	d.replaying = true
+	d.replayed = 0
+}
+
+// duplicate returns whether e was already delivered, pos is the position of the
+// binlog file of e and byPos whether the syncer syncs by position.
+func (d *eventDeduper) duplicate(e *BinlogEvent, pos mysql.Position, byPos bool) bool {
+	switch e.Header.EventType {
+	case FORMAT_DESCRIPTION_EVENT, PREVIOUS_GTIDS_EVENT, ROTATE_EVENT, HEARTBEAT_EVENT, HEARTBEAT_LOG_EVENT_V2,
+		MARIADB_GTID_LIST_EVENT, MARIADB_BINLOG_CHECKPOINT_EVENT:
+		// sent again on each connection, they don't belong to transactions
+		return false
+	}
+
+	gtid := eventGTID(e)
+	if d.replaying {
+		if d.replay(e, gtid, pos, byPos) {
+			return true
+		}
+		d.replaying = false
+	}
+
+	if gtid != "" {
+		d.gtid = gtid
+		d.delivered = 1
+	} else {
+		d.delivered++
+	}
+	if e.Header.LogPos > 0 {
+		d.pos = mysql.Position{Name: pos.Name, Pos: e.Header.LogPos}
+	}
+	return false
+}
+
+func (d *eventDeduper) replay(e *BinlogEvent, gtid string, pos mysql.Position, byPos bool) bool {
+	switch {
+	case gtid != "":
+		if gtid == d.gtid {
+			d.replayed = 1
+			return true
+		}
+		return false
+	case d.replayed > 0:
+		// in the transaction sent again, until the first event not delivered
+		if d.replayed < d.delivered {
+			d.replayed++
+			return true
+		}
+		return false
+	case byPos:
+		return e.Header.LogPos > 0 && pos.Name == d.pos.Name && e.Header.LogPos <= d.pos.Pos
+	}
+	return false
+}
+
+// eventGTID returns the GTID of e if it starts a transaction.
+func eventGTID(e *BinlogEvent) string {
+	switch event := e.Event.(type) {
+	case *GTIDEvent:
+		u, err := uuid.FromBytes(event.SID)
+		if err != nil {
+			return ""
+		}
+		return u.String() + ":" + strconv.FormatInt(event.GNO, 10)
+	case *MariadbGTIDEvent:
+		return event.GTID.String()
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package replication
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/pingcap/errors"
+)
+
+// Encode returns the EventHeaderSize bytes of the header.
+func (h *EventHeader) Encode() []byte {
+	data := make([]byte, 0, EventHeaderSize)
+	data = binary.LittleEndian.AppendUint32(data, h.Timestamp)
+	data = append(data, byte(h.EventType))
+	data = binary.LittleEndian.AppendUint32(data, h.ServerID)
+	data = binary.LittleEndian.AppendUint32(data, h.EventSize)
+	data = binary.LittleEndian.AppendUint32(data, h.LogPos)
+	return binary.LittleEndian.AppendUint16(data, h.Flags)
+}
+
+// Encode returns the body of the event, a MariaDB compressed event is not
+// supported.
+func (e *QueryEvent) Encode() ([]byte, error) {
+	if e.compressed {
+		return nil, errors.New("encoding a compressed query event is not supported")
+	}
+	if len(e.Schema) > 255 {
+		return nil, errors.Errorf("schema name %q is too long", e.Schema)
+	}
+
+	data := make([]byte, 0, 13+len(e.StatusVars)+len(e.Schema)+1+len(e.Query))
+	data = binary.LittleEndian.AppendUint32(data, e.SlaveProxyID)
+	data = binary.LittleEndian.AppendUint32(data, e.ExecutionTime)
+	data = append(data, byte(len(e.Schema)))
+	data = binary.LittleEndian.AppendUint16(data, e.ErrorCode)
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(e.StatusVars)))
+	data = append(data, e.StatusVars...)
+	data = append(data, e.Schema...)
+	data = append(data, 0)
+	return append(data, e.Query...), nil
+}
+
+// encodeWithSchema returns body, the body of the event, with the schema
+// replaced. The other fields are kept as they are in body.
+func (e *TableMapEvent) encodeWithSchema(body []byte, schema []byte) ([]byte, error) {
+	if len(schema) > 255 {
+		return nil, errors.Errorf("schema name %q is too long", schema)
+	}
+
+	pos := e.tableIDSize + 2
+	if len(body) <= pos || len(body) < pos+1+int(body[pos])+1 {
+		return nil, errors.Errorf("table map event too short %d", len(body))
+	}
+	rest := body[pos+1+int(body[pos])+1:]
+
+	data := make([]byte, 0, pos+1+len(schema)+1+len(rest))
+	data = append(data, body[:pos]...)
+	data = append(data, byte(len(schema)))
+	data = append(data, schema...)
+	data = append(data, 0)
+	return append(data, rest...), nil
+}
+
+// encodeEvent returns the raw data of an event with the header h and body, and
+// the CRC32 checksum if checksum is set. The event size of h is updated.
+func encodeEvent(h *EventHeader, body []byte, checksum bool) []byte {
+	size := EventHeaderSize + len(body)
+	if checksum {
+		size += BinlogChecksumLength
+	}
+	h.EventSize = uint32(size)
+
+	data := make([]byte, 0, size)
+	data = append(data, h.Encode()...)
+	data = append(data, body...)
+	if checksum {
+		data = binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+	}
+	return data
+}
+
+// eventBody returns the body of the raw data of an event, without the checksum.
+func eventBody(data []byte, checksum bool) []byte {
+	if checksum {
+		return data[EventHeaderSize : len(data)-BinlogChecksumLength]
+	}
+	return data[EventHeaderSize:]
+}
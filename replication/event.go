@@ -190,6 +190,11 @@ func (e *FormatDescriptionEvent) Decode(data []byte) error {
 		// here, the last 5 bytes is 1 byte check sum alg type and 4 byte checksum if exists
 		e.ChecksumAlgorithm = data[len(data)-5]
 		e.EventTypeHeaderLengths = data[pos : len(data)-5]
+		switch e.ChecksumAlgorithm {
+		case BINLOG_CHECKSUM_ALG_OFF, BINLOG_CHECKSUM_ALG_CRC32, BINLOG_CHECKSUM_ALG_UNDEF:
+		default:
+			return errors.Errorf("unsupported binlog checksum algorithm %d", e.ChecksumAlgorithm)
+		}
 	} else {
 		e.ChecksumAlgorithm = BINLOG_CHECKSUM_ALG_UNDEF
 		e.EventTypeHeaderLengths = data[pos:]
@@ -364,6 +369,9 @@ type QueryEvent struct {
 
 	// in fact QueryEvent dosen't have the GTIDSet information, just for beneficial to use
 	GSet mysql.GTIDSet
+
+	// Context is the context of the statement written before it, nil if none.
+	Context *StatementContext
 }
 
 func (e *QueryEvent) Decode(data []byte) error {
@@ -945,3 +953,70 @@ func (i *IntVarEvent) Dump(w io.Writer) {
 	fmt.Fprintf(w, "Type: %d\n", i.Type)
 	fmt.Fprintf(w, "Value: %d\n", i.Value)
 }
+
+// ViewChangeEvent is written by Group Replication when the membership of the
+// group changes, a member joins or leaves, before the transactions of the new
+// view. The view ID is like 17000000001234567:3, its counter increases with
+// each change, see ViewChangeCounter.
+type ViewChangeEvent struct {
+	ViewID string
+	// SeqNumber is the sequence number of the certification of the view change
+	SeqNumber uint64
+	// CertificationInfo is the certification database sent to the joining
+	// members, the GTID sets of the write sets.
+	CertificationInfo map[string][]byte
+}
+
+// viewIDLength is the length of the null padded view ID.
+const viewIDLength = 40
+
+func (e *ViewChangeEvent) Decode(data []byte) error {
+	if len(data) < viewIDLength+12 {
+		return errors.Errorf("view change event too short %d", len(data))
+	}
+	e.ViewID = string(bytes.TrimRight(data[:viewIDLength], "\x00"))
+	pos := viewIDLength
+	e.SeqNumber = binary.LittleEndian.Uint64(data[pos:])
+	pos += 8
+	count := binary.LittleEndian.Uint32(data[pos:])
+	pos += 4
+
+	e.CertificationInfo = make(map[string][]byte, min(int(count), len(data)/6))
+	for i := uint32(0); i < count; i++ {
+		if len(data) < pos+2 {
+			return errors.Errorf("view change event key %d too short", i)
+		}
+		n := int(binary.LittleEndian.Uint16(data[pos:]))
+		pos += 2
+		if len(data) < pos+n+4 {
+			return errors.Errorf("view change event key %d too short", i)
+		}
+		key := string(data[pos : pos+n])
+		pos += n
+		m := int(binary.LittleEndian.Uint32(data[pos:]))
+		pos += 4
+		if len(data) < pos+m {
+			return errors.Errorf("view change event value %d too short", i)
+		}
+		e.CertificationInfo[key] = data[pos : pos+m]
+		pos += m
+	}
+	return nil
+}
+
+// ViewChangeCounter returns the counter of the view ID, that increases with
+// each membership change of the group.
+func (e *ViewChangeEvent) ViewChangeCounter() (uint64, error) {
+	i := strings.LastIndexByte(e.ViewID, ':')
+	if i < 0 {
+		return 0, errors.Errorf("invalid view ID %q", e.ViewID)
+	}
+	return strconv.ParseUint(e.ViewID[i+1:], 10, 64)
+}
+
+func (e *ViewChangeEvent) Dump(w io.Writer) {
+	fmt.Fprintf(w, "View ID: %s\n", e.ViewID)
+	fmt.Fprintf(w, "Seq Number: %d\n", e.SeqNumber)
+	fmt.Fprintf(w, "Certification Info: %d entries\n", len(e.CertificationInfo))
+	fmt.Fprintln(w)
+}
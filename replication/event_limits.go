@@ -0,0 +1,109 @@
+package replication
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// EventTooLargeError is the error of an event larger than
+// BinlogSyncerConfig.MaxEventSize.
+type EventTooLargeError struct {
+	Header *EventHeader
+	// Position is the binlog file and the end position of the event.
+	Position mysql.Position
+	// Size is the size of the event, Limit the MaxEventSize.
+	Size  int
+	Limit int
+}
+
+func (e *EventTooLargeError) Error() string {
+	return fmt.Sprintf("%s of %d bytes at %s exceeds the max event size %d",
+		e.Header.EventType, e.Size, e.Position, e.Limit)
+}
+
+// TruncatedEvent replaces an event larger than BinlogSyncerConfig.MaxEventSize
+// if TruncateOversizedEvents is set. The event is not decoded, Data is the start
+// of its body, at most MaxEventSize bytes with the header.
+type TruncatedEvent struct {
+	Err  *EventTooLargeError
+	Data []byte
+}
+
+func (e *TruncatedEvent) Decode(data []byte) error {
+	e.Data = data
+	return nil
+}
+
+func (e *TruncatedEvent) Dump(w io.Writer) {
+	fmt.Fprintf(w, "Truncated: %s\n", e.Err)
+	fmt.Fprintf(w, "Kept: %d bytes\n", len(e.Data))
+	fmt.Fprintln(w)
+}
+
+// semiSyncPacketPrefix is the OK byte and the semi-sync header before an event
+const semiSyncPacketPrefix = 3
+
+// readPacket reads the next packet, only the start of an event larger than
+// MaxEventSize is kept. size is the full size of the packet.
+func (b *BinlogSyncer) readPacket() (data []byte, size int, err error) {
+	if b.cfg.MaxEventSize <= 0 {
+		data, err = b.c.ReadPacket()
+		return data, len(data), err
+	}
+	return b.c.ReadPacketTruncated(semiSyncPacketPrefix + b.cfg.MaxEventSize)
+}
+
+// eventPrefixSize returns the size of the OK byte and the semi-sync header of the
+// event packet data.
+func (b *BinlogSyncer) eventPrefixSize(data []byte) int {
+	if b.cfg.SemiSyncEnabled && len(data) > 1 && data[1] == SemiSyncIndicator {
+		return semiSyncPacketPrefix
+	}
+	return 1
+}
+
+// oversized returns whether the event of the packet data of size bytes is larger
+// than MaxEventSize.
+func (b *BinlogSyncer) oversized(data []byte, size int) bool {
+	return b.cfg.MaxEventSize > 0 && size-b.eventPrefixSize(data) > b.cfg.MaxEventSize
+}
+
+// parseOversizedEvent returns the EventTooLargeError of the event, or the event
+// as a TruncatedEvent if TruncateOversizedEvents is set.
+func (b *BinlogSyncer) parseOversizedEvent(data []byte, size int) (event *BinlogEvent, needACK bool, err error) {
+	prefix := b.eventPrefixSize(data)
+	if prefix == semiSyncPacketPrefix {
+		needACK = data[2] == 0x01
+	}
+	data = data[prefix:min(len(data), prefix+b.cfg.MaxEventSize)]
+
+	h := new(EventHeader)
+	if err = h.Decode(data); err != nil {
+		return nil, false, errors.Trace(err)
+	}
+
+	pos := b.nextPos
+	if h.LogPos > 0 {
+		pos.Pos = h.LogPos
+	}
+	tooLarge := &EventTooLargeError{
+		Header:   h,
+		Position: pos,
+		Size:     size - prefix,
+		Limit:    b.cfg.MaxEventSize,
+	}
+	if !b.cfg.TruncateOversizedEvents {
+		return nil, false, tooLarge
+	}
+
+	b.cfg.Logger.Warn(tooLarge.Error())
+	return &BinlogEvent{
+		RawData: data,
+		Header:  h,
+		Event:   &TruncatedEvent{Err: tooLarge, Data: data[EventHeaderSize:]},
+	}, needACK, nil
+}
@@ -0,0 +1,133 @@
+package replication
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/packet"
+)
+
+func TestOversizedEvent(t *testing.T) {
+	event := make([]byte, EventHeaderSize+100)
+	event[4] = byte(WRITE_ROWS_EVENTv2)
+	binary.LittleEndian.PutUint32(event[9:], uint32(len(event)))
+	binary.LittleEndian.PutUint32(event[13:], 1000)
+
+	server, conn := net.Pipe()
+	defer server.Close()
+	go func() {
+		pc := packet.NewConn(server)
+		_ = pc.WritePacket(append(make([]byte, 4+1), event...))
+	}()
+
+	data, size, err := packet.NewConn(conn).ReadPacketTruncated(semiSyncPacketPrefix + 32)
+	require.NoError(t, err)
+	require.Equal(t, 1+len(event), size)
+	require.Len(t, data, semiSyncPacketPrefix+32)
+
+	b := &BinlogSyncer{
+		cfg:     BinlogSyncerConfig{MaxEventSize: 32, Logger: slog.Default()},
+		nextPos: mysql.Position{Name: "mysql-bin.000001", Pos: 4},
+	}
+	require.True(t, b.oversized(data, size))
+	require.False(t, b.oversized(data[:33], 33))
+
+	_, _, err = b.parseOversizedEvent(data, size)
+	var tooLarge *EventTooLargeError
+	require.True(t, errors.As(err, &tooLarge))
+	require.Equal(t, len(event), tooLarge.Size)
+	require.Equal(t, 32, tooLarge.Limit)
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000001", Pos: 1000}, tooLarge.Position)
+
+	b.cfg.TruncateOversizedEvents = true
+	e, needACK, err := b.parseOversizedEvent(data, size)
+	require.NoError(t, err)
+	require.False(t, needACK)
+	require.Equal(t, WRITE_ROWS_EVENTv2, e.Header.EventType)
+	require.Len(t, e.RawData, 32)
+	truncated, ok := e.Event.(*TruncatedEvent)
+	require.True(t, ok)
+	require.Len(t, truncated.Data, 32-EventHeaderSize)
+}
+
+func TestStreamerBufferedBytes(t *testing.T) {
+	s := NewBinlogStreamerWithChanSize(10)
+	s.maxBufferedBytes = 100
+	ev := func(n int) *BinlogEvent {
+		return &BinlogEvent{RawData: make([]byte, n), Header: &EventHeader{}}
+	}
+	ctx := context.Background()
+
+	// an event larger than the limit goes through an empty streamer
+	require.True(t, s.reserve(ctx, ev(150)))
+	s.ch <- ev(150)
+	require.EqualValues(t, 150, s.BufferedBytes())
+
+	reserved := make(chan bool)
+	go func() {
+		reserved <- s.reserve(ctx, ev(60))
+	}()
+	select {
+	case <-reserved:
+		t.Fatal("reserve must wait for the buffered events to be read")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	_, err := s.GetEvent(ctx)
+	require.NoError(t, err)
+	require.True(t, <-reserved)
+	require.EqualValues(t, 60, s.BufferedBytes())
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	require.False(t, s.reserve(ctx, ev(60)))
+}
+
+func TestStreamerSyntheticHeartbeat(t *testing.T) {
+	s := NewBinlogStreamerWithChanSize(10)
+	s.SetSyntheticHeartbeatPeriod(50 * time.Millisecond)
+	ctx := context.Background()
+
+	require.NoError(t, s.AddEventToStreamer(&BinlogEvent{
+		Header: &EventHeader{EventType: ROTATE_EVENT},
+		Event:  &RotateEvent{Position: 4, NextLogName: []byte("mysql-bin.000002")},
+	}))
+	require.NoError(t, s.AddEventToStreamer(&BinlogEvent{
+		Header: &EventHeader{EventType: XID_EVENT, Timestamp: 1700000000, LogPos: 120},
+		Event:  &XIDEvent{},
+	}))
+	for i := 0; i < 2; i++ {
+		e, err := s.GetEvent(ctx)
+		require.NoError(t, err)
+		require.NotEqual(t, HEARTBEAT_EVENT, e.Header.EventType)
+	}
+
+	start := time.Now()
+	e, err := s.GetEvent(ctx)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	require.Equal(t, HEARTBEAT_EVENT, e.Header.EventType)
+	require.Equal(t, LOG_EVENT_ARTIFICIAL_F, e.Header.Flags)
+	require.EqualValues(t, 120, e.Header.LogPos)
+	require.Equal(t, &SyntheticHeartbeatEvent{
+		Position:      mysql.Position{Name: "mysql-bin.000002", Pos: 120},
+		LastEventTime: time.Unix(1700000000, 0),
+	}, e.Event)
+
+	// a real event arriving first is returned
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = s.AddEventToStreamer(&BinlogEvent{Header: &EventHeader{EventType: XID_EVENT, LogPos: 200}, Event: &XIDEvent{}})
+	}()
+	e, err = s.GetEvent(ctx)
+	require.NoError(t, err)
+	require.Equal(t, XID_EVENT, e.Header.EventType)
+}
@@ -1,8 +1,11 @@
 package replication
 
 import (
+	"encoding/binary"
 	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -123,6 +126,55 @@ func TestGTIDEventMysql8NewFields(t *testing.T) {
 	}
 }
 
+func TestFormatDescriptionEventChecksumAlgorithm(t *testing.T) {
+	data := []byte{4, 0}
+	data = append(data, make([]byte, 50)...)
+	copy(data[2:], "5.7.22-log")
+	data = append(data, 0, 0, 0, 0, byte(EventHeaderSize), 0x38, 0x0d, 0x00, 0x08)
+	data = append(data, BINLOG_CHECKSUM_ALG_CRC32, 0, 0, 0, 0)
+
+	ev := FormatDescriptionEvent{}
+	require.NoError(t, ev.Decode(data))
+	require.Equal(t, BINLOG_CHECKSUM_ALG_CRC32, ev.ChecksumAlgorithm)
+	require.Equal(t, []byte{0x38, 0x0d, 0x00, 0x08}, ev.EventTypeHeaderLengths)
+
+	data[len(data)-5] = 2
+	require.ErrorContains(t, ev.Decode(data), "unsupported binlog checksum algorithm 2")
+
+	for name, alg := range map[string]byte{"": BINLOG_CHECKSUM_ALG_UNDEF, "NONE": BINLOG_CHECKSUM_ALG_OFF, "crc32": BINLOG_CHECKSUM_ALG_CRC32} {
+		v, err := checksumAlgorithmByName(name)
+		require.NoError(t, err)
+		require.Equal(t, alg, v)
+	}
+	_, err := checksumAlgorithmByName("XXHASH")
+	require.Error(t, err)
+}
+
+func TestCommitTime(t *testing.T) {
+	header := &EventHeader{Timestamp: 1700000000}
+
+	// Q_FLAGS2_CODE, Q_SQL_MODE_CODE, Q_CATALOG_NZ_CODE "std", Q_UPDATED_DB_NAMES "a" "b", Q_HRNOW 123456
+	vars := []byte{Q_FLAGS2_CODE, 0, 0, 0, 0, Q_SQL_MODE_CODE, 0, 0, 0, 0, 0, 0, 0, 0, Q_CATALOG_NZ_CODE, 3, 's', 't', 'd'}
+	vars = append(vars, Q_UPDATED_DB_NAMES, 2, 'a', 0, 'b', 0, Q_HRNOW, 0x40, 0xe2, 0x01)
+	query := &QueryEvent{StatusVars: vars}
+	usec, ok := query.Microseconds()
+	require.True(t, ok)
+	require.Equal(t, uint32(123456), usec)
+	e := &BinlogEvent{Header: header, Event: query}
+	require.Equal(t, time.Unix(1700000000, 123456000), e.CommitTime())
+
+	// without
+	query.StatusVars = vars[:19]
+	_, ok = query.Microseconds()
+	require.False(t, ok)
+	require.Equal(t, time.Unix(1700000000, 0), e.CommitTime())
+
+	e.Event = &GTIDEvent{ImmediateCommitTimestamp: 1700000001000002}
+	require.Equal(t, time.Unix(1700000001, 2000), e.CommitTime())
+	e.Event = &MariadbGTIDEvent{}
+	require.Equal(t, time.Unix(1700000000, 0), e.CommitTime())
+}
+
 func TestIntVarEvent(t *testing.T) {
 	// IntVarEvent Type LastInsertID, Value 13
 	data := []byte{1, 13, 0, 0, 0, 0, 0, 0, 0}
@@ -141,6 +193,53 @@ func TestIntVarEvent(t *testing.T) {
 	require.Equal(t, uint64(23), ev.Value)
 }
 
+func TestRandEvent(t *testing.T) {
+	data := []byte{1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0}
+	ev := RandEvent{}
+	require.NoError(t, ev.Decode(data))
+	require.Equal(t, uint64(1), ev.Seed1)
+	require.Equal(t, uint64(2), ev.Seed2)
+
+	require.Error(t, ev.Decode(data[:8]))
+}
+
+// userVarEventData returns the data of a USER_VAR_EVENT of the variable a.
+func userVarEventData(tp UserVarType, charset uint32, value []byte, flags byte) []byte {
+	data := []byte{1, 0, 0, 0, 'a', 0, byte(tp)}
+	data = binary.LittleEndian.AppendUint32(data, charset)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(value)))
+	data = append(data, value...)
+	return append(data, flags)
+}
+
+func TestUserVarEvent(t *testing.T) {
+	testcases := []struct {
+		data     []byte
+		expected interface{}
+	}{
+		{userVarEventData(USER_VAR_STRING, 33, []byte("abc"), 0), "abc"},
+		{userVarEventData(USER_VAR_REAL, 63, binary.LittleEndian.AppendUint64(nil, 0x3ff8000000000000), 0), 1.5},
+		{userVarEventData(USER_VAR_INT, 63, binary.LittleEndian.AppendUint64(nil, math.MaxUint64), 0), int64(-1)},
+		{userVarEventData(USER_VAR_INT, 63, binary.LittleEndian.AppendUint64(nil, math.MaxUint64), 1), uint64(math.MaxUint64)},
+		// 1.50, precision 3, scale 2
+		{userVarEventData(USER_VAR_DECIMAL, 63, []byte{3, 2, 0x81, 0x32}, 0), "1.50"},
+	}
+	for i, tc := range testcases {
+		ev := UserVarEvent{}
+		require.NoError(t, ev.Decode(tc.data), "testcase: %d", i)
+		require.Equal(t, "a", string(ev.Name))
+		require.False(t, ev.IsNull)
+		require.Equal(t, tc.expected, ev.Value, "testcase: %d", i)
+	}
+
+	ev := UserVarEvent{}
+	require.NoError(t, ev.Decode([]byte{1, 0, 0, 0, 'a', 1}))
+	require.True(t, ev.IsNull)
+	require.Nil(t, ev.Value)
+
+	require.Error(t, ev.Decode([]byte{9, 0, 0, 0, 'a', 1}))
+}
+
 func TestDecodeSid(t *testing.T) {
 	testcases := []struct {
 		input      []byte
@@ -194,3 +293,77 @@ func TestPreviousGTIDEvent(t *testing.T) {
 		require.Equal(t, tc.GTIDSets, e.GTIDSets)
 	}
 }
+
+func TestViewChangeEvent(t *testing.T) {
+	data := append([]byte("17000000001234567:3"), make([]byte, 40-19)...)
+	data = binary.LittleEndian.AppendUint64(data, 7)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = binary.LittleEndian.AppendUint16(data, 13)
+	data = append(data, "gtid_executed"...)
+	data = binary.LittleEndian.AppendUint32(data, 3)
+	data = append(data, "a:1"...)
+
+	e := ViewChangeEvent{}
+	require.NoError(t, e.Decode(data))
+	require.Equal(t, "17000000001234567:3", e.ViewID)
+	require.Equal(t, uint64(7), e.SeqNumber)
+	require.Equal(t, map[string][]byte{"gtid_executed": []byte("a:1")}, e.CertificationInfo)
+	counter, err := e.ViewChangeCounter()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), counter)
+
+	require.Error(t, e.Decode(data[:len(data)-1]))
+	require.Error(t, e.Decode(data[:30]))
+}
+
+func TestQueryStatusVars(t *testing.T) {
+	vars := []byte{Q_FLAGS2_CODE, 0, 0, 0, 0}
+	// STRICT_TRANS_TABLES, NO_ENGINE_SUBSTITUTION
+	vars = append(vars, Q_SQL_MODE_CODE, 0, 0, 0x20, 0x40, 0, 0, 0, 0)
+	vars = append(vars, Q_CATALOG_NZ_CODE, 3, 's', 't', 'd')
+	vars = append(vars, Q_AUTO_INCREMENT, 2, 0, 1, 0)
+	// utf8mb4_general_ci, utf8mb4_general_ci, latin1_swedish_ci
+	vars = append(vars, Q_CHARSET_CODE, 45, 0, 45, 0, 8, 0)
+	vars = append(vars, Q_TIME_ZONE_CODE, 6, '+', '0', '8', ':', '3', '0')
+	vars = append(vars, Q_INVOKER, 4, 'r', 'o', 'o', 't', 9, 'l', 'o', 'c', 'a', 'l', 'h', 'o', 's', 't')
+	vars = append(vars, Q_UPDATED_DB_NAMES, 2, 'a', 0, 'b', 0, Q_MICROSECONDS, 0x40, 0xe2, 0x01)
+	query := &QueryEvent{StatusVars: vars}
+
+	v, err := query.ParseStatusVars()
+	require.NoError(t, err)
+	require.True(t, v.Has(Q_SQL_MODE_CODE))
+	require.False(t, v.Has(Q_LC_TIME_NAMES_CODE))
+	require.Equal(t, []string{"STRICT_TRANS_TABLES", "NO_ENGINE_SUBSTITUTION"}, v.SQLModeNames())
+	require.Equal(t, "std", v.Catalog)
+	require.Equal(t, uint16(2), v.AutoIncrementIncrement)
+	require.Equal(t, uint16(1), v.AutoIncrementOffset)
+	require.Equal(t, "utf8mb4", v.ClientCharset())
+	require.Equal(t, uint16(8), v.CollationServer)
+	require.Equal(t, "+08:30", v.TimeZone)
+	loc, err := v.Location()
+	require.NoError(t, err)
+	_, offset := time.Date(2024, 1, 1, 0, 0, 0, 0, loc).Zone()
+	require.Equal(t, 8*3600+30*60, offset)
+	require.Equal(t, "root", v.InvokerUser)
+	require.Equal(t, "localhost", v.InvokerHost)
+	require.Equal(t, []string{"a", "b"}, v.UpdatedDBNames)
+	require.Equal(t, uint32(123456), v.Microseconds)
+
+	// the variables before an unknown one
+	query.StatusVars = append(vars[:5:5], Q_COMMIT_TS, 1, 2, 3)
+	v, err = query.ParseStatusVars()
+	require.EqualError(t, err, "unknown status variable 14")
+	require.True(t, v.Has(Q_FLAGS2_CODE))
+
+	v = &QueryStatusVars{TimeZone: "SYSTEM"}
+	loc, err = v.Location()
+	require.NoError(t, err)
+	require.Nil(t, loc)
+	v.TimeZone = "UTC"
+	loc, err = v.Location()
+	require.NoError(t, err)
+	require.Equal(t, time.UTC, loc)
+	v.TimeZone = "+8"
+	_, err = v.Location()
+	require.Error(t, err)
+}
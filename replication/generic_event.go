@@ -136,26 +136,6 @@ func (e *GenericEvent) Decode(data []byte) error {
 // 	FileID uint32
 // }
 
-// type RandEvent struct {
-// 	Seed1 uint64
-// 	Seed2 uint64
-// }
-
-// type UserVarEvent struct {
-// 	NameLength uint32
-// 	Name       []byte
-// 	IsNull     uint8
-
-// 	//if not is null
-// 	Type        uint8
-// 	Charset     uint32
-// 	ValueLength uint32
-// 	Value       []byte
-
-// 	//if more data
-// 	Flags uint8
-// }
-
 // type IncidentEvent struct {
 // 	Type          uint16
 // 	MessageLength uint8
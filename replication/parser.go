@@ -1,6 +1,7 @@
 package replication
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
 	"fmt"
@@ -37,14 +38,32 @@ type BinlogParser struct {
 
 	useDecimal               bool
 	useFloatWithTrailingZero bool
+	useFloat32Vector         bool
 	ignoreJSONDecodeErr      bool
 	verifyChecksum           bool
+	convertCharset           bool
 
 	rowsEventDecodeFunc func(*RowsEvent, []byte) error
+	// deferRowsDecodeFunc decodes the rows events of the binlog, not the ones
+	// of a TRANSACTION_PAYLOAD_EVENT, when they are decoded concurrently
+	deferRowsDecodeFunc func(*RowsEvent, []byte) error
 
 	tableMapOptionalMetaDecodeFunc func([]byte) error
+
+	tableColumnNamesFunc func(schema, table string) ([]string, error)
+
+	attachRowsQuery bool
+	// query of the last rows query event, attached to the following rows events
+	rowsQuery []byte
+	// context of the next query event
+	stmtContext *StatementContext
+
+	eventDecoders map[EventType]EventDecoder
 }
 
+// EventDecoder decodes the data of an event, without the header and checksum.
+type EventDecoder func(h *EventHeader, data []byte) (Event, error)
+
 func NewBinlogParser() *BinlogParser {
 	p := new(BinlogParser)
 
@@ -74,8 +93,23 @@ func (p *BinlogParser) ParseFile(name string, offset int64, onEvent OnEventFunc)
 	}
 	defer f.Close()
 
+	st, err := f.Stat()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return p.parseReaderAt(name, f, st.Size(), offset, onEvent)
+}
+
+// ParseReaderAt parses the binlog file of size bytes read from r from offset,
+// like ParseFile, e.g. a file of an object storage read by ranges, see
+// BinlogStorage. The reads of r are buffered.
+func (p *BinlogParser) ParseReaderAt(r io.ReaderAt, size int64, offset int64, onEvent OnEventFunc) error {
+	return p.parseReaderAt("binlog", r, size, offset, onEvent)
+}
+
+func (p *BinlogParser) parseReaderAt(name string, r io.ReaderAt, size int64, offset int64, onEvent OnEventFunc) error {
 	b := make([]byte, 4)
-	if _, err = f.Read(b); err != nil {
+	if _, err := r.ReadAt(b, 0); err != nil {
 		return errors.Trace(err)
 	} else if !bytes.Equal(b, BinLogFileHeader) {
 		return errors.Errorf("%s is not a valid binlog file, head 4 bytes must fe'bin' ", name)
@@ -85,20 +119,24 @@ func (p *BinlogParser) ParseFile(name string, offset int64, onEvent OnEventFunc)
 		offset = 4
 	} else if offset > 4 {
 		//  FORMAT_DESCRIPTION event should be read by default always (despite that fact passed offset may be higher than 4)
-		if _, err = f.Seek(4, io.SeekStart); err != nil {
-			return errors.Errorf("seek %s to %d error %v", name, offset, err)
-		}
-
-		if err = p.parseFormatDescriptionEvent(f, onEvent); err != nil {
+		if err := p.parseFormatDescriptionEvent(io.NewSectionReader(r, 4, size-4), onEvent); err != nil {
 			return errors.Annotatef(err, "parse FormatDescriptionEvent")
 		}
 	}
-
-	if _, err = f.Seek(offset, io.SeekStart); err != nil {
-		return errors.Errorf("seek %s to %d error %v", name, offset, err)
+	if offset > size {
+		return errors.Errorf("seek %s to %d error, the file has %d bytes", name, offset, size)
 	}
 
-	return p.ParseReader(f, onEvent)
+	return p.ParseReader(bufio.NewReaderSize(io.NewSectionReader(r, offset, size-offset), binlogReadSize), onEvent)
+}
+
+// ParseDir parses the binlog files of dir from the file fromFile at the offset
+// fromPos, see ParseFile, or from the first file if fromFile is empty. A file is
+// followed by the file of its rotate event, or by the next file of the same
+// base name if it has none, like after a crash. It returns once the last file
+// is parsed, or the next file is missing.
+func (p *BinlogParser) ParseDir(dir string, fromFile string, fromPos int64, onEvent OnEventFunc) error {
+	return p.ParseStorage(DirStorage(dir), fromFile, fromPos, onEvent)
 }
 
 func (p *BinlogParser) parseFormatDescriptionEvent(r io.Reader, onEvent OnEventFunc) error {
@@ -203,6 +241,18 @@ func (p *BinlogParser) SetUseFloatWithTrailingZero(useFloatWithTrailingZero bool
 	p.useFloatWithTrailingZero = useFloatWithTrailingZero
 }
 
+func (p *BinlogParser) SetUseFloat32Vector(useFloat32Vector bool) {
+	p.useFloat32Vector = useFloat32Vector
+}
+
+// SetConvertCharset converts the values of the character columns of the rows
+// events in other character sets than UTF-8 to UTF-8, by the collations of the
+// TableMapEvent, see mysql.ToUTF8. The collations are only logged with
+// binlog_row_metadata=FULL, the values are left as is without them.
+func (p *BinlogParser) SetConvertCharset(convert bool) {
+	p.convertCharset = convert
+}
+
 func (p *BinlogParser) SetIgnoreJSONDecodeError(ignoreJSONDecodeErr bool) {
 	p.ignoreJSONDecodeErr = ignoreJSONDecodeErr
 }
@@ -223,6 +273,34 @@ func (p *BinlogParser) SetTableMapOptionalMetaDecodeFunc(tableMapOptionalMetaDec
 	p.tableMapOptionalMetaDecodeFunc = tableMapOptionalMetaDecondeFunc
 }
 
+// SetTableColumnNamesFunc sets the function used by RowsEvent.ColumnNames to look up
+// the full column list of a table when the TableMapEvent carries no column names.
+func (p *BinlogParser) SetTableColumnNamesFunc(tableColumnNamesFunc func(schema, table string) ([]string, error)) {
+	p.tableColumnNamesFunc = tableColumnNamesFunc
+}
+
+// SetAttachRowsQuery makes the parser set RowsEvent.Query to the statement of
+// the preceding ROWS_QUERY_EVENT or ANNOTATE_ROWS_EVENT.
+func (p *BinlogParser) SetAttachRowsQuery(attach bool) {
+	p.attachRowsQuery = attach
+}
+
+// RegisterEventDecoder registers the decoder of the events of type t, e.g. for
+// vendor specific events which are decoded to GenericEvent otherwise. It overrides
+// the built-in decoder of t, except for FORMAT_DESCRIPTION_EVENT and ROTATE_EVENT
+// which the parser needs. A nil decoder removes the decoder of t. Decoders are not
+// used in raw mode.
+func (p *BinlogParser) RegisterEventDecoder(t EventType, decoder EventDecoder) {
+	if decoder == nil {
+		delete(p.eventDecoders, t)
+		return
+	}
+	if p.eventDecoders == nil {
+		p.eventDecoders = make(map[EventType]EventDecoder)
+	}
+	p.eventDecoders[t] = decoder
+}
+
 func (p *BinlogParser) parseHeader(data []byte) (*EventHeader, error) {
 	h := new(EventHeader)
 	err := h.Decode(data)
@@ -235,6 +313,8 @@ func (p *BinlogParser) parseHeader(data []byte) (*EventHeader, error) {
 
 func (p *BinlogParser) parseEvent(h *EventHeader, data []byte, rawData []byte) (Event, error) {
 	var e Event
+	// decoded by a decoder of RegisterEventDecoder
+	var decoded bool
 
 	if h.EventType == FORMAT_DESCRIPTION_EVENT {
 		p.format = &FormatDescriptionEvent{}
@@ -250,6 +330,12 @@ func (p *BinlogParser) parseEvent(h *EventHeader, data []byte, rawData []byte) (
 
 		if h.EventType == ROTATE_EVENT {
 			e = &RotateEvent{}
+		} else if decoder, ok := p.eventDecoders[h.EventType]; ok && !p.rawMode {
+			de, err := decoder(h, data)
+			if err != nil {
+				return nil, &EventError{h, err.Error(), data}
+			}
+			e, decoded = de, true
 		} else if !p.rawMode {
 			switch h.EventType {
 			case QUERY_EVENT:
@@ -312,8 +398,21 @@ func (p *BinlogParser) parseEvent(h *EventHeader, data []byte, rawData []byte) (
 				e = &PreviousGTIDsEvent{}
 			case INTVAR_EVENT:
 				e = &IntVarEvent{}
+			case RAND_EVENT:
+				e = &RandEvent{}
+			case USER_VAR_EVENT:
+				e = &UserVarEvent{useDecimal: p.useDecimal}
+			case VIEW_CHANGE_EVENT:
+				e = &ViewChangeEvent{}
 			case TRANSACTION_PAYLOAD_EVENT:
-				e = p.newTransactionPayloadEvent()
+				if isRelayCompressed(h) {
+					// a rows event compressed by a RelayLog, ReplayRelayLog
+					// parses it once decompressed, with the table maps parsed
+					// before
+					e = &GenericEvent{}
+				} else {
+					e = p.newTransactionPayloadEvent()
+				}
 			default:
 				e = &GenericEvent{}
 			}
@@ -322,20 +421,31 @@ func (p *BinlogParser) parseEvent(h *EventHeader, data []byte, rawData []byte) (
 		}
 	}
 
-	var err error
-	if re, ok := e.(*RowsEvent); ok && p.rowsEventDecodeFunc != nil {
-		err = p.rowsEventDecodeFunc(re, data)
-	} else {
-		err = e.Decode(data)
-	}
-	if err != nil {
-		return nil, &EventError{h, err.Error(), data}
+	if !decoded {
+		var err error
+		re, ok := e.(*RowsEvent)
+		switch {
+		case ok && p.deferRowsDecodeFunc != nil:
+			err = p.deferRowsDecodeFunc(re, data)
+		case ok && p.rowsEventDecodeFunc != nil:
+			err = p.rowsEventDecodeFunc(re, data)
+		default:
+			err = e.Decode(data)
+		}
+		if err != nil {
+			return nil, &EventError{h, err.Error(), data}
+		}
 	}
 
 	if te, ok := e.(*TableMapEvent); ok {
 		p.tables[te.TableID] = te
 	}
 
+	if p.attachRowsQuery {
+		p.trackRowsQuery(e)
+	}
+	p.trackStatementContext(e)
+
 	if re, ok := e.(*RowsEvent); ok {
 		if (re.Flags & RowsEventStmtEndFlag) > 0 {
 			// Refer https://github.com/alibaba/canal/blob/38cc81b7dab29b51371096fb6763ca3a8432ffee/dbsync/src/main/java/com/taobao/tddl/dbsync/binlog/event/RowsLogEvent.java#L176
@@ -375,6 +485,21 @@ func (p *BinlogParser) Parse(data []byte) (*BinlogEvent, error) {
 	return &BinlogEvent{RawData: rawData, Header: h, Event: e}, nil
 }
 
+func (p *BinlogParser) trackRowsQuery(e Event) {
+	switch ev := e.(type) {
+	case *RowsQueryEvent:
+		// copy, the event data may be reused after parsing
+		p.rowsQuery = append([]byte(nil), ev.Query...)
+	case *MariadbAnnotateRowsEvent:
+		p.rowsQuery = append([]byte(nil), ev.Query...)
+	case *RowsEvent:
+		ev.Query = p.rowsQuery
+	case *QueryEvent, *XIDEvent, *GTIDEvent, *MariadbGTIDEvent:
+		// a new statement or transaction, the query doesn't apply anymore
+		p.rowsQuery = nil
+	}
+}
+
 func (p *BinlogParser) verifyCrc32Checksum(rawData []byte) error {
 	if !p.verifyChecksum {
 		return nil
@@ -412,7 +537,10 @@ func (p *BinlogParser) newRowsEvent(h *EventHeader) *RowsEvent {
 	e.timestampStringLocation = p.timestampStringLocation
 	e.useDecimal = p.useDecimal
 	e.useFloatWithTrailingZero = p.useFloatWithTrailingZero
+	e.useFloat32Vector = p.useFloat32Vector
 	e.ignoreJSONDecodeErr = p.ignoreJSONDecodeErr
+	e.convertCharset = p.convertCharset
+	e.tableColumnNamesFunc = p.tableColumnNamesFunc
 
 	switch h.EventType {
 	case WRITE_ROWS_EVENTv0:
@@ -457,5 +585,15 @@ func (p *BinlogParser) newTransactionPayloadEvent() *TransactionPayloadEvent {
 	e := &TransactionPayloadEvent{}
 	e.format = *p.format
 
+	// the settings of p, with its own state
+	parser := *p
+	parser.tables = make(map[uint64]*TableMapEvent)
+	parser.stopProcessing = 0
+	parser.rowsQuery = nil
+	parser.stmtContext = nil
+	// the rows events of the payload are handed back decoded with it
+	parser.deferRowsDecodeFunc = nil
+	e.parser = &parser
+
 	return e
 }
@@ -2,6 +2,13 @@ package replication
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -140,3 +147,242 @@ func TestRowsEventDecodeImageWithEmptyJSON(t *testing.T) {
 	require.Equal(t, []byte{}, row[4]) // empty json
 	require.Equal(t, int32(4404), row[7])
 }
+
+func TestParserAttachRowsQuery(t *testing.T) {
+	p := NewBinlogParser()
+	p.SetAttachRowsQuery(true)
+
+	query := []byte("INSERT INTO t VALUES (1), (2)")
+	p.trackRowsQuery(&RowsQueryEvent{Query: query})
+	query[0] = 'X'
+
+	for i := 0; i < 2; i++ {
+		re := &RowsEvent{}
+		p.trackRowsQuery(re)
+		require.Equal(t, "INSERT INTO t VALUES (1), (2)", string(re.Query))
+	}
+
+	p.trackRowsQuery(&XIDEvent{})
+	re := &RowsEvent{}
+	p.trackRowsQuery(re)
+	require.Nil(t, re.Query)
+
+	p.trackRowsQuery(&MariadbAnnotateRowsEvent{Query: []byte("DELETE FROM t")})
+	p.trackRowsQuery(re)
+	require.Equal(t, "DELETE FROM t", string(re.Query))
+}
+
+func TestParserStatementContext(t *testing.T) {
+	p := NewBinlogParser()
+
+	q := &QueryEvent{}
+	p.trackStatementContext(q)
+	require.Nil(t, q.Context)
+
+	name := []byte("a")
+	p.trackStatementContext(&IntVarEvent{Type: INSERT_ID, Value: 5})
+	p.trackStatementContext(&RandEvent{Seed1: 1, Seed2: 2})
+	p.trackStatementContext(&UserVarEvent{Name: name, Value: "x"})
+	name[0] = 'b'
+	p.trackStatementContext(q)
+	require.NotNil(t, q.Context)
+	id, ok := q.Context.InsertID()
+	require.True(t, ok)
+	require.Equal(t, uint64(5), id)
+	_, ok = q.Context.LastInsertID()
+	require.False(t, ok)
+	require.Equal(t, &RandEvent{Seed1: 1, Seed2: 2}, q.Context.Rand)
+	require.Equal(t, "x", q.Context.UserVar("a").Value)
+	require.Nil(t, q.Context.UserVar("b"))
+
+	// the context applies to the next query only
+	q = &QueryEvent{}
+	p.trackStatementContext(q)
+	require.Nil(t, q.Context)
+
+	p.trackStatementContext(&IntVarEvent{Type: INSERT_ID, Value: 5})
+	p.trackStatementContext(&XIDEvent{})
+	p.trackStatementContext(q)
+	require.Nil(t, q.Context)
+}
+
+type vendorEvent struct {
+	GenericEvent
+	Value byte
+}
+
+func TestParserRegisterEventDecoder(t *testing.T) {
+	p := NewBinlogParser()
+	const vendorEventType = EventType(0xa4)
+
+	e, err := p.parseEvent(&EventHeader{EventType: vendorEventType}, []byte{7}, nil)
+	require.NoError(t, err)
+	require.IsType(t, &GenericEvent{}, e)
+
+	p.RegisterEventDecoder(vendorEventType, func(h *EventHeader, data []byte) (Event, error) {
+		if len(data) != 1 {
+			return nil, errors.New("invalid vendor event")
+		}
+		return &vendorEvent{Value: data[0]}, nil
+	})
+	e, err = p.parseEvent(&EventHeader{EventType: vendorEventType}, []byte{7}, nil)
+	require.NoError(t, err)
+	require.Equal(t, byte(7), e.(*vendorEvent).Value)
+
+	_, err = p.parseEvent(&EventHeader{EventType: vendorEventType}, nil, nil)
+	require.ErrorContains(t, err, "invalid vendor event")
+
+	// built-in decoders can be overridden, but not in raw mode
+	p.RegisterEventDecoder(XID_EVENT, func(h *EventHeader, data []byte) (Event, error) {
+		return &GenericEvent{Data: data}, nil
+	})
+	e, err = p.parseEvent(&EventHeader{EventType: XID_EVENT}, make([]byte, 8), nil)
+	require.NoError(t, err)
+	require.IsType(t, &GenericEvent{}, e)
+
+	p.SetRawMode(true)
+	e, err = p.parseEvent(&EventHeader{EventType: vendorEventType}, []byte{7}, nil)
+	require.NoError(t, err)
+	require.IsType(t, &GenericEvent{}, e)
+	p.SetRawMode(false)
+
+	p.RegisterEventDecoder(XID_EVENT, nil)
+	e, err = p.parseEvent(&EventHeader{EventType: XID_EVENT}, make([]byte, 8), nil)
+	require.NoError(t, err)
+	require.IsType(t, &XIDEvent{}, e)
+
+	// the table maps decoded by a decoder are used by the rows events
+	p.RegisterEventDecoder(TABLE_MAP_EVENT, func(h *EventHeader, data []byte) (Event, error) {
+		return &TableMapEvent{TableID: 7, Schema: []byte("db"), Table: []byte("tbl")}, nil
+	})
+	_, err = p.parseEvent(&EventHeader{EventType: TABLE_MAP_EVENT}, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []byte("tbl"), p.tables[7].Table)
+}
+
+func TestParseDir(t *testing.T) {
+	dir := t.TempDir()
+	xid := func(id uint64, checksum bool) []byte {
+		return encodeEvent(&EventHeader{EventType: XID_EVENT, ServerID: 11, LogPos: 150}, binary.LittleEndian.AppendUint64(nil, id), checksum)
+	}
+	rotate := func(name string) []byte {
+		return encodeEvent(&EventHeader{EventType: ROTATE_EVENT, ServerID: 11}, append([]byte{4, 0, 0, 0, 0, 0, 0, 0}, name...), true)
+	}
+	// a server restarted without the checksum
+	noChecksum := append([]byte(nil), testFormatDescriptionEvent...)
+	noChecksum[len(noChecksum)-5] = BINLOG_CHECKSUM_ALG_OFF
+
+	write := func(name string, events ...[]byte) {
+		data := bytes.Join(append([][]byte{BinLogFileHeader}, events...), nil)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o644))
+	}
+	write("mysql-bin.000001", testFormatDescriptionEvent, xid(1, true), rotate("mysql-bin.000002"))
+	// no rotate event, after a crash
+	write("mysql-bin.000002", noChecksum, xid(2, false))
+	write("mysql-bin.000003", testFormatDescriptionEvent, xid(3, true), rotate("mysql-bin.000004"))
+	write("mysql-bin.index", []byte("mysql-bin.000001\n"))
+
+	parse := func(fromFile string, fromPos int64) ([]uint64, error) {
+		var xids []uint64
+		err := NewBinlogParser().ParseDir(dir, fromFile, fromPos, func(e *BinlogEvent) error {
+			if ev, ok := e.Event.(*XIDEvent); ok {
+				xids = append(xids, ev.XID)
+			}
+			return nil
+		})
+		return xids, err
+	}
+
+	xids, err := parse("", 0)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2, 3}, xids)
+
+	xids, err = parse("mysql-bin.000002", int64(len(BinLogFileHeader)+len(noChecksum)))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 3}, xids)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "mysql-bin.000004"), []byte("not a binlog"), 0o644))
+	_, err = parse("mysql-bin.000003", 0)
+	require.ErrorContains(t, err, "parse mysql-bin.000004")
+
+	write("other-bin.000001", testFormatDescriptionEvent)
+	_, err = parse("", 0)
+	require.ErrorContains(t, err, "several binlog base names")
+}
+
+// memStorage is a BinlogStorage of files in memory, counting the reads.
+type memStorage struct {
+	files map[string][]byte
+	reads int
+}
+
+func (s *memStorage) List() ([]string, error) {
+	var names []string
+	for name := range s.files {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (s *memStorage) Open(name string) (io.ReaderAt, int64, error) {
+	data, ok := s.files[name]
+	if !ok {
+		return nil, 0, fmt.Errorf("open %s: %w", name, fs.ErrNotExist)
+	}
+	return readerAtFunc(func(b []byte, off int64) (int, error) {
+		s.reads++
+		return bytes.NewReader(data).ReadAt(b, off)
+	}), int64(len(data)), nil
+}
+
+type readerAtFunc func(b []byte, off int64) (int, error)
+
+func (f readerAtFunc) ReadAt(b []byte, off int64) (int, error) {
+	return f(b, off)
+}
+
+func TestParseStorage(t *testing.T) {
+	xid := func(id uint64) []byte {
+		return encodeEvent(&EventHeader{EventType: XID_EVENT, ServerID: 11, LogPos: 150}, binary.LittleEndian.AppendUint64(nil, id), true)
+	}
+	rotate := func(name string) []byte {
+		return encodeEvent(&EventHeader{EventType: ROTATE_EVENT, ServerID: 11}, append([]byte{4, 0, 0, 0, 0, 0, 0, 0}, name...), true)
+	}
+	file := func(events ...[]byte) []byte {
+		return bytes.Join(append([][]byte{BinLogFileHeader, testFormatDescriptionEvent}, events...), nil)
+	}
+	s := &memStorage{files: map[string][]byte{
+		"mysql-bin.000001": file(xid(1), xid(2), rotate("mysql-bin.000002")),
+		// rotates to a file not archived yet
+		"mysql-bin.000002": file(xid(3), rotate("mysql-bin.000003")),
+		"mysql-bin.index":  []byte("mysql-bin.000001\n"),
+	}}
+
+	var xids []uint64
+	err := NewBinlogParser().ParseStorage(s, "", 0, func(e *BinlogEvent) error {
+		if ev, ok := e.Event.(*XIDEvent); ok {
+			xids = append(xids, ev.XID)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2, 3}, xids)
+	// the reads are buffered: the header, and the events of each file
+	require.LessOrEqual(t, s.reads, 6)
+
+	// a file of the storage parsed alone
+	data := s.files["mysql-bin.000001"]
+	xids = nil
+	offset := int64(len(BinLogFileHeader) + len(testFormatDescriptionEvent) + len(xid(1)))
+	err = NewBinlogParser().ParseReaderAt(bytes.NewReader(data), int64(len(data)), offset, func(e *BinlogEvent) error {
+		if ev, ok := e.Event.(*XIDEvent); ok {
+			xids = append(xids, ev.XID)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2}, xids)
+
+	err = NewBinlogParser().ParseStorage(s, "mysql-bin.000004", 0, func(e *BinlogEvent) error { return nil })
+	require.ErrorContains(t, err, "file does not exist")
+}
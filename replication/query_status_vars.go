@@ -0,0 +1,272 @@
+package replication
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/pkg/parser/charset"
+)
+
+// QueryStatusVars are the status variables of a QueryEvent, the session state
+// the statement was executed with, see QueryEvent.ParseStatusVars. A variable
+// is only set if it was written, see Has.
+type QueryStatusVars struct {
+	// Flags2 are the OPTION_ flags of the session, like OPTION_AUTO_IS_NULL
+	Flags2 uint32
+	// SQLMode is the bitmap of sql_mode, see SQLModeNames
+	SQLMode uint64
+	Catalog string
+
+	AutoIncrementIncrement uint16
+	AutoIncrementOffset    uint16
+
+	// CharsetClient, CollationConnection and CollationServer are the ids of
+	// the collations of character_set_client, collation_connection and
+	// collation_server, see ClientCharset
+	CharsetClient       uint16
+	CollationConnection uint16
+	CollationServer     uint16
+	// CollationDatabase is the id of collation_database
+	CollationDatabase uint16
+
+	// TimeZone is time_zone, like SYSTEM or +08:00, see Location
+	TimeZone        string
+	LCTimeNamesCode uint16
+
+	TableMapForUpdate uint64
+	MasterDataWritten uint32
+
+	// InvokerUser and InvokerHost are the definer of the statement
+	InvokerUser string
+	InvokerHost string
+
+	// UpdatedDBNames are the databases updated, nil if there are more than 254
+	UpdatedDBNames []string
+
+	// Microseconds are the microseconds of the start time, Q_MICROSECONDS of
+	// MySQL or Q_HRNOW of MariaDB, see QueryEvent.Microseconds
+	Microseconds uint32
+
+	ExplicitDefaultsForTimestamp bool
+	// DDLXID is the XID of a DDL logged with it, in MySQL 8.0
+	DDLXID                     uint64
+	DefaultCollationForUTF8MB4 uint16
+	SQLRequirePrimaryKey       bool
+	DefaultTableEncryption     bool
+
+	// XID is the Q_XID of MariaDB
+	XID uint64
+
+	// codes are the codes of the variables written, in order
+	codes []byte
+}
+
+// ParseStatusVars parses the status variables of the event. The variables
+// after one unknown, whose length is unknown, can't be parsed: they are
+// returned along with an error.
+func (e *QueryEvent) ParseStatusVars() (*QueryStatusVars, error) {
+	v := &QueryStatusVars{}
+	vars := e.StatusVars
+	for len(vars) > 0 {
+		code := vars[0]
+		vars = vars[1:]
+
+		n, err := statusVarLength(code, vars)
+		if err != nil {
+			return v, errors.Trace(err)
+		}
+		if len(vars) < n {
+			return v, errors.Errorf("status variable %d truncated", code)
+		}
+		data := vars[:n]
+		vars = vars[n:]
+
+		switch code {
+		case Q_FLAGS2_CODE:
+			v.Flags2 = binary.LittleEndian.Uint32(data)
+		case Q_SQL_MODE_CODE:
+			v.SQLMode = binary.LittleEndian.Uint64(data)
+		case Q_CATALOG_CODE:
+			v.Catalog = string(data[1 : n-1])
+		case Q_CATALOG_NZ_CODE:
+			v.Catalog = string(data[1:])
+		case Q_AUTO_INCREMENT:
+			v.AutoIncrementIncrement = binary.LittleEndian.Uint16(data)
+			v.AutoIncrementOffset = binary.LittleEndian.Uint16(data[2:])
+		case Q_CHARSET_CODE:
+			v.CharsetClient = binary.LittleEndian.Uint16(data)
+			v.CollationConnection = binary.LittleEndian.Uint16(data[2:])
+			v.CollationServer = binary.LittleEndian.Uint16(data[4:])
+		case Q_TIME_ZONE_CODE:
+			v.TimeZone = string(data[1:])
+		case Q_LC_TIME_NAMES_CODE:
+			v.LCTimeNamesCode = binary.LittleEndian.Uint16(data)
+		case Q_CHARSET_DATABASE_CODE:
+			v.CollationDatabase = binary.LittleEndian.Uint16(data)
+		case Q_TABLE_MAP_FOR_UPDATE_CODE:
+			v.TableMapForUpdate = binary.LittleEndian.Uint64(data)
+		case Q_MASTER_DATA_WRITTEN_CODE:
+			v.MasterDataWritten = binary.LittleEndian.Uint32(data)
+		case Q_INVOKER:
+			userLen := int(data[0])
+			v.InvokerUser = string(data[1 : 1+userLen])
+			v.InvokerHost = string(data[2+userLen:])
+		case Q_UPDATED_DB_NAMES:
+			if data[0] != updatedDBNamesOverMax {
+				v.UpdatedDBNames = make([]string, 0, data[0])
+				for _, name := range strings.Split(string(data[1:]), "\x00") {
+					if len(v.UpdatedDBNames) < cap(v.UpdatedDBNames) {
+						v.UpdatedDBNames = append(v.UpdatedDBNames, name)
+					}
+				}
+			}
+		case Q_MICROSECONDS, Q_HRNOW:
+			v.Microseconds = uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16
+		case Q_EXPLICIT_DEFAULTS_FOR_TIMESTAMP:
+			v.ExplicitDefaultsForTimestamp = data[0] != 0
+		case Q_DDL_LOGGED_WITH_XID:
+			v.DDLXID = binary.LittleEndian.Uint64(data)
+		case Q_DEFAULT_COLLATION_FOR_UTF8MB4:
+			v.DefaultCollationForUTF8MB4 = binary.LittleEndian.Uint16(data)
+		case Q_SQL_REQUIRE_PRIMARY_KEY:
+			v.SQLRequirePrimaryKey = data[0] != 0
+		case Q_DEFAULT_TABLE_ENCRYPTION:
+			v.DefaultTableEncryption = data[0] != 0
+		case Q_XID:
+			v.XID = binary.LittleEndian.Uint64(data)
+		}
+		v.codes = append(v.codes, code)
+	}
+	return v, nil
+}
+
+// statusVarLength returns the length of the value of the status variable code
+// at the start of vars.
+func statusVarLength(code byte, vars []byte) (int, error) {
+	switch code {
+	case Q_EXPLICIT_DEFAULTS_FOR_TIMESTAMP, Q_SQL_REQUIRE_PRIMARY_KEY, Q_DEFAULT_TABLE_ENCRYPTION:
+		return 1, nil
+	case Q_LC_TIME_NAMES_CODE, Q_CHARSET_DATABASE_CODE, Q_DEFAULT_COLLATION_FOR_UTF8MB4:
+		return 2, nil
+	case Q_MICROSECONDS, Q_HRNOW:
+		return 3, nil
+	case Q_FLAGS2_CODE, Q_AUTO_INCREMENT, Q_MASTER_DATA_WRITTEN_CODE:
+		return 4, nil
+	case Q_CHARSET_CODE:
+		return 6, nil
+	case Q_SQL_MODE_CODE, Q_TABLE_MAP_FOR_UPDATE_CODE, Q_DDL_LOGGED_WITH_XID, Q_XID:
+		return 8, nil
+	}
+
+	if len(vars) < 1 {
+		return 0, errors.Errorf("status variable %d truncated", code)
+	}
+	switch code {
+	case Q_TIME_ZONE_CODE, Q_CATALOG_NZ_CODE:
+		return 1 + int(vars[0]), nil
+	case Q_CATALOG_CODE:
+		// with a NUL
+		return 2 + int(vars[0]), nil
+	case Q_INVOKER:
+		// the user, then the host
+		if len(vars) < 2+int(vars[0]) {
+			return 0, errors.Errorf("status variable %d truncated", code)
+		}
+		return 2 + int(vars[0]) + int(vars[1+int(vars[0])]), nil
+	case Q_UPDATED_DB_NAMES:
+		n := 1
+		if count := int(vars[0]); count != updatedDBNamesOverMax {
+			// NUL terminated names
+			for ; count > 0; count-- {
+				i := n
+				for i < len(vars) && vars[i] != 0 {
+					i++
+				}
+				n = i + 1
+			}
+		}
+		return n, nil
+	}
+	return 0, errors.Errorf("unknown status variable %d", code)
+}
+
+// Has returns whether the status variable code, like Q_SQL_MODE_CODE, was
+// written.
+func (v *QueryStatusVars) Has(code byte) bool {
+	for _, c := range v.codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlModeNames are the names of the bits of sql_mode, in MySQL. MariaDB names
+// the bit 4 IGNORE_BAD_TABLE_OPTIONS, and its bits from 32 are different.
+var sqlModeNames = []string{
+	"REAL_AS_FLOAT", "PIPES_AS_CONCAT", "ANSI_QUOTES", "IGNORE_SPACE", "NOT_USED", "ONLY_FULL_GROUP_BY",
+	"NO_UNSIGNED_SUBTRACTION", "NO_DIR_IN_CREATE", "POSTGRESQL", "ORACLE", "MSSQL", "DB2", "MAXDB",
+	"NO_KEY_OPTIONS", "NO_TABLE_OPTIONS", "NO_FIELD_OPTIONS", "MYSQL323", "MYSQL40", "ANSI",
+	"NO_AUTO_VALUE_ON_ZERO", "NO_BACKSLASH_ESCAPES", "STRICT_TRANS_TABLES", "STRICT_ALL_TABLES",
+	"NO_ZERO_IN_DATE", "NO_ZERO_DATE", "ALLOW_INVALID_DATES", "ERROR_FOR_DIVISION_BY_ZERO", "TRADITIONAL",
+	"NO_AUTO_CREATE_USER", "HIGH_NOT_PRECEDENCE", "NO_ENGINE_SUBSTITUTION", "PAD_CHAR_TO_FULL_LENGTH",
+	"TIME_TRUNCATE_FRACTIONAL",
+}
+
+// SQLModeNames returns the names of the modes of SQLMode, like they are in
+// @@sql_mode, the unknown bits by number.
+func (v *QueryStatusVars) SQLModeNames() []string {
+	var names []string
+	for i := 0; i < 64; i++ {
+		if v.SQLMode&(1<<i) == 0 {
+			continue
+		}
+		if i < len(sqlModeNames) {
+			names = append(names, sqlModeNames[i])
+		} else {
+			names = append(names, strconv.Itoa(i))
+		}
+	}
+	return names
+}
+
+// ClientCharset returns the charset of character_set_client, the charset of
+// the query, empty if it is unknown.
+func (v *QueryStatusVars) ClientCharset() string {
+	if !v.Has(Q_CHARSET_CODE) {
+		return ""
+	}
+	collation, err := charset.GetCollationByID(int(v.CharsetClient))
+	if err != nil {
+		return ""
+	}
+	return collation.CharsetName
+}
+
+// Location returns the location of TimeZone, nil for SYSTEM, the time zone of
+// the server, or if it isn't written.
+func (v *QueryStatusVars) Location() (*time.Location, error) {
+	tz := v.TimeZone
+	if tz == "" || strings.EqualFold(tz, "SYSTEM") {
+		return nil, nil
+	}
+	if tz[0] == '+' || tz[0] == '-' {
+		// like +08:00
+		hours, minutes, ok := strings.Cut(tz[1:], ":")
+		h, herr := strconv.Atoi(hours)
+		m, merr := strconv.Atoi(minutes)
+		if !ok || herr != nil || merr != nil || h > 14 || m > 59 {
+			return nil, errors.Errorf("invalid time zone %s", tz)
+		}
+		offset := h*3600 + m*60
+		if tz[0] == '-' {
+			offset = -offset
+		}
+		return time.FixedZone(tz, offset), nil
+	}
+	loc, err := time.LoadLocation(tz)
+	return loc, errors.Annotatef(err, "time zone %s", tz)
+}
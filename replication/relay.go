@@ -0,0 +1,235 @@
+package replication
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/pingcap/errors"
+)
+
+// RelayConfig is the config of a Relay.
+type RelayConfig struct {
+	// IncludeTableRegex and ExcludeTableRegex filter the tables, like in canal:
+	// a table is relayed if "db.table" matches one of IncludeTableRegex, or
+	// IncludeTableRegex is empty, and none of ExcludeTableRegex. A query event,
+	// like a DDL, is matched as "db." with its default database, so `db\..*`
+	// matches the queries run in db. BEGIN, COMMIT and the like are always
+	// relayed.
+	IncludeTableRegex []string
+	ExcludeTableRegex []string
+
+	// RenameSchemas maps the databases of the source to the names in the relayed
+	// table map and query events. The text of a query is not rewritten, a table
+	// name qualified with the database in a DDL is kept.
+	RenameSchemas map[string]string
+
+	// ChanSize is the size of the channel of the relayed stream, see
+	// NewBinlogStreamerWithChanSize.
+	ChanSize int
+}
+
+// Relay filters and rewrites the events of a BinlogStreamer into another
+// stream, for selective replication. A rewritten event is encoded again with its
+// new size and checksum, the log positions are the ones of the source. A
+// transaction payload event with filtered or rewritten events is replaced by
+// its events, uncompressed.
+type Relay struct {
+	cfg RelayConfig
+	src *BinlogStreamer
+	out *BinlogStreamer
+
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+	matches map[string]bool
+
+	// checksum is whether the events of the source have a CRC32 checksum, from
+	// the last format description event
+	checksum bool
+	// skipped are the ids of the filtered tables
+	skipped map[uint64]bool
+}
+
+var transactionControlExp = regexp.MustCompile(`(?i)^\s*(BEGIN|COMMIT|ROLLBACK|SAVEPOINT|XA)\b`)
+
+// NewRelay creates a Relay of the events of src, see Run.
+func NewRelay(src *BinlogStreamer, cfg RelayConfig) (*Relay, error) {
+	r := &Relay{
+		cfg:     cfg,
+		src:     src,
+		out:     NewBinlogStreamerWithChanSize(cfg.ChanSize),
+		matches: make(map[string]bool),
+		skipped: make(map[uint64]bool),
+	}
+	for _, val := range cfg.IncludeTableRegex {
+		reg, err := regexp.Compile(val)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		r.include = append(r.include, reg)
+	}
+	for _, val := range cfg.ExcludeTableRegex {
+		reg, err := regexp.Compile(val)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		r.exclude = append(r.exclude, reg)
+	}
+	return r, nil
+}
+
+// Streamer returns the stream of the relayed events.
+func (r *Relay) Streamer() *BinlogStreamer {
+	return r.out
+}
+
+// Run relays the events of the source until it fails or ctx is done. The error
+// is returned, and by the relayed stream too.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		e, err := r.src.GetEvent(ctx)
+		if err == nil {
+			err = r.relay(ctx, e)
+		}
+		if err != nil {
+			r.out.closeWithError(err)
+			return err
+		}
+	}
+}
+
+func (r *Relay) relay(ctx context.Context, e *BinlogEvent) error {
+	events, err := r.Handle(e)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, e := range events {
+		r.out.bufferedBytes.Add(int64(len(e.RawData)))
+		select {
+		case r.out.ch <- e:
+		case <-ctx.Done():
+			r.out.release(e)
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Handle returns the events relayed for e: none if e is filtered, e itself,
+// rewritten if needed, or the events of a transaction payload event. It is used
+// by Run, and can relay events from another source than a BinlogStreamer, but
+// not concurrently. e may be modified.
+func (r *Relay) Handle(e *BinlogEvent) ([]*BinlogEvent, error) {
+	if ev, ok := e.Event.(*FormatDescriptionEvent); ok {
+		r.checksum = ev.ChecksumAlgorithm == BINLOG_CHECKSUM_ALG_CRC32
+	}
+
+	ev, ok := e.Event.(*TransactionPayloadEvent)
+	if !ok {
+		events, _, err := r.handle(e, r.checksum)
+		return events, err
+	}
+
+	var events []*BinlogEvent
+	changed := false
+	for _, inner := range ev.Events {
+		// the events of the payload have no checksum
+		relayed, c, err := r.handle(inner, false)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		changed = changed || c
+		events = append(events, relayed...)
+	}
+	if !changed {
+		return []*BinlogEvent{e}, nil
+	}
+	if r.checksum {
+		for _, inner := range events {
+			inner.RawData = encodeEvent(inner.Header, inner.RawData[EventHeaderSize:], true)
+		}
+	}
+	return events, nil
+}
+
+// handle returns the events relayed for e, and whether they are not e as it was.
+func (r *Relay) handle(e *BinlogEvent, checksum bool) ([]*BinlogEvent, bool, error) {
+	switch ev := e.Event.(type) {
+	case *TableMapEvent:
+		if !r.match(string(ev.Schema), string(ev.Table)) {
+			r.skipped[ev.TableID] = true
+			return nil, true, nil
+		}
+		delete(r.skipped, ev.TableID)
+
+		schema, ok := r.rename(ev.Schema)
+		if !ok {
+			break
+		}
+		body, err := ev.encodeWithSchema(eventBody(e.RawData, checksum), schema)
+		if err != nil {
+			return nil, false, errors.Trace(err)
+		}
+		e.RawData = encodeEvent(e.Header, body, checksum)
+		ev.Schema = schema
+		return []*BinlogEvent{e}, true, nil
+	case *RowsEvent:
+		if r.skipped[ev.TableID] {
+			return nil, true, nil
+		}
+	case *QueryEvent:
+		if !transactionControlExp.Match(ev.Query) && !r.match(string(ev.Schema), "") {
+			return nil, true, nil
+		}
+
+		schema, ok := r.rename(ev.Schema)
+		if !ok {
+			break
+		}
+		old := ev.Schema
+		ev.Schema = schema
+		body, err := ev.Encode()
+		if err != nil {
+			ev.Schema = old
+			return nil, false, errors.Trace(err)
+		}
+		e.RawData = encodeEvent(e.Header, body, checksum)
+		return []*BinlogEvent{e}, true, nil
+	}
+	return []*BinlogEvent{e}, false, nil
+}
+
+func (r *Relay) rename(schema []byte) ([]byte, bool) {
+	name, ok := r.cfg.RenameSchemas[string(schema)]
+	if !ok || name == string(schema) {
+		return schema, false
+	}
+	return []byte(name), true
+}
+
+func (r *Relay) match(schema, table string) bool {
+	if r.include == nil && r.exclude == nil {
+		return true
+	}
+
+	key := schema + "." + table
+	if matched, ok := r.matches[key]; ok {
+		return matched
+	}
+	matched := r.include == nil
+	for _, reg := range r.include {
+		if reg.MatchString(key) {
+			matched = true
+			break
+		}
+	}
+	if matched {
+		for _, reg := range r.exclude {
+			if reg.MatchString(key) {
+				matched = false
+				break
+			}
+		}
+	}
+	r.matches[key] = matched
+	return matched
+}
@@ -0,0 +1,430 @@
+package replication
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// DefaultRelayLogMaxFileSize is the default RelayLogConfig.MaxFileSize, like
+// max_binlog_size.
+const DefaultRelayLogMaxFileSize = 1 << 30
+
+// RelayLogConfig is the config of a RelayLog.
+type RelayLogConfig struct {
+	// Dir is the directory of the files.
+	Dir string
+	// BaseName is the name of the files, BaseName.000001, BaseName.000002, etc.
+	// and the index file BaseName.index listing them. "relay-bin" by default.
+	BaseName string
+	// MaxFileSize is the size of a file above which the next event is written to
+	// a new file, DefaultRelayLogMaxFileSize by default.
+	MaxFileSize int64
+	// SyncOnWrite syncs the file to the disk after each event.
+	SyncOnWrite bool
+	// CompressMinSize is the size of the rows events from which they are written
+	// compressed by zstd, in a TRANSACTION_PAYLOAD_EVENT, to use less disk. They
+	// are replayed as they were received by ReplayRelayLog. 0 disables it.
+	CompressMinSize int
+}
+
+func (cfg *RelayLogConfig) adjust() {
+	if cfg.BaseName == "" {
+		cfg.BaseName = "relay-bin"
+	}
+	if cfg.MaxFileSize <= 0 {
+		cfg.MaxFileSize = DefaultRelayLogMaxFileSize
+	}
+}
+
+func (cfg *RelayLogConfig) indexPath() string {
+	return filepath.Join(cfg.Dir, cfg.BaseName+".index")
+}
+
+// RelayLog writes the raw events received from a source to local files in the
+// binlog format, to process them apart from their reception and replay them
+// after a crash, see ReplayRelayLog. Like the relay log of MySQL, a new file
+// starts with the last format description event, and the events keep the log
+// positions of the source.
+type RelayLog struct {
+	cfg RelayLogConfig
+
+	mu    sync.Mutex
+	files []string
+	f     *os.File
+	size  int64
+	// fde is the raw data of the last format description event
+	fde []byte
+	// pos is the position in the source after the last event
+	pos mysql.Position
+	// enc compresses the events, see CompressMinSize
+	enc *zstd.Encoder
+}
+
+// OpenRelayLog opens the relay log of cfg, or creates it. An event partially
+// written to the last file, by a crash, is removed.
+func OpenRelayLog(cfg RelayLogConfig) (*RelayLog, error) {
+	cfg.adjust()
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	files, err := readRelayLogIndex(cfg)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	l := &RelayLog{cfg: cfg, files: files}
+	if len(files) > 0 {
+		if err = l.openLast(); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return l, nil
+}
+
+func readRelayLogIndex(cfg RelayLogConfig) ([]string, error) {
+	data, err := os.ReadFile(cfg.indexPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var files []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
+// openLast opens the last file for writing, after its last complete event.
+func (l *RelayLog) openLast() error {
+	f, err := os.OpenFile(filepath.Join(l.cfg.Dir, l.files[len(l.files)-1]), os.O_RDWR, 0o644)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	size, err := l.scan(f)
+	if err == nil {
+		err = f.Truncate(size)
+	}
+	if err == nil {
+		_, err = f.Seek(size, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return errors.Trace(err)
+	}
+	l.f, l.size = f, size
+	return nil
+}
+
+// scan reads the events of f, the last format description event and the source
+// position, and returns the size of the complete events.
+func (l *RelayLog) scan(f *os.File) (int64, error) {
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(BinLogFileHeader))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		// a file created but not written
+		return 0, f.Truncate(0)
+	} else if !bytes.Equal(magic, BinLogFileHeader) {
+		return 0, errors.Errorf("%s is not a binlog file", f.Name())
+	}
+
+	var rotate []byte
+	size := int64(len(magic))
+	header := make([]byte, EventHeaderSize)
+events:
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			break
+		}
+		h := new(EventHeader)
+		if err := h.Decode(header); err != nil || h.EventSize < EventHeaderSize {
+			break
+		}
+
+		var data []byte
+		switch h.EventType {
+		case FORMAT_DESCRIPTION_EVENT, ROTATE_EVENT:
+			data = make([]byte, h.EventSize)
+			copy(data, header)
+			if _, err := io.ReadFull(r, data[EventHeaderSize:]); err != nil {
+				break events
+			}
+		default:
+			if n, _ := r.Discard(int(h.EventSize) - EventHeaderSize); n != int(h.EventSize)-EventHeaderSize {
+				break events
+			}
+		}
+		size += int64(h.EventSize)
+
+		switch h.EventType {
+		case FORMAT_DESCRIPTION_EVENT:
+			l.fde = data
+			if rotate != nil {
+				l.pos = l.decodeRotate(rotate)
+				rotate = nil
+			}
+		case ROTATE_EVENT:
+			if l.fde != nil {
+				l.pos = l.decodeRotate(data)
+			} else {
+				// the checksum is known from the format description event,
+				// which comes next in the first file
+				rotate = data
+			}
+		}
+		if h.EventType != ROTATE_EVENT && h.LogPos > 0 {
+			l.pos.Pos = h.LogPos
+		}
+	}
+	if rotate != nil {
+		l.pos = l.decodeRotate(rotate)
+	}
+	return size, nil
+}
+
+// checksum returns whether the events have a CRC32 checksum, from the last
+// format description event.
+func (l *RelayLog) checksum() bool {
+	if l.fde == nil {
+		return false
+	}
+	fde := new(FormatDescriptionEvent)
+	return fde.Decode(l.fde[EventHeaderSize:]) == nil && fde.ChecksumAlgorithm == BINLOG_CHECKSUM_ALG_CRC32
+}
+
+func (l *RelayLog) decodeRotate(data []byte) mysql.Position {
+	body := eventBody(data, l.checksum())
+	if len(body) < 8 {
+		return l.pos
+	}
+	return mysql.Position{Name: string(body[8:]), Pos: uint32(binary.LittleEndian.Uint64(body))}
+}
+
+// fakeRotateEvent returns a fake rotate event to the source position, like the
+// one starting a binlog dump.
+func (l *RelayLog) fakeRotateEvent() []byte {
+	h := &EventHeader{
+		EventType: ROTATE_EVENT,
+		ServerID:  binary.LittleEndian.Uint32(l.fde[5:]),
+		Flags:     LOG_EVENT_ARTIFICIAL_F,
+	}
+	body := binary.LittleEndian.AppendUint64(nil, uint64(l.pos.Pos))
+	body = append(body, l.pos.Name...)
+	return encodeEvent(h, body, l.checksum())
+}
+
+// Files returns the names of the files, in order.
+func (l *RelayLog) Files() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.files...)
+}
+
+// Position returns the position in the source after the last event written, to
+// sync from once the relay log is replayed.
+func (l *RelayLog) Position() mysql.Position {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.pos
+}
+
+// WriteEvent writes the raw data of e, the heartbeats are skipped.
+func (l *RelayLog) WriteEvent(e *BinlogEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch e.Header.EventType {
+	case HEARTBEAT_EVENT, HEARTBEAT_LOG_EVENT_V2:
+		return nil
+	}
+
+	if l.f == nil || l.size >= l.cfg.MaxFileSize {
+		if err := l.rotate(e.Header.EventType != FORMAT_DESCRIPTION_EVENT); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	data, err := l.compress(e)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if _, err = l.f.Write(data); err != nil {
+		return errors.Trace(err)
+	}
+	l.size += int64(len(data))
+	if l.cfg.SyncOnWrite {
+		if err := l.f.Sync(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	if e.Header.EventType == FORMAT_DESCRIPTION_EVENT {
+		l.fde = append(l.fde[:0], e.RawData...)
+	}
+	if ev, ok := e.Event.(*RotateEvent); ok {
+		l.pos = mysql.Position{Name: string(ev.NextLogName), Pos: uint32(ev.Position)}
+	} else if e.Header.LogPos > 0 {
+		l.pos.Pos = e.Header.LogPos
+	}
+	return nil
+}
+
+// compress returns the raw data of e compressed in a payload event, if it is a
+// rows event of CompressMinSize and compressing makes it smaller.
+func (l *RelayLog) compress(e *BinlogEvent) ([]byte, error) {
+	if l.cfg.CompressMinSize <= 0 || len(e.RawData) < l.cfg.CompressMinSize || !isRelayCompressible(e.Header.EventType) {
+		return e.RawData, nil
+	}
+	if l.enc == nil {
+		enc, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		l.enc = enc
+	}
+	data, err := compressRelayEvent(l.enc, e.RawData, l.checksum())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(data) >= len(e.RawData) {
+		return e.RawData, nil
+	}
+	return data, nil
+}
+
+// rotate starts a new file, with the last format description event if
+// writeFDE is set, and a fake rotate event to keep the source position.
+func (l *RelayLog) rotate(writeFDE bool) error {
+	var seq uint64
+	if len(l.files) > 0 {
+		_, last, err := mysql.ParseBinlogFileName(l.files[len(l.files)-1])
+		if err != nil {
+			return errors.Trace(err)
+		}
+		seq = last
+	}
+	name := fmt.Sprintf("%s.%06d", l.cfg.BaseName, seq+1)
+
+	f, err := os.OpenFile(filepath.Join(l.cfg.Dir, name), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	data := BinLogFileHeader
+	if writeFDE && l.fde != nil {
+		data = append(append([]byte(nil), data...), l.fde...)
+		if l.pos.Name != "" {
+			data = append(data, l.fakeRotateEvent()...)
+		}
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return errors.Trace(err)
+	}
+
+	files := append(l.files, name)
+	if err = writeRelayLogIndex(l.cfg, files); err != nil {
+		f.Close()
+		return errors.Trace(err)
+	}
+
+	if l.f != nil {
+		if err = l.f.Close(); err != nil {
+			f.Close()
+			return errors.Trace(err)
+		}
+	}
+	l.files, l.f, l.size = files, f, int64(len(data))
+	return nil
+}
+
+func writeRelayLogIndex(cfg RelayLogConfig, files []string) error {
+	tmp := cfg.indexPath() + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(files, "\n")+"\n"), 0o644); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(os.Rename(tmp, cfg.indexPath()))
+}
+
+// Run writes the events of s until it fails or ctx is done.
+func (l *RelayLog) Run(ctx context.Context, s *BinlogStreamer) error {
+	for {
+		e, err := s.GetEvent(ctx)
+		if err != nil {
+			return err
+		}
+		if err = l.WriteEvent(e); err != nil {
+			return errors.Trace(err)
+		}
+	}
+}
+
+// Close closes the current file.
+func (l *RelayLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.enc != nil {
+		l.enc.Close()
+		l.enc = nil
+	}
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return errors.Trace(err)
+}
+
+// ReplayRelayLog parses the events of the files of the relay log of cfg in
+// order, see BinlogParser.ParseFile. The relay log should be opened first by
+// OpenRelayLog after a crash, to remove an event partially written. The events
+// compressed by the relay log, see CompressMinSize, are decompressed.
+func ReplayRelayLog(cfg RelayLogConfig, p *BinlogParser, onEvent OnEventFunc) error {
+	cfg.adjust()
+	files, err := readRelayLogIndex(cfg)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	onRelayEvent := func(e *BinlogEvent) error {
+		if !isRelayCompressed(e.Header) {
+			return onEvent(e)
+		}
+		checksum := p.format != nil && p.format.ChecksumAlgorithm == BINLOG_CHECKSUM_ALG_CRC32
+		events, err := expandRelayEvent(e.RawData, checksum)
+		if err != nil {
+			return errors.Annotatef(err, "decompress event at %d", e.Header.LogPos)
+		}
+		for _, data := range events {
+			ev, err := p.Parse(data)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if err = onEvent(ev); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, name := range files {
+		if err = p.ParseFile(filepath.Join(cfg.Dir, name), 0, onRelayEvent); err != nil {
+			return errors.Annotatef(err, "replay %s", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package replication
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pingcap/errors"
+)
+
+// isRelayCompressible returns whether the events of t are compressed by a
+// RelayLog with CompressMinSize.
+func isRelayCompressible(t EventType) bool {
+	switch t {
+	case WRITE_ROWS_EVENTv0, UPDATE_ROWS_EVENTv0, DELETE_ROWS_EVENTv0,
+		WRITE_ROWS_EVENTv1, UPDATE_ROWS_EVENTv1, DELETE_ROWS_EVENTv1,
+		WRITE_ROWS_EVENTv2, UPDATE_ROWS_EVENTv2, DELETE_ROWS_EVENTv2,
+		PARTIAL_UPDATE_ROWS_EVENT:
+		return true
+	}
+	return false
+}
+
+// isRelayCompressed returns whether the event of h is a payload event written
+// by a RelayLog, and not by the source, to replay as the event it compresses.
+func isRelayCompressed(h *EventHeader) bool {
+	return h.EventType == TRANSACTION_PAYLOAD_EVENT && h.Flags&LOG_EVENT_RELAY_LOG_F != 0
+}
+
+// compressRelayEvent returns the raw data of a TRANSACTION_PAYLOAD_EVENT with
+// the event of data compressed by zstd, like binlog_transaction_compression
+// does. The payload event keeps the header of the event, its position in the
+// source, with LOG_EVENT_RELAY_LOG_F set. Like in the payload of MySQL, the
+// event compressed has no checksum.
+func compressRelayEvent(enc *zstd.Encoder, data []byte, checksum bool) ([]byte, error) {
+	h := new(EventHeader)
+	if err := h.Decode(data); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	inner := data
+	if checksum {
+		inner = append([]byte(nil), data[:len(data)-BinlogChecksumLength]...)
+		binary.LittleEndian.PutUint32(inner[9:], uint32(len(inner)))
+	}
+	payload := enc.EncodeAll(inner, nil)
+
+	body := make([]byte, 0, 3*10+1+len(payload))
+	body = appendPayloadField(body, OTW_PAYLOAD_COMPRESSION_TYPE_FIELD, ZSTD)
+	body = appendPayloadField(body, OTW_PAYLOAD_UNCOMPRESSED_SIZE_FIELD, uint64(len(inner)))
+	body = appendPayloadField(body, OTW_PAYLOAD_SIZE_FIELD, uint64(len(payload)))
+	body = append(body, OTW_PAYLOAD_HEADER_END_MARK)
+	body = append(body, payload...)
+
+	h.EventType = TRANSACTION_PAYLOAD_EVENT
+	h.Flags |= LOG_EVENT_RELAY_LOG_F
+	return encodeEvent(h, body, checksum), nil
+}
+
+// appendPayloadField appends a field of a payload event, its type, the length of
+// the value and the value in little endian.
+func appendPayloadField(data []byte, fieldType byte, value uint64) []byte {
+	n := 1
+	for v := value >> 8; v > 0; v >>= 8 {
+		n++
+	}
+	data = append(data, fieldType, byte(n))
+	for i := 0; i < n; i++ {
+		data = append(data, byte(value>>(8*i)))
+	}
+	return data
+}
+
+// expandRelayEvent returns the raw data of the events compressed in the payload
+// event of data, with the checksum if checksum is set, as they were before
+// compressRelayEvent.
+func expandRelayEvent(data []byte, checksum bool) ([][]byte, error) {
+	body := eventBody(data, checksum)
+	e := new(TransactionPayloadEvent)
+	if len(body) == 0 {
+		return nil, errors.New("empty payload event")
+	}
+	if err := e.decodeFields(body); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if e.CompressionType != ZSTD {
+		return nil, errors.Errorf("payload event has compression type %s", e.compressionType())
+	}
+
+	dec, err := payloadDecoder()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	payload, err := dec.DecodeAll(e.Payload, make([]byte, 0, e.UncompressedSize))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	var events [][]byte
+	for len(payload) > 0 {
+		if len(payload) < EventHeaderSize {
+			return nil, errors.Errorf("event header of %d bytes in payload event too short", len(payload))
+		}
+		size := int(binary.LittleEndian.Uint32(payload[9:]))
+		if size < EventHeaderSize || size > len(payload) {
+			return nil, errors.Errorf("event length of %d in payload event of %d bytes invalid", size, len(payload))
+		}
+		event := payload[:size:size]
+		if checksum {
+			event = append(event, 0, 0, 0, 0)
+			binary.LittleEndian.PutUint32(event[9:], uint32(len(event)))
+			binary.LittleEndian.PutUint32(event[size:], crc32.ChecksumIEEE(event[:size]))
+		}
+		events = append(events, event)
+		payload = payload[size:]
+	}
+	return events, nil
+}
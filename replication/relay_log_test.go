@@ -0,0 +1,134 @@
+package replication
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// testFormatDescriptionEvent is a format description event of MySQL 5.7.22
+// with the CRC32 checksum.
+var testFormatDescriptionEvent = []byte{0x64, 0x61, 0x72, 0x63, 0xf, 0xb, 0x0, 0x0, 0x0, 0x77, 0x0, 0x0, 0x0, 0x7b, 0x0, 0x0, 0x0, 0x1, 0x0, 0x4, 0x0, 0x35, 0x2e, 0x37, 0x2e, 0x32, 0x32, 0x2d, 0x6c, 0x6f, 0x67, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x64, 0x61, 0x72, 0x63, 0x13, 0x38, 0xd, 0x0, 0x8, 0x0, 0x12, 0x0, 0x4, 0x4, 0x4, 0x4, 0x12, 0x0, 0x0, 0x5f, 0x0, 0x4, 0x1a, 0x8, 0x0, 0x0, 0x0, 0x8, 0x8, 0x8, 0x2, 0x0, 0x0, 0x0, 0xa, 0xa, 0xa, 0x2a, 0x2a, 0x0, 0x12, 0x34, 0x0, 0x1, 0xb8, 0x78, 0x9d, 0xfe}
+
+func TestRelayLog(t *testing.T) {
+	fde := testFormatDescriptionEvent
+	rotate := encodeEvent(&EventHeader{EventType: ROTATE_EVENT, ServerID: 11}, append([]byte{4, 0, 0, 0, 0, 0, 0, 0}, "mysql-bin.000007"...), true)
+	events := [][]byte{
+		fde,
+		rotate,
+		{0x8d, 0x61, 0x72, 0x63, 0x13, 0xb, 0x0, 0x0, 0x0, 0x2c, 0x0, 0x0, 0x0, 0xa7, 0x0, 0x0, 0x0, 0x1, 0x0, 0x6c, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x2, 0x64, 0x62, 0x0, 0x3, 0x74, 0x62, 0x6c, 0x0, 0x1, 0x3, 0x0, 0x0, 0x63, 0x17, 0xe6, 0xf0},
+		{0xb6, 0x61, 0x72, 0x63, 0x1e, 0xb, 0x0, 0x0, 0x0, 0x28, 0x0, 0x0, 0x0, 0xcf, 0x0, 0x0, 0x0, 0x1, 0x0, 0x6c, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x2, 0x0, 0x1, 0xff, 0x0, 0x1, 0x0, 0x0, 0x0, 0xf9, 0xf7, 0x89, 0x2a},
+		{0x22, 0x6c, 0x72, 0x63, 0x13, 0xb, 0x0, 0x0, 0x0, 0x2e, 0x0, 0x0, 0x0, 0xfd, 0x0, 0x0, 0x0, 0x1, 0x0, 0x76, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x3, 0x64, 0x62, 0x31, 0x0, 0x4, 0x74, 0x62, 0x6c, 0x31, 0x0, 0x1, 0x1, 0x0, 0x0, 0x32, 0xec, 0x2f, 0x4},
+		{0xeb, 0x64, 0x72, 0x63, 0x1e, 0xb, 0x0, 0x0, 0x0, 0x2d, 0x0, 0x0, 0x0, 0x2a, 0x1, 0x0, 0x0, 0x1, 0x0, 0x76, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x2, 0x0, 0x1, 0xff, 0x0, 0x1, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x0, 0x0, 0x6e, 0xef, 0xb2, 0xb1},
+	}
+
+	cfg := RelayLogConfig{Dir: t.TempDir(), MaxFileSize: 250}
+	l, err := OpenRelayLog(cfg)
+	require.NoError(t, err)
+
+	parser := NewBinlogParser()
+	parser.SetRawMode(true)
+	for i, data := range events {
+		e, err := parser.Parse(data)
+		require.NoError(t, err)
+		require.NoError(t, l.WriteEvent(e))
+		if i == 3 {
+			require.NoError(t, l.WriteEvent(&BinlogEvent{Header: &EventHeader{EventType: HEARTBEAT_EVENT}}))
+		}
+	}
+	pos := mysql.Position{Name: "mysql-bin.000007", Pos: 298}
+	require.Equal(t, pos, l.Position())
+	require.Equal(t, []string{"relay-bin.000001", "relay-bin.000002"}, l.Files())
+	require.NoError(t, l.Close())
+
+	// an event partially written by a crash is removed
+	last := filepath.Join(cfg.Dir, "relay-bin.000002")
+	st, err := os.Stat(last)
+	require.NoError(t, err)
+	f, err := os.OpenFile(last, os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.Write(events[2][:30])
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	l, err = OpenRelayLog(cfg)
+	require.NoError(t, err)
+	require.Equal(t, pos, l.Position())
+	require.Len(t, l.Files(), 2)
+	require.NoError(t, l.Close())
+	st2, err := os.Stat(last)
+	require.NoError(t, err)
+	require.Equal(t, st.Size(), st2.Size())
+
+	var types []EventType
+	p := NewBinlogParser()
+	p.SetRawMode(true)
+	err = ReplayRelayLog(cfg, p, func(e *BinlogEvent) error {
+		types = append(types, e.Header.EventType)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []EventType{
+		FORMAT_DESCRIPTION_EVENT, ROTATE_EVENT, TABLE_MAP_EVENT, WRITE_ROWS_EVENTv2,
+		FORMAT_DESCRIPTION_EVENT, ROTATE_EVENT, TABLE_MAP_EVENT, WRITE_ROWS_EVENTv2,
+	}, types)
+}
+
+func TestRelayLogCompress(t *testing.T) {
+	tableMap := []byte{0x8d, 0x61, 0x72, 0x63, 0x13, 0xb, 0x0, 0x0, 0x0, 0x2c, 0x0, 0x0, 0x0, 0xa7, 0x0, 0x0, 0x0, 0x1, 0x0, 0x6c, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x2, 0x64, 0x62, 0x0, 0x3, 0x74, 0x62, 0x6c, 0x0, 0x1, 0x3, 0x0, 0x0, 0x63, 0x17, 0xe6, 0xf0}
+	// a write rows event of 1000 rows of the int column of db.tbl, 0 to 9
+	body := []byte{0x6c, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x0, 0x2, 0x0, 0x1, 0xff}
+	for i := 0; i < 1000; i++ {
+		body = append(body, 0x0, byte(i%10), 0x0, 0x0, 0x0)
+	}
+	rows := encodeEvent(&EventHeader{Timestamp: 0x637261b6, EventType: WRITE_ROWS_EVENTv2, ServerID: 11, LogPos: 0xa7 + 5027, Flags: 0x1}, body, true)
+	events := [][]byte{testFormatDescriptionEvent, tableMap, rows}
+
+	write := func(cfg RelayLogConfig) int64 {
+		l, err := OpenRelayLog(cfg)
+		require.NoError(t, err)
+		parser := NewBinlogParser()
+		parser.SetRawMode(true)
+		for _, data := range events {
+			e, err := parser.Parse(data)
+			require.NoError(t, err)
+			require.NoError(t, l.WriteEvent(e))
+		}
+		require.Equal(t, uint32(0xa7+5027), l.Position().Pos)
+		require.NoError(t, l.Close())
+		st, err := os.Stat(filepath.Join(cfg.Dir, "relay-bin.000001"))
+		require.NoError(t, err)
+		return st.Size()
+	}
+	cfg := RelayLogConfig{Dir: t.TempDir(), CompressMinSize: 1024}
+	size := write(cfg)
+	require.Less(t, size, write(RelayLogConfig{Dir: t.TempDir()})/2)
+
+	// the position is kept after a restart
+	l, err := OpenRelayLog(cfg)
+	require.NoError(t, err)
+	require.Equal(t, uint32(0xa7+5027), l.Position().Pos)
+	require.NoError(t, l.Close())
+
+	for _, raw := range []bool{true, false} {
+		var replayed []*BinlogEvent
+		p := NewBinlogParser()
+		p.SetRawMode(raw)
+		err := ReplayRelayLog(cfg, p, func(e *BinlogEvent) error {
+			replayed = append(replayed, e)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, replayed, 3)
+		for i, e := range replayed {
+			require.Equal(t, events[i], e.RawData)
+		}
+		if !raw {
+			require.Len(t, replayed[2].Event.(*RowsEvent).Rows, 1000)
+		}
+	}
+}
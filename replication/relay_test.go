@@ -0,0 +1,145 @@
+package replication
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testTableMapEvent(t *testing.T, schema string, table string, tableID byte, checksum bool) *BinlogEvent {
+	t.Helper()
+	body := []byte{tableID, 0, 0, 0, 0, 0, 1, 0, byte(len(schema))}
+	body = append(body, schema...)
+	body = append(body, 0, byte(len(table)))
+	body = append(body, table...)
+	body = append(body, 0, 1, 1, 0, 1)
+
+	h := &EventHeader{EventType: TABLE_MAP_EVENT, ServerID: 1, LogPos: 100}
+	raw := encodeEvent(h, body, checksum)
+	ev := &TableMapEvent{tableIDSize: 6}
+	require.NoError(t, ev.Decode(eventBody(raw, checksum)))
+	return &BinlogEvent{RawData: raw, Header: h, Event: ev}
+}
+
+func testQueryEvent(t *testing.T, schema string, query string, checksum bool) *BinlogEvent {
+	t.Helper()
+	ev := &QueryEvent{SlaveProxyID: 7, StatusVars: []byte{0, 0, 0, 0, 0}, Schema: []byte(schema), Query: []byte(query)}
+	body, err := ev.Encode()
+	require.NoError(t, err)
+
+	h := &EventHeader{EventType: QUERY_EVENT, ServerID: 1, LogPos: 200}
+	decoded := new(QueryEvent)
+	require.NoError(t, decoded.Decode(body))
+	require.Equal(t, ev, decoded)
+	return &BinlogEvent{RawData: encodeEvent(h, body, checksum), Header: h, Event: decoded}
+}
+
+func testRowsEvent(tableID uint64) *BinlogEvent {
+	h := &EventHeader{EventType: WRITE_ROWS_EVENTv2, EventSize: EventHeaderSize}
+	return &BinlogEvent{RawData: h.Encode(), Header: h, Event: &RowsEvent{TableID: tableID}}
+}
+
+func requireChecksum(t *testing.T, e *BinlogEvent) {
+	t.Helper()
+	n := len(e.RawData) - BinlogChecksumLength
+	require.Equal(t, crc32.ChecksumIEEE(e.RawData[:n]), binary.LittleEndian.Uint32(e.RawData[n:]))
+	require.EqualValues(t, len(e.RawData), e.Header.EventSize)
+	require.EqualValues(t, len(e.RawData), binary.LittleEndian.Uint32(e.RawData[9:]))
+}
+
+func TestRelayHandle(t *testing.T) {
+	r, err := NewRelay(NewBinlogStreamer(), RelayConfig{
+		ExcludeTableRegex: []string{`other\..*`},
+		RenameSchemas:     map[string]string{"test": "prod"},
+	})
+	require.NoError(t, err)
+
+	events, err := r.Handle(&BinlogEvent{Header: &EventHeader{EventType: FORMAT_DESCRIPTION_EVENT}, Event: &FormatDescriptionEvent{ChecksumAlgorithm: BINLOG_CHECKSUM_ALG_CRC32}})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	events, err = r.Handle(testTableMapEvent(t, "test", "funnytable", 1, true))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	requireChecksum(t, events[0])
+	require.EqualValues(t, 100, events[0].Header.LogPos)
+	tableMap := &TableMapEvent{tableIDSize: 6}
+	require.NoError(t, tableMap.Decode(eventBody(events[0].RawData, true)))
+	require.Equal(t, "prod", string(tableMap.Schema))
+	require.Equal(t, "funnytable", string(tableMap.Table))
+	require.Equal(t, "prod", string(events[0].Event.(*TableMapEvent).Schema))
+
+	events, err = r.Handle(testTableMapEvent(t, "other", "t", 2, true))
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	events, err = r.Handle(testRowsEvent(1))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	events, err = r.Handle(testRowsEvent(2))
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	events, err = r.Handle(testQueryEvent(t, "other", "BEGIN", true))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	events, err = r.Handle(testQueryEvent(t, "other", "DROP TABLE t", true))
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	events, err = r.Handle(testQueryEvent(t, "test", "ALTER TABLE funnytable ADD c INT", true))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	requireChecksum(t, events[0])
+	query := new(QueryEvent)
+	require.NoError(t, query.Decode(eventBody(events[0].RawData, true)))
+	require.Equal(t, "prod", string(query.Schema))
+	require.Equal(t, "ALTER TABLE funnytable ADD c INT", string(query.Query))
+	require.EqualValues(t, 7, query.SlaveProxyID)
+
+	// a payload with a filtered event is expanded, with the checksum of the
+	// stream
+	payload := &BinlogEvent{Header: &EventHeader{EventType: TRANSACTION_PAYLOAD_EVENT}, Event: &TransactionPayloadEvent{Events: []*BinlogEvent{
+		testQueryEvent(t, "test", "BEGIN", false),
+		testTableMapEvent(t, "other", "t", 2, false),
+		testRowsEvent(2),
+	}}}
+	events, err = r.Handle(payload)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	requireChecksum(t, events[0])
+
+	payload.Event = &TransactionPayloadEvent{Events: []*BinlogEvent{testRowsEvent(1)}}
+	events, err = r.Handle(payload)
+	require.NoError(t, err)
+	require.Equal(t, []*BinlogEvent{payload}, events)
+}
+
+func TestRelayRun(t *testing.T) {
+	src := NewBinlogStreamer()
+	r, err := NewRelay(src, RelayConfig{IncludeTableRegex: []string{`test\.funnytable`}})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx) }()
+
+	require.NoError(t, src.AddEventToStreamer(testTableMapEvent(t, "test", "other", 2, false)))
+	kept := testTableMapEvent(t, "test", "funnytable", 1, false)
+	require.NoError(t, src.AddEventToStreamer(kept))
+
+	e, err := r.Streamer().GetEvent(context.Background())
+	require.NoError(t, err)
+	require.Same(t, kept, e)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	_, err = r.Streamer().GetEvent(context.Background())
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = NewRelay(src, RelayConfig{IncludeTableRegex: []string{"("}})
+	require.Error(t, err)
+}
@@ -0,0 +1,152 @@
+package replication
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// StartSyncWithGTIDFallback starts syncing from pos like StartSync, gset is the
+// GTID set executed up to pos. It is used with ResumeByGTIDOnFailover to resume
+// by GTID if pos is lost after a reconnect, before a PREVIOUS_GTIDS_EVENT is received.
+func (b *BinlogSyncer) StartSyncWithGTIDFallback(pos mysql.Position, gset mysql.GTIDSet) (*BinlogStreamer, error) {
+	if gset != nil {
+		b.resumeGset = gset.Clone()
+	}
+	return b.StartSync(pos)
+}
+
+// trackResumeGTIDs tracks the GTID set of the transactions received when
+// syncing by file and position, to resume from it on failover.
+func (b *BinlogSyncer) trackResumeGTIDs(e *BinlogEvent) error {
+	switch event := e.Event.(type) {
+	case *PreviousGTIDsEvent:
+		gset, err := mysql.ParseMysqlGTIDSet(event.GTIDSets)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		b.resumeGset = gset
+		b.resumeGTIDEvent = nil
+	case *GTIDEvent:
+		if b.resumeGset == nil {
+			break
+		}
+		if err := b.addResumeGTID(); err != nil {
+			return errors.Trace(err)
+		}
+		b.resumeGTIDEvent = event
+	case *XIDEvent:
+		return b.addResumeGTID()
+	case *QueryEvent:
+		// BEGIN starts a transaction, any other statement ends it (COMMIT, DDL)
+		if !strings.EqualFold(string(event.Query), "BEGIN") {
+			return b.addResumeGTID()
+		}
+	}
+	return nil
+}
+
+// addResumeGTID adds the GTID of the transaction just completed to the resume GTID set.
+func (b *BinlogSyncer) addResumeGTID() error {
+	// anonymous transactions (gtid_mode=OFF) have no GTID
+	if b.resumeGTIDEvent == nil || b.resumeGset == nil || b.resumeGTIDEvent.GNO == 0 {
+		b.resumeGTIDEvent = nil
+		return nil
+	}
+	u, err := uuid.FromBytes(b.resumeGTIDEvent.SID)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	b.resumeGset.(*mysql.MysqlGTIDSet).AddGTID(u, b.resumeGTIDEvent.GNO)
+	b.resumeGTIDEvent = nil
+	return nil
+}
+
+// prepareResumePos reconnects to re-sync from pos. If pos doesn't exist on the
+// server, because it is another server than before or its binary logs were reset,
+// it re-syncs from the tracked GTID set instead and keeps syncing by GTID.
+func (b *BinlogSyncer) prepareResumePos(pos mysql.Position) error {
+	if err := b.prepare(); err != nil {
+		return errors.Trace(err)
+	}
+
+	prevUUID := b.serverUUID
+	lost, err := b.resumePosLost(pos)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if !lost {
+		if pos.Pos < 4 {
+			pos.Pos = 4
+		}
+		return errors.Trace(b.writeBinlogDumpCommand(pos))
+	}
+
+	if b.resumeGset == nil {
+		return errors.Errorf("binlog position %s doesn't exist on server %s (was %s) and the GTID set to resume from is unknown",
+			pos, b.serverUUID, prevUUID)
+	}
+
+	b.cfg.Logger.Warn("binlog position doesn't exist on the server, re-sync from GTID set",
+		slog.String("position", pos.String()),
+		slog.String("server uuid", b.serverUUID),
+		slog.String("previous server uuid", prevUUID),
+		slog.String("GTID Set", b.resumeGset.String()))
+
+	b.prevGset = b.resumeGset
+	b.prevMySQLGTIDEvent = nil
+	b.currGset = nil
+	b.resumeGset = nil
+	b.resumeGTIDEvent = nil
+	return errors.Trace(b.writeBinlogDumpMysqlGTIDCommand(b.prevGset))
+}
+
+// resumePosLost reports whether pos can't be synced from on the connected
+// server. The first call only records the server UUID.
+func (b *BinlogSyncer) resumePosLost(pos mysql.Position) (bool, error) {
+	r, err := b.c.Execute("SELECT @@GLOBAL.server_uuid")
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	serverUUID, err := r.GetString(0, 0)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+
+	prevUUID := b.serverUUID
+	b.serverUUID = serverUUID
+	if prevUUID == "" {
+		return false, nil
+	}
+	if prevUUID != serverUUID {
+		return true, nil
+	}
+
+	r, err = b.c.Execute("SHOW BINARY LOGS")
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	for i := 0; i < r.RowNumber(); i++ {
+		name, err := r.GetString(i, 0)
+		if err != nil {
+			return false, errors.Trace(err)
+		}
+		if name != pos.Name {
+			continue
+		}
+		size, err := r.GetUint(i, 1)
+		if err != nil {
+			return false, errors.Trace(err)
+		}
+		return uint64(pos.Pos) > size, nil
+	}
+	return true, nil
+}
+
+func (b *BinlogSyncer) resumeByGTIDOnFailover() bool {
+	return b.cfg.ResumeByGTIDOnFailover && b.cfg.Flavor != mysql.MariaDBFlavor
+}
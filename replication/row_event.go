@@ -893,7 +893,7 @@ const RowsEventStmtEndFlag = 0x01
 // - mysql.MYSQL_TYPE_STRING: string
 // - mysql.MYSQL_TYPE_JSON: []byte / *replication.JsonDiff
 // - mysql.MYSQL_TYPE_GEOMETRY: []byte
-// - mysql.MYSQL_TYPE_VECTOR: []byte
+// - mysql.MYSQL_TYPE_VECTOR: []byte / []float32
 type RowsEvent struct {
 	// 0, 1, 2
 	Version int
@@ -945,11 +945,21 @@ type RowsEvent struct {
 	Rows           [][]interface{}
 	SkippedColumns [][]int
 
+	// Query is the statement that caused the row changes, only set with
+	// BinlogSyncerConfig.AttachRowsQuery and if the server logs it.
+	Query []byte
+
 	parseTime                bool
 	timestampStringLocation  *time.Location
 	useDecimal               bool
 	useFloatWithTrailingZero bool
+	useFloat32Vector         bool
 	ignoreJSONDecodeErr      bool
+	convertCharset           bool
+	// collations of the character columns of Table to convert, for convertCharset
+	collations map[int]uint64
+
+	tableColumnNamesFunc func(schema, table string) ([]string, error)
 }
 
 // EnumRowsEventType is an abridged type describing the operation which triggered the given RowsEvent.
@@ -1158,6 +1168,84 @@ func (e *RowsEvent) Type() EnumRowsEventType {
 	}
 }
 
+// columnNotPresent is the type of ColumnNotPresent.
+type columnNotPresent struct{}
+
+func (columnNotPresent) String() string {
+	return "<not present>"
+}
+
+// ColumnNotPresent is the value NamedRows uses for a column which is not part of a row image,
+// e.g. a column that was not changed by an UPDATE with binlog_row_image=MINIMAL.
+// Unlike nil, which is a NULL value, it means that the value is unknown.
+var ColumnNotPresent interface{} = columnNotPresent{}
+
+// IsColumnPresent reports whether column col is part of the row image Rows[row].
+func (e *RowsEvent) IsColumnPresent(row int, col int) bool {
+	if col < 0 || col >= int(e.ColumnCount) {
+		return false
+	}
+	if row >= len(e.SkippedColumns) {
+		return true
+	}
+	for _, skipped := range e.SkippedColumns[row] {
+		if skipped == col {
+			return false
+		}
+	}
+	return true
+}
+
+// ColumnNames returns the full column list of the table, in column order.
+// The names are taken from the TableMapEvent if binlog_row_metadata=FULL,
+// otherwise from the function set with BinlogParser.SetTableColumnNamesFunc.
+func (e *RowsEvent) ColumnNames() ([]string, error) {
+	if e.Table == nil {
+		return nil, errors.New("rows event has no table map event")
+	}
+	if names := e.Table.ColumnNameString(); len(names) > 0 {
+		return names, nil
+	}
+	if e.tableColumnNamesFunc == nil {
+		return nil, errors.Errorf("no column names for table %s.%s, binlog_row_metadata is not FULL and no TableColumnNamesFunc is set",
+			e.Table.Schema, e.Table.Table)
+	}
+	names, err := e.tableColumnNamesFunc(string(e.Table.Schema), string(e.Table.Table))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return names, nil
+}
+
+// NamedRows returns Rows as maps from column name to value. Every column returned by ColumnNames
+// is in each map, so the mapping is stable whatever the binlog_row_image is. Columns which are not part
+// of the row image, or which are unknown to the event because the table has more columns than
+// ColumnCount, are set to ColumnNotPresent.
+func (e *RowsEvent) NamedRows() ([]map[string]interface{}, error) {
+	names, err := e.ColumnNames()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) < int(e.ColumnCount) {
+		return nil, errors.Errorf("table %s.%s has %d column names, but rows event has %d columns",
+			e.Table.Schema, e.Table.Table, len(names), e.ColumnCount)
+	}
+
+	rows := make([]map[string]interface{}, len(e.Rows))
+	for i, row := range e.Rows {
+		m := make(map[string]interface{}, len(names))
+		for j, name := range names {
+			if j < len(row) && e.IsColumnPresent(i, j) {
+				m[name] = row[j]
+			} else {
+				m[name] = ColumnNotPresent
+			}
+		}
+		rows[i] = m
+	}
+	return rows, nil
+}
+
 func isBitSet(bitmap []byte, i int) bool {
 	return bitmap[i>>3]&(1<<(uint(i)&7)) > 0
 }
@@ -1237,11 +1325,51 @@ func (e *RowsEvent) decodeImage(data []byte, bitmap []byte, rowImageType EnumRow
 		pos += n
 	}
 
+	if e.convertCharset {
+		if err := e.convertRowCharset(row); err != nil {
+			return 0, err
+		}
+	}
+
 	e.Rows = append(e.Rows, row)
 	e.SkippedColumns = append(e.SkippedColumns, skips)
 	return pos, nil
 }
 
+// convertRowCharset converts the values of the character columns of row to
+// UTF-8, see BinlogParser.SetConvertCharset.
+func (e *RowsEvent) convertRowCharset(row []interface{}) error {
+	if e.collations == nil {
+		// only the columns to convert
+		e.collations = make(map[int]uint64)
+		for i, collation := range e.Table.CollationMap() {
+			if _, ok := mysql.CollationEncoding(collation); ok {
+				e.collations[i] = collation
+			}
+		}
+	}
+	for i, collation := range e.collations {
+		if i >= len(row) {
+			continue
+		}
+		switch v := row[i].(type) {
+		case string:
+			b, err := mysql.ToUTF8(collation, utils.StringToByteSlice(v))
+			if err != nil {
+				return errors.Annotatef(err, "convert column %d to UTF-8", i)
+			}
+			row[i] = string(b)
+		case []byte:
+			b, err := mysql.ToUTF8(collation, v)
+			if err != nil {
+				return errors.Annotatef(err, "convert column %d to UTF-8", i)
+			}
+			row[i] = b
+		}
+	}
+	return nil
+}
+
 func (e *RowsEvent) parseFracTime(t interface{}) interface{} {
 	v, ok := t.(fracTime)
 	if !ok {
@@ -1449,7 +1577,13 @@ func (e *RowsEvent) decodeValue(data []byte, tp byte, meta uint16, isPartial boo
 		// see https://github.com/twpayne/go-geom or https://github.com/paulmach/go.geo
 		v, n, err = decodeBlob(data, meta)
 	case mysql.MYSQL_TYPE_VECTOR:
-		v, n, err = decodeBlob(data, meta)
+		var b []byte
+		b, n, err = decodeBlob(data, meta)
+		if err == nil && e.useFloat32Vector {
+			v, err = mysql.DecodeVector(b)
+		} else {
+			v = b
+		}
 	default:
 		err = fmt.Errorf("unsupport type %d in binlog and don't know how to handle", tp)
 	}
@@ -1855,6 +1989,9 @@ func (e *RowsEvent) Dump(w io.Writer) {
 	fmt.Fprintf(w, "Flags: %d\n", e.Flags)
 	fmt.Fprintf(w, "Column count: %d\n", e.ColumnCount)
 	fmt.Fprintf(w, "NDB data: %s\n", e.NdbData)
+	if len(e.Query) > 0 {
+		fmt.Fprintf(w, "Query: %s\n", e.Query)
+	}
 	fmt.Fprintf(w, "Event type: %s (%s)", e.Type(), e.eventType)
 
 	fmt.Fprintf(w, "Values:\n")
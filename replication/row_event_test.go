@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/schema"
 )
 
 func TestDecodeDecimal(t *testing.T) {
@@ -1571,3 +1572,154 @@ func BenchmarkInt(b *testing.B) {
 		}
 	}
 }
+
+func TestRowsEventNamedRowsMinimalImage(t *testing.T) {
+	// CREATE TABLE t (id INT PRIMARY KEY, a INT, b INT) with binlog_row_image=MINIMAL
+	// UPDATE t SET b = 30 WHERE id = 1
+	tableMapEvent := &TableMapEvent{
+		TableID:     1,
+		Schema:      []byte("db"),
+		Table:       []byte("t"),
+		ColumnCount: 3,
+		ColumnType:  []byte{mysql.MYSQL_TYPE_LONG, mysql.MYSQL_TYPE_LONG, mysql.MYSQL_TYPE_LONG},
+		ColumnMeta:  []uint16{0, 0, 0},
+	}
+
+	rows := &RowsEvent{
+		Version:     2,
+		tableIDSize: 6,
+		tables:      map[uint64]*TableMapEvent{1: tableMapEvent},
+		needBitmap2: true,
+		eventType:   UPDATE_ROWS_EVENTv2,
+	}
+
+	data := []byte{
+		1, 0, 0, 0, 0, 0, // table id
+		0, 0, // flags
+		2, 0, // extra data length
+		3,                // column count
+		0x01,             // before image: id
+		0x04,             // after image: b
+		0x00, 1, 0, 0, 0, // before image row
+		0x00, 30, 0, 0, 0, // after image row
+	}
+	require.NoError(t, rows.Decode(data))
+	require.True(t, rows.IsColumnPresent(0, 0))
+	require.False(t, rows.IsColumnPresent(0, 2))
+	require.False(t, rows.IsColumnPresent(1, 0))
+	require.True(t, rows.IsColumnPresent(1, 2))
+
+	_, err := rows.NamedRows()
+	require.Error(t, err)
+
+	// The table gained a column after the event was written.
+	rows.tableColumnNamesFunc = func(schema, table string) ([]string, error) {
+		require.Equal(t, "db", schema)
+		require.Equal(t, "t", table)
+		return []string{"id", "a", "b", "c"}, nil
+	}
+	named, err := rows.NamedRows()
+	require.NoError(t, err)
+	require.Equal(t, []map[string]interface{}{
+		{"id": int32(1), "a": ColumnNotPresent, "b": ColumnNotPresent, "c": ColumnNotPresent},
+		{"id": ColumnNotPresent, "a": ColumnNotPresent, "b": int32(30), "c": ColumnNotPresent},
+	}, named)
+
+	// Column names in the table map event take precedence.
+	tableMapEvent.ColumnName = [][]byte{[]byte("x"), []byte("y"), []byte("z")}
+	named, err = rows.NamedRows()
+	require.NoError(t, err)
+	require.Equal(t, int32(30), named[1]["z"])
+	require.Equal(t, ColumnNotPresent, named[1]["y"])
+}
+
+func TestDecodeVector(t *testing.T) {
+	vec := []float32{0.5, 1, -1}
+	data := append([]byte{12, 0, 0, 0}, mysql.EncodeVector(vec)...)
+
+	e := &RowsEvent{}
+	v, n, err := e.decodeValue(data, mysql.MYSQL_TYPE_VECTOR, 4, false)
+	require.NoError(t, err)
+	require.Equal(t, len(data), n)
+	require.Equal(t, data[4:], v)
+
+	e.useFloat32Vector = true
+	v, n, err = e.decodeValue(data, mysql.MYSQL_TYPE_VECTOR, 4, false)
+	require.NoError(t, err)
+	require.Equal(t, len(data), n)
+	require.Equal(t, vec, v)
+}
+
+func TestTableMapSchemaTable(t *testing.T) {
+	/*
+		CREATE TABLE `t` (
+			`id` bigint unsigned NOT NULL,
+			`name` varchar(64) NOT NULL,
+			`data` varbinary(64),
+			`e` enum('a','b') COLLATE utf8mb4_general_ci,
+			`ts` timestamp(3),
+			`amount` mediumint,
+			PRIMARY KEY (`id`, `name`)
+		) DEFAULT CHARSET=utf8mb4
+	*/
+	tableMapEvent := &TableMapEvent{
+		Schema:      []byte("db"),
+		Table:       []byte("t"),
+		ColumnCount: 6,
+		ColumnType: []byte{
+			mysql.MYSQL_TYPE_LONGLONG, mysql.MYSQL_TYPE_VARCHAR, mysql.MYSQL_TYPE_VARCHAR,
+			mysql.MYSQL_TYPE_STRING, mysql.MYSQL_TYPE_TIMESTAMP2, mysql.MYSQL_TYPE_INT24,
+		},
+		ColumnMeta: []uint16{0, 256, 64, uint16(mysql.MYSQL_TYPE_ENUM)<<8 | 1, 3, 0},
+	}
+
+	_, err := tableMapEvent.SchemaTable()
+	require.Error(t, err)
+
+	tableMapEvent.ColumnName = [][]byte{
+		[]byte("id"), []byte("name"), []byte("data"), []byte("e"), []byte("ts"), []byte("amount"),
+	}
+	tableMapEvent.SignednessBitmap = []byte{0x80}
+	tableMapEvent.DefaultCharset = []uint64{255, 1, 63}
+	tableMapEvent.EnumSetDefaultCharset = []uint64{45}
+	tableMapEvent.EnumStrValue = [][][]byte{{[]byte("a"), []byte("b")}}
+	tableMapEvent.PrimaryKey = []uint64{0, 1}
+
+	ta, err := tableMapEvent.SchemaTable()
+	require.NoError(t, err)
+	require.Equal(t, "db", ta.Schema)
+	require.Equal(t, "t", ta.Name)
+	require.Equal(t, []schema.TableColumn{
+		{Name: "id", Type: schema.TYPE_NUMBER, IsUnsigned: true},
+		{Name: "name", Type: schema.TYPE_STRING, Collation: "utf8mb4_0900_ai_ci"},
+		{Name: "data", Type: schema.TYPE_BINARY, Collation: "binary"},
+		{Name: "e", Type: schema.TYPE_ENUM, Collation: "utf8mb4_general_ci", EnumValues: []string{"a", "b"}},
+		{Name: "ts", Type: schema.TYPE_TIMESTAMP},
+		{Name: "amount", Type: schema.TYPE_MEDIUM_INT},
+	}, ta.Columns)
+	require.Equal(t, []int{0}, ta.UnsignedColumns)
+	require.Equal(t, []int{0, 1}, ta.PKColumns)
+	require.Len(t, ta.Indexes, 1)
+	require.Equal(t, []string{"id", "name"}, ta.Indexes[0].Columns)
+}
+
+func TestRowsEventConvertCharset(t *testing.T) {
+	tableMapEvent := &TableMapEvent{
+		ColumnCount: 4,
+		ColumnType: []byte{
+			mysql.MYSQL_TYPE_LONGLONG, mysql.MYSQL_TYPE_VARCHAR, mysql.MYSQL_TYPE_BLOB, mysql.MYSQL_TYPE_VARCHAR,
+		},
+		ColumnMeta: []uint16{0, 64, 2, 64},
+		// latin1_swedish_ci, the blob is binary and the last column utf8mb4
+		DefaultCharset: []uint64{8, 1, 63, 2, 255},
+	}
+	e := &RowsEvent{Table: tableMapEvent, convertCharset: true}
+
+	row := []interface{}{int64(1), "caf\xe9", []byte("\xe9"), "café"}
+	require.NoError(t, e.convertRowCharset(row))
+	require.Equal(t, []interface{}{int64(1), "café", []byte("\xe9"), "café"}, row)
+
+	row = []interface{}{int64(2), nil, nil, nil}
+	require.NoError(t, e.convertRowCharset(row))
+	require.Equal(t, []interface{}{int64(2), nil, nil, nil}, row)
+}
@@ -0,0 +1,161 @@
+package replication
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// deferredRows is a RowsEvent whose header is decoded but not the rows yet.
+type deferredRows struct {
+	event *RowsEvent
+	pos   int
+	data  []byte
+}
+
+type rowsDecodeJob struct {
+	e    *BinlogEvent
+	rows *deferredRows
+	done chan error
+}
+
+// rowsDecoder decodes the rows of RowsEvents in worker goroutines while the
+// syncer goroutine reads the next events. A dispatcher goroutine handles the
+// events in binlog order, waiting for the rows of each RowsEvent to be decoded.
+type rowsDecoder struct {
+	b *BinlogSyncer
+	s *BinlogStreamer
+
+	jobs  chan *rowsDecodeJob
+	queue chan *rowsDecodeJob
+
+	// pending counts the queued events not handled by the dispatcher yet
+	pending sync.WaitGroup
+	workers sync.WaitGroup
+	stopped chan struct{}
+
+	// failed is closed if the dispatcher fails, the error is sent to the streamer
+	failed chan struct{}
+}
+
+func newRowsDecoder(b *BinlogSyncer, s *BinlogStreamer, concurrency int) *rowsDecoder {
+	d := &rowsDecoder{
+		b:       b,
+		s:       s,
+		jobs:    make(chan *rowsDecodeJob, concurrency),
+		queue:   make(chan *rowsDecodeJob, 2*concurrency),
+		stopped: make(chan struct{}),
+		failed:  make(chan struct{}),
+	}
+
+	d.workers.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go d.worker()
+	}
+	go d.dispatch()
+
+	return d
+}
+
+// deferRowsDecode is the rows event decode func of the parser when the rows
+// are decoded concurrently, it only decodes the header.
+func (b *BinlogSyncer) deferRowsDecode(e *RowsEvent, data []byte) error {
+	pos, err := e.DecodeHeader(data)
+	if err != nil {
+		return err
+	}
+	b.deferredRows = &deferredRows{event: e, pos: pos, data: data}
+	return nil
+}
+
+// add queues the event just parsed. It returns false if the events can't be
+// handled anymore, the error was already sent to the streamer.
+func (d *rowsDecoder) add(e *BinlogEvent) bool {
+	job := &rowsDecodeJob{e: e}
+	if rows := d.b.deferredRows; rows != nil && rows.event == e.Event {
+		d.b.deferredRows = nil
+		job.rows = rows
+		job.done = make(chan error, 1)
+
+		select {
+		case d.jobs <- job:
+		case <-d.failed:
+			return false
+		}
+	}
+
+	d.pending.Add(1)
+	select {
+	case d.queue <- job:
+		return true
+	case <-d.failed:
+		d.pending.Done()
+		return false
+	}
+}
+
+// wait waits until the queued events are handled, it returns false if the
+// dispatcher failed.
+func (d *rowsDecoder) wait() bool {
+	d.pending.Wait()
+	select {
+	case <-d.failed:
+		return false
+	default:
+		return true
+	}
+}
+
+// close stops the goroutines after the queued events are handled.
+func (d *rowsDecoder) close() {
+	close(d.queue)
+	close(d.jobs)
+	<-d.stopped
+	d.workers.Wait()
+}
+
+func (d *rowsDecoder) worker() {
+	defer d.workers.Done()
+
+	for job := range d.jobs {
+		err := job.rows.event.DecodeData(job.rows.pos, job.rows.data)
+		if err != nil {
+			err = &EventError{job.e.Header, err.Error(), job.rows.data}
+		}
+		job.done <- err
+	}
+}
+
+func (d *rowsDecoder) dispatch() {
+	defer close(d.stopped)
+
+	failed := false
+	for job := range d.queue {
+		if !failed {
+			if err := d.handle(job); err != nil {
+				failed = true
+				d.s.closeWithError(err)
+				close(d.failed)
+			}
+		} else if job.done != nil {
+			<-job.done
+		}
+		d.pending.Done()
+	}
+}
+
+func (d *rowsDecoder) handle(job *rowsDecodeJob) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("panic %v\nstack: %s", e, mysql.Pstack())
+		}
+	}()
+
+	if job.done != nil {
+		if err = <-job.done; err != nil {
+			return err
+		}
+	}
+	return d.b.handleEventAndACK(d.s, job.e, false)
+}
@@ -0,0 +1,228 @@
+package replication
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/pingcap/errors"
+)
+
+// UserVarType is the type of the value of a user variable, Item_result of
+// MySQL.
+type UserVarType byte
+
+const (
+	USER_VAR_STRING UserVarType = iota
+	USER_VAR_REAL
+	USER_VAR_INT
+	USER_VAR_ROW
+	USER_VAR_DECIMAL
+)
+
+// userVarUnsigned is the flag of the unsigned integers.
+const userVarUnsigned = 0x01
+
+// RandEvent is written before a statement calling RAND(), with the seeds of
+// the random number generator.
+type RandEvent struct {
+	Seed1 uint64
+	Seed2 uint64
+}
+
+func (e *RandEvent) Decode(data []byte) error {
+	if len(data) < 16 {
+		return errors.Errorf("invalid rand event length %d", len(data))
+	}
+	e.Seed1 = binary.LittleEndian.Uint64(data)
+	e.Seed2 = binary.LittleEndian.Uint64(data[8:])
+	return nil
+}
+
+func (e *RandEvent) Dump(w io.Writer) {
+	fmt.Fprintf(w, "Seed1: %d\n", e.Seed1)
+	fmt.Fprintf(w, "Seed2: %d\n", e.Seed2)
+	fmt.Fprintln(w)
+}
+
+// UserVarEvent is written before a statement using a user variable, with its
+// value.
+type UserVarEvent struct {
+	Name   []byte
+	IsNull bool
+
+	Type UserVarType
+	// Charset is the collation ID of a string value, see mysql.ToUTF8.
+	Charset  uint32
+	Unsigned bool
+	// Value is the decoded value, nil if IsNull: a string for USER_VAR_STRING,
+	// a float64 for USER_VAR_REAL, an int64 or a uint64 if Unsigned for
+	// USER_VAR_INT, and a string or a decimal.Decimal with
+	// BinlogSyncerConfig.UseDecimal for USER_VAR_DECIMAL.
+	Value interface{}
+	// RawValue is the value as written by the server.
+	RawValue []byte
+
+	useDecimal bool
+}
+
+func (e *UserVarEvent) Decode(data []byte) error {
+	if len(data) < 5 {
+		return errors.Errorf("invalid user var event length %d", len(data))
+	}
+	pos := 0
+	nameLength := int(binary.LittleEndian.Uint32(data))
+	pos += 4
+	if len(data) < pos+nameLength+1 {
+		return errors.Errorf("invalid user var event length %d", len(data))
+	}
+	e.Name = data[pos : pos+nameLength]
+	pos += nameLength
+
+	e.IsNull = data[pos] != 0
+	pos++
+	if e.IsNull {
+		return nil
+	}
+
+	if len(data) < pos+9 {
+		return errors.Errorf("invalid user var event length %d", len(data))
+	}
+	e.Type = UserVarType(data[pos])
+	pos++
+	e.Charset = binary.LittleEndian.Uint32(data[pos:])
+	pos += 4
+	valueLength := int(binary.LittleEndian.Uint32(data[pos:]))
+	pos += 4
+	if len(data) < pos+valueLength {
+		return errors.Errorf("invalid user var event length %d", len(data))
+	}
+	e.RawValue = data[pos : pos+valueLength]
+	pos += valueLength
+
+	// the flags are written since MySQL 5.5
+	if pos < len(data) {
+		e.Unsigned = data[pos]&userVarUnsigned > 0
+	}
+
+	return errors.Trace(e.decodeValue())
+}
+
+func (e *UserVarEvent) decodeValue() error {
+	v := e.RawValue
+	switch e.Type {
+	case USER_VAR_STRING:
+		e.Value = string(v)
+	case USER_VAR_REAL:
+		if len(v) < 8 {
+			return errors.Errorf("invalid real user var length %d", len(v))
+		}
+		e.Value = math.Float64frombits(binary.LittleEndian.Uint64(v))
+	case USER_VAR_INT:
+		if len(v) < 8 {
+			return errors.Errorf("invalid int user var length %d", len(v))
+		}
+		if e.Unsigned {
+			e.Value = binary.LittleEndian.Uint64(v)
+		} else {
+			e.Value = int64(binary.LittleEndian.Uint64(v))
+		}
+	case USER_VAR_DECIMAL:
+		// precision and scale, then the decimal as in the rows events
+		if len(v) < 2 {
+			return errors.Errorf("invalid decimal user var length %d", len(v))
+		}
+		d, _, err := decodeDecimal(v[2:], int(v[0]), int(v[1]), e.useDecimal)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		e.Value = d
+	default:
+		return errors.Errorf("unsupported user var type %d", e.Type)
+	}
+	return nil
+}
+
+func (e *UserVarEvent) Dump(w io.Writer) {
+	fmt.Fprintf(w, "Name: %s\n", e.Name)
+	if e.IsNull {
+		fmt.Fprintf(w, "Value: NULL\n")
+	} else {
+		fmt.Fprintf(w, "Type: %d\n", e.Type)
+		fmt.Fprintf(w, "Charset: %d\n", e.Charset)
+		fmt.Fprintf(w, "Value: %v\n", e.Value)
+	}
+	fmt.Fprintln(w)
+}
+
+// StatementContext is the context of a statement written before its
+// QueryEvent in statement-based replication: the values of LAST_INSERT_ID()
+// and of the auto increment, the seeds of RAND() and the user variables used.
+type StatementContext struct {
+	IntVars  []*IntVarEvent
+	Rand     *RandEvent
+	UserVars []*UserVarEvent
+}
+
+// LastInsertID returns the value of LAST_INSERT_ID() of the statement, false
+// if it is not used.
+func (c *StatementContext) LastInsertID() (uint64, bool) {
+	return c.intVar(LAST_INSERT_ID)
+}
+
+// InsertID returns the first auto increment value of the statement, false if
+// it doesn't generate one.
+func (c *StatementContext) InsertID() (uint64, bool) {
+	return c.intVar(INSERT_ID)
+}
+
+func (c *StatementContext) intVar(t IntVarEventType) (uint64, bool) {
+	for _, e := range c.IntVars {
+		if e.Type == t {
+			return e.Value, true
+		}
+	}
+	return 0, false
+}
+
+// UserVar returns the event of the user variable name, nil if the statement
+// doesn't use it.
+func (c *StatementContext) UserVar(name string) *UserVarEvent {
+	for _, e := range c.UserVars {
+		if string(e.Name) == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// trackStatementContext collects the context events and attaches them to the
+// following QueryEvent.
+func (p *BinlogParser) trackStatementContext(e Event) {
+	switch ev := e.(type) {
+	case *IntVarEvent:
+		c := p.statementContext()
+		c.IntVars = append(c.IntVars, ev)
+	case *RandEvent:
+		p.statementContext().Rand = ev
+	case *UserVarEvent:
+		// copy, the event data may be reused after parsing
+		ev.Name = append([]byte(nil), ev.Name...)
+		ev.RawValue = append([]byte(nil), ev.RawValue...)
+		c := p.statementContext()
+		c.UserVars = append(c.UserVars, ev)
+	case *QueryEvent:
+		ev.Context = p.stmtContext
+		p.stmtContext = nil
+	case *XIDEvent, *GTIDEvent, *MariadbGTIDEvent:
+		p.stmtContext = nil
+	}
+}
+
+func (p *BinlogParser) statementContext() *StatementContext {
+	if p.stmtContext == nil {
+		p.stmtContext = new(StatementContext)
+	}
+	return p.stmtContext
+}
@@ -0,0 +1,90 @@
+package replication
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/utils"
+)
+
+// SyncStatus is the replication status of a BinlogSyncer, see BinlogSyncer.Status.
+type SyncStatus struct {
+	// Position is the position after the last event received.
+	Position mysql.Position
+	// LastGTID is the GTID of the last transaction received, nil if no GTID
+	// event was received.
+	LastGTID mysql.GTIDSet
+	// LastEventTime is the timestamp of the last event received. For a heartbeat,
+	// which the server only sends when the syncer is caught up, it is the time
+	// the heartbeat was received.
+	LastEventTime time.Time
+	// Lag is the wall clock time since LastEventTime, 0 if no event was received.
+	Lag time.Duration
+}
+
+type syncStatus struct {
+	sync.Mutex
+
+	pos           mysql.Position
+	lastGTID      interface{ GTIDNext() (mysql.GTIDSet, error) }
+	lastEventTime time.Time
+}
+
+func (s *syncStatus) update(e *BinlogEvent, pos mysql.Position) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.pos = pos
+	switch event := e.Event.(type) {
+	case *GTIDEvent:
+		s.lastGTID = event
+	case *MariadbGTIDEvent:
+		s.lastGTID = event
+	}
+
+	switch {
+	case e.Header.EventType == HEARTBEAT_EVENT || e.Header.EventType == HEARTBEAT_LOG_EVENT_V2:
+		s.lastEventTime = utils.Now()
+	case e.Header.Timestamp > 0:
+		// the fake rotate and format description events sent first have no timestamp
+		s.lastEventTime = time.Unix(int64(e.Header.Timestamp), 0)
+	}
+}
+
+// Status returns the replication status of the syncer. It can be called from
+// any goroutine while syncing. Enable HeartbeatPeriod so the lag doesn't grow
+// while the server has no events to send.
+func (b *BinlogSyncer) Status() (SyncStatus, error) {
+	b.status.Lock()
+	defer b.status.Unlock()
+
+	status := SyncStatus{
+		Position:      b.status.pos,
+		LastEventTime: b.status.lastEventTime,
+	}
+	if !status.LastEventTime.IsZero() {
+		status.Lag = max(utils.Now().Sub(status.LastEventTime), 0)
+	}
+	if b.status.lastGTID != nil {
+		gtid, err := b.status.lastGTID.GTIDNext()
+		if err != nil {
+			return status, errors.Trace(err)
+		}
+		status.LastGTID = gtid
+	}
+	return status, nil
+}
+
+// Lag returns the replication lag of the syncer, see SyncStatus.Lag.
+func (b *BinlogSyncer) Lag() time.Duration {
+	b.status.Lock()
+	defer b.status.Unlock()
+
+	if b.status.lastEventTime.IsZero() {
+		return 0
+	}
+	return max(utils.Now().Sub(b.status.lastEventTime), 0)
+}
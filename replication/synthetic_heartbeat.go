@@ -0,0 +1,84 @@
+package replication
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// SyntheticHeartbeatEvent is returned by the BinlogStreamer when no event has
+// been read for BinlogSyncerConfig.SyntheticHeartbeatPeriod, so the consumers
+// can commit their position while the server is idle. It isn't sent by the
+// server: its header is a HEARTBEAT_EVENT with LOG_EVENT_ARTIFICIAL_F, the time
+// it was emitted and the position of Position, and it has no raw data.
+type SyntheticHeartbeatEvent struct {
+	// Position is the position after the last event read, the file of the last
+	// RotateEvent.
+	Position mysql.Position
+	// LastEventTime is the timestamp of the last event read, not synthetic.
+	LastEventTime time.Time
+}
+
+func (e *SyntheticHeartbeatEvent) Dump(w io.Writer) {
+	fmt.Fprintf(w, "Position: %s\n", e.Position)
+	fmt.Fprintf(w, "Last event time: %s\n", e.LastEventTime)
+	fmt.Fprintln(w)
+}
+
+func (e *SyntheticHeartbeatEvent) Decode(data []byte) error {
+	return nil
+}
+
+// SetSyntheticHeartbeatPeriod makes GetEvent return a SyntheticHeartbeatEvent
+// when no event has been read for period, 0 to disable it, the default. The
+// syncer sets BinlogSyncerConfig.SyntheticHeartbeatPeriod, it must be called
+// before the first GetEvent for the streamers created by NewBinlogStreamer.
+func (s *BinlogStreamer) SetSyntheticHeartbeatPeriod(period time.Duration) {
+	s.heartbeatPeriod = period
+}
+
+// heartbeatTimer returns the channel receiving when the synthetic heartbeat is
+// due, nil if it is disabled, and the function to stop its timer.
+func (s *BinlogStreamer) heartbeatTimer() (<-chan time.Time, func() bool) {
+	if s.heartbeatPeriod <= 0 {
+		return nil, func() bool { return false }
+	}
+	if s.lastRead.IsZero() {
+		s.lastRead = time.Now()
+	}
+	t := time.NewTimer(s.heartbeatPeriod - time.Since(s.lastRead))
+	return t.C, t.Stop
+}
+
+// track keeps the position after e, for the synthetic heartbeats.
+func (s *BinlogStreamer) track(e *BinlogEvent) {
+	if s.heartbeatPeriod <= 0 {
+		return
+	}
+	s.lastRead = time.Now()
+	if e.Header == nil {
+		return
+	}
+	trackPosition(&s.lastPos, e)
+	if e.Header.Timestamp > 0 {
+		s.lastEventTime = time.Unix(int64(e.Header.Timestamp), 0)
+	}
+}
+
+// syntheticHeartbeat returns a SyntheticHeartbeatEvent of the position of the
+// last event read.
+func (s *BinlogStreamer) syntheticHeartbeat() *BinlogEvent {
+	now := time.Now()
+	s.lastRead = now
+	return &BinlogEvent{
+		Header: &EventHeader{
+			Timestamp: uint32(now.Unix()),
+			EventType: HEARTBEAT_EVENT,
+			LogPos:    s.lastPos.Pos,
+			Flags:     LOG_EVENT_ARTIFICIAL_F,
+		},
+		Event: &SyntheticHeartbeatEvent{Position: s.lastPos, LastEventTime: s.lastEventTime},
+	}
+}
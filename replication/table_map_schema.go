@@ -0,0 +1,126 @@
+package replication
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/pkg/parser/charset"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/schema"
+)
+
+// binaryCollationID is the id of the binary collation, used by the binary,
+// varbinary and blob columns.
+const binaryCollationID = 63
+
+// SchemaTable returns the table described by the optional metadata of the event,
+// so the rows can be handled without querying the source for the schema.
+//
+// The column names are required, so the source must log with
+// binlog_row_metadata=FULL (MySQL 8.0.1+ or MariaDB 10.5+). The signedness, the
+// collations, the enum and set values and the primary key are filled when they
+// are logged. RawType, the sizes and the generated and auto increment flags
+// of the columns are not available in the binlog, and are left empty.
+func (e *TableMapEvent) SchemaTable() (*schema.Table, error) {
+	names := e.ColumnNameString()
+	if len(names) != int(e.ColumnCount) {
+		return nil, errors.Errorf("table %s.%s has no column names in its table map event, binlog_row_metadata is not FULL",
+			e.Schema, e.Table)
+	}
+
+	unsigned := e.UnsignedMap()
+	collations := e.CollationMap()
+	enumSetCollations := e.EnumSetCollationMap()
+	enumValues := e.EnumStrValueMap()
+	setValues := e.SetStrValueMap()
+	geometryTypes := e.GeometryTypeMap()
+
+	t := &schema.Table{
+		Schema:  string(e.Schema),
+		Name:    string(e.Table),
+		Columns: make([]schema.TableColumn, e.ColumnCount),
+		Indexes: make([]*schema.Index, 0, 1),
+	}
+	for i := range t.Columns {
+		col := &t.Columns[i]
+		col.Name = names[i]
+
+		collationID, ok := collations[i]
+		if !ok {
+			collationID, ok = enumSetCollations[i]
+		}
+		if ok {
+			if collation, err := charset.GetCollationByID(int(collationID)); err == nil {
+				col.Collation = collation.Name
+			}
+		}
+
+		switch e.realType(i) {
+		case mysql.MYSQL_TYPE_TINY, mysql.MYSQL_TYPE_SHORT, mysql.MYSQL_TYPE_LONG, mysql.MYSQL_TYPE_LONGLONG,
+			mysql.MYSQL_TYPE_YEAR:
+			col.Type = schema.TYPE_NUMBER
+		case mysql.MYSQL_TYPE_INT24:
+			col.Type = schema.TYPE_MEDIUM_INT
+		case mysql.MYSQL_TYPE_FLOAT, mysql.MYSQL_TYPE_DOUBLE:
+			col.Type = schema.TYPE_FLOAT
+		case mysql.MYSQL_TYPE_NEWDECIMAL, mysql.MYSQL_TYPE_DECIMAL:
+			col.Type = schema.TYPE_DECIMAL
+		case mysql.MYSQL_TYPE_ENUM:
+			col.Type = schema.TYPE_ENUM
+			col.EnumValues = enumValues[i]
+		case mysql.MYSQL_TYPE_SET:
+			col.Type = schema.TYPE_SET
+			col.SetValues = setValues[i]
+		case mysql.MYSQL_TYPE_DATETIME, mysql.MYSQL_TYPE_DATETIME2:
+			col.Type = schema.TYPE_DATETIME
+		case mysql.MYSQL_TYPE_TIMESTAMP, mysql.MYSQL_TYPE_TIMESTAMP2:
+			col.Type = schema.TYPE_TIMESTAMP
+		case mysql.MYSQL_TYPE_NEWDATE:
+			col.Type = schema.TYPE_DATE
+		case mysql.MYSQL_TYPE_TIME, mysql.MYSQL_TYPE_TIME2:
+			col.Type = schema.TYPE_TIME
+		case mysql.MYSQL_TYPE_BIT:
+			col.Type = schema.TYPE_BIT
+		case mysql.MYSQL_TYPE_JSON:
+			col.Type = schema.TYPE_JSON
+		case mysql.MYSQL_TYPE_GEOMETRY:
+			// like schema.Table.AddColumn, only point and multipoint are points
+			if gt, ok := geometryTypes[i]; ok && (gt == 1 || gt == 4) {
+				col.Type = schema.TYPE_POINT
+			} else {
+				col.Type = schema.TYPE_STRING
+			}
+		case mysql.MYSQL_TYPE_STRING, mysql.MYSQL_TYPE_VARCHAR, mysql.MYSQL_TYPE_VAR_STRING:
+			if ok && collationID == binaryCollationID {
+				col.Type = schema.TYPE_BINARY
+			} else {
+				col.Type = schema.TYPE_STRING
+			}
+		default:
+			col.Type = schema.TYPE_STRING
+		}
+
+		if unsigned[i] {
+			col.IsUnsigned = true
+			t.UnsignedColumns = append(t.UnsignedColumns, i)
+		}
+	}
+
+	if len(e.PrimaryKey) > 0 {
+		pk := &schema.Index{
+			Name:    "PRIMARY",
+			Columns: make([]string, 0, len(e.PrimaryKey)),
+			Visible: true,
+		}
+		for _, i := range e.PrimaryKey {
+			if i >= e.ColumnCount {
+				return nil, errors.Errorf("table %s.%s has primary key column %d, but only %d columns",
+					e.Schema, e.Table, i, e.ColumnCount)
+			}
+			t.PKColumns = append(t.PKColumns, int(i))
+			pk.Columns = append(pk.Columns, names[i])
+		}
+		t.Indexes = append(t.Indexes, pk)
+	}
+
+	return t, nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"sync"
 
 	"github.com/gongzhxu/go-mysql/mysql"
 	"github.com/klauspost/compress/zstd"
@@ -27,7 +28,10 @@ const (
 )
 
 type TransactionPayloadEvent struct {
-	format           FormatDescriptionEvent
+	format FormatDescriptionEvent
+	// parser of the events of the payload, with the settings of the parser of
+	// the event
+	parser           *BinlogParser
 	Size             uint64
 	UncompressedSize uint64
 	CompressionType  uint64
@@ -97,17 +101,22 @@ func (e *TransactionPayloadEvent) decodeFields(data []byte) error {
 	return nil
 }
 
+// payloadDecoder returns the decoder of the zstd payloads, shared by the
+// payload events as its DecodeAll is safe for concurrent use.
+var payloadDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+	return zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+})
+
 func (e *TransactionPayloadEvent) decodePayload() error {
 	if e.CompressionType != ZSTD {
 		return fmt.Errorf("TransactionPayloadEvent has compression type %d (%s)",
 			e.CompressionType, e.compressionType())
 	}
 
-	decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	decoder, err := payloadDecoder()
 	if err != nil {
 		return err
 	}
-	defer decoder.Close()
 
 	payloadUncompressed, err := decoder.DecodeAll(e.Payload, nil)
 	if err != nil {
@@ -115,10 +124,13 @@ func (e *TransactionPayloadEvent) decodePayload() error {
 	}
 
 	// The uncompressed data needs to be split up into individual events for Parse()
-	// to work on them. We can't use e.parser directly as we need to disable checksums
-	// but we still need the initialization from the FormatDescriptionEvent. We can't
-	// modify e.parser as it is used elsewhere.
-	parser := NewBinlogParser()
+	// to work on them. We can't use the parser of the event directly as we need to
+	// disable checksums but we still need the initialization from the
+	// FormatDescriptionEvent, e.parser is a copy of its settings.
+	parser := e.parser
+	if parser == nil {
+		parser = NewBinlogParser()
+	}
 	parser.format = &FormatDescriptionEvent{
 		Version:                e.format.Version,
 		ServerVersion:          e.format.ServerVersion,
@@ -1,6 +1,7 @@
 package replication
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -93,4 +94,37 @@ func TestTransactionPayloadEventDecode(t *testing.T) {
 	devent, ok := e.Events[6].Event.(*RowsEvent)
 	require.True(t, ok)
 	require.Equal(t, devent.Type(), EnumRowsEventTypeDelete)
+
+	// the events of the payload are parsed with the settings of the parser
+	p := NewBinlogParser()
+	p.format = &e.format
+	p.SetTableColumnNamesFunc(func(schema, table string) ([]string, error) {
+		names := make([]string, ievent.ColumnCount)
+		for i := range names {
+			names[i] = fmt.Sprintf("c%d", i)
+		}
+		return names, nil
+	})
+	pe := p.newTransactionPayloadEvent()
+	pe.CompressionType = ZSTD
+	pe.Payload = e.Payload
+	require.NoError(t, pe.decodePayload())
+	named, err := pe.Events[2].Event.(*RowsEvent).NamedRows()
+	require.NoError(t, err)
+	require.Len(t, named, len(ievent.Rows))
+	require.Equal(t, ievent.Rows[0][0], named[0]["c0"])
+
+	// the rows of the payload are decoded when the syncer defers the rows of
+	// the binlog events to its workers
+	b := NewBinlogSyncer(BinlogSyncerConfig{ServerID: 1, RowsDecodeConcurrency: 4})
+	defer b.Close()
+	b.parser.format = &e.format
+	pe = b.parser.newTransactionPayloadEvent()
+	pe.CompressionType = ZSTD
+	pe.Payload = e.Payload
+	require.NoError(t, pe.decodePayload())
+	require.Nil(t, b.deferredRows)
+	require.Equal(t, ievent.Rows, pe.Events[2].Event.(*RowsEvent).Rows)
+	require.Equal(t, uevent.Rows, pe.Events[4].Event.(*RowsEvent).Rows)
+	require.Equal(t, devent.Rows, pe.Events[6].Event.(*RowsEvent).Rows)
 }
@@ -0,0 +1,79 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// ToCreateTableSQL returns a CREATE TABLE IF NOT EXISTS statement creating the
+// table, e.g. on the target of a replication, from what the table knows: the
+// column types and collations, AUTO_INCREMENT, the primary key and the indexes.
+// The columns are NULL but the ones of the primary key, without default. The
+// generated columns, whose expression isn't known, are skipped, as well as the
+// indexes on them, on expressions, on the spatial columns, and on BLOB and TEXT
+// columns, whose prefix length isn't known. The foreign keys are skipped too,
+// the tables can be created in any order.
+func (ta *Table) ToCreateTableSQL() string {
+	var defs []string
+	for i := range ta.Columns {
+		col := &ta.Columns[i]
+		if col.IsVirtual || col.IsStored {
+			continue
+		}
+		def := mysql.QuoteIdentifier(col.Name) + " " + col.RawType
+		if col.Collation != "" {
+			def += " COLLATE " + col.Collation
+		}
+		if ta.IsPrimaryKey(i) {
+			def += " NOT NULL"
+		}
+		if col.IsAuto {
+			def += " AUTO_INCREMENT"
+		}
+		defs = append(defs, def)
+	}
+
+	for _, idx := range ta.Indexes {
+		columns, ok := ta.indexColumns(idx)
+		if !ok {
+			continue
+		}
+		switch {
+		case idx.Name == "PRIMARY":
+			defs = append(defs, "PRIMARY KEY ("+columns+")")
+			continue
+		case idx.NoneUnique == 0:
+			defs = append(defs, fmt.Sprintf("UNIQUE KEY %s (%s)", mysql.QuoteIdentifier(idx.Name), columns))
+		default:
+			defs = append(defs, fmt.Sprintf("KEY %s (%s)", mysql.QuoteIdentifier(idx.Name), columns))
+		}
+		if !idx.Visible {
+			defs[len(defs)-1] += " INVISIBLE"
+		}
+	}
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.%s (\n  %s\n)",
+		mysql.QuoteIdentifier(ta.Schema), mysql.QuoteIdentifier(ta.Name), strings.Join(defs, ",\n  "))
+}
+
+// indexColumns returns the quoted columns of idx, or false if it can't be
+// created by ToCreateTableSQL.
+func (ta *Table) indexColumns(idx *Index) (string, bool) {
+	columns := make([]string, len(idx.Columns))
+	for i, name := range idx.Columns {
+		j := ta.FindColumn(name)
+		if j < 0 {
+			// an expression
+			return "", false
+		}
+		col := &ta.Columns[j]
+		raw := strings.ToLower(col.RawType)
+		if col.IsVirtual || col.IsStored || col.Type == TYPE_POINT || strings.HasSuffix(raw, "blob") || strings.HasSuffix(raw, "text") {
+			return "", false
+		}
+		columns[i] = mysql.QuoteIdentifier(name)
+	}
+	return strings.Join(columns, ","), len(columns) > 0
+}
@@ -0,0 +1,182 @@
+package schema
+
+import "slices"
+
+// TableDiff is the difference between two versions of a table, returned by
+// Diff. The columns, indexes and foreign keys are matched by name, so a
+// renamed column is a drop and an add.
+type TableDiff struct {
+	AddedColumns    []ColumnAdd
+	DroppedColumns  []TableColumn
+	ModifiedColumns []ColumnChange
+
+	AddedIndexes    []*Index
+	DroppedIndexes  []*Index
+	ModifiedIndexes []IndexChange
+
+	// PKChanged is set if the primary key columns changed, the PRIMARY index
+	// is then also added, dropped or modified.
+	PKChanged bool
+
+	AddedForeignKeys   []*ForeignKey
+	DroppedForeignKeys []*ForeignKey
+}
+
+// ColumnAdd is a column added, After is the name of the column before it in
+// the new table, empty if it is the first one, like ADD COLUMN ... AFTER.
+type ColumnAdd struct {
+	Column TableColumn
+	After  string
+}
+
+// ColumnChange is a column of both tables whose definition changed.
+type ColumnChange struct {
+	Old TableColumn
+	New TableColumn
+}
+
+// IndexChange is an index of both tables whose columns, uniqueness or
+// visibility changed.
+type IndexChange struct {
+	Old *Index
+	New *Index
+}
+
+// Empty returns whether the tables of the diff have the same structure.
+func (d *TableDiff) Empty() bool {
+	return len(d.AddedColumns) == 0 && len(d.DroppedColumns) == 0 && len(d.ModifiedColumns) == 0 &&
+		len(d.AddedIndexes) == 0 && len(d.DroppedIndexes) == 0 && len(d.ModifiedIndexes) == 0 &&
+		!d.PKChanged && len(d.AddedForeignKeys) == 0 && len(d.DroppedForeignKeys) == 0
+}
+
+// Diff returns the changes from the table old to the table new, e.g. the table
+// of canal before and after a DDL, to apply them to a downstream table. The
+// changes are in the order of the columns and indexes of the tables, the
+// positions of the columns kept are not compared.
+func Diff(old, new *Table) *TableDiff {
+	d := &TableDiff{}
+
+	for i, c := range new.Columns {
+		j := old.FindColumn(c.Name)
+		if j < 0 {
+			after := ""
+			if i > 0 {
+				after = new.Columns[i-1].Name
+			}
+			d.AddedColumns = append(d.AddedColumns, ColumnAdd{Column: c, After: after})
+		} else if !columnEqual(&old.Columns[j], &c) {
+			d.ModifiedColumns = append(d.ModifiedColumns, ColumnChange{Old: old.Columns[j], New: c})
+		}
+	}
+	for _, c := range old.Columns {
+		if new.FindColumn(c.Name) < 0 {
+			d.DroppedColumns = append(d.DroppedColumns, c)
+		}
+	}
+
+	for _, idx := range new.Indexes {
+		o := findIndex(old.Indexes, idx.Name)
+		if o == nil {
+			d.AddedIndexes = append(d.AddedIndexes, idx)
+		} else if !indexEqual(o, idx) {
+			d.ModifiedIndexes = append(d.ModifiedIndexes, IndexChange{Old: o, New: idx})
+		}
+	}
+	for _, idx := range old.Indexes {
+		if findIndex(new.Indexes, idx.Name) == nil {
+			d.DroppedIndexes = append(d.DroppedIndexes, idx)
+		}
+	}
+
+	d.PKChanged = !slices.Equal(pkColumnNames(old), pkColumnNames(new))
+
+	for _, fk := range new.ForeignKeys {
+		if o := findForeignKey(old.ForeignKeys, fk.Name); o == nil {
+			d.AddedForeignKeys = append(d.AddedForeignKeys, fk)
+		} else if !foreignKeyEqual(o, fk) {
+			// a foreign key can't be altered, it is dropped and added again
+			d.DroppedForeignKeys = append(d.DroppedForeignKeys, o)
+			d.AddedForeignKeys = append(d.AddedForeignKeys, fk)
+		}
+	}
+	for _, fk := range old.ForeignKeys {
+		if findForeignKey(new.ForeignKeys, fk.Name) == nil {
+			d.DroppedForeignKeys = append(d.DroppedForeignKeys, fk)
+		}
+	}
+	return d
+}
+
+// Clone returns a deep copy of the table, a snapshot to Diff with the table
+// after it is altered.
+func (ta *Table) Clone() *Table {
+	t := &Table{
+		Schema:          ta.Schema,
+		Name:            ta.Name,
+		Columns:         make([]TableColumn, len(ta.Columns)),
+		PKColumns:       slices.Clone(ta.PKColumns),
+		UnsignedColumns: slices.Clone(ta.UnsignedColumns),
+	}
+	for i, c := range ta.Columns {
+		c.EnumValues = slices.Clone(c.EnumValues)
+		c.SetValues = slices.Clone(c.SetValues)
+		t.Columns[i] = c
+	}
+	for _, idx := range ta.Indexes {
+		c := *idx
+		c.Columns = slices.Clone(idx.Columns)
+		c.Cardinality = slices.Clone(idx.Cardinality)
+		t.Indexes = append(t.Indexes, &c)
+	}
+	for _, fk := range ta.ForeignKeys {
+		c := *fk
+		c.Columns = slices.Clone(fk.Columns)
+		c.RefColumns = slices.Clone(fk.RefColumns)
+		t.ForeignKeys = append(t.ForeignKeys, &c)
+	}
+	return t
+}
+
+// columnEqual compares the definitions of the columns, RawType has the type,
+// its length and whether it is unsigned, and the values of an enum or a set.
+func columnEqual(a, b *TableColumn) bool {
+	return a.RawType == b.RawType && a.Collation == b.Collation && a.IsAuto == b.IsAuto &&
+		a.IsVirtual == b.IsVirtual && a.IsStored == b.IsStored
+}
+
+// indexEqual compares the indexes without their cardinality, which changes with
+// the data.
+func indexEqual(a, b *Index) bool {
+	return slices.Equal(a.Columns, b.Columns) && a.NoneUnique == b.NoneUnique && a.Visible == b.Visible
+}
+
+func foreignKeyEqual(a, b *ForeignKey) bool {
+	return slices.Equal(a.Columns, b.Columns) && a.RefSchema == b.RefSchema && a.RefTable == b.RefTable &&
+		slices.Equal(a.RefColumns, b.RefColumns) && a.OnUpdate == b.OnUpdate && a.OnDelete == b.OnDelete
+}
+
+func findIndex(indexes []*Index, name string) *Index {
+	for _, idx := range indexes {
+		if idx.Name == name {
+			return idx
+		}
+	}
+	return nil
+}
+
+func findForeignKey(fks []*ForeignKey, name string) *ForeignKey {
+	for _, fk := range fks {
+		if fk.Name == name {
+			return fk
+		}
+	}
+	return nil
+}
+
+func pkColumnNames(ta *Table) []string {
+	names := make([]string, 0, len(ta.PKColumns))
+	for _, i := range ta.PKColumns {
+		names = append(names, ta.Columns[i].Name)
+	}
+	return names
+}
@@ -0,0 +1,151 @@
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// ForeignKey is a foreign key of a table, Columns references RefColumns of
+// RefSchema.RefTable.
+type ForeignKey struct {
+	Name       string
+	Columns    []string
+	RefSchema  string
+	RefTable   string
+	RefColumns []string
+	// OnUpdate and OnDelete are the rules, like CASCADE or RESTRICT
+	OnUpdate string
+	OnDelete string
+}
+
+// RefTableString returns the referenced table as schema.table, like Table.String.
+func (fk *ForeignKey) RefTableString() string {
+	return fmt.Sprintf("%s.%s", fk.RefSchema, fk.RefTable)
+}
+
+func (ta *Table) foreignKeysQuery() string {
+	return fmt.Sprintf(`SELECT k.CONSTRAINT_NAME, k.COLUMN_NAME, k.REFERENCED_TABLE_SCHEMA, k.REFERENCED_TABLE_NAME,
+	k.REFERENCED_COLUMN_NAME, r.UPDATE_RULE, r.DELETE_RULE
+FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE k
+JOIN INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS r
+	ON r.CONSTRAINT_SCHEMA = k.CONSTRAINT_SCHEMA AND r.CONSTRAINT_NAME = k.CONSTRAINT_NAME AND r.TABLE_NAME = k.TABLE_NAME
+WHERE k.TABLE_SCHEMA = '%s' AND k.TABLE_NAME = '%s' AND k.REFERENCED_TABLE_NAME IS NOT NULL
+ORDER BY k.CONSTRAINT_NAME, k.ORDINAL_POSITION`, mysql.Escape(ta.Schema), mysql.Escape(ta.Name))
+}
+
+// addForeignKeyColumn adds a column of the foreign key name, the rows of a key
+// come in order.
+func (ta *Table) addForeignKeyColumn(name, column, refSchema, refTable, refColumn, onUpdate, onDelete string) {
+	n := len(ta.ForeignKeys)
+	if n == 0 || ta.ForeignKeys[n-1].Name != name {
+		ta.ForeignKeys = append(ta.ForeignKeys, &ForeignKey{
+			Name:      name,
+			RefSchema: refSchema,
+			RefTable:  refTable,
+			OnUpdate:  onUpdate,
+			OnDelete:  onDelete,
+		})
+		n++
+	}
+	fk := ta.ForeignKeys[n-1]
+	fk.Columns = append(fk.Columns, column)
+	fk.RefColumns = append(fk.RefColumns, refColumn)
+}
+
+// FetchForeignKeys sets the ForeignKeys of the table from the
+// INFORMATION_SCHEMA. Unlike the columns and the indexes, NewTable doesn't
+// fetch them, as few users need them and the query is slow on many tables.
+func (ta *Table) FetchForeignKeys(conn mysql.Executer) error {
+	r, err := conn.Execute(ta.foreignKeysQuery())
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	ta.ForeignKeys = nil
+
+	for i := 0; i < r.RowNumber(); i++ {
+		var v [7]string
+		for j := range v {
+			if v[j], err = r.GetString(i, j); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		ta.addForeignKeyColumn(v[0], v[1], v[2], v[3], v[4], v[5], v[6])
+	}
+
+	return nil
+}
+
+// FetchForeignKeysViaSqlDB is FetchForeignKeys for a table of NewTableFromSqlDB.
+func (ta *Table) FetchForeignKeysViaSqlDB(conn *sql.DB) error {
+	r, err := conn.Query(ta.foreignKeysQuery())
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer r.Close()
+
+	ta.ForeignKeys = nil
+	for r.Next() {
+		var name, column, refSchema, refTable, refColumn, onUpdate, onDelete string
+		if err := r.Scan(&name, &column, &refSchema, &refTable, &refColumn, &onUpdate, &onDelete); err != nil {
+			return errors.Trace(err)
+		}
+		ta.addForeignKeyColumn(name, column, refSchema, refTable, refColumn, onUpdate, onDelete)
+	}
+
+	return r.Err()
+}
+
+// SortByForeignKeys returns the tables ordered so a table comes after the tables
+// it references, the order to insert rows in without breaking the foreign keys,
+// the reverse is the order to delete them in. The references to tables not in
+// the list and to the table itself are ignored. It fails if the foreign keys of
+// the tables have a cycle.
+func SortByForeignKeys(tables []*Table) ([]*Table, error) {
+	const (
+		visiting = iota + 1
+		done
+	)
+
+	byName := make(map[string]*Table, len(tables))
+	for _, ta := range tables {
+		byName[ta.String()] = ta
+	}
+
+	sorted := make([]*Table, 0, len(tables))
+	state := make(map[*Table]int, len(tables))
+	var visit func(ta *Table) error
+	visit = func(ta *Table) error {
+		switch state[ta] {
+		case done:
+			return nil
+		case visiting:
+			return errors.Errorf("foreign keys of table %s have a cycle", ta)
+		}
+		state[ta] = visiting
+		for _, fk := range ta.ForeignKeys {
+			ref, ok := byName[fk.RefTableString()]
+			if !ok || ref == ta {
+				continue
+			}
+			if err := visit(ref); err != nil {
+				return err
+			}
+		}
+		state[ta] = done
+		sorted = append(sorted, ta)
+		return nil
+	}
+
+	for _, ta := range tables {
+		if err := visit(ta); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
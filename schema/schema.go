@@ -72,6 +72,10 @@ type Table struct {
 	PKColumns []int
 
 	UnsignedColumns []int
+
+	// ForeignKeys are the foreign keys of the table, referencing other tables,
+	// only set by FetchForeignKeys.
+	ForeignKeys []*ForeignKey
 }
 
 func (ta *Table) String() string {
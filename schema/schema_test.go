@@ -346,3 +346,141 @@ func (s *schemaTestSuite) TestVisibleFieldInSchema() {
 		require.True(s.T(), idx.Visible, "Index %s should be visible by default (SQL DB)", idx.Name)
 	}
 }
+
+func (s *schemaTestSuite) TestForeignKeys() {
+	_, err := s.conn.Execute(`DROP TABLE IF EXISTS schema_fk_child, schema_fk_parent`)
+	require.NoError(s.T(), err)
+
+	_, err = s.conn.Execute(`CREATE TABLE schema_fk_parent (a INT, b INT, PRIMARY KEY (a, b)) ENGINE = INNODB`)
+	require.NoError(s.T(), err)
+	_, err = s.conn.Execute(`CREATE TABLE schema_fk_child (
+		id INT PRIMARY KEY,
+		pa INT,
+		pb INT,
+		CONSTRAINT fk_parent FOREIGN KEY (pa, pb) REFERENCES schema_fk_parent (a, b) ON DELETE CASCADE ON UPDATE RESTRICT
+	) ENGINE = INNODB`)
+	require.NoError(s.T(), err)
+
+	expected := []*ForeignKey{{
+		Name:       "fk_parent",
+		Columns:    []string{"pa", "pb"},
+		RefSchema:  *schema,
+		RefTable:   "schema_fk_parent",
+		RefColumns: []string{"a", "b"},
+		OnUpdate:   "RESTRICT",
+		OnDelete:   "CASCADE",
+	}}
+
+	child, err := NewTable(s.conn, *schema, "schema_fk_child")
+	require.NoError(s.T(), err)
+	require.Empty(s.T(), child.ForeignKeys)
+	require.NoError(s.T(), child.FetchForeignKeys(s.conn))
+	require.Equal(s.T(), expected, child.ForeignKeys)
+	// fetched again, not added twice
+	require.NoError(s.T(), child.FetchForeignKeys(s.conn))
+	require.Equal(s.T(), expected, child.ForeignKeys)
+
+	childFromSqlDB, err := NewTableFromSqlDB(s.sqlDB, *schema, "schema_fk_child")
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), childFromSqlDB.FetchForeignKeysViaSqlDB(s.sqlDB))
+	require.Equal(s.T(), expected, childFromSqlDB.ForeignKeys)
+
+	parent, err := NewTable(s.conn, *schema, "schema_fk_parent")
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), parent.FetchForeignKeys(s.conn))
+	require.Empty(s.T(), parent.ForeignKeys)
+}
+
+func TestSortByForeignKeys(t *testing.T) {
+	ref := func(table string) *ForeignKey {
+		return &ForeignKey{RefSchema: "db", RefTable: table}
+	}
+	a := &Table{Schema: "db", Name: "a", ForeignKeys: []*ForeignKey{ref("b"), ref("c")}}
+	b := &Table{Schema: "db", Name: "b", ForeignKeys: []*ForeignKey{ref("c"), ref("b")}}
+	c := &Table{Schema: "db", Name: "c", ForeignKeys: []*ForeignKey{ref("other")}}
+
+	sorted, err := SortByForeignKeys([]*Table{a, b, c})
+	require.NoError(t, err)
+	require.Equal(t, []*Table{c, b, a}, sorted)
+
+	c.ForeignKeys = append(c.ForeignKeys, ref("a"))
+	_, err = SortByForeignKeys([]*Table{a, b, c})
+	require.ErrorContains(t, err, "cycle")
+}
+
+func TestDiff(t *testing.T) {
+	old := &Table{Schema: "db", Name: "t"}
+	old.AddColumn("id", "int(11)", "", "auto_increment")
+	old.AddColumn("name", "varchar(32)", "utf8mb4_general_ci", "")
+	old.AddColumn("gone", "int(11)", "", "")
+	old.AddIndex("PRIMARY").AddColumn("id", 10)
+	old.AddIndex("name").AddColumn("name", 5)
+	old.AddIndex("gone").AddColumn("gone", 5)
+	old.PKColumns = []int{0}
+
+	require.True(t, Diff(old, old.Clone()).Empty())
+
+	cur := old.Clone()
+	cur.Indexes[0].Cardinality[0] = 20
+	require.True(t, Diff(old, cur).Empty())
+
+	cur = &Table{Schema: "db", Name: "t"}
+	cur.AddColumn("id", "bigint(20) unsigned", "", "auto_increment")
+	cur.AddColumn("added", "int(11)", "", "")
+	cur.AddColumn("name", "varchar(32)", "utf8mb4_general_ci", "")
+	cur.AddIndex("PRIMARY").AddColumn("id", 10)
+	cur.Indexes[0].AddColumn("name", 10)
+	idx := cur.AddIndex("name")
+	idx.AddColumn("name", 5)
+	idx.Visible = false
+	cur.AddIndex("added").AddColumn("added", 5)
+	cur.PKColumns = []int{0, 2}
+
+	d := Diff(old, cur)
+	require.False(t, d.Empty())
+	require.Equal(t, []ColumnAdd{{Column: cur.Columns[1], After: "id"}}, d.AddedColumns)
+	require.Equal(t, []TableColumn{old.Columns[2]}, d.DroppedColumns)
+	require.Equal(t, []ColumnChange{{Old: old.Columns[0], New: cur.Columns[0]}}, d.ModifiedColumns)
+	require.Equal(t, []*Index{cur.Indexes[2]}, d.AddedIndexes)
+	require.Equal(t, []*Index{old.Indexes[2]}, d.DroppedIndexes)
+	require.Equal(t, []IndexChange{{old.Indexes[0], cur.Indexes[0]}, {old.Indexes[1], cur.Indexes[1]}}, d.ModifiedIndexes)
+	require.True(t, d.PKChanged)
+
+	fk := &ForeignKey{Name: "fk", Columns: []string{"added"}, RefSchema: "db", RefTable: "p", RefColumns: []string{"id"}}
+	changed := *fk
+	changed.OnDelete = "CASCADE"
+	old.ForeignKeys = []*ForeignKey{fk}
+	next := old.Clone()
+	next.ForeignKeys = []*ForeignKey{&changed}
+	d = Diff(old, next)
+	require.Equal(t, []*ForeignKey{fk}, d.DroppedForeignKeys)
+	require.Equal(t, []*ForeignKey{&changed}, d.AddedForeignKeys)
+}
+
+func TestToCreateTableSQL(t *testing.T) {
+	ta := &Table{Schema: "db", Name: "t"}
+	ta.AddColumn("id", "bigint(20) unsigned", "", "auto_increment")
+	ta.AddColumn("name", "varchar(32)", "utf8mb4_general_ci", "")
+	ta.AddColumn("body", "text", "utf8mb4_general_ci", "")
+	ta.AddColumn("len", "int(11)", "", "VIRTUAL GENERATED")
+	ta.AddIndex("PRIMARY").AddColumn("id", 10)
+	ta.AddIndex("name").AddColumn("name", 5)
+	idx := ta.AddIndex("name_id")
+	idx.AddColumn("name", 5)
+	idx.AddColumn("id", 10)
+	idx.NoneUnique = 1
+	idx.Visible = false
+	ta.AddIndex("body").AddColumn("body", 5)
+	ta.AddIndex("len").AddColumn("len", 5)
+	ta.AddIndex("expr").AddColumn("", 5)
+	ta.PKColumns = []int{0}
+
+	require.Equal(t, "CREATE TABLE IF NOT EXISTS `db`.`t` (\n"+
+		"  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,\n"+
+		"  `name` varchar(32) COLLATE utf8mb4_general_ci,\n"+
+		"  `body` text COLLATE utf8mb4_general_ci,\n"+
+		"  PRIMARY KEY (`id`),\n"+
+		"  UNIQUE KEY `name` (`name`),\n"+
+		"  KEY `name_id` (`name`,`id`) INVISIBLE\n"+
+		")", ta.ToCreateTableSQL())
+}
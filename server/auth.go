@@ -49,7 +49,7 @@ func (c *Conn) compareAuthData(authPluginName string, clientAuthData []byte) err
 		return c.compareSha256PasswordAuthData(clientAuthData, c.password)
 
 	default:
-		return errors.Errorf("unknown authentication plugin name '%s'", authPluginName)
+		return c.authenticateWithPlugin(authPluginName, clientAuthData)
 	}
 }
 
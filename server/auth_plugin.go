@@ -0,0 +1,114 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// AuthPlugin is an authentication method of the server, in addition to
+// 'mysql_native_password', 'caching_sha2_password' and 'sha256_password', see
+// Server.RegisterAuthPlugin.
+type AuthPlugin interface {
+	// Authenticate checks authData, sent by the client in the handshake response
+	// or the auth switch response, for the user c.GetUser(). More data can be
+	// exchanged with the client by Conn.AuthMoreData. Returning an error wrapping
+	// ErrAccessDenied sends ER_ACCESS_DENIED_ERROR to the client.
+	Authenticate(c *Conn, authData []byte) error
+}
+
+// AuthPluginFunc is a function implementing AuthPlugin.
+type AuthPluginFunc func(c *Conn, authData []byte) error
+
+func (f AuthPluginFunc) Authenticate(c *Conn, authData []byte) error {
+	return f(c, authData)
+}
+
+// ClearPasswordPlugin is the 'mysql_clear_password' authentication method: the
+// client sends the password in clear, to check it against an external service
+// like LDAP or PAM. The connection must use TLS or a unix socket unless
+// AllowInsecure is set.
+type ClearPasswordPlugin struct {
+	// Check checks the password of user, it should return ErrAccessDenied for a
+	// wrong password.
+	Check         func(user string, password string) error
+	AllowInsecure bool
+}
+
+func (p *ClearPasswordPlugin) Authenticate(c *Conn, authData []byte) error {
+	if !p.AllowInsecure && !c.isSecureTransport() {
+		return errors.Errorf("%s requires a TLS connection", mysql.AUTH_CLEAR_PASSWORD)
+	}
+	// the password ends with a \NUL
+	if l := len(authData); l != 0 && authData[l-1] == 0x00 {
+		authData = authData[:l-1]
+	}
+	return p.Check(c.user, string(authData))
+}
+
+// RegisterAuthPlugin registers the authentication method name of the server,
+// to be set by SetDefaultAuthMethod. It must be called before the server
+// accepts connections, and panics for a built-in method.
+func (s *Server) RegisterAuthPlugin(name string, p AuthPlugin) {
+	if isAuthMethodSupported(name) {
+		panic(fmt.Sprintf("server authentication method '%s' is built-in", name))
+	}
+	if s.authPlugins == nil {
+		s.authPlugins = make(map[string]AuthPlugin)
+	}
+	s.authPlugins[name] = p
+}
+
+// SetDefaultAuthMethod sets the authentication method enforced by the server,
+// a built-in or registered one, see NewServer.
+func (s *Server) SetDefaultAuthMethod(name string) error {
+	if _, ok := s.authPlugins[name]; !ok && !isAuthMethodSupported(name) {
+		return errors.Errorf("server authentication method '%s' is not supported", name)
+	}
+	s.defaultAuthMethod = name
+	return nil
+}
+
+// handshakeAuthMethod returns the authentication method of the initial
+// handshake. For a registered method it is 'mysql_native_password' and the
+// client is asked to switch, like MySQL does for the users of another method,
+// as the clients may not use a method like 'mysql_clear_password' unasked.
+func (s *Server) handshakeAuthMethod() string {
+	if _, ok := s.authPlugins[s.defaultAuthMethod]; ok {
+		return mysql.AUTH_NATIVE_PASSWORD
+	}
+	return s.defaultAuthMethod
+}
+
+// AuthMoreData sends data to the client in an AuthMoreData packet during the
+// authentication, and returns its response, for an AuthPlugin.
+func (c *Conn) AuthMoreData(data []byte) ([]byte, error) {
+	packet := make([]byte, 4, 5+len(data))
+	packet = append(packet, mysql.MORE_DATE_HEADER)
+	packet = append(packet, data...)
+	if err := c.WritePacket(packet); err != nil {
+		return nil, err
+	}
+	return c.readAuthSwitchRequestResponse()
+}
+
+// isSecureTransport returns whether the connection uses TLS or a unix socket.
+func (c *Conn) isSecureTransport() bool {
+	switch c.Conn.Conn.(type) {
+	case *tls.Conn, *net.UnixConn:
+		return true
+	}
+	return false
+}
+
+func (c *Conn) authenticateWithPlugin(authPluginName string, authData []byte) error {
+	p, ok := c.serverConf.authPlugins[authPluginName]
+	if !ok {
+		return errors.Errorf("unknown authentication plugin name '%s'", authPluginName)
+	}
+	return p.Authenticate(c, authData)
+}
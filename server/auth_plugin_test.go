@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+func TestClearPasswordPlugin(t *testing.T) {
+	s := NewDefaultServer()
+	require.Panics(t, func() { s.RegisterAuthPlugin(mysql.AUTH_NATIVE_PASSWORD, &ClearPasswordPlugin{}) })
+	require.ErrorContains(t, s.SetDefaultAuthMethod(mysql.AUTH_CLEAR_PASSWORD), "not supported")
+
+	plugin := &ClearPasswordPlugin{
+		Check: func(user string, password string) error {
+			if user == "ldap_user" && password == "secret" {
+				return nil
+			}
+			return ErrAccessDenied
+		},
+	}
+	s.RegisterAuthPlugin(mysql.AUTH_CLEAR_PASSWORD, plugin)
+	require.NoError(t, s.SetDefaultAuthMethod(mysql.AUTH_CLEAR_PASSWORD))
+
+	addr := serveConns(t, func(c net.Conn) (*Conn, error) {
+		// no user is known by the credential provider
+		return s.NewCustomizedConn(c, NewInMemoryProvider(), &EmptyHandler{})
+	}, nil)
+
+	useTLS := func(c *client.Conn) error {
+		c.UseSSL(true)
+		return nil
+	}
+	// not enabled by the client
+	_, err := client.Connect(addr, "ldap_user", "secret", "", "", useTLS)
+	require.ErrorContains(t, err, "WithCleartextPassword")
+	// no TLS
+	_, err = client.Connect(addr, "ldap_user", "secret", "", "", client.WithCleartextPassword())
+	require.ErrorContains(t, err, "requires TLS")
+
+	conn, err := client.Connect(addr, "ldap_user", "secret", "", "", useTLS, client.WithCleartextPassword())
+	require.NoError(t, err)
+	require.NoError(t, conn.Ping())
+	require.NoError(t, conn.Close())
+
+	_, err = client.Connect(addr, "ldap_user", "wrong", "", "", useTLS, client.WithCleartextPassword())
+	require.ErrorContains(t, err, "Access denied for user 'ldap_user'")
+}
@@ -59,7 +59,7 @@ func (c *Conn) handleAuthSwitchResponse() error {
 		return c.compareSha256PasswordAuthData(authData, c.password)
 
 	default:
-		return errors.Errorf("unknown authentication plugin name '%s'", c.authPluginName)
+		return c.authenticateWithPlugin(c.authPluginName, authData)
 	}
 }
 
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser/charset"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// CharsetHandler is for handlers that want to handle the changes of the character
+// set of the connection, e.g. a proxy forwarding them to the backends. If the handler
+// implements it, it is called for SET NAMES and SET collation_connection statements
+// instead of Handler.HandleQuery, with the charset and collation resolved. The
+// statements with a charset or a collation unknown to the server are passed to
+// Handler.HandleQuery, as all of them are for the other handlers.
+type CharsetHandler interface {
+	HandleSetNames(charset string, collation string) error
+}
+
+var (
+	setNamesRegexp = regexp.MustCompile("(?i)^\\s*SET\\s+NAMES\\s+['\"`]?(\\w+)['\"`]?(?:\\s+COLLATE\\s+['\"`]?(\\w+)['\"`]?)?\\s*;?\\s*$")
+
+	setCollationRegexp = regexp.MustCompile("(?i)^\\s*SET\\s+(?:SESSION\\s+|LOCAL\\s+|@@SESSION\\.|@@LOCAL\\.|@@)?collation_connection\\s*=\\s*['\"`]?(\\w+)['\"`]?\\s*;?\\s*$")
+)
+
+// parseSetNames parses a SET NAMES or SET collation_connection statement, the
+// charset or collation not given are "".
+func parseSetNames(query string) (cs string, collation string, ok bool) {
+	if q := strings.TrimLeft(query, " \t\r\n"); len(q) < 3 || !strings.EqualFold(q[:3], "SET") {
+		return "", "", false
+	}
+	if m := setNamesRegexp.FindStringSubmatch(query); m != nil {
+		return m[1], m[2], true
+	}
+	if m := setCollationRegexp.FindStringSubmatch(query); m != nil {
+		return "", m[1], true
+	}
+	return "", "", false
+}
+
+// resolveCollation returns the collation of cs and collation, the default
+// collation of cs if collation is "".
+func (c *Conn) resolveCollation(cs string, collation string) (*charset.Collation, error) {
+	if strings.EqualFold(cs, "default") {
+		id := mysql.DEFAULT_COLLATION_ID
+		if c.serverConf != nil {
+			id = c.serverConf.collationId
+		}
+		return charset.GetCollationByID(int(id))
+	}
+
+	if collation == "" {
+		name, ok := mysql.CharsetDefaultCollation(cs)
+		if !ok {
+			var err error
+			if name, err = charset.GetDefaultCollation(strings.ToLower(cs)); err != nil {
+				return nil, mysql.NewDefaultError(mysql.ER_UNKNOWN_CHARACTER_SET, cs)
+			}
+		}
+		collation = name
+	}
+
+	co, err := charset.GetCollationByName(collation)
+	if err != nil {
+		return nil, mysql.NewDefaultError(mysql.ER_UNKNOWN_COLLATION, collation)
+	}
+	if cs != "" && !strings.EqualFold(co.CharsetName, cs) &&
+		!(strings.EqualFold(cs, "utf8mb3") && co.CharsetName == charset.CharsetUTF8) {
+		return nil, mysql.NewDefaultError(mysql.ER_COLLATION_CHARSET_MISMATCH, co.Name, cs)
+	}
+	return co, nil
+}
+
+// handleSetNames handles a SET NAMES or SET collation_connection statement.
+func (c *Conn) handleSetNames(query string, cs string, collation string) (*mysql.Result, error) {
+	co, err := c.resolveCollation(cs, collation)
+	h, ok := c.h.(CharsetHandler)
+	if !ok || isUnknownCollation(err) {
+		r, qerr := c.contextHandler().HandleQueryContext(c.Context(), query)
+		if qerr != nil {
+			return nil, qerr
+		}
+		if err == nil {
+			c.collation = uint16(co.ID)
+		}
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.HandleSetNames(co.CharsetName, co.Name); err != nil {
+		return nil, err
+	}
+	c.collation = uint16(co.ID)
+	return nil, nil
+}
+
+// isUnknownCollation returns whether err of resolveCollation is for a charset
+// or a collation unknown.
+func isUnknownCollation(err error) bool {
+	code, _ := mysql.MyErrorCode(err)
+	return code == mysql.ER_UNKNOWN_CHARACTER_SET || code == mysql.ER_UNKNOWN_COLLATION
+}
+
+// Collation returns the collation ID of the connection, set by the handshake,
+// SET NAMES or SET collation_connection. Unlike Charset it supports collation
+// IDs > 255, which can only be set after the handshake.
+func (c *Conn) Collation() uint16 {
+	return c.collation
+}
+
+// CollationName returns the name of the collation of the connection, "" if unknown.
+func (c *Conn) CollationName() string {
+	co, err := charset.GetCollationByID(int(c.collation))
+	if err != nil {
+		return ""
+	}
+	return co.Name
+}
+
+// CharsetName returns the name of the character set of the connection, "" if unknown.
+func (c *Conn) CharsetName() string {
+	co, err := charset.GetCollationByID(int(c.collation))
+	if err != nil {
+		return ""
+	}
+	return co.CharsetName
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+func TestParseSetNames(t *testing.T) {
+	testcases := []struct {
+		query     string
+		charset   string
+		collation string
+		ok        bool
+	}{
+		{"SET NAMES utf8mb4", "utf8mb4", "", true},
+		{"set names 'latin1' collate 'latin1_bin';", "latin1", "latin1_bin", true},
+		{"SET NAMES `utf8mb4` COLLATE utf8mb4_ja_0900_as_cs", "utf8mb4", "utf8mb4_ja_0900_as_cs", true},
+		{"SET @@session.collation_connection = 'utf8mb4_bin'", "", "utf8mb4_bin", true},
+		{"SET collation_connection=utf8mb4_general_ci", "", "utf8mb4_general_ci", true},
+		{"SET NAMES utf8mb4, autocommit = 1", "", "", false},
+		{"SELECT 'SET NAMES utf8mb4'", "", "", false},
+		{"SE", "", "", false},
+	}
+	for _, tc := range testcases {
+		cs, collation, ok := parseSetNames(tc.query)
+		require.Equal(t, tc.ok, ok, tc.query)
+		require.Equal(t, tc.charset, cs, tc.query)
+		require.Equal(t, tc.collation, collation, tc.query)
+	}
+}
+
+type setNamesHandler struct {
+	EmptyHandler
+	charset   string
+	collation string
+}
+
+func (h *setNamesHandler) HandleSetNames(charset string, collation string) error {
+	h.charset = charset
+	h.collation = collation
+	return nil
+}
+
+func TestHandleSetNames(t *testing.T) {
+	h := &setNamesHandler{}
+	c := &Conn{h: h, charset: mysql.DEFAULT_COLLATION_ID, collation: uint16(mysql.DEFAULT_COLLATION_ID)}
+	require.Equal(t, "utf8mb4_0900_ai_ci", c.CollationName())
+
+	require.Nil(t, c.dispatch(append([]byte{mysql.COM_QUERY}, "SET NAMES latin1"...)))
+	require.Equal(t, "latin1", h.charset)
+	require.Equal(t, "latin1_swedish_ci", h.collation)
+	require.EqualValues(t, 8, c.Collation())
+	require.Equal(t, "latin1", c.CharsetName())
+
+	// collations with IDs > 255 can only be set after the handshake
+	require.Nil(t, c.dispatch(append([]byte{mysql.COM_QUERY}, "SET NAMES utf8mb4 COLLATE utf8mb4_ja_0900_as_cs"...)))
+	require.EqualValues(t, 303, c.Collation())
+	require.Equal(t, "utf8mb4_ja_0900_as_cs", c.CollationName())
+	require.EqualValues(t, mysql.DEFAULT_COLLATION_ID, c.Charset())
+
+	err, ok := c.dispatch(append([]byte{mysql.COM_QUERY}, "SET NAMES latin1 COLLATE utf8mb4_bin"...)).(error)
+	require.True(t, ok)
+	require.EqualValues(t, mysql.ER_COLLATION_CHARSET_MISMATCH, err.(*mysql.MyError).Code)
+
+	// an unknown charset is passed to HandleQuery
+	err, ok = c.dispatch(append([]byte{mysql.COM_QUERY}, "SET NAMES nope"...)).(error)
+	require.True(t, ok)
+	require.EqualError(t, err, "not supported now")
+	require.EqualValues(t, 303, c.Collation())
+}
+
+func TestHandleSetNamesForwarded(t *testing.T) {
+	h := &forwardingHandler{queries: make(chan string, 1)}
+	c := &Conn{h: h, charset: mysql.DEFAULT_COLLATION_ID, collation: uint16(mysql.DEFAULT_COLLATION_ID)}
+
+	require.Nil(t, c.dispatch(append([]byte{mysql.COM_QUERY}, "SET NAMES latin1"...)))
+	require.Equal(t, "SET NAMES latin1", <-h.queries)
+	require.EqualValues(t, 8, c.Collation())
+
+	require.Nil(t, c.dispatch(append([]byte{mysql.COM_QUERY}, "SET NAMES nope"...)))
+	require.Equal(t, "SET NAMES nope", <-h.queries)
+	require.EqualValues(t, 8, c.Collation())
+}
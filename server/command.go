@@ -23,7 +23,8 @@ type Handler interface {
 	// context will be used later for statement execute
 	HandleStmtPrepare(query string) (params int, columns int, context interface{}, err error)
 	// handle COM_STMT_EXECUTE, context is the previous one set in prepare
-	// query is the statement prepare query, and args is the params for this statement
+	// query is the statement prepare query, and args is the params for this statement,
+	// see PreparedQuery to bind them into the query and StmtArgs to convert them
 	HandleStmtExecute(context interface{}, query string, args []interface{}) (*mysql.Result, error)
 	// handle COM_STMT_CLOSE, context is the previous one set in prepare
 	// this handler has no response
@@ -33,6 +34,15 @@ type Handler interface {
 	HandleOtherCommand(cmd byte, data []byte) error
 }
 
+// StmtPrepareFieldsHandler is for handlers that want to describe the parameters and
+// columns of prepared statements, so clients binding parameters by their types work.
+// If the handler implements it, it is called for COM_STMT_PREPARE instead of
+// Handler.HandleStmtPrepare. The fields are sent in the COM_STMT_PREPARE response,
+// the number of params and columns are the lengths of params and columns.
+type StmtPrepareFieldsHandler interface {
+	HandleStmtPrepareFields(query string) (params []*mysql.Field, columns []*mysql.Field, context interface{}, err error)
+}
+
 // ReplicationHandler is for handlers that want to implement the replication protocol
 type ReplicationHandler interface {
 	// handle Replication command
@@ -48,17 +58,38 @@ func (c *Conn) HandleCommand() error {
 		return fmt.Errorf("connection closed")
 	}
 
-	data, err := c.ReadPacket()
+	c.setCommandReadDeadline()
+	data, ok, err := c.readCommand()
 	if err != nil {
+		if c.shuttingDown() {
+			return c.closeForShutdown()
+		}
+		if c.killed.Load() {
+			return c.closeForKill()
+		}
+		if c.readDeadlineExceeded() {
+			return c.closeForTimeout()
+		}
 		c.Close()
 		c.Conn = nil
 		return err
 	}
+	if !ok {
+		return c.closeForPacketTooLarge()
+	}
+
+	c.clearCommandReadDeadline()
+
+	if !c.startCommand() {
+		return c.closeForShutdown()
+	}
 
-	v := c.dispatch(data)
+	v := c.interrupted(c.dispatch(data))
 
 	err = c.WriteValue(v)
 
+	c.finishCommand()
+
 	if c.Conn != nil {
 		c.ResetSequence()
 	}
@@ -66,6 +97,10 @@ func (c *Conn) HandleCommand() error {
 	if err != nil {
 		c.Close()
 		c.Conn = nil
+	} else if c.shuttingDown() {
+		return c.closeForShutdown()
+	} else if c.killed.Load() {
+		return c.closeForKill()
 	}
 	return err
 }
@@ -80,7 +115,36 @@ func (c *Conn) dispatch(data []byte) interface{} {
 		c.Conn = nil
 		return noResponse{}
 	case mysql.COM_QUERY:
-		if r, err := c.h.HandleQuery(utils.ByteSliceToString(data)); err != nil {
+		c.countQuestion()
+		if err := c.countUserQuery(); err != nil {
+			return err
+		}
+		if c.queryAttributesEnabled() {
+			attrs, rest, err := parseQueryAttributes(data)
+			if err != nil {
+				return err
+			}
+			c.setQueryAttributes(attrs)
+			data = rest
+		}
+		query := utils.ByteSliceToString(data)
+		if h, ok := c.h.(KillHandler); ok {
+			if id, killQuery, ok := parseKill(query); ok {
+				return c.handleKill(h, id, killQuery)
+			}
+		}
+		if cs, collation, ok := parseSetNames(query); ok {
+			if r, err := c.handleSetNames(query, cs, collation); err != nil {
+				return err
+			} else {
+				return r
+			}
+		}
+		ctx := c.queryContext(query)
+		if h, ok := c.h.(StreamingQueryHandler); ok {
+			return c.handleQueryStreaming(h, query)
+		}
+		if r, err := c.contextHandler().HandleQueryContext(ctx, query); err != nil {
 			return err
 		} else {
 			return r
@@ -88,7 +152,7 @@ func (c *Conn) dispatch(data []byte) interface{} {
 	case mysql.COM_PING:
 		return nil
 	case mysql.COM_INIT_DB:
-		if err := c.h.UseDB(utils.ByteSliceToString(data)); err != nil {
+		if err := c.contextHandler().UseDBContext(c.Context(), utils.ByteSliceToString(data)); err != nil {
 			return err
 		} else {
 			return nil
@@ -98,7 +162,7 @@ func (c *Conn) dispatch(data []byte) interface{} {
 		table := utils.ByteSliceToString(data[0:index])
 		wildcard := utils.ByteSliceToString(data[index+1:])
 
-		if fs, err := c.h.HandleFieldList(table, wildcard); err != nil {
+		if fs, err := c.contextHandler().HandleFieldListContext(c.Context(), table, wildcard); err != nil {
 			return err
 		} else {
 			return fs
@@ -109,7 +173,14 @@ func (c *Conn) dispatch(data []byte) interface{} {
 		st.ID = c.stmtID
 		st.Query = utils.ByteSliceToString(data)
 		var err error
-		if st.Params, st.Columns, st.Context, err = c.h.HandleStmtPrepare(st.Query); err != nil {
+		if h, ok := c.h.(StmtPrepareFieldsHandler); ok {
+			if st.ParamFields, st.ColumnFields, st.Context, err = h.HandleStmtPrepareFields(st.Query); err == nil {
+				st.Params, st.Columns = len(st.ParamFields), len(st.ColumnFields)
+			}
+		} else {
+			st.Params, st.Columns, st.Context, err = c.contextHandler().HandleStmtPrepareContext(c.queryContext(st.Query), st.Query)
+		}
+		if err != nil {
 			return err
 		} else {
 			st.ResetParams()
@@ -117,6 +188,10 @@ func (c *Conn) dispatch(data []byte) interface{} {
 			return st
 		}
 	case mysql.COM_STMT_EXECUTE:
+		c.countQuestion()
+		if err := c.countUserQuery(); err != nil {
+			return err
+		}
 		if r, err := c.handleStmtExecute(data); err != nil {
 			return err
 		} else {
@@ -139,19 +214,28 @@ func (c *Conn) dispatch(data []byte) interface{} {
 			return r
 		}
 	case mysql.COM_SET_OPTION:
-		if err := c.h.HandleOtherCommand(cmd, data); err != nil {
-			return err
+		return c.handleSetOption(data)
+	case mysql.COM_STATISTICS:
+		return c.handleStatistics()
+	case mysql.COM_DEBUG:
+		return c.handleDebug()
+	case mysql.COM_PROCESS_KILL:
+		if h, ok := c.h.(KillHandler); ok {
+			return c.handleProcessKill(h, data)
 		}
-
-		return eofResponse{}
+		return c.contextHandler().HandleOtherCommandContext(c.Context(), cmd, data)
 	case mysql.COM_REGISTER_SLAVE:
-		if h, ok := c.h.(ReplicationHandler); ok {
+		if h, ok := c.h.(ReplicationCommandHandler); ok {
+			return c.handleRegisterReplica(h, data)
+		} else if h, ok := c.h.(ReplicationHandler); ok {
 			return h.HandleRegisterSlave(data)
 		} else {
-			return c.h.HandleOtherCommand(cmd, data)
+			return c.contextHandler().HandleOtherCommandContext(c.Context(), cmd, data)
 		}
 	case mysql.COM_BINLOG_DUMP:
-		if h, ok := c.h.(ReplicationHandler); ok {
+		if h, ok := c.h.(ReplicationCommandHandler); ok {
+			return c.handleBinlogDump(h, cmd, data)
+		} else if h, ok := c.h.(ReplicationHandler); ok {
 			pos, err := parseBinlogDump(data)
 			if err != nil {
 				return err
@@ -162,10 +246,12 @@ func (c *Conn) dispatch(data []byte) interface{} {
 				return s
 			}
 		} else {
-			return c.h.HandleOtherCommand(cmd, data)
+			return c.contextHandler().HandleOtherCommandContext(c.Context(), cmd, data)
 		}
 	case mysql.COM_BINLOG_DUMP_GTID:
-		if h, ok := c.h.(ReplicationHandler); ok {
+		if h, ok := c.h.(ReplicationCommandHandler); ok {
+			return c.handleBinlogDump(h, cmd, data)
+		} else if h, ok := c.h.(ReplicationHandler); ok {
 			gtidSet, err := parseBinlogDumpGTID(data)
 			if err != nil {
 				return err
@@ -176,10 +262,10 @@ func (c *Conn) dispatch(data []byte) interface{} {
 				return s
 			}
 		} else {
-			return c.h.HandleOtherCommand(cmd, data)
+			return c.contextHandler().HandleOtherCommandContext(c.Context(), cmd, data)
 		}
 	default:
-		return c.h.HandleOtherCommand(cmd, data)
+		return c.contextHandler().HandleOtherCommandContext(c.Context(), cmd, data)
 	}
 }
 
@@ -206,9 +292,11 @@ func (h EmptyHandler) HandleQuery(query string) (*mysql.Result, error) {
 		return nil, nil
 	}
 	if query == `select concat(@@version, ' ', @@version_comment)` {
-		r, err := mysql.BuildSimpleResultset([]string{"concat(@@version, ' ', @@version_comment)"}, [][]interface{}{
-			{"8.0.11"},
-		}, false)
+		b := mysql.NewResultsetBuilder(false).AddColumn("concat(@@version, ' ', @@version_comment)", mysql.MYSQL_TYPE_VAR_STRING)
+		if err := b.AddRow("8.0.11"); err != nil {
+			return nil, err
+		}
+		r, err := b.Build()
 		if err != nil {
 			return nil, err
 		}
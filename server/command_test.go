@@ -4,4 +4,6 @@ package server
 var (
 	_ Handler            = EmptyHandler{}
 	_ ReplicationHandler = EmptyReplicationHandler{}
+
+	_ ReplicationCommandHandler = RejectReplicationHandler{}
 )
@@ -1,10 +1,12 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"net"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/gongzhxu/go-mysql/mysql"
 	"github.com/gongzhxu/go-mysql/packet"
@@ -17,6 +19,7 @@ type Conn struct {
 	serverConf     *Server
 	capability     uint32
 	charset        uint8
+	collation      uint16
 	authPluginName string
 	attributes     map[string]string
 	connectionID   uint32
@@ -28,13 +31,33 @@ type Conn struct {
 	user                string
 	password            string
 	cachingSha2FullAuth bool
+	userLimits          *UserLimits // set if the connection is counted for the user, see UserLimitsProvider
+	userReleased        atomic.Bool
 
 	h Handler
+	// ch is h as a ContextHandler, see AdaptHandler
+	ch ContextHandler
 
 	stmts  map[uint32]*Stmt
 	stmtID uint32
 
 	closed atomic.Bool
+
+	// netConn is the accepted connection, stateMu protects busy, which is set
+	// while a command is handled, see Server.Shutdown, and the context of the
+	// command, see Server.Kill
+	netConn     net.Conn
+	stateMu     sync.Mutex
+	busy        bool
+	cmdCtx      context.Context
+	cmdCancel   context.CancelFunc
+	queryKilled bool
+	killed      atomic.Bool
+
+	// connectedAt is when the connection was accepted, see Server.SetMaxConnLifetime
+	connectedAt time.Time
+	// readDeadline is the deadline of reading the next command, zero if none
+	readDeadline time.Time
 }
 
 var (
@@ -68,6 +91,20 @@ func (s *Server) NewConn(conn net.Conn, user string, password string, h Handler)
 
 // NewCustomizedConn: create connection with customized server settings
 func (s *Server) NewCustomizedConn(conn net.Conn, p CredentialProvider, h Handler) (*Conn, error) {
+	if s.proxyProtocol {
+		pc, err := readProxyHeader(conn)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = pc
+	}
+
+	if s.shuttingDown.Load() {
+		c := &Conn{Conn: packet.NewConn(conn), serverConf: s}
+		return nil, c.closeForShutdown()
+	}
+
 	var packetConn *packet.Conn
 	if s.tlsConfig != nil {
 		packetConn = packet.NewTLSConn(conn)
@@ -77,14 +114,17 @@ func (s *Server) NewCustomizedConn(conn net.Conn, p CredentialProvider, h Handle
 
 	c := &Conn{
 		Conn:               packetConn,
+		netConn:            conn,
 		serverConf:         s,
 		credentialProvider: p,
 		h:                  h,
 		connectionID:       atomic.AddUint32(&baseConnID, 1),
 		stmts:              make(map[uint32]*Stmt),
 		salt:               mysql.RandomBuf(20),
+		connectedAt:        time.Now(),
 	}
 	c.closed.Store(false)
+	s.trackConn(c)
 
 	if err := c.handshake(); err != nil {
 		c.Close()
@@ -112,6 +152,11 @@ func (c *Conn) handshake() error {
 		return err
 	}
 
+	if err := c.acquireUserConn(); err != nil {
+		_ = c.writeError(err)
+		return err
+	}
+
 	if err := c.writeOK(nil); err != nil {
 		return err
 	}
@@ -123,6 +168,15 @@ func (c *Conn) handshake() error {
 
 func (c *Conn) Close() {
 	c.closed.Store(true)
+	c.stateMu.Lock()
+	if c.cmdCancel != nil {
+		c.cmdCancel()
+	}
+	c.stateMu.Unlock()
+	if c.serverConf != nil {
+		c.serverConf.untrackConn(c)
+	}
+	c.releaseUserConn()
 	c.Conn.Close()
 }
 
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"time"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// SetIdleTimeout closes the connections idle for d, waiting for the next
+// command, like wait_timeout of MySQL, 0 to disable it, the default. They are
+// sent ER_CLIENT_INTERACTION_TIMEOUT before being closed, like MySQL 8.0.24+
+// does. See SetInteractiveTimeout for the interactive clients.
+func (s *Server) SetIdleTimeout(d time.Duration) {
+	s.idleTimeout = d
+}
+
+// SetInteractiveTimeout is the idle timeout of the clients connecting with
+// CLIENT_INTERACTIVE, like the mysql client, like interactive_timeout of MySQL.
+// The idle timeout of SetIdleTimeout is used if it is 0.
+func (s *Server) SetInteractiveTimeout(d time.Duration) {
+	s.interactiveTimeout = d
+}
+
+// SetMaxConnLifetime closes the connections open for d, 0 to disable it, the
+// default. A command in progress is completed first, the connection is closed
+// when it waits for the next command, sent ER_CONNECTION_KILLED. It spreads the
+// reconnections of long-lived pools, e.g. to move them to new backends.
+func (s *Server) SetMaxConnLifetime(d time.Duration) {
+	s.maxConnLifetime = d
+}
+
+// idleTimeout returns the idle timeout of the connection, 0 if none.
+func (c *Conn) idleTimeout() time.Duration {
+	if c.capability&mysql.CLIENT_INTERACTIVE > 0 && c.serverConf.interactiveTimeout > 0 {
+		return c.serverConf.interactiveTimeout
+	}
+	return c.serverConf.idleTimeout
+}
+
+// lifetimeDeadline returns when the lifetime of the connection ends, zero if
+// it is not limited.
+func (c *Conn) lifetimeDeadline() time.Time {
+	if c.serverConf.maxConnLifetime <= 0 {
+		return time.Time{}
+	}
+	return c.connectedAt.Add(c.serverConf.maxConnLifetime)
+}
+
+// setCommandReadDeadline sets the deadline of reading the next command, by
+// the idle timeout and the lifetime of the connection.
+func (c *Conn) setCommandReadDeadline() {
+	if c.serverConf == nil || c.netConn == nil {
+		return
+	}
+	var deadline time.Time
+	if d := c.idleTimeout(); d > 0 {
+		deadline = time.Now().Add(d)
+	}
+	if end := c.lifetimeDeadline(); !end.IsZero() && (deadline.IsZero() || end.Before(deadline)) {
+		deadline = end
+	}
+	if deadline.IsZero() {
+		return
+	}
+
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.readDeadline = deadline
+	// don't postpone the interrupt of Kill or Shutdown
+	if c.killed.Load() || c.shuttingDown() {
+		deadline = time.Now()
+	}
+	_ = c.netConn.SetReadDeadline(deadline)
+}
+
+// clearCommandReadDeadline clears the deadline once the command is read, so
+// the reads of the command aren't limited.
+func (c *Conn) clearCommandReadDeadline() {
+	if c.readDeadline.IsZero() {
+		return
+	}
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.readDeadline = time.Time{}
+	_ = c.netConn.SetReadDeadline(time.Time{})
+}
+
+// readDeadlineExceeded returns whether reading the next command failed for the
+// idle timeout or the lifetime of the connection.
+func (c *Conn) readDeadlineExceeded() bool {
+	return !c.readDeadline.IsZero() && !time.Now().Before(c.readDeadline)
+}
+
+// closeForTimeout sends the error of the idle timeout or the lifetime of the
+// connection, and closes it.
+func (c *Conn) closeForTimeout() error {
+	err := mysql.NewError(mysql.ER_CLIENT_INTERACTION_TIMEOUT, "The client was disconnected by the server because of inactivity. See wait_timeout and interactive_timeout for configuring this behavior.")
+	if end := c.lifetimeDeadline(); !end.IsZero() && !c.readDeadline.Before(end) {
+		err = mysql.NewError(mysql.ER_CONNECTION_KILLED, "Connection was killed, its maximum lifetime is exceeded")
+	}
+
+	c.ResetSequence()
+	_ = c.SetWriteDeadline(time.Now().Add(time.Second))
+	_ = c.writeError(err)
+	c.Close()
+	c.Conn = nil
+	return err
+}
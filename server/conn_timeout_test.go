@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+func TestConnTimeouts(t *testing.T) {
+	s := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	closed := make(chan error, 1)
+
+	addr := serveConns(t, func(c net.Conn) (*Conn, error) {
+		return s.NewConn(c, "root", "", EmptyHandler{})
+	}, closed)
+
+	s.SetIdleTimeout(100 * time.Millisecond)
+	conn, err := client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+	// the commands reset the idle timeout
+	for i := 0; i < 3; i++ {
+		time.Sleep(50 * time.Millisecond)
+		require.NoError(t, conn.Ping())
+	}
+	code, _ := mysql.MyErrorCode(<-closed)
+	require.EqualValues(t, mysql.ER_CLIENT_INTERACTION_TIMEOUT, code)
+	require.Error(t, conn.Ping())
+
+	s.SetIdleTimeout(0)
+	s.SetMaxConnLifetime(150 * time.Millisecond)
+	conn, err = client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+	start := time.Now()
+	require.NoError(t, conn.Ping())
+	code, _ = mysql.MyErrorCode(<-closed)
+	require.EqualValues(t, mysql.ER_CONNECTION_KILLED, code)
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	require.Error(t, conn.Ping())
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// ContextHandler is the Handler whose methods take the context of the command,
+// see Conn.Context. It is canceled when the command is completed, the query or
+// the connection is killed, see Server.Kill, or the connection is closed, so the
+// handler can cancel the work it does for the command. The context also has the
+// trace context of the query, see SetTraceContextExtraction.
+//
+// If the Handler of a Conn implements it, its methods are called instead of the
+// Handler ones. A ContextHandler alone is passed to NewConn by
+// AdaptContextHandler, a Handler is used as a ContextHandler by AdaptHandler.
+type ContextHandler interface {
+	UseDBContext(ctx context.Context, dbName string) error
+	HandleQueryContext(ctx context.Context, query string) (*mysql.Result, error)
+	HandleFieldListContext(ctx context.Context, table string, fieldWildcard string) ([]*mysql.Field, error)
+	HandleStmtPrepareContext(ctx context.Context, query string) (params int, columns int, context interface{}, err error)
+	HandleStmtExecuteContext(ctx context.Context, context interface{}, query string, args []interface{}) (*mysql.Result, error)
+	HandleStmtCloseContext(ctx context.Context, context interface{}) error
+	HandleOtherCommandContext(ctx context.Context, cmd byte, data []byte) error
+}
+
+// AdaptHandler returns h as a ContextHandler, whose methods call the ones of h
+// without the context, or h itself if it implements ContextHandler.
+func AdaptHandler(h Handler) ContextHandler {
+	if ch, ok := h.(ContextHandler); ok {
+		return ch
+	}
+	return handlerAdapter{h}
+}
+
+// AdaptContextHandler returns h as a Handler for NewConn, its Handler methods
+// call the ones of h with context.Background(). The Conn calls the methods of h
+// with the context of the commands.
+func AdaptContextHandler(h ContextHandler) Handler {
+	return contextHandlerAdapter{h}
+}
+
+// contextHandler returns the handler as a ContextHandler.
+func (c *Conn) contextHandler() ContextHandler {
+	if c.ch == nil {
+		c.ch = AdaptHandler(c.h)
+	}
+	return c.ch
+}
+
+type handlerAdapter struct {
+	h Handler
+}
+
+func (a handlerAdapter) UseDBContext(_ context.Context, dbName string) error {
+	return a.h.UseDB(dbName)
+}
+
+func (a handlerAdapter) HandleQueryContext(_ context.Context, query string) (*mysql.Result, error) {
+	return a.h.HandleQuery(query)
+}
+
+func (a handlerAdapter) HandleFieldListContext(_ context.Context, table string, fieldWildcard string) ([]*mysql.Field, error) {
+	return a.h.HandleFieldList(table, fieldWildcard)
+}
+
+func (a handlerAdapter) HandleStmtPrepareContext(_ context.Context, query string) (int, int, interface{}, error) {
+	return a.h.HandleStmtPrepare(query)
+}
+
+func (a handlerAdapter) HandleStmtExecuteContext(_ context.Context, stmtCtx interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	return a.h.HandleStmtExecute(stmtCtx, query, args)
+}
+
+func (a handlerAdapter) HandleStmtCloseContext(_ context.Context, stmtCtx interface{}) error {
+	return a.h.HandleStmtClose(stmtCtx)
+}
+
+func (a handlerAdapter) HandleOtherCommandContext(_ context.Context, cmd byte, data []byte) error {
+	return a.h.HandleOtherCommand(cmd, data)
+}
+
+type contextHandlerAdapter struct {
+	ContextHandler
+}
+
+func (a contextHandlerAdapter) UseDB(dbName string) error {
+	return a.UseDBContext(context.Background(), dbName)
+}
+
+func (a contextHandlerAdapter) HandleQuery(query string) (*mysql.Result, error) {
+	return a.HandleQueryContext(context.Background(), query)
+}
+
+func (a contextHandlerAdapter) HandleFieldList(table string, fieldWildcard string) ([]*mysql.Field, error) {
+	return a.HandleFieldListContext(context.Background(), table, fieldWildcard)
+}
+
+func (a contextHandlerAdapter) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	return a.HandleStmtPrepareContext(context.Background(), query)
+}
+
+func (a contextHandlerAdapter) HandleStmtExecute(stmtCtx interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	return a.HandleStmtExecuteContext(context.Background(), stmtCtx, query, args)
+}
+
+func (a contextHandlerAdapter) HandleStmtClose(stmtCtx interface{}) error {
+	return a.HandleStmtCloseContext(context.Background(), stmtCtx)
+}
+
+func (a contextHandlerAdapter) HandleOtherCommand(cmd byte, data []byte) error {
+	return a.HandleOtherCommandContext(context.Background(), cmd, data)
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+type slowContextHandler struct {
+	ContextHandler
+	started chan struct{}
+	done    chan error
+}
+
+func (h *slowContextHandler) HandleQueryContext(ctx context.Context, query string) (*mysql.Result, error) {
+	if query != "SLOW" {
+		return h.ContextHandler.HandleQueryContext(ctx, query)
+	}
+	h.started <- struct{}{}
+	<-ctx.Done()
+	h.done <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+func TestContextHandler(t *testing.T) {
+	require.Equal(t, handlerAdapter{EmptyHandler{}}, AdaptHandler(EmptyHandler{}))
+	h := &slowContextHandler{
+		ContextHandler: AdaptHandler(EmptyHandler{}),
+		started:        make(chan struct{}),
+		done:           make(chan error, 1),
+	}
+	require.Equal(t, h, AdaptHandler(AdaptContextHandler(h)).(contextHandlerAdapter).ContextHandler)
+
+	s := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	conns := make(chan *Conn, 1)
+	addr := serveConns(t, func(c net.Conn) (*Conn, error) {
+		conn, err := s.NewConn(c, "root", "", AdaptContextHandler(h))
+		if err == nil {
+			conns <- conn
+		}
+		return conn, err
+	}, nil)
+
+	conn, err := client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+	serverConn := <-conns
+
+	// the context is canceled by KILL QUERY
+	errs := make(chan error, 1)
+	go func() {
+		_, err := conn.Execute("SLOW")
+		errs <- err
+	}()
+	<-h.started
+	require.NoError(t, s.Kill(serverConn.ConnectionID(), true))
+	require.ErrorIs(t, <-h.done, context.Canceled)
+	require.ErrorContains(t, <-errs, "interrupted")
+
+	// and when the connection is closed
+	go func() {
+		_, err := conn.Execute("SLOW")
+		errs <- err
+	}()
+	<-h.started
+	serverConn.Close()
+	require.ErrorIs(t, <-h.done, context.Canceled)
+	require.Error(t, <-errs)
+}
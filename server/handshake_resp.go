@@ -85,6 +85,7 @@ func (c *Conn) decodeFirstPart(data []byte) (newData []byte, pos int, err error)
 
 	// connection's default character set as defined
 	c.charset = data[pos]
+	c.collation = uint16(c.charset)
 	pos++
 
 	// skip reserved 23[00]
@@ -125,7 +126,7 @@ func (c *Conn) readDb(data []byte, pos int) (int, error) {
 		db := string(data[pos : pos+bytes.IndexByte(data[pos:], 0x00)])
 		pos += len(db) + 1
 
-		if err := c.h.UseDB(db); err != nil {
+		if err := c.contextHandler().UseDBContext(c.Context(), db); err != nil {
 			return 0, err
 		}
 	}
@@ -0,0 +1,477 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// Catalog describes the databases, tables and variables of a server, for the
+// metadata queries answered by InformationSchemaHandler.
+type Catalog interface {
+	// Databases returns the databases with their tables. information_schema is
+	// added if it is not one of them.
+	Databases() ([]CatalogDatabase, error)
+	// Variables returns the system variables, like version or sql_mode, by name.
+	Variables() (map[string]string, error)
+	// Status returns the status variables, like Uptime, by name.
+	Status() (map[string]string, error)
+}
+
+// CatalogDatabase is a database of a Catalog.
+type CatalogDatabase struct {
+	Name string
+	// CharacterSet and Collation are utf8mb4 and utf8mb4_0900_ai_ci if empty.
+	CharacterSet string
+	Collation    string
+	Tables       []CatalogTable
+}
+
+// CatalogTable is a table of a CatalogDatabase.
+type CatalogTable struct {
+	Name string
+	// Type is BASE TABLE if empty, or VIEW.
+	Type string
+	// Engine is InnoDB if empty.
+	Engine    string
+	Rows      uint64
+	Collation string
+	Comment   string
+	Columns   []CatalogColumn
+}
+
+// CatalogColumn is a column of a CatalogTable.
+type CatalogColumn struct {
+	Name string
+	// Type is the column type, like int(11) unsigned or varchar(32).
+	Type     string
+	Nullable bool
+	// Key is PRI, UNI, MUL or empty.
+	Key string
+	// Default is the default value, nil if the column has none.
+	Default *string
+	// Extra is like auto_increment.
+	Extra     string
+	Collation string
+	Comment   string
+}
+
+// InformationSchemaHandler answers the metadata queries of the clients from a
+// Catalog, and passes the other queries to the handler it wraps, so a server
+// built on this package works with the GUI clients like MySQL Workbench or
+// DBeaver, which send many of them. It answers:
+//
+//	SHOW DATABASES
+//	SHOW [FULL] TABLES [FROM db] [LIKE 'pattern' | WHERE ...]
+//	SHOW [FULL] COLUMNS FROM table [FROM db] [LIKE 'pattern' | WHERE ...]
+//	SHOW [GLOBAL | SESSION] VARIABLES [LIKE 'pattern' | WHERE ...]
+//	SHOW [GLOBAL | SESSION] STATUS [LIKE 'pattern' | WHERE ...]
+//	SELECT @@version, DATABASE(), ...
+//	SELECT columns FROM information_schema.SCHEMATA [WHERE ...] [ORDER BY ...] [LIMIT ...]
+//
+// and the same SELECT of TABLES, COLUMNS, GLOBAL_VARIABLES, SESSION_VARIABLES,
+// GLOBAL_STATUS and SESSION_STATUS. The WHERE conditions are comparisons of a
+// column and a value with =, <>, LIKE or IN, joined by AND. The queries are
+// parsed with the TiDB parser, the ones it can't parse or answer are passed to
+// the handler. The values are the ones of the parser driver of the program,
+// like the test_driver of TiDB, a driver of this module is installed only if
+// it has none.
+//
+// It keeps the current database, so it is a handler per connection, like the
+// Handler passed to NewConn. It implements ContextHandler, calling the one of
+// the wrapped handler, see AdaptHandler, but not the other optional interfaces
+// like StreamingQueryHandler.
+type InformationSchemaHandler struct {
+	Handler
+
+	h       ContextHandler
+	catalog Catalog
+	db      string
+}
+
+// NewInformationSchemaHandler returns a handler answering the metadata queries
+// from catalog, and the other queries with h.
+func NewInformationSchemaHandler(h Handler, catalog Catalog) *InformationSchemaHandler {
+	return &InformationSchemaHandler{Handler: h, h: AdaptHandler(h), catalog: catalog}
+}
+
+func (h *InformationSchemaHandler) UseDB(dbName string) error {
+	return h.UseDBContext(context.Background(), dbName)
+}
+
+func (h *InformationSchemaHandler) HandleQuery(query string) (*mysql.Result, error) {
+	return h.HandleQueryContext(context.Background(), query)
+}
+
+// UseDBContext accepts information_schema, and passes the other databases to
+// the handler.
+func (h *InformationSchemaHandler) UseDBContext(ctx context.Context, dbName string) error {
+	if !strings.EqualFold(dbName, informationSchema) {
+		if err := h.h.UseDBContext(ctx, dbName); err != nil {
+			return err
+		}
+	}
+	h.db = dbName
+	return nil
+}
+
+func (h *InformationSchemaHandler) HandleQueryContext(ctx context.Context, query string) (*mysql.Result, error) {
+	if db, ok := parseUse(query); ok {
+		if err := h.UseDBContext(ctx, db); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	if r, ok, err := h.handleMetadataQuery(query); ok {
+		return r, err
+	}
+	return h.h.HandleQueryContext(ctx, query)
+}
+
+func (h *InformationSchemaHandler) HandleFieldListContext(ctx context.Context, table string, fieldWildcard string) ([]*mysql.Field, error) {
+	return h.h.HandleFieldListContext(ctx, table, fieldWildcard)
+}
+
+func (h *InformationSchemaHandler) HandleStmtPrepareContext(ctx context.Context, query string) (int, int, interface{}, error) {
+	return h.h.HandleStmtPrepareContext(ctx, query)
+}
+
+func (h *InformationSchemaHandler) HandleStmtExecuteContext(ctx context.Context, stmtCtx interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	return h.h.HandleStmtExecuteContext(ctx, stmtCtx, query, args)
+}
+
+func (h *InformationSchemaHandler) HandleStmtCloseContext(ctx context.Context, stmtCtx interface{}) error {
+	return h.h.HandleStmtCloseContext(ctx, stmtCtx)
+}
+
+func (h *InformationSchemaHandler) HandleOtherCommandContext(ctx context.Context, cmd byte, data []byte) error {
+	return h.h.HandleOtherCommandContext(ctx, cmd, data)
+}
+
+const informationSchema = "information_schema"
+
+// virtualColumn is a column of a virtualTable, its values are string or nil,
+// or uint64 if number is set.
+type virtualColumn struct {
+	name   string
+	number bool
+}
+
+// virtualTable is a table of information_schema, or the result of a SHOW.
+type virtualTable struct {
+	name    string
+	columns []virtualColumn
+	rows    [][]interface{}
+}
+
+func stringColumns(names ...string) []virtualColumn {
+	columns := make([]virtualColumn, len(names))
+	for i, name := range names {
+		columns[i] = virtualColumn{name: name}
+	}
+	return columns
+}
+
+func (t *virtualTable) findColumn(name string) int {
+	for i, c := range t.columns {
+		if strings.EqualFold(c.name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+var (
+	schemataColumns = stringColumns("CATALOG_NAME", "SCHEMA_NAME", "DEFAULT_CHARACTER_SET_NAME",
+		"DEFAULT_COLLATION_NAME", "SQL_PATH")
+	tablesColumns = []virtualColumn{
+		{name: "TABLE_CATALOG"}, {name: "TABLE_SCHEMA"}, {name: "TABLE_NAME"}, {name: "TABLE_TYPE"},
+		{name: "ENGINE"}, {name: "TABLE_ROWS", number: true}, {name: "TABLE_COLLATION"}, {name: "TABLE_COMMENT"},
+	}
+	columnsColumns = []virtualColumn{
+		{name: "TABLE_CATALOG"}, {name: "TABLE_SCHEMA"}, {name: "TABLE_NAME"}, {name: "COLUMN_NAME"},
+		{name: "ORDINAL_POSITION", number: true}, {name: "COLUMN_DEFAULT"}, {name: "IS_NULLABLE"},
+		{name: "DATA_TYPE"}, {name: "CHARACTER_SET_NAME"}, {name: "COLLATION_NAME"}, {name: "COLUMN_TYPE"},
+		{name: "COLUMN_KEY"}, {name: "EXTRA"}, {name: "PRIVILEGES"}, {name: "COLUMN_COMMENT"},
+	}
+	variablesColumns = stringColumns("VARIABLE_NAME", "VARIABLE_VALUE")
+
+	// informationSchemaTables are the tables of information_schema answered by
+	// InformationSchemaHandler.
+	informationSchemaTables = []virtualTable{
+		{name: "COLUMNS", columns: columnsColumns},
+		{name: "GLOBAL_STATUS", columns: variablesColumns},
+		{name: "GLOBAL_VARIABLES", columns: variablesColumns},
+		{name: "SCHEMATA", columns: schemataColumns},
+		{name: "SESSION_STATUS", columns: variablesColumns},
+		{name: "SESSION_VARIABLES", columns: variablesColumns},
+		{name: "TABLES", columns: tablesColumns},
+	}
+)
+
+// databases returns the databases of the catalog, with information_schema and
+// its tables.
+func (h *InformationSchemaHandler) databases() ([]CatalogDatabase, error) {
+	dbs, err := h.catalog.Databases()
+	if err != nil {
+		return nil, err
+	}
+	for _, db := range dbs {
+		if strings.EqualFold(db.Name, informationSchema) {
+			return dbs, nil
+		}
+	}
+
+	is := CatalogDatabase{Name: informationSchema, CharacterSet: "utf8mb3", Collation: "utf8mb3_general_ci"}
+	for _, t := range informationSchemaTables {
+		ct := CatalogTable{Name: t.name, Type: "SYSTEM VIEW", Engine: "InnoDB"}
+		for _, c := range t.columns {
+			typ := "varchar(64)"
+			if c.number {
+				typ = "bigint unsigned"
+			}
+			ct.Columns = append(ct.Columns, CatalogColumn{Name: c.name, Type: typ, Nullable: true})
+		}
+		is.Tables = append(is.Tables, ct)
+	}
+	return append([]CatalogDatabase{is}, dbs...), nil
+}
+
+// database returns the database name, or the current one if name is empty.
+func (h *InformationSchemaHandler) database(name string) (*CatalogDatabase, error) {
+	if name == "" {
+		if name = h.db; name == "" {
+			return nil, mysql.NewDefaultError(mysql.ER_NO_DB_ERROR)
+		}
+	}
+	dbs, err := h.databases()
+	if err != nil {
+		return nil, err
+	}
+	for i := range dbs {
+		if strings.EqualFold(dbs[i].Name, name) {
+			return &dbs[i], nil
+		}
+	}
+	return nil, mysql.NewDefaultError(mysql.ER_BAD_DB_ERROR, name)
+}
+
+// informationSchemaTable returns the rows of the table name of information_schema.
+func (h *InformationSchemaHandler) informationSchemaTable(name string) (*virtualTable, bool, error) {
+	t := &virtualTable{name: strings.ToUpper(name)}
+	switch t.name {
+	case "SCHEMATA", "TABLES", "COLUMNS":
+		dbs, err := h.databases()
+		if err != nil {
+			return nil, true, err
+		}
+		switch t.name {
+		case "SCHEMATA":
+			t.columns = schemataColumns
+			for _, db := range dbs {
+				cs, collation := databaseCharset(&db)
+				t.rows = append(t.rows, []interface{}{"def", db.Name, cs, collation, nil})
+			}
+		case "TABLES":
+			t.columns = tablesColumns
+			for _, db := range dbs {
+				_, dbCollation := databaseCharset(&db)
+				for _, ct := range db.Tables {
+					typ, engine, collation := ct.Type, ct.Engine, ct.Collation
+					if typ == "" {
+						typ = "BASE TABLE"
+					}
+					if engine == "" {
+						engine = "InnoDB"
+					}
+					if collation == "" {
+						collation = dbCollation
+					}
+					t.rows = append(t.rows, []interface{}{"def", db.Name, ct.Name, typ, engine, ct.Rows, collation, ct.Comment})
+				}
+			}
+		default:
+			t.columns = columnsColumns
+			for _, db := range dbs {
+				for _, ct := range db.Tables {
+					for i, c := range ct.Columns {
+						var def, cs, collation interface{}
+						if c.Default != nil {
+							def = *c.Default
+						}
+						if c.Collation != "" {
+							cs, _, _ = strings.Cut(c.Collation, "_")
+							collation = c.Collation
+						}
+						t.rows = append(t.rows, []interface{}{"def", db.Name, ct.Name, c.Name, uint64(i + 1), def,
+							yesNo(c.Nullable), dataType(c.Type), cs, collation, c.Type, c.Key, c.Extra,
+							"select,insert,update,references", c.Comment})
+					}
+				}
+			}
+		}
+	case "GLOBAL_VARIABLES", "SESSION_VARIABLES", "GLOBAL_STATUS", "SESSION_STATUS":
+		vars, err := h.variables(strings.HasSuffix(t.name, "_STATUS"))
+		if err != nil {
+			return nil, true, err
+		}
+		t.columns = variablesColumns
+		t.rows = variableRows(vars)
+	default:
+		return nil, false, nil
+	}
+	return t, true, nil
+}
+
+func (h *InformationSchemaHandler) variables(status bool) (map[string]string, error) {
+	if status {
+		return h.catalog.Status()
+	}
+	return h.catalog.Variables()
+}
+
+// variableRows returns the rows of vars, sorted by name.
+func variableRows(vars map[string]string) [][]interface{} {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rows := make([][]interface{}, len(names))
+	for i, name := range names {
+		rows[i] = []interface{}{name, vars[name]}
+	}
+	return rows
+}
+
+// variable returns the value of the system variable name, without its @@ and
+// scope like in @@session.sql_mode.
+func (h *InformationSchemaHandler) variable(name string) (string, error) {
+	vars, err := h.catalog.Variables()
+	if err != nil {
+		return "", err
+	}
+	if v, ok := vars[name]; ok {
+		return v, nil
+	}
+	for n, v := range vars {
+		if strings.EqualFold(n, name) {
+			return v, nil
+		}
+	}
+	return "", mysql.NewDefaultError(mysql.ER_UNKNOWN_SYSTEM_VARIABLE, name)
+}
+
+// showTables returns the result of SHOW [FULL] TABLES of db.
+func (h *InformationSchemaHandler) showTables(db string, full bool) (*virtualTable, error) {
+	cdb, err := h.database(db)
+	if err != nil {
+		return nil, err
+	}
+	t := &virtualTable{columns: stringColumns("Tables_in_" + cdb.Name)}
+	if full {
+		t.columns = append(t.columns, virtualColumn{name: "Table_type"})
+	}
+	for _, ct := range cdb.Tables {
+		row := []interface{}{ct.Name}
+		if full {
+			typ := ct.Type
+			if typ == "" {
+				typ = "BASE TABLE"
+			}
+			row = append(row, typ)
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t, nil
+}
+
+// showColumns returns the result of SHOW [FULL] COLUMNS of the table of db.
+func (h *InformationSchemaHandler) showColumns(db, table string, full bool) (*virtualTable, error) {
+	cdb, err := h.database(db)
+	if err != nil {
+		return nil, err
+	}
+	var ct *CatalogTable
+	for i := range cdb.Tables {
+		if strings.EqualFold(cdb.Tables[i].Name, table) {
+			ct = &cdb.Tables[i]
+			break
+		}
+	}
+	if ct == nil {
+		return nil, mysql.NewDefaultError(mysql.ER_NO_SUCH_TABLE, cdb.Name, table)
+	}
+
+	t := &virtualTable{columns: stringColumns("Field", "Type", "Null", "Key", "Default", "Extra")}
+	if full {
+		t.columns = stringColumns("Field", "Type", "Collation", "Null", "Key", "Default", "Extra", "Privileges", "Comment")
+	}
+	for _, c := range ct.Columns {
+		var def, collation interface{}
+		if c.Default != nil {
+			def = *c.Default
+		}
+		if c.Collation != "" {
+			collation = c.Collation
+		}
+		if full {
+			t.rows = append(t.rows, []interface{}{c.Name, c.Type, collation, yesNo(c.Nullable), c.Key, def, c.Extra,
+				"select,insert,update,references", c.Comment})
+		} else {
+			t.rows = append(t.rows, []interface{}{c.Name, c.Type, yesNo(c.Nullable), c.Key, def, c.Extra})
+		}
+	}
+	return t, nil
+}
+
+func databaseCharset(db *CatalogDatabase) (string, string) {
+	cs, collation := db.CharacterSet, db.Collation
+	if cs == "" {
+		cs = mysql.DEFAULT_CHARSET
+	}
+	if collation == "" {
+		collation = mysql.DEFAULT_COLLATION_NAME
+	}
+	return cs, collation
+}
+
+// dataType returns the data type of the column type typ, like int of
+// int(11) unsigned.
+func dataType(typ string) string {
+	if i := strings.IndexAny(typ, "( "); i >= 0 {
+		typ = typ[:i]
+	}
+	return strings.ToLower(typ)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "YES"
+	}
+	return "NO"
+}
+
+// result returns the resultset of the rows of t, with the columns of t.
+func (t *virtualTable) result(names []string) (*mysql.Result, error) {
+	b := mysql.NewResultsetBuilder(false)
+	for i, c := range t.columns {
+		if c.number {
+			b.AddColumn(names[i], mysql.MYSQL_TYPE_LONGLONG, mysql.ColumnUnsigned())
+		} else {
+			b.AddColumn(names[i], mysql.MYSQL_TYPE_VAR_STRING)
+		}
+	}
+	for _, row := range t.rows {
+		if err := b.AddRow(row...); err != nil {
+			return nil, err
+		}
+	}
+	r, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return mysql.NewResult(r), nil
+}
@@ -0,0 +1,731 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/tidb/pkg/parser"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/opcode"
+
+	"github.com/gongzhxu/go-mysql/internal/parserdriver"
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+var sqlParsers = sync.Pool{New: func() interface{} { return parser.New() }}
+
+// parseStatement parses query with the TiDB parser if it is a single statement
+// starting with one of keywords, see leadingKeyword, not to parse the queries
+// it doesn't answer.
+func parseStatement(query string, keywords ...string) (ast.StmtNode, bool) {
+	kw := leadingKeyword(query)
+	if kw == "" || !slices.Contains(keywords, kw) {
+		return nil, false
+	}
+	// the values are the ones of the driver of the program, if any
+	parserdriver.Ensure()
+	p := sqlParsers.Get().(*parser.Parser)
+	defer sqlParsers.Put(p)
+	stmts, _, err := p.Parse(query, "", "")
+	if err != nil || len(stmts) != 1 {
+		return nil, false
+	}
+	return stmts[0], true
+}
+
+// literal returns the value of a string or number literal, as a string as its
+// text, or nil for NULL. The values are read through ast.ValueExpr, from the
+// types of any parser driver.
+func literal(expr ast.ExprNode) (interface{}, bool) {
+	if u, ok := expr.(*ast.UnaryOperationExpr); ok && (u.Op == opcode.Minus || u.Op == opcode.Plus) {
+		// a signed number, like -1
+		v, ok := u.V.(ast.ValueExpr)
+		if !ok {
+			return nil, false
+		}
+		n, ok := numberText(v.GetValue())
+		if ok && u.Op == opcode.Minus {
+			n = "-" + n
+		}
+		return n, ok
+	}
+	v, ok := expr.(ast.ValueExpr)
+	if !ok {
+		return nil, false
+	}
+	switch x := v.GetValue().(type) {
+	case nil:
+		return nil, true
+	case string:
+		return x, true
+	case []byte:
+		return string(x), true
+	default:
+		return numberText(x)
+	}
+}
+
+func numberText(v interface{}) (string, bool) {
+	switch x := v.(type) {
+	case int64:
+		return strconv.FormatInt(x, 10), true
+	case uint64:
+		return strconv.FormatUint(x, 10), true
+	case float32:
+		return strconv.FormatFloat(float64(x), 'g', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64), true
+	case fmt.Stringer:
+		// a decimal, but not a hexadecimal or bit literal like 0x41
+		s := x.String()
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// condition is a comparison of a column and values of a WHERE clause.
+type condition struct {
+	column string
+	// like is set for LIKE, else the column is compared to be equal to one of
+	// the values, for = or IN
+	like   bool
+	not    bool
+	values []interface{}
+}
+
+// conditions returns the conditions of expr, comparisons joined by AND, db is
+// the current database, for DATABASE().
+func conditions(expr ast.ExprNode, db string) ([]condition, bool) {
+	var c condition
+	var values []ast.ExprNode
+	switch e := expr.(type) {
+	case *ast.ParenthesesExpr:
+		return conditions(e.Expr, db)
+	case *ast.BinaryOperationExpr:
+		switch e.Op {
+		case opcode.LogicAnd:
+			left, ok := conditions(e.L, db)
+			if !ok {
+				return nil, false
+			}
+			right, ok := conditions(e.R, db)
+			return append(left, right...), ok
+		case opcode.EQ:
+		case opcode.NE:
+			c.not = true
+		default:
+			return nil, false
+		}
+		expr, values = e.L, []ast.ExprNode{e.R}
+	case *ast.PatternLikeOrIlikeExpr:
+		if !e.IsLike || e.Escape != '\\' {
+			return nil, false
+		}
+		c.like, c.not = true, e.Not
+		expr, values = e.Expr, []ast.ExprNode{e.Pattern}
+	case *ast.PatternInExpr:
+		if e.Sel != nil {
+			return nil, false
+		}
+		c.not = e.Not
+		expr, values = e.Expr, e.List
+	default:
+		return nil, false
+	}
+
+	column, ok := expr.(*ast.ColumnNameExpr)
+	if !ok {
+		return nil, false
+	}
+	c.column = column.Name.Name.O
+	for _, v := range values {
+		value, ok := conditionValue(v, db)
+		if !ok {
+			return nil, false
+		}
+		c.values = append(c.values, value)
+	}
+	return []condition{c}, true
+}
+
+// conditionValue returns the value of a literal or of DATABASE(), nil if it is
+// NULL.
+func conditionValue(expr ast.ExprNode, db string) (interface{}, bool) {
+	if f, ok := expr.(*ast.FuncCallExpr); ok {
+		switch f.FnName.L {
+		case "database", "schema":
+			if len(f.Args) > 0 {
+				return nil, false
+			}
+			if db == "" {
+				return nil, true
+			}
+			return db, true
+		}
+		return nil, false
+	}
+	return literal(expr)
+}
+
+// likeRegexp returns the regexp of the LIKE pattern, case insensitive.
+func likeRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+func valueString(v interface{}) string {
+	switch v := v.(type) {
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case string:
+		return v
+	}
+	return ""
+}
+
+// equalValue compares the value of a row to the one of a condition, the
+// numbers by value and the strings case insensitively.
+func equalValue(value interface{}, v interface{}) bool {
+	if n, ok := value.(uint64); ok {
+		if f, err := strconv.ParseFloat(valueString(v), 64); err == nil {
+			return float64(n) == f
+		}
+	}
+	return strings.EqualFold(valueString(value), valueString(v))
+}
+
+// filter removes the rows of t not matching conds. clause is the name of the
+// clause in the error of an unknown column.
+func (t *virtualTable) filter(conds []condition, clause string) error {
+	for _, c := range conds {
+		i := t.findColumn(c.column)
+		if i < 0 {
+			return mysql.NewDefaultError(mysql.ER_BAD_FIELD_ERROR, c.column, clause)
+		}
+		var res []*regexp.Regexp
+		if c.like {
+			for _, v := range c.values {
+				if v != nil {
+					re, err := likeRegexp(valueString(v))
+					if err != nil {
+						return err
+					}
+					res = append(res, re)
+				}
+			}
+		}
+
+		rows := t.rows[:0]
+		for _, row := range t.rows {
+			if row[i] == nil {
+				// a comparison with NULL is never true
+				continue
+			}
+			s := valueString(row[i])
+			match, compared := false, false
+			if c.like {
+				for _, re := range res {
+					match = match || re.MatchString(s)
+					compared = true
+				}
+			} else {
+				for _, v := range c.values {
+					if v != nil {
+						match = match || equalValue(row[i], v)
+						compared = true
+					}
+				}
+			}
+			if compared && match != c.not {
+				rows = append(rows, row)
+			}
+		}
+		t.rows = rows
+	}
+	return nil
+}
+
+type orderItem struct {
+	column string
+	desc   bool
+}
+
+// sort sorts the rows of t by order, the numbers by value and the strings case
+// insensitively, NULL first.
+func (t *virtualTable) sort(order []orderItem) error {
+	indexes := make([]int, len(order))
+	for i, o := range order {
+		if indexes[i] = t.findColumn(o.column); indexes[i] < 0 {
+			return mysql.NewDefaultError(mysql.ER_BAD_FIELD_ERROR, o.column, "order clause")
+		}
+	}
+	sort.SliceStable(t.rows, func(a, b int) bool {
+		for i, o := range order {
+			c := compareValues(t.rows[a][indexes[i]], t.rows[b][indexes[i]])
+			if c != 0 {
+				return c < 0 != o.desc
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+func compareValues(a, b interface{}) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	if x, ok := a.(uint64); ok {
+		if y, ok := b.(uint64); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(strings.ToLower(valueString(a)), strings.ToLower(valueString(b)))
+}
+
+// selectItem is an expression of a select list: a column, *, a system variable
+// or a function.
+type selectItem struct {
+	star     bool
+	column   string
+	variable string
+	// global is the scope of the variable
+	global   bool
+	function string
+	// name is the name of the column of the result
+	name string
+}
+
+// metadataQuery is a query answered by InformationSchemaHandler, a SHOW or a
+// SELECT.
+type metadataQuery struct {
+	show      string
+	full      bool
+	global    bool
+	db, table string
+	like      interface{}
+	hasLike   bool
+
+	items   []selectItem
+	from    string
+	where   []condition
+	orderBy []orderItem
+	limit   int
+	offset  int
+}
+
+// leadingKeyword returns the first word of query in upper case, after the
+// comments, like /* ApplicationName=DBeaver */ of the queries of DBeaver. The
+// text of the conditional comments, like /*!40101 SET NAMES utf8 */, is not a
+// comment.
+func leadingKeyword(query string) string {
+	for {
+		query = strings.TrimLeft(query, " \t\r\n")
+		if strings.HasPrefix(query, "/*!") {
+			query = strings.TrimLeft(query[3:], "0123456789")
+			continue
+		}
+		if !strings.HasPrefix(query, "/*") {
+			break
+		}
+		end := strings.Index(query, "*/")
+		if end < 0 {
+			return ""
+		}
+		query = query[end+2:]
+	}
+	i := 0
+	for i < len(query) && isIdentChar(query[i]) {
+		i++
+	}
+	return strings.ToUpper(query[:i])
+}
+
+func isIdentChar(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' ||
+		ch == '_' || ch == '$' || ch >= 0x80
+}
+
+// parseUse parses USE db.
+func parseUse(query string) (string, bool) {
+	stmt, ok := parseStatement(query, "USE")
+	if !ok {
+		return "", false
+	}
+	use, ok := stmt.(*ast.UseStmt)
+	if !ok {
+		return "", false
+	}
+	return use.DBName, true
+}
+
+// parseMetadataQuery parses a query answered by InformationSchemaHandler, db is
+// the current database.
+func parseMetadataQuery(query string, db string) (*metadataQuery, bool) {
+	stmt, ok := parseStatement(query, "SHOW", "SELECT")
+	if !ok {
+		return nil, false
+	}
+	mq := &metadataQuery{limit: -1}
+	switch stmt := stmt.(type) {
+	case *ast.ShowStmt:
+		ok = mq.parseShow(stmt, db)
+	case *ast.SelectStmt:
+		ok = mq.parseSelect(stmt, db)
+	default:
+		ok = false
+	}
+	if !ok {
+		return nil, false
+	}
+	return mq, true
+}
+
+func (mq *metadataQuery) parseShow(stmt *ast.ShowStmt, db string) bool {
+	mq.full = stmt.Full
+	mq.global = stmt.GlobalScope
+	switch stmt.Tp {
+	case ast.ShowDatabases:
+		mq.show = "DATABASES"
+	case ast.ShowTables:
+		mq.show = "TABLES"
+		mq.db = stmt.DBName
+	case ast.ShowColumns:
+		if stmt.Extended {
+			return false
+		}
+		mq.show = "COLUMNS"
+		mq.db, mq.table = stmt.Table.Schema.O, stmt.Table.Name.O
+		if stmt.DBName != "" {
+			mq.db = stmt.DBName
+		}
+	case ast.ShowVariables:
+		mq.show = "VARIABLES"
+	case ast.ShowStatus:
+		mq.show = "STATUS"
+	default:
+		return false
+	}
+
+	var ok bool
+	switch {
+	case stmt.Pattern != nil:
+		if stmt.Pattern.Escape != '\\' {
+			return false
+		}
+		mq.like, ok = literal(stmt.Pattern.Pattern)
+		mq.hasLike = true
+		return ok
+	case stmt.Where != nil:
+		mq.where, ok = conditions(stmt.Where, db)
+		return ok
+	}
+	return true
+}
+
+func (mq *metadataQuery) parseSelect(stmt *ast.SelectStmt, db string) bool {
+	if stmt.Kind != ast.SelectStmtKindSelect || stmt.Distinct || stmt.GroupBy != nil || stmt.Having != nil ||
+		stmt.WindowSpecs != nil || stmt.LockInfo != nil || stmt.SelectIntoOpt != nil || stmt.With != nil {
+		return false
+	}
+	for _, f := range stmt.Fields.Fields {
+		var item selectItem
+		if f.WildCard != nil {
+			item.star = true
+			mq.items = append(mq.items, item)
+			continue
+		}
+		switch e := f.Expr.(type) {
+		case *ast.ColumnNameExpr:
+			item.column, item.name = e.Name.Name.O, e.Name.Name.O
+		case *ast.VariableExpr:
+			if !e.IsSystem {
+				return false
+			}
+			item.variable, item.global, item.name = e.Name, e.IsGlobal, f.Text()
+		case *ast.FuncCallExpr:
+			if len(e.Args) > 0 {
+				return false
+			}
+			item.function, item.name = strings.ToUpper(e.FnName.L), f.Text()
+		default:
+			return false
+		}
+		if f.AsName.O != "" {
+			item.name = f.AsName.O
+		}
+		mq.items = append(mq.items, item)
+	}
+
+	if stmt.From == nil {
+		for _, item := range mq.items {
+			if item.star || item.column != "" {
+				return false
+			}
+			switch item.function {
+			case "", "DATABASE", "SCHEMA", "VERSION":
+			default:
+				return false
+			}
+		}
+		return stmt.Where == nil && stmt.OrderBy == nil && stmt.Limit == nil
+	}
+
+	join := stmt.From.TableRefs
+	source, ok := join.Left.(*ast.TableSource)
+	if !ok || join.Right != nil {
+		return false
+	}
+	table, ok := source.Source.(*ast.TableName)
+	if !ok {
+		return false
+	}
+	schema := table.Schema.O
+	if schema == "" {
+		schema = db
+	}
+	if !strings.EqualFold(schema, informationSchema) {
+		return false
+	}
+	mq.from = table.Name.O
+	for _, item := range mq.items {
+		if item.variable != "" || item.function != "" {
+			return false
+		}
+	}
+
+	if stmt.Where != nil {
+		if mq.where, ok = conditions(stmt.Where, db); !ok {
+			return false
+		}
+	}
+	if stmt.OrderBy != nil {
+		for _, by := range stmt.OrderBy.Items {
+			column, ok := by.Expr.(*ast.ColumnNameExpr)
+			if !ok {
+				return false
+			}
+			mq.orderBy = append(mq.orderBy, orderItem{column: column.Name.Name.O, desc: by.Desc})
+		}
+	}
+	if stmt.Limit != nil {
+		if mq.limit, ok = number(stmt.Limit.Count); !ok {
+			return false
+		}
+		if stmt.Limit.Offset != nil {
+			if mq.offset, ok = number(stmt.Limit.Offset); !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// number returns the value of a number of LIMIT.
+func number(expr ast.ExprNode) (int, bool) {
+	v, ok := expr.(ast.ValueExpr)
+	if !ok {
+		return 0, false
+	}
+	switch n := v.GetValue().(type) {
+	case uint64:
+		return int(n), n <= math.MaxInt32
+	case int64:
+		return int(n), n >= 0 && n <= math.MaxInt32
+	}
+	return 0, false
+}
+
+// handleMetadataQuery answers query if it is a metadata query, and returns
+// whether it is.
+func (h *InformationSchemaHandler) handleMetadataQuery(query string) (*mysql.Result, bool, error) {
+	mq, ok := parseMetadataQuery(query, h.db)
+	if !ok {
+		return nil, false, nil
+	}
+	var r *mysql.Result
+	var err error
+	switch {
+	case mq.show != "":
+		r, err = h.show(mq)
+	case mq.from != "":
+		var t *virtualTable
+		if t, ok, err = h.informationSchemaTable(mq.from); !ok {
+			return nil, false, nil
+		} else if err == nil {
+			r, err = t.selectRows(mq)
+		}
+	default:
+		r, err = h.selectValues(mq)
+	}
+	return r, true, err
+}
+
+func (h *InformationSchemaHandler) show(mq *metadataQuery) (*mysql.Result, error) {
+	var t *virtualTable
+	var err error
+	switch mq.show {
+	case "DATABASES":
+		var dbs []CatalogDatabase
+		if dbs, err = h.databases(); err == nil {
+			t = &virtualTable{columns: stringColumns("Database")}
+			for _, db := range dbs {
+				t.rows = append(t.rows, []interface{}{db.Name})
+			}
+		}
+	case "TABLES":
+		t, err = h.showTables(mq.db, mq.full)
+	case "COLUMNS":
+		t, err = h.showColumns(mq.db, mq.table, mq.full)
+	default:
+		var vars map[string]string
+		if vars, err = h.variables(mq.show == "STATUS"); err == nil {
+			t = &virtualTable{columns: stringColumns("Variable_name", "Value"), rows: variableRows(vars)}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t.showResult(mq)
+}
+
+// showResult returns the rows of t matching the LIKE or WHERE of the SHOW mq.
+func (t *virtualTable) showResult(mq *metadataQuery) (*mysql.Result, error) {
+	conds := mq.where
+	if mq.hasLike {
+		conds = []condition{{column: t.columns[0].name, like: true, values: []interface{}{mq.like}}}
+	}
+	if err := t.filter(conds, "where clause"); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		names[i] = c.name
+	}
+	return t.result(names)
+}
+
+// selectRows returns the rows of t of the SELECT mq.
+func (t *virtualTable) selectRows(mq *metadataQuery) (*mysql.Result, error) {
+	if err := t.filter(mq.where, "where clause"); err != nil {
+		return nil, err
+	}
+	// ORDER BY may use the names of the select list
+	order := make([]orderItem, len(mq.orderBy))
+	for i, o := range mq.orderBy {
+		order[i] = o
+		if t.findColumn(o.column) >= 0 {
+			continue
+		}
+		for _, item := range mq.items {
+			if item.column != "" && strings.EqualFold(item.name, o.column) {
+				order[i].column = item.column
+			}
+		}
+	}
+	if err := t.sort(order); err != nil {
+		return nil, err
+	}
+	if mq.offset > len(t.rows) {
+		mq.offset = len(t.rows)
+	}
+	t.rows = t.rows[mq.offset:]
+	if mq.limit >= 0 && mq.limit < len(t.rows) {
+		t.rows = t.rows[:mq.limit]
+	}
+
+	var indexes []int
+	var names []string
+	out := &virtualTable{name: t.name}
+	for _, item := range mq.items {
+		if item.star {
+			for i, c := range t.columns {
+				indexes = append(indexes, i)
+				names = append(names, c.name)
+				out.columns = append(out.columns, c)
+			}
+			continue
+		}
+		i := t.findColumn(item.column)
+		if i < 0 {
+			return nil, mysql.NewDefaultError(mysql.ER_BAD_FIELD_ERROR, item.column, "field list")
+		}
+		indexes = append(indexes, i)
+		names = append(names, item.name)
+		out.columns = append(out.columns, t.columns[i])
+	}
+	for _, row := range t.rows {
+		values := make([]interface{}, len(indexes))
+		for i, index := range indexes {
+			values[i] = row[index]
+		}
+		out.rows = append(out.rows, values)
+	}
+	return out.result(names)
+}
+
+// selectValues returns the row of the SELECT mq of variables and functions.
+func (h *InformationSchemaHandler) selectValues(mq *metadataQuery) (*mysql.Result, error) {
+	t := &virtualTable{columns: make([]virtualColumn, len(mq.items))}
+	names := make([]string, len(mq.items))
+	row := make([]interface{}, len(mq.items))
+	for i, item := range mq.items {
+		t.columns[i].name, names[i] = item.name, item.name
+		switch item.function {
+		case "DATABASE", "SCHEMA":
+			if h.db != "" {
+				row[i] = h.db
+			}
+		case "VERSION":
+			v, err := h.variable("version")
+			if err != nil {
+				return nil, err
+			}
+			row[i] = v
+		default:
+			v, err := h.variable(item.variable)
+			if err != nil {
+				return nil, err
+			}
+			row[i] = v
+		}
+	}
+	t.rows = [][]interface{}{row}
+	return t.result(names)
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/test_driver"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/internal/parserdriver"
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+type testCatalog struct{}
+
+func (testCatalog) Databases() ([]CatalogDatabase, error) {
+	def := "0"
+	return []CatalogDatabase{{
+		Name: "shop",
+		Tables: []CatalogTable{
+			{Name: "orders", Rows: 10, Columns: []CatalogColumn{
+				{Name: "id", Type: "bigint unsigned", Key: "PRI", Extra: "auto_increment"},
+				{Name: "amount", Type: "decimal(10,2)", Nullable: true, Default: &def},
+				{Name: "note", Type: "varchar(255)", Nullable: true, Collation: "utf8mb4_general_ci"},
+			}},
+			{Name: "customers", Type: "VIEW"},
+		},
+	}}, nil
+}
+
+func (testCatalog) Variables() (map[string]string, error) {
+	return map[string]string{"version": "8.0.36", "sql_mode": "STRICT_TRANS_TABLES", "autocommit": "ON"}, nil
+}
+
+func (testCatalog) Status() (map[string]string, error) {
+	return map[string]string{"Uptime": "42", "Threads_connected": "1"}, nil
+}
+
+func queryRows(t *testing.T, h Handler, query string) ([]string, [][]interface{}) {
+	t.Helper()
+	r, err := h.HandleQuery(query)
+	require.NoError(t, err, query)
+	require.NotNil(t, r.Resultset, query)
+
+	names := make([]string, len(r.Fields))
+	for i, f := range r.Fields {
+		names[i] = string(f.Name)
+	}
+	rows := make([][]interface{}, len(r.RowDatas))
+	for i, data := range r.RowDatas {
+		values, err := data.Parse(r.Fields, false, nil)
+		require.NoError(t, err)
+		for _, v := range values {
+			rows[i] = append(rows[i], v.Value())
+		}
+	}
+	return names, rows
+}
+
+func TestInformationSchemaHandler(t *testing.T) {
+	h := NewInformationSchemaHandler(EmptyHandler{}, testCatalog{})
+
+	names, rows := queryRows(t, h, "SHOW DATABASES")
+	require.Equal(t, []string{"Database"}, names)
+	require.Equal(t, [][]interface{}{{[]byte("information_schema")}, {[]byte("shop")}}, rows)
+
+	_, err := h.HandleQuery("SHOW TABLES")
+	require.Equal(t, mysql.ER_NO_DB_ERROR, int(err.(*mysql.MyError).Code))
+	_, err = h.HandleQuery("SHOW TABLES FROM nope")
+	require.Equal(t, mysql.ER_BAD_DB_ERROR, int(err.(*mysql.MyError).Code))
+
+	r, err := h.HandleQuery("USE `shop`")
+	require.NoError(t, err)
+	require.Nil(t, r)
+	names, rows = queryRows(t, h, "show full tables like 'c%';")
+	require.Equal(t, []string{"Tables_in_shop", "Table_type"}, names)
+	require.Equal(t, [][]interface{}{{[]byte("customers"), []byte("VIEW")}}, rows)
+
+	names, rows = queryRows(t, h, "SHOW COLUMNS FROM shop.orders WHERE `Null` = 'YES'")
+	require.Equal(t, []string{"Field", "Type", "Null", "Key", "Default", "Extra"}, names)
+	require.Equal(t, [][]interface{}{
+		{[]byte("amount"), []byte("decimal(10,2)"), []byte("YES"), []byte(nil), []byte("0"), []byte(nil)},
+		{[]byte("note"), []byte("varchar(255)"), []byte("YES"), []byte(nil), nil, []byte(nil)},
+	}, rows)
+	_, err = h.HandleQuery("SHOW COLUMNS FROM nope")
+	require.Equal(t, mysql.ER_NO_SUCH_TABLE, int(err.(*mysql.MyError).Code))
+
+	names, rows = queryRows(t, h, "SHOW SESSION VARIABLES LIKE 'sql\\_mode'")
+	require.Equal(t, []string{"Variable_name", "Value"}, names)
+	require.Equal(t, [][]interface{}{{[]byte("sql_mode"), []byte("STRICT_TRANS_TABLES")}}, rows)
+	_, rows = queryRows(t, h, "SHOW GLOBAL STATUS")
+	require.Equal(t, [][]interface{}{{[]byte("Threads_connected"), []byte("1")}, {[]byte("Uptime"), []byte("42")}}, rows)
+
+	names, rows = queryRows(t, h, "/* ApplicationName=DBeaver */ SELECT @@session.autocommit, DATABASE() AS db, version()")
+	require.Equal(t, []string{"@@session.autocommit", "db", "version()"}, names)
+	require.Equal(t, [][]interface{}{{[]byte("ON"), []byte("shop"), []byte("8.0.36")}}, rows)
+	_, err = h.HandleQuery("SELECT @@nope")
+	require.Equal(t, mysql.ER_UNKNOWN_SYSTEM_VARIABLE, int(err.(*mysql.MyError).Code))
+
+	names, rows = queryRows(t, h, `SELECT c.COLUMN_NAME name, ORDINAL_POSITION FROM information_schema.COLUMNS c
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME IN ('orders', 'x') AND COLUMN_KEY <> 'PRI'
+		ORDER BY name DESC LIMIT 1`)
+	require.Equal(t, []string{"name", "ORDINAL_POSITION"}, names)
+	require.Equal(t, [][]interface{}{{[]byte("note"), uint64(3)}}, rows)
+
+	_, rows = queryRows(t, h, "select table_name, table_rows from information_schema.tables where table_schema = 'shop' and table_type like 'base%'")
+	require.Equal(t, [][]interface{}{{[]byte("orders"), uint64(10)}}, rows)
+	_, rows = queryRows(t, h, "SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_NAME = 'orders' AND ORDINAL_POSITION IN (-1, 1.5, 3)")
+	require.Equal(t, [][]interface{}{{[]byte("note")}}, rows)
+	_, rows = queryRows(t, h, "/*!40101 SHOW TABLES LIKE 'o%' */")
+	require.Equal(t, [][]interface{}{{[]byte("orders")}}, rows)
+	_, rows = queryRows(t, h, "SELECT SCHEMA_NAME FROM information_schema.SCHEMATA ORDER BY SCHEMA_NAME DESC LIMIT 1, 1")
+	require.Equal(t, [][]interface{}{{[]byte("information_schema")}}, rows)
+	_, err = h.HandleQuery("SELECT nope FROM information_schema.SCHEMATA")
+	require.Equal(t, mysql.ER_BAD_FIELD_ERROR, int(err.(*mysql.MyError).Code))
+
+	require.NoError(t, h.UseDB("information_schema"))
+	_, rows = queryRows(t, h, "SELECT * FROM GLOBAL_VARIABLES WHERE VARIABLE_NAME = 'VERSION'")
+	require.Equal(t, [][]interface{}{{[]byte("version"), []byte("8.0.36")}}, rows)
+	_, rows = queryRows(t, h, "SHOW TABLES LIKE 'SCHEMATA'")
+	require.Equal(t, [][]interface{}{{[]byte("SCHEMATA")}}, rows)
+
+	// the other queries are passed to the handler
+	for _, query := range []string{
+		"SELECT COUNT(*) FROM information_schema.TABLES",
+		"SELECT * FROM information_schema.ROUTINES",
+		"SELECT id FROM orders",
+		"SHOW CREATE TABLE orders",
+	} {
+		_, err = h.HandleQuery(query)
+		require.EqualError(t, err, "not supported now", query)
+	}
+}
+
+func TestInformationSchemaQueries(t *testing.T) {
+	tests := []struct {
+		query string
+		names []string
+		rows  [][]interface{}
+	}{
+		{"SELECT DATABASE(), SCHEMA(), @@GLOBAL.version, @@sql_mode",
+			[]string{"DATABASE()", "SCHEMA()", "@@GLOBAL.version", "@@sql_mode"},
+			[][]interface{}{{nil, nil, []byte("8.0.36"), []byte("STRICT_TRANS_TABLES")}}},
+		{"SHOW DATABASES LIKE 's%'", []string{"Database"}, [][]interface{}{{[]byte("shop")}}},
+		{"SHOW DATABASES WHERE `Database` <> 'shop'", []string{"Database"}, [][]interface{}{{[]byte("information_schema")}}},
+		{"SHOW TABLES FROM shop", []string{"Tables_in_shop"}, [][]interface{}{{[]byte("orders")}, {[]byte("customers")}}},
+		{"SHOW TABLES IN shop LIKE 'o%'", []string{"Tables_in_shop"}, [][]interface{}{{[]byte("orders")}}},
+		{"SHOW FULL TABLES FROM `shop` WHERE Table_type = 'VIEW'",
+			[]string{"Tables_in_shop", "Table_type"},
+			[][]interface{}{{[]byte("customers"), []byte("VIEW")}}},
+		{"SHOW FULL COLUMNS FROM orders FROM shop LIKE 'a%'",
+			[]string{"Field", "Type", "Collation", "Null", "Key", "Default", "Extra", "Privileges", "Comment"},
+			[][]interface{}{{[]byte("amount"), []byte("decimal(10,2)"), nil, []byte("YES"), []byte(nil), []byte("0"), []byte(nil), []byte("select,insert,update,references"), []byte(nil)}}},
+		{"SHOW COLUMNS FROM `orders` IN `shop` WHERE Field IN ('id', 'note') AND `Key` = 'PRI'",
+			[]string{"Field", "Type", "Null", "Key", "Default", "Extra"},
+			[][]interface{}{{[]byte("id"), []byte("bigint unsigned"), []byte("NO"), []byte("PRI"), nil, []byte("auto_increment")}}},
+		{"SHOW GLOBAL VARIABLES WHERE Variable_name IN ('version', 'autocommit')",
+			[]string{"Variable_name", "Value"},
+			[][]interface{}{{[]byte("autocommit"), []byte("ON")}, {[]byte("version"), []byte("8.0.36")}}},
+		{"SHOW VARIABLES WHERE Variable_name NOT LIKE 's%'",
+			[]string{"Variable_name", "Value"},
+			[][]interface{}{{[]byte("autocommit"), []byte("ON")}, {[]byte("version"), []byte("8.0.36")}}},
+		{"SHOW STATUS LIKE 'Up%'", []string{"Variable_name", "Value"}, [][]interface{}{{[]byte("Uptime"), []byte("42")}}},
+		{"SHOW SESSION STATUS WHERE Variable_name = 'Uptime'", []string{"Variable_name", "Value"}, [][]interface{}{{[]byte("Uptime"), []byte("42")}}},
+		{"SELECT SCHEMA_NAME, DEFAULT_CHARACTER_SET_NAME, DEFAULT_COLLATION_NAME FROM information_schema.SCHEMATA WHERE SCHEMA_NAME NOT LIKE 'info%'",
+			[]string{"SCHEMA_NAME", "DEFAULT_CHARACTER_SET_NAME", "DEFAULT_COLLATION_NAME"},
+			[][]interface{}{{[]byte("shop"), []byte("utf8mb4"), []byte("utf8mb4_0900_ai_ci")}}},
+		{"SELECT TABLE_NAME, TABLE_TYPE, ENGINE FROM information_schema.TABLES WHERE TABLE_SCHEMA = 'shop' AND TABLE_NAME NOT IN ('orders') ORDER BY TABLE_NAME",
+			[]string{"TABLE_NAME", "TABLE_TYPE", "ENGINE"},
+			[][]interface{}{{[]byte("customers"), []byte("VIEW"), []byte("InnoDB")}}},
+		{"SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = 'shop' ORDER BY TABLE_TYPE, TABLE_NAME DESC LIMIT 1 OFFSET 1",
+			[]string{"TABLE_NAME"}, [][]interface{}{{[]byte("customers")}}},
+		{"SELECT COLUMN_NAME, DATA_TYPE, IS_NULLABLE, COLUMN_DEFAULT, COLLATION_NAME FROM information_schema.`COLUMNS` WHERE (TABLE_SCHEMA = 'shop') AND (TABLE_NAME = 'orders') ORDER BY ORDINAL_POSITION",
+			[]string{"COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE", "COLUMN_DEFAULT", "COLLATION_NAME"},
+			[][]interface{}{
+				{[]byte("id"), []byte("bigint"), []byte("NO"), nil, nil},
+				{[]byte("amount"), []byte("decimal"), []byte("YES"), []byte("0"), nil},
+				{[]byte("note"), []byte("varchar"), []byte("YES"), nil, []byte("utf8mb4_general_ci")},
+			}},
+		{"SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_NAME = 'orders' AND ORDINAL_POSITION IN (2.0, '3')",
+			[]string{"COLUMN_NAME"}, [][]interface{}{{[]byte("amount")}, {[]byte("note")}}},
+		{"SELECT * FROM information_schema.SESSION_VARIABLES WHERE VARIABLE_NAME LIKE 'auto%'",
+			[]string{"VARIABLE_NAME", "VARIABLE_VALUE"}, [][]interface{}{{[]byte("autocommit"), []byte("ON")}}},
+		{"SELECT VARIABLE_VALUE FROM information_schema.GLOBAL_STATUS WHERE VARIABLE_NAME = 'UPTIME'",
+			[]string{"VARIABLE_VALUE"}, [][]interface{}{{[]byte("42")}}},
+		{"SELECT VARIABLE_NAME FROM information_schema.SESSION_STATUS ORDER BY VARIABLE_NAME DESC",
+			[]string{"VARIABLE_NAME"}, [][]interface{}{{[]byte("Uptime")}, {[]byte("Threads_connected")}}},
+	}
+
+	run := func(t *testing.T) {
+		h := NewInformationSchemaHandler(EmptyHandler{}, testCatalog{})
+		for _, test := range tests {
+			names, rows := queryRows(t, h, test.query)
+			require.Equal(t, test.names, names, test.query)
+			require.Equal(t, test.rows, rows, test.query)
+		}
+
+		// the queries not answered are passed to the handler
+		for _, query := range []string{
+			"SELECT @@version; SELECT 1",
+			"SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = 'shop' OR TABLE_NAME = 'x'",
+			"SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE ORDINAL_POSITION IN (SELECT 1)",
+			"SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE ORDINAL_POSITION = 0x01",
+			"SHOW TABLES LIKE CONCAT('o', '%')",
+		} {
+			_, err := h.HandleQuery(query)
+			require.EqualError(t, err, "not supported now", query)
+		}
+	}
+
+	// the values of the driver of the program, the driver of the test is kept
+	t.Run("test_driver", run)
+	_, ok := ast.NewValueExpr(1, "", "").(*test_driver.ValueExpr)
+	require.True(t, ok)
+
+	// and the ones of parserdriver
+	newValueExpr, newParamMarkerExpr := ast.NewValueExpr, ast.NewParamMarkerExpr
+	newDecimal, newHexLiteral, newBitLiteral := ast.NewDecimal, ast.NewHexLiteral, ast.NewBitLiteral
+	defer func() {
+		ast.NewValueExpr, ast.NewParamMarkerExpr = newValueExpr, newParamMarkerExpr
+		ast.NewDecimal, ast.NewHexLiteral, ast.NewBitLiteral = newDecimal, newHexLiteral, newBitLiteral
+	}()
+	parserdriver.Install()
+	t.Run("parserdriver", run)
+}
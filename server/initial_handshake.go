@@ -47,7 +47,7 @@ func (c *Conn) writeInitialHandshake() error {
 	data = append(data, 0x00)
 
 	// auth plugin name
-	data = append(data, c.serverConf.defaultAuthMethod...)
+	data = append(data, c.serverConf.handshakeAuthMethod()...)
 
 	// EOF if MySQL version (>= 5.5.7 and < 5.5.10) or (>= 5.6.0 and < 5.6.2)
 	// \NUL otherwise, so we use \NUL
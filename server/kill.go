@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// KillHandler is for handlers that want the server to handle KILL statements
+// and COM_PROCESS_KILL, which are passed to HandleQuery and HandleOtherCommand
+// otherwise, e.g. to be forwarded to a backend. HandleKill is called first: if
+// it returns an error the connection is not killed and the error is sent to
+// the client, otherwise the server kills the query or the connection, see
+// Server.Kill. A user can only kill its own connections, but the users of
+// SetKillAnyUsers.
+type KillHandler interface {
+	HandleKill(connectionID uint32, query bool) error
+}
+
+var killExp = regexp.MustCompile(`(?i)^\s*KILL\s+(?:(QUERY|CONNECTION)\s+)?(\d+)\s*;?\s*$`)
+
+// parseKill parses a KILL [QUERY | CONNECTION] statement.
+func parseKill(query string) (connectionID uint32, killQuery bool, ok bool) {
+	m := killExp.FindStringSubmatch(query)
+	if m == nil {
+		return 0, false, false
+	}
+	id, err := strconv.ParseUint(m[2], 10, 32)
+	if err != nil {
+		return 0, false, false
+	}
+	return uint32(id), strings.EqualFold(m[1], "QUERY"), true
+}
+
+// Kill kills the query in progress of the connection connectionID if query is
+// set, or the connection, like the KILL statement. The context of the command
+// in progress is canceled, see Conn.Context, and the command gets
+// ER_QUERY_INTERRUPTED if the handler returns an error. A killed connection is
+// closed once its command is completed, or at once if it is idle.
+func (s *Server) Kill(connectionID uint32, query bool) error {
+	c := s.trackedConn(connectionID)
+	if c == nil {
+		return mysql.NewDefaultError(mysql.ER_NO_SUCH_THREAD, connectionID)
+	}
+	c.kill(query)
+	return nil
+}
+
+// SetKillAnyUsers lets users kill the connections of the other users with KILL
+// and COM_PROCESS_KILL, like the users with the CONNECTION_ADMIN privilege of
+// MySQL, see KillHandler.
+func (s *Server) SetKillAnyUsers(users ...string) {
+	s.killAnyUsers = make(map[string]bool, len(users))
+	for _, user := range users {
+		s.killAnyUsers[user] = true
+	}
+}
+
+func (s *Server) trackedConn(connectionID uint32) *Conn {
+	for _, c := range s.trackedConns() {
+		if c.ConnectionID() == connectionID {
+			return c
+		}
+	}
+	return nil
+}
+
+// Context returns the context of the command being handled, canceled when the
+// query or the connection is killed or when the command is completed. A handler
+// running a long query should stop if it is done.
+func (c *Conn) Context() context.Context {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.cmdCtx == nil {
+		return context.Background()
+	}
+	return c.cmdCtx
+}
+
+func (c *Conn) kill(query bool) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	if !query {
+		c.killed.Store(true)
+	}
+	if c.busy {
+		if c.cmdCancel != nil {
+			c.queryKilled = true
+			c.cmdCancel()
+		}
+	} else if !query && !c.Closed() {
+		_ = c.netConn.SetReadDeadline(time.Now())
+	}
+}
+
+func (c *Conn) handleKill(h KillHandler, connectionID uint32, query bool) interface{} {
+	if err := h.HandleKill(connectionID, query); err != nil {
+		return err
+	}
+	var target *Conn
+	if c.serverConf != nil {
+		target = c.serverConf.trackedConn(connectionID)
+	}
+	if target == nil {
+		return mysql.NewDefaultError(mysql.ER_NO_SUCH_THREAD, connectionID)
+	}
+	if target.GetUser() != c.GetUser() && !c.serverConf.killAnyUsers[c.GetUser()] {
+		return mysql.NewDefaultError(mysql.ER_KILL_DENIED_ERROR, connectionID)
+	}
+	target.kill(query)
+	return nil
+}
+
+func (c *Conn) handleProcessKill(h KillHandler, data []byte) interface{} {
+	if len(data) < 4 {
+		return mysql.ErrMalformPacket
+	}
+	return c.handleKill(h, binary.LittleEndian.Uint32(data), false)
+}
+
+// interrupted replaces the error of a killed command by ER_QUERY_INTERRUPTED.
+func (c *Conn) interrupted(v interface{}) interface{} {
+	if _, ok := v.(error); !ok {
+		return v
+	}
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.queryKilled {
+		return mysql.NewDefaultError(mysql.ER_QUERY_INTERRUPTED)
+	}
+	return v
+}
+
+// closeForKill sends ER_CONNECTION_KILLED to the client and closes the connection.
+func (c *Conn) closeForKill() error {
+	err := mysql.NewError(mysql.ER_CONNECTION_KILLED, "Connection was killed")
+	if c.Conn == nil {
+		return err
+	}
+
+	c.ResetSequence()
+	_ = c.SetWriteDeadline(time.Now().Add(time.Second))
+	_ = c.writeError(err)
+	c.Close()
+	c.Conn = nil
+	return err
+}
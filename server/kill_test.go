@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+type killableHandler struct {
+	EmptyHandler
+	conn    *Conn
+	started chan struct{}
+}
+
+func (h *killableHandler) HandleQuery(query string) (*mysql.Result, error) {
+	if query == "SLOW" {
+		ctx := h.conn.Context()
+		h.started <- struct{}{}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return nil, nil
+}
+
+func (h *killableHandler) HandleKill(connectionID uint32, query bool) error {
+	return nil
+}
+
+type forwardingHandler struct {
+	EmptyHandler
+	queries chan string
+}
+
+func (h *forwardingHandler) HandleQuery(query string) (*mysql.Result, error) {
+	h.queries <- query
+	return nil, nil
+}
+
+func TestParseKill(t *testing.T) {
+	for query, want := range map[string][2]interface{}{
+		"KILL 12":                    {uint32(12), false},
+		"kill query 13;":             {uint32(13), true},
+		" KILL CONNECTION 14 ":       {uint32(14), false},
+		"KILL connection 4294967295": {uint32(4294967295), false},
+	} {
+		id, killQuery, ok := parseKill(query)
+		require.True(t, ok, query)
+		require.Equal(t, want[0], id, query)
+		require.Equal(t, want[1], killQuery, query)
+	}
+	for _, query := range []string{"KILL", "KILL QUERY", "KILL 1 2", "SELECT 'KILL 1'", "KILL 4294967296"} {
+		_, _, ok := parseKill(query)
+		require.False(t, ok, query)
+	}
+}
+
+func TestKill(t *testing.T) {
+	s := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	started := make(chan struct{})
+	users := NewInMemoryProvider()
+	users.AddUser("root", "")
+	users.AddUser("other", "")
+
+	addr := serveConns(t, func(c net.Conn) (*Conn, error) {
+		h := &killableHandler{started: started}
+		conn, err := s.NewCustomizedConn(c, users, h)
+		h.conn = conn
+		return conn, err
+	}, nil)
+
+	connect := func(user string) *client.Conn {
+		conn, err := client.Connect(addr, user, "", "", "")
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	admin := connect("root")
+	worker := connect("root")
+	other := connect("other")
+	id := worker.GetConnectionID()
+
+	// only the users of SetKillAnyUsers kill the connections of the others
+	_, err := other.Execute(fmt.Sprintf("KILL QUERY %d", id))
+	code, _ := mysql.MyErrorCode(err)
+	require.EqualValues(t, mysql.ER_KILL_DENIED_ERROR, code)
+	s.SetKillAnyUsers("other")
+	_, err = other.Execute(fmt.Sprintf("KILL QUERY %d", id))
+	require.NoError(t, err)
+
+	queryErr := make(chan error, 1)
+	go func() {
+		_, err := worker.Execute("SLOW")
+		queryErr <- err
+	}()
+	<-started
+	_, err = admin.Execute(fmt.Sprintf("KILL QUERY %d", id))
+	require.NoError(t, err)
+	err = <-queryErr
+	code, _ = mysql.MyErrorCode(err)
+	require.EqualValues(t, mysql.ER_QUERY_INTERRUPTED, code)
+
+	// the connection is still usable
+	_, err = worker.Execute("SELECT 1")
+	require.NoError(t, err)
+
+	_, err = admin.Execute(fmt.Sprintf("KILL %d", id))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return s.ConnCount() == 2 }, time.Second, 10*time.Millisecond)
+	_, err = worker.Execute("SELECT 1")
+	require.Error(t, err)
+
+	_, err = admin.Execute(fmt.Sprintf("KILL %d", id))
+	code, _ = mysql.MyErrorCode(err)
+	require.EqualValues(t, mysql.ER_NO_SUCH_THREAD, code)
+}
+
+func TestKillForwarded(t *testing.T) {
+	h := &forwardingHandler{queries: make(chan string, 1)}
+	c := &Conn{h: h}
+
+	// without a KillHandler, KILL is passed to HandleQuery, e.g. for a backend
+	require.Nil(t, c.dispatch(append([]byte{mysql.COM_QUERY}, "KILL QUERY 12"...)))
+	require.Equal(t, "KILL QUERY 12", <-h.queries)
+}
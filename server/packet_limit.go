@@ -0,0 +1,37 @@
+package server
+
+import (
+	"time"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// SetMaxAllowedPacket limits the size of the commands to n bytes, like
+// max_allowed_packet of MySQL, 0 for no limit, the default. The commands of
+// more than 16MB, sent in several packets, are reassembled up to n bytes. The
+// connection of a larger command is sent ER_NET_PACKET_TOO_LARGE and closed,
+// like MySQL does, without reading the rest of the command.
+func (s *Server) SetMaxAllowedPacket(n int) {
+	s.maxAllowedPacket = n
+}
+
+// readCommand reads the packets of the next command, up to max_allowed_packet.
+func (c *Conn) readCommand() ([]byte, bool, error) {
+	limit := c.serverConf.maxAllowedPacket
+	if limit <= 0 {
+		data, err := c.ReadPacket()
+		return data, true, err
+	}
+	return c.ReadPacketLimited(limit)
+}
+
+// closeForPacketTooLarge closes the connection of a command exceeding
+// max_allowed_packet.
+func (c *Conn) closeForPacketTooLarge() error {
+	err := mysql.NewDefaultError(mysql.ER_NET_PACKET_TOO_LARGE)
+	_ = c.SetWriteDeadline(time.Now().Add(time.Second))
+	_ = c.writeError(err)
+	c.Close()
+	c.Conn = nil
+	return err
+}
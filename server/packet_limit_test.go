@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+type queryLenHandler struct {
+	EmptyHandler
+}
+
+func (h queryLenHandler) HandleQuery(query string) (*mysql.Result, error) {
+	return &mysql.Result{AffectedRows: uint64(len(query))}, nil
+}
+
+// serveLimited serves the connections of s, their errors are sent to closed.
+func serveLimited(t *testing.T, s *Server, closed chan<- error) string {
+	return serveConns(t, func(c net.Conn) (*Conn, error) {
+		return s.NewConn(c, "root", "", queryLenHandler{})
+	}, closed)
+}
+
+func TestMaxAllowedPacket(t *testing.T) {
+	s := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	s.SetMaxAllowedPacket(mysql.MaxPayloadLen + 100)
+	conn, err := client.Connect(serveLimited(t, s, make(chan error, 1)), "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// reassembled from 2 packets, with COM_QUERY
+	query := strings.Repeat("x", mysql.MaxPayloadLen+99)
+	r, err := conn.Execute(query)
+	require.NoError(t, err)
+	require.EqualValues(t, len(query), r.AffectedRows)
+
+	s = NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	s.SetMaxAllowedPacket(1024)
+	closed := make(chan error, 1)
+	conn, err = client.Connect(serveLimited(t, s, closed), "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Execute(strings.Repeat("x", 1024))
+	code, _ := mysql.MyErrorCode(err)
+	require.EqualValues(t, mysql.ER_NET_PACKET_TOO_LARGE, code)
+	code, _ = mysql.MyErrorCode(<-closed)
+	require.EqualValues(t, mysql.ER_NET_PACKET_TOO_LARGE, code)
+	require.Error(t, conn.Ping())
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// proxyProtocolV2Signature starts every PROXY protocol v2 header.
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyConn is a net.Conn that reports the addresses from a PROXY protocol header.
+type proxyConn struct {
+	net.Conn
+	r      *bufio.Reader
+	remote net.Addr
+	local  net.Addr
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyConn) LocalAddr() net.Addr {
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+// SetProxyProtocol makes the server read a PROXY protocol (v1 or v2) header from
+// every new connection before the handshake. Conn.RemoteAddr then returns the
+// original client address. Only enable it if all clients connect through a proxy
+// sending the header, as a client could otherwise forge its address.
+func (s *Server) SetProxyProtocol(enabled bool) {
+	s.proxyProtocol = enabled
+}
+
+// readProxyHeader reads a PROXY protocol header from conn.
+func readProxyHeader(conn net.Conn) (*proxyConn, error) {
+	c := &proxyConn{Conn: conn, r: bufio.NewReader(conn)}
+
+	sig, err := c.r.Peek(len(proxyProtocolV2Signature))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if bytes.Equal(sig, proxyProtocolV2Signature) {
+		err = c.readV2()
+	} else {
+		err = c.readV1()
+	}
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return c, nil
+}
+
+// readV1 parses the text header, like "PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n".
+func (c *proxyConn) readV1() error {
+	// the header is at most 107 bytes
+	var line []byte
+	for len(line) < 107 {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		line = append(line, b)
+		if bytes.HasSuffix(line, []byte("\r\n")) {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return errors.New("invalid PROXY protocol v1 header: too long")
+	}
+
+	parts := strings.Split(string(line[:len(line)-2]), " ")
+	if parts[0] != "PROXY" || len(parts) < 2 {
+		return errors.New("invalid PROXY protocol header")
+	}
+
+	switch parts[1] {
+	case "UNKNOWN":
+		// the proxy doesn't know the addresses, keep the ones of the connection
+		return nil
+	case "TCP4", "TCP6":
+	default:
+		return errors.Errorf("invalid PROXY protocol v1 family %q", parts[1])
+	}
+
+	if len(parts) != 6 {
+		return errors.Errorf("invalid PROXY protocol v1 header %q", line)
+	}
+
+	src, err := parseProxyV1Addr(parts[2], parts[4])
+	if err != nil {
+		return errors.Trace(err)
+	}
+	dst, err := parseProxyV1Addr(parts[3], parts[5])
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	c.remote, c.local = src, dst
+	return nil
+}
+
+func parseProxyV1Addr(ip string, port string) (*net.TCPAddr, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil, errors.Errorf("invalid PROXY protocol v1 address %q", ip)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, errors.Errorf("invalid PROXY protocol v1 port %q", port)
+	}
+	return &net.TCPAddr{IP: addr, Port: int(p)}, nil
+}
+
+// readV2 parses the binary header.
+func (c *proxyConn) readV2() error {
+	var header [16]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return errors.Trace(err)
+	}
+
+	if header[12]>>4 != 2 {
+		return errors.Errorf("invalid PROXY protocol v2 version %d", header[12]>>4)
+	}
+	command := header[12] & 0x0f
+	family := header[13]
+
+	data := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return errors.Trace(err)
+	}
+
+	switch command {
+	case 0x0:
+		// LOCAL: health checks of the proxy, keep the addresses of the connection
+		return nil
+	case 0x1:
+	default:
+		return errors.Errorf("invalid PROXY protocol v2 command %d", command)
+	}
+
+	var ipLen int
+	switch family >> 4 {
+	case 0x1:
+		ipLen = net.IPv4len
+	case 0x2:
+		ipLen = net.IPv6len
+	default:
+		// AF_UNSPEC and AF_UNIX: keep the addresses of the connection
+		return nil
+	}
+
+	if len(data) < 2*ipLen+4 {
+		return errors.New("invalid PROXY protocol v2 address block")
+	}
+
+	srcIP := net.IP(append([]byte(nil), data[:ipLen]...))
+	dstIP := net.IP(append([]byte(nil), data[ipLen:2*ipLen]...))
+	srcPort := int(binary.BigEndian.Uint16(data[2*ipLen:]))
+	dstPort := int(binary.BigEndian.Uint16(data[2*ipLen+2:]))
+
+	// the rest of the block are TLVs, which are ignored
+	if family&0x0f == 0x2 {
+		c.remote = &net.UDPAddr{IP: srcIP, Port: srcPort}
+		c.local = &net.UDPAddr{IP: dstIP, Port: dstPort}
+	} else {
+		c.remote = &net.TCPAddr{IP: srcIP, Port: srcPort}
+		c.local = &net.TCPAddr{IP: dstIP, Port: dstPort}
+	}
+	return nil
+}
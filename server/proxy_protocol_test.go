@@ -0,0 +1,74 @@
+package server
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testReadProxyHeader(t *testing.T, header []byte) *proxyConn {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		_, _ = client.Write(append(header, "rest"...))
+	}()
+
+	c, err := readProxyHeader(server)
+	require.NoError(t, err)
+
+	// the data after the header must not be lost
+	rest := make([]byte, 4)
+	_, err = io.ReadFull(c, rest)
+	require.NoError(t, err)
+	require.Equal(t, "rest", string(rest))
+
+	return c
+}
+
+func TestReadProxyHeaderV1(t *testing.T) {
+	c := testReadProxyHeader(t, []byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 3306\r\n"))
+	require.Equal(t, "192.168.0.1:56324", c.RemoteAddr().String())
+	require.Equal(t, "192.168.0.11:3306", c.LocalAddr().String())
+
+	c = testReadProxyHeader(t, []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 3306\r\n"))
+	require.Equal(t, "[2001:db8::1]:56324", c.RemoteAddr().String())
+
+	c = testReadProxyHeader(t, []byte("PROXY UNKNOWN\r\n"))
+	require.Equal(t, c.Conn.RemoteAddr(), c.RemoteAddr())
+}
+
+func TestReadProxyHeaderV2(t *testing.T) {
+	header := append([]byte(nil), proxyProtocolV2Signature...)
+	// PROXY over TCP4, 12 bytes of addresses and a 3 byte TLV
+	header = append(header, 0x21, 0x11, 0x00, 15)
+	header = append(header, 10, 0, 0, 1, 10, 0, 0, 2, 0xdc, 0x04, 0x0c, 0xea)
+	header = append(header, 0x04, 0x00, 0x00)
+
+	c := testReadProxyHeader(t, header)
+	require.Equal(t, "10.0.0.1:56324", c.RemoteAddr().String())
+	require.Equal(t, "10.0.0.2:3306", c.LocalAddr().String())
+
+	// LOCAL keeps the addresses of the connection
+	header = append(append([]byte(nil), proxyProtocolV2Signature...), 0x20, 0x00, 0x00, 0x00)
+	c = testReadProxyHeader(t, header)
+	require.Equal(t, c.Conn.RemoteAddr(), c.RemoteAddr())
+}
+
+func TestReadProxyHeaderInvalid(t *testing.T) {
+	for _, header := range []string{
+		"GET / HTTP/1.1\r\n",
+		"PROXY TCP4 192.168.0.1 56324 3306\r\n",
+		"PROXY TCP4 not-an-ip 192.168.0.11 56324 3306\r\n",
+	} {
+		client, server := net.Pipe()
+		go func() {
+			_, _ = client.Write([]byte(header))
+		}()
+		_, err := readProxyHeader(server)
+		require.Error(t, err, header)
+		client.Close()
+	}
+}
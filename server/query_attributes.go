@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+type queryAttributesKey struct{}
+
+// SetQueryAttributes enables the query attributes of MySQL 8.0.23+, the
+// key/value pairs the clients send along with COM_QUERY and COM_STMT_EXECUTE,
+// like `query_attributes n1 v1` of the mysql client. They are added to the
+// context of the command, see QueryAttributesFromContext and
+// Conn.QueryAttributes.
+func (s *Server) SetQueryAttributes(enabled bool) {
+	if enabled {
+		s.capability |= mysql.CLIENT_QUERY_ATTRIBUTES
+	} else {
+		s.capability &^= mysql.CLIENT_QUERY_ATTRIBUTES
+	}
+}
+
+// QueryAttributesFromContext returns the query attributes of the command of
+// ctx, nil if it has none.
+func QueryAttributesFromContext(ctx context.Context) []mysql.QueryAttribute {
+	attrs, _ := ctx.Value(queryAttributesKey{}).([]mysql.QueryAttribute)
+	return attrs
+}
+
+// QueryAttributes returns the query attributes of the command being handled.
+func (c *Conn) QueryAttributes() []mysql.QueryAttribute {
+	return QueryAttributesFromContext(c.Context())
+}
+
+// queryAttributesEnabled returns whether both the server and the client have
+// CLIENT_QUERY_ATTRIBUTES, so the commands carry the attributes.
+func (c *Conn) queryAttributesEnabled() bool {
+	return c.serverConf != nil && c.serverConf.capability&mysql.CLIENT_QUERY_ATTRIBUTES > 0 &&
+		c.capability&mysql.CLIENT_QUERY_ATTRIBUTES > 0
+}
+
+func (c *Conn) setQueryAttributes(attrs []mysql.QueryAttribute) {
+	if len(attrs) == 0 {
+		return
+	}
+	c.stateMu.Lock()
+	if c.cmdCtx != nil {
+		c.cmdCtx = context.WithValue(c.cmdCtx, queryAttributesKey{}, attrs)
+	}
+	c.stateMu.Unlock()
+}
+
+// parseQueryAttributes parses the attributes leading the query of COM_QUERY,
+// and returns the query.
+func parseQueryAttributes(data []byte) ([]mysql.QueryAttribute, []byte, error) {
+	count, _, n := mysql.LengthEncodedInt(data)
+	if n == 0 || count > uint64(len(data)) {
+		return nil, nil, mysql.ErrMalformPacket
+	}
+	pos := n
+	// parameter_set_count, always 1
+	if _, _, n = mysql.LengthEncodedInt(data[pos:]); n == 0 {
+		return nil, nil, mysql.ErrMalformPacket
+	}
+	pos += n
+	if count == 0 {
+		return nil, data[pos:], nil
+	}
+
+	num := int(count)
+	nullBitmapLen := (num + 7) >> 3
+	if len(data) < pos+nullBitmapLen+1 {
+		return nil, nil, mysql.ErrMalformPacket
+	}
+	nullBitmap := data[pos : pos+nullBitmapLen]
+	pos += nullBitmapLen
+
+	// new_params_bind_flag, always 1
+	pos++
+	types, names, n, err := parseParamTypes(data[pos:], num, true)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	pos += n
+
+	attrs, n, err := attributeValues(nullBitmap, 0, types, names, data[pos:])
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	return attrs, data[pos+n:], nil
+}
+
+// parseParamTypes parses the type and flag of num params, followed by their
+// names if withNames, and returns the length parsed.
+func parseParamTypes(data []byte, num int, withNames bool) ([]byte, []string, int, error) {
+	if !withNames {
+		if len(data) < num<<1 {
+			return nil, nil, 0, mysql.ErrMalformPacket
+		}
+		return data[:num<<1], nil, num << 1, nil
+	}
+
+	types := make([]byte, 0, num<<1)
+	names := make([]string, num)
+	pos := 0
+	for i := 0; i < num; i++ {
+		if len(data) < pos+2 {
+			return nil, nil, 0, mysql.ErrMalformPacket
+		}
+		types = append(types, data[pos], data[pos+1])
+		pos += 2
+
+		name, _, n, err := mysql.LengthEncodedString(data[pos:])
+		if err != nil {
+			return nil, nil, 0, errors.Trace(err)
+		}
+		names[i] = string(name)
+		pos += n
+	}
+	return types, names, pos, nil
+}
+
+// attributeValues decodes the values of the attributes, the params from the
+// index first, and returns the length decoded.
+func attributeValues(nullBitmap []byte, first int, types []byte, names []string, data []byte) ([]mysql.QueryAttribute, int, error) {
+	num := len(types) >> 1
+	attrs := make([]mysql.QueryAttribute, 0, num-first)
+	pos := 0
+	for i := first; i < num; i++ {
+		attr := mysql.QueryAttribute{Name: names[i]}
+		if nullBitmap[i>>3]&(1<<(uint(i)%8)) == 0 {
+			v, n, err := binaryParamValue(types[i<<1], types[(i<<1)+1]&mysql.PARAM_UNSIGNED > 0, data[pos:])
+			if err != nil {
+				return nil, 0, errors.Trace(err)
+			}
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			attr.Value = v
+			pos += n
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs, pos, nil
+}
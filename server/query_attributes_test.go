@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+type queryAttributesHandler struct {
+	ContextHandler
+	attrs chan []mysql.QueryAttribute
+	args  chan []interface{}
+}
+
+func (h *queryAttributesHandler) HandleQueryContext(ctx context.Context, query string) (*mysql.Result, error) {
+	h.attrs <- QueryAttributesFromContext(ctx)
+	return nil, nil
+}
+
+func (h *queryAttributesHandler) HandleStmtPrepareContext(context.Context, string) (int, int, interface{}, error) {
+	return 1, 0, nil, nil
+}
+
+func (h *queryAttributesHandler) HandleStmtExecuteContext(ctx context.Context, _ interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	h.args <- append([]interface{}(nil), args...)
+	return h.HandleQueryContext(ctx, query)
+}
+
+func TestQueryAttributes(t *testing.T) {
+	s := NewDefaultServer()
+	s.SetQueryAttributes(true)
+	h := &queryAttributesHandler{
+		ContextHandler: AdaptHandler(EmptyHandler{}),
+		attrs:          make(chan []mysql.QueryAttribute, 1),
+		args:           make(chan []interface{}, 1),
+	}
+
+	addr := serveConns(t, func(c net.Conn) (*Conn, error) {
+		return s.NewConn(c, "root", "", AdaptContextHandler(h))
+	}, nil)
+
+	conn, err := client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	attrs := []mysql.QueryAttribute{{Name: "trace", Value: "abc"}, {Name: "shard", Value: uint64(7)}}
+	require.NoError(t, conn.SetQueryAttributes(attrs...))
+	_, err = conn.Execute("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	require.Equal(t, attrs, <-h.attrs)
+
+	// the attributes are sent with one query only
+	_, err = conn.Execute("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	require.Nil(t, <-h.attrs)
+
+	require.NoError(t, conn.SetQueryAttributes(attrs[0]))
+	_, err = conn.Execute("INSERT INTO t VALUES (?)", "x")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{[]byte("x")}, <-h.args)
+	require.Equal(t, attrs[:1], <-h.attrs)
+
+	// disabled, the client doesn't send them
+	s.SetQueryAttributes(false)
+	conn, err = client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetQueryAttributes(attrs...))
+	_, err = conn.Execute("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	require.Nil(t, <-h.attrs)
+}
@@ -1,30 +1,178 @@
 package server
 
 import (
+	"context"
 	"encoding/binary"
 
 	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/replication"
 )
 
-func parseBinlogDump(data []byte) (mysql.Position, error) {
-	if len(data) < 10 {
-		return mysql.Position{}, mysql.ErrMalformPacket
+// RegisterReplicaRequest is the COM_REGISTER_SLAVE of a replica, sent before
+// dumping the binlog.
+type RegisterReplicaRequest struct {
+	ServerID uint32
+	// Hostname, User, Password and Port are report_host, report_user,
+	// report_password and report_port of the replica, shown by SHOW REPLICAS.
+	Hostname string
+	User     string
+	Password string
+	Port     uint16
+	Rank     uint32
+	MasterID uint32
+}
+
+// BinlogDumpRequest is the COM_BINLOG_DUMP or COM_BINLOG_DUMP_GTID of a
+// replica.
+type BinlogDumpRequest struct {
+	ServerID uint32
+	// Flags are the flags of the dump, like replication.BINLOG_DUMP_NON_BLOCK.
+	Flags uint16
+	// Position is the start position, the file name may be empty with a GTID
+	// set.
+	Position mysql.Position
+	// GTIDSet is the GTID set of the replica for COM_BINLOG_DUMP_GTID, the
+	// dump starts at the first transaction not in it, nil for COM_BINLOG_DUMP.
+	GTIDSet *mysql.MysqlGTIDSet
+}
+
+// ReplicationCommandHandler is for handlers serving the replication commands
+// with their context and all their fields, it is used instead of
+// ReplicationHandler if the handler implements both. The events written to the
+// returned streamer are sent to the replica until the streamer is closed by an
+// error, with BinlogStreamer.AddErrorToStreamer: the dump ends with an EOF
+// packet for replication.ErrSyncClosed if the replica asked for
+// BINLOG_DUMP_NON_BLOCK, with an error packet for a *mysql.MyError, and other
+// errors close the connection. See RejectReplicationHandler to reject them.
+type ReplicationCommandHandler interface {
+	HandleRegisterReplica(ctx context.Context, req *RegisterReplicaRequest) error
+	HandleBinlogDumpRequest(ctx context.Context, req *BinlogDumpRequest) (*replication.BinlogStreamer, error)
+}
+
+// RejectReplicationHandler is a ReplicationCommandHandler rejecting the
+// replicas like MySQL does for users without the REPLICATION SLAVE privilege.
+// It is embedded in a Handler along with EmptyHandler, or another Handler.
+type RejectReplicationHandler struct{}
+
+func (RejectReplicationHandler) HandleRegisterReplica(context.Context, *RegisterReplicaRequest) error {
+	return mysql.NewDefaultError(mysql.ER_SPECIFIC_ACCESS_DENIED_ERROR, "REPLICATION SLAVE")
+}
+
+func (RejectReplicationHandler) HandleBinlogDumpRequest(context.Context, *BinlogDumpRequest) (*replication.BinlogStreamer, error) {
+	return nil, mysql.NewDefaultError(mysql.ER_SPECIFIC_ACCESS_DENIED_ERROR, "REPLICATION SLAVE")
+}
+
+// binlogDumpResponse is the streamer of a binlog dump, see writeBinlogEvents.
+type binlogDumpResponse struct {
+	s     *replication.BinlogStreamer
+	flags uint16
+}
+
+func (c *Conn) handleRegisterReplica(h ReplicationCommandHandler, data []byte) interface{} {
+	req, err := parseRegisterSlave(data)
+	if err != nil {
+		return err
 	}
-	var p mysql.Position
-	p.Pos = binary.LittleEndian.Uint32(data[0:4])
-	p.Name = string(data[10:])
+	return h.HandleRegisterReplica(c.Context(), req)
+}
 
-	return p, nil
+func (c *Conn) handleBinlogDump(h ReplicationCommandHandler, cmd byte, data []byte) interface{} {
+	var req *BinlogDumpRequest
+	var err error
+	if cmd == mysql.COM_BINLOG_DUMP_GTID {
+		req, err = parseBinlogDumpGTIDRequest(data)
+	} else {
+		req, err = parseBinlogDumpRequest(data)
+	}
+	if err != nil {
+		return err
+	}
+	s, err := h.HandleBinlogDumpRequest(c.Context(), req)
+	if err != nil {
+		return err
+	}
+	return binlogDumpResponse{s: s, flags: req.Flags}
 }
 
-func parseBinlogDumpGTID(data []byte) (*mysql.MysqlGTIDSet, error) {
-	if len(data) < 15 {
+func parseRegisterSlave(data []byte) (*RegisterReplicaRequest, error) {
+	req := new(RegisterReplicaRequest)
+	if len(data) < 4 {
 		return nil, mysql.ErrMalformPacket
 	}
-	lenPosName := binary.LittleEndian.Uint32(data[11:15])
-	if len(data) < 22+int(lenPosName) {
+	req.ServerID = binary.LittleEndian.Uint32(data)
+	pos := 4
+
+	for _, s := range []*string{&req.Hostname, &req.User, &req.Password} {
+		if len(data) < pos+1 || len(data) < pos+1+int(data[pos]) {
+			return nil, mysql.ErrMalformPacket
+		}
+		n := int(data[pos])
+		*s = string(data[pos+1 : pos+1+n])
+		pos += 1 + n
+	}
+
+	if len(data) < pos+10 {
 		return nil, mysql.ErrMalformPacket
 	}
+	req.Port = binary.LittleEndian.Uint16(data[pos:])
+	req.Rank = binary.LittleEndian.Uint32(data[pos+2:])
+	req.MasterID = binary.LittleEndian.Uint32(data[pos+6:])
+	return req, nil
+}
 
-	return mysql.DecodeMysqlGTIDSet(data[22+lenPosName:])
+func parseBinlogDumpRequest(data []byte) (*BinlogDumpRequest, error) {
+	if len(data) < 10 {
+		return nil, mysql.ErrMalformPacket
+	}
+	return &BinlogDumpRequest{
+		Position: mysql.Position{Name: string(data[10:]), Pos: binary.LittleEndian.Uint32(data[0:4])},
+		Flags:    binary.LittleEndian.Uint16(data[4:6]),
+		ServerID: binary.LittleEndian.Uint32(data[6:10]),
+	}, nil
+}
+
+func parseBinlogDumpGTIDRequest(data []byte) (*BinlogDumpRequest, error) {
+	if len(data) < 10 {
+		return nil, mysql.ErrMalformPacket
+	}
+	req := &BinlogDumpRequest{
+		Flags:    binary.LittleEndian.Uint16(data[0:2]),
+		ServerID: binary.LittleEndian.Uint32(data[2:6]),
+	}
+	nameLen := int(binary.LittleEndian.Uint32(data[6:10]))
+	pos := 10
+	if len(data) < pos+nameLen+12 {
+		return nil, mysql.ErrMalformPacket
+	}
+	req.Position.Name = string(data[pos : pos+nameLen])
+	pos += nameLen
+	req.Position.Pos = uint32(binary.LittleEndian.Uint64(data[pos:]))
+	pos += 8
+	size := int(binary.LittleEndian.Uint32(data[pos:]))
+	pos += 4
+	if len(data) < pos+size {
+		return nil, mysql.ErrMalformPacket
+	}
+
+	var err error
+	if req.GTIDSet, err = mysql.DecodeMysqlGTIDSet(data[pos : pos+size]); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func parseBinlogDump(data []byte) (mysql.Position, error) {
+	req, err := parseBinlogDumpRequest(data)
+	if err != nil {
+		return mysql.Position{}, err
+	}
+	return req.Position, nil
+}
+
+func parseBinlogDumpGTID(data []byte) (*mysql.MysqlGTIDSet, error) {
+	req, err := parseBinlogDumpGTIDRequest(data)
+	if err != nil {
+		return nil, err
+	}
+	return req.GTIDSet, nil
 }
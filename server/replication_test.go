@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/packet"
+	"github.com/gongzhxu/go-mysql/replication"
+	mockconn "github.com/gongzhxu/go-mysql/test_util/conn"
+)
+
+type replicationCommandHandler struct {
+	EmptyHandler
+	register *RegisterReplicaRequest
+	dump     *BinlogDumpRequest
+}
+
+func (h *replicationCommandHandler) HandleRegisterReplica(ctx context.Context, req *RegisterReplicaRequest) error {
+	h.register = req
+	return nil
+}
+
+func (h *replicationCommandHandler) HandleBinlogDumpRequest(ctx context.Context, req *BinlogDumpRequest) (*replication.BinlogStreamer, error) {
+	h.dump = req
+	return replication.NewBinlogStreamer(), nil
+}
+
+func TestReplicationCommands(t *testing.T) {
+	s := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+
+	register := []byte{mysql.COM_REGISTER_SLAVE, 100, 0, 0, 0, 4, 'h', 'o', 's', 't', 4, 'r', 'e', 'p', 'l', 0}
+	register = binary.LittleEndian.AppendUint16(register, 3307)
+	register = append(register, 0, 0, 0, 0, 0, 0, 0, 0)
+
+	dump := []byte{mysql.COM_BINLOG_DUMP}
+	dump = binary.LittleEndian.AppendUint32(dump, 4)
+	dump = binary.LittleEndian.AppendUint16(dump, replication.BINLOG_DUMP_NON_BLOCK)
+	dump = binary.LittleEndian.AppendUint32(dump, 100)
+	dump = append(dump, "mysql-bin.000002"...)
+
+	gset, err := mysql.ParseMysqlGTIDSet("de278ad0-2106-11e4-9f8e-6edd0ca20947:1-2")
+	require.NoError(t, err)
+	gtidData := gset.Encode()
+	dumpGTID := []byte{mysql.COM_BINLOG_DUMP_GTID, 0, 0}
+	dumpGTID = binary.LittleEndian.AppendUint32(dumpGTID, 100)
+	dumpGTID = binary.LittleEndian.AppendUint32(dumpGTID, uint32(len("mysql-bin.000003")))
+	dumpGTID = append(dumpGTID, "mysql-bin.000003"...)
+	dumpGTID = binary.LittleEndian.AppendUint64(dumpGTID, 4)
+	dumpGTID = binary.LittleEndian.AppendUint32(dumpGTID, uint32(len(gtidData)))
+	dumpGTID = append(dumpGTID, gtidData...)
+
+	// rejected like MySQL
+	c := &Conn{serverConf: s, h: struct {
+		EmptyHandler
+		RejectReplicationHandler
+	}{}}
+	for _, data := range [][]byte{register, dump, dumpGTID} {
+		err, ok := c.dispatch(data).(error)
+		require.True(t, ok)
+		require.EqualValues(t, mysql.ER_SPECIFIC_ACCESS_DENIED_ERROR, err.(*mysql.MyError).Code)
+	}
+
+	h := &replicationCommandHandler{}
+	c = &Conn{serverConf: s, h: h}
+	require.Nil(t, c.dispatch(register))
+	require.Equal(t, &RegisterReplicaRequest{ServerID: 100, Hostname: "host", User: "repl", Port: 3307}, h.register)
+
+	v, ok := c.dispatch(dump).(binlogDumpResponse)
+	require.True(t, ok)
+	require.Equal(t, replication.BINLOG_DUMP_NON_BLOCK, v.flags)
+	require.Equal(t, &BinlogDumpRequest{
+		ServerID: 100,
+		Flags:    replication.BINLOG_DUMP_NON_BLOCK,
+		Position: mysql.Position{Name: "mysql-bin.000002", Pos: 4},
+	}, h.dump)
+
+	_, ok = c.dispatch(dumpGTID).(binlogDumpResponse)
+	require.True(t, ok)
+	require.Equal(t, mysql.Position{Name: "mysql-bin.000003", Pos: 4}, h.dump.Position)
+	require.Equal(t, gset.String(), h.dump.GTIDSet.String())
+
+	_, ok = c.dispatch(dumpGTID[:12]).(error)
+	require.True(t, ok)
+}
+
+func TestWriteBinlogEventsEnd(t *testing.T) {
+	clientConn := &mockconn.MockConn{}
+	c := &Conn{Conn: packet.NewConn(clientConn)}
+
+	st := replication.NewBinlogStreamer()
+	require.NoError(t, st.AddEventToStreamer(&replication.BinlogEvent{RawData: []byte{1, 2}}))
+	st.AddErrorToStreamer(replication.ErrSyncClosed)
+	require.NoError(t, c.writeBinlogEvents(st, replication.BINLOG_DUMP_NON_BLOCK))
+	require.Equal(t, []byte{1, 0, 0, 1, mysql.EOF_HEADER}, clientConn.WriteBuffered)
+
+	st = replication.NewBinlogStreamer()
+	st.AddErrorToStreamer(mysql.NewDefaultError(mysql.ER_MASTER_FATAL_ERROR_READING_BINLOG, 1236, "purged"))
+	require.NoError(t, c.writeBinlogEvents(st, 0))
+	require.Equal(t, mysql.ERR_HEADER, clientConn.WriteBuffered[4])
+
+	// the connection is closed for other errors
+	st = replication.NewBinlogStreamer()
+	st.AddErrorToStreamer(replication.ErrSyncClosed)
+	require.ErrorIs(t, c.writeBinlogEvents(st, 0), replication.ErrSyncClosed)
+}
@@ -200,12 +200,29 @@ func (c *Conn) writeFieldValues(fv []mysql.FieldValue) error {
 }
 
 // see: https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_replication.html
-func (c *Conn) writeBinlogEvents(s *replication.BinlogStreamer) error {
+func (c *Conn) writeBinlogEvents(s *replication.BinlogStreamer, flags uint16) error {
 	for {
 		ev, err := s.GetEvent(context.Background())
 		if err != nil {
+			if err == replication.ErrSyncClosed && flags&replication.BINLOG_DUMP_NON_BLOCK > 0 {
+				// no more events, the ones added before the error are sent first
+				if err = c.writeBinlogEvent(s.DumpEvents()...); err != nil {
+					return err
+				}
+				return c.writeEOF()
+			} else if myErr, ok := err.(*mysql.MyError); ok {
+				return c.writeError(myErr)
+			}
+			return err
+		}
+		if err := c.writeBinlogEvent(ev); err != nil {
 			return err
 		}
+	}
+}
+
+func (c *Conn) writeBinlogEvent(events ...*replication.BinlogEvent) error {
+	for _, ev := range events {
 		data := make([]byte, 4, 4+len(ev.RawData))
 		data = append(data, mysql.OK_HEADER)
 
@@ -214,6 +231,7 @@ func (c *Conn) writeBinlogEvents(s *replication.BinlogStreamer) error {
 			return err
 		}
 	}
+	return nil
 }
 
 type (
@@ -225,8 +243,12 @@ func (c *Conn) WriteValue(value interface{}) error {
 	switch v := value.(type) {
 	case noResponse:
 		return nil
+	case failedResponse:
+		return v.err
 	case eofResponse:
 		return c.writeEOF()
+	case statisticsResponse:
+		return c.writeStatistics(v)
 	case error:
 		return c.writeError(v)
 	case nil:
@@ -242,7 +264,9 @@ func (c *Conn) WriteValue(value interface{}) error {
 	case []mysql.FieldValue:
 		return c.writeFieldValues(v)
 	case *replication.BinlogStreamer:
-		return c.writeBinlogEvents(v)
+		return c.writeBinlogEvents(v, 0)
+	case binlogDumpResponse:
+		return c.writeBinlogEvents(v.s, v.flags)
 	case *Stmt:
 		return c.writePrepare(v)
 	default:
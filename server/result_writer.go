@@ -0,0 +1,131 @@
+package server
+
+import (
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// ResultWriter streams a resultset to the client, row by row, see
+// StreamingQueryHandler. The writes block while the client doesn't read the
+// rows, so a handler producing rows faster than the client reads them is slowed
+// down instead of buffering them.
+type ResultWriter interface {
+	// WriteColumns sends the columns, once before the rows.
+	WriteColumns(fields []*mysql.Field) error
+	// WriteRow sends a row in the text protocol, with a value for each column
+	// formatted like BuildSimpleTextResultset does, nil for NULL.
+	WriteRow(values []interface{}) error
+	// Finish ends the resultset. It is called after the handler returns if the
+	// handler did not.
+	Finish() error
+}
+
+// StreamingQueryHandler is for handlers that send large resultsets without
+// building them in memory. If the handler implements it, it is called for
+// COM_QUERY instead of Handler.HandleQuery. The handler either streams a
+// resultset with w, or returns the result like HandleQuery without using w.
+// An error returned once the columns are written is sent to the client instead
+// of the rest of the rows, it is dropped if the resultset is finished. w must
+// not be used after HandleQueryStreaming returns.
+type StreamingQueryHandler interface {
+	HandleQueryStreaming(query string, w ResultWriter) (*mysql.Result, error)
+}
+
+type resultWriter struct {
+	c    *Conn
+	data []byte
+
+	columns  int
+	started  bool
+	finished bool
+	// err is the error of a write, the connection is closed
+	err error
+}
+
+// failedResponse is the error of a response partially written, the connection
+// can't be used anymore.
+type failedResponse struct {
+	err error
+}
+
+func (w *resultWriter) WriteColumns(fields []*mysql.Field) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.started {
+		return errors.New("columns already written")
+	}
+	w.started = true
+	w.columns = len(fields)
+
+	w.data = append(w.data[:4], mysql.PutLengthEncodedInt(uint64(len(fields)))...)
+	if w.err = w.c.WritePacket(w.data); w.err != nil {
+		return w.err
+	}
+	w.err = w.c.writeFieldList(fields, w.data)
+	return w.err
+}
+
+func (w *resultWriter) WriteRow(values []interface{}) error {
+	if w.err != nil {
+		return w.err
+	}
+	if !w.started || w.finished {
+		return errors.New("row written outside of the resultset")
+	}
+	if len(values) != w.columns {
+		return errors.Errorf("row has %d values, need %d", len(values), w.columns)
+	}
+
+	w.data = w.data[:4]
+	for _, v := range values {
+		if v == nil {
+			// NULL value is encoded as 0xfb here
+			w.data = append(w.data, 0xfb)
+			continue
+		}
+		b, err := mysql.FormatTextValue(v)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		w.data = append(w.data, mysql.PutLengthEncodedString(b)...)
+	}
+	w.err = w.c.WritePacket(w.data)
+	return w.err
+}
+
+func (w *resultWriter) Finish() error {
+	if w.err != nil {
+		return w.err
+	}
+	if !w.started {
+		return errors.New("columns not written")
+	}
+	if w.finished {
+		return nil
+	}
+	w.finished = true
+	w.err = w.c.writeEOF()
+	return w.err
+}
+
+func (c *Conn) handleQueryStreaming(h StreamingQueryHandler, query string) interface{} {
+	w := &resultWriter{c: c, data: make([]byte, 4, 1024)}
+	r, err := h.HandleQueryStreaming(query, w)
+	switch {
+	case w.err != nil:
+		return failedResponse{w.err}
+	case !w.started:
+		if err != nil {
+			return err
+		}
+		return r
+	case w.finished:
+		return noResponse{}
+	case err != nil:
+		return err
+	default:
+		return eofResponse{}
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+type streamingHandler struct {
+	EmptyHandler
+}
+
+func (h *streamingHandler) HandleQueryStreaming(query string, w ResultWriter) (*mysql.Result, error) {
+	switch query {
+	case "OK":
+		return &mysql.Result{AffectedRows: 3}, nil
+	case "FAIL":
+		return nil, mysql.NewError(mysql.ER_UNKNOWN_ERROR, "failed")
+	}
+
+	fields := []*mysql.Field{
+		{Name: []byte("id"), Type: mysql.MYSQL_TYPE_LONGLONG},
+		{Name: []byte("name"), Type: mysql.MYSQL_TYPE_VAR_STRING},
+	}
+	if err := w.WriteColumns(fields); err != nil {
+		return nil, err
+	}
+	if err := w.WriteColumns(fields); err == nil {
+		return nil, errors.New("columns written twice")
+	}
+	for i := 0; i < 10000; i++ {
+		var name interface{} = "row" + strconv.Itoa(i)
+		if i%10 == 0 {
+			name = nil
+		}
+		if err := w.WriteRow([]interface{}{int64(i), name}); err != nil {
+			return nil, err
+		}
+		if query == "FAIL LATER" && i == 5 {
+			return nil, mysql.NewError(mysql.ER_UNKNOWN_ERROR, "failed later")
+		}
+	}
+	if err := w.WriteRow([]interface{}{1}); err == nil {
+		return nil, errors.New("row with a missing value written")
+	}
+	if query == "FINISH" {
+		return nil, w.Finish()
+	}
+	// finished by the server
+	return nil, nil
+}
+
+func TestResultWriter(t *testing.T) {
+	addr := serve(t, &streamingHandler{})
+
+	conn, err := client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	for _, query := range []string{"SELECT", "FINISH"} {
+		var rows int
+		var result mysql.Result
+		err = conn.ExecuteSelectStreaming(query, &result, func(row []mysql.FieldValue) error {
+			require.EqualValues(t, rows, row[0].AsInt64())
+			if rows%10 == 0 {
+				require.Nil(t, row[1].Value())
+			} else {
+				require.Equal(t, "row"+strconv.Itoa(rows), string(row[1].AsString()))
+			}
+			rows++
+			return nil
+		}, nil)
+		require.NoError(t, err)
+		require.Equal(t, 10000, rows)
+	}
+
+	r, err := conn.Execute("OK")
+	require.NoError(t, err)
+	require.EqualValues(t, 3, r.AffectedRows)
+
+	_, err = conn.Execute("FAIL LATER")
+	require.ErrorContains(t, err, "failed later")
+	_, err = conn.Execute("FAIL")
+	require.ErrorContains(t, err, "failed")
+
+	// the connection is still usable
+	r, err = conn.Execute("SELECT")
+	require.NoError(t, err)
+	require.Equal(t, 10000, r.RowNumber())
+}
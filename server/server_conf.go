@@ -4,6 +4,8 @@ import (
 	"crypto/tls"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gongzhxu/go-mysql/mysql"
 )
@@ -32,6 +34,29 @@ type Server struct {
 	pubKey            []byte
 	tlsConfig         *tls.Config
 	cacheShaPassword  *sync.Map // 'user@host' -> SHA256(SHA256(PASSWORD))
+	proxyProtocol     bool      // read a PROXY protocol header before the handshake
+	authPlugins       map[string]AuthPlugin
+	traceContext      bool // parse the trace context of the queries, see SetTraceContextExtraction
+
+	// limits of the connections, see SetIdleTimeout and SetMaxConnLifetime
+	idleTimeout        time.Duration
+	interactiveTimeout time.Duration
+	maxConnLifetime    time.Duration
+	// see SetMaxAllowedPacket
+	maxAllowedPacket int
+	// users killing the connections of the others, see SetKillAnyUsers
+	killAnyUsers map[string]bool
+
+	startTime time.Time
+	questions atomic.Uint64 // COM_QUERY and COM_STMT_EXECUTE commands, see COM_STATISTICS
+
+	shuttingDown atomic.Bool
+	connsMu      sync.Mutex
+	conns        map[*Conn]struct{}
+
+	// usersMu protects users, the resources used by the users with limits, see UserLimitsProvider
+	usersMu sync.Mutex
+	users   map[string]*userUsage
 }
 
 // NewDefaultServer: New mysql server with default settings.
@@ -56,6 +81,7 @@ func NewDefaultServer() *Server {
 		pubKey:            getPublicKeyFromCert(certPem),
 		tlsConfig:         tlsConf,
 		cacheShaPassword:  new(sync.Map),
+		startTime:         time.Now(),
 	}
 }
 
@@ -63,7 +89,8 @@ func NewDefaultServer() *Server {
 //
 // NOTES:
 // You can control the authentication methods and TLS settings here.
-// For auth method, you can specify one of the supported methods 'mysql_native_password', 'caching_sha2_password', and 'sha256_password'.
+// For auth method, you can specify one of the supported methods 'mysql_native_password', 'caching_sha2_password', and 'sha256_password',
+// other methods can be registered with RegisterAuthPlugin and set with SetDefaultAuthMethod.
 // The specified auth method will be enforced by the server in the connection phase. That means, client will be asked to switch auth method
 // if the supplied auth method is different from the server default.
 // And for TLS support, you can specify self-signed or CA-signed certificates and decide whether the client needs to provide
@@ -91,6 +118,7 @@ func NewServer(serverVersion string, collationId uint8, defaultAuthMethod string
 		pubKey:            pubKey,
 		tlsConfig:         tlsConfig,
 		cacheShaPassword:  new(sync.Map),
+		startTime:         time.Now(),
 	}
 }
 
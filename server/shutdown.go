@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// shutdownPollInterval is how often Shutdown checks for idle connections.
+var shutdownPollInterval = 50 * time.Millisecond
+
+// Shutdown gracefully shuts down the connections of the server. New connections
+// and new commands are rejected with ER_SERVER_SHUTDOWN, commands in progress are
+// completed first. Idle connections get ER_SERVER_SHUTDOWN and are closed.
+//
+// Shutdown waits for all connections to be closed. If ctx expires before, the
+// remaining connections are closed forcibly and the context error is returned.
+// HandleCommand returns the ER_SERVER_SHUTDOWN error for closed connections.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shuttingDown.Store(true)
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for {
+		conns := s.trackedConns()
+		if len(conns) == 0 {
+			return nil
+		}
+		for _, c := range conns {
+			c.interruptIfIdle()
+		}
+
+		select {
+		case <-ctx.Done():
+			for _, c := range s.trackedConns() {
+				c.forceClose()
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ConnCount returns the number of open connections created by the server.
+func (s *Server) ConnCount() int {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	return len(s.conns)
+}
+
+func (s *Server) trackConn(c *Conn) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	if s.conns == nil {
+		s.conns = make(map[*Conn]struct{})
+	}
+	s.conns[c] = struct{}{}
+}
+
+func (s *Server) untrackConn(c *Conn) {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	delete(s.conns, c)
+}
+
+func (s *Server) trackedConns() []*Conn {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+	conns := make([]*Conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+func (c *Conn) shuttingDown() bool {
+	return c.serverConf != nil && c.serverConf.shuttingDown.Load()
+}
+
+// startCommand marks the connection busy, it returns false if the server is
+// shutting down and the command must be rejected.
+func (c *Conn) startCommand() bool {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.shuttingDown() {
+		return false
+	}
+	c.busy = true
+	c.cmdCtx, c.cmdCancel = context.WithCancel(context.Background())
+	c.queryKilled = false
+	return true
+}
+
+func (c *Conn) finishCommand() {
+	c.stateMu.Lock()
+	c.busy = false
+	if c.cmdCancel != nil {
+		c.cmdCancel()
+	}
+	c.cmdCtx, c.cmdCancel = nil, nil
+	c.stateMu.Unlock()
+}
+
+// interruptIfIdle makes a connection blocked reading the next command return,
+// so HandleCommand can close it.
+func (c *Conn) interruptIfIdle() {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if !c.busy && !c.Closed() {
+		_ = c.netConn.SetReadDeadline(time.Now())
+	}
+}
+
+// forceClose closes the network connection from another goroutine than the
+// one handling the connection, which notices it in HandleCommand.
+func (c *Conn) forceClose() {
+	c.closed.Store(true)
+	c.serverConf.untrackConn(c)
+	c.releaseUserConn()
+	_ = c.netConn.Close()
+}
+
+// closeForShutdown sends ER_SERVER_SHUTDOWN to the client and closes the connection.
+func (c *Conn) closeForShutdown() error {
+	err := mysql.NewDefaultError(mysql.ER_SERVER_SHUTDOWN)
+	if c.Conn == nil {
+		return err
+	}
+
+	c.ResetSequence()
+	_ = c.SetWriteDeadline(time.Now().Add(time.Second))
+	_ = c.writeError(err)
+	c.Close()
+	c.Conn = nil
+	return err
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+type blockingHandler struct {
+	EmptyHandler
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h *blockingHandler) HandleQuery(query string) (*mysql.Result, error) {
+	if query == "SLOW" {
+		h.started <- struct{}{}
+		<-h.release
+	}
+	return nil, nil
+}
+
+func startShutdownTestServer(t *testing.T, s *Server, h Handler) (string, chan error) {
+	errs := make(chan error, 10)
+	addr := serveConns(t, func(c net.Conn) (*Conn, error) {
+		return s.NewConn(c, "root", "", h)
+	}, errs)
+	return addr, errs
+}
+
+func TestServerShutdown(t *testing.T) {
+	s := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	h := &blockingHandler{started: make(chan struct{}), release: make(chan struct{})}
+	addr, errs := startShutdownTestServer(t, s, h)
+
+	idle, err := client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer idle.Close()
+	busy, err := client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer busy.Close()
+
+	require.Eventually(t, func() bool { return s.ConnCount() == 2 }, time.Second, 10*time.Millisecond)
+
+	queryErr := make(chan error, 1)
+	go func() {
+		_, err := busy.Execute("SLOW")
+		queryErr <- err
+	}()
+	<-h.started
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- s.Shutdown(context.Background())
+	}()
+
+	// the idle connection is closed first
+	err = <-errs
+	require.EqualValues(t, mysql.ER_SERVER_SHUTDOWN, errors.Cause(err).(*mysql.MyError).Code)
+	_, err = idle.Execute("SELECT 1")
+	require.Error(t, err)
+
+	// the command in progress completes
+	close(h.release)
+	require.NoError(t, <-queryErr)
+	require.NoError(t, <-shutdownErr)
+	require.Equal(t, 0, s.ConnCount())
+
+	// new connections are rejected
+	_, err = client.Connect(addr, "root", "", "", "")
+	require.ErrorContains(t, err, "Server shutdown in progress")
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	s := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	h := &blockingHandler{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(h.release)
+	addr, _ := startShutdownTestServer(t, s, h)
+
+	conn, err := client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	go func() {
+		_, _ = conn.Execute("SLOW")
+	}()
+	<-h.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, s.Shutdown(ctx), context.DeadlineExceeded)
+	require.Equal(t, 0, s.ConnCount())
+}
@@ -22,9 +22,18 @@ type Stmt struct {
 	Params  int
 	Columns int
 
+	// ParamFields and ColumnFields are the fields sent for the params and
+	// columns in the COM_STMT_PREPARE response, see StmtPrepareFieldsHandler.
+	ParamFields  []*mysql.Field
+	ColumnFields []*mysql.Field
+
 	Args []interface{}
 
 	Context interface{}
+
+	// longData marks the params sent by COM_STMT_SEND_LONG_DATA, their values
+	// are not in the COM_STMT_EXECUTE packet
+	longData []bool
 }
 
 func (s *Stmt) Rest(params int, columns int, context interface{}) {
@@ -36,6 +45,7 @@ func (s *Stmt) Rest(params int, columns int, context interface{}) {
 
 func (s *Stmt) ResetParams() {
 	s.Args = make([]interface{}, s.Params)
+	s.longData = nil
 }
 
 func (c *Conn) writePrepare(s *Stmt) error {
@@ -61,7 +71,11 @@ func (c *Conn) writePrepare(s *Stmt) error {
 	if s.Params > 0 {
 		for i := 0; i < s.Params; i++ {
 			data = data[0:4]
-			data = append(data, paramFieldData...)
+			if i < len(s.ParamFields) {
+				data = append(data, s.ParamFields[i].Dump()...)
+			} else {
+				data = append(data, paramFieldData...)
+			}
 
 			if err := c.WritePacket(data); err != nil {
 				return errors.Trace(err)
@@ -76,7 +90,11 @@ func (c *Conn) writePrepare(s *Stmt) error {
 	if s.Columns > 0 {
 		for i := 0; i < s.Columns; i++ {
 			data = data[0:4]
-			data = append(data, columnFieldData...)
+			if i < len(s.ColumnFields) {
+				data = append(data, s.ColumnFields[i].Dump()...)
+			} else {
+				data = append(data, columnFieldData...)
+			}
 
 			if err := c.WritePacket(data); err != nil {
 				return errors.Trace(err)
@@ -136,8 +154,19 @@ func (c *Conn) handleStmtExecute(data []byte) (*mysql.Result, error) {
 
 	paramNum := s.Params
 
+	// with query attributes, the params are followed by the attributes
+	withAttributes := c.queryAttributesEnabled()
+	if withAttributes && (paramNum > 0 || flag&mysql.PARAMETER_COUNT_AVAILABLE > 0) {
+		count, _, n := mysql.LengthEncodedInt(data[pos:])
+		if n == 0 || count < uint64(s.Params) || count > uint64(len(data)) {
+			return nil, mysql.ErrMalformPacket
+		}
+		paramNum = int(count)
+		pos += n
+	}
+
 	if paramNum > 0 {
-		nullBitmapLen := (s.Params + 7) >> 3
+		nullBitmapLen := (paramNum + 7) >> 3
 		if len(data) < (pos + nullBitmapLen + 1) {
 			return nil, mysql.ErrMalformPacket
 		}
@@ -147,24 +176,32 @@ func (c *Conn) handleStmtExecute(data []byte) (*mysql.Result, error) {
 		// new param bound flag
 		if data[pos] == 1 {
 			pos++
-			if len(data) < (pos + (paramNum << 1)) {
-				return nil, mysql.ErrMalformPacket
+			var names []string
+			var n int
+			var err error
+			if paramTypes, names, n, err = parseParamTypes(data[pos:], paramNum, withAttributes); err != nil {
+				return nil, errors.Trace(err)
 			}
-
-			paramTypes = data[pos : pos+(paramNum<<1)]
-			pos += paramNum << 1
+			pos += n
 
 			paramValues = data[pos:]
 
-			if err := c.bindStmtArgs(s, nullBitmaps, paramTypes, paramValues); err != nil {
+			if n, err = c.bindStmtArgs(s, nullBitmaps, paramTypes[:s.Params<<1], paramValues); err != nil {
 				return nil, errors.Trace(err)
 			}
+			if paramNum > s.Params {
+				attrs, _, err := attributeValues(nullBitmaps, s.Params, paramTypes, names, paramValues[n:])
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+				c.setQueryAttributes(attrs)
+			}
 		}
 	}
 
 	var r *mysql.Result
 	var err error
-	if r, err = c.h.HandleStmtExecute(s.Context, s.Query, s.Args); err != nil {
+	if r, err = c.contextHandler().HandleStmtExecuteContext(c.queryContext(s.Query), s.Context, s.Query, s.Args); err != nil {
 		return nil, errors.Trace(err)
 	}
 
@@ -173,7 +210,7 @@ func (c *Conn) handleStmtExecute(data []byte) (*mysql.Result, error) {
 	return r, nil
 }
 
-func (c *Conn) bindStmtArgs(s *Stmt, nullBitmap, paramTypes, paramValues []byte) error {
+func (c *Conn) bindStmtArgs(s *Stmt, nullBitmap, paramTypes, paramValues []byte) (int, error) {
 	args := s.Args
 
 	// Every param should have a type-and-flag of 2 bytes
@@ -181,129 +218,113 @@ func (c *Conn) bindStmtArgs(s *Stmt, nullBitmap, paramTypes, paramValues []byte)
 	// The flag only has one bit and that indicates if it is unsigned or not.
 	// Types are 1 byte, but might grow into the 7 unused bits in the future.
 	if len(paramTypes)/2 != s.Params {
-		return mysql.ErrMalformPacket
+		return 0, mysql.ErrMalformPacket
 	}
 
 	pos := 0
-
-	var v []byte
-	var n int
-	var isNull bool
-	var err error
-
 	for i := 0; i < s.Params; i++ {
-		if nullBitmap[i>>3]&(1<<(uint(i)%8)) > 0 {
-			args[i] = nil
+		if s.longData != nil && s.longData[i] {
 			continue
 		}
 
-		tp := paramTypes[i<<1]
-		isUnsigned := (paramTypes[(i<<1)+1] & mysql.PARAM_UNSIGNED) > 0
-
-		switch tp {
-		case mysql.MYSQL_TYPE_NULL:
+		if nullBitmap[i>>3]&(1<<(uint(i)%8)) > 0 {
 			args[i] = nil
 			continue
+		}
 
-		case mysql.MYSQL_TYPE_TINY:
-			if len(paramValues) < (pos + 1) {
-				return mysql.ErrMalformPacket
-			}
+		isUnsigned := (paramTypes[(i<<1)+1] & mysql.PARAM_UNSIGNED) > 0
+		v, n, err := binaryParamValue(paramTypes[i<<1], isUnsigned, paramValues[pos:])
+		if err != nil {
+			return 0, err
+		}
+		args[i] = v
+		pos += n
+	}
+	return pos, nil
+}
 
-			if isUnsigned {
-				args[i] = paramValues[pos]
-			} else {
-				args[i] = int8(paramValues[pos])
-			}
+// binaryParamValue decodes the value of type tp of a param in the binary
+// protocol at the start of data, and returns its length.
+func binaryParamValue(tp byte, isUnsigned bool, data []byte) (interface{}, int, error) {
+	switch tp {
+	case mysql.MYSQL_TYPE_NULL:
+		return nil, 0, nil
 
-			pos++
-			continue
+	case mysql.MYSQL_TYPE_TINY:
+		if len(data) < 1 {
+			return nil, 0, mysql.ErrMalformPacket
+		}
 
-		case mysql.MYSQL_TYPE_SHORT, mysql.MYSQL_TYPE_YEAR:
-			if len(paramValues) < (pos + 2) {
-				return mysql.ErrMalformPacket
-			}
+		if isUnsigned {
+			return data[0], 1, nil
+		}
+		return int8(data[0]), 1, nil
 
-			if isUnsigned {
-				args[i] = binary.LittleEndian.Uint16(paramValues[pos : pos+2])
-			} else {
-				args[i] = int16(binary.LittleEndian.Uint16(paramValues[pos : pos+2]))
-			}
-			pos += 2
-			continue
+	case mysql.MYSQL_TYPE_SHORT, mysql.MYSQL_TYPE_YEAR:
+		if len(data) < 2 {
+			return nil, 0, mysql.ErrMalformPacket
+		}
 
-		case mysql.MYSQL_TYPE_INT24, mysql.MYSQL_TYPE_LONG:
-			if len(paramValues) < (pos + 4) {
-				return mysql.ErrMalformPacket
-			}
+		if isUnsigned {
+			return binary.LittleEndian.Uint16(data), 2, nil
+		}
+		return int16(binary.LittleEndian.Uint16(data)), 2, nil
 
-			if isUnsigned {
-				args[i] = binary.LittleEndian.Uint32(paramValues[pos : pos+4])
-			} else {
-				args[i] = int32(binary.LittleEndian.Uint32(paramValues[pos : pos+4]))
-			}
-			pos += 4
-			continue
+	case mysql.MYSQL_TYPE_INT24, mysql.MYSQL_TYPE_LONG:
+		if len(data) < 4 {
+			return nil, 0, mysql.ErrMalformPacket
+		}
 
-		case mysql.MYSQL_TYPE_LONGLONG:
-			if len(paramValues) < (pos + 8) {
-				return mysql.ErrMalformPacket
-			}
+		if isUnsigned {
+			return binary.LittleEndian.Uint32(data), 4, nil
+		}
+		return int32(binary.LittleEndian.Uint32(data)), 4, nil
 
-			if isUnsigned {
-				args[i] = binary.LittleEndian.Uint64(paramValues[pos : pos+8])
-			} else {
-				args[i] = int64(binary.LittleEndian.Uint64(paramValues[pos : pos+8]))
-			}
-			pos += 8
-			continue
+	case mysql.MYSQL_TYPE_LONGLONG:
+		if len(data) < 8 {
+			return nil, 0, mysql.ErrMalformPacket
+		}
 
-		case mysql.MYSQL_TYPE_FLOAT:
-			if len(paramValues) < (pos + 4) {
-				return mysql.ErrMalformPacket
-			}
+		if isUnsigned {
+			return binary.LittleEndian.Uint64(data), 8, nil
+		}
+		return int64(binary.LittleEndian.Uint64(data)), 8, nil
 
-			args[i] = math.Float32frombits(binary.LittleEndian.Uint32(paramValues[pos : pos+4]))
-			pos += 4
-			continue
+	case mysql.MYSQL_TYPE_FLOAT:
+		if len(data) < 4 {
+			return nil, 0, mysql.ErrMalformPacket
+		}
 
-		case mysql.MYSQL_TYPE_DOUBLE:
-			if len(paramValues) < (pos + 8) {
-				return mysql.ErrMalformPacket
-			}
+		return math.Float32frombits(binary.LittleEndian.Uint32(data)), 4, nil
 
-			args[i] = math.Float64frombits(binary.LittleEndian.Uint64(paramValues[pos : pos+8]))
-			pos += 8
-			continue
+	case mysql.MYSQL_TYPE_DOUBLE:
+		if len(data) < 8 {
+			return nil, 0, mysql.ErrMalformPacket
+		}
 
-		case mysql.MYSQL_TYPE_DECIMAL, mysql.MYSQL_TYPE_NEWDECIMAL, mysql.MYSQL_TYPE_VARCHAR, mysql.MYSQL_TYPE_BIT,
-			mysql.MYSQL_TYPE_ENUM, mysql.MYSQL_TYPE_SET, mysql.MYSQL_TYPE_TINY_BLOB, mysql.MYSQL_TYPE_MEDIUM_BLOB,
-			mysql.MYSQL_TYPE_LONG_BLOB, mysql.MYSQL_TYPE_BLOB, mysql.MYSQL_TYPE_VAR_STRING, mysql.MYSQL_TYPE_STRING,
-			mysql.MYSQL_TYPE_GEOMETRY, mysql.MYSQL_TYPE_VECTOR,
-			mysql.MYSQL_TYPE_DATE, mysql.MYSQL_TYPE_NEWDATE,
-			mysql.MYSQL_TYPE_TIMESTAMP, mysql.MYSQL_TYPE_DATETIME, mysql.MYSQL_TYPE_TIME:
-			if len(paramValues) < (pos + 1) {
-				return mysql.ErrMalformPacket
-			}
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), 8, nil
 
-			v, isNull, n, err = mysql.LengthEncodedString(paramValues[pos:])
-			pos += n
-			if err != nil {
-				return errors.Trace(err)
-			}
+	case mysql.MYSQL_TYPE_DECIMAL, mysql.MYSQL_TYPE_NEWDECIMAL, mysql.MYSQL_TYPE_VARCHAR, mysql.MYSQL_TYPE_BIT,
+		mysql.MYSQL_TYPE_ENUM, mysql.MYSQL_TYPE_SET, mysql.MYSQL_TYPE_TINY_BLOB, mysql.MYSQL_TYPE_MEDIUM_BLOB,
+		mysql.MYSQL_TYPE_LONG_BLOB, mysql.MYSQL_TYPE_BLOB, mysql.MYSQL_TYPE_VAR_STRING, mysql.MYSQL_TYPE_STRING,
+		mysql.MYSQL_TYPE_GEOMETRY, mysql.MYSQL_TYPE_VECTOR,
+		mysql.MYSQL_TYPE_DATE, mysql.MYSQL_TYPE_NEWDATE,
+		mysql.MYSQL_TYPE_TIMESTAMP, mysql.MYSQL_TYPE_DATETIME, mysql.MYSQL_TYPE_TIME:
+		if len(data) < 1 {
+			return nil, 0, mysql.ErrMalformPacket
+		}
 
-			if !isNull {
-				args[i] = v
-				continue
-			} else {
-				args[i] = nil
-				continue
-			}
-		default:
-			return errors.Errorf("Stmt Unknown FieldType %d", tp)
+		v, isNull, n, err := mysql.LengthEncodedString(data)
+		if err != nil {
+			return nil, 0, errors.Trace(err)
 		}
+		if isNull {
+			return nil, n, nil
+		}
+		return v, n, nil
+	default:
+		return nil, 0, errors.Errorf("Stmt Unknown FieldType %d", tp)
 	}
-	return nil
 }
 
 // stmt send long data command has no response
@@ -324,8 +345,13 @@ func (c *Conn) handleStmtSendLongData(data []byte) error {
 		return nil
 	}
 
+	if s.longData == nil {
+		s.longData = make([]bool, s.Params)
+	}
+	s.longData[paramId] = true
+
 	if s.Args[paramId] == nil {
-		s.Args[paramId] = data[6:]
+		s.Args[paramId] = append([]byte{}, data[6:]...)
 	} else {
 		if b, ok := s.Args[paramId].([]byte); ok {
 			b = append(b, data[6:]...)
@@ -369,7 +395,7 @@ func (c *Conn) handleStmtClose(data []byte) error {
 		return nil
 	}
 
-	if err := c.h.HandleStmtClose(stmt.Context); err != nil {
+	if err := c.contextHandler().HandleStmtCloseContext(c.Context(), stmt.Context); err != nil {
 		return err
 	}
 
@@ -0,0 +1,315 @@
+package server
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// PreparedQuery is a prepared query with the positions of its ? placeholders,
+// to bind the args of COM_STMT_EXECUTE into a text query. It is meant to be
+// the context of the statement, returned by HandleStmtPrepare:
+//
+//	func (h *handler) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+//		p, err := server.ParsePreparedQuery(query)
+//		if err != nil {
+//			return 0, 0, nil, err
+//		}
+//		return p.Params(), 0, p, nil
+//	}
+//
+//	func (h *handler) HandleStmtExecute(context interface{}, query string, args []interface{}) (*mysql.Result, error) {
+//		q, err := context.(*server.PreparedQuery).Bind(args)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return h.HandleQuery(q)
+//	}
+type PreparedQuery struct {
+	query string
+	// offsets of the placeholders in query
+	placeholders []int
+}
+
+// ParsePreparedQuery returns the prepared query, the ? in the strings, quoted
+// identifiers and comments are not placeholders, except in the conditional
+// comments like /*!80000 ... */, whose text is part of the query.
+func ParsePreparedQuery(query string) (*PreparedQuery, error) {
+	p := &PreparedQuery{query: query}
+	for i := 0; i < len(query); {
+		ch := query[i]
+		switch {
+		case ch == '?':
+			p.placeholders = append(p.placeholders, i)
+			i++
+		case ch == '\'' || ch == '"' || ch == '`':
+			n, ok := quotedLen(query[i:])
+			if !ok {
+				return nil, errors.Errorf("unterminated %c at %d in %q", ch, i, query)
+			}
+			i += n
+		case strings.HasPrefix(query[i:], "/*!"):
+			// the text of a conditional comment is part of the query, its */
+			// is skipped by the default case
+			i += 3
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return nil, errors.Errorf("unterminated comment at %d in %q", i, query)
+			}
+			i += end + 4
+		case strings.HasPrefix(query[i:], "-- ") || ch == '#':
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			i += end
+		default:
+			i++
+		}
+	}
+	return p, nil
+}
+
+// quotedLen returns the length of the string or the quoted identifier s starts
+// with, the quotes are escaped by doubling them, or by a backslash in strings.
+func quotedLen(s string) (int, bool) {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+// Query returns the query with its placeholders.
+func (p *PreparedQuery) Query() string {
+	return p.query
+}
+
+// Params returns the number of placeholders of the query.
+func (p *PreparedQuery) Params() int {
+	return len(p.placeholders)
+}
+
+// Bind returns the query with its placeholders replaced by args as literals,
+// see StmtArgLiteral.
+func (p *PreparedQuery) Bind(args []interface{}) (string, error) {
+	if len(args) != len(p.placeholders) {
+		return "", errors.Errorf("argument mismatch, need %d but got %d", len(p.placeholders), len(args))
+	}
+	var b strings.Builder
+	b.Grow(len(p.query) + 8*len(args))
+	last := 0
+	for i, offset := range p.placeholders {
+		literal, err := StmtArgLiteral(args[i])
+		if err != nil {
+			return "", errors.Annotatef(err, "arg %d", i)
+		}
+		b.WriteString(p.query[last:offset])
+		b.WriteString(literal)
+		last = offset + 1
+	}
+	b.WriteString(p.query[last:])
+	return b.String(), nil
+}
+
+// StmtArgLiteral returns the SQL literal of an arg of HandleStmtExecute, or of
+// a value of the same Go types: NULL for nil, the numbers as they are, and the
+// strings, the []byte and the time.Time quoted and escaped. The escaping
+// assumes the NO_BACKSLASH_ESCAPES SQL mode is off.
+func StmtArgLiteral(v interface{}) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		if x {
+			return "1", nil
+		}
+		return "0", nil
+	case int8, int16, int32, int64, int, uint8, uint16, uint32, uint64, uint:
+		n, _ := StmtArgs{x}.String(0)
+		return n, nil
+	case float32:
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return "", errors.Errorf("invalid float %v", x)
+		}
+		return strconv.FormatFloat(float64(x), 'g', -1, 32), nil
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return "", errors.Errorf("invalid float %v", x)
+		}
+		return strconv.FormatFloat(x, 'g', -1, 64), nil
+	case []byte:
+		return "'" + mysql.Escape(string(x)) + "'", nil
+	case string:
+		return "'" + mysql.Escape(x) + "'", nil
+	case time.Time:
+		return "'" + x.Format(stmtArgTimeFormat) + "'", nil
+	default:
+		return "", errors.Errorf("unsupported arg type %T", v)
+	}
+}
+
+const stmtArgTimeFormat = "2006-01-02 15:04:05.999999"
+
+// StmtArgs are the args of HandleStmtExecute, decoded from the binary protocol:
+// the integers are int8 to int64 or uint8 to uint64 as sent by the client, the
+// floats float32 or float64, the other types []byte, and NULL is nil. Their
+// accessors convert them to the types asked, failing if they can't, like
+// for a NULL.
+type StmtArgs []interface{}
+
+// IsNull returns whether the arg i is NULL.
+func (a StmtArgs) IsNull(i int) bool {
+	return a[i] == nil
+}
+
+// Int64 returns the arg i as an int64, the strings are parsed.
+func (a StmtArgs) Int64(i int) (int64, error) {
+	switch x := a[i].(type) {
+	case int8:
+		return int64(x), nil
+	case int16:
+		return int64(x), nil
+	case int32:
+		return int64(x), nil
+	case int64:
+		return x, nil
+	case int:
+		return int64(x), nil
+	case []byte, string:
+		s, _ := a.String(i)
+		n, err := strconv.ParseInt(s, 10, 64)
+		return n, errors.Trace(err)
+	}
+	if u, err := a.Uint64(i); err == nil {
+		if u > math.MaxInt64 {
+			return 0, errors.Errorf("arg %d %d overflows int64", i, u)
+		}
+		return int64(u), nil
+	}
+	return 0, a.typeError(i, "int64")
+}
+
+// Uint64 returns the arg i as an uint64, the strings are parsed.
+func (a StmtArgs) Uint64(i int) (uint64, error) {
+	switch x := a[i].(type) {
+	case uint8:
+		return uint64(x), nil
+	case uint16:
+		return uint64(x), nil
+	case uint32:
+		return uint64(x), nil
+	case uint64:
+		return x, nil
+	case uint:
+		return uint64(x), nil
+	case int8, int16, int32, int64, int:
+		n, _ := a.Int64(i)
+		if n < 0 {
+			return 0, errors.Errorf("arg %d %d overflows uint64", i, n)
+		}
+		return uint64(n), nil
+	case []byte, string:
+		s, _ := a.String(i)
+		n, err := strconv.ParseUint(s, 10, 64)
+		return n, errors.Trace(err)
+	}
+	return 0, a.typeError(i, "uint64")
+}
+
+// Float64 returns the arg i as a float64, the integers are converted and the
+// strings parsed.
+func (a StmtArgs) Float64(i int) (float64, error) {
+	switch x := a[i].(type) {
+	case float32:
+		return float64(x), nil
+	case float64:
+		return x, nil
+	case []byte, string:
+		s, _ := a.String(i)
+		f, err := strconv.ParseFloat(s, 64)
+		return f, errors.Trace(err)
+	}
+	if n, err := a.Int64(i); err == nil {
+		return float64(n), nil
+	}
+	if u, err := a.Uint64(i); err == nil {
+		return float64(u), nil
+	}
+	return 0, a.typeError(i, "float64")
+}
+
+// String returns the arg i as a string, the numbers are formatted.
+func (a StmtArgs) String(i int) (string, error) {
+	switch x := a[i].(type) {
+	case []byte:
+		return string(x), nil
+	case string:
+		return x, nil
+	case int8, int16, int32, int64, int:
+		n, _ := a.Int64(i)
+		return strconv.FormatInt(n, 10), nil
+	case uint8, uint16, uint32, uint64, uint:
+		u, _ := a.Uint64(i)
+		return strconv.FormatUint(u, 10), nil
+	case float32:
+		return strconv.FormatFloat(float64(x), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64), nil
+	}
+	return "", a.typeError(i, "string")
+}
+
+// Bytes returns the arg i as a []byte, the numbers are formatted.
+func (a StmtArgs) Bytes(i int) ([]byte, error) {
+	if b, ok := a[i].([]byte); ok {
+		return b, nil
+	}
+	s, err := a.String(i)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+// Time returns the arg i as a time in loc, parsed from a DATE or a DATETIME
+// string, with up to 6 fractional digits.
+func (a StmtArgs) Time(i int, loc *time.Location) (time.Time, error) {
+	if t, ok := a[i].(time.Time); ok {
+		return t.In(loc), nil
+	}
+	s, err := a.String(i)
+	if err != nil {
+		return time.Time{}, a.typeError(i, "time")
+	}
+	layout := stmtArgTimeFormat
+	if len(s) == len("2006-01-02") {
+		layout = time.DateOnly
+	}
+	t, err := time.ParseInLocation(layout, s, loc)
+	return t, errors.Trace(err)
+}
+
+func (a StmtArgs) typeError(i int, tp string) error {
+	if a[i] == nil {
+		return errors.Errorf("arg %d is NULL, not %s", i, tp)
+	}
+	return errors.Errorf("arg %d of type %T is not %s", i, a[i], tp)
+}
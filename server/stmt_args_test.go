@@ -0,0 +1,85 @@
+package server
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreparedQueryBind(t *testing.T) {
+	p, err := ParsePreparedQuery("SELECT ?, '?', \"a\\\"?\", `?` /* ? */ FROM t WHERE a = ? AND b IN (?, ?) -- ?\nAND c = ?")
+	require.NoError(t, err)
+	require.Equal(t, 5, p.Params())
+
+	q, err := p.Bind([]interface{}{nil, int8(-1), uint64(math.MaxUint64), []byte("it's\n"), time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)})
+	require.NoError(t, err)
+	require.Equal(t, "SELECT NULL, '?', \"a\\\"?\", `?` /* ? */ FROM t WHERE a = -1 AND b IN (18446744073709551615, 'it\\'s\\n') -- ?\n"+
+		"AND c = '2024-01-02 03:04:05.000006'", q)
+
+	_, err = p.Bind([]interface{}{1})
+	require.EqualError(t, err, "argument mismatch, need 5 but got 1")
+	_, err = p.Bind([]interface{}{1, 2, 3, 4, struct{}{}})
+	require.EqualError(t, err, "arg 4: unsupported arg type struct {}")
+	_, err = p.Bind([]interface{}{1, 2, 3, 4, math.NaN()})
+	require.Error(t, err)
+
+	// the text of a conditional comment is part of the query
+	p, err = ParsePreparedQuery("SELECT ? /*!80000 , ? */ /* ? */")
+	require.NoError(t, err)
+	require.Equal(t, 2, p.Params())
+
+	_, err = ParsePreparedQuery("SELECT 'a")
+	require.Error(t, err)
+	_, err = ParsePreparedQuery("SELECT 1 /* ?")
+	require.Error(t, err)
+}
+
+func TestStmtArgs(t *testing.T) {
+	args := StmtArgs{nil, int8(-3), uint64(math.MaxUint64), float32(1.5), []byte("42"), []byte("2024-01-02 03:04:05.5"), []byte("2024-01-02")}
+
+	require.True(t, args.IsNull(0))
+	require.False(t, args.IsNull(1))
+	_, err := args.Int64(0)
+	require.EqualError(t, err, "arg 0 is NULL, not int64")
+
+	n, err := args.Int64(1)
+	require.NoError(t, err)
+	require.Equal(t, int64(-3), n)
+	_, err = args.Uint64(1)
+	require.Error(t, err)
+	_, err = args.Int64(2)
+	require.Error(t, err)
+	u, err := args.Uint64(2)
+	require.NoError(t, err)
+	require.Equal(t, uint64(math.MaxUint64), u)
+	n, err = args.Int64(4)
+	require.NoError(t, err)
+	require.Equal(t, int64(42), n)
+	_, err = args.Int64(3)
+	require.EqualError(t, err, "arg 3 of type float32 is not int64")
+
+	f, err := args.Float64(3)
+	require.NoError(t, err)
+	require.Equal(t, 1.5, f)
+	f, err = args.Float64(1)
+	require.NoError(t, err)
+	require.Equal(t, -3.0, f)
+
+	s, err := args.String(2)
+	require.NoError(t, err)
+	require.Equal(t, "18446744073709551615", s)
+	b, err := args.Bytes(1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("-3"), b)
+
+	tm, err := args.Time(5, time.UTC)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 5e8, time.UTC), tm)
+	tm, err = args.Time(6, time.UTC)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), tm)
+	_, err = args.Time(1, time.UTC)
+	require.Error(t, err)
+}
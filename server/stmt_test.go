@@ -1,9 +1,14 @@
 package server
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+	"github.com/gongzhxu/go-mysql/packet"
+	mockconn "github.com/gongzhxu/go-mysql/test_util/conn"
 )
 
 func TestHandleStmtExecute(t *testing.T) {
@@ -46,3 +51,36 @@ func TestHandleStmtExecute(t *testing.T) {
 		}
 	}
 }
+
+type stmtFieldsHandler struct {
+	EmptyHandler
+}
+
+func (h stmtFieldsHandler) HandleStmtPrepareFields(query string) ([]*mysql.Field, []*mysql.Field, interface{}, error) {
+	params := []*mysql.Field{
+		{Name: []byte("?"), Type: mysql.MYSQL_TYPE_LONGLONG, Charset: 63, Flag: mysql.BINARY_FLAG},
+		{Name: []byte("?"), Type: mysql.MYSQL_TYPE_VAR_STRING, Charset: 255},
+	}
+	columns := []*mysql.Field{
+		{Name: []byte("id"), Type: mysql.MYSQL_TYPE_LONGLONG, Charset: 63, Flag: mysql.NOT_NULL_FLAG | mysql.PRI_KEY_FLAG},
+	}
+	return params, columns, query, nil
+}
+
+func TestStmtPrepareFields(t *testing.T) {
+	clientConn := &mockconn.MockConn{MultiWrite: true}
+	c := &Conn{Conn: packet.NewConn(clientConn), h: stmtFieldsHandler{}, stmts: make(map[uint32]*Stmt)}
+
+	v := c.dispatch([]byte{mysql.COM_STMT_PREPARE, 's', 'q', 'l'})
+	st, ok := v.(*Stmt)
+	require.True(t, ok)
+	require.Equal(t, 2, st.Params)
+	require.Equal(t, 1, st.Columns)
+	require.Equal(t, "sql", st.Context)
+
+	require.NoError(t, c.writePrepare(st))
+	// the ok packet is followed by the field definitions
+	require.True(t, bytes.Contains(clientConn.WriteBuffered, st.ParamFields[0].Dump()))
+	require.True(t, bytes.Contains(clientConn.WriteBuffered, st.ParamFields[1].Dump()))
+	require.True(t, bytes.Contains(clientConn.WriteBuffered, st.ColumnFields[0].Dump()))
+}
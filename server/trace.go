@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"encoding/hex"
+	"net/url"
+	"strings"
+)
+
+// TraceContext is the W3C trace context of a query, sent by the client in a
+// comment, see SetTraceContextExtraction.
+type TraceContext struct {
+	// Traceparent is like 00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01,
+	// TraceID, SpanID and Sampled are parsed from it.
+	Traceparent string
+	Tracestate  string
+
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+type traceContextKey struct{}
+
+// TraceContextFromContext returns the trace context of the query of ctx.
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// SetTraceContextExtraction enables parsing the trace context of the queries,
+// from a comment leading or ending them in the sqlcommenter format, like
+// /*traceparent='00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01'*/,
+// to add it to the context of the command, see Conn.Context and ContextHandler.
+// A proxy can then continue the trace of the client. For the prepared
+// statements, the comment of the query prepared is used.
+func (s *Server) SetTraceContextExtraction(enabled bool) {
+	s.traceContext = enabled
+}
+
+// queryContext returns the context of the command, with the trace context of
+// query if it has one.
+func (c *Conn) queryContext(query string) context.Context {
+	if c.serverConf != nil && c.serverConf.traceContext {
+		if tc, ok := parseTraceContext(query); ok {
+			c.stateMu.Lock()
+			if c.cmdCtx != nil {
+				c.cmdCtx = context.WithValue(c.cmdCtx, traceContextKey{}, tc)
+			}
+			c.stateMu.Unlock()
+		}
+	}
+	return c.Context()
+}
+
+// parseTraceContext parses the trace context of the comments leading query,
+// or of the last comment ending it.
+func parseTraceContext(query string) (TraceContext, bool) {
+	var tc TraceContext
+	found := false
+	rest := query
+	for {
+		rest = strings.TrimLeft(rest, " \t\r\n")
+		if !strings.HasPrefix(rest, "/*") {
+			break
+		}
+		end := strings.Index(rest, "*/")
+		if end < 0 {
+			break
+		}
+		found = tc.parseComment(rest[2:end]) || found
+		rest = rest[end+2:]
+	}
+	if !found {
+		trimmed := strings.TrimRight(query, " \t\r\n;")
+		if strings.HasSuffix(trimmed, "*/") {
+			if start := strings.LastIndex(trimmed, "/*"); start >= 0 {
+				found = tc.parseComment(trimmed[start+2 : len(trimmed)-2])
+			}
+		}
+	}
+	return tc, found
+}
+
+// parseComment parses the key='value' pairs of a comment, the values URL
+// encoded, and returns whether it has a valid traceparent.
+func (tc *TraceContext) parseComment(comment string) bool {
+	if strings.HasPrefix(comment, "!") {
+		// executable comment
+		return false
+	}
+	found := false
+	for _, pair := range strings.Split(comment, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), "'")
+		if v, err := url.QueryUnescape(strings.ReplaceAll(value, `\'`, "'")); err == nil {
+			value = v
+		}
+		switch strings.TrimSpace(key) {
+		case "traceparent":
+			found = tc.parseTraceparent(value)
+		case "tracestate":
+			tc.Tracestate = value
+		}
+	}
+	return found
+}
+
+// parseTraceparent parses a traceparent version-traceid-spanid-flags, the
+// trace and span IDs must not be zero.
+func (tc *TraceContext) parseTraceparent(s string) bool {
+	parts := strings.Split(s, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return false
+	}
+	var traceID [16]byte
+	var spanID [8]byte
+	var flags [1]byte
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil {
+		return false
+	}
+	if _, err := hex.Decode(spanID[:], []byte(parts[2])); err != nil {
+		return false
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return false
+	}
+	if traceID == [16]byte{} || spanID == [8]byte{} {
+		return false
+	}
+	tc.TraceID, tc.SpanID = traceID, spanID
+	tc.Traceparent = s
+	tc.Sampled = flags[0]&0x01 != 0
+	return true
+}
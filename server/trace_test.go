@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+func TestParseTraceContext(t *testing.T) {
+	const traceparent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+	for _, query := range []string{
+		"/*traceparent='" + traceparent + "',tracestate='congo%3Dt61rcWkgMzE'*/ SELECT 1",
+		"/* app */ /*traceparent=" + traceparent + ",tracestate=congo=t61rcWkgMzE*/SELECT 1",
+		"SELECT 1 /*tracestate='congo%3Dt61rcWkgMzE',traceparent='" + traceparent + "'*/;",
+	} {
+		tc, ok := parseTraceContext(query)
+		require.True(t, ok, query)
+		require.Equal(t, traceparent, tc.Traceparent)
+		require.Equal(t, "congo=t61rcWkgMzE", tc.Tracestate)
+		require.Equal(t, byte(0x0a), tc.TraceID[0])
+		require.Equal(t, byte(0x31), tc.SpanID[7])
+		require.True(t, tc.Sampled)
+	}
+
+	for _, query := range []string{
+		"SELECT 1",
+		"/*!40101 SET NAMES utf8 */",
+		"/*traceparent='00-00000000000000000000000000000000-b7ad6b7169203331-01'*/ SELECT 1",
+		"/*traceparent='00-0af7651916cd43dd8448eb211c80319c-b7ad6b71692033-01'*/ SELECT 1",
+		"/*traceparent='00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01' SELECT 1",
+	} {
+		_, ok := parseTraceContext(query)
+		require.False(t, ok, query)
+	}
+}
+
+type traceContextHandler struct {
+	ContextHandler
+	contexts chan TraceContext
+}
+
+func (h *traceContextHandler) HandleQueryContext(ctx context.Context, query string) (*mysql.Result, error) {
+	tc, _ := TraceContextFromContext(ctx)
+	h.contexts <- tc
+	return nil, nil
+}
+
+func (h *traceContextHandler) HandleStmtPrepareContext(context.Context, string) (int, int, interface{}, error) {
+	return 1, 0, nil, nil
+}
+
+func (h *traceContextHandler) HandleStmtExecuteContext(ctx context.Context, _ interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	return h.HandleQueryContext(ctx, query)
+}
+
+func TestTraceContextExtraction(t *testing.T) {
+	s := NewDefaultServer()
+	s.SetTraceContextExtraction(true)
+	h := &traceContextHandler{ContextHandler: AdaptHandler(EmptyHandler{}), contexts: make(chan TraceContext, 1)}
+
+	addr := serveConns(t, func(c net.Conn) (*Conn, error) {
+		return s.NewConn(c, "root", "", AdaptContextHandler(h))
+	}, nil)
+
+	traceparent := client.Traceparent([16]byte{1}, [8]byte{2}, true)
+	comment := &client.QueryCommentHook{Tags: func(*client.QueryEvent) map[string]string {
+		return map[string]string{"traceparent": traceparent}
+	}}
+	conn, err := client.Connect(addr, "root", "", "", "", client.WithQueryHook(comment))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Execute("INSERT INTO t VALUES (1)")
+	require.NoError(t, err)
+	require.Equal(t, TraceContext{Traceparent: traceparent, TraceID: [16]byte{1}, SpanID: [8]byte{2}, Sampled: true}, <-h.contexts)
+
+	_, err = conn.Execute("INSERT INTO t VALUES (?)", 1)
+	require.NoError(t, err)
+	require.Equal(t, traceparent, (<-h.contexts).Traceparent)
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"time"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// UserLimits are the resource limits of a user, like the MAX_USER_CONNECTIONS and
+// MAX_QUERIES_PER_HOUR options of CREATE USER. 0 means unlimited.
+type UserLimits struct {
+	// MaxConnections is the number of connections of the user at the same time.
+	MaxConnections int
+	// MaxQueriesPerHour is the number of COM_QUERY and COM_STMT_EXECUTE commands
+	// of the user in an hour, counted over all its connections.
+	MaxQueriesPerHour int
+}
+
+// UserLimitsProvider is for credential providers that want to limit the resources
+// of the users. The limits are read once the user is authenticated, and kept for
+// the whole connection. A connection over the limits gets ER_USER_LIMIT_REACHED
+// and is closed, a query over the limits gets ER_USER_LIMIT_REACHED.
+type UserLimitsProvider interface {
+	GetUserLimits(username string) (UserLimits, error)
+}
+
+// userUsage is the resources used by a user, since is the start of the hour
+// the queries are counted in.
+type userUsage struct {
+	conns   int
+	queries int
+	since   time.Time
+}
+
+// acquireUserConn reads the limits of the user and counts the connection, it
+// returns ER_USER_LIMIT_REACHED if the user has too many connections.
+func (c *Conn) acquireUserConn() error {
+	p, ok := c.credentialProvider.(UserLimitsProvider)
+	if !ok || c.serverConf == nil {
+		return nil
+	}
+	limits, err := p.GetUserLimits(c.user)
+	if err != nil {
+		return err
+	}
+	if limits.MaxConnections <= 0 && limits.MaxQueriesPerHour <= 0 {
+		return nil
+	}
+
+	s := c.serverConf
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+
+	u := s.userUsage(c.user)
+	if limits.MaxConnections > 0 && u.conns >= limits.MaxConnections {
+		s.releaseUserUsage(c.user, u)
+		return mysql.NewDefaultError(mysql.ER_USER_LIMIT_REACHED, c.user, "max_user_connections", limits.MaxConnections)
+	}
+	u.conns++
+	c.userLimits = &limits
+	return nil
+}
+
+// releaseUserConn uncounts the connection, it is called when the connection is
+// closed.
+func (c *Conn) releaseUserConn() {
+	if c.userLimits == nil || c.serverConf == nil || !c.userReleased.CompareAndSwap(false, true) {
+		return
+	}
+
+	s := c.serverConf
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+
+	if u, ok := s.users[c.user]; ok {
+		u.conns--
+		s.releaseUserUsage(c.user, u)
+	}
+}
+
+// countUserQuery counts a query of the user, it returns ER_USER_LIMIT_REACHED if
+// the user has sent too many queries in the current hour.
+func (c *Conn) countUserQuery() error {
+	if c.userLimits == nil || c.userLimits.MaxQueriesPerHour <= 0 || c.serverConf == nil {
+		return nil
+	}
+
+	s := c.serverConf
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+
+	u := s.userUsage(c.user)
+	if now := time.Now(); now.Sub(u.since) >= time.Hour {
+		u.since = now
+		u.queries = 0
+	}
+	if u.queries >= c.userLimits.MaxQueriesPerHour {
+		return mysql.NewDefaultError(mysql.ER_USER_LIMIT_REACHED, c.user, "max_questions", c.userLimits.MaxQueriesPerHour)
+	}
+	u.queries++
+	return nil
+}
+
+// userUsage returns the usage of user, s.usersMu must be held.
+func (s *Server) userUsage(user string) *userUsage {
+	if s.users == nil {
+		s.users = make(map[string]*userUsage)
+	}
+	u, ok := s.users[user]
+	if !ok {
+		u = &userUsage{since: time.Now()}
+		s.users[user] = u
+	}
+	return u
+}
+
+// releaseUserUsage forgets the usage of user once it has no connections and
+// its queries are not counted anymore, s.usersMu must be held.
+func (s *Server) releaseUserUsage(user string, u *userUsage) {
+	if u.conns <= 0 && (u.queries == 0 || time.Since(u.since) >= time.Hour) {
+		delete(s.users, user)
+	}
+}
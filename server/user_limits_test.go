@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+type limitedProvider struct {
+	*InMemoryProvider
+	limits UserLimits
+}
+
+func (p *limitedProvider) GetUserLimits(string) (UserLimits, error) {
+	return p.limits, nil
+}
+
+func TestUserLimits(t *testing.T) {
+	s := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	p := &limitedProvider{InMemoryProvider: NewInMemoryProvider(), limits: UserLimits{MaxConnections: 1, MaxQueriesPerHour: 2}}
+	p.AddUser("root", "")
+
+	addr := serveConns(t, func(c net.Conn) (*Conn, error) {
+		return s.NewCustomizedConn(c, p, &blockingHandler{})
+	}, nil)
+
+	conn, err := client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+
+	_, err = client.Connect(addr, "root", "", "", "")
+	require.Error(t, err)
+	code, _ := mysql.MyErrorCode(err)
+	require.EqualValues(t, mysql.ER_USER_LIMIT_REACHED, code)
+	require.Contains(t, err.Error(), "max_user_connections")
+
+	require.NoError(t, conn.Ping())
+	_, err = conn.Execute("SELECT 1")
+	require.NoError(t, err)
+	_, err = conn.Execute("SELECT 2")
+	require.NoError(t, err)
+	_, err = conn.Execute("SELECT 3")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "max_questions")
+
+	// the queries are counted for the user, not the connection
+	conn.Close()
+	require.Eventually(t, func() bool { return s.ConnCount() == 0 }, time.Second, 10*time.Millisecond)
+	conn, err = client.Connect(addr, "root", "", "", "")
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Execute("SELECT 4")
+	require.Error(t, err)
+
+	// a new hour starts
+	s.usersMu.Lock()
+	s.users["root"].since = time.Now().Add(-time.Hour)
+	s.usersMu.Unlock()
+	_, err = conn.Execute("SELECT 5")
+	require.NoError(t, err)
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// SetOptionHandler is for handlers that want to handle COM_SET_OPTION, option is
+// MYSQL_OPTION_MULTI_STATEMENTS_ON or MYSQL_OPTION_MULTI_STATEMENTS_OFF.
+// Without it COM_SET_OPTION is passed to HandleOtherCommand, as before the
+// interface. The CLIENT_MULTI_STATEMENTS capability of the connection is set or
+// unset if the handler returns no error.
+type SetOptionHandler interface {
+	HandleSetOption(option uint16) error
+}
+
+// StatisticsHandler is for handlers that want to handle COM_STATISTICS, the
+// returned string is sent as is, like "Uptime: 10  Threads: 1  Questions: 3 ...".
+// Without it the uptime and the open connections of the server are reported.
+type StatisticsHandler interface {
+	HandleStatistics() (string, error)
+}
+
+// DebugHandler is for handlers that want to handle COM_DEBUG, which asks the
+// server to dump debug information to its log. Without it COM_DEBUG is a no-op.
+type DebugHandler interface {
+	HandleDebug() error
+}
+
+// statisticsResponse is the response of COM_STATISTICS, a string without header
+type statisticsResponse string
+
+func (c *Conn) handleSetOption(data []byte) interface{} {
+	if len(data) < 2 {
+		return mysql.ErrMalformPacket
+	}
+	option := binary.LittleEndian.Uint16(data)
+	if option != mysql.MYSQL_OPTION_MULTI_STATEMENTS_ON && option != mysql.MYSQL_OPTION_MULTI_STATEMENTS_OFF {
+		return mysql.NewDefaultError(mysql.ER_UNKNOWN_COM_ERROR)
+	}
+
+	if h, ok := c.h.(SetOptionHandler); ok {
+		if err := h.HandleSetOption(option); err != nil {
+			return err
+		}
+	} else if err := c.contextHandler().HandleOtherCommandContext(c.Context(), mysql.COM_SET_OPTION, data); err != nil {
+		return err
+	}
+
+	if option == mysql.MYSQL_OPTION_MULTI_STATEMENTS_ON {
+		c.SetCapability(mysql.CLIENT_MULTI_STATEMENTS)
+	} else {
+		c.UnsetCapability(mysql.CLIENT_MULTI_STATEMENTS)
+	}
+	return eofResponse{}
+}
+
+func (c *Conn) handleStatistics() interface{} {
+	if h, ok := c.h.(StatisticsHandler); ok {
+		s, err := h.HandleStatistics()
+		if err != nil {
+			return err
+		}
+		return statisticsResponse(s)
+	}
+
+	var uptime int64
+	var threads int
+	var questions uint64
+	if c.serverConf != nil {
+		if !c.serverConf.startTime.IsZero() {
+			uptime = int64(time.Since(c.serverConf.startTime).Seconds())
+		}
+		threads = c.serverConf.ConnCount()
+		questions = c.serverConf.questions.Load()
+	}
+	var qps float64
+	if uptime > 0 {
+		qps = float64(questions) / float64(uptime)
+	}
+	return statisticsResponse(fmt.Sprintf(
+		"Uptime: %d  Threads: %d  Questions: %d  Slow queries: 0  Opens: 0  Flush tables: 0  Open tables: 0  Queries per second avg: %.3f",
+		uptime, threads, questions, qps))
+}
+
+func (c *Conn) handleDebug() interface{} {
+	if h, ok := c.h.(DebugHandler); ok {
+		if err := h.HandleDebug(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Conn) writeStatistics(s statisticsResponse) error {
+	data := make([]byte, 4, 4+len(s))
+	data = append(data, s...)
+	return c.WritePacket(data)
+}
+
+func (c *Conn) countQuestion() {
+	if c.serverConf != nil {
+		c.serverConf.questions.Add(1)
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+type utilityHandler struct {
+	EmptyHandler
+	options []uint16
+	debug   bool
+}
+
+func (h *utilityHandler) HandleSetOption(option uint16) error {
+	h.options = append(h.options, option)
+	return nil
+}
+
+func (h *utilityHandler) HandleStatistics() (string, error) {
+	return "Uptime: 1", nil
+}
+
+func (h *utilityHandler) HandleDebug() error {
+	h.debug = true
+	return nil
+}
+
+// otherCommandHandler records the commands passed to HandleOtherCommand
+type otherCommandHandler struct {
+	EmptyHandler
+	commands [][]byte
+}
+
+func (h *otherCommandHandler) HandleOtherCommand(cmd byte, data []byte) error {
+	h.commands = append(h.commands, append([]byte{cmd}, data...))
+	return nil
+}
+
+func TestUtilityCommands(t *testing.T) {
+	s := NewServer("8.0.12", mysql.DEFAULT_COLLATION_ID, mysql.AUTH_NATIVE_PASSWORD, nil, nil)
+	c := &Conn{serverConf: s, h: EmptyHandler{}}
+
+	// without SetOptionHandler, COM_SET_OPTION is passed to HandleOtherCommand
+	err, ok := c.dispatch([]byte{mysql.COM_SET_OPTION, mysql.MYSQL_OPTION_MULTI_STATEMENTS_ON, 0}).(error)
+	require.True(t, ok)
+	require.ErrorContains(t, err, "not supported now")
+	require.False(t, c.HasCapability(mysql.CLIENT_MULTI_STATEMENTS))
+	other := &otherCommandHandler{}
+	c = &Conn{serverConf: s, h: other}
+	require.Equal(t, eofResponse{}, c.dispatch([]byte{mysql.COM_SET_OPTION, mysql.MYSQL_OPTION_MULTI_STATEMENTS_ON, 0}))
+	require.True(t, c.HasCapability(mysql.CLIENT_MULTI_STATEMENTS))
+	require.Equal(t, eofResponse{}, c.dispatch([]byte{mysql.COM_SET_OPTION, mysql.MYSQL_OPTION_MULTI_STATEMENTS_OFF, 0}))
+	require.False(t, c.HasCapability(mysql.CLIENT_MULTI_STATEMENTS))
+	require.Equal(t, [][]byte{
+		{mysql.COM_SET_OPTION, mysql.MYSQL_OPTION_MULTI_STATEMENTS_ON, 0},
+		{mysql.COM_SET_OPTION, mysql.MYSQL_OPTION_MULTI_STATEMENTS_OFF, 0},
+	}, other.commands)
+
+	err, ok = c.dispatch([]byte{mysql.COM_SET_OPTION, 5, 0}).(error)
+	require.True(t, ok)
+	require.EqualValues(t, mysql.ER_UNKNOWN_COM_ERROR, err.(*mysql.MyError).Code)
+
+	_ = c.dispatch(append([]byte{mysql.COM_QUERY}, "SELECT 1"...))
+	stats, ok := c.dispatch([]byte{mysql.COM_STATISTICS}).(statisticsResponse)
+	require.True(t, ok)
+	require.True(t, strings.HasPrefix(string(stats), "Uptime: "))
+	require.Contains(t, string(stats), "Questions: 1 ")
+
+	require.Nil(t, c.dispatch([]byte{mysql.COM_DEBUG}))
+
+	h := &utilityHandler{}
+	c = &Conn{serverConf: s, h: h}
+	require.Equal(t, eofResponse{}, c.dispatch([]byte{mysql.COM_SET_OPTION, mysql.MYSQL_OPTION_MULTI_STATEMENTS_ON, 0}))
+	require.Equal(t, []uint16{mysql.MYSQL_OPTION_MULTI_STATEMENTS_ON}, h.options)
+	require.True(t, c.HasCapability(mysql.CLIENT_MULTI_STATEMENTS))
+	require.Equal(t, statisticsResponse("Uptime: 1"), c.dispatch([]byte{mysql.COM_STATISTICS}))
+	require.Nil(t, c.dispatch([]byte{mysql.COM_DEBUG}))
+	require.True(t, h.debug)
+}
@@ -0,0 +1,505 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// SystemVariables are the global values of the system variables of a server,
+// shared by its connections, which start with them as their session values,
+// see NewSession and VariablesHandler. The names are case insensitive, the
+// values are strings like in SHOW VARIABLES.
+type SystemVariables struct {
+	mu       sync.RWMutex
+	defaults map[string]string
+	values   map[string]string
+}
+
+// NewSystemVariables returns the system variables with their default values,
+// like sql_mode: STRICT_TRANS_TABLES or autocommit: ON, the other variables
+// are unknown.
+func NewSystemVariables(defaults map[string]string) *SystemVariables {
+	v := &SystemVariables{
+		defaults: make(map[string]string, len(defaults)),
+		values:   make(map[string]string, len(defaults)),
+	}
+	for name, value := range defaults {
+		name = strings.ToLower(name)
+		v.defaults[name] = value
+		v.values[name] = value
+	}
+	return v
+}
+
+// Get returns the global value of the variable, ok is false if it is unknown.
+func (v *SystemVariables) Get(name string) (value string, ok bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	value, ok = v.values[strings.ToLower(name)]
+	return value, ok
+}
+
+// Set sets the global value of the variable, like SET GLOBAL, value is a
+// string, a number or a bool. The sessions not having set the variable see
+// the value.
+func (v *SystemVariables) Set(name string, value interface{}) error {
+	name = strings.ToLower(name)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	current, ok := v.values[name]
+	if !ok {
+		return mysql.NewDefaultError(mysql.ER_UNKNOWN_SYSTEM_VARIABLE, name)
+	}
+	s, err := formatVariable(name, current, value)
+	if err != nil {
+		return err
+	}
+	v.values[name] = s
+	return nil
+}
+
+// Reset sets the global value of the variable to its default, like SET GLOBAL
+// name = DEFAULT.
+func (v *SystemVariables) Reset(name string) error {
+	name = strings.ToLower(name)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	def, ok := v.defaults[name]
+	if !ok {
+		return mysql.NewDefaultError(mysql.ER_UNKNOWN_SYSTEM_VARIABLE, name)
+	}
+	v.values[name] = def
+	return nil
+}
+
+// All returns the global values of the variables by name.
+func (v *SystemVariables) All() map[string]string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	values := make(map[string]string, len(v.values))
+	for name, value := range v.values {
+		values[name] = value
+	}
+	return values
+}
+
+// SessionVariables are the values of the system variables of a connection: the
+// global values, except the ones set in the session. Unlike SystemVariables,
+// they are not safe for concurrent use.
+type SessionVariables struct {
+	global *SystemVariables
+	values map[string]string
+}
+
+// NewSession returns the variables of a new session.
+func (v *SystemVariables) NewSession() *SessionVariables {
+	return &SessionVariables{global: v, values: make(map[string]string)}
+}
+
+// Global returns the global variables of the session.
+func (s *SessionVariables) Global() *SystemVariables {
+	return s.global
+}
+
+// Get returns the value of the variable in the session, ok is false if it is
+// unknown.
+func (s *SessionVariables) Get(name string) (value string, ok bool) {
+	if value, ok = s.values[strings.ToLower(name)]; ok {
+		return value, true
+	}
+	return s.global.Get(name)
+}
+
+// GetInt returns the value of a numeric variable, like max_execution_time.
+func (s *SessionVariables) GetInt(name string) (int64, error) {
+	value, ok := s.Get(name)
+	if !ok {
+		return 0, mysql.NewDefaultError(mysql.ER_UNKNOWN_SYSTEM_VARIABLE, name)
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, mysql.NewDefaultError(mysql.ER_WRONG_TYPE_FOR_VAR, name)
+	}
+	return n, nil
+}
+
+// GetBool returns the value of a boolean variable, like autocommit, ON or
+// OFF.
+func (s *SessionVariables) GetBool(name string) (bool, error) {
+	value, ok := s.Get(name)
+	if !ok {
+		return false, mysql.NewDefaultError(mysql.ER_UNKNOWN_SYSTEM_VARIABLE, name)
+	}
+	b, ok := parseBoolVariable(value)
+	if !ok {
+		return false, mysql.NewDefaultError(mysql.ER_WRONG_TYPE_FOR_VAR, name)
+	}
+	return b, nil
+}
+
+// Set sets the value of the variable in the session, like SET SESSION, value
+// is a string, a number or a bool.
+func (s *SessionVariables) Set(name string, value interface{}) error {
+	current, ok := s.Get(name)
+	if !ok {
+		return mysql.NewDefaultError(mysql.ER_UNKNOWN_SYSTEM_VARIABLE, name)
+	}
+	str, err := formatVariable(name, current, value)
+	if err != nil {
+		return err
+	}
+	s.values[strings.ToLower(name)] = str
+	return nil
+}
+
+// Reset sets the value of the variable in the session to the global one, like
+// SET SESSION name = DEFAULT.
+func (s *SessionVariables) Reset(name string) error {
+	if _, ok := s.global.Get(name); !ok {
+		return mysql.NewDefaultError(mysql.ER_UNKNOWN_SYSTEM_VARIABLE, name)
+	}
+	delete(s.values, strings.ToLower(name))
+	return nil
+}
+
+// All returns the values of the variables in the session by name.
+func (s *SessionVariables) All() map[string]string {
+	values := s.global.All()
+	for name, value := range s.values {
+		values[name] = value
+	}
+	return values
+}
+
+// parseBoolVariable parses the value of a boolean variable.
+func parseBoolVariable(value string) (bool, bool) {
+	switch strings.ToUpper(value) {
+	case "ON", "1", "TRUE":
+		return true, true
+	case "OFF", "0", "FALSE":
+		return false, true
+	}
+	return false, false
+}
+
+// formatVariable returns value as the value of the variable name, whose
+// current value is current: the boolean variables, ON or OFF, stay ON or OFF,
+// like for SET autocommit = 1.
+func formatVariable(name string, current string, value interface{}) (string, error) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	case bool:
+		s = "OFF"
+		if v {
+			s = "ON"
+		}
+	case int:
+		s = strconv.Itoa(v)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case uint64:
+		s = strconv.FormatUint(v, 10)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return "", mysql.NewDefaultError(mysql.ER_WRONG_TYPE_FOR_VAR, name)
+	}
+
+	if c := strings.ToUpper(current); c == "ON" || c == "OFF" {
+		b, ok := parseBoolVariable(s)
+		if !ok {
+			return "", mysql.NewDefaultError(mysql.ER_WRONG_VALUE_FOR_VAR, name, s)
+		}
+		if s = "OFF"; b {
+			s = "ON"
+		}
+	}
+	return s, nil
+}
+
+// UnknownVariableHandler is for the handlers wrapped by VariablesHandler that
+// have variables unknown to its SystemVariables, e.g. a proxy forwarding them
+// to a backend. Without it, the queries using unknown variables are passed to
+// the handler.
+type UnknownVariableHandler interface {
+	// GetVariable returns the session or global value of the variable, ok is
+	// false if it is unknown too.
+	GetVariable(name string, global bool) (value string, ok bool, err error)
+	// SetVariable sets the session or global value of the variable, value is
+	// the string, or the number or word like ON, of the SET statement, nil for
+	// DEFAULT. ok is false if it is unknown too.
+	SetVariable(name string, global bool, value interface{}) (ok bool, err error)
+}
+
+// VariablesHandler answers the queries of the system variables of the session
+// from SessionVariables, and passes the other queries to the handler it wraps,
+// so a server or a proxy built on this package works with the clients and ORMs
+// setting and reading variables like sql_mode when they connect. It answers:
+//
+//	SET [GLOBAL | SESSION] name = value, @@[global. | session.]name = value, ...
+//	SELECT @@[global. | session.]name [AS alias], ...
+//	SHOW [GLOBAL | SESSION] VARIABLES [LIKE 'pattern' | WHERE ...]
+//
+// The values are strings, numbers, words like ON, DEFAULT or other variables.
+// The variables unknown to SystemVariables are asked to the handler if it
+// implements UnknownVariableHandler, they are not in SHOW VARIABLES. The
+// queries it can't parse, like SET with user variables or expressions, are
+// passed to the handler.
+//
+// Its variables are the session ones of a connection, so it is a handler per
+// connection, like the Handler passed to NewConn, sharing the SystemVariables
+// of the server. It implements ContextHandler, calling the one of the wrapped
+// handler, see AdaptHandler, but not the other optional interfaces like
+// StreamingQueryHandler.
+type VariablesHandler struct {
+	Handler
+
+	h       ContextHandler
+	unknown UnknownVariableHandler
+	vars    *SessionVariables
+}
+
+// NewVariablesHandler returns a handler answering the queries of the system
+// variables with a new session of global, and the other queries with h.
+func NewVariablesHandler(h Handler, global *SystemVariables) *VariablesHandler {
+	unknown, _ := h.(UnknownVariableHandler)
+	return &VariablesHandler{Handler: h, h: AdaptHandler(h), unknown: unknown, vars: global.NewSession()}
+}
+
+// Variables returns the variables of the session.
+func (h *VariablesHandler) Variables() *SessionVariables {
+	return h.vars
+}
+
+func (h *VariablesHandler) UseDB(dbName string) error {
+	return h.UseDBContext(context.Background(), dbName)
+}
+
+func (h *VariablesHandler) HandleQuery(query string) (*mysql.Result, error) {
+	return h.HandleQueryContext(context.Background(), query)
+}
+
+func (h *VariablesHandler) UseDBContext(ctx context.Context, dbName string) error {
+	return h.h.UseDBContext(ctx, dbName)
+}
+
+func (h *VariablesHandler) HandleQueryContext(ctx context.Context, query string) (*mysql.Result, error) {
+	if r, ok, err := h.handleVariablesQuery(query); ok {
+		return r, err
+	}
+	return h.h.HandleQueryContext(ctx, query)
+}
+
+func (h *VariablesHandler) HandleFieldListContext(ctx context.Context, table string, fieldWildcard string) ([]*mysql.Field, error) {
+	return h.h.HandleFieldListContext(ctx, table, fieldWildcard)
+}
+
+func (h *VariablesHandler) HandleStmtPrepareContext(ctx context.Context, query string) (int, int, interface{}, error) {
+	return h.h.HandleStmtPrepareContext(ctx, query)
+}
+
+func (h *VariablesHandler) HandleStmtExecuteContext(ctx context.Context, stmtCtx interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	return h.h.HandleStmtExecuteContext(ctx, stmtCtx, query, args)
+}
+
+func (h *VariablesHandler) HandleStmtCloseContext(ctx context.Context, stmtCtx interface{}) error {
+	return h.h.HandleStmtCloseContext(ctx, stmtCtx)
+}
+
+func (h *VariablesHandler) HandleOtherCommandContext(ctx context.Context, cmd byte, data []byte) error {
+	return h.h.HandleOtherCommandContext(ctx, cmd, data)
+}
+
+// variableRef is a system variable of a query, with its scope.
+type variableRef struct {
+	name   string
+	global bool
+}
+
+// variableAssignment is an assignment of SET, the value is a string, nil for
+// DEFAULT, or the variable ref.
+type variableAssignment struct {
+	variableRef
+	value interface{}
+	ref   *variableRef
+}
+
+// parseSetVariables parses a SET of system variables.
+func parseSetVariables(query string) ([]variableAssignment, bool) {
+	stmt, ok := parseStatement(query, "SET")
+	if !ok {
+		return nil, false
+	}
+	set, ok := stmt.(*ast.SetStmt)
+	if !ok {
+		return nil, false
+	}
+	assignments := make([]variableAssignment, len(set.Variables))
+	for i, v := range set.Variables {
+		if !v.IsSystem {
+			// a user variable, or SET NAMES
+			return nil, false
+		}
+		a := variableAssignment{variableRef: variableRef{name: v.Name, global: v.IsGlobal}}
+		switch e := v.Value.(type) {
+		case *ast.DefaultExpr:
+		case *ast.VariableExpr:
+			if !e.IsSystem {
+				return nil, false
+			}
+			a.ref = &variableRef{name: e.Name, global: e.IsGlobal}
+		case *ast.ColumnNameExpr:
+			// a word, like OFF
+			if e.Name.Table.O != "" {
+				return nil, false
+			}
+			a.value = e.Name.Name.O
+		default:
+			if a.value, ok = literal(e); !ok || a.value == nil {
+				return nil, false
+			}
+		}
+		assignments[i] = a
+	}
+	return assignments, true
+}
+
+// known returns whether the variable is known, to the SystemVariables or the
+// wrapped handler.
+func (h *VariablesHandler) known(ref variableRef) bool {
+	_, ok := h.vars.Get(ref.name)
+	return ok || h.unknown != nil
+}
+
+// get returns the value of the variable.
+func (h *VariablesHandler) get(ref variableRef) (string, error) {
+	var value string
+	var ok bool
+	if ref.global {
+		value, ok = h.vars.Global().Get(ref.name)
+	} else {
+		value, ok = h.vars.Get(ref.name)
+	}
+	if !ok && h.unknown != nil {
+		var err error
+		if value, ok, err = h.unknown.GetVariable(ref.name, ref.global); err != nil {
+			return "", err
+		}
+	}
+	if !ok {
+		return "", mysql.NewDefaultError(mysql.ER_UNKNOWN_SYSTEM_VARIABLE, ref.name)
+	}
+	return value, nil
+}
+
+// set applies an assignment of SET.
+func (h *VariablesHandler) set(a variableAssignment) error {
+	value := a.value
+	if a.ref != nil {
+		v, err := h.get(*a.ref)
+		if err != nil {
+			return err
+		}
+		value = v
+	}
+
+	if _, ok := h.vars.Get(a.name); !ok {
+		ok, err := h.unknown.SetVariable(a.name, a.global, value)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return mysql.NewDefaultError(mysql.ER_UNKNOWN_SYSTEM_VARIABLE, a.name)
+		}
+		return nil
+	}
+	switch {
+	case a.global && value == nil:
+		return h.vars.Global().Reset(a.name)
+	case a.global:
+		return h.vars.Global().Set(a.name, value)
+	case value == nil:
+		return h.vars.Reset(a.name)
+	default:
+		return h.vars.Set(a.name, value)
+	}
+}
+
+// handleVariablesQuery answers query if it is a query of the system variables,
+// and returns whether it is.
+func (h *VariablesHandler) handleVariablesQuery(query string) (*mysql.Result, bool, error) {
+	switch leadingKeyword(query) {
+	case "SET":
+		assignments, ok := parseSetVariables(query)
+		if !ok {
+			return nil, false, nil
+		}
+		for _, a := range assignments {
+			if !h.known(a.variableRef) || a.ref != nil && !h.known(*a.ref) {
+				return nil, false, nil
+			}
+		}
+		for _, a := range assignments {
+			if err := h.set(a); err != nil {
+				return nil, true, err
+			}
+		}
+		return nil, true, nil
+	case "SHOW", "SELECT":
+		mq, ok := parseMetadataQuery(query, "")
+		if !ok {
+			return nil, false, nil
+		}
+		if mq.show == "VARIABLES" {
+			vars := h.vars.All()
+			if mq.global {
+				vars = h.vars.Global().All()
+			}
+			t := &virtualTable{columns: stringColumns("Variable_name", "Value"), rows: variableRows(vars)}
+			r, err := t.showResult(mq)
+			return r, true, err
+		}
+		if mq.show != "" || mq.from != "" {
+			return nil, false, nil
+		}
+		refs := make([]variableRef, len(mq.items))
+		for i, item := range mq.items {
+			if item.variable == "" {
+				return nil, false, nil
+			}
+			if refs[i] = (variableRef{name: item.variable, global: item.global}); !h.known(refs[i]) {
+				return nil, false, nil
+			}
+		}
+		r, err := h.selectVariables(mq, refs)
+		return r, true, err
+	}
+	return nil, false, nil
+}
+
+// selectVariables returns the row of the SELECT mq of the variables refs.
+func (h *VariablesHandler) selectVariables(mq *metadataQuery, refs []variableRef) (*mysql.Result, error) {
+	t := &virtualTable{columns: make([]virtualColumn, len(mq.items))}
+	names := make([]string, len(mq.items))
+	row := make([]interface{}, len(mq.items))
+	for i, item := range mq.items {
+		t.columns[i].name, names[i] = item.name, item.name
+		v, err := h.get(refs[i])
+		if err != nil {
+			return nil, err
+		}
+		row[i] = v
+	}
+	t.rows = [][]interface{}{row}
+	return t.result(names)
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+type testVariablesHandler struct {
+	EmptyHandler
+	values map[string]interface{}
+}
+
+func (h *testVariablesHandler) GetVariable(name string, global bool) (string, bool, error) {
+	v, ok := h.values[name]
+	if !ok {
+		return "", false, nil
+	}
+	s, _ := v.(string)
+	return s, true, nil
+}
+
+func (h *testVariablesHandler) SetVariable(name string, global bool, value interface{}) (bool, error) {
+	if _, ok := h.values[name]; !ok {
+		return false, nil
+	}
+	h.values[name] = value
+	return true, nil
+}
+
+func TestSystemVariables(t *testing.T) {
+	global := NewSystemVariables(map[string]string{"SQL_MODE": "STRICT_TRANS_TABLES", "autocommit": "ON", "wait_timeout": "28800"})
+	s := global.NewSession()
+
+	v, ok := s.Get("sql_mode")
+	require.True(t, ok)
+	require.Equal(t, "STRICT_TRANS_TABLES", v)
+	_, ok = s.Get("nope")
+	require.False(t, ok)
+
+	require.NoError(t, s.Set("autocommit", 0))
+	b, err := s.GetBool("autocommit")
+	require.NoError(t, err)
+	require.False(t, b)
+	v, _ = s.Get("autocommit")
+	require.Equal(t, "OFF", v)
+	err = s.Set("autocommit", "maybe")
+	require.Equal(t, mysql.ER_WRONG_VALUE_FOR_VAR, int(err.(*mysql.MyError).Code))
+
+	require.NoError(t, s.Set("wait_timeout", int64(60)))
+	n, err := s.GetInt("wait_timeout")
+	require.NoError(t, err)
+	require.Equal(t, int64(60), n)
+	_, err = s.GetInt("sql_mode")
+	require.Equal(t, mysql.ER_WRONG_TYPE_FOR_VAR, int(err.(*mysql.MyError).Code))
+	err = s.Set("nope", 1)
+	require.Equal(t, mysql.ER_UNKNOWN_SYSTEM_VARIABLE, int(err.(*mysql.MyError).Code))
+
+	// the global values are seen by the sessions not having set them
+	require.NoError(t, global.Set("wait_timeout", 10))
+	require.NoError(t, global.Set("sql_mode", ""))
+	other := global.NewSession()
+	n, _ = other.GetInt("wait_timeout")
+	require.Equal(t, int64(10), n)
+	n, _ = s.GetInt("wait_timeout")
+	require.Equal(t, int64(60), n)
+	require.NoError(t, s.Reset("wait_timeout"))
+	n, _ = s.GetInt("wait_timeout")
+	require.Equal(t, int64(10), n)
+	require.Equal(t, map[string]string{"sql_mode": "", "autocommit": "OFF", "wait_timeout": "10"}, s.All())
+
+	require.NoError(t, global.Reset("sql_mode"))
+	v, _ = other.Get("sql_mode")
+	require.Equal(t, "STRICT_TRANS_TABLES", v)
+}
+
+func TestVariablesHandler(t *testing.T) {
+	global := NewSystemVariables(map[string]string{
+		"sql_mode": "STRICT_TRANS_TABLES", "autocommit": "ON", "time_zone": "SYSTEM", "long_query_time": "10", "max_join_size": "0",
+	})
+	h := NewVariablesHandler(EmptyHandler{}, global)
+
+	r, err := h.HandleQuery("SET autocommit = 0, SESSION sql_mode = 'ANSI', @@global.time_zone = '+00:00'")
+	require.NoError(t, err)
+	require.Nil(t, r)
+	names, rows := queryRows(t, h, "SELECT @@autocommit, @@session.sql_mode AS mode, @@global.sql_mode, @@time_zone")
+	require.Equal(t, []string{"@@autocommit", "mode", "@@global.sql_mode", "@@time_zone"}, names)
+	require.Equal(t, [][]interface{}{{[]byte("OFF"), []byte("ANSI"), []byte("STRICT_TRANS_TABLES"), []byte("+00:00")}}, rows)
+
+	names, rows = queryRows(t, h, "SHOW VARIABLES LIKE 'sql%'")
+	require.Equal(t, []string{"Variable_name", "Value"}, names)
+	require.Equal(t, [][]interface{}{{[]byte("sql_mode"), []byte("ANSI")}}, rows)
+	_, rows = queryRows(t, h, "SHOW GLOBAL VARIABLES WHERE Variable_name = 'autocommit'")
+	require.Equal(t, [][]interface{}{{[]byte("autocommit"), []byte("ON")}}, rows)
+
+	_, err = h.HandleQuery("SET sql_mode = DEFAULT, autocommit = @@global.autocommit")
+	require.NoError(t, err)
+	_, rows = queryRows(t, h, "SELECT @@sql_mode, @@autocommit")
+	require.Equal(t, [][]interface{}{{[]byte("STRICT_TRANS_TABLES"), []byte("ON")}}, rows)
+
+	_, err = h.HandleQuery("/*!40101 SET long_query_time = 1.5, max_join_size = -1 */")
+	require.NoError(t, err)
+	_, rows = queryRows(t, h, "SELECT @@long_query_time, @@max_join_size")
+	require.Equal(t, [][]interface{}{{[]byte("1.5"), []byte("-1")}}, rows)
+
+	_, err = h.HandleQuery("SET autocommit = 'nope'")
+	require.Equal(t, mysql.ER_WRONG_VALUE_FOR_VAR, int(err.(*mysql.MyError).Code))
+
+	// the queries of unknown variables and the others are passed to the handler
+	for _, query := range []string{
+		"SET nope = 1",
+		"SET autocommit = 1, nope = 1",
+		"SELECT @@nope",
+		"SELECT @@autocommit, DATABASE()",
+		"SET @x = 1",
+		"SET sql_mode = CONCAT(@@sql_mode, ',ANSI')",
+		"SELECT 1",
+	} {
+		_, err = h.HandleQuery(query)
+		require.EqualError(t, err, "not supported now", query)
+	}
+
+	// or to UnknownVariableHandler
+	th := &testVariablesHandler{values: map[string]interface{}{"net_write_timeout": "60"}}
+	h = NewVariablesHandler(th, global)
+	_, err = h.HandleQuery("SET net_write_timeout = 120, autocommit = OFF")
+	require.NoError(t, err)
+	require.Equal(t, "120", th.values["net_write_timeout"])
+	b, err := h.Variables().GetBool("autocommit")
+	require.NoError(t, err)
+	require.False(t, b)
+	_, err = h.HandleQuery("SET net_write_timeout = DEFAULT")
+	require.NoError(t, err)
+	require.Nil(t, th.values["net_write_timeout"])
+	_, err = h.HandleQuery("SELECT @@nope")
+	require.Equal(t, mysql.ER_UNKNOWN_SYSTEM_VARIABLE, int(err.(*mysql.MyError).Code))
+}
@@ -0,0 +1,149 @@
+package xclient
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// Collection is a document store collection, a table with a JSON doc column
+// and an _id column generated from it.
+//
+// Conditions are SQL expressions on the doc column, like
+// "doc->>'$.name' = ?", with optional ? placeholders. An empty condition
+// matches every document.
+type Collection struct {
+	conn   *Conn
+	Schema string
+	Name   string
+}
+
+// Collection returns a handle to an existing collection.
+func (c *Conn) Collection(schema, name string) *Collection {
+	return &Collection{conn: c, Schema: schema, Name: name}
+}
+
+// CreateCollection creates the collection using the mysqlx admin command.
+func (c *Conn) CreateCollection(schema, name string) (*Collection, error) {
+	if err := c.adminCommand("create_collection", schema, name); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return c.Collection(schema, name), nil
+}
+
+// DropCollection drops the collection using the mysqlx admin command.
+func (c *Conn) DropCollection(schema, name string) error {
+	return errors.Trace(c.adminCommand("drop_collection", schema, name))
+}
+
+func (c *Conn) adminCommand(command, schema, name string) error {
+	arg, err := encodeObjectAny([]string{"schema", "name"}, []interface{}{schema, name})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = c.executeStmt("mysqlx", command, [][]byte{arg})
+	return errors.Trace(err)
+}
+
+// Add inserts the documents. A document is either a JSON string / []byte or
+// a value that is marshaled with encoding/json. Documents without an _id get
+// one generated by the server.
+func (col *Collection) Add(docs ...interface{}) (*Result, error) {
+	if len(docs) == 0 {
+		return &Result{}, nil
+	}
+
+	var buf strings.Builder
+	buf.WriteString("INSERT INTO ")
+	buf.WriteString(col.table())
+	buf.WriteString(" (doc) VALUES ")
+
+	args := make([]interface{}, 0, len(docs))
+	for i, doc := range docs {
+		var s string
+		switch d := doc.(type) {
+		case string:
+			s = d
+		case []byte:
+			s = string(d)
+		default:
+			b, err := json.Marshal(d)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			s = string(b)
+		}
+
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		// JSON_INSERT doesn't overwrite an existing _id
+		buf.WriteString("(JSON_INSERT(?, '$._id', REPLACE(UUID(), '-', '')))")
+		args = append(args, s)
+	}
+
+	return col.conn.Execute(buf.String(), args...)
+}
+
+// Find returns the documents matching the condition.
+func (col *Collection) Find(condition string, args ...interface{}) ([]json.RawMessage, error) {
+	r, err := col.conn.Execute("SELECT doc FROM "+col.table()+where(condition), args...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	docs := make([]json.RawMessage, 0, len(r.Rows))
+	for _, row := range r.Rows {
+		switch v := row[0].(type) {
+		case string:
+			docs = append(docs, json.RawMessage(v))
+		case []byte:
+			docs = append(docs, json.RawMessage(v))
+		default:
+			return nil, errors.Errorf("unexpected doc type %T", v)
+		}
+	}
+	return docs, nil
+}
+
+// Modify sets the given JSON paths, like "$.name", to the values in the
+// documents matching the condition.
+func (col *Collection) Modify(set map[string]interface{}, condition string, args ...interface{}) (*Result, error) {
+	if len(set) == 0 {
+		return nil, errors.New("nothing to modify")
+	}
+
+	paths := make([]string, 0, len(set))
+	for p := range set {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	setArgs := make([]interface{}, 0, 2*len(set)+len(args))
+	for _, p := range paths {
+		setArgs = append(setArgs, p, set[p])
+	}
+	query := "UPDATE " + col.table() + " SET doc = JSON_SET(doc" + strings.Repeat(", ?, ?", len(set)) + ")" + where(condition)
+
+	return col.conn.Execute(query, append(setArgs, args...)...)
+}
+
+// Remove deletes the documents matching the condition.
+func (col *Collection) Remove(condition string, args ...interface{}) (*Result, error) {
+	return col.conn.Execute("DELETE FROM "+col.table()+where(condition), args...)
+}
+
+func (col *Collection) table() string {
+	return mysql.QuoteIdentifier(col.Schema) + "." + mysql.QuoteIdentifier(col.Name)
+}
+
+func where(condition string) string {
+	if condition == "" {
+		return ""
+	}
+	return " WHERE " + condition
+}
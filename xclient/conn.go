@@ -0,0 +1,434 @@
+// Package xclient is a minimal client for the MySQL X Protocol, the protobuf
+// based protocol served by the X Plugin (default port 33060). It supports
+// MYSQL41 authentication, SQL statements with placeholders and basic
+// document store CRUD, see Collection.
+package xclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"github.com/gongzhxu/go-mysql/client"
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+// DefaultPort is the default port of the X Plugin.
+const DefaultPort = 33060
+
+// Column types of ColumnMetaData, see mysqlx_resultset.proto
+const (
+	TypeSInt     = 1
+	TypeUInt     = 2
+	TypeDouble   = 5
+	TypeFloat    = 6
+	TypeBytes    = 7
+	TypeTime     = 10
+	TypeDatetime = 12
+	TypeSet      = 15
+	TypeEnum     = 16
+	TypeBit      = 17
+	TypeDecimal  = 18
+)
+
+// Conn is a connection speaking the X Protocol.
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	user     string
+	password string
+	db       string
+
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+// Option is a configuration callback applied before authentication.
+type Option func(*Conn) error
+
+// Column describes a column of a Result.
+type Column struct {
+	Name   string
+	Table  string
+	Schema string
+	// Type is one of the Type constants.
+	Type uint64
+}
+
+// Result is the outcome of a statement. Values of SInt, UInt, Double, Float and
+// Bytes columns are decoded to int64, uint64, float64, float32 and string, values
+// of other types are returned as the raw []byte of the X Protocol encoding.
+// NULL is returned as nil.
+type Result struct {
+	Columns []Column
+	Rows    [][]interface{}
+
+	AffectedRows uint64
+	InsertId     uint64
+}
+
+// Connect to a X Plugin endpoint, addr is host:port.
+func Connect(addr, user, password, dbName string, options ...Option) (*Conn, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	return ConnectWithDialer(context.Background(), "tcp", addr, user, password, dbName, dialer.DialContext, options...)
+}
+
+// ConnectWithDialer to a X Plugin endpoint using the given Dialer.
+func ConnectWithDialer(ctx context.Context, network, addr, user, password, dbName string, dialer client.Dialer, options ...Option) (*Conn, error) {
+	conn, err := dialer(ctx, network, addr)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	c := &Conn{
+		conn:     conn,
+		br:       bufio.NewReader(conn),
+		user:     user,
+		password: password,
+		db:       dbName,
+	}
+
+	for _, option := range options {
+		if err := option(c); err != nil {
+			c.conn.Close()
+			return nil, errors.Trace(err)
+		}
+	}
+
+	if err = c.authenticate(); err != nil {
+		c.conn.Close()
+		return nil, errors.Trace(err)
+	}
+
+	return c, nil
+}
+
+// authenticate runs the MYSQL41 challenge-response, which uses the same
+// scramble as mysql_native_password.
+func (c *Conn) authenticate() error {
+	if err := c.writeMessage(clientSessAuthenticateStart, appendBytesField(nil, 1, []byte("MYSQL41"))); err != nil {
+		return errors.Trace(err)
+	}
+
+	salt, err := c.readAuthData(serverSessAuthenticateContinue)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	resp := make([]byte, 0, len(c.db)+len(c.user)+43)
+	resp = append(resp, c.db...)
+	resp = append(resp, 0)
+	resp = append(resp, c.user...)
+	resp = append(resp, 0)
+	if len(c.password) > 0 {
+		resp = append(resp, '*')
+		resp = append(resp, strings.ToUpper(hex.EncodeToString(mysql.CalcPassword(salt, []byte(c.password))))...)
+	}
+
+	if err = c.writeMessage(clientSessAuthenticateCont, appendBytesField(nil, 1, resp)); err != nil {
+		return errors.Trace(err)
+	}
+
+	_, err = c.readAuthData(serverSessAuthenticateOk)
+	return errors.Trace(err)
+}
+
+func (c *Conn) readAuthData(expect byte) ([]byte, error) {
+	typ, payload, err := c.readMessage()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if typ != expect {
+		return nil, errors.Errorf("unexpected message type %d during authentication", typ)
+	}
+
+	var data []byte
+	err = walkFields(payload, func(field int, _ uint64, b []byte) error {
+		if field == 1 {
+			data = b
+		}
+		return nil
+	})
+	return data, errors.Trace(err)
+}
+
+// Close closes the session and the connection.
+func (c *Conn) Close() error {
+	err := c.writeMessage(clientConClose, nil)
+	if err == nil {
+		_, _, err = c.readMessage()
+	}
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return errors.Trace(err)
+}
+
+// SetDeadline sets the read and write deadlines of the underlying connection.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+// Reset resets the session state, keeping the connection authenticated.
+func (c *Conn) Reset() error {
+	// keep_open, so the session doesn't need to authenticate again
+	if err := c.writeMessage(clientSessReset, appendVarintField(nil, 1, 1)); err != nil {
+		return errors.Trace(err)
+	}
+	_, err := c.execute()
+	return errors.Trace(err)
+}
+
+// Execute runs a SQL statement, args replace the ? placeholders in query.
+func (c *Conn) Execute(query string, args ...interface{}) (*Result, error) {
+	encoded := make([][]byte, 0, len(args))
+	for _, arg := range args {
+		a, err := encodeScalarAny(arg)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		encoded = append(encoded, a)
+	}
+
+	return c.executeStmt("sql", query, encoded)
+}
+
+func (c *Conn) executeStmt(namespace, stmt string, args [][]byte) (*Result, error) {
+	msg := appendBytesField(nil, 1, []byte(stmt))
+	for _, arg := range args {
+		msg = appendBytesField(msg, 2, arg)
+	}
+	msg = appendBytesField(msg, 3, []byte(namespace))
+
+	if err := c.writeMessage(clientSQLStmtExecute, msg); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return c.execute()
+}
+
+// execute reads the response of a statement until StmtExecuteOk or Ok. Only
+// the first resultset is kept.
+func (c *Conn) execute() (*Result, error) {
+	r := new(Result)
+	moreResultsets := false
+
+	for {
+		typ, payload, err := c.readMessage()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		switch typ {
+		case serverOk, serverSQLStmtExecuteOk:
+			return r, nil
+		case serverNotice:
+			if err = r.handleNotice(payload); err != nil {
+				return nil, errors.Trace(err)
+			}
+		case serverResultsetColumnMetaData:
+			if !moreResultsets {
+				col, err := parseColumn(payload)
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+				r.Columns = append(r.Columns, col)
+			}
+		case serverResultsetRow:
+			if !moreResultsets {
+				row, err := r.parseRow(payload)
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+				r.Rows = append(r.Rows, row)
+			}
+		case serverResultsetFetchDoneMoreRS, serverResultsetFetchDoneMoreOutP:
+			moreResultsets = true
+		case serverResultsetFetchDone, serverResultsetFetchSuspended:
+		default:
+			return nil, errors.Errorf("unexpected message type %d", typ)
+		}
+	}
+}
+
+func (r *Result) handleNotice(payload []byte) error {
+	var (
+		typ  uint64
+		data []byte
+	)
+	err := walkFields(payload, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			typ = v
+		case 3:
+			data = b
+		}
+		return nil
+	})
+	if err != nil || typ != noticeSessionStateChanged {
+		return errors.Trace(err)
+	}
+
+	var (
+		param uint64
+		value interface{}
+	)
+	err = walkFields(data, func(field int, v uint64, b []byte) error {
+		var err error
+		switch field {
+		case 1:
+			param = v
+		case 2:
+			value, err = decodeScalar(b)
+		}
+		return err
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	n, _ := value.(uint64)
+	switch param {
+	case sessionStateRowsAffected:
+		r.AffectedRows = n
+	case sessionStateGeneratedInsertID:
+		r.InsertId = n
+	}
+	return nil
+}
+
+func parseColumn(payload []byte) (Column, error) {
+	var col Column
+	err := walkFields(payload, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			col.Type = v
+		case 2:
+			col.Name = string(b)
+		case 4:
+			col.Table = string(b)
+		case 6:
+			col.Schema = string(b)
+		}
+		return nil
+	})
+	return col, errors.Trace(err)
+}
+
+func (r *Result) parseRow(payload []byte) ([]interface{}, error) {
+	row := make([]interface{}, 0, len(r.Columns))
+	err := walkFields(payload, func(field int, _ uint64, b []byte) error {
+		if field != 1 {
+			return nil
+		}
+		if len(row) >= len(r.Columns) {
+			return errors.New("row has more fields than columns")
+		}
+		v, err := decodeField(r.Columns[len(row)].Type, b)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		row = append(row, v)
+		return nil
+	})
+	return row, errors.Trace(err)
+}
+
+func decodeField(typ uint64, b []byte) (interface{}, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	switch typ {
+	case TypeSInt:
+		v, _, err := readVarint(b)
+		return unzigzag(v), errors.Trace(err)
+	case TypeUInt:
+		v, _, err := readVarint(b)
+		return v, errors.Trace(err)
+	case TypeDouble:
+		if len(b) != 8 {
+			return nil, errors.Errorf("invalid double field length %d", len(b))
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case TypeFloat:
+		if len(b) != 4 {
+			return nil, errors.Errorf("invalid float field length %d", len(b))
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+	case TypeBytes:
+		// bytes are followed by a 0x00 to tell the empty string from NULL
+		return string(b[:len(b)-1]), nil
+	default:
+		return append([]byte(nil), b...), nil
+	}
+}
+
+// readMessage reads a frame and returns the message type and payload. Error
+// messages are returned as *mysql.MyError.
+func (c *Conn) readMessage() (byte, []byte, error) {
+	if c.ReadTimeout > 0 {
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.ReadTimeout))
+	}
+
+	var header [5]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return 0, nil, errors.Trace(err)
+	}
+	length := binary.LittleEndian.Uint32(header[:4])
+	if length == 0 {
+		return 0, nil, errors.New("invalid message length 0")
+	}
+
+	payload := make([]byte, length-1)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return 0, nil, errors.Trace(err)
+	}
+
+	if header[4] == serverError {
+		return 0, nil, parseError(payload)
+	}
+	return header[4], payload, nil
+}
+
+func parseError(payload []byte) error {
+	e := new(mysql.MyError)
+	err := walkFields(payload, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 2:
+			e.Code = uint16(v)
+		case 3:
+			e.Message = string(b)
+		case 4:
+			e.State = string(b)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return e
+}
+
+func (c *Conn) writeMessage(typ byte, payload []byte) error {
+	if c.WriteTimeout > 0 {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(c.WriteTimeout))
+	}
+
+	data := make([]byte, 5, 5+len(payload))
+	binary.LittleEndian.PutUint32(data, uint32(len(payload)+1))
+	data[4] = typ
+	data = append(data, payload...)
+
+	_, err := c.conn.Write(data)
+	return errors.Trace(err)
+}
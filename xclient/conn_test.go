@@ -0,0 +1,150 @@
+package xclient
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/pingcap/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gongzhxu/go-mysql/mysql"
+)
+
+type fakeServer struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+func (s *fakeServer) read(expect byte) []byte {
+	var header [5]byte
+	_, err := io.ReadFull(s.conn, header[:])
+	require.NoError(s.t, err)
+	require.Equal(s.t, expect, header[4])
+
+	payload := make([]byte, binary.LittleEndian.Uint32(header[:4])-1)
+	_, err = io.ReadFull(s.conn, payload)
+	require.NoError(s.t, err)
+	return payload
+}
+
+func (s *fakeServer) write(typ byte, payload []byte) {
+	data := binary.LittleEndian.AppendUint32(nil, uint32(len(payload)+1))
+	data = append(data, typ)
+	_, err := s.conn.Write(append(data, payload...))
+	require.NoError(s.t, err)
+}
+
+func fields(t *testing.T, payload []byte) map[int][][]byte {
+	m := make(map[int][][]byte)
+	require.NoError(t, walkFields(payload, func(field int, v uint64, data []byte) error {
+		if data == nil {
+			data = appendVarint(nil, v)
+		}
+		m[field] = append(m[field], data)
+		return nil
+	}))
+	return m
+}
+
+func TestConnExecute(t *testing.T) {
+	client, server := net.Pipe()
+	s := &fakeServer{t: t, conn: server}
+	salt := []byte("abcdefghijklmnopqrst")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		require.Equal(t, "MYSQL41", string(fields(t, s.read(clientSessAuthenticateStart))[1][0]))
+		s.write(serverSessAuthenticateContinue, appendBytesField(nil, 1, salt))
+
+		auth := fields(t, s.read(clientSessAuthenticateCont))[1][0]
+		expected := "test\x00root\x00*" + strings.ToUpper(hex.EncodeToString(mysql.CalcPassword(salt, []byte("secret"))))
+		require.Equal(t, expected, string(auth))
+		s.write(serverSessAuthenticateOk, nil)
+
+		stmt := fields(t, s.read(clientSQLStmtExecute))
+		require.Equal(t, "SELECT id, name, score FROM t WHERE id > ?", string(stmt[1][0]))
+		require.Equal(t, "sql", string(stmt[3][0]))
+		require.Len(t, stmt[2], 1)
+		arg, err := encodeScalarAny(int64(-1))
+		require.NoError(t, err)
+		require.Equal(t, arg, stmt[2][0])
+
+		col := func(typ uint64, name string) []byte {
+			return appendBytesField(appendVarintField(nil, 1, typ), 2, []byte(name))
+		}
+		s.write(serverResultsetColumnMetaData, col(TypeSInt, "id"))
+		s.write(serverResultsetColumnMetaData, col(TypeBytes, "name"))
+		s.write(serverResultsetColumnMetaData, col(TypeDouble, "score"))
+
+		row := appendBytesField(nil, 1, appendVarint(nil, zigzag(-5)))
+		row = appendBytesField(row, 1, []byte("foo\x00"))
+		row = appendBytesField(row, 1, binary.LittleEndian.AppendUint64(nil, math.Float64bits(1.5)))
+		s.write(serverResultsetRow, row)
+		// NULL fields are empty
+		row = appendBytesField(nil, 1, appendVarint(nil, zigzag(7)))
+		row = appendBytesField(row, 1, nil)
+		row = appendBytesField(row, 1, nil)
+		s.write(serverResultsetRow, row)
+		s.write(serverResultsetFetchDone, nil)
+
+		// SessionStateChanged ROWS_AFFECTED
+		value := appendBytesField(nil, 2, appendVarintField(appendVarintField(nil, 1, scalarUInt), 3, 2))
+		notice := appendVarintField(nil, 1, noticeSessionStateChanged)
+		notice = appendBytesField(notice, 3, append(appendVarintField(nil, 1, sessionStateRowsAffected), value...))
+		s.write(serverNotice, notice)
+		s.write(serverSQLStmtExecuteOk, nil)
+
+		s.read(clientSQLStmtExecute)
+		errPayload := appendVarintField(nil, 2, 1146)
+		errPayload = appendBytesField(errPayload, 3, []byte("Table 'test.x' doesn't exist"))
+		errPayload = appendBytesField(errPayload, 4, []byte("42S02"))
+		s.write(serverError, errPayload)
+
+		s.read(clientConClose)
+		s.write(serverOk, nil)
+	}()
+
+	dialer := func(context.Context, string, string) (net.Conn, error) { return client, nil }
+	c, err := ConnectWithDialer(context.Background(), "tcp", "", "root", "secret", "test", dialer)
+	require.NoError(t, err)
+
+	r, err := c.Execute("SELECT id, name, score FROM t WHERE id > ?", int64(-1))
+	require.NoError(t, err)
+	require.Len(t, r.Columns, 3)
+	require.Equal(t, "name", r.Columns[1].Name)
+	require.Equal(t, [][]interface{}{
+		{int64(-5), "foo", 1.5},
+		{int64(7), nil, nil},
+	}, r.Rows)
+	require.Equal(t, uint64(2), r.AffectedRows)
+
+	_, err = c.Execute("SELECT * FROM x")
+	require.Equal(t, &mysql.MyError{Code: 1146, Message: "Table 'test.x' doesn't exist", State: "42S02"}, errors.Cause(err))
+
+	require.NoError(t, c.Close())
+	<-done
+}
+
+func TestEncodeScalarAny(t *testing.T) {
+	for _, v := range []interface{}{nil, int64(-42), uint64(42), 1.25, float32(0.5), true, "abc"} {
+		b, err := encodeScalarAny(v)
+		require.NoError(t, err)
+
+		f := fields(t, b)
+		require.Equal(t, appendVarint(nil, anyScalar), f[1][0])
+		decoded, err := decodeScalar(f[2][0])
+		require.NoError(t, err)
+		require.Equal(t, v, decoded)
+	}
+
+	_, err := encodeScalarAny(struct{}{})
+	require.Error(t, err)
+}
@@ -0,0 +1,258 @@
+package xclient
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/pingcap/errors"
+)
+
+// Client message types, see mysqlx.proto ClientMessages.Type
+const (
+	clientConClose              byte = 3
+	clientSessAuthenticateStart byte = 4
+	clientSessAuthenticateCont  byte = 5
+	clientSessReset             byte = 6
+	clientSQLStmtExecute        byte = 12
+)
+
+// Server message types, see mysqlx.proto ServerMessages.Type
+const (
+	serverOk                         byte = 0
+	serverError                      byte = 1
+	serverSessAuthenticateContinue   byte = 3
+	serverSessAuthenticateOk         byte = 4
+	serverNotice                     byte = 11
+	serverResultsetColumnMetaData    byte = 12
+	serverResultsetRow               byte = 13
+	serverResultsetFetchDone         byte = 14
+	serverResultsetFetchSuspended    byte = 15
+	serverResultsetFetchDoneMoreRS   byte = 16
+	serverSQLStmtExecuteOk           byte = 17
+	serverResultsetFetchDoneMoreOutP byte = 18
+)
+
+// Notice types and session state parameters used by the client.
+const (
+	noticeSessionStateChanged = 3
+
+	sessionStateGeneratedInsertID = 3
+	sessionStateRowsAffected      = 4
+)
+
+// Scalar and Any types, see mysqlx_datatypes.proto
+const (
+	scalarSInt   = 1
+	scalarUInt   = 2
+	scalarNull   = 3
+	scalarOctets = 4
+	scalarDouble = 5
+	scalarFloat  = 6
+	scalarBool   = 7
+	scalarString = 8
+
+	anyScalar = 1
+	anyObject = 2
+)
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendTag(b []byte, field int, wireType int) []byte {
+	return appendVarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	b = appendTag(b, field, wireVarint)
+	return appendVarint(b, v)
+}
+
+func appendBytesField(b []byte, field int, v []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func unzigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func readVarint(b []byte) (uint64, int, error) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * i)
+		if b[i] < 0x80 {
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, errors.New("invalid varint")
+}
+
+// walkFields calls f for every field of the protobuf message b. For varint and
+// fixed fields v holds the value, for length-delimited fields data holds the payload.
+func walkFields(b []byte, f func(field int, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		tag, n, err := readVarint(b)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		b = b[n:]
+
+		var (
+			v    uint64
+			data []byte
+		)
+		switch tag & 7 {
+		case wireVarint:
+			if v, n, err = readVarint(b); err != nil {
+				return errors.Trace(err)
+			}
+		case wireFixed64:
+			if len(b) < 8 {
+				return errors.New("invalid fixed64 field")
+			}
+			v, n = binary.LittleEndian.Uint64(b), 8
+		case wireFixed32:
+			if len(b) < 4 {
+				return errors.New("invalid fixed32 field")
+			}
+			v, n = uint64(binary.LittleEndian.Uint32(b)), 4
+		case wireBytes:
+			var l uint64
+			if l, n, err = readVarint(b); err != nil {
+				return errors.Trace(err)
+			}
+			if uint64(len(b)-n) < l {
+				return errors.New("invalid length-delimited field")
+			}
+			data = b[n : n+int(l)]
+			n += int(l)
+		default:
+			return errors.Errorf("unsupported wire type %d", tag&7)
+		}
+		b = b[n:]
+
+		if err := f(int(tag>>3), v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeScalarAny encodes a Go value as Mysqlx.Datatypes.Any holding a scalar.
+func encodeScalarAny(arg interface{}) ([]byte, error) {
+	var s []byte
+	switch v := arg.(type) {
+	case nil:
+		s = appendVarintField(s, 1, scalarNull)
+	case int:
+		s = appendVarintField(appendVarintField(s, 1, scalarSInt), 2, zigzag(int64(v)))
+	case int8:
+		s = appendVarintField(appendVarintField(s, 1, scalarSInt), 2, zigzag(int64(v)))
+	case int16:
+		s = appendVarintField(appendVarintField(s, 1, scalarSInt), 2, zigzag(int64(v)))
+	case int32:
+		s = appendVarintField(appendVarintField(s, 1, scalarSInt), 2, zigzag(int64(v)))
+	case int64:
+		s = appendVarintField(appendVarintField(s, 1, scalarSInt), 2, zigzag(v))
+	case uint:
+		s = appendVarintField(appendVarintField(s, 1, scalarUInt), 3, uint64(v))
+	case uint8:
+		s = appendVarintField(appendVarintField(s, 1, scalarUInt), 3, uint64(v))
+	case uint16:
+		s = appendVarintField(appendVarintField(s, 1, scalarUInt), 3, uint64(v))
+	case uint32:
+		s = appendVarintField(appendVarintField(s, 1, scalarUInt), 3, uint64(v))
+	case uint64:
+		s = appendVarintField(appendVarintField(s, 1, scalarUInt), 3, v)
+	case float32:
+		s = appendVarintField(s, 1, scalarFloat)
+		s = binary.LittleEndian.AppendUint32(appendTag(s, 7, wireFixed32), math.Float32bits(v))
+	case float64:
+		s = appendVarintField(s, 1, scalarDouble)
+		s = binary.LittleEndian.AppendUint64(appendTag(s, 6, wireFixed64), math.Float64bits(v))
+	case bool:
+		var b uint64
+		if v {
+			b = 1
+		}
+		s = appendVarintField(appendVarintField(s, 1, scalarBool), 8, b)
+	case string:
+		s = appendVarintField(s, 1, scalarString)
+		s = appendBytesField(s, 9, appendBytesField(nil, 1, []byte(v)))
+	case []byte:
+		s = appendVarintField(s, 1, scalarOctets)
+		s = appendBytesField(s, 5, appendBytesField(nil, 1, v))
+	default:
+		return nil, errors.Errorf("unsupported argument type %T", arg)
+	}
+
+	return appendBytesField(appendVarintField(nil, 1, anyScalar), 2, s), nil
+}
+
+// encodeObjectAny encodes the key/value pairs as Mysqlx.Datatypes.Any holding an object.
+func encodeObjectAny(keys []string, values []interface{}) ([]byte, error) {
+	var obj []byte
+	for i, k := range keys {
+		v, err := encodeScalarAny(values[i])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		obj = appendBytesField(obj, 1, appendBytesField(appendBytesField(nil, 1, []byte(k)), 2, v))
+	}
+
+	return appendBytesField(appendVarintField(nil, 1, anyObject), 3, obj), nil
+}
+
+// decodeScalar decodes a Mysqlx.Datatypes.Scalar into a Go value.
+func decodeScalar(b []byte) (interface{}, error) {
+	var (
+		typ uint64
+		val interface{}
+	)
+	err := walkFields(b, func(field int, v uint64, data []byte) error {
+		switch field {
+		case 1:
+			typ = v
+		case 2:
+			val = unzigzag(v)
+		case 3:
+			val = v
+		case 5, 9:
+			return walkFields(data, func(field int, _ uint64, data []byte) error {
+				if field == 1 {
+					val = string(data)
+				}
+				return nil
+			})
+		case 6:
+			val = math.Float64frombits(v)
+		case 7:
+			val = math.Float32frombits(uint32(v))
+		case 8:
+			val = v != 0
+		}
+		return nil
+	})
+	if typ == scalarNull {
+		return nil, errors.Trace(err)
+	}
+	return val, errors.Trace(err)
+}